@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/android-lewis/dbsmith/internal/importer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importSources []string
+	importFiles   []string
+	importDryRun  bool
+)
+
+var connectionCmd = &cobra.Command{
+	Use:   "connection",
+	Short: "Manage workspace connections",
+}
+
+var connectionImportCmd = &cobra.Command{
+	Use:   "import [name...]",
+	Short: "Import connections from .pgpass, pg_service.conf, .my.cnf and DBeaver",
+	Long: `Import connections defined for other tools into the current workspace.
+
+Without --file the usual locations are read: $PGPASSFILE or ~/.pgpass,
+$PGSERVICEFILE or ~/.pg_service.conf, ~/.my.cnf and DBeaver's
+data-sources.json. Passwords are stored in the secrets store, never in the
+workspace file. DBeaver passwords protected by a master password cannot be
+read. Connections whose name already exists are skipped; pass names to import
+only those.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runConnectionImport,
+}
+
+func init() {
+	connectionImportCmd.Flags().StringSliceVarP(&importSources, "source", "s", nil,
+		"sources to read: pgpass, pg_service, mycnf, dbeaver (default all)")
+	connectionImportCmd.Flags().StringSliceVarP(&importFiles, "file", "f", nil,
+		"read this file instead of the default locations (requires a single --source)")
+	connectionImportCmd.Flags().BoolVarP(&importDryRun, "dry-run", "n", false,
+		"list what would be imported without changing the workspace")
+
+	connectionCmd.AddCommand(connectionImportCmd)
+	rootCmd.AddCommand(connectionCmd)
+}
+
+func runConnectionImport(cmd *cobra.Command, args []string) error {
+	sources := make([]importer.Source, 0, len(importSources))
+	for _, name := range importSources {
+		source, err := importer.ParseSource(name)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source)
+	}
+
+	var candidates []importer.Candidate
+	if len(importFiles) > 0 {
+		if len(sources) != 1 {
+			return fmt.Errorf("--file needs exactly one --source")
+		}
+		for _, path := range importFiles {
+			found, err := importer.ParseFile(sources[0], path)
+			if err != nil {
+				return err
+			}
+			candidates = append(candidates, found...)
+		}
+		candidates = importer.UniqueNames(candidates)
+	} else {
+		var errs []error
+		candidates, errs = importer.Discover(sources...)
+		for _, err := range errs {
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: %v\n", err)
+		}
+	}
+
+	if len(args) > 0 {
+		candidates = selectCandidates(candidates, args)
+	}
+
+	out := cmd.OutOrStdout()
+	if len(candidates) == 0 {
+		fmt.Fprintln(out, "No connections found")
+		return nil
+	}
+	if importDryRun {
+		for _, c := range candidates {
+			fmt.Fprintf(out, "%-24s %-50s %s\n", c.Connection.Name, c.Describe(), c.Origin)
+		}
+		return nil
+	}
+
+	ws, err := openWorkspace(true)
+	if err != nil {
+		return err
+	}
+	secretsMgr, err := openSecrets(cmd)
+	if err != nil {
+		return err
+	}
+
+	result := importer.Import(ws, secretsMgr, candidates)
+	for _, name := range result.Imported {
+		fmt.Fprintf(out, "imported %s\n", name)
+	}
+	for _, name := range result.Skipped {
+		fmt.Fprintf(out, "skipped %s (already exists)\n", name)
+	}
+	failed := make([]string, 0, len(result.Failed))
+	for name := range result.Failed {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	for _, name := range failed {
+		fmt.Fprintf(cmd.ErrOrStderr(), "failed %s: %v\n", name, result.Failed[name])
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d connection(s) could not be imported", len(failed))
+	}
+	return nil
+}
+
+func selectCandidates(candidates []importer.Candidate, names []string) []importer.Candidate {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	var selected []importer.Candidate
+	for _, c := range candidates {
+		if wanted[c.Connection.Name] {
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
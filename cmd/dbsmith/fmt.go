@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/config"
+	"github.com/android-lewis/dbsmith/internal/formatter"
+	"github.com/spf13/cobra"
+)
+
+var errUnformatted = errors.New("some files are not formatted")
+
+var fmtFlags struct {
+	check       bool
+	write       bool
+	dialect     string
+	keywordCase string
+	indent      int
+	commaStyle  string
+	lineWidth   int
+}
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [file...]",
+	Short: "Format SQL files",
+	Long: `Format SQL using the same rules as the editor's format action.
+
+With no files, or "-", SQL is read from stdin and written to stdout.
+Style defaults come from the editor.format section of the config file
+and can be overridden with flags.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runFmt,
+}
+
+func init() {
+	f := fmtCmd.Flags()
+	f.BoolVar(&fmtFlags.check, "check", false, "report files that need formatting and exit non-zero")
+	f.BoolVarP(&fmtFlags.write, "write", "w", false, "write the result back to the source file")
+	f.StringVar(&fmtFlags.dialect, "dialect", "", "SQL dialect: postgres, mysql or sqlite")
+	f.StringVar(&fmtFlags.keywordCase, "keyword-case", "", "keyword case: upper, lower or preserve")
+	f.IntVar(&fmtFlags.indent, "indent", 0, "indent width in spaces")
+	f.StringVar(&fmtFlags.commaStyle, "comma-style", "", "comma placement: trailing or leading")
+	f.IntVar(&fmtFlags.lineWidth, "line-width", 0, "preferred maximum line width")
+
+	rootCmd.AddCommand(fmtCmd)
+}
+
+func runFmt(cmd *cobra.Command, args []string) error {
+	if fmtFlags.check && fmtFlags.write {
+		return errors.New("--check and --write cannot be used together")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	style := cfg.Editor.Format
+	if fmtFlags.keywordCase != "" {
+		style.KeywordCase = fmtFlags.keywordCase
+	}
+	if fmtFlags.indent != 0 {
+		style.Indent = fmtFlags.indent
+	}
+	if fmtFlags.commaStyle != "" {
+		style.CommaStyle = fmtFlags.commaStyle
+	}
+	if fmtFlags.lineWidth != 0 {
+		style.LineWidth = fmtFlags.lineWidth
+	}
+	if err := formatter.ValidateConfig(style); err != nil {
+		return err
+	}
+	opts := formatter.OptionsFromConfig(style)
+
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	unformatted := false
+	for _, path := range args {
+		changed, err := formatSource(cmd, path, opts)
+		if err != nil {
+			return err
+		}
+		if changed && fmtFlags.check {
+			unformatted = true
+			fmt.Fprintln(cmd.OutOrStdout(), displayName(path))
+		}
+	}
+
+	if unformatted {
+		return errUnformatted
+	}
+	return nil
+}
+
+func formatSource(cmd *cobra.Command, path string, opts formatter.Options) (bool, error) {
+	var src []byte
+	var err error
+	if path == "-" {
+		src, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		src, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", displayName(path), err)
+	}
+
+	formatted, err := formatter.Format(string(src), fmtFlags.dialect, opts)
+	if err != nil {
+		return false, fmt.Errorf("failed to format %s: %w", displayName(path), err)
+	}
+	if formatted != "" && !strings.HasSuffix(formatted, "\n") {
+		formatted += "\n"
+	}
+
+	changed := formatted != string(src)
+	switch {
+	case fmtFlags.check:
+	case fmtFlags.write && path != "-":
+		if changed {
+			info, err := os.Stat(path)
+			if err != nil {
+				return false, fmt.Errorf("failed to stat %s: %w", path, err)
+			}
+			if err := os.WriteFile(path, []byte(formatted), info.Mode().Perm()); err != nil {
+				return false, fmt.Errorf("failed to write %s: %w", path, err)
+			}
+		}
+	default:
+		if _, err := io.WriteString(cmd.OutOrStdout(), formatted); err != nil {
+			return false, err
+		}
+	}
+
+	return changed, nil
+}
+
+func displayName(path string) string {
+	if path == "-" {
+		return "<stdin>"
+	}
+	return path
+}
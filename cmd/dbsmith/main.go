@@ -19,6 +19,8 @@ var (
 
 const appName = "dbsmith"
 
+var workspaceFlag string
+
 var rootCmd = &cobra.Command{
 	Use:   appName,
 	Short: "DBSmith - A production-ready database TUI",
@@ -33,11 +35,12 @@ Features:
 - Secure credential storage`,
 	Version: Version,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		application, err := app.New(Version)
+		application, err := app.New(Version, app.Options{Workspace: workspaceFlag})
 		if err != nil {
 			return err
 		}
 		defer func() {
+			application.FlushCompletionUsage()
 			application.Cleanup()
 			if err := logging.Close(); err != nil {
 				logging.Error().Err(err).Msg("Failed to close logger")
@@ -54,6 +57,11 @@ Features:
 	},
 }
 
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&workspaceFlag, "workspace", "W", "",
+		"workspace name or file to open (default $DBSMITH_WORKSPACE, then ~/.config/dbsmith/workspace.yaml)")
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
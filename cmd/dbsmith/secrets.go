@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/config"
+	"github.com/android-lewis/dbsmith/internal/secrets"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var removeMasterPassword bool
+
+// stdinLines is shared between prompts so buffered input is not lost.
+var stdinLines *bufio.Reader
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage stored passwords",
+}
+
+var masterPasswordCmd = &cobra.Command{
+	Use:   "master-password",
+	Short: "Set, change or remove the master password of the secrets file",
+	Long: `Protect the encrypted secrets file with a master password.
+
+Without a master password the encryption key sits in .secrets.key next to
+the secrets, so anyone who can read the config directory can decrypt them.
+With one, the key is derived from the password with Argon2id and is only
+kept in memory while dbsmith is unlocked. Setting it re-encrypts every
+secret and deletes .secrets.key; --remove goes back to a key file.
+
+Only applies when passwords are stored in the encrypted file, i.e. when no
+system keyring is available.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runMasterPassword,
+}
+
+func init() {
+	masterPasswordCmd.Flags().BoolVar(&removeMasterPassword, "remove", false, "remove the master password")
+
+	secretsCmd.AddCommand(masterPasswordCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
+
+// newSecretsManager builds the secrets manager the TUI would use.
+func newSecretsManager() (secrets.Manager, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	mgr, err := secrets.NewManager(configDir, secrets.CommandOptions{
+		Timeout:  cfg.GetSecretsCommandTimeout(),
+		CacheTTL: cfg.GetSecretsCommandCacheTTL(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets store: %w", err)
+	}
+	return mgr, nil
+}
+
+// openSecrets is newSecretsManager, asking for the master password when the
+// secrets file is locked.
+func openSecrets(cmd *cobra.Command) (secrets.Manager, error) {
+	mgr, err := newSecretsManager()
+	if err != nil {
+		return nil, err
+	}
+
+	if lockable, ok := mgr.(secrets.Lockable); ok && lockable.Locked() {
+		password, err := readPassword(cmd, "Master password: ")
+		if err != nil {
+			return nil, err
+		}
+		if err := lockable.Unlock(password); err != nil {
+			return nil, err
+		}
+	}
+	return mgr, nil
+}
+
+func runMasterPassword(cmd *cobra.Command, args []string) error {
+	mgr, err := newSecretsManager()
+	if err != nil {
+		return err
+	}
+	fileSecrets, ok := secrets.FileManager(mgr)
+	if !ok {
+		return errors.New("passwords are stored in the system keyring; a master password only applies to the encrypted secrets file")
+	}
+
+	protected := fileSecrets.Protected()
+	if removeMasterPassword && !protected {
+		return secrets.ErrNoMasterPassword
+	}
+
+	var current string
+	if protected {
+		if current, err = readPassword(cmd, "Current master password: "); err != nil {
+			return err
+		}
+	}
+
+	var next string
+	if !removeMasterPassword {
+		if next, err = readPassword(cmd, "New master password: "); err != nil {
+			return err
+		}
+		if next == "" {
+			return secrets.ErrEmptyMasterPassword
+		}
+		confirm, err := readPassword(cmd, "Repeat new master password: ")
+		if err != nil {
+			return err
+		}
+		if confirm != next {
+			return errors.New("the passwords do not match")
+		}
+	}
+
+	if err := fileSecrets.SetMasterPassword(current, next); err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	switch {
+	case removeMasterPassword:
+		fmt.Fprintln(out, "Master password removed")
+	case protected:
+		fmt.Fprintln(out, "Master password changed")
+	default:
+		fmt.Fprintln(out, "Master password set; secrets re-encrypted and .secrets.key removed")
+	}
+	return nil
+}
+
+// readPassword prompts on stderr and reads a line without echo when stdin is
+// a terminal, or a plain line otherwise so scripts can pipe it in.
+func readPassword(cmd *cobra.Command, prompt string) (string, error) {
+	fmt.Fprint(cmd.ErrOrStderr(), prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		data, err := term.ReadPassword(fd)
+		fmt.Fprintln(cmd.ErrOrStderr())
+		if err != nil {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return string(data), nil
+	}
+
+	if stdinLines == nil {
+		stdinLines = bufio.NewReader(cmd.InOrStdin())
+	}
+	line, err := stdinLines.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/app"
+	"github.com/android-lewis/dbsmith/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+var shareClear bool
+
+var workspaceCmd = &cobra.Command{
+	Use:   "workspace",
+	Short: "Manage workspace files",
+}
+
+var shareCmd = &cobra.Command{
+	Use:   "share <path>",
+	Short: "Layer a shared team workspace under the current workspace",
+	Long: `Layer a shared workspace under the current one.
+
+The shared workspace is a YAML file or a directory holding connections.yaml
+and one .sql file per saved query, usually checked into a team repository.
+It is never written by dbsmith: editing a shared entry saves a personal
+override instead. Paths inside the workspace file's directory are stored
+relative to it.`,
+	Args:          cobra.RangeArgs(0, 1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runShare,
+}
+
+var exportSharedCmd = &cobra.Command{
+	Use:   "export-shared <path>",
+	Short: "Write the workspace as a shared team workspace",
+	Long: `Write every connection, saved query and folder of the current workspace,
+including its shared layer, as a shared workspace.
+
+Passwords, secret references, timestamps and usage statistics are left out
+and entries are sorted, so the result diffs cleanly under version control.
+A path ending in "/" or naming an existing directory gets one .sql file per
+query; anything else is written as a single YAML file.`,
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runExportShared,
+}
+
+func init() {
+	shareCmd.Flags().BoolVar(&shareClear, "clear", false, "remove the shared layer")
+
+	workspaceCmd.AddCommand(shareCmd, exportSharedCmd)
+	rootCmd.AddCommand(workspaceCmd)
+}
+
+// openWorkspace loads the workspace chosen by --workspace or
+// DBSMITH_WORKSPACE, like the TUI does. With create set, a missing workspace
+// file is started empty and saved.
+func openWorkspace(create bool) (*workspace.Manager, error) {
+	registry, err := workspace.LoadRegistry(workspace.GetDefaultRegistryPath())
+	if err != nil {
+		return nil, err
+	}
+
+	selected := workspaceFlag
+	if selected == "" {
+		selected = os.Getenv(workspace.EnvWorkspace)
+	}
+	path, err := registry.ResolvePath(selected)
+	if err != nil {
+		return nil, err
+	}
+	if create {
+		return app.LoadWorkspace(path)
+	}
+	return workspace.Load(path)
+}
+
+func runShare(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(false)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	switch {
+	case shareClear:
+		if err := ws.SetSharedPath(""); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Shared workspace removed")
+		return nil
+	case len(args) == 0:
+		if ws.SharedPath() == "" {
+			fmt.Fprintln(out, "No shared workspace")
+		} else {
+			fmt.Fprintln(out, ws.SharedPath())
+		}
+		return nil
+	}
+
+	shared, err := workspace.AbsPath(args[0])
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(filepath.Dir(ws.GetFilePath()), shared); err == nil && !strings.HasPrefix(rel, "..") {
+		shared = rel
+	}
+
+	if err := ws.SetSharedPath(shared); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Shared workspace: %s\n", ws.SharedPath())
+	return nil
+}
+
+func runExportShared(cmd *cobra.Command, args []string) error {
+	ws, err := openWorkspace(false)
+	if err != nil {
+		return err
+	}
+	if ws.SharedPath() != "" && !ws.HasShared() {
+		fmt.Fprintf(cmd.ErrOrStderr(), "warning: shared workspace %s could not be read and is not included\n", ws.SharedPath())
+	}
+
+	if err := ws.ExportShared(args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Exported %d connections and %d saved queries to %s\n",
+		len(ws.ListConnections()), len(ws.ListSavedQueries()), args[0])
+	return nil
+}
@@ -5,13 +5,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/android-lewis/dbsmith/internal/config"
+	"github.com/android-lewis/dbsmith/internal/constants"
 	"github.com/android-lewis/dbsmith/internal/db"
 	"github.com/android-lewis/dbsmith/internal/executor"
 	"github.com/android-lewis/dbsmith/internal/explorer"
 	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/metadata"
 	"github.com/android-lewis/dbsmith/internal/models"
 	"github.com/android-lewis/dbsmith/internal/secrets"
 	wsmgr "github.com/android-lewis/dbsmith/internal/workspace"
@@ -24,22 +27,39 @@ const (
 	WorkspaceFile  = "workspace.yaml"
 )
 
+// Options selects what New opens at startup.
+type Options struct {
+	// Workspace is a registered workspace name or a workspace file path. When
+	// empty, DBSMITH_WORKSPACE and then the default workspace are used.
+	Workspace string
+}
+
 type App struct {
 	Cleanup func()
 	Context context.Context
 
 	Config         *config.Config
 	Workspace      *wsmgr.Manager
+	Registry       *wsmgr.Registry
 	Connection     *models.Connection
 	SecretsManager secrets.Manager
 	Driver         db.Driver
 	Executor       *executor.QueryExecutor
 	Explorer       *explorer.Explorer
+	Health         *executor.HealthMonitor
+
+	// Metadata caches the schemas, tables and columns of the active
+	// connection for the explorer and autocomplete.
+	Metadata *metadata.Cache
+
+	// OnHealthChange is called from the health monitor's goroutine when the
+	// active connection becomes degraded, is lost or recovers.
+	OnHealthChange func(executor.HealthState)
 
 	configDir string
 }
 
-func New(version string) (*App, error) {
+func New(version string, opts Options) (*App, error) {
 	configDir, err := config.GetConfigDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get config directory: %w", err)
@@ -79,31 +99,108 @@ func New(version string) (*App, error) {
 		Str("config_dir", configDir).
 		Msg("Starting DBSmith")
 
-	ws, secret, err := loadWorkspace(configDir)
+	registry, err := wsmgr.LoadRegistry(filepath.Join(configDir, constants.WorkspaceRegistryFileName))
+	if err != nil {
+		logging.Warn().Err(err).Msg("Failed to load workspace registry, starting with an empty one")
+		registry = &wsmgr.Registry{}
+	}
+
+	selected := opts.Workspace
+	if selected == "" {
+		selected = os.Getenv(wsmgr.EnvWorkspace)
+	}
+	wsPath := filepath.Join(configDir, WorkspaceFile)
+	if selected != "" {
+		if wsPath, err = registry.ResolvePath(selected); err != nil {
+			return nil, err
+		}
+	}
+
+	ws, err := LoadWorkspace(wsPath)
 	if err != nil {
 		logging.Error().Err(err).Msg("Failed to load workspace")
 		return nil, fmt.Errorf("failed to load workspace: %w", err)
 	}
 
+	secret, err := secrets.NewManager(configDir, secrets.CommandOptions{
+		Timeout:  cfg.GetSecretsCommandTimeout(),
+		CacheTTL: cfg.GetSecretsCommandCacheTTL(),
+	})
+	if err != nil {
+		logging.Error().Err(err).Msg("Failed to initialize secrets manager")
+		return nil, fmt.Errorf("failed to initialize secrets manager: %w", err)
+	}
+	if fileSecrets, ok := secrets.FileManager(secret); ok {
+		fileSecrets.SetIdleTimeout(cfg.GetSecretsIdleLock())
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	logging.Info().Msg("Application initialized successfully")
 
-	return &App{
+	a := &App{
 		Config:         cfg,
 		Workspace:      ws,
+		Registry:       registry,
 		SecretsManager: secret,
 		Cleanup:        cancel,
 		Context:        ctx,
 		configDir:      configDir,
-	}, nil
+	}
+	a.rememberWorkspace()
+
+	return a, nil
 }
 
+// SaveWorkspace writes the workspace back to the file it was opened from.
 func (a *App) SaveWorkspace() error {
-	wsPath := filepath.Join(a.configDir, WorkspaceFile)
+	if a.Workspace == nil {
+		return fmt.Errorf("no workspace loaded")
+	}
+	wsPath := a.Workspace.GetFilePath()
+	if wsPath == "" {
+		return fmt.Errorf("workspace file path not set")
+	}
 	return a.Workspace.Save(wsPath)
 }
 
+// OpenWorkspace switches to the workspace file at path, creating it when it
+// does not exist yet. Any open connection belongs to the previous workspace,
+// so callers should disconnect first.
+func (a *App) OpenWorkspace(path string) error {
+	absPath, err := wsmgr.AbsPath(path)
+	if err != nil {
+		return err
+	}
+
+	ws, err := LoadWorkspace(absPath)
+	if err != nil {
+		return err
+	}
+
+	a.Workspace = ws
+	a.rememberWorkspace()
+	return nil
+}
+
+// RecentWorkspaces lists the registered workspaces, most recent first.
+func (a *App) RecentWorkspaces() []wsmgr.RegistryEntry {
+	if a.Registry == nil {
+		return nil
+	}
+	return a.Registry.Recent()
+}
+
+func (a *App) rememberWorkspace() {
+	if a.Registry == nil || a.Workspace == nil || a.Workspace.GetFilePath() == "" {
+		return
+	}
+	a.Registry.Touch(a.Workspace.GetName(), a.Workspace.GetFilePath())
+	if err := a.Registry.Save(); err != nil {
+		logging.Warn().Err(err).Msg("Failed to save workspace registry")
+	}
+}
+
 func (a *App) ConnectToDatabase(conn *models.Connection) error {
 	if conn == nil {
 		logging.Error().Msg("Connection is nil")
@@ -130,7 +227,12 @@ func (a *App) ConnectToDatabase(conn *models.Connection) error {
 	ctx, cancel := context.WithTimeout(a.Context, timeout)
 	defer cancel()
 
-	if err := driver.Connect(ctx, conn, a.SecretsManager); err != nil {
+	// Environment policies can force a read-only session without changing the
+	// saved connection.
+	session := *conn
+	session.ReadOnly = a.policyFor(conn).ReadOnly
+
+	if err := driver.Connect(ctx, &session, a.SecretsManager); err != nil {
 		logging.Error().
 			Err(err).
 			Str("connection_name", conn.Name).
@@ -138,20 +240,113 @@ func (a *App) ConnectToDatabase(conn *models.Connection) error {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	a.FlushCompletionUsage()
+	a.stopHealthMonitor()
+	a.stopMetadataCache()
 	a.Driver = driver
 	a.Connection = conn
 	a.Executor = executor.NewQueryExecutor(driver)
 	a.Explorer = explorer.NewExplorer(driver)
+	a.startHealthMonitor()
+	a.startMetadataCache()
 
 	logging.Info().
 		Str("connection_name", conn.Name).
 		Str("connection_type", string(conn.Type)).
+		Str("environment", string(conn.Environment)).
+		Bool("read_only", session.ReadOnly).
 		Msg("Successfully connected to database")
 
 	return nil
 }
 
+// EnvironmentPolicy returns the guardrails for the active connection.
+func (a *App) EnvironmentPolicy() config.EnvironmentPolicy {
+	return a.policyFor(a.Connection)
+}
+
+func (a *App) policyFor(conn *models.Connection) config.EnvironmentPolicy {
+	if conn == nil || a.Config == nil {
+		return config.EnvironmentPolicy{}
+	}
+	policy := a.Config.EnvironmentPolicy(string(conn.Environment))
+	policy.ReadOnly = policy.ReadOnly || conn.ReadOnly
+	return policy
+}
+
+// HealthState reports the health of the active connection. Connections
+// without a monitor are always healthy.
+func (a *App) HealthState() executor.HealthState {
+	if a.Health == nil {
+		return executor.HealthHealthy
+	}
+	return a.Health.State()
+}
+
+func (a *App) startHealthMonitor() {
+	interval := a.Config.GetHealthCheckInterval()
+	if interval <= 0 {
+		return
+	}
+	a.Health = executor.NewHealthMonitor(a.Executor, interval, func(state executor.HealthState) {
+		if a.OnHealthChange != nil {
+			a.OnHealthChange(state)
+		}
+	})
+	a.Health.Start(a.Context)
+}
+
+func (a *App) stopHealthMonitor() {
+	if a.Health != nil {
+		a.Health.Stop()
+		a.Health = nil
+	}
+}
+
+// startMetadataCache serves metadata from the cache file of the connection
+// right away and refreshes it in the background.
+func (a *App) startMetadataCache() {
+	fingerprint := metadata.Fingerprint(a.Connection)
+	path := ""
+	if a.configDir != "" {
+		path = filepath.Join(a.configDir, constants.MetadataCacheDirName, fingerprint+".json")
+	}
+
+	a.Metadata = metadata.New(a.Explorer, metadata.Options{
+		Path:        path,
+		Fingerprint: fingerprint,
+	})
+	if err := a.Metadata.Load(); err != nil {
+		logging.Warn().Err(err).Str("connection_name", a.Connection.Name).Msg("Failed to load metadata cache")
+	}
+	a.Metadata.Start(a.Context)
+}
+
+func (a *App) stopMetadataCache() {
+	if a.Metadata != nil {
+		a.Metadata.Stop()
+		a.Metadata = nil
+	}
+}
+
+// FlushCompletionUsage saves the completion counts the editor has recorded
+// but not yet written, before switching connection or exiting.
+func (a *App) FlushCompletionUsage() {
+	if a.Workspace == nil {
+		return
+	}
+	if err := a.Workspace.FlushCompletionUsage(); err != nil {
+		logging.Warn().Err(err).Msg("Failed to save completion usage")
+	}
+}
+
 func (a *App) Disconnect() error {
+	a.FlushCompletionUsage()
+	a.stopHealthMonitor()
+	a.stopMetadataCache()
+	if r, ok := a.SecretsManager.(*secrets.Registry); ok {
+		r.Flush()
+	}
 	if a.Driver == nil || !a.Driver.IsConnected() {
 		return nil
 	}
@@ -181,56 +376,45 @@ func (a *App) Disconnect() error {
 	return nil
 }
 
-func loadWorkspace(configDir string) (*wsmgr.Manager, secrets.Manager, error) {
-	wsPath := filepath.Join(configDir, WorkspaceFile)
-
+// LoadWorkspace loads the workspace file at wsPath, creating an empty one
+// named after the file when it does not exist yet.
+func LoadWorkspace(wsPath string) (*wsmgr.Manager, error) {
 	if _, err := os.Stat(wsPath); os.IsNotExist(err) {
-		return createNewWorkspace(configDir, wsPath)
+		return createNewWorkspace(wsPath)
 	}
 
-	return loadExistingWorkspace(configDir, wsPath)
+	return loadExistingWorkspace(wsPath)
 }
 
-func createNewWorkspace(configDir, wsPath string) (*wsmgr.Manager, secrets.Manager, error) {
+func createNewWorkspace(wsPath string) (*wsmgr.Manager, error) {
 	logging.Info().Str("workspace_path", wsPath).Msg("Creating new workspace")
 
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		logging.Error().Err(err).Str("config_dir", configDir).Msg("Failed to create config directory")
-		return nil, nil, fmt.Errorf("failed to create config directory: %w", err)
+	dir := filepath.Dir(wsPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logging.Error().Err(err).Str("workspace_dir", dir).Msg("Failed to create workspace directory")
+		return nil, fmt.Errorf("failed to create workspace directory: %w", err)
 	}
 
 	wsMgr := wsmgr.New()
-	wsMgr.SetName("Default Workspace")
+	wsMgr.SetName(defaultWorkspaceName(wsPath))
 
 	if err := wsMgr.Save(wsPath); err != nil {
 		logging.Error().Err(err).Str("workspace_path", wsPath).Msg("Failed to save workspace")
-		return nil, nil, fmt.Errorf("failed to create workspace file: %w", err)
-	}
-
-	secretsMgr, err := secrets.NewManager(configDir)
-	if err != nil {
-		logging.Error().Err(err).Msg("Failed to initialize secrets manager")
-		return nil, nil, fmt.Errorf("failed to initialize secrets manager: %w", err)
+		return nil, fmt.Errorf("failed to create workspace file: %w", err)
 	}
 
 	logging.Info().Str("workspace_path", wsPath).Msg("New workspace created successfully")
 
-	return wsMgr, secretsMgr, nil
+	return wsMgr, nil
 }
 
-func loadExistingWorkspace(configDir, wsPath string) (*wsmgr.Manager, secrets.Manager, error) {
+func loadExistingWorkspace(wsPath string) (*wsmgr.Manager, error) {
 	logging.Info().Str("workspace_path", wsPath).Msg("Loading existing workspace")
 
 	wsMgr, err := wsmgr.Load(wsPath)
 	if err != nil {
 		logging.Error().Err(err).Str("workspace_path", wsPath).Msg("Failed to load workspace")
-		return nil, nil, fmt.Errorf("failed to load workspace: %w", err)
-	}
-
-	secretsMgr, err := secrets.NewManager(configDir)
-	if err != nil {
-		logging.Error().Err(err).Msg("Failed to initialize secrets manager")
-		return nil, nil, fmt.Errorf("failed to initialize secrets manager: %w", err)
+		return nil, fmt.Errorf("failed to load workspace: %w", err)
 	}
 
 	logging.Info().
@@ -239,5 +423,15 @@ func loadExistingWorkspace(configDir, wsPath string) (*wsmgr.Manager, secrets.Ma
 		Int("connections", len(wsMgr.ListConnections())).
 		Msg("Workspace loaded successfully")
 
-	return wsMgr, secretsMgr, nil
+	return wsMgr, nil
+}
+
+// defaultWorkspaceName names a new workspace after its file, so
+// ~/work/acme.yaml becomes "acme". The default file keeps its old name.
+func defaultWorkspaceName(wsPath string) string {
+	base := strings.TrimSuffix(filepath.Base(wsPath), filepath.Ext(wsPath))
+	if base == "" || filepath.Base(wsPath) == WorkspaceFile {
+		return "Default Workspace"
+	}
+	return base
 }
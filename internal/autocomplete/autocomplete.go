@@ -28,9 +28,35 @@ type Table struct {
 	Columns []Column
 }
 
+// ForeignKey references RefColumns of RefTable from Columns of Table.
+type ForeignKey struct {
+	Schema     string
+	Table      string
+	Columns    []string
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+}
+
 type Context struct {
 	Tables         []Table
 	ColumnsByTable map[string][]Column
+	// Functions are user-defined functions; built-ins come from the dialect.
+	Functions []Function
+	// Schemas lists every schema, or database on MySQL. When empty the
+	// schemas of Tables are used.
+	Schemas []string
+	// SearchPath holds the schemas unqualified names resolve in, in order:
+	// the Postgres search_path or the current MySQL database. Tables in it
+	// are offered without a schema prefix.
+	SearchPath []string
+	// ForeignKeys drive join suggestions.
+	ForeignKeys []ForeignKey
+	// Usage counts accepted completions by UsageKey; used items rank higher.
+	Usage map[string]int
+	// Snippets are user-defined snippets. They replace built-in snippets of
+	// the same name.
+	Snippets []Snippet
 }
 
 type ItemKind int
@@ -39,12 +65,52 @@ const (
 	KindKeyword ItemKind = iota
 	KindTable
 	KindColumn
+	KindFunction
+	KindSchema
+	// KindJoin items are ON predicates. Requested after JOIN, it instead
+	// ranks tables related to the FROM clause first.
+	KindJoin
+	// KindSnippet items insert a Snippet with tab stops. They are offered
+	// wherever keywords are.
+	KindSnippet
 )
 
+func (k ItemKind) String() string {
+	switch k {
+	case KindKeyword:
+		return "keyword"
+	case KindTable:
+		return "table"
+	case KindColumn:
+		return "column"
+	case KindFunction:
+		return "function"
+	case KindSchema:
+		return "schema"
+	case KindJoin:
+		return "join"
+	case KindSnippet:
+		return "snippet"
+	default:
+		return "unknown"
+	}
+}
+
 type Item struct {
 	Label  string
 	Kind   ItemKind
 	Detail string
+	// Function is set for KindFunction items.
+	Function *Function
+	// Snippet is set for KindSnippet items.
+	Snippet *Snippet
+	// Priority orders items of the same kind, highest first.
+	Priority int
+	// Score is how well Label matches the typed word plus a boost for past
+	// use; it orders items of the same kind and priority.
+	Score int
+	// Matches holds the rune offsets in Label matched by the typed word.
+	Matches []int
 }
 
 type Request struct {
@@ -63,11 +129,18 @@ type Analysis struct {
 	Word      string
 	Replace   Range
 	Qualifier string
-	Quote     string
-	Kinds     []ItemKind
-	Tables    []TableRef
-	Aliases   map[string]string
-	Suppress  bool
+	// Schema is set when the cursor follows "schema.table.".
+	Schema  string
+	Quote   string
+	Kinds   []ItemKind
+	Tables  []TableRef
+	Aliases map[string]string
+	// Derived holds the CTEs and FROM subqueries visible at the cursor,
+	// keyed by upper-case name.
+	Derived map[string]DerivedTable
+	// Joining is the table of the JOIN whose ON the cursor follows.
+	Joining  TableRef
+	Suppress bool
 }
 
 func (a Analysis) HasKind(kind ItemKind) bool {
@@ -79,21 +152,31 @@ func (a Analysis) HasKind(kind ItemKind) bool {
 	return false
 }
 
+// TargetTables returns the database tables whose columns completion needs.
+// CTEs and subqueries are replaced by the tables they select * from.
 func (a Analysis) TargetTables() []string {
-	stmt := statement{
-		Tables:      a.Tables,
-		Aliases:     a.Aliases,
-		TableLookup: tableLookupFromRefs(a.Tables),
+	stmt := a.statement()
+	if a.Schema != "" && a.Qualifier != "" {
+		return []string{a.Schema + "." + a.Qualifier}
 	}
 	if a.Qualifier != "" {
 		if target := resolveQualifiedTable(a.Qualifier, stmt); target != "" {
-			return []string{target}
+			return stmt.baseTables([]string{target})
 		}
 	}
 	if len(a.Tables) == 0 {
 		return nil
 	}
-	return dedupeTableNames(a.Tables)
+	return stmt.baseTables(dedupeTableNames(a.Tables))
+}
+
+func (a Analysis) statement() statement {
+	return statement{
+		Tables:      a.Tables,
+		Aliases:     a.Aliases,
+		TableLookup: tableLookupFromRefs(a.Tables),
+		Derived:     a.Derived,
+	}
 }
 
 func Complete(req Request) (Result, error) {
@@ -105,7 +188,7 @@ func Complete(req Request) (Result, error) {
 }
 
 func Analyze(req Request) (Analysis, error) {
-	tokens, err := tokenize(req.SQL, req.Dialect)
+	tokens, err := Tokenize(req.SQL, req.Dialect)
 	if err != nil {
 		return Analysis{}, err
 	}
@@ -122,17 +205,24 @@ func Analyze(req Request) (Analysis, error) {
 	}
 
 	lexemes := buildLexemes(tokens)
-	stmt := parseStatement(lexemes)
-	kinds := detectCompletionKinds(lexemes, req.Position)
+	stmt := visibleAt(req.SQL, req.Dialect, req.Position)
+	completion := detectCompletionKinds(lexemes, req.Position)
+	schema := ""
+	if qualifier != "" {
+		schema, _ = qualifierBeforeCursor(req.SQL, req.Position)
+	}
 
 	return Analysis{
 		Word:      word,
 		Replace:   replaceRange,
 		Qualifier: qualifier,
+		Schema:    schema,
 		Quote:     quote,
-		Kinds:     kinds.kinds,
+		Kinds:     completion.kinds,
 		Tables:    stmt.Tables,
 		Aliases:   stmt.Aliases,
+		Derived:   stmt.Derived,
+		Joining:   completion.joining,
 	}, nil
 }
 
@@ -141,15 +231,14 @@ func CompleteWithAnalysis(analysis Analysis, context Context, dialect string) Re
 		return Result{Replace: analysis.Replace}
 	}
 
-	ctx := completionContext{kinds: analysis.Kinds}
-	stmt := statement{
-		Tables:      analysis.Tables,
-		Aliases:     analysis.Aliases,
-		TableLookup: tableLookupFromRefs(analysis.Tables),
-	}
+	ctx := completionContext{kinds: analysis.Kinds, joining: analysis.Joining}
+	stmt := analysis.statement()
 
-	items := buildCandidates(ctx, stmt, context, dialect, analysis.Qualifier, analysis.Quote)
+	items := buildCandidates(ctx, stmt, context, dialect, qualifiedName{Schema: analysis.Schema, Name: analysis.Qualifier}, analysis.Quote)
 	items = filterCandidates(items, analysis.Word)
+	for i := range items {
+		items[i].Score += usageBoost(context.Usage[UsageKey(items[i])])
+	}
 	sortCandidates(items)
 
 	return Result{
@@ -168,20 +257,34 @@ func sortCandidates(items []Item) {
 		if rankI != rankJ {
 			return rankI < rankJ
 		}
+		if items[i].Priority != items[j].Priority {
+			return items[i].Priority > items[j].Priority
+		}
+		if items[i].Score != items[j].Score {
+			return items[i].Score > items[j].Score
+		}
 		return strings.ToUpper(items[i].Label) < strings.ToUpper(items[j].Label)
 	})
 }
 
 func kindRank(kind ItemKind) int {
 	switch kind {
-	case KindColumn:
+	case KindJoin:
 		return 0
-	case KindTable:
+	case KindColumn:
 		return 1
-	case KindKeyword:
+	case KindTable:
 		return 2
-	default:
+	case KindSchema:
 		return 3
+	case KindFunction:
+		return 4
+	case KindKeyword:
+		return 5
+	case KindSnippet:
+		return 6
+	default:
+		return 7
 	}
 }
 
@@ -189,12 +292,12 @@ func dedupeTableNames(tables []TableRef) []string {
 	seen := map[string]bool{}
 	results := make([]string, 0, len(tables))
 	for _, table := range tables {
-		key := strings.ToUpper(table.Name)
+		key := strings.ToUpper(table.QualifiedName())
 		if seen[key] {
 			continue
 		}
 		seen[key] = true
-		results = append(results, table.Name)
+		results = append(results, table.QualifiedName())
 	}
 	return results
 }
@@ -6,16 +6,41 @@ import (
 	"strings"
 )
 
-func buildCandidates(ctx completionContext, stmt statement, dbContext Context, dialect, qualifier, quote string) []Item {
+func buildCandidates(ctx completionContext, stmt statement, dbContext Context, dialect string, qualifier qualifiedName, quote string) []Item {
 	var items []Item
 
-	if hasKind(ctx.kinds, KindColumn) {
-		items = append(items, columnCandidates(stmt, dbContext, qualifier, quote)...)
+	// A qualifier naming a schema rather than a table in the statement
+	// completes the objects inside that schema.
+	inSchema := false
+	if qualifier.Schema == "" && qualifier.Name != "" && dbContext.hasSchema(qualifier.Name) {
+		inSchema = !hasKind(ctx.kinds, KindColumn) || resolveQualifiedTable(qualifier.Name, stmt) == ""
 	}
-	if hasKind(ctx.kinds, KindTable) {
-		items = append(items, tableCandidates(dbContext, quote)...)
+
+	if hasKind(ctx.kinds, KindJoin) && ctx.joining.Name != "" && qualifier.Name == "" {
+		items = append(items, joinCandidates(stmt, dbContext, dialect, ctx.joining)...)
+	}
+	if hasKind(ctx.kinds, KindColumn) && !inSchema {
+		items = append(items, columnCandidates(stmt, dbContext, dialect, qualifier, quote)...)
+	}
+	if (hasKind(ctx.kinds, KindTable) && qualifier.Name == "") || inSchema {
+		schema := ""
+		if inSchema {
+			schema = qualifier.Name
+		}
+		var related map[string]string
+		if hasKind(ctx.kinds, KindJoin) {
+			related = relatedTables(stmt, dbContext)
+		}
+		items = append(items, tableCandidates(stmt, dbContext, dialect, schema, quote, related)...)
 	}
-	if hasKind(ctx.kinds, KindKeyword) {
+	if hasKind(ctx.kinds, KindSchema) && qualifier.Name == "" {
+		items = append(items, schemaCandidates(dbContext, dialect, quote)...)
+	}
+	if hasKind(ctx.kinds, KindFunction) && qualifier.Schema == "" {
+		items = append(items, functionCandidates(dbContext, dialect, qualifier.Name)...)
+	}
+	if hasKind(ctx.kinds, KindKeyword) && qualifier.Name == "" {
+		items = append(items, snippetCandidates(dbContext, dialect)...)
 		items = append(items, keywordCandidates(dialect)...)
 	}
 
@@ -39,66 +64,101 @@ func keywordCandidates(dialect string) []Item {
 	return items
 }
 
-func tableCandidates(ctx Context, quote string) []Item {
+func schemaCandidates(ctx Context, dialect, quote string) []Item {
+	schemas := ctx.schemaNames()
+	items := make([]Item, 0, len(schemas))
+	for _, schema := range schemas {
+		items = append(items, Item{
+			Label:  identifierLabel(schema, quote, dialect),
+			Kind:   KindSchema,
+			Detail: "schema",
+		})
+	}
+	return items
+}
+
+// tableCandidates lists the tables of schema, or every table when schema is
+// empty. Tables outside the search path are labelled schema.table, and
+// tables in related, keyed by columnsKey, rank first.
+func tableCandidates(stmt statement, ctx Context, dialect, schema, quote string, related map[string]string) []Item {
 	items := make([]Item, 0, len(ctx.Tables))
+	if schema == "" {
+		for _, derived := range stmt.Derived {
+			if !derived.CTE {
+				continue
+			}
+			items = append(items, Item{
+				Label:  identifierLabel(derived.Name, quote, dialect),
+				Kind:   KindTable,
+				Detail: "cte",
+			})
+		}
+	}
+
+	visible := ctx.unqualifiedSchemas()
 	for _, table := range ctx.Tables {
-		label := tableLabel(table, quote)
+		if schema != "" && !strings.EqualFold(table.Schema, schema) {
+			continue
+		}
+		label := identifierLabel(table.Name, quote, dialect)
+		if schema == "" && table.Schema != "" && !strings.EqualFold(visible[strings.ToUpper(table.Name)], table.Schema) {
+			label = identifierLabel(table.Schema, quote, dialect) + "." + label
+		}
 		detail := "table"
 		if table.Schema != "" {
 			detail = "table (" + table.Schema + ")"
 		}
+		priority := 0
+		if via, ok := related[columnsKey(table.Schema, table.Name)]; ok {
+			detail += ", joins " + via
+			priority = 1
+		}
 		items = append(items, Item{
-			Label:  label,
-			Kind:   KindTable,
-			Detail: detail,
+			Label:    label,
+			Kind:     KindTable,
+			Detail:   detail,
+			Priority: priority,
 		})
 	}
 	return items
 }
 
-func columnCandidates(stmt statement, ctx Context, qualifier, quote string) []Item {
-	columnsByTable := ctx.ColumnsByTable
-	if columnsByTable == nil {
-		columnsByTable = make(map[string][]Column, len(ctx.Tables))
-		for _, table := range ctx.Tables {
-			columnsByTable[strings.ToUpper(table.Name)] = table.Columns
-		}
-	}
-
+func columnCandidates(stmt statement, ctx Context, dialect string, qualifier qualifiedName, quote string) []Item {
 	var targetTables []string
-	if qualifier != "" {
-		target := resolveQualifiedTable(qualifier, stmt)
-		if target != "" {
+	switch {
+	case qualifier.Schema != "":
+		targetTables = append(targetTables, qualifier.Schema+"."+qualifier.Name)
+	case qualifier.Name != "":
+		if target := resolveQualifiedTable(qualifier.Name, stmt); target != "" {
 			targetTables = append(targetTables, target)
 		}
 	}
 
-	if len(targetTables) == 0 {
+	if len(targetTables) == 0 && qualifier.Schema == "" {
 		for _, table := range stmt.Tables {
-			targetTables = append(targetTables, table.Name)
+			targetTables = append(targetTables, table.QualifiedName())
 		}
 	}
 
-	if len(targetTables) == 0 {
+	if len(targetTables) == 0 && qualifier.Schema == "" {
 		for _, table := range ctx.Tables {
-			targetTables = append(targetTables, table.Name)
+			targetTables = append(targetTables, TableRef{Name: table.Name, Schema: table.Schema}.QualifiedName())
 		}
 	}
 
 	var items []Item
 	for _, tableName := range dedupeStrings(targetTables) {
-		cols := columnsByTable[strings.ToUpper(tableName)]
+		cols := ctx.columnsFor(tableName)
+		if derived, ok := stmt.derivedTable(tableName); ok {
+			cols = stmt.derivedColumns(derived, ctx)
+		}
 		for _, col := range cols {
-			label := col.Name
-			if quote != "" {
-				label = quote + label + quote
-			}
 			detail := "column"
 			if tableName != "" {
 				detail = "column (" + tableName + ")"
 			}
 			items = append(items, Item{
-				Label:  label,
+				Label:  identifierLabel(col.Name, quote, dialect),
 				Kind:   KindColumn,
 				Detail: detail,
 			})
@@ -107,17 +167,6 @@ func columnCandidates(stmt statement, ctx Context, qualifier, quote string) []It
 	return items
 }
 
-func tableLabel(table Table, quote string) string {
-	name := table.Name
-	if table.Schema != "" {
-		name = table.Schema + "." + table.Name
-	}
-	if quote != "" {
-		return quote + name + quote
-	}
-	return name
-}
-
 func resolveQualifiedTable(qualifier string, stmt statement) string {
 	if qualifier == "" {
 		return ""
@@ -139,26 +188,22 @@ func resolveQualifiedTable(qualifier string, stmt statement) string {
 	return ""
 }
 
+// filterCandidates keeps the items whose label fuzzily matches prefix,
+// recording the match score and positions on each.
 func filterCandidates(items []Item, prefix string) []Item {
 	if prefix == "" {
 		return items
 	}
 
-	upperPrefix := strings.ToUpper(prefix)
 	filtered := make([]Item, 0, len(items))
 	for _, item := range items {
-		value := strings.Trim(item.Label, "`\"")
-		upperValue := strings.ToUpper(value)
-		matched := strings.HasPrefix(upperValue, upperPrefix)
-		if !matched {
-			if dot := strings.LastIndex(value, "."); dot >= 0 && dot+1 < len(value) {
-				suffix := strings.ToUpper(value[dot+1:])
-				matched = strings.HasPrefix(suffix, upperPrefix)
-			}
-		}
-		if matched {
-			filtered = append(filtered, item)
+		score, matches, ok := fuzzyMatch(prefix, item.Label)
+		if !ok {
+			continue
 		}
+		item.Score = score
+		item.Matches = matches
+		filtered = append(filtered, item)
 	}
 	return filtered
 }
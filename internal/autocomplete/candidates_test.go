@@ -27,7 +27,7 @@ func TestColumnCandidatesWithQualifier(t *testing.T) {
 		TableLookup: tableLookupFromRefs([]TableRef{{Name: "users"}, {Name: "orders"}}),
 	}
 
-	items := columnCandidates(stmt, ctx, "u", "")
+	items := columnCandidates(stmt, ctx, "", qualifiedName{Name: "u"}, "")
 	if len(items) != 2 {
 		t.Fatalf("expected 2 columns, got %d", len(items))
 	}
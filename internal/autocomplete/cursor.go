@@ -8,11 +8,16 @@ import (
 )
 
 var wordMatcher = regexp.MustCompile("([`\"]?[\\w$]+)$")
-var qualifierMatcher = regexp.MustCompile(`([A-Za-z_][\w$]*)\.[\w$]*$`)
+
+// qualifierMatcher matches "qualifier." or "schema.qualifier." before the
+// word being typed; either part may be quoted.
+var qualifierMatcher = regexp.MustCompile(`(?:(` + identifierPattern + `)\.)?(` + identifierPattern + `)\.[` + "`" + `"]?[\w$]*$`)
+
+const identifierPattern = `[A-Za-z_][\w$]*|"[^"]+"|` + "`[^`]+`"
 
 func currentWord(tokens []Token, sql string, pos Position) (string, Range, string, string, bool) {
 	replace := Range{Start: pos, End: pos}
-	qualifier := qualifierBeforeCursor(sql, pos)
+	_, qualifier := qualifierBeforeCursor(sql, pos)
 
 	token := tokenAtPosition(tokens, pos)
 	if token != nil && token.Type.InCategory(chroma.Comment) {
@@ -106,13 +111,15 @@ func prefixToPosition(token Token, pos Position) string {
 	return builder.String()
 }
 
-func qualifierBeforeCursor(sql string, pos Position) string {
+// qualifierBeforeCursor returns the unquoted qualifier before the cursor and,
+// for "schema.table.", the schema in front of it.
+func qualifierBeforeCursor(sql string, pos Position) (string, string) {
 	before := textBeforeCursor(sql, pos)
 	matches := qualifierMatcher.FindStringSubmatch(before)
-	if len(matches) > 1 {
-		return matches[1]
+	if len(matches) > 2 {
+		return normalizeIdentifier(matches[1]), normalizeIdentifier(matches[2])
 	}
-	return ""
+	return "", ""
 }
 
 func textBeforeCursor(sql string, pos Position) string {
@@ -43,7 +43,7 @@ func TestAnalyzeQualifierWithoutWord(t *testing.T) {
 
 func TestAnalyzeSuppressInString(t *testing.T) {
 	sql := "SELECT 'name'"
-	tokens, err := tokenize(sql, "postgresql")
+	tokens, err := Tokenize(sql, "postgresql")
 	if err != nil {
 		t.Fatalf("tokenize failed: %v", err)
 	}
@@ -0,0 +1,188 @@
+package autocomplete
+
+import "strings"
+
+// Function is a built-in or user-defined SQL function. A variadic function
+// repeats its last parameter.
+type Function struct {
+	Name     string
+	Schema   string
+	Params   []string
+	Returns  string
+	Variadic bool
+}
+
+// ParamList renders the parameters without the function name, e.g.
+// "(text, delimiter)".
+func (f Function) ParamList() string {
+	params := make([]string, len(f.Params))
+	for i, param := range f.Params {
+		params[i] = f.paramLabel(i, param)
+	}
+	return "(" + strings.Join(params, ", ") + ")"
+}
+
+// Signature renders the full call signature with its return type.
+func (f Function) Signature() string {
+	signature := f.Name + f.ParamList()
+	if f.Returns != "" {
+		signature += " → " + f.Returns
+	}
+	return signature
+}
+
+func (f Function) paramLabel(index int, param string) string {
+	if f.Variadic && index == len(f.Params)-1 {
+		return param + "..."
+	}
+	return param
+}
+
+// fn builds a catalog entry. A trailing "..." on the last parameter marks the
+// function variadic.
+func fn(name, returns string, params ...string) Function {
+	f := Function{Name: name, Params: params, Returns: returns}
+	if n := len(params); n > 0 && strings.HasSuffix(params[n-1], "...") {
+		f.Params = append([]string{}, params...)
+		f.Params[n-1] = strings.TrimSuffix(params[n-1], "...")
+		f.Variadic = true
+	}
+	return f
+}
+
+var baseFunctions = []Function{
+	fn("COUNT", "bigint", "expression"),
+	fn("SUM", "numeric", "expression"),
+	fn("AVG", "numeric", "expression"),
+	fn("MIN", "any", "expression"),
+	fn("MAX", "any", "expression"),
+	fn("COALESCE", "any", "value..."),
+	fn("NULLIF", "any", "value1", "value2"),
+	fn("ABS", "numeric", "number"),
+	fn("ROUND", "numeric", "number", "decimals"),
+	fn("LOWER", "text", "text"),
+	fn("UPPER", "text", "text"),
+	fn("LENGTH", "integer", "text"),
+	fn("TRIM", "text", "text"),
+	fn("REPLACE", "text", "text", "from", "to"),
+	fn("SUBSTR", "text", "text", "start", "length"),
+}
+
+var postgresFunctions = []Function{
+	fn("NOW", "timestamptz"),
+	fn("AGE", "interval", "timestamp", "timestamp"),
+	fn("DATE_TRUNC", "timestamp", "field", "source"),
+	fn("TO_CHAR", "text", "value", "format"),
+	fn("CONCAT", "text", "value..."),
+	fn("SPLIT_PART", "text", "text", "delimiter", "n"),
+	fn("REGEXP_REPLACE", "text", "source", "pattern", "replacement", "flags"),
+	fn("STRING_AGG", "text", "expression", "delimiter"),
+	fn("ARRAY_AGG", "anyarray", "expression"),
+	fn("JSON_AGG", "json", "expression"),
+	fn("JSONB_BUILD_OBJECT", "jsonb", "key_value..."),
+	fn("GENERATE_SERIES", "setof any", "start", "stop", "step"),
+	fn("GREATEST", "any", "value..."),
+	fn("LEAST", "any", "value..."),
+	fn("ROW_NUMBER", "bigint"),
+	fn("RANK", "bigint"),
+	fn("LAG", "any", "value", "offset", "default"),
+	fn("LEAD", "any", "value", "offset", "default"),
+	fn("CURRENT_SETTING", "text", "setting_name"),
+}
+
+var mysqlFunctions = []Function{
+	fn("NOW", "datetime"),
+	fn("DATE_FORMAT", "varchar", "date", "format"),
+	fn("DATEDIFF", "int", "date1", "date2"),
+	fn("STR_TO_DATE", "datetime", "str", "format"),
+	fn("UNIX_TIMESTAMP", "bigint", "date"),
+	fn("FROM_UNIXTIME", "datetime", "timestamp"),
+	fn("IFNULL", "any", "expr1", "expr2"),
+	fn("IF", "any", "condition", "then", "else"),
+	fn("CONCAT", "varchar", "str..."),
+	fn("CONCAT_WS", "varchar", "separator", "str..."),
+	fn("GROUP_CONCAT", "text", "expression"),
+	fn("JSON_EXTRACT", "json", "json_doc", "path..."),
+	fn("JSON_OBJECT", "json", "key_value..."),
+	fn("GREATEST", "any", "value..."),
+	fn("LEAST", "any", "value..."),
+	fn("ROW_NUMBER", "bigint"),
+	fn("LAST_INSERT_ID", "bigint"),
+}
+
+var sqliteFunctions = []Function{
+	fn("DATE", "text", "time_value", "modifier..."),
+	fn("DATETIME", "text", "time_value", "modifier..."),
+	fn("STRFTIME", "text", "format", "time_value", "modifier..."),
+	fn("JULIANDAY", "real", "time_value", "modifier..."),
+	fn("IFNULL", "any", "x", "y"),
+	fn("IIF", "any", "condition", "then", "else"),
+	fn("INSTR", "integer", "text", "substring"),
+	fn("PRINTF", "text", "format", "args..."),
+	fn("GROUP_CONCAT", "text", "expression", "separator"),
+	fn("JSON_EXTRACT", "any", "json", "path..."),
+	fn("TYPEOF", "text", "x"),
+	fn("RANDOM", "integer"),
+	fn("CHANGES", "integer"),
+	fn("LAST_INSERT_ROWID", "integer"),
+}
+
+// BuiltinFunctions returns the built-in function catalog for dialect.
+func BuiltinFunctions(dialect string) []Function {
+	functions := append([]Function{}, baseFunctions...)
+	switch strings.ToLower(dialect) {
+	case "postgres", "postgresql":
+		functions = append(functions, postgresFunctions...)
+	case "mysql":
+		functions = append(functions, mysqlFunctions...)
+	case "sqlite", "sqlite3":
+		functions = append(functions, sqliteFunctions...)
+	}
+	return functions
+}
+
+// SplitArguments splits an argument list as printed by the database into
+// parameters, keeping commas inside type modifiers such as numeric(10,2).
+func SplitArguments(args string) []string {
+	var params []string
+	depth, start := 0, 0
+	for i, r := range args {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				params = append(params, strings.TrimSpace(args[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if last := strings.TrimSpace(args[start:]); last != "" {
+		params = append(params, last)
+	}
+	return params
+}
+
+// functionsFor returns the user-defined functions followed by the built-ins,
+// so a user function shadows a built-in of the same name.
+func functionsFor(ctx Context, dialect string) []Function {
+	return append(append([]Function{}, ctx.Functions...), BuiltinFunctions(dialect)...)
+}
+
+// lookupFunctions returns every overload of name, optionally restricted to
+// schema.
+func lookupFunctions(ctx Context, dialect, schema, name string) []Function {
+	var matches []Function
+	for _, f := range functionsFor(ctx, dialect) {
+		if !strings.EqualFold(f.Name, name) {
+			continue
+		}
+		if schema != "" && !strings.EqualFold(f.Schema, schema) {
+			continue
+		}
+		matches = append(matches, f)
+	}
+	return matches
+}
@@ -0,0 +1,210 @@
+package autocomplete
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompleteFunctionsInExpressions(t *testing.T) {
+	sql := "SELECT coa FROM users"
+	result, err := Complete(Request{
+		SQL:      sql,
+		Position: Position{Line: 0, Column: len("SELECT coa")},
+		Dialect:  "postgresql",
+	})
+	if err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+
+	if !hasItem(result.Items, "COALESCE", KindFunction) {
+		t.Fatalf("expected COALESCE function, got %#v", result.Items)
+	}
+	for _, item := range result.Items {
+		if item.Label == "COALESCE" && item.Detail != "(value...) → any" {
+			t.Errorf("COALESCE detail = %q", item.Detail)
+		}
+	}
+}
+
+func TestCompleteFunctionsNotInFromClause(t *testing.T) {
+	result, err := Complete(Request{
+		SQL:      "SELECT * FROM co",
+		Position: Position{Line: 0, Column: len("SELECT * FROM co")},
+		Dialect:  "postgresql",
+	})
+	if err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	for _, item := range result.Items {
+		if item.Kind == KindFunction {
+			t.Fatalf("unexpected function %q in FROM clause", item.Label)
+		}
+	}
+}
+
+func TestCompleteFunctionsPerDialect(t *testing.T) {
+	tests := []struct {
+		dialect string
+		prefix  string
+		want    string
+		absent  string
+	}{
+		{dialect: "postgresql", prefix: "string_", want: "STRING_AGG"},
+		{dialect: "mysql", prefix: "group_", want: "GROUP_CONCAT"},
+		{dialect: "sqlite", prefix: "strf", want: "STRFTIME"},
+		{dialect: "sqlite", prefix: "string_", absent: "STRING_AGG"},
+	}
+
+	for _, tt := range tests {
+		sql := "SELECT " + tt.prefix
+		result, err := Complete(Request{
+			SQL:      sql,
+			Position: Position{Line: 0, Column: len(sql)},
+			Dialect:  tt.dialect,
+		})
+		if err != nil {
+			t.Fatalf("%s: complete failed: %v", tt.dialect, err)
+		}
+		if tt.want != "" && !hasItem(result.Items, tt.want, KindFunction) {
+			t.Errorf("%s: expected %s, got %#v", tt.dialect, tt.want, result.Items)
+		}
+		if tt.absent != "" && hasItem(result.Items, tt.absent, KindFunction) {
+			t.Errorf("%s: did not expect %s", tt.dialect, tt.absent)
+		}
+	}
+}
+
+func TestCompleteUserFunctionsShadowBuiltins(t *testing.T) {
+	result, err := Complete(Request{
+		SQL:      "SELECT ",
+		Position: Position{Line: 0, Column: len("SELECT ")},
+		Dialect:  "postgresql",
+		Context: Context{
+			Functions: []Function{
+				{Name: "now", Schema: "public", Params: []string{"tz text"}, Returns: "timestamp"},
+				{Name: "full_name", Schema: "public", Params: []string{"u users"}, Returns: "text"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+
+	var nows []Item
+	for _, item := range result.Items {
+		if item.Kind == KindFunction && (item.Label == "now" || item.Label == "NOW") {
+			nows = append(nows, item)
+		}
+	}
+	if len(nows) != 1 || nows[0].Detail != "(tz text) → timestamp" {
+		t.Fatalf("expected the user-defined now only, got %#v", nows)
+	}
+	if !hasItem(result.Items, "full_name", KindFunction) {
+		t.Fatalf("expected full_name, got %#v", result.Items)
+	}
+}
+
+func TestSignatureAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		dialect  string
+		function string
+		argument int
+		active   int
+		ok       bool
+	}{
+		{name: "first argument", sql: "SELECT date_trunc(", dialect: "postgresql", function: "DATE_TRUNC", argument: 0, active: 0, ok: true},
+		{name: "second argument", sql: "SELECT date_trunc('day', ", dialect: "postgresql", function: "DATE_TRUNC", argument: 1, active: 1, ok: true},
+		{name: "comma in string", sql: "SELECT date_trunc('a,b', ", dialect: "postgresql", function: "DATE_TRUNC", argument: 1, active: 1, ok: true},
+		{name: "nested call", sql: "SELECT coalesce(upper(name), lower(", dialect: "postgresql", function: "LOWER", argument: 0, active: 0, ok: true},
+		{name: "bare parens", sql: "SELECT coalesce(a, (b + ", dialect: "postgresql", function: "COALESCE", argument: 1, active: 0, ok: true},
+		{name: "variadic tail", sql: "SELECT concat_ws(',', a, b, ", dialect: "mysql", function: "CONCAT_WS", argument: 3, active: 1, ok: true},
+		{name: "past last param", sql: "SELECT upper(a, b", dialect: "sqlite", function: "UPPER", argument: 1, active: -1, ok: true},
+		{name: "closed call", sql: "SELECT upper(name) ", dialect: "postgresql"},
+		{name: "unknown function", sql: "SELECT * FROM t WHERE id IN (", dialect: "postgresql"},
+		{name: "new statement", sql: "SELECT upper(; SELECT ", dialect: "postgresql"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			help, ok, err := SignatureAt(Request{
+				SQL:      tt.sql,
+				Position: Position{Line: 0, Column: len([]rune(tt.sql))},
+				Dialect:  tt.dialect,
+			})
+			if err != nil {
+				t.Fatalf("signature failed: %v", err)
+			}
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v (%#v)", ok, tt.ok, help)
+			}
+			if !ok {
+				return
+			}
+			if help.Function.Name != tt.function || help.Argument != tt.argument || help.ActiveParam != tt.active {
+				t.Fatalf("got %s arg %d param %d, want %s arg %d param %d",
+					help.Function.Name, help.Argument, help.ActiveParam, tt.function, tt.argument, tt.active)
+			}
+		})
+	}
+}
+
+func TestSignatureAtUserFunction(t *testing.T) {
+	sql := "SELECT billing.net_total(o.id, "
+	help, ok, err := SignatureAt(Request{
+		SQL:      sql,
+		Position: Position{Line: 0, Column: len(sql)},
+		Dialect:  "postgresql",
+		Context: Context{
+			Functions: []Function{
+				{Name: "net_total", Schema: "public", Params: []string{"order_id bigint"}, Returns: "numeric"},
+				{Name: "net_total", Schema: "billing", Params: []string{"order_id bigint", "currency text"}, Returns: "numeric"},
+			},
+		},
+	})
+	if err != nil || !ok {
+		t.Fatalf("expected signature, got ok=%v err=%v", ok, err)
+	}
+	if help.Function.Schema != "billing" || help.ActiveParam != 1 {
+		t.Fatalf("unexpected signature %#v", help)
+	}
+	if got := help.Function.Signature(); got != "net_total(order_id bigint, currency text) → numeric" {
+		t.Fatalf("Signature() = %q", got)
+	}
+}
+
+func TestSignatureAtPicksOverloadByArgument(t *testing.T) {
+	sql := "SELECT area(a, "
+	help, ok, err := SignatureAt(Request{
+		SQL:      sql,
+		Position: Position{Line: 0, Column: len(sql)},
+		Dialect:  "postgresql",
+		Context: Context{
+			Functions: []Function{
+				{Name: "area", Params: []string{"c circle"}, Returns: "double precision"},
+				{Name: "area", Params: []string{"w integer", "h integer"}, Returns: "integer"},
+			},
+		},
+	})
+	if err != nil || !ok {
+		t.Fatalf("expected signature, got ok=%v err=%v", ok, err)
+	}
+	if len(help.Overloads) != 2 || help.Function.Returns != "integer" {
+		t.Fatalf("expected the two-argument overload, got %#v", help)
+	}
+}
+
+func TestSplitArguments(t *testing.T) {
+	tests := map[string][]string{
+		"":                                  nil,
+		"a integer":                         {"a integer"},
+		"a integer, b numeric(10,2)":        {"a integer", "b numeric(10,2)"},
+		" VARIADIC parts text[] , sep text": {"VARIADIC parts text[]", "sep text"},
+	}
+	for input, want := range tests {
+		if got := SplitArguments(input); !reflect.DeepEqual(got, want) {
+			t.Errorf("SplitArguments(%q) = %#v, want %#v", input, got, want)
+		}
+	}
+}
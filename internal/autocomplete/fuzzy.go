@@ -0,0 +1,138 @@
+package autocomplete
+
+import (
+	"math"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+const (
+	fuzzyCharScore        = 1
+	fuzzyStartBonus       = 8
+	fuzzyWordStartBonus   = 6
+	fuzzyConsecutiveBonus = 5
+	fuzzyMaxGapPenalty    = 5
+	fuzzyUsageWeight      = 3
+)
+
+// fuzzyMatch matches pattern against text as a case-insensitive subsequence
+// whose first character starts text or one of its words. It returns the best
+// score and the rune offsets of the matched characters. Matches at word
+// starts and consecutive runs score higher; skipped characters cost a little.
+func fuzzyMatch(pattern, text string) (int, []int, bool) {
+	p := []rune(pattern)
+	t := []rune(text)
+	if len(p) == 0 {
+		return 0, nil, true
+	}
+	if len(p) > len(t) {
+		return 0, nil, false
+	}
+	for i, r := range p {
+		p[i] = unicode.ToLower(r)
+	}
+	lower := make([]rune, len(t))
+	for i, r := range t {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// score[i][j] is the best score for p[:i+1] with p[i] matched at t[j];
+	// from[i][j] is where p[i-1] matched on that path.
+	const none = math.MinInt32
+	score := make([][]int, len(p))
+	from := make([][]int, len(p))
+	for i := range p {
+		score[i] = make([]int, len(t))
+		from[i] = make([]int, len(t))
+		for j := range t {
+			score[i][j] = none
+			if lower[j] != p[i] {
+				continue
+			}
+			bonus := wordStartBonus(t, j)
+			if i == 0 {
+				if bonus == 0 {
+					continue
+				}
+				score[i][j] = fuzzyCharScore + bonus - min(j, fuzzyMaxGapPenalty)
+				from[i][j] = -1
+				continue
+			}
+
+			best, bestK := none, -1
+			for k := i - 1; k < j; k++ {
+				if score[i-1][k] == none {
+					continue
+				}
+				s := score[i-1][k]
+				if k == j-1 {
+					s += fuzzyConsecutiveBonus
+				} else {
+					s -= min(j-k-1, fuzzyMaxGapPenalty)
+				}
+				if s > best {
+					best, bestK = s, k
+				}
+			}
+			if bestK < 0 {
+				continue
+			}
+			score[i][j] = best + fuzzyCharScore + bonus
+			from[i][j] = bestK
+		}
+	}
+
+	last := len(p) - 1
+	best, end := none, -1
+	for j := range t {
+		if score[last][j] > best {
+			best, end = score[last][j], j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	positions := make([]int, len(p))
+	for i, j := last, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	// Prefer the shorter of two otherwise equal matches.
+	best -= min(len(t)-end-1, fuzzyMaxGapPenalty)
+	return best, positions, true
+}
+
+func wordStartBonus(text []rune, i int) int {
+	if i == 0 {
+		return fuzzyStartBonus
+	}
+	prev, cur := text[i-1], text[i]
+	switch {
+	case i == 1 && (prev == '"' || prev == '`'):
+		return fuzzyStartBonus
+	case strings.ContainsRune("_.-$ \"`", prev):
+		return fuzzyWordStartBonus
+	case unicode.IsLower(prev) && unicode.IsUpper(cur):
+		return fuzzyWordStartBonus
+	case !unicode.IsDigit(prev) && unicode.IsDigit(cur):
+		return fuzzyWordStartBonus
+	}
+	return 0
+}
+
+// usageBoost grows with the log of how often an item was accepted, so a few
+// uses matter and heavy use does not drown out match quality.
+func usageBoost(count int) int {
+	if count <= 0 {
+		return 0
+	}
+	return bits.Len(uint(count)) * fuzzyUsageWeight
+}
+
+// UsageKey identifies an item for usage counting, independent of quoting.
+func UsageKey(item Item) string {
+	label := strings.NewReplacer("`", "", `"`, "").Replace(item.Label)
+	return item.Kind.String() + ":" + strings.ToUpper(label)
+}
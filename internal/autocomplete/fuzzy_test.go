@@ -0,0 +1,103 @@
+package autocomplete
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		text    string
+		ok      bool
+		matches []int
+	}{
+		{pattern: "usr_evt", text: "user_events", ok: true, matches: []int{0, 1, 3, 4, 5, 6, 9}},
+		{pattern: "evt", text: "user_events", ok: true, matches: []int{5, 6, 9}},
+		{pattern: "ue", text: "user_events", ok: true, matches: []int{0, 5}},
+		{pattern: "USE", text: "users", ok: true, matches: []int{0, 1, 2}},
+		{pattern: "oi", text: "orderItems", ok: true, matches: []int{0, 5}},
+		{pattern: "ord", text: `public."Orders"`, ok: true, matches: []int{8, 9, 10}},
+		{pattern: "sers", text: "users", ok: false},
+		{pattern: "xyz", text: "users", ok: false},
+		{pattern: "users_all", text: "users", ok: false},
+	}
+
+	for _, tt := range tests {
+		_, matches, ok := fuzzyMatch(tt.pattern, tt.text)
+		if ok != tt.ok {
+			t.Errorf("fuzzyMatch(%q, %q) ok = %v, want %v", tt.pattern, tt.text, ok, tt.ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(matches, tt.matches) {
+			t.Errorf("fuzzyMatch(%q, %q) matches = %v, want %v", tt.pattern, tt.text, matches, tt.matches)
+		}
+	}
+}
+
+func TestFuzzyMatchScoring(t *testing.T) {
+	tests := []struct {
+		pattern       string
+		better, worse string
+	}{
+		{pattern: "use", better: "users", worse: "user_events"},
+		{pattern: "ev", better: "events", worse: "user_events"},
+		{pattern: "ue", better: "user_events", worse: "unique_keys"},
+		{pattern: "ord", better: "orders", worse: "old_records"},
+	}
+	for _, tt := range tests {
+		better, _, _ := fuzzyMatch(tt.pattern, tt.better)
+		worse, _, _ := fuzzyMatch(tt.pattern, tt.worse)
+		if better <= worse {
+			t.Errorf("%q: %q scored %d, not above %q at %d", tt.pattern, tt.better, better, tt.worse, worse)
+		}
+	}
+}
+
+func TestCompleteFuzzyTables(t *testing.T) {
+	ctx := Context{Tables: []Table{{Name: "users"}, {Name: "user_events"}, {Name: "user_sessions"}, {Name: "events"}}}
+	_, result := completeAt(t, "SELECT * FROM usr_evt|", ctx)
+
+	got := labelsOfKind(result.Items, KindTable)
+	if !reflect.DeepEqual(got, []string{"user_events"}) {
+		t.Fatalf("tables = %v", got)
+	}
+	if item := result.Items[0]; len(item.Matches) != len("usr_evt") {
+		t.Fatalf("matches = %v", item.Matches)
+	}
+}
+
+func TestCompleteRanksByUsage(t *testing.T) {
+	ctx := Context{
+		Tables: []Table{{Name: "accounts"}, {Name: "audit_log"}, {Name: "orders"}},
+		Usage:  map[string]int{"table:ORDERS": 5, "table:AUDIT_LOG": 1},
+	}
+
+	_, result := completeAt(t, "SELECT * FROM |", ctx)
+	if got := labelsOfKind(result.Items, KindTable); len(got) != 3 {
+		t.Fatalf("tables = %v", got)
+	}
+	var order []string
+	for _, item := range result.Items {
+		if item.Kind == KindTable {
+			order = append(order, item.Label)
+		}
+	}
+	if !reflect.DeepEqual(order, []string{"orders", "audit_log", "accounts"}) {
+		t.Fatalf("order = %v", order)
+	}
+
+	_, result = completeAt(t, "SELECT * FROM a|", ctx)
+	if first := result.Items[0]; first.Label != "audit_log" {
+		t.Fatalf("first = %+v", first)
+	}
+}
+
+func TestUsageKey(t *testing.T) {
+	if got := UsageKey(Item{Label: `public."Users"`, Kind: KindTable}); got != "table:PUBLIC.USERS" {
+		t.Fatalf("UsageKey = %q", got)
+	}
+	if UsageKey(Item{Label: "id", Kind: KindColumn}) == UsageKey(Item{Label: "id", Kind: KindTable}) {
+		t.Fatal("UsageKey should include the kind")
+	}
+}
@@ -0,0 +1,155 @@
+package autocomplete
+
+import (
+	"regexp"
+	"strings"
+)
+
+var plainIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// qualifiedName is the "schema.name." typed in front of the cursor.
+type qualifiedName struct {
+	Schema string
+	Name   string
+}
+
+// needsQuoting reports whether name must be quoted to be used as written.
+// Postgres folds unquoted names to lower case, so mixed case needs quotes
+// there too.
+func needsQuoting(name, dialect string) bool {
+	if !plainIdentifier.MatchString(name) || isKeyword(strings.ToUpper(name)) {
+		return true
+	}
+	if isPostgresDialect(dialect) {
+		return name != strings.ToLower(name)
+	}
+	return false
+}
+
+// quoteIdentifier quotes name with backticks on MySQL and double quotes
+// elsewhere.
+func quoteIdentifier(name, dialect string) string {
+	quote := `"`
+	if isMySQLDialect(dialect) {
+		quote = "`"
+	}
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+}
+
+// identifierLabel renders name for insertion. A quote the user already typed
+// is kept; otherwise names are quoted only when the dialect requires it.
+func identifierLabel(name, quote, dialect string) string {
+	if quote != "" {
+		return quote + name + quote
+	}
+	if needsQuoting(name, dialect) {
+		return quoteIdentifier(name, dialect)
+	}
+	return name
+}
+
+func isPostgresDialect(dialect string) bool {
+	switch strings.ToLower(dialect) {
+	case "postgres", "postgresql":
+		return true
+	default:
+		return false
+	}
+}
+
+func isMySQLDialect(dialect string) bool {
+	switch strings.ToLower(dialect) {
+	case "mysql", "mariadb":
+		return true
+	default:
+		return false
+	}
+}
+
+func columnsKey(schema, table string) string {
+	if schema == "" {
+		return strings.ToUpper(table)
+	}
+	return strings.ToUpper(schema + "." + table)
+}
+
+func splitQualifiedName(name string) (string, string) {
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		return name[:dot], name[dot+1:]
+	}
+	return "", name
+}
+
+// schemaNames returns Schemas, or the schemas of Tables when it is empty.
+func (c Context) schemaNames() []string {
+	if len(c.Schemas) > 0 {
+		return c.Schemas
+	}
+	var names []string
+	for _, table := range c.Tables {
+		if table.Schema != "" {
+			names = append(names, table.Schema)
+		}
+	}
+	return dedupeStrings(names)
+}
+
+func (c Context) hasSchema(name string) bool {
+	for _, schema := range c.schemaNames() {
+		if strings.EqualFold(schema, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// unqualifiedSchemas maps each table name to the first search_path schema
+// holding it, which is where the bare name resolves.
+func (c Context) unqualifiedSchemas() map[string]string {
+	resolved := map[string]string{}
+	for i := len(c.SearchPath) - 1; i >= 0; i-- {
+		for _, table := range c.Tables {
+			if strings.EqualFold(table.Schema, c.SearchPath[i]) {
+				resolved[strings.ToUpper(table.Name)] = table.Schema
+			}
+		}
+	}
+	return resolved
+}
+
+// columnsFor looks up the columns of name, which may be schema-qualified.
+// Unqualified names are resolved through the search path first.
+func (c Context) columnsFor(name string) []Column {
+	schema, table := splitQualifiedName(name)
+	lookup := func(schema string) ([]Column, bool) {
+		if cols, ok := c.ColumnsByTable[columnsKey(schema, table)]; ok {
+			return cols, true
+		}
+		for _, t := range c.Tables {
+			if strings.EqualFold(t.Schema, schema) && strings.EqualFold(t.Name, table) && len(t.Columns) > 0 {
+				return t.Columns, true
+			}
+		}
+		return nil, false
+	}
+
+	schemas := c.SearchPath
+	if schema != "" {
+		schemas = []string{schema}
+	}
+	for _, s := range schemas {
+		if cols, ok := lookup(s); ok {
+			return cols
+		}
+	}
+
+	if cols, ok := c.ColumnsByTable[columnsKey("", table)]; ok {
+		return cols
+	}
+	for _, t := range c.Tables {
+		if strings.EqualFold(t.Name, table) && len(t.Columns) > 0 {
+			return t.Columns
+		}
+	}
+	return nil
+}
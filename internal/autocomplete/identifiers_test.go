@@ -0,0 +1,137 @@
+package autocomplete
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var schemaContext = Context{
+	Tables: []Table{
+		{Name: "users", Schema: "public"},
+		{Name: "users", Schema: "app"},
+		{Name: "events", Schema: "analytics"},
+		{Name: "Accounts", Schema: "app"},
+	},
+	ColumnsByTable: map[string][]Column{
+		"PUBLIC.USERS":     {{Name: "id"}, {Name: "email"}},
+		"APP.USERS":        {{Name: "id"}, {Name: "tenant_id"}},
+		"ANALYTICS.EVENTS": {{Name: "event_id"}, {Name: "payload"}},
+	},
+	Schemas:    []string{"analytics", "app", "public"},
+	SearchPath: []string{"app", "public"},
+}
+
+func labelsOfKind(items []Item, kind ItemKind) []string {
+	var labels []string
+	for _, item := range items {
+		if item.Kind == kind {
+			labels = append(labels, item.Label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func TestCompleteTablesRespectSearchPath(t *testing.T) {
+	_, result := completeAt(t, "SELECT * FROM |", schemaContext)
+
+	want := []string{`"Accounts"`, "analytics.events", "public.users", "users"}
+	if got := labelsOfKind(result.Items, KindTable); !reflect.DeepEqual(got, want) {
+		t.Fatalf("tables = %v, want %v", got, want)
+	}
+	if got := labelsOfKind(result.Items, KindSchema); !reflect.DeepEqual(got, []string{"analytics", "app", "public"}) {
+		t.Fatalf("schemas = %v", got)
+	}
+}
+
+func TestCompleteTablesAfterSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{name: "from clause", sql: "SELECT * FROM analytics.|", want: []string{"events"}},
+		{name: "with prefix", sql: "SELECT * FROM app.us|", want: []string{"users"}},
+		{name: "select list", sql: "SELECT public.| FROM users", want: []string{"users"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, result := completeAt(t, tt.sql, schemaContext)
+			if got := labelsOfKind(result.Items, KindTable); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("tables = %v, want %v", got, tt.want)
+			}
+			for _, item := range result.Items {
+				if item.Kind == KindKeyword || item.Kind == KindSchema || item.Kind == KindColumn {
+					t.Fatalf("unexpected %v item %q after schema qualifier", item.Kind, item.Label)
+				}
+			}
+		})
+	}
+}
+
+func TestCompleteColumnsAfterSchemaTable(t *testing.T) {
+	analysis, result := completeAt(t, "SELECT analytics.events.| FROM analytics.events", schemaContext)
+
+	if analysis.Schema != "analytics" || analysis.Qualifier != "events" {
+		t.Fatalf("schema = %q, qualifier = %q", analysis.Schema, analysis.Qualifier)
+	}
+	if got := analysis.TargetTables(); !reflect.DeepEqual(got, []string{"analytics.events"}) {
+		t.Fatalf("target tables = %v", got)
+	}
+	if got := columnLabels(result.Items); !reflect.DeepEqual(got, []string{"event_id", "payload"}) {
+		t.Fatalf("columns = %v", got)
+	}
+}
+
+func TestCompleteColumnsResolveThroughSearchPath(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{sql: "SELECT | FROM users", want: []string{"id", "tenant_id"}},
+		{sql: "SELECT u.| FROM public.users u", want: []string{"email", "id"}},
+		{sql: "SELECT users.| FROM public.users", want: []string{"email", "id"}},
+	}
+
+	for _, tt := range tests {
+		_, result := completeAt(t, tt.sql, schemaContext)
+		if got := columnLabels(result.Items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: columns = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestIdentifierLabel(t *testing.T) {
+	tests := []struct {
+		name    string
+		quote   string
+		dialect string
+		want    string
+	}{
+		{name: "users", dialect: "postgresql", want: "users"},
+		{name: "Users", dialect: "postgresql", want: `"Users"`},
+		{name: "Users", dialect: "mysql", want: "Users"},
+		{name: "order", dialect: "mysql", want: "`order`"},
+		{name: "order", dialect: "sqlite", want: `"order"`},
+		{name: "my table", dialect: "postgresql", want: `"my table"`},
+		{name: `say "hi"`, dialect: "postgresql", want: `"say ""hi"""`},
+		{name: "users", quote: "`", dialect: "mysql", want: "`users`"},
+	}
+
+	for _, tt := range tests {
+		if got := identifierLabel(tt.name, tt.quote, tt.dialect); got != tt.want {
+			t.Errorf("identifierLabel(%q, %q, %q) = %s, want %s", tt.name, tt.quote, tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestFilterMatchesQuotedLabels(t *testing.T) {
+	items := []Item{{Label: `"Accounts"`}, {Label: `public."Orders"`}, {Label: "users"}}
+
+	got := filterCandidates(items, "ord")
+	if len(got) != 1 || got[0].Label != `public."Orders"` {
+		t.Fatalf("filtered = %#v", got)
+	}
+}
@@ -0,0 +1,146 @@
+package autocomplete
+
+import "strings"
+
+// joinCandidates suggests ON predicates between the table being joined and
+// the other tables in scope. Foreign keys are used when they link the pair;
+// otherwise "<table>_id" columns are matched against "id".
+func joinCandidates(stmt statement, ctx Context, dialect string, joining TableRef) []Item {
+	var items []Item
+	for _, other := range stmt.Tables {
+		if sameTableRef(other, joining) {
+			continue
+		}
+
+		predicates := foreignKeyPredicates(ctx, dialect, joining, other)
+		detail := "join (foreign key)"
+		if len(predicates) == 0 {
+			predicates = namePredicates(stmt, ctx, dialect, joining, other)
+			detail = "join (by name)"
+		}
+		for _, predicate := range predicates {
+			items = append(items, Item{
+				Label:  predicate,
+				Kind:   KindJoin,
+				Detail: detail,
+			})
+		}
+	}
+	return items
+}
+
+func foreignKeyPredicates(ctx Context, dialect string, joining, other TableRef) []string {
+	var predicates []string
+	for _, fk := range ctx.ForeignKeys {
+		if len(fk.Columns) == 0 || len(fk.Columns) != len(fk.RefColumns) {
+			continue
+		}
+		if refersTo(joining, fk.Schema, fk.Table) && refersTo(other, fk.RefSchema, fk.RefTable) {
+			predicates = append(predicates, joinPredicate(dialect, joining, fk.Columns, other, fk.RefColumns))
+		}
+		if refersTo(joining, fk.RefSchema, fk.RefTable) && refersTo(other, fk.Schema, fk.Table) {
+			predicates = append(predicates, joinPredicate(dialect, joining, fk.RefColumns, other, fk.Columns))
+		}
+	}
+	return dedupeStrings(predicates)
+}
+
+func namePredicates(stmt statement, ctx Context, dialect string, joining, other TableRef) []string {
+	joiningColumns := stmt.columnsOf(joining, ctx)
+	otherColumns := stmt.columnsOf(other, ctx)
+
+	var predicates []string
+	if fk, ok := referenceColumn(joiningColumns, other.Name); ok {
+		if id, ok := findColumn(otherColumns, "id"); ok {
+			predicates = append(predicates, joinPredicate(dialect, joining, []string{fk}, other, []string{id}))
+		}
+	}
+	if fk, ok := referenceColumn(otherColumns, joining.Name); ok {
+		if id, ok := findColumn(joiningColumns, "id"); ok {
+			predicates = append(predicates, joinPredicate(dialect, joining, []string{id}, other, []string{fk}))
+		}
+	}
+	return dedupeStrings(predicates)
+}
+
+// relatedTables maps the columnsKey of every table with a foreign key to or
+// from a table in scope to the name of that table.
+func relatedTables(stmt statement, ctx Context) map[string]string {
+	related := map[string]string{}
+	for _, ref := range stmt.Tables {
+		for _, fk := range ctx.ForeignKeys {
+			if refersTo(ref, fk.Schema, fk.Table) {
+				related[columnsKey(fk.RefSchema, fk.RefTable)] = ref.Name
+			}
+			if refersTo(ref, fk.RefSchema, fk.RefTable) {
+				related[columnsKey(fk.Schema, fk.Table)] = ref.Name
+			}
+		}
+	}
+	return related
+}
+
+func joinPredicate(dialect string, left TableRef, leftColumns []string, right TableRef, rightColumns []string) string {
+	parts := make([]string, len(leftColumns))
+	for i := range leftColumns {
+		parts[i] = tableRefLabel(left, dialect) + "." + identifierLabel(leftColumns[i], "", dialect) + " = " +
+			tableRefLabel(right, dialect) + "." + identifierLabel(rightColumns[i], "", dialect)
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// tableRefLabel is how columns of ref are qualified: by alias when it has one.
+func tableRefLabel(ref TableRef, dialect string) string {
+	if ref.Alias != "" {
+		return identifierLabel(ref.Alias, "", dialect)
+	}
+	label := identifierLabel(ref.Name, "", dialect)
+	if ref.Schema != "" {
+		label = identifierLabel(ref.Schema, "", dialect) + "." + label
+	}
+	return label
+}
+
+func refersTo(ref TableRef, schema, table string) bool {
+	if !strings.EqualFold(ref.Name, table) {
+		return false
+	}
+	return ref.Schema == "" || schema == "" || strings.EqualFold(ref.Schema, schema)
+}
+
+func sameTableRef(a, b TableRef) bool {
+	return strings.EqualFold(a.Name, b.Name) && strings.EqualFold(a.Schema, b.Schema) && strings.EqualFold(a.Alias, b.Alias)
+}
+
+// referenceColumn finds the "<table>_id" column of columns, trying the
+// singular form of table first.
+func referenceColumn(columns []Column, table string) (string, bool) {
+	for _, name := range []string{singular(table) + "_id", strings.TrimSuffix(table, "s") + "_id", table + "_id"} {
+		if column, ok := findColumn(columns, name); ok {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+func findColumn(columns []Column, name string) (string, bool) {
+	for _, column := range columns {
+		if strings.EqualFold(column.Name, name) {
+			return column.Name, true
+		}
+	}
+	return "", false
+}
+
+func singular(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "ies"):
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(lower, "ses"), strings.HasSuffix(lower, "xes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss"):
+		return name[:len(name)-1]
+	}
+	return name
+}
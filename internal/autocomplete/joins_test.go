@@ -0,0 +1,148 @@
+package autocomplete
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var joinContext = Context{
+	Tables: []Table{
+		{Name: "customers", Columns: []Column{{Name: "id"}, {Name: "name"}}},
+		{Name: "orders", Columns: []Column{{Name: "id"}, {Name: "customer_id"}, {Name: "total"}}},
+		{Name: "order_items", Columns: []Column{{Name: "id"}, {Name: "order_id"}, {Name: "product_id"}}},
+		{Name: "products", Columns: []Column{{Name: "id"}, {Name: "name"}}},
+		{Name: "shipments", Columns: []Column{{Name: "order_id"}, {Name: "region"}}},
+		{Name: "regions", Columns: []Column{{Name: "order_id"}, {Name: "region"}}},
+	},
+	ForeignKeys: []ForeignKey{
+		{Table: "orders", Columns: []string{"customer_id"}, RefTable: "customers", RefColumns: []string{"id"}},
+		{Table: "order_items", Columns: []string{"order_id"}, RefTable: "orders", RefColumns: []string{"id"}},
+		{Table: "shipments", Columns: []string{"order_id", "region"}, RefTable: "regions", RefColumns: []string{"order_id", "region"}},
+	},
+}
+
+func TestCompleteJoinPredicates(t *testing.T) {
+	tests := []struct {
+		name   string
+		sql    string
+		want   []string
+		detail string
+	}{
+		{
+			name:   "foreign key from joined table",
+			sql:    "SELECT * FROM customers c JOIN orders o ON |",
+			want:   []string{"o.customer_id = c.id"},
+			detail: "join (foreign key)",
+		},
+		{
+			name:   "foreign key to joined table",
+			sql:    "SELECT * FROM order_items oi LEFT JOIN orders AS o ON |",
+			want:   []string{"o.id = oi.order_id"},
+			detail: "join (foreign key)",
+		},
+		{
+			name:   "unaliased tables",
+			sql:    "SELECT * FROM customers JOIN orders ON |",
+			want:   []string{"orders.customer_id = customers.id"},
+			detail: "join (foreign key)",
+		},
+		{
+			name:   "composite key",
+			sql:    "SELECT * FROM regions r JOIN shipments s ON |",
+			want:   []string{"s.order_id = r.order_id AND s.region = r.region"},
+			detail: "join (foreign key)",
+		},
+		{
+			name:   "name heuristic",
+			sql:    "SELECT * FROM order_items oi JOIN products p ON |",
+			want:   []string{"p.id = oi.product_id"},
+			detail: "join (by name)",
+		},
+		{
+			name:   "every table in scope",
+			sql:    "SELECT * FROM customers c JOIN orders o ON o.customer_id = c.id JOIN order_items i ON |",
+			want:   []string{"i.order_id = o.id"},
+			detail: "join (foreign key)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, result := completeAt(t, tt.sql, joinContext)
+			got := labelsOfKind(result.Items, KindJoin)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("predicates = %v, want %v", got, tt.want)
+			}
+			if first := result.Items[0]; first.Kind != KindJoin || first.Detail != tt.detail {
+				t.Fatalf("first item = %+v, want a %q predicate", first, tt.detail)
+			}
+		})
+	}
+}
+
+func TestCompleteJoinPredicatesNeedON(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT * FROM customers c JOIN orders o ON o.|",
+		"SELECT * FROM customers c JOIN orders o ON o.customer_id = |",
+		"SELECT * FROM customers c JOIN orders o WHERE |",
+		"SELECT * FROM customers c, orders o WHERE c.id = o.customer_id AND |",
+	} {
+		_, result := completeAt(t, sql, joinContext)
+		if got := labelsOfKind(result.Items, KindJoin); len(got) != 0 {
+			t.Errorf("%q: unexpected predicates %v", sql, got)
+		}
+	}
+}
+
+func TestAnalyzeJoiningTable(t *testing.T) {
+	analysis, _ := completeAt(t, "SELECT * FROM customers c LEFT JOIN public.orders o ON cu|", joinContext)
+
+	want := TableRef{Name: "orders", Schema: "public", Alias: "o"}
+	if analysis.Joining != want {
+		t.Fatalf("joining = %+v, want %+v", analysis.Joining, want)
+	}
+}
+
+func TestCompleteRelatedTablesAfterJoin(t *testing.T) {
+	_, result := completeAt(t, "SELECT * FROM orders o JOIN |", joinContext)
+
+	var tables []Item
+	for _, item := range result.Items {
+		if item.Kind == KindTable {
+			tables = append(tables, item)
+		}
+	}
+	if len(tables) < 2 {
+		t.Fatalf("tables = %+v", tables)
+	}
+	for _, item := range tables[:2] {
+		if !strings.HasSuffix(item.Detail, "joins orders") || item.Priority == 0 {
+			t.Fatalf("expected related tables first, got %+v", tables)
+		}
+	}
+	if got := []string{tables[0].Label, tables[1].Label}; !reflect.DeepEqual(got, []string{"customers", "order_items"}) {
+		t.Fatalf("related tables = %v", got)
+	}
+	for _, item := range tables[2:] {
+		if item.Priority != 0 {
+			t.Fatalf("unrelated table ranked up: %+v", item)
+		}
+	}
+}
+
+func TestSingular(t *testing.T) {
+	tests := map[string]string{
+		"users":     "user",
+		"companies": "company",
+		"addresses": "address",
+		"boxes":     "box",
+		"glass":     "glass",
+		"person":    "person",
+	}
+	for in, want := range tests {
+		if got := singular(in); got != want {
+			t.Errorf("singular(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
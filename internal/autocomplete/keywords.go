@@ -3,6 +3,7 @@ package autocomplete
 import "strings"
 
 var baseKeywords = []string{
+	"WITH",
 	"SELECT",
 	"FROM",
 	"WHERE",
@@ -12,6 +13,8 @@ var baseKeywords = []string{
 	"INTO",
 	"VALUES",
 	"JOIN",
+	"ON",
+	"USING",
 	"LEFT",
 	"RIGHT",
 	"INNER",
@@ -38,6 +41,7 @@ var baseKeywords = []string{
 	"THEN",
 	"ELSE",
 	"END",
+	"UNION",
 }
 
 var postgresKeywords = []string{
@@ -1,12 +1,12 @@
 package autocomplete
 
 import (
-	"strings"
-
 	"github.com/alecthomas/chroma/v2"
-	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/android-lewis/dbsmith/internal/sqlparse"
 )
 
+// Token is a lexical token produced by the chroma SQL lexer, annotated with
+// line/column positions.
 type Token struct {
 	Type  chroma.TokenType
 	Value string
@@ -14,12 +14,10 @@ type Token struct {
 	End   Position
 }
 
-func tokenize(sql, dialect string) ([]Token, error) {
-	lexerName := mapDialectToLexer(dialect)
-	lexer := lexers.Get(lexerName)
-	if lexer == nil {
-		lexer = lexers.Get("sql")
-	}
+// Tokenize splits sql into tokens using the lexer for dialect, falling back
+// to the generic SQL lexer.
+func Tokenize(sql, dialect string) ([]Token, error) {
+	lexer := sqlparse.Lexer(dialect)
 	if lexer == nil {
 		return nil, ErrLexerNotFound
 	}
@@ -55,19 +53,6 @@ func tokenize(sql, dialect string) ([]Token, error) {
 	return tokens, nil
 }
 
-func mapDialectToLexer(dialect string) string {
-	switch strings.ToLower(dialect) {
-	case "postgres", "postgresql":
-		return "postgresql"
-	case "mysql":
-		return "mysql"
-	case "sqlite", "sqlite3":
-		return "sqlite3"
-	default:
-		return "sql"
-	}
-}
-
 func advancePosition(start Position, text string) Position {
 	line := start.Line
 	col := start.Column
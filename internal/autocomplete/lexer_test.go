@@ -6,7 +6,7 @@ import (
 )
 
 func TestTokenizeDialectFallback(t *testing.T) {
-	tokens, err := tokenize("SELECT 1", "unknown")
+	tokens, err := Tokenize("SELECT 1", "unknown")
 	if err != nil {
 		t.Fatalf("tokenize failed: %v", err)
 	}
@@ -17,7 +17,7 @@ func TestTokenizeDialectFallback(t *testing.T) {
 
 func TestTokenizePositions(t *testing.T) {
 	sql := "SELECT\nFROM users"
-	tokens, err := tokenize(sql, "postgresql")
+	tokens, err := Tokenize(sql, "postgresql")
 	if err != nil {
 		t.Fatalf("tokenize failed: %v", err)
 	}
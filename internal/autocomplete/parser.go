@@ -27,14 +27,24 @@ type TableRef struct {
 	Alias  string
 }
 
+// QualifiedName returns schema.name, or just the name without a schema.
+func (t TableRef) QualifiedName() string {
+	if t.Schema == "" {
+		return t.Name
+	}
+	return t.Schema + "." + t.Name
+}
+
 type statement struct {
 	Tables      []TableRef
 	Aliases     map[string]string
 	TableLookup map[string]string
+	Derived     map[string]DerivedTable
 }
 
 type completionContext struct {
-	kinds []ItemKind
+	kinds   []ItemKind
+	joining TableRef
 }
 
 type clauseKind int
@@ -66,7 +76,9 @@ func buildLexemes(tokens []Token) []lexeme {
 			continue
 		}
 
-		if isPunctuationLiteral(trimmed) {
+		// Some lexers split strings into pieces, so a quoted ',' must not be
+		// taken for punctuation.
+		if isPunctuationLiteral(trimmed) && !token.Type.InSubCategory(chroma.LiteralString) {
 			lexemes = append(lexemes, lexeme{
 				Kind:  lexemePunctuation,
 				Value: trimmed,
@@ -104,71 +116,16 @@ func buildLexemes(tokens []Token) []lexeme {
 	return lexemes
 }
 
-func parseStatement(lexemes []lexeme) statement {
-	tables, aliases := extractTables(lexemes)
-	return statement{
-		Tables:      tables,
-		Aliases:     aliases,
-		TableLookup: tableLookupFromRefs(tables),
-	}
-}
-
 func tableLookupFromRefs(tables []TableRef) map[string]string {
 	tableLookup := map[string]string{}
 	for _, table := range tables {
-		key := strings.ToUpper(table.Name)
-		if _, ok := tableLookup[key]; !ok {
-			tableLookup[key] = table.Name
-		}
-	}
-	return tableLookup
-}
-
-func extractTables(lexemes []lexeme) ([]TableRef, map[string]string) {
-	var tables []TableRef
-	aliases := map[string]string{}
-
-	for i := 0; i < len(lexemes); i++ {
-		lex := lexemes[i]
-		if lex.Kind != lexemeKeyword || !isTableStartKeyword(lex.Value) {
-			continue
-		}
-
-		refs, next := parseTablesAfter(lexemes, i+1)
-		for _, ref := range refs {
-			tables = append(tables, ref)
-			if ref.Alias != "" {
-				aliases[strings.ToUpper(ref.Alias)] = ref.Name
+		for _, key := range []string{strings.ToUpper(table.Name), strings.ToUpper(table.QualifiedName())} {
+			if _, ok := tableLookup[key]; !ok {
+				tableLookup[key] = table.QualifiedName()
 			}
 		}
-		i = next
-	}
-
-	return tables, aliases
-}
-
-func parseTablesAfter(lexemes []lexeme, start int) ([]TableRef, int) {
-	var refs []TableRef
-	i := start
-	for i < len(lexemes) {
-		lex := lexemes[i]
-		if lex.Kind == lexemeKeyword && isTableStopKeyword(lex.Value) {
-			break
-		}
-		if lex.Kind == lexemePunctuation && strings.Contains(lex.Value, ",") {
-			i++
-			continue
-		}
-		if lex.Kind != lexemeIdentifier {
-			i++
-			continue
-		}
-
-		ref, next := parseSingleTable(lexemes, i)
-		refs = append(refs, ref)
-		i = next + 1
 	}
-	return refs, i - 1
+	return tableLookup
 }
 
 func parseSingleTable(lexemes []lexeme, start int) (TableRef, int) {
@@ -209,8 +166,9 @@ func detectCompletionKinds(lexemes []lexeme, pos Position) completionContext {
 		return completionContext{kinds: []ItemKind{KindColumn, KindKeyword}}
 	}
 
-	depth := 0
-	clause := clauseUnknown
+	// Each parenthesis level starts in its parent's clause, so a function
+	// call keeps it while a subquery sets its own.
+	clauses := []clauseKind{clauseUnknown}
 
 	for _, lex := range lexemes {
 		if !posAfterStart(pos, lex.Start) {
@@ -218,53 +176,100 @@ func detectCompletionKinds(lexemes []lexeme, pos Position) completionContext {
 		}
 
 		if lex.Kind == lexemePunctuation {
-			depth += strings.Count(lex.Value, "(")
-			depth -= strings.Count(lex.Value, ")")
-			if depth < 0 {
-				depth = 0
+			for _, r := range lex.Value {
+				switch {
+				case r == '(':
+					clauses = append(clauses, clauses[len(clauses)-1])
+				case r == ')' && len(clauses) > 1:
+					clauses = clauses[:len(clauses)-1]
+				}
 			}
 			continue
 		}
 
-		if depth > 0 || lex.Kind != lexemeKeyword {
+		if lex.Kind != lexemeKeyword {
 			continue
 		}
 
+		clause := &clauses[len(clauses)-1]
 		switch lex.Value {
 		case "SELECT":
-			clause = clauseSelect
+			*clause = clauseSelect
 		case "FROM":
-			clause = clauseFrom
+			*clause = clauseFrom
 		case "WHERE":
-			clause = clauseWhere
+			*clause = clauseWhere
 		case "JOIN":
-			clause = clauseJoin
+			*clause = clauseJoin
 		case "UPDATE":
-			clause = clauseUpdate
+			*clause = clauseUpdate
 		case "INTO":
-			clause = clauseInto
+			*clause = clauseInto
 		case "DELETE":
-			clause = clauseDelete
+			*clause = clauseDelete
 		case "SET":
-			clause = clauseSet
+			*clause = clauseSet
 		case "GROUP":
-			clause = clauseGroup
+			*clause = clauseGroup
 		case "ORDER":
-			clause = clauseOrder
+			*clause = clauseOrder
 		case "HAVING":
-			clause = clauseHaving
+			*clause = clauseHaving
+		case "ON", "USING":
+			*clause = clauseWhere
+		case "WITH":
+			*clause = clauseUnknown
 		}
 	}
 
 	kinds := []ItemKind{KindKeyword}
-	switch clause {
+	switch clauses[len(clauses)-1] {
 	case clauseSelect, clauseWhere, clauseSet, clauseGroup, clauseOrder, clauseHaving:
-		kinds = append(kinds, KindColumn)
+		kinds = append(kinds, KindColumn, KindFunction)
 	case clauseFrom, clauseJoin, clauseUpdate, clauseInto, clauseDelete:
-		kinds = append(kinds, KindTable)
+		kinds = append(kinds, KindTable, KindSchema)
 	}
 
-	return completionContext{kinds: kinds}
+	result := completionContext{kinds: kinds}
+	if clauses[len(clauses)-1] == clauseJoin {
+		result.kinds = append(result.kinds, KindJoin)
+	}
+	if joining, ok := joiningTable(lexemes, pos); ok {
+		result.kinds = append(result.kinds, KindJoin)
+		result.joining = joining
+	}
+	return result
+}
+
+// joiningTable returns the table of "JOIN table [alias] ON" when that ON is
+// the last thing before the word at pos.
+func joiningTable(lexemes []lexeme, pos Position) (TableRef, bool) {
+	on := -1
+	for i, lex := range lexemes {
+		if !posAfterStart(pos, lex.End) || (lex.End == pos && lex.Kind == lexemeIdentifier) {
+			break
+		}
+		on = i
+	}
+	if on < 0 || lexemes[on].Kind != lexemeKeyword || lexemes[on].Value != "ON" {
+		return TableRef{}, false
+	}
+
+	for i := on - 1; i >= 0; i-- {
+		lex := lexemes[i]
+		if lex.Kind == lexemePunctuation && lex.Value != "." {
+			return TableRef{}, false
+		}
+		if lex.Kind != lexemeKeyword || lex.Value == "AS" {
+			continue
+		}
+		if lex.Value != "JOIN" || lexemes[i+1].Kind != lexemeIdentifier {
+			return TableRef{}, false
+		}
+		ref, _ := parseSingleTable(lexemes, i+1)
+		return ref, true
+	}
+	return TableRef{}, false
 }
 
 func findInsertColumnsRange(lexemes []lexeme) *Range {
@@ -322,24 +327,6 @@ func findInsertColumnsRange(lexemes []lexeme) *Range {
 	return nil
 }
 
-func isTableStartKeyword(keyword string) bool {
-	switch keyword {
-	case "FROM", "JOIN", "UPDATE", "INTO", "DELETE":
-		return true
-	default:
-		return false
-	}
-}
-
-func isTableStopKeyword(keyword string) bool {
-	switch keyword {
-	case "WHERE", "GROUP", "ORDER", "HAVING", "JOIN", "SET", "VALUES", "LIMIT", "OFFSET":
-		return true
-	default:
-		return false
-	}
-}
-
 func normalizeIdentifier(value string) string {
 	return strings.Trim(value, "`\"")
 }
@@ -0,0 +1,193 @@
+package autocomplete
+
+import (
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/sqlparse"
+)
+
+// DerivedTable is a CTE or a subquery in FROM, with the columns its select
+// list outputs. Sources are the database tables it passes through whole with
+// * or t.*, directly or through nested derived tables; their columns are
+// looked up when completing.
+type DerivedTable struct {
+	Name    string
+	Columns []Column
+	Sources []string
+	CTE     bool
+}
+
+// visibleAt returns the tables, aliases and derived tables visible at pos:
+// those of the innermost query containing it and of the queries enclosing
+// it. The scopes come from sqlparse, as the editor diagnostics' do. Inner
+// queries come first and win on name clashes.
+func visibleAt(sql, dialect string, pos Position) statement {
+	stmt := statement{Aliases: map[string]string{}, Derived: map[string]DerivedTable{}}
+	offset := len(textBeforeCursor(sql, pos))
+	lexemes := sqlparse.StatementAt(sqlparse.Lex(sql, dialect), offset)
+	r := derivedResolver{visiting: map[*sqlparse.Body]bool{}}
+
+	for s := sqlparse.ScopeAt(sqlparse.Parse(lexemes), offset); s != nil; s = s.Parent {
+		for key, cte := range s.CTEs {
+			if _, ok := stmt.Derived[key]; !ok {
+				stmt.Derived[key] = r.cte(cte)
+			}
+		}
+		for _, t := range s.Tables {
+			if t.Function || t.Body != nil && t.Alias == "" {
+				continue
+			}
+			if t.Body != nil {
+				stmt.Tables = append(stmt.Tables, TableRef{Name: t.Alias})
+				if _, ok := stmt.Derived[strings.ToUpper(t.Alias)]; !ok {
+					stmt.Derived[strings.ToUpper(t.Alias)] = r.subquery(t)
+				}
+				continue
+			}
+			ref := tableRef(t)
+			stmt.Tables = append(stmt.Tables, ref)
+			if _, ok := stmt.Aliases[strings.ToUpper(ref.Alias)]; ref.Alias != "" && !ok {
+				stmt.Aliases[strings.ToUpper(ref.Alias)] = ref.QualifiedName()
+			}
+		}
+	}
+	stmt.TableLookup = tableLookupFromRefs(stmt.Tables)
+	return stmt
+}
+
+// tableRef converts a reference to a database table or CTE.
+func tableRef(t *sqlparse.Table) TableRef {
+	ref := TableRef{Name: t.Parts[len(t.Parts)-1], Alias: t.Alias}
+	if len(t.Parts) > 1 {
+		ref.Schema = t.Parts[len(t.Parts)-2]
+	}
+	return ref
+}
+
+// derivedResolver builds the derived tables of CTEs and FROM subqueries.
+// visiting guards against a recursive CTE selecting * from itself.
+type derivedResolver struct {
+	visiting map[*sqlparse.Body]bool
+}
+
+func (r derivedResolver) cte(cte *sqlparse.CTE) DerivedTable {
+	derived := r.outputs(cte.Body, cte.Columns)
+	derived.Name = cte.Name
+	derived.CTE = true
+	return derived
+}
+
+func (r derivedResolver) subquery(t *sqlparse.Table) DerivedTable {
+	derived := r.outputs(t.Body, t.Columns)
+	derived.Name = t.Alias
+	return derived
+}
+
+// outputs names the columns of body, or of the column list when one was
+// written. Unnamed expressions are skipped. A * over a derived table is
+// expanded now, because that table is out of scope where the completion
+// happens; a database table is kept as a source to look up then.
+func (r derivedResolver) outputs(body *sqlparse.Body, columns []string) DerivedTable {
+	var derived DerivedTable
+	if len(columns) > 0 {
+		for _, name := range columns {
+			derived.Columns = append(derived.Columns, Column{Name: name})
+		}
+		return derived
+	}
+	if r.visiting[body] {
+		return derived
+	}
+	r.visiting[body] = true
+	defer delete(r.visiting, body)
+
+	outputs, _ := body.Outputs()
+	for _, out := range outputs {
+		var inner DerivedTable
+		switch t := out.Star; {
+		case t == nil:
+			derived.Columns = append(derived.Columns, Column{Name: out.Name})
+			continue
+		case t.Function:
+			continue
+		case t.Body != nil:
+			inner = r.outputs(t.Body, t.Columns)
+		case t.CTE() != nil:
+			columns := t.Columns
+			if columns == nil {
+				columns = t.CTE().Columns
+			}
+			inner = r.outputs(t.CTE().Body, columns)
+		default:
+			derived.Sources = append(derived.Sources, tableRef(t).QualifiedName())
+			continue
+		}
+		derived.Columns = append(derived.Columns, inner.Columns...)
+		derived.Sources = append(derived.Sources, inner.Sources...)
+	}
+	return derived
+}
+
+// derivedTable returns the CTE or subquery called name, if any.
+func (s statement) derivedTable(name string) (DerivedTable, bool) {
+	derived, ok := s.Derived[strings.ToUpper(name)]
+	return derived, ok
+}
+
+// baseTables replaces derived tables in names with the database tables they
+// pass columns through from.
+func (s statement) baseTables(names []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	var walk func(names []string)
+	walk = func(names []string) {
+		for _, name := range names {
+			key := strings.ToUpper(name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if derived, ok := s.derivedTable(name); ok {
+				walk(derived.Sources)
+				continue
+			}
+			result = append(result, name)
+		}
+	}
+	walk(names)
+	return result
+}
+
+// columnsOf returns the columns of ref, whether it is a database table or a
+// derived one.
+func (s statement) columnsOf(ref TableRef, ctx Context) []Column {
+	if derived, ok := s.derivedTable(ref.Name); ok && ref.Schema == "" {
+		return s.derivedColumns(derived, ctx)
+	}
+	return ctx.columnsFor(ref.QualifiedName())
+}
+
+// derivedColumns lists the output columns of a derived table, expanding its
+// * sources from the database context or other derived tables.
+func (s statement) derivedColumns(derived DerivedTable, ctx Context) []Column {
+	columns := append([]Column{}, derived.Columns...)
+	seen := map[string]bool{strings.ToUpper(derived.Name): true}
+	var expand func(sources []string)
+	expand = func(sources []string) {
+		for _, source := range sources {
+			key := strings.ToUpper(source)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if inner, ok := s.derivedTable(source); ok {
+				columns = append(columns, inner.Columns...)
+				expand(inner.Sources)
+				continue
+			}
+			columns = append(columns, ctx.columnsFor(source)...)
+		}
+	}
+	expand(derived.Sources)
+	return columns
+}
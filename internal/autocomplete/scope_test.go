@@ -0,0 +1,244 @@
+package autocomplete
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// completeAt completes at the "|" marker in sql.
+func completeAt(t *testing.T, sql string, ctx Context) (Analysis, Result) {
+	t.Helper()
+	offset := strings.Index(sql, "|")
+	if offset < 0 {
+		t.Fatalf("missing cursor marker in %q", sql)
+	}
+	sql = sql[:offset] + sql[offset+1:]
+
+	req := Request{
+		SQL:      sql,
+		Position: Position{Line: 0, Column: offset},
+		Dialect:  "postgresql",
+		Context:  ctx,
+	}
+	analysis, err := Analyze(req)
+	if err != nil {
+		t.Fatalf("analyze failed: %v", err)
+	}
+	return analysis, CompleteWithAnalysis(analysis, ctx, req.Dialect)
+}
+
+func columnLabels(items []Item) []string {
+	var labels []string
+	for _, item := range items {
+		if item.Kind == KindColumn {
+			labels = append(labels, item.Label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+func tableNames(tables []TableRef) []string {
+	names := make([]string, 0, len(tables))
+	for _, table := range tables {
+		names = append(names, table.Name)
+	}
+	return names
+}
+
+var scopeContext = Context{
+	Tables: []Table{
+		{Name: "users", Columns: []Column{{Name: "id"}, {Name: "name"}}},
+		{Name: "orders", Columns: []Column{{Name: "id"}, {Name: "user_id"}, {Name: "total"}}},
+	},
+}
+
+func TestCompleteCTEColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "projection names",
+			sql:  "WITH recent AS (SELECT o.id, total AS amount, count(*) FROM orders o) SELECT r.| FROM recent r",
+			want: []string{"amount", "count", "id"},
+		},
+		{
+			name: "column list",
+			sql:  "WITH t(a, b) AS (SELECT 1, 2) SELECT t.| FROM t",
+			want: []string{"a", "b"},
+		},
+		{
+			name: "star",
+			sql:  "WITH u AS (SELECT * FROM users) SELECT u.| FROM u",
+			want: []string{"id", "name"},
+		},
+		{
+			name: "chained",
+			sql:  "WITH a AS (SELECT id, name FROM users), b AS (SELECT a.*, 1 AS extra FROM a) SELECT b.| FROM b",
+			want: []string{"extra", "id", "name"},
+		},
+		{
+			name: "recursive",
+			sql:  "WITH RECURSIVE tree(id, parent_id) AS (SELECT id, NULL FROM users UNION ALL SELECT t.| FROM tree t) SELECT * FROM tree",
+			want: []string{"id", "parent_id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, result := completeAt(t, tt.sql, scopeContext)
+			if got := columnLabels(result.Items); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("columns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteDerivedTableColumns(t *testing.T) {
+	sql := "SELECT s.| FROM (SELECT user_id, sum(total) AS spent FROM orders GROUP BY user_id) AS s JOIN users u ON u.id = s.user_id"
+	analysis, result := completeAt(t, sql, scopeContext)
+
+	if got := columnLabels(result.Items); !reflect.DeepEqual(got, []string{"spent", "user_id"}) {
+		t.Fatalf("columns = %v", got)
+	}
+	if got := tableNames(analysis.Tables); !reflect.DeepEqual(got, []string{"s", "users"}) {
+		t.Fatalf("tables = %v", got)
+	}
+}
+
+func TestTargetTablesExpandDerivedSources(t *testing.T) {
+	analysis, result := completeAt(t, "SELECT x.| FROM (SELECT * FROM users) x", scopeContext)
+
+	if got := analysis.TargetTables(); !reflect.DeepEqual(got, []string{"users"}) {
+		t.Fatalf("target tables = %v", got)
+	}
+	if got := columnLabels(result.Items); !reflect.DeepEqual(got, []string{"id", "name"}) {
+		t.Fatalf("columns = %v", got)
+	}
+}
+
+func TestCompleteNestedStarDerivedTables(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "star over a derived table",
+			sql:  "SELECT d.| FROM (SELECT * FROM (SELECT id, name FROM users) x) d",
+			want: []string{"id", "name"},
+		},
+		{
+			name: "qualified star over a derived table",
+			sql:  "SELECT d.| FROM (SELECT x.*, 1 AS extra FROM (SELECT * FROM (SELECT id, total FROM orders) y) x) d",
+			want: []string{"extra", "id", "total"},
+		},
+		{
+			name: "star down to a database table",
+			sql:  "SELECT d.| FROM (SELECT * FROM (SELECT * FROM users) x) d",
+			want: []string{"id", "name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, result := completeAt(t, tt.sql, scopeContext)
+			if got := columnLabels(result.Items); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("columns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeNestedScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		sql    string
+		tables []string
+	}{
+		{
+			name:   "correlated subquery sees outer tables after its own",
+			sql:    "SELECT * FROM users u WHERE u.id IN (SELECT | FROM orders o)",
+			tables: []string{"orders", "users"},
+		},
+		{
+			name:   "sibling subqueries are isolated",
+			sql:    "SELECT (SELECT id FROM users), (SELECT | FROM orders)",
+			tables: []string{"orders"},
+		},
+		{
+			name:   "outer query does not see subquery tables",
+			sql:    "SELECT | FROM users WHERE id IN (SELECT user_id FROM orders)",
+			tables: []string{"users"},
+		},
+		{
+			name:   "join condition is not a table",
+			sql:    "SELECT | FROM users u JOIN orders o ON o.user_id = u.id",
+			tables: []string{"users", "orders"},
+		},
+		{
+			name:   "statements are separate",
+			sql:    "SELECT * FROM users; SELECT | FROM orders",
+			tables: []string{"orders"},
+		},
+		{
+			name:   "union parts are separate",
+			sql:    "SELECT id FROM users UNION SELECT | FROM orders",
+			tables: []string{"orders"},
+		},
+		{
+			name:   "insert source does not see the target",
+			sql:    "INSERT INTO users (id) SELECT | FROM orders",
+			tables: []string{"orders"},
+		},
+		{
+			name:   "update target before SET",
+			sql:    "UPDATE users u |",
+			tables: []string{"users"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis, _ := completeAt(t, tt.sql, scopeContext)
+			if got := tableNames(analysis.Tables); !reflect.DeepEqual(got, tt.tables) {
+				t.Fatalf("tables = %v, want %v", got, tt.tables)
+			}
+		})
+	}
+}
+
+func TestAnalyzeKindsInsideSubquery(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want ItemKind
+	}{
+		{sql: "SELECT * FROM (SELECT | FROM orders) s", want: KindColumn},
+		{sql: "WITH c AS (SELECT id FROM |", want: KindTable},
+		{sql: "WITH c AS (SELECT id FROM orders) SELECT | FROM c", want: KindColumn},
+		{sql: "SELECT * FROM users u JOIN orders o ON |", want: KindColumn},
+		{sql: "SELECT count(|) FROM users", want: KindColumn},
+	}
+
+	for _, tt := range tests {
+		analysis, _ := completeAt(t, tt.sql, scopeContext)
+		if !analysis.HasKind(tt.want) {
+			t.Errorf("%q: kinds %v do not include %v", tt.sql, analysis.Kinds, tt.want)
+		}
+	}
+}
+
+func TestCompleteCTENamesAsTables(t *testing.T) {
+	_, result := completeAt(t, "WITH recent AS (SELECT id FROM orders) SELECT * FROM re|", scopeContext)
+
+	for _, item := range result.Items {
+		if item.Label == "recent" && item.Kind == KindTable && item.Detail == "cte" {
+			return
+		}
+	}
+	t.Fatalf("expected recent CTE as a table, got %#v", result.Items)
+}
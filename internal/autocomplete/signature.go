@@ -0,0 +1,139 @@
+package autocomplete
+
+import "strings"
+
+// SignatureHelp describes the function call around the cursor.
+type SignatureHelp struct {
+	Function  Function
+	Overloads []Function
+	// Argument is the zero-based argument the cursor is in. ActiveParam is
+	// the parameter of Function it maps to, or -1 past the last parameter.
+	Argument    int
+	ActiveParam int
+}
+
+type openCall struct {
+	name     int
+	argument int
+}
+
+// SignatureAt finds the innermost known function call whose parentheses
+// enclose the cursor. ok is false outside any call.
+func SignatureAt(req Request) (help SignatureHelp, ok bool, err error) {
+	tokens, err := Tokenize(req.SQL, req.Dialect)
+	if err != nil {
+		return SignatureHelp{}, false, err
+	}
+
+	lexemes := buildLexemes(tokens)
+	calls := openCallsBefore(lexemes, req.Position)
+	for i := len(calls) - 1; i >= 0; i-- {
+		call := calls[i]
+		if call.name < 0 {
+			continue
+		}
+		name := lexemes[call.name].Value
+		schema := ""
+		if call.name >= 2 && lexemes[call.name-1].Value == "." && lexemes[call.name-2].Kind == lexemeIdentifier {
+			schema = lexemes[call.name-2].Value
+		}
+
+		overloads := lookupFunctions(req.Context, req.Dialect, schema, name)
+		if len(overloads) == 0 {
+			continue
+		}
+		f := pickOverload(overloads, call.argument)
+		return SignatureHelp{
+			Function:    f,
+			Overloads:   overloads,
+			Argument:    call.argument,
+			ActiveParam: activeParam(f, call.argument),
+		}, true, nil
+	}
+	return SignatureHelp{}, false, nil
+}
+
+// openCallsBefore returns the parentheses still open at pos, outermost first,
+// with the lexeme naming each call (-1 for a bare parenthesis) and how many
+// top-level commas precede the cursor inside it.
+func openCallsBefore(lexemes []lexeme, pos Position) []openCall {
+	var calls []openCall
+	for i, lex := range lexemes {
+		if !posAfterStart(pos, lex.End) {
+			break
+		}
+		if lex.Kind != lexemePunctuation {
+			continue
+		}
+		for _, r := range lex.Value {
+			switch r {
+			case '(':
+				name := -1
+				if i > 0 && lexemes[i-1].Kind != lexemePunctuation {
+					name = i - 1
+				}
+				calls = append(calls, openCall{name: name})
+			case ')':
+				if len(calls) > 0 {
+					calls = calls[:len(calls)-1]
+				}
+			case ',':
+				if len(calls) > 0 {
+					calls[len(calls)-1].argument++
+				}
+			case ';':
+				calls = nil
+			}
+		}
+	}
+	return calls
+}
+
+// pickOverload prefers the first overload that accepts argument.
+func pickOverload(overloads []Function, argument int) Function {
+	for _, f := range overloads {
+		if f.Variadic || argument < len(f.Params) {
+			return f
+		}
+	}
+	return overloads[0]
+}
+
+func activeParam(f Function, argument int) int {
+	switch {
+	case argument < len(f.Params):
+		return argument
+	case f.Variadic:
+		return len(f.Params) - 1
+	default:
+		return -1
+	}
+}
+
+func functionCandidates(ctx Context, dialect, qualifier string) []Item {
+	seen := map[string]bool{}
+	var items []Item
+	for _, f := range functionsFor(ctx, dialect) {
+		if qualifier != "" && !strings.EqualFold(f.Schema, qualifier) {
+			continue
+		}
+		key := strings.ToUpper(f.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		f := f
+		detail := f.ParamList()
+		if f.Returns != "" {
+			detail += " → " + f.Returns
+		}
+		items = append(items, Item{
+			Label:    f.Name,
+			Kind:     KindFunction,
+			Detail:   detail,
+			Function: &f,
+		})
+	}
+	return items
+}
@@ -0,0 +1,225 @@
+package autocomplete
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Snippet is a completion template. Body marks tab stops as $1, $2, ... or
+// ${1:placeholder}, visited in order; $0 is where the cursor ends up. \$ is
+// a literal dollar sign. Dialects limits the snippet to those dialects; an
+// empty list means every dialect.
+type Snippet struct {
+	Name        string
+	Description string
+	Body        string
+	Dialects    []string
+}
+
+// TabStop is a span of expanded snippet text the cursor visits. Start and
+// End are byte offsets; they are equal for a stop without a placeholder.
+type TabStop struct {
+	Index int
+	Start int
+	End   int
+}
+
+var builtinSnippets = []Snippet{
+	{Name: "sel", Description: "SELECT ... WHERE ... LIMIT", Body: "SELECT ${1:*} FROM ${2:table} WHERE ${3:condition} LIMIT ${4:100}$0"},
+	{Name: "selc", Description: "count rows", Body: "SELECT count(*) FROM ${1:table} WHERE ${2:condition}$0"},
+	{Name: "selg", Description: "count by group", Body: "SELECT ${1:column}, count(*)\nFROM ${2:table}\nGROUP BY 1\nORDER BY 2 DESC$0"},
+	{Name: "ins", Description: "INSERT ... VALUES", Body: "INSERT INTO ${1:table} (${2:columns}) VALUES (${3:values})$0"},
+	{Name: "upd", Description: "UPDATE ... WHERE", Body: "UPDATE ${1:table} SET ${2:column} = ${3:value} WHERE ${4:condition}$0"},
+	{Name: "del", Description: "DELETE ... WHERE", Body: "DELETE FROM ${1:table} WHERE ${2:condition}$0"},
+	{
+		Name:        "upsert",
+		Description: "INSERT ... ON CONFLICT DO UPDATE",
+		Body:        "INSERT INTO ${1:table} (${2:columns}) VALUES (${3:values})\nON CONFLICT (${4:key}) DO UPDATE SET ${5:column} = excluded.${6:column}$0",
+		Dialects:    []string{"postgres", "sqlite"},
+	},
+	{
+		Name:        "upsert",
+		Description: "INSERT ... ON DUPLICATE KEY UPDATE",
+		Body:        "INSERT INTO ${1:table} (${2:columns}) VALUES (${3:values})\nON DUPLICATE KEY UPDATE ${4:column} = VALUES(${5:column})$0",
+		Dialects:    []string{"mysql"},
+	},
+	{Name: "cte", Description: "WITH ... AS (...)", Body: "WITH ${1:name} AS (\n    ${2:SELECT 1}\n)\nSELECT ${3:*} FROM ${4:name}$0"},
+	{Name: "win", Description: "window function", Body: "${1:row_number}() OVER (PARTITION BY ${2:column} ORDER BY ${3:column})$0"},
+	{Name: "join", Description: "JOIN ... ON", Body: "JOIN ${1:table} ON ${2:condition}$0"},
+	{Name: "explain", Description: "EXPLAIN (ANALYZE, BUFFERS)", Body: "EXPLAIN (ANALYZE, BUFFERS) ${0}", Dialects: []string{"postgres"}},
+	{Name: "explain", Description: "EXPLAIN ANALYZE", Body: "EXPLAIN ANALYZE ${0}", Dialects: []string{"mysql"}},
+	{Name: "explain", Description: "EXPLAIN QUERY PLAN", Body: "EXPLAIN QUERY PLAN ${0}", Dialects: []string{"sqlite"}},
+}
+
+// BuiltinSnippets returns the built-in snippets for dialect.
+func BuiltinSnippets(dialect string) []Snippet {
+	var snippets []Snippet
+	for _, s := range builtinSnippets {
+		if s.AppliesTo(dialect) {
+			snippets = append(snippets, s)
+		}
+	}
+	return snippets
+}
+
+// AppliesTo reports whether the snippet is offered for dialect.
+func (s Snippet) AppliesTo(dialect string) bool {
+	if len(s.Dialects) == 0 {
+		return true
+	}
+	for _, d := range s.Dialects {
+		if dialectFamily(d) == dialectFamily(dialect) {
+			return true
+		}
+	}
+	return false
+}
+
+// snippetsFor returns the user snippets for dialect followed by the
+// built-ins they do not override by name. Of user snippets sharing a name
+// the first wins.
+func snippetsFor(ctx Context, dialect string) []Snippet {
+	var snippets []Snippet
+	defined := map[string]bool{}
+	for _, s := range ctx.Snippets {
+		if s.Name != "" && !defined[strings.ToUpper(s.Name)] && s.AppliesTo(dialect) {
+			snippets = append(snippets, s)
+			defined[strings.ToUpper(s.Name)] = true
+		}
+	}
+	for _, s := range BuiltinSnippets(dialect) {
+		if !defined[strings.ToUpper(s.Name)] {
+			snippets = append(snippets, s)
+		}
+	}
+	return snippets
+}
+
+func snippetCandidates(ctx Context, dialect string) []Item {
+	snippets := snippetsFor(ctx, dialect)
+	items := make([]Item, 0, len(snippets))
+	for i := range snippets {
+		detail := snippets[i].Description
+		if detail == "" {
+			detail = "snippet"
+		}
+		items = append(items, Item{
+			Label:   snippets[i].Name,
+			Kind:    KindSnippet,
+			Detail:  detail,
+			Snippet: &snippets[i],
+		})
+	}
+	return items
+}
+
+// ExpandSnippet turns a snippet body into the text to insert and its tab
+// stops, ordered by index with $0 last. Lines after the first are prefixed
+// with indent so the snippet lines up with the line it is inserted into.
+// A repeated index is a stop only at its first occurrence. When the body
+// has no $0, the final stop is the end of the text.
+func ExpandSnippet(body, indent string) (string, []TabStop) {
+	var b strings.Builder
+	var stops []TabStop
+	seen := map[int]bool{}
+	addStop := func(index, start int) {
+		if !seen[index] {
+			seen[index] = true
+			stops = append(stops, TabStop{Index: index, Start: start, End: b.Len()})
+		}
+	}
+
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\\' && i+1 < len(body) && (body[i+1] == '$' || body[i+1] == '\\'):
+			b.WriteByte(body[i+1])
+			i++
+		case c == '\n':
+			b.WriteByte('\n')
+			b.WriteString(indent)
+		case c == '$' && i+1 < len(body) && isDigit(body[i+1]):
+			j := i + 1
+			for j < len(body) && isDigit(body[j]) {
+				j++
+			}
+			index, _ := strconv.Atoi(body[i+1 : j])
+			addStop(index, b.Len())
+			i = j - 1
+		case c == '$' && strings.HasPrefix(body[i+1:], "{"):
+			index, placeholder, n, ok := parsePlaceholder(body[i:])
+			if !ok {
+				b.WriteByte(c)
+				continue
+			}
+			start := b.Len()
+			b.WriteString(strings.ReplaceAll(placeholder, "\n", "\n"+indent))
+			addStop(index, start)
+			i += n - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	if !seen[0] {
+		addStop(0, b.Len())
+	}
+	sort.SliceStable(stops, func(i, j int) bool {
+		if (stops[i].Index == 0) != (stops[j].Index == 0) {
+			return stops[j].Index == 0
+		}
+		return stops[i].Index < stops[j].Index
+	})
+	return b.String(), stops
+}
+
+// parsePlaceholder parses ${N} or ${N:text} at the start of s and returns
+// the index, the placeholder text and the length consumed.
+func parsePlaceholder(s string) (int, string, int, bool) {
+	j := 2
+	for j < len(s) && isDigit(s[j]) {
+		j++
+	}
+	if j == 2 || j >= len(s) {
+		return 0, "", 0, false
+	}
+	index, _ := strconv.Atoi(s[2:j])
+	if s[j] == '}' {
+		return index, "", j + 1, true
+	}
+	if s[j] != ':' {
+		return 0, "", 0, false
+	}
+
+	var text strings.Builder
+	for k := j + 1; k < len(s); k++ {
+		switch {
+		case s[k] == '\\' && k+1 < len(s) && (s[k+1] == '}' || s[k+1] == '$' || s[k+1] == '\\'):
+			text.WriteByte(s[k+1])
+			k++
+		case s[k] == '}':
+			return index, text.String(), k + 1, true
+		default:
+			text.WriteByte(s[k])
+		}
+	}
+	return 0, "", 0, false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func dialectFamily(dialect string) string {
+	switch d := strings.ToLower(dialect); {
+	case isPostgresDialect(d):
+		return "postgres"
+	case isMySQLDialect(d):
+		return "mysql"
+	case d == "sqlite3":
+		return "sqlite"
+	default:
+		return d
+	}
+}
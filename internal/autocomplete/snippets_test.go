@@ -0,0 +1,133 @@
+package autocomplete
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandSnippet(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		indent string
+		want   string
+		stops  []TabStop
+	}{
+		{
+			name:  "placeholders",
+			body:  "SELECT ${1:*} FROM ${2:table}$0",
+			want:  "SELECT * FROM table",
+			stops: []TabStop{{1, 7, 8}, {2, 14, 19}, {0, 19, 19}},
+		},
+		{
+			name:  "bare stops and implicit end",
+			body:  "DELETE FROM $1 WHERE $2",
+			want:  "DELETE FROM  WHERE ",
+			stops: []TabStop{{1, 12, 12}, {2, 19, 19}, {0, 19, 19}},
+		},
+		{
+			name:  "final stop first in body",
+			body:  "$0 -- ${1:note}",
+			want:  " -- note",
+			stops: []TabStop{{1, 4, 8}, {0, 0, 0}},
+		},
+		{
+			name:  "repeated index",
+			body:  "${1:a} ${1:b}",
+			want:  "a b",
+			stops: []TabStop{{1, 0, 1}, {0, 3, 3}},
+		},
+		{
+			name:  "escapes",
+			body:  `\$1 ${1:x\}y} \\`,
+			want:  `$1 x}y \`,
+			stops: []TabStop{{1, 3, 6}, {0, 8, 8}},
+		},
+		{
+			name:   "indent",
+			body:   "WITH x AS (\n    $1\n)",
+			indent: "  ",
+			want:   "WITH x AS (\n      \n  )",
+			stops:  []TabStop{{1, 18, 18}, {0, 22, 22}},
+		},
+		{
+			name: "not a placeholder",
+			body: "SELECT '${x}', $",
+			want: "SELECT '${x}', $",
+		},
+	}
+
+	for _, tt := range tests {
+		text, stops := ExpandSnippet(tt.body, tt.indent)
+		if text != tt.want {
+			t.Errorf("%s: text = %q, want %q", tt.name, text, tt.want)
+		}
+		if tt.stops == nil {
+			tt.stops = []TabStop{{0, len(tt.want), len(tt.want)}}
+		}
+		if !reflect.DeepEqual(stops, tt.stops) {
+			t.Errorf("%s: stops = %v, want %v", tt.name, stops, tt.stops)
+		}
+	}
+}
+
+func TestBuiltinSnippetsPerDialect(t *testing.T) {
+	bodies := func(dialect string) map[string]string {
+		snippets := map[string]string{}
+		for _, s := range BuiltinSnippets(dialect) {
+			if _, ok := snippets[s.Name]; ok {
+				t.Fatalf("%s: %q offered twice", dialect, s.Name)
+			}
+			snippets[s.Name] = s.Body
+		}
+		return snippets
+	}
+
+	postgres, mysql, sqlite := bodies("postgresql"), bodies("mysql"), bodies("sqlite3")
+	if postgres["sel"] == "" || mysql["sel"] == "" || sqlite["sel"] == "" {
+		t.Fatal("sel should be offered for every dialect")
+	}
+	if postgres["upsert"] == mysql["upsert"] || postgres["upsert"] != sqlite["upsert"] {
+		t.Fatal("upsert should differ between postgres and mysql")
+	}
+	if postgres["explain"] == sqlite["explain"] {
+		t.Fatal("explain should differ between postgres and sqlite")
+	}
+}
+
+func TestCompleteSnippets(t *testing.T) {
+	ctx := Context{
+		Snippets: []Snippet{
+			{Name: "sel", Body: "SELECT 1"},
+			{Name: "sel", Body: "SELECT 2"},
+			{Name: "mine", Body: "SELECT $1", Dialects: []string{"postgres"}},
+			{Name: "theirs", Body: "SELECT $1", Dialects: []string{"mysql"}},
+		},
+	}
+
+	_, result := completeAt(t, "|", ctx)
+	labels := labelsOfKind(result.Items, KindSnippet)
+	for _, want := range []string{"mine", "sel", "upsert"} {
+		if !hasItem(result.Items, want, KindSnippet) {
+			t.Errorf("missing snippet %q in %v", want, labels)
+		}
+	}
+	if hasItem(result.Items, "theirs", KindSnippet) {
+		t.Error("mysql snippet offered for postgres")
+	}
+
+	var sel []string
+	for _, item := range result.Items {
+		if item.Kind == KindSnippet && item.Label == "sel" {
+			sel = append(sel, item.Snippet.Body)
+		}
+	}
+	if !reflect.DeepEqual(sel, []string{"SELECT 1"}) {
+		t.Fatalf("sel bodies = %v, want the first user snippet only", sel)
+	}
+
+	_, result = completeAt(t, "SELECT u.| FROM users u", ctx)
+	if got := labelsOfKind(result.Items, KindSnippet); len(got) != 0 {
+		t.Fatalf("snippets offered after a qualifier: %v", got)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/android-lewis/dbsmith/internal/constants"
+	"github.com/android-lewis/dbsmith/internal/fileutil"
+	"github.com/android-lewis/dbsmith/internal/models"
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,6 +17,19 @@ type Config struct {
 	Logging    LoggingConfig    `yaml:"logging"`
 	Editor     EditorConfig     `yaml:"editor"`
 	UI         UIConfig         `yaml:"ui"`
+	Secrets    SecretsConfig    `yaml:"secrets"`
+
+	Environments map[string]EnvironmentPolicy `yaml:"environments"`
+
+	// loaded records the file this config was read from so SaveToPath can
+	// merge edits another process made in the meantime.
+	loaded *loadedFile
+}
+
+type loadedFile struct {
+	path     string
+	checksum string
+	base     map[string]interface{}
 }
 
 type ConnectionConfig struct {
@@ -23,6 +38,9 @@ type ConnectionConfig struct {
 	MaxIdleConns    int    `yaml:"max_idle_conns"`
 	ConnMaxLifetime string `yaml:"conn_max_lifetime"`
 	ConnMaxIdleTime string `yaml:"conn_max_idle_time"`
+	// HealthCheckInterval is how often an open connection is pinged; 0
+	// turns the checks and automatic reconnects off.
+	HealthCheckInterval string `yaml:"health_check_interval"`
 }
 
 type LoggingConfig struct {
@@ -32,10 +50,35 @@ type LoggingConfig struct {
 	MaxAgeDays int    `yaml:"max_age_days"`
 }
 
+// EditorConfig holds editor settings. Snippets apply to every workspace;
+// workspace snippets of the same name take precedence. Keymap is default,
+// vim or emacs.
 type EditorConfig struct {
-	DefaultLimit       int  `yaml:"default_limit"`
-	ConfirmDestructive bool `yaml:"confirm_destructive"`
-	TabSize            int  `yaml:"tab_size"`
+	DefaultLimit       int              `yaml:"default_limit"`
+	ConfirmDestructive bool             `yaml:"confirm_destructive"`
+	TabSize            int              `yaml:"tab_size"`
+	Keymap             string           `yaml:"keymap"`
+	Format             FormatConfig     `yaml:"format"`
+	Snippets           []models.Snippet `yaml:"snippets,omitempty"`
+}
+
+// FormatConfig controls the SQL formatter. KeywordCase is upper, lower or
+// preserve; CommaStyle is trailing or leading.
+type FormatConfig struct {
+	KeywordCase string `yaml:"keyword_case"`
+	Indent      int    `yaml:"indent"`
+	CommaStyle  string `yaml:"comma_style"`
+	LineWidth   int    `yaml:"line_width"`
+}
+
+// EnvironmentPolicy holds the guardrails applied to connections tagged with an
+// environment. Color is a tcell color name or #rrggbb value.
+type EnvironmentPolicy struct {
+	ReadOnly        bool   `yaml:"read_only"`
+	ConfirmWrites   bool   `yaml:"confirm_writes"`
+	TypedConfirmDDL bool   `yaml:"typed_confirm_ddl"`
+	MaxRowsAffected int64  `yaml:"max_rows_affected"`
+	Color           string `yaml:"color"`
 }
 
 type UIConfig struct {
@@ -45,14 +88,24 @@ type UIConfig struct {
 	MaxPreviewCellWidth int  `yaml:"max_preview_cell_width"`
 }
 
+// SecretsConfig applies to stored secrets. IdleLock is how long a
+// master-password key stays unlocked without use; "0" never locks.
+// CommandTimeout and CommandCacheTTL apply to cmd: and pass: providers.
+type SecretsConfig struct {
+	IdleLock        string `yaml:"idle_lock"`
+	CommandTimeout  string `yaml:"command_timeout"`
+	CommandCacheTTL string `yaml:"command_cache_ttl"`
+}
+
 func DefaultConfig() *Config {
 	return &Config{
 		Connection: ConnectionConfig{
-			Timeout:         "30s",
-			MaxOpenConns:    25,
-			MaxIdleConns:    5,
-			ConnMaxLifetime: "5m",
-			ConnMaxIdleTime: "5m",
+			Timeout:             "30s",
+			MaxOpenConns:        25,
+			MaxIdleConns:        5,
+			ConnMaxLifetime:     "5m",
+			ConnMaxIdleTime:     "5m",
+			HealthCheckInterval: "15s",
 		},
 		Logging: LoggingConfig{
 			Level:      "info",
@@ -64,6 +117,13 @@ func DefaultConfig() *Config {
 			DefaultLimit:       10000,
 			ConfirmDestructive: true,
 			TabSize:            4,
+			Keymap:             "default",
+			Format: FormatConfig{
+				KeywordCase: "upper",
+				Indent:      4,
+				CommaStyle:  "trailing",
+				LineWidth:   80,
+			},
 		},
 		UI: UIConfig{
 			ShowDataPreview:     true,
@@ -71,7 +131,36 @@ func DefaultConfig() *Config {
 			ShowIndexes:         false,
 			MaxPreviewCellWidth: 50,
 		},
+		Secrets: SecretsConfig{
+			IdleLock:        "15m",
+			CommandTimeout:  "30s",
+			CommandCacheTTL: "5m",
+		},
+		Environments: map[string]EnvironmentPolicy{
+			"dev": {
+				Color: "green",
+			},
+			"staging": {
+				ConfirmWrites: true,
+				Color:         "yellow",
+			},
+			"prod": {
+				ConfirmWrites:   true,
+				TypedConfirmDDL: true,
+				MaxRowsAffected: 1000,
+				Color:           "red",
+			},
+		},
+	}
+}
+
+// EnvironmentPolicy returns the policy for env. Untagged connections and
+// environments without a configured policy get no extra guardrails.
+func (c *Config) EnvironmentPolicy(env string) EnvironmentPolicy {
+	if env == "" || c.Environments == nil {
+		return EnvironmentPolicy{}
 	}
+	return c.Environments[env]
 }
 
 func GetConfigDir() (string, error) {
@@ -114,13 +203,42 @@ func LoadFromPath(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
+	if err := parse(data, cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	cfg.remember(path, data)
 
 	return cfg, nil
 }
 
+// parse decodes data over cfg. Environment policies are merged field by
+// field, so a partial entry only overrides the fields it sets.
+func parse(data []byte, cfg *Config) error {
+	defaults := make(map[string]EnvironmentPolicy, len(cfg.Environments))
+	for name, policy := range cfg.Environments {
+		defaults[name] = policy
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return err
+	}
+
+	var raw struct {
+		Environments map[string]yaml.Node `yaml:"environments"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for name, node := range raw.Environments {
+		policy := defaults[name]
+		if err := node.Decode(&policy); err != nil {
+			return err
+		}
+		cfg.Environments[name] = policy
+	}
+	return nil
+}
+
 func (c *Config) Save() error {
 	path, err := GetConfigPath()
 	if err != nil {
@@ -129,20 +247,34 @@ func (c *Config) Save() error {
 	return c.SaveToPath(path)
 }
 
+// SaveToPath writes the config atomically while holding the file lock. If
+// another process changed the file since it was loaded, non-conflicting
+// edits are merged in; conflicting ones return a *ConflictError.
 func (c *Config) SaveToPath(path string) error {
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	lock, err := fileutil.Lock(path, fileutil.DefaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	if err := c.mergeFromDisk(path); err != nil {
+		return err
+	}
+
 	data, err := yaml.Marshal(c)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0600); err != nil {
+	if err := fileutil.WriteFileAtomic(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
+	c.remember(path, data)
 
 	return nil
 }
@@ -170,3 +302,35 @@ func (c *Config) GetConnMaxIdleTime() time.Duration {
 	}
 	return d
 }
+
+func (c *Config) GetHealthCheckInterval() time.Duration {
+	d, err := time.ParseDuration(c.Connection.HealthCheckInterval)
+	if err != nil {
+		return 15 * time.Second
+	}
+	return d
+}
+
+func (c *Config) GetSecretsIdleLock() time.Duration {
+	d, err := time.ParseDuration(c.Secrets.IdleLock)
+	if err != nil {
+		return 15 * time.Minute
+	}
+	return d
+}
+
+func (c *Config) GetSecretsCommandTimeout() time.Duration {
+	d, err := time.ParseDuration(c.Secrets.CommandTimeout)
+	if err != nil {
+		return 30 * time.Second
+	}
+	return d
+}
+
+func (c *Config) GetSecretsCommandCacheTTL() time.Duration {
+	d, err := time.ParseDuration(c.Secrets.CommandCacheTTL)
+	if err != nil {
+		return 5 * time.Minute
+	}
+	return d
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -164,3 +165,133 @@ func TestConfig_PartialOverride(t *testing.T) {
 		t.Errorf("Editor.DefaultLimit = %d, want 10000 (default)", cfg.Editor.DefaultLimit)
 	}
 }
+
+func TestConfig_EnvironmentPolicy(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "envs.yaml")
+
+	content := `environments:
+  prod:
+    read_only: true
+    color: "#ff0000"
+  qa:
+    confirm_writes: true
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFromPath(path)
+	if err != nil {
+		t.Fatalf("LoadFromPath() error = %v", err)
+	}
+
+	prod := cfg.EnvironmentPolicy("prod")
+	if !prod.ReadOnly || prod.Color != "#ff0000" {
+		t.Errorf("prod policy = %+v, want read-only with custom color", prod)
+	}
+	if !prod.ConfirmWrites || !prod.TypedConfirmDDL || prod.MaxRowsAffected != 1000 {
+		t.Errorf("prod policy = %+v, want the default fields it does not set", prod)
+	}
+
+	if !cfg.EnvironmentPolicy("qa").ConfirmWrites {
+		t.Error("custom environment qa should confirm writes")
+	}
+	if !cfg.EnvironmentPolicy("staging").ConfirmWrites {
+		t.Error("default staging policy should be preserved")
+	}
+	if cfg.EnvironmentPolicy("") != (EnvironmentPolicy{}) {
+		t.Error("untagged connections should have an empty policy")
+	}
+}
+
+func TestConfig_EnvironmentPolicyPartialOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "envs.yaml")
+	content := `environments:
+  prod:
+    max_rows_affected: 50
+  staging:
+    confirm_writes: false
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFromPath(path)
+	if err != nil {
+		t.Fatalf("LoadFromPath() error = %v", err)
+	}
+
+	want := DefaultConfig().Environments["prod"]
+	want.MaxRowsAffected = 50
+	if got := cfg.EnvironmentPolicy("prod"); got != want {
+		t.Errorf("prod policy = %+v, want %+v", got, want)
+	}
+
+	staging := cfg.EnvironmentPolicy("staging")
+	if staging.ConfirmWrites || staging.Color != "yellow" {
+		t.Errorf("staging policy = %+v, want confirm_writes off and the default color", staging)
+	}
+	if DefaultConfig().Environments["prod"].MaxRowsAffected != 1000 {
+		t.Error("loading a config should not change the defaults")
+	}
+}
+
+func TestConfig_SaveMergesConcurrentChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := DefaultConfig().SaveToPath(path); err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := LoadFromPath(path)
+	b, _ := LoadFromPath(path)
+
+	a.Editor.TabSize = 2
+	if err := a.SaveToPath(path); err != nil {
+		t.Fatalf("a.SaveToPath() error = %v", err)
+	}
+
+	b.Logging.Level = "debug"
+	if err := b.SaveToPath(path); err != nil {
+		t.Fatalf("b.SaveToPath() error = %v", err)
+	}
+
+	loaded, _ := LoadFromPath(path)
+	if loaded.Editor.TabSize != 2 || loaded.Logging.Level != "debug" {
+		t.Errorf("TabSize = %d, Level = %s; want both edits kept", loaded.Editor.TabSize, loaded.Logging.Level)
+	}
+	if b.Editor.TabSize != 2 {
+		t.Error("merged change was not applied in memory")
+	}
+}
+
+func TestConfig_SaveReportsConflicts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := DefaultConfig().SaveToPath(path); err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := LoadFromPath(path)
+	b, _ := LoadFromPath(path)
+
+	a.Editor.DefaultLimit = 500
+	if err := a.SaveToPath(path); err != nil {
+		t.Fatalf("a.SaveToPath() error = %v", err)
+	}
+
+	b.Editor.DefaultLimit = 50
+	err := b.SaveToPath(path)
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConfigConflict) {
+		t.Fatalf("SaveToPath() error = %v, want ConflictError", err)
+	}
+	if len(conflict.Keys) != 1 || conflict.Keys[0] != "editor.default_limit" {
+		t.Errorf("Keys = %v, want [editor.default_limit]", conflict.Keys)
+	}
+
+	loaded, _ := LoadFromPath(path)
+	if loaded.Editor.DefaultLimit != 500 {
+		t.Errorf("conflicting save overwrote the file: %d", loaded.Editor.DefaultLimit)
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrConfigConflict is matched by *ConflictError.
+var ErrConfigConflict = errors.New("config file was changed by another process")
+
+// ConflictError lists the settings both this process and another one changed
+// since the config was loaded, as dotted YAML keys.
+type ConflictError struct {
+	Path string
+	Keys []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %v; conflicting changes to %s",
+		e.Path, ErrConfigConflict, strings.Join(e.Keys, ", "))
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConfigConflict
+}
+
+func (c *Config) remember(path string, data []byte) {
+	base, err := toMap(c)
+	if err != nil {
+		c.loaded = nil
+		return
+	}
+	c.loaded = &loadedFile{path: path, checksum: fileutil.Checksum(data), base: base}
+}
+
+// mergeFromDisk folds settings another process saved to path into c. Configs
+// that were not loaded from path simply overwrite it.
+func (c *Config) mergeFromDisk(path string) error {
+	if c.loaded == nil || c.loaded.path != path {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if fileutil.Checksum(data) == c.loaded.checksum {
+		return nil
+	}
+
+	disk := DefaultConfig()
+	if err := parse(data, disk); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	theirs, err := toMap(disk)
+	if err != nil {
+		return err
+	}
+	ours, err := toMap(c)
+	if err != nil {
+		return err
+	}
+
+	merged, conflicts := mergeMaps(c.loaded.base, ours, theirs, "")
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return &ConflictError{Path: path, Keys: conflicts}
+	}
+
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	next := &Config{}
+	if err := yaml.Unmarshal(out, next); err != nil {
+		return fmt.Errorf("failed to parse merged config: %w", err)
+	}
+	next.loaded = c.loaded
+	*c = *next
+	return nil
+}
+
+// mergeMaps three-way merges nested YAML maps key by key. A key changed on
+// only one side takes that side's value; a key changed differently on both
+// sides keeps ours and is reported.
+func mergeMaps(base, ours, theirs map[string]interface{}, prefix string) (map[string]interface{}, []string) {
+	merged := make(map[string]interface{})
+	var conflicts []string
+
+	keys := make(map[string]bool)
+	for k := range ours {
+		keys[k] = true
+	}
+	for k := range theirs {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		b, inB := base[k]
+		o, inO := ours[k]
+		t, inT := theirs[k]
+
+		bm, _ := b.(map[string]interface{})
+		om, oIsMap := o.(map[string]interface{})
+		tm, tIsMap := t.(map[string]interface{})
+		if oIsMap && tIsMap {
+			sub, subConflicts := mergeMaps(bm, om, tm, prefix+k+".")
+			merged[k] = sub
+			conflicts = append(conflicts, subConflicts...)
+			continue
+		}
+
+		sameOT := inO == inT && reflect.DeepEqual(o, t)
+		sameBT := inB == inT && reflect.DeepEqual(b, t)
+		sameBO := inB == inO && reflect.DeepEqual(b, o)
+		switch {
+		case sameOT, sameBT:
+			if inO {
+				merged[k] = o
+			}
+		case sameBO:
+			if inT {
+				merged[k] = t
+			}
+		default:
+			conflicts = append(conflicts, prefix+k)
+			if inO {
+				merged[k] = o
+			}
+		}
+	}
+	return merged, conflicts
+}
+
+func toMap(c *Config) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	m := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return m, nil
+}
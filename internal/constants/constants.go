@@ -9,12 +9,14 @@ const (
 )
 
 const (
-	DefaultLogFileName       = "dbsmith.log"
-	DefaultConfigFileName    = "config.log"
-	DefaultWorkspaceFileName = "workspace.yaml"
-	DefaultMaxSizeMB         = 10
-	DefaultMaxBackups        = 3
-	DefaultMaxAgeDays        = 28
+	DefaultLogFileName        = "dbsmith.log"
+	DefaultConfigFileName     = "config.log"
+	DefaultWorkspaceFileName  = "workspace.yaml"
+	WorkspaceRegistryFileName = "workspaces.yaml"
+	MetadataCacheDirName      = "metadata"
+	DefaultMaxSizeMB          = 10
+	DefaultMaxBackups         = 3
+	DefaultMaxAgeDays         = 28
 )
 
 const (
@@ -6,4 +6,5 @@ var (
 	ErrQueryTimeout   = errors.New("query execution timeout")
 	ErrQueryCancelled = errors.New("query cancelled by user")
 	ErrNotConnected   = errors.New("database not connected")
+	ErrSessionLost    = errors.New("database session lost")
 )
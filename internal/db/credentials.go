@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/android-lewis/dbsmith/internal/secrets"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// minConnLifetime bounds how often pooled connections are recycled for
+// credentials that expire very soon.
+const minConnLifetime = time.Minute
+
+// CredentialRefresher supplies the password for every new pooled connection,
+// so connections authenticating with short-lived tokens keep working after
+// the token that opened the pool has expired.
+type CredentialRefresher interface {
+	Credential(ctx context.Context) (secrets.Credential, error)
+	// Invalidate forgets a cached credential after the server rejected it.
+	Invalidate()
+}
+
+// credentialSource is implemented by secrets managers that know when a
+// secret expires, such as secrets.Registry.
+type credentialSource interface {
+	RetrieveCredential(keyID string) (secrets.Credential, error)
+	Invalidate(keyID string)
+}
+
+type secretRefresher struct {
+	mgr   secrets.Manager
+	keyID string
+}
+
+// NewSecretRefresher refreshes the secret keyID from mgr. Expirations are
+// only known for managers that report them, e.g. cmd: helper references.
+func NewSecretRefresher(mgr secrets.Manager, keyID string) CredentialRefresher {
+	return &secretRefresher{mgr: mgr, keyID: keyID}
+}
+
+func (r *secretRefresher) Credential(context.Context) (secrets.Credential, error) {
+	if src, ok := r.mgr.(credentialSource); ok {
+		return src.RetrieveCredential(r.keyID)
+	}
+	secret, err := r.mgr.RetrieveSecret(r.keyID)
+	return secrets.Credential{Secret: secret}, err
+}
+
+func (r *secretRefresher) Invalidate() {
+	if src, ok := r.mgr.(credentialSource); ok {
+		src.Invalidate(r.keyID)
+	}
+}
+
+// credentialConnector opens every connection with a freshly refreshed
+// password. An authentication failure invalidates the credential and retries
+// once with a new one, for tokens revoked before their reported expiry.
+type credentialConnector struct {
+	drv       driver.Driver
+	dsn       func(password string) string
+	refresher CredentialRefresher
+}
+
+func (c *credentialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	cred, err := c.refresher.Credential(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh credentials: %w", err)
+	}
+
+	conn, err := c.open(ctx, cred.Secret)
+	if err == nil || !isAuthError(err) {
+		return conn, err
+	}
+
+	c.refresher.Invalidate()
+	fresh, refreshErr := c.refresher.Credential(ctx)
+	if refreshErr != nil || fresh.Secret == cred.Secret {
+		return nil, err
+	}
+	logging.Info().Msg("Retrying connection with refreshed credentials")
+	return c.open(ctx, fresh.Secret)
+}
+
+func (c *credentialConnector) open(ctx context.Context, password string) (driver.Conn, error) {
+	dsn := c.dsn(password)
+	if dc, ok := c.drv.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return connector.Connect(ctx)
+	}
+	return c.drv.Open(dsn)
+}
+
+func (c *credentialConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+// isAuthError reports whether err is the server rejecting the password.
+func isAuthError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Class() == "28"
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1045
+	}
+	return false
+}
+
+// storedPassword returns the password stored under conn.SecretKeyID. A
+// secret that cannot be read is logged and treated as no password, unless
+// the secrets are locked.
+func storedPassword(conn *models.Connection, secretsMgr secrets.Manager) (string, error) {
+	if conn.SecretKeyID == "" || secretsMgr == nil {
+		return "", nil
+	}
+	password, err := secretsMgr.RetrieveSecret(conn.SecretKeyID)
+	if errors.Is(err, secrets.ErrSecretsLocked) {
+		return "", err
+	}
+	if err != nil {
+		warnMissingSecret(conn.SecretKeyID, err)
+		return "", nil
+	}
+	return password, nil
+}
+
+func warnMissingSecret(keyID string, err error) {
+	logging.Warn().
+		Err(err).
+		Str("secretKeyID", keyID).
+		Msg("Failed to retrieve password from secrets manager, connection will proceed without stored password")
+}
+
+// connectWithSecret connects with the password stored under the resolved
+// connection's SecretKeyID. A password that expires is fetched again for
+// every new pooled connection; others are baked into the DSN once.
+func (bd *BaseDriver) connectWithSecret(ctx context.Context, driverName string, conn, resolved *models.Connection, secretsMgr secrets.Manager, dsn func(password string) string) error {
+	if resolved.SecretKeyID == "" || secretsMgr == nil {
+		return bd.ConnectWithDSN(ctx, driverName, dsn(""), conn)
+	}
+
+	refresher := NewSecretRefresher(secretsMgr, resolved.SecretKeyID)
+	cred, err := refresher.Credential(ctx)
+	if errors.Is(err, secrets.ErrSecretsLocked) {
+		return err
+	}
+	if err != nil {
+		warnMissingSecret(resolved.SecretKeyID, err)
+		return bd.ConnectWithDSN(ctx, driverName, dsn(""), conn)
+	}
+	if cred.Expires.IsZero() {
+		return bd.ConnectWithDSN(ctx, driverName, dsn(cred.Secret), conn)
+	}
+	return bd.ConnectWithRefresher(ctx, driverName, conn, refresher, dsn, cred.Expires)
+}
+
+// ConnectWithRefresher opens a pool whose connections each authenticate with
+// a password from refresher, passed to dsn to build the connection string.
+// Connections are recycled after the lifetime of the first credential, so
+// servers that end sessions when their token expires see no interruption.
+func (bd *BaseDriver) ConnectWithRefresher(ctx context.Context, driverName string, conn *models.Connection, refresher CredentialRefresher, dsn func(password string) string, expires time.Time) error {
+	drv, err := lookupDriver(driverName)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConnectionFailed, err)
+	}
+
+	var connector driver.Connector = &credentialConnector{drv: drv, dsn: dsn, refresher: refresher}
+	if conn != nil && conn.ReadOnly && readOnlySessionSQL(conn.Type) != "" {
+		connector = &sessionConnector{base: connector, statements: []string{readOnlySessionSQL(conn.Type)}}
+	}
+
+	db := sql.OpenDB(connector)
+	if !expires.IsZero() {
+		db.SetConnMaxLifetime(max(time.Until(expires), minConnLifetime))
+	}
+	return bd.finishConnect(ctx, db, conn)
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/secrets"
+	"github.com/lib/pq"
+)
+
+// tokenServer accepts only the password in accept, like a database checking
+// short-lived tokens.
+type tokenServer struct {
+	mu     sync.Mutex
+	accept string
+	down   bool
+	dsns   []string
+}
+
+func (s *tokenServer) Open(dsn string) (driver.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dsns = append(s.dsns, dsn)
+	if s.down {
+		return nil, errors.New("connection refused")
+	}
+	if dsn != s.accept {
+		return nil, &pq.Error{Code: "28P01", Message: "password authentication failed"}
+	}
+	return tokenConn{}, nil
+}
+
+type tokenConn struct{}
+
+func (tokenConn) Prepare(string) (driver.Stmt, error) { return nil, ErrUnsupportedOperation }
+func (tokenConn) Close() error                        { return nil }
+func (tokenConn) Begin() (driver.Tx, error)           { return nil, ErrUnsupportedOperation }
+
+// stubRefresher hands out tokens in order; Invalidate moves to the next one.
+type stubRefresher struct {
+	tokens      []string
+	next        int
+	invalidated int
+}
+
+func (r *stubRefresher) Credential(context.Context) (secrets.Credential, error) {
+	return secrets.Credential{Secret: r.tokens[r.next]}, nil
+}
+
+func (r *stubRefresher) Invalidate() {
+	r.invalidated++
+	if r.next < len(r.tokens)-1 {
+		r.next++
+	}
+}
+
+func TestCredentialConnectorUsesCurrentToken(t *testing.T) {
+	server := &tokenServer{accept: "t1"}
+	refresher := &stubRefresher{tokens: []string{"t1", "t2"}}
+	db := sql.OpenDB(&credentialConnector{drv: server, dsn: func(p string) string { return p }, refresher: refresher})
+	defer func() { _ = db.Close() }()
+
+	ctx := context.Background()
+	first, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("first connection: %v", err)
+	}
+	defer func() { _ = first.Close() }()
+
+	// The token rotates; the next pooled connection must pick it up.
+	refresher.next = 1
+	server.accept = "t2"
+	second, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("second connection: %v", err)
+	}
+	defer func() { _ = second.Close() }()
+
+	if len(server.dsns) != 2 || server.dsns[0] != "t1" || server.dsns[1] != "t2" {
+		t.Errorf("dsns = %v", server.dsns)
+	}
+	if refresher.invalidated != 0 {
+		t.Errorf("invalidated %d times, want 0", refresher.invalidated)
+	}
+}
+
+func TestCredentialConnectorRetriesRejectedToken(t *testing.T) {
+	server := &tokenServer{accept: "fresh"}
+	refresher := &stubRefresher{tokens: []string{"revoked", "fresh"}}
+	c := &credentialConnector{drv: server, dsn: func(p string) string { return p }, refresher: refresher}
+
+	conn, err := c.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	_ = conn.Close()
+	if refresher.invalidated != 1 || len(server.dsns) != 2 {
+		t.Errorf("invalidated %d times with dsns %v", refresher.invalidated, server.dsns)
+	}
+
+	// Other failures are returned without asking for a new token.
+	server.down = true
+	if _, err := c.Connect(context.Background()); err == nil || isAuthError(err) {
+		t.Errorf("error = %v, want the connection failure", err)
+	}
+	if refresher.invalidated != 1 {
+		t.Errorf("invalidated %d times after a non-auth failure", refresher.invalidated)
+	}
+
+	// A rejected token that does not change is not retried.
+	server.down = false
+	server.accept = "other"
+	if _, err := c.Connect(context.Background()); !isAuthError(err) {
+		t.Errorf("error = %v, want the authentication failure", err)
+	}
+}
+
+func TestSecretRefresherPlainManager(t *testing.T) {
+	r := NewSecretRefresher(mapSecrets{"k": "pw"}, "k")
+	cred, err := r.Credential(context.Background())
+	if err != nil || cred.Secret != "pw" || !cred.Expires.IsZero() {
+		t.Errorf("Credential = %+v, %v", cred, err)
+	}
+	r.Invalidate()
+}
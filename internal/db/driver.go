@@ -15,17 +15,23 @@ type Driver interface {
 	Disconnect(ctx context.Context) error
 	IsConnected() bool
 	Ping(ctx context.Context) error
+	Reconnect(ctx context.Context) error
 	ExecuteQuery(ctx context.Context, sql string, args ...any) (*models.QueryResult, error)
+	ExecuteReadOnlyQuery(ctx context.Context, sql string) (*models.QueryResult, error)
 	ExecuteNonQuery(ctx context.Context, sql string, args ...any) (int64, error)
 	GetSchemas(ctx context.Context) ([]models.Schema, error)
 	GetTables(ctx context.Context, schema models.Schema) ([]models.Table, error)
 	GetTableColumns(ctx context.Context, schemaName, tableName string) (*models.TableColumns, error)
 	GetTableData(ctx context.Context, tableName string, limit int, offset int) (*models.QueryResult, error)
 	GetTableIndexes(ctx context.Context, table string) ([]models.Index, error)
+	GetFunctions(ctx context.Context) ([]models.Function, error)
+	GetSearchPath(ctx context.Context) ([]string, error)
+	GetForeignKeys(ctx context.Context) ([]models.ForeignKey, error)
 	ExecuteTransaction(ctx context.Context, queries []string) error
+	ExecuteTransactionWithLimit(ctx context.Context, queries []string, maxRows int64) (int64, error)
 	GetVersion(ctx context.Context) (string, error)
 	GetServerInfo(ctx context.Context) (*models.ServerInfo, error)
-	GetQueryExecutionPlan(ctx context.Context, sql string) (*models.QueryResult, error)
+	GetQueryExecutionPlan(ctx context.Context, sql string, analyze bool) (*models.QueryPlan, error)
 	GetConnection() *models.Connection
 }
 
@@ -69,19 +75,30 @@ func (bd *BaseDriver) BaseDb() *sql.DB {
 }
 
 // ConnectWithDSN opens a database connection using the provided driver name and DSN.
-// This is a helper for concrete driver Connect implementations.
+// This is a helper for concrete driver Connect implementations. Read-only
+// connections make every pooled session read-only before it is used.
 func (bd *BaseDriver) ConnectWithDSN(ctx context.Context, driverName, dsn string, conn *models.Connection) error {
-	db, err := sql.Open(driverName, dsn)
+	var db *sql.DB
+	var err error
+	if conn != nil && conn.ReadOnly && readOnlySessionSQL(conn.Type) != "" {
+		db, err = openWithSession(driverName, dsn, []string{readOnlySessionSQL(conn.Type)})
+	} else {
+		db, err = sql.Open(driverName, dsn)
+	}
 	if err != nil {
-		return fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+		return fmt.Errorf("%w: %w", ErrConnectionFailed, err)
 	}
+	return bd.finishConnect(ctx, db, conn)
+}
 
+// finishConnect pings a newly opened pool and adopts it.
+func (bd *BaseDriver) finishConnect(ctx context.Context, db *sql.DB, conn *models.Connection) error {
 	if err := db.PingContext(ctx); err != nil {
 		// Close error is secondary to ping failure - log but don't change the returned error
 		if closeErr := db.Close(); closeErr != nil {
 			logging.Debug().Err(closeErr).Msg("failed to close db after ping failure")
 		}
-		return fmt.Errorf("%w: %v", ErrConnectionFailed, err)
+		return fmt.Errorf("%w: %w", ErrConnectionFailed, err)
 	}
 
 	bd.db = db
@@ -117,11 +134,24 @@ func (bd *BaseDriver) ExecuteQuery(ctx context.Context, query string, args ...an
 
 	rows, err := bd.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+		return nil, queryFailed(err, query)
 	}
 	defer closeRows(rows)
 
-	return scanRowsToResult(rows)
+	result, err := scanRowsToResult(rows)
+	if err != nil {
+		return nil, queryFailed(err, query)
+	}
+	return result, nil
+}
+
+// ExecuteReadOnlyQuery runs a query in a read-only transaction that is rolled
+// back afterwards, so nothing it does can persist.
+func (bd *BaseDriver) ExecuteReadOnlyQuery(ctx context.Context, query string) (*models.QueryResult, error) {
+	if !bd.IsConnected() || bd.db == nil {
+		return nil, ErrNotConnected
+	}
+	return queryInRollbackTx(ctx, bd.db, query, &sql.TxOptions{ReadOnly: true})
 }
 
 // ExecuteNonQuery runs a statement that doesn't return rows (INSERT, UPDATE, DELETE).
@@ -132,7 +162,7 @@ func (bd *BaseDriver) ExecuteNonQuery(ctx context.Context, query string, args ..
 
 	result, err := bd.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return 0, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+		return 0, queryFailed(err, query)
 	}
 
 	return result.RowsAffected()
@@ -145,3 +175,12 @@ func (bd *BaseDriver) ExecuteTransaction(ctx context.Context, queries []string)
 	}
 	return executeTransaction(ctx, bd.db, queries)
 }
+
+// ExecuteTransactionWithLimit runs queries in a transaction and rolls it back
+// if more than maxRows rows are affected in total.
+func (bd *BaseDriver) ExecuteTransactionWithLimit(ctx context.Context, queries []string, maxRows int64) (int64, error) {
+	if !bd.IsConnected() || bd.db == nil {
+		return 0, ErrNotConnected
+	}
+	return executeWithRowLimit(ctx, bd.db, queries, maxRows)
+}
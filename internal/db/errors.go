@@ -14,4 +14,16 @@ var (
 	ErrOperationTimeout     = errors.New("operation timeout")
 	ErrUnsupportedOperation = errors.New("this operation is not supported")
 	ErrInvalidIdentifier    = errors.New("invalid identifier")
+	ErrRowLimitExceeded     = errors.New("row limit exceeded, changes rolled back")
+	ErrUnresolvedReference  = errors.New("unresolved connection reference")
 )
+
+// ErrorPosition returns where in the query text the server located an error,
+// as a zero-based character (not byte) offset.
+func ErrorPosition(err error) (int, bool) {
+	qe, ok := AsQueryError(err)
+	if !ok || qe.Position < 0 {
+		return 0, false
+	}
+	return qe.Position, true
+}
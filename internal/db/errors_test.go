@@ -0,0 +1,25 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestErrorPosition(t *testing.T) {
+	wrapped := fmt.Errorf("failed to execute query: %w", &pq.Error{Message: "syntax error", Position: "8"})
+	if pos, ok := ErrorPosition(wrapped); !ok || pos != 7 {
+		t.Fatalf("ErrorPosition = %d, %v, want 7, true", pos, ok)
+	}
+	if pos, ok := ErrorPosition(queryFailed(&pq.Error{Message: "syntax error", Position: "3"}, "SELEC 1")); !ok || pos != 2 {
+		t.Fatalf("ErrorPosition of a converted error = %d, %v, want 2, true", pos, ok)
+	}
+	if _, ok := ErrorPosition(&pq.Error{Message: "deadlock detected"}); ok {
+		t.Fatal("an error without a position should report none")
+	}
+	if _, ok := ErrorPosition(errors.New("near \"FROM\": syntax error")); ok {
+		t.Fatal("an error that is not from a server should report no position")
+	}
+}
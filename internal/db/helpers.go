@@ -68,9 +68,151 @@ func executeTransaction(ctx context.Context, db *sql.DB, queries []string) error
 			if rbErr := tx.Rollback(); rbErr != nil {
 				logging.Warn().Err(rbErr).Msg("rollback failed after query error")
 			}
-			return fmt.Errorf("%w: %v", ErrQueryFailed, err)
+			return queryFailed(err, q)
 		}
 	}
 
 	return tx.Commit()
 }
+
+// executeWithRowLimit runs queries in a transaction, committing only if the
+// total rows affected stays within maxRows. A non-positive maxRows disables the
+// limit.
+func executeWithRowLimit(ctx context.Context, db *sql.DB, queries []string, maxRows int64) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrQueryFailed, err)
+	}
+
+	rollback := func() {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logging.Warn().Err(rbErr).Msg("rollback failed")
+		}
+	}
+
+	var total int64
+	for _, q := range queries {
+		result, err := tx.ExecContext(ctx, q)
+		if err != nil {
+			rollback()
+			return 0, queryFailed(err, q)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			total += n
+		}
+		if maxRows > 0 && total > maxRows {
+			rollback()
+			return total, fmt.Errorf("%w: %d rows affected, limit is %d", ErrRowLimitExceeded, total, maxRows)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, queryFailed(err, "")
+	}
+	return total, nil
+}
+
+// queryInRollbackTx runs a query inside a transaction that is always rolled
+// back, so statements such as EXPLAIN ANALYZE on DML leave no trace. opts may
+// be nil.
+func queryInRollbackTx(ctx context.Context, db *sql.DB, query string, opts *sql.TxOptions) (*models.QueryResult, error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
+	}
+	defer func() {
+		if rbErr := tx.Rollback(); rbErr != nil && rbErr != sql.ErrTxDone {
+			logging.Warn().Err(rbErr).Msg("rollback failed after rolled back query")
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, queryFailed(err, query)
+	}
+	defer closeRows(rows)
+
+	result, err := scanRowsToResult(rows)
+	if err != nil {
+		return nil, queryFailed(err, query)
+	}
+	return result, nil
+}
+
+// singleTextValue extracts the first column of the first row as a string,
+// which is how EXPLAIN returns JSON and tree formatted plans.
+func singleTextValue(result *models.QueryResult) (string, error) {
+	if result == nil || len(result.Rows) == 0 || len(result.Rows[0]) == 0 {
+		return "", fmt.Errorf("%w: empty plan output", ErrQueryFailed)
+	}
+	switch v := result.Rows[0][0].(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		return fmt.Sprintf("%v", v), nil
+	}
+}
+
+func toInt64(v any) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case float64:
+		return int64(n)
+	case []byte:
+		var i int64
+		_, _ = fmt.Sscan(string(n), &i)
+		return i
+	case string:
+		var i int64
+		_, _ = fmt.Sscan(n, &i)
+		return i
+	default:
+		return 0
+	}
+}
+
+// scanFunctionRows reads schema, name, arguments and return type columns.
+// The caller must close rows after this function returns.
+func scanFunctionRows(rows *sql.Rows) ([]models.Function, error) {
+	var functions []models.Function
+	for rows.Next() {
+		var fn models.Function
+		if err := rows.Scan(&fn.Schema, &fn.Name, &fn.Arguments, &fn.ReturnType); err != nil {
+			return nil, fmt.Errorf("failed to scan function row: %w", err)
+		}
+		functions = append(functions, fn)
+	}
+	return functions, rows.Err()
+}
+
+// scanForeignKeyRows reads one row per foreign key column: name, schema,
+// table, column, referenced schema, table and column. Rows of a key must be
+// adjacent and in column order. The caller must close rows after this
+// function returns.
+func scanForeignKeyRows(rows *sql.Rows) ([]models.ForeignKey, error) {
+	var keys []models.ForeignKey
+	for rows.Next() {
+		var fk models.ForeignKey
+		var column, refColumn string
+		if err := rows.Scan(&fk.Name, &fk.Schema, &fk.Table, &column, &fk.RefSchema, &fk.RefTable, &refColumn); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign key row: %w", err)
+		}
+
+		if n := len(keys); n > 0 && keys[n-1].Name == fk.Name && keys[n-1].Schema == fk.Schema && keys[n-1].Table == fk.Table {
+			keys[n-1].Columns = append(keys[n-1].Columns, column)
+			keys[n-1].RefColumns = append(keys[n-1].RefColumns, refColumn)
+			continue
+		}
+		fk.Columns = []string{column}
+		fk.RefColumns = []string{refColumn}
+		keys = append(keys, fk)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/android-lewis/dbsmith/internal/secrets"
+)
+
+const secretRefPrefix = "secret:"
+
+// maskedSecret stands in for secret values in previews.
+const maskedSecret = "******"
+
+// referenceResolver looks up one ${...} reference; ref is the text between
+// the braces.
+type referenceResolver func(ref string) (string, error)
+
+// HasReferences reports whether s contains a ${...} reference.
+func HasReferences(s string) bool {
+	return strings.Contains(strings.ReplaceAll(s, "$${", ""), "${")
+}
+
+// ExpandReferences replaces ${NAME} and ${env:NAME} with environment
+// variables and ${secret:key} with the secret stored under key. $${ is a
+// literal ${.
+func ExpandReferences(s string, secretsMgr secrets.Manager) (string, error) {
+	return expandReferences(s, func(ref string) (string, error) {
+		return resolveReference(ref, secretsMgr, false)
+	})
+}
+
+func resolveReference(ref string, secretsMgr secrets.Manager, mask bool) (string, error) {
+	if key, ok := strings.CutPrefix(ref, secretRefPrefix); ok {
+		if key == "" {
+			return "", fmt.Errorf("%w: ${%s} names no secret", ErrUnresolvedReference, ref)
+		}
+		// Connection fields can come from a shared workspace, so they may only
+		// name stored secrets. A provider reference would let the file run
+		// commands (cmd:, pass:) or read files (file:) on every connect.
+		if secrets.IsProviderRef(key) {
+			scheme, _, _ := strings.Cut(key, ":")
+			return "", fmt.Errorf("%w: ${secret:%s:...} is not allowed; connection fields may only reference stored secrets", ErrUnresolvedReference, scheme)
+		}
+		if mask {
+			return maskedSecret, nil
+		}
+		if secretsMgr == nil {
+			return "", fmt.Errorf("%w: secret %s cannot be read without a secrets manager", ErrUnresolvedReference, key)
+		}
+		value, err := secretsMgr.RetrieveSecret(key)
+		if err != nil {
+			return "", fmt.Errorf("%w: secret %s: %w", ErrUnresolvedReference, key, err)
+		}
+		return value, nil
+	}
+
+	name := strings.TrimPrefix(ref, "env:")
+	if name == "" {
+		return "", fmt.Errorf("%w: ${%s} names no variable", ErrUnresolvedReference, ref)
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrUnresolvedReference, name)
+	}
+	return value, nil
+}
+
+func expandReferences(s string, resolve referenceResolver) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			sb.WriteString(s)
+			return sb.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			sb.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("%w: unterminated reference in %q", ErrUnresolvedReference, s)
+		}
+		value, err := resolve(strings.TrimSpace(s[i+2 : i+end]))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(s[:i])
+		sb.WriteString(value)
+		s = s[i+end+1:]
+	}
+}
+
+// ResolveConnection returns a copy of conn with every reference in its host,
+// port, database, username and CA certificate path expanded. Errors name the
+// connection and field as well as the missing variable or secret.
+func ResolveConnection(conn *models.Connection, secretsMgr secrets.Manager) (*models.Connection, error) {
+	return resolveConnection(conn, func(ref string) (string, error) {
+		return resolveReference(ref, secretsMgr, false)
+	})
+}
+
+// ResolveConnectionWithoutSecrets is ResolveConnection with ${secret:...}
+// references left as written, for identifying a connection without reading
+// its secrets.
+func ResolveConnectionWithoutSecrets(conn *models.Connection) (*models.Connection, error) {
+	return resolveConnection(conn, func(ref string) (string, error) {
+		if strings.HasPrefix(ref, secretRefPrefix) {
+			return "${" + ref + "}", nil
+		}
+		return resolveReference(ref, nil, false)
+	})
+}
+
+// PreviewReferences expands s like ExpandReferences but masks secrets, for
+// display next to the template it came from.
+func PreviewReferences(s string) (string, error) {
+	return expandReferences(s, func(ref string) (string, error) {
+		return resolveReference(ref, nil, true)
+	})
+}
+
+func resolveConnection(conn *models.Connection, resolve referenceResolver) (*models.Connection, error) {
+	resolved := *conn
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"host", &resolved.Host},
+		{"database", &resolved.Database},
+		{"username", &resolved.Username},
+		{"ssl_ca_cert_path", &resolved.SSLCACertPath},
+	}
+	for _, f := range fields {
+		value, err := expandReferences(*f.value, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("connection %q, %s: %w", conn.Name, f.name, err)
+		}
+		*f.value = value
+	}
+
+	if conn.PortTemplate != "" {
+		value, err := expandReferences(conn.PortTemplate, resolve)
+		if err != nil {
+			return nil, fmt.Errorf("connection %q, port: %w", conn.Name, err)
+		}
+		if resolved.Port, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+			return nil, fmt.Errorf("connection %q, port: %q from %s is not a number", conn.Name, value, conn.PortTemplate)
+		}
+		resolved.PortTemplate = ""
+	}
+
+	return &resolved, nil
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/android-lewis/dbsmith/internal/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+type mapSecrets map[string]string
+
+func (m mapSecrets) StoreSecret(keyID, secret string) error { m[keyID] = secret; return nil }
+func (m mapSecrets) DeleteSecret(keyID string) error        { delete(m, keyID); return nil }
+func (m mapSecrets) RetrieveSecret(keyID string) (string, error) {
+	if v, ok := m[keyID]; ok {
+		return v, nil
+	}
+	return "", errors.New("not found")
+}
+
+func TestExpandReferences(t *testing.T) {
+	t.Setenv("DBSMITH_TEST_HOST", "db.internal")
+	t.Setenv("DBSMITH_TEST_EMPTY", "")
+	secrets := mapSecrets{"ci-user": "robot"}
+
+	tests := []struct {
+		in, want string
+	}{
+		{"localhost", "localhost"},
+		{"${DBSMITH_TEST_HOST}", "db.internal"},
+		{"${env:DBSMITH_TEST_HOST}:5432", "db.internal:5432"},
+		{"${secret:ci-user}@${ DBSMITH_TEST_HOST }", "robot@db.internal"},
+		{"x${DBSMITH_TEST_EMPTY}y", "xy"},
+		{"$${DBSMITH_TEST_HOST}", "${DBSMITH_TEST_HOST}"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandReferences(tt.in, secrets)
+		if err != nil {
+			t.Errorf("ExpandReferences(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ExpandReferences(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"${DBSMITH_TEST_MISSING}", "${secret:nope}", "${DBSMITH_TEST_HOST", "${}"} {
+		if _, err := ExpandReferences(in, secrets); !errors.Is(err, ErrUnresolvedReference) {
+			t.Errorf("ExpandReferences(%q) error = %v, want ErrUnresolvedReference", in, err)
+		}
+	}
+}
+
+func TestSecretReferencesRejectProviders(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	registry := secrets.NewRegistry(mapSecrets{}, secrets.CommandOptions{})
+
+	conn := &models.Connection{Name: "shared", Type: models.PostgresType, Host: "${secret:cmd:touch " + marker + "}"}
+	if _, err := ResolveConnection(conn, registry); !errors.Is(err, ErrUnresolvedReference) {
+		t.Errorf("ResolveConnection error = %v, want ErrUnresolvedReference", err)
+	}
+	if _, err := PreviewReferences(conn.Host); !errors.Is(err, ErrUnresolvedReference) {
+		t.Errorf("PreviewReferences error = %v, want ErrUnresolvedReference", err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("cmd: reference was executed (stat error %v)", err)
+	}
+
+	for _, ref := range []string{"${secret:pass:db/prod}", "${secret:file:/etc/passwd}", "${secret:env:HOME}"} {
+		if _, err := ExpandReferences(ref, registry); !errors.Is(err, ErrUnresolvedReference) {
+			t.Errorf("ExpandReferences(%q) error = %v, want ErrUnresolvedReference", ref, err)
+		}
+	}
+}
+
+func TestResolveConnection(t *testing.T) {
+	t.Setenv("DBSMITH_TEST_HOST", "db.internal")
+	t.Setenv("DBSMITH_TEST_PORT", "6543")
+
+	conn := &models.Connection{
+		Name:         "ci",
+		Type:         models.PostgresType,
+		Host:         "${DBSMITH_TEST_HOST}",
+		PortTemplate: "${DBSMITH_TEST_PORT}",
+		Database:     "app",
+		Username:     "${secret:ci-user}",
+	}
+	resolved, err := ResolveConnection(conn, mapSecrets{"ci-user": "robot"})
+	if err != nil {
+		t.Fatalf("ResolveConnection: %v", err)
+	}
+	if resolved.Host != "db.internal" || resolved.Port != 6543 || resolved.Username != "robot" {
+		t.Errorf("resolved = %+v", resolved)
+	}
+	if conn.Host != "${DBSMITH_TEST_HOST}" {
+		t.Error("ResolveConnection modified the original connection")
+	}
+
+	public, err := ResolveConnectionWithoutSecrets(conn)
+	if err != nil {
+		t.Fatalf("ResolveConnectionWithoutSecrets: %v", err)
+	}
+	if public.Host != "db.internal" || public.Port != 6543 || public.Username != "${secret:ci-user}" {
+		t.Errorf("resolved without secrets = %+v", public)
+	}
+
+	preview, err := PreviewReferences(conn.Username + "@" + conn.Host)
+	if err != nil {
+		t.Fatalf("PreviewReferences: %v", err)
+	}
+	if preview != maskedSecret+"@db.internal" {
+		t.Errorf("preview = %q, want the secret masked", preview)
+	}
+
+	conn.Database = "${DBSMITH_TEST_MISSING_DB}"
+	_, err = ResolveConnection(conn, nil)
+	if !errors.Is(err, ErrUnresolvedReference) {
+		t.Fatalf("error = %v, want ErrUnresolvedReference", err)
+	}
+	for _, want := range []string{`"ci"`, "database", "DBSMITH_TEST_MISSING_DB"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	t.Setenv("DBSMITH_TEST_PORT", "abc")
+	conn.Database = "app"
+	conn.Username = "me"
+	if _, err := ResolveConnection(conn, nil); err == nil || !strings.Contains(err.Error(), "not a number") {
+		t.Errorf("non-numeric port error = %v", err)
+	}
+}
+
+func TestPostgresConnectionStringResolvesReferences(t *testing.T) {
+	t.Setenv("DBSMITH_TEST_HOST", "db.internal")
+
+	d := NewPostgresDriver()
+	dsn, err := d.buildConnectionString(&models.Connection{
+		Name: "ci", Type: models.PostgresType, Host: "${DBSMITH_TEST_HOST}", Port: 5432, Database: "app",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dsn, "host=db.internal") {
+		t.Errorf("dsn = %q", dsn)
+	}
+
+	_, err = d.buildConnectionString(&models.Connection{Name: "ci", Type: models.PostgresType, Host: "${DBSMITH_TEST_NOPE}"}, nil)
+	if !errors.Is(err, ErrUnresolvedReference) {
+		t.Errorf("error = %v, want ErrUnresolvedReference", err)
+	}
+}
+
+func TestConnectionPortTemplateYAML(t *testing.T) {
+	in := "name: ci\ntype: postgres\nhost: ${DB_HOST}\nport: ${DB_PORT}\n"
+	var conn models.Connection
+	if err := yaml.Unmarshal([]byte(in), &conn); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if conn.PortTemplate != "${DB_PORT}" || conn.Port != 0 || conn.Host != "${DB_HOST}" {
+		t.Errorf("decoded = %+v", conn)
+	}
+
+	out, err := yaml.Marshal(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("round trip:\n%s\nwant:\n%s", out, in)
+	}
+
+	var plain models.Connection
+	if err := yaml.Unmarshal([]byte("name: x\ntype: mysql\nport: 3306\n"), &plain); err != nil || plain.Port != 3306 {
+		t.Errorf("numeric port: %+v, %v", plain, err)
+	}
+}
@@ -2,7 +2,10 @@ package db
 
 import (
 	"context"
+	"database/sql/driver"
+	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/android-lewis/dbsmith/internal/models"
@@ -15,6 +18,9 @@ type MockDriver struct {
 	queryResults    map[string]*models.QueryResult
 	queryRowResults map[string][]interface{}
 	queryDelays     map[string]time.Duration
+	sessionLost     atomic.Bool
+	reconnectFails  atomic.Bool
+	reconnects      atomic.Int32
 }
 
 func NewMockDriver() *MockDriver {
@@ -29,6 +35,22 @@ func (md *MockDriver) SetQueryDelay(queryPattern string, delay time.Duration) {
 	md.queryDelays[queryPattern] = delay
 }
 
+// SetSessionLost makes pings and queries fail with driver.ErrBadConn, as if
+// the server went away, until Reconnect succeeds.
+func (md *MockDriver) SetSessionLost(lost bool) {
+	md.sessionLost.Store(lost)
+}
+
+// SetReconnectFails makes Reconnect fail while the session is lost.
+func (md *MockDriver) SetReconnectFails(fail bool) {
+	md.reconnectFails.Store(fail)
+}
+
+// Reconnects returns how often Reconnect was called.
+func (md *MockDriver) Reconnects() int {
+	return int(md.reconnects.Load())
+}
+
 func (md *MockDriver) getDelay(sql string) time.Duration {
 	for pattern, delay := range md.queryDelays {
 		if strings.Contains(sql, pattern) {
@@ -80,6 +102,21 @@ func (md *MockDriver) Ping(ctx context.Context) error {
 	if !md.IsConnected() {
 		return ErrNotConnected
 	}
+	if md.sessionLost.Load() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+func (md *MockDriver) Reconnect(ctx context.Context) error {
+	md.reconnects.Add(1)
+	if !md.IsConnected() {
+		return ErrNotConnected
+	}
+	if md.reconnectFails.Load() {
+		return driver.ErrBadConn
+	}
+	md.sessionLost.Store(false)
 	return nil
 }
 
@@ -94,6 +131,10 @@ func (md *MockDriver) ExecuteQuery(ctx context.Context, sql string, args ...inte
 	default:
 	}
 
+	if md.sessionLost.Load() {
+		return nil, driver.ErrBadConn
+	}
+
 	if err := md.waitWithContext(ctx, md.getDelay(sql)); err != nil {
 		return nil, err
 	}
@@ -121,6 +162,10 @@ func (md *MockDriver) ExecuteQuery(ctx context.Context, sql string, args ...inte
 	}, nil
 }
 
+func (md *MockDriver) ExecuteReadOnlyQuery(ctx context.Context, sql string) (*models.QueryResult, error) {
+	return md.ExecuteQuery(ctx, sql)
+}
+
 func (md *MockDriver) ExecuteQueryWithParameters(ctx context.Context, query *models.SavedQuery, params map[string]interface{}) (*models.QueryResult, error) {
 	if !md.IsConnected() {
 		return nil, ErrNotConnected
@@ -134,6 +179,9 @@ func (md *MockDriver) ExecuteNonQuery(ctx context.Context, sql string, args ...i
 	if !md.IsConnected() {
 		return 0, ErrNotConnected
 	}
+	if md.sessionLost.Load() {
+		return 0, driver.ErrBadConn
+	}
 
 	if err := md.waitWithContext(ctx, md.getDelay(sql)); err != nil {
 		return 0, err
@@ -208,9 +256,27 @@ func (md *MockDriver) ExecuteTransaction(ctx context.Context, queries []string)
 	if !md.IsConnected() {
 		return ErrNotConnected
 	}
+	if md.sessionLost.Load() {
+		return driver.ErrBadConn
+	}
 	return nil
 }
 
+func (md *MockDriver) ExecuteTransactionWithLimit(ctx context.Context, queries []string, maxRows int64) (int64, error) {
+	if !md.IsConnected() {
+		return 0, ErrNotConnected
+	}
+	if md.sessionLost.Load() {
+		return 0, driver.ErrBadConn
+	}
+
+	affected := int64(len(queries))
+	if maxRows > 0 && affected > maxRows {
+		return affected, fmt.Errorf("%w: %d rows affected, limit is %d", ErrRowLimitExceeded, affected, maxRows)
+	}
+	return affected, nil
+}
+
 func (md *MockDriver) GetVersion(ctx context.Context) (string, error) {
 	if !md.IsConnected() {
 		return "", ErrNotConnected
@@ -242,16 +308,44 @@ func (md *MockDriver) GetTableIndexes(ctx context.Context, table string) ([]mode
 	return nil, nil
 }
 
-func (md *MockDriver) GetQueryExecutionPlan(ctx context.Context, sql string) (*models.QueryResult, error) {
+func (md *MockDriver) GetFunctions(ctx context.Context) ([]models.Function, error) {
 	if !md.IsConnected() {
 		return nil, ErrNotConnected
 	}
-	return &models.QueryResult{
-		Columns:     []string{"QUERY PLAN"},
-		ColumnTypes: []string{"TEXT"},
-		Rows: [][]interface{}{
-			{"Seq Scan on mock_table"},
-		},
-		RowCount: 1,
-	}, nil
+	return nil, nil
+}
+
+func (md *MockDriver) GetSearchPath(ctx context.Context) ([]string, error) {
+	if !md.IsConnected() {
+		return nil, ErrNotConnected
+	}
+	return nil, nil
+}
+
+func (md *MockDriver) GetForeignKeys(ctx context.Context) ([]models.ForeignKey, error) {
+	if !md.IsConnected() {
+		return nil, ErrNotConnected
+	}
+	return nil, nil
+}
+
+func (md *MockDriver) GetQueryExecutionPlan(ctx context.Context, sql string, analyze bool) (*models.QueryPlan, error) {
+	if !md.IsConnected() {
+		return nil, ErrNotConnected
+	}
+	root := &models.PlanNode{
+		Operation:     "Seq Scan",
+		Relation:      "mock_table",
+		TotalCost:     10,
+		HasCost:       true,
+		EstimatedRows: 1,
+		SeqScan:       true,
+	}
+	if analyze {
+		root.HasActual = true
+		root.ActualRows = 1
+		root.ActualTimeMs = 0.1
+		root.Loops = 1
+	}
+	return &models.QueryPlan{Root: root, Dialect: "postgresql", Analyzed: analyze}, nil
 }
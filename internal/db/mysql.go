@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/android-lewis/dbsmith/internal/explain"
 	"github.com/android-lewis/dbsmith/internal/logging"
 	"github.com/android-lewis/dbsmith/internal/models"
 	"github.com/android-lewis/dbsmith/internal/secrets"
@@ -38,12 +39,14 @@ func (d *MySQLDriver) Connect(ctx context.Context, conn *models.Connection, secr
 		return err
 	}
 
-	dsn, err := d.buildConnectionString(conn, secretsMgr)
+	resolved, err := ResolveConnection(conn, secretsMgr)
 	if err != nil {
 		return err
 	}
 
-	return d.ConnectWithDSN(ctx, "mysql", dsn, conn)
+	return d.connectWithSecret(ctx, "mysql", conn, resolved, secretsMgr, func(password string) string {
+		return d.dsn(resolved, password)
+	})
 }
 
 func (d *MySQLDriver) GetSchemas(ctx context.Context) ([]models.Schema, error) {
@@ -55,7 +58,7 @@ func (d *MySQLDriver) GetSchemas(ctx context.Context) ([]models.Schema, error) {
 
 	rows, err := d.BaseDb().QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
 	}
 	defer closeRows(rows)
 
@@ -83,7 +86,7 @@ func (d *MySQLDriver) GetTables(ctx context.Context, schema models.Schema) ([]mo
 
 	rows, err := d.BaseDb().QueryContext(ctx, query, schema.Name)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
 	}
 	defer closeRows(rows)
 
@@ -295,32 +298,58 @@ func (d *MySQLDriver) GetServerInfo(ctx context.Context) (*models.ServerInfo, er
 	return info, nil
 }
 
-func (d *MySQLDriver) GetQueryExecutionPlan(ctx context.Context, sql string) (*models.QueryResult, error) {
+// GetQueryExecutionPlan returns the parsed plan for sql. MySQL only emits
+// tree output for EXPLAIN ANALYZE, which runs in a rolled back transaction.
+func (d *MySQLDriver) GetQueryExecutionPlan(ctx context.Context, sql string, analyze bool) (*models.QueryPlan, error) {
 	if !d.IsConnected() || d.BaseDb() == nil {
 		return nil, ErrNotConnected
 	}
 
-	explainSQL := "EXPLAIN " + sql
-	return d.ExecuteQuery(ctx, explainSQL)
+	if analyze {
+		result, err := queryInRollbackTx(ctx, d.BaseDb(), "EXPLAIN ANALYZE "+sql, nil)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := singleTextValue(result)
+		if err != nil {
+			return nil, err
+		}
+		return explain.ParseMySQLTree(raw)
+	}
+
+	result, err := d.ExecuteQuery(ctx, "EXPLAIN FORMAT=JSON "+sql)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := singleTextValue(result)
+	if err != nil {
+		return nil, err
+	}
+	return explain.ParseMySQL(raw)
 }
 
 func (d *MySQLDriver) buildConnectionString(conn *models.Connection, secretsMgr secrets.Manager) (string, error) {
+	conn, err := ResolveConnection(conn, secretsMgr)
+	if err != nil {
+		return "", err
+	}
+	password, err := storedPassword(conn, secretsMgr)
+	if err != nil {
+		return "", err
+	}
+	return d.dsn(conn, password), nil
+}
+
+// dsn builds the connection string for a resolved connection.
+func (d *MySQLDriver) dsn(conn *models.Connection, password string) string {
 	var userPass string
 
 	if conn.Username != "" {
 		userPass = conn.Username
 	}
 
-	if conn.SecretKeyID != "" && secretsMgr != nil {
-		password, err := secretsMgr.RetrieveSecret(conn.SecretKeyID)
-		if err != nil {
-			logging.Warn().
-				Err(err).
-				Str("secretKeyID", conn.SecretKeyID).
-				Msg("Failed to retrieve password from secrets manager, connection will proceed without stored password")
-		} else if password != "" {
-			userPass += ":" + password
-		}
+	if password != "" {
+		userPass += ":" + password
 	}
 
 	if userPass != "" {
@@ -343,7 +372,85 @@ func (d *MySQLDriver) buildConnectionString(conn *models.Connection, secretsMgr
 
 	params := "parseTime=true&loc=Local"
 
-	return fmt.Sprintf("%s%s/%s?%s", userPass, host, dbName, params), nil
+	return fmt.Sprintf("%s%s/%s?%s", userPass, host, dbName, params)
+}
+
+// GetFunctions lists the stored functions of the current database.
+func (d *MySQLDriver) GetFunctions(ctx context.Context) ([]models.Function, error) {
+	if !d.IsConnected() || d.BaseDb() == nil {
+		return nil, ErrNotConnected
+	}
+
+	query := `
+		SELECT
+			r.ROUTINE_SCHEMA,
+			r.ROUTINE_NAME,
+			COALESCE(GROUP_CONCAT(CONCAT(p.PARAMETER_NAME, ' ', p.DTD_IDENTIFIER)
+				ORDER BY p.ORDINAL_POSITION SEPARATOR ', '), ''),
+			COALESCE(r.DTD_IDENTIFIER, '')
+		FROM information_schema.ROUTINES r
+		LEFT JOIN information_schema.PARAMETERS p
+			ON p.SPECIFIC_SCHEMA = r.ROUTINE_SCHEMA
+			AND p.SPECIFIC_NAME = r.SPECIFIC_NAME
+			AND p.ORDINAL_POSITION > 0
+		WHERE r.ROUTINE_TYPE = 'FUNCTION'
+		AND r.ROUTINE_SCHEMA = DATABASE()
+		GROUP BY r.ROUTINE_SCHEMA, r.ROUTINE_NAME, r.DTD_IDENTIFIER
+		ORDER BY r.ROUTINE_NAME
+	`
+	rows, err := d.BaseDb().QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
+	}
+	defer closeRows(rows)
+
+	return scanFunctionRows(rows)
+}
+
+// GetSearchPath returns the current database, which unqualified names
+// resolve in.
+func (d *MySQLDriver) GetSearchPath(ctx context.Context) ([]string, error) {
+	if !d.IsConnected() || d.BaseDb() == nil {
+		return nil, ErrNotConnected
+	}
+
+	var database string
+	if err := d.BaseDb().QueryRowContext(ctx, "SELECT COALESCE(DATABASE(), '')").Scan(&database); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
+	}
+	if database == "" {
+		return nil, nil
+	}
+	return []string{database}, nil
+}
+
+// GetForeignKeys lists the foreign keys of tables in the current database.
+func (d *MySQLDriver) GetForeignKeys(ctx context.Context) ([]models.ForeignKey, error) {
+	if !d.IsConnected() || d.BaseDb() == nil {
+		return nil, ErrNotConnected
+	}
+
+	query := `
+		SELECT
+			CONSTRAINT_NAME,
+			TABLE_SCHEMA,
+			TABLE_NAME,
+			COLUMN_NAME,
+			REFERENCED_TABLE_SCHEMA,
+			REFERENCED_TABLE_NAME,
+			REFERENCED_COLUMN_NAME
+		FROM information_schema.KEY_COLUMN_USAGE
+		WHERE TABLE_SCHEMA = DATABASE()
+		AND REFERENCED_TABLE_NAME IS NOT NULL
+		ORDER BY TABLE_NAME, CONSTRAINT_NAME, ORDINAL_POSITION
+	`
+	rows, err := d.BaseDb().QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
+	}
+	defer closeRows(rows)
+
+	return scanForeignKeyRows(rows)
 }
 
 func (d *MySQLDriver) GetTableIndexes(ctx context.Context, table string) ([]models.Index, error) {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/android-lewis/dbsmith/internal/explain"
 	"github.com/android-lewis/dbsmith/internal/logging"
 	"github.com/android-lewis/dbsmith/internal/models"
 	"github.com/android-lewis/dbsmith/internal/secrets"
@@ -25,12 +26,14 @@ func (d *PostgresDriver) Connect(ctx context.Context, conn *models.Connection, s
 		return err
 	}
 
-	dsn, err := d.buildConnectionString(conn, secretsMgr)
+	resolved, err := ResolveConnection(conn, secretsMgr)
 	if err != nil {
 		return err
 	}
 
-	return d.ConnectWithDSN(ctx, "postgres", dsn, conn)
+	return d.connectWithSecret(ctx, "postgres", conn, resolved, secretsMgr, func(password string) string {
+		return d.dsn(resolved, password)
+	})
 }
 
 func (d *PostgresDriver) GetSchemas(ctx context.Context) ([]models.Schema, error) {
@@ -42,7 +45,7 @@ func (d *PostgresDriver) GetSchemas(ctx context.Context) ([]models.Schema, error
 
 	rows, err := d.BaseDb().QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
 	}
 	defer closeRows(rows)
 
@@ -73,7 +76,7 @@ func (d *PostgresDriver) GetTables(ctx context.Context, schema models.Schema) ([
 
 	rows, err := d.BaseDb().QueryContext(ctx, query, schema.Name)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
 	}
 	defer closeRows(rows)
 
@@ -290,13 +293,110 @@ func (d *PostgresDriver) GetServerInfo(ctx context.Context) (*models.ServerInfo,
 	return info, nil
 }
 
-func (d *PostgresDriver) GetQueryExecutionPlan(ctx context.Context, sql string) (*models.QueryResult, error) {
+// GetQueryExecutionPlan returns the parsed JSON plan for sql. With analyze
+// set the statement is actually executed, so it runs in a transaction that is
+// rolled back afterwards.
+func (d *PostgresDriver) GetQueryExecutionPlan(ctx context.Context, sql string, analyze bool) (*models.QueryPlan, error) {
 	if !d.IsConnected() || d.BaseDb() == nil {
 		return nil, ErrNotConnected
 	}
 
-	explainSQL := "EXPLAIN ANALYZE " + sql
-	return d.ExecuteQuery(ctx, explainSQL)
+	var result *models.QueryResult
+	var err error
+	if analyze {
+		result, err = queryInRollbackTx(ctx, d.BaseDb(), "EXPLAIN (ANALYZE, FORMAT JSON) "+sql, nil)
+	} else {
+		result, err = d.ExecuteQuery(ctx, "EXPLAIN (FORMAT JSON) "+sql)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := singleTextValue(result)
+	if err != nil {
+		return nil, err
+	}
+	return explain.ParsePostgres(raw)
+}
+
+// GetFunctions lists the functions and aggregates outside the system schemas.
+func (d *PostgresDriver) GetFunctions(ctx context.Context) ([]models.Function, error) {
+	if !d.IsConnected() || d.BaseDb() == nil {
+		return nil, ErrNotConnected
+	}
+
+	query := `
+		SELECT
+			n.nspname,
+			p.proname,
+			pg_get_function_arguments(p.oid),
+			COALESCE(pg_get_function_result(p.oid), '')
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND n.nspname NOT LIKE 'pg_toast%'
+		AND n.nspname NOT LIKE 'pg_temp%'
+		AND p.prokind IN ('f', 'a', 'w')
+		ORDER BY n.nspname, p.proname
+	`
+	rows, err := d.BaseDb().QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
+	}
+	defer closeRows(rows)
+
+	return scanFunctionRows(rows)
+}
+
+// GetSearchPath returns the schemas of the effective search_path, skipping
+// implicit ones such as pg_catalog.
+func (d *PostgresDriver) GetSearchPath(ctx context.Context) ([]string, error) {
+	if !d.IsConnected() || d.BaseDb() == nil {
+		return nil, ErrNotConnected
+	}
+
+	var schemas []string
+	if err := d.BaseDb().QueryRowContext(ctx, "SELECT current_schemas(false)").Scan(pq.Array(&schemas)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
+	}
+	return schemas, nil
+}
+
+// GetForeignKeys lists the foreign keys of tables outside the system schemas.
+func (d *PostgresDriver) GetForeignKeys(ctx context.Context) ([]models.ForeignKey, error) {
+	if !d.IsConnected() || d.BaseDb() == nil {
+		return nil, ErrNotConnected
+	}
+
+	query := `
+		SELECT
+			con.conname,
+			ns.nspname,
+			cl.relname,
+			a.attname,
+			rns.nspname,
+			rcl.relname,
+			ra.attname
+		FROM pg_constraint con
+		CROSS JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, refattnum, ord)
+		JOIN pg_class cl ON cl.oid = con.conrelid
+		JOIN pg_namespace ns ON ns.oid = cl.relnamespace
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+		JOIN pg_class rcl ON rcl.oid = con.confrelid
+		JOIN pg_namespace rns ON rns.oid = rcl.relnamespace
+		JOIN pg_attribute ra ON ra.attrelid = con.confrelid AND ra.attnum = k.refattnum
+		WHERE con.contype = 'f'
+		AND ns.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND ns.nspname NOT LIKE 'pg_toast%'
+		ORDER BY ns.nspname, cl.relname, con.conname, k.ord
+	`
+	rows, err := d.BaseDb().QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
+	}
+	defer closeRows(rows)
+
+	return scanForeignKeyRows(rows)
 }
 
 func (d *PostgresDriver) GetTableIndexes(ctx context.Context, table string) ([]models.Index, error) {
@@ -307,7 +407,7 @@ func (d *PostgresDriver) GetTableIndexes(ctx context.Context, table string) ([]m
 	query := d.buildIndexQuery()
 	rows, err := d.BaseDb().QueryContext(ctx, query, table)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
 	}
 	defer closeRows(rows)
 
@@ -380,6 +480,19 @@ func (d *PostgresDriver) scanIndexRow(rows *sql.Rows) (models.Index, error) {
 }
 
 func (d *PostgresDriver) buildConnectionString(conn *models.Connection, secretsMgr secrets.Manager) (string, error) {
+	conn, err := ResolveConnection(conn, secretsMgr)
+	if err != nil {
+		return "", err
+	}
+	password, err := storedPassword(conn, secretsMgr)
+	if err != nil {
+		return "", err
+	}
+	return d.dsn(conn, password), nil
+}
+
+// dsn builds the connection string for a resolved connection.
+func (d *PostgresDriver) dsn(conn *models.Connection, password string) string {
 	var parts []string
 
 	if conn.Host != "" {
@@ -398,16 +511,8 @@ func (d *PostgresDriver) buildConnectionString(conn *models.Connection, secretsM
 		parts = append(parts, fmt.Sprintf("user=%s", conn.Username))
 	}
 
-	if conn.SecretKeyID != "" && secretsMgr != nil {
-		password, err := secretsMgr.RetrieveSecret(conn.SecretKeyID)
-		if err != nil {
-			logging.Warn().
-				Err(err).
-				Str("secretKeyID", conn.SecretKeyID).
-				Msg("Failed to retrieve password from secrets manager, connection will proceed without stored password")
-		} else if password != "" {
-			parts = append(parts, fmt.Sprintf("password=%s", password))
-		}
+	if password != "" {
+		parts = append(parts, fmt.Sprintf("password=%s", password))
 	}
 
 	if conn.SSL != "" {
@@ -422,5 +527,5 @@ func (d *PostgresDriver) buildConnectionString(conn *models.Connection, secretsM
 		parts = append(parts, fmt.Sprintf("sslrootcert=%s", certPath))
 	}
 
-	return strings.Join(parts, " "), nil
+	return strings.Join(parts, " ")
 }
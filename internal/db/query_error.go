@@ -0,0 +1,372 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+)
+
+// mysqlStates maps MySQL error numbers to the SQLSTATE Postgres uses for the
+// same condition, where MySQL only reports a generic class.
+var mysqlStates = map[uint16]string{
+	1044: "42501",
+	1048: "23502",
+	1052: "42702",
+	1054: "42703",
+	1062: "23505",
+	1064: "42601",
+	1142: "42501",
+	1143: "42501",
+	1146: "42P01",
+	1205: "55P03",
+	1213: "40P01",
+	1305: "42883",
+	1317: "57014",
+	1365: "22012",
+	1366: "22P02",
+	1451: "23503",
+	1452: "23503",
+	1792: "25006",
+	3024: "57014",
+	3819: "23514",
+}
+
+var (
+	mysqlColumnName = regexp.MustCompile(`^(?:Unknown column|Column) '([^']*)'`)
+	mysqlTableName  = regexp.MustCompile(`^Table '([^']*)' doesn't exist`)
+	mysqlKeyName    = regexp.MustCompile(`for key '([^']*)'$`)
+	mysqlForeignKey = regexp.MustCompile("fails \\(`([^`]*)`\\.`([^`]*)`, CONSTRAINT `([^`]*)`")
+	mysqlCheckName  = regexp.MustCompile(`^Check constraint '([^']*)'`)
+	mysqlNear       = regexp.MustCompile(`(?s)near '(.*)' at line (\d+)$`)
+)
+
+// sqliteStates maps SQLite extended result codes to SQLSTATEs.
+var sqliteStates = map[int]string{
+	275:  "23514",
+	787:  "23503",
+	1299: "23502",
+	1555: "23505",
+	2067: "23505",
+}
+
+// sqlitePrimaryStates maps SQLite primary result codes to SQLSTATEs.
+var sqlitePrimaryStates = map[int]string{
+	5: "55P03",
+	6: "55P03",
+	8: "25006",
+	9: "57014",
+}
+
+// sqliteMessages classifies SQLITE_ERROR by the start of its message.
+var sqliteMessages = []struct {
+	prefix string
+	code   string
+}{
+	{"no such column: ", "42703"},
+	{"no such table: ", "42P01"},
+	{"ambiguous column name: ", "42702"},
+	{"no such function: ", "42883"},
+	{"incomplete input", "42601"},
+}
+
+var (
+	sqliteResultCode = regexp.MustCompile(` \(\d+\)( \(SQLITE_BUSY\))?$`)
+	sqliteNear       = regexp.MustCompile(`^near "(.*)": syntax error$`)
+	sqliteConstraint = regexp.MustCompile(`^(?:UNIQUE|NOT NULL) constraint failed: ([^.,]+)\.([^,]+)(,.*)?$`)
+	sqliteCheckName  = regexp.MustCompile(`^CHECK constraint failed: (.+)$`)
+)
+
+// NewQueryError converts an error Postgres, MySQL or SQLite reported for
+// query into a *models.QueryError. Other errors, and errors that already
+// carry one, are returned unchanged.
+func NewQueryError(err error, query string) error {
+	var qe *models.QueryError
+	var pqErr *pq.Error
+	var mysqlErr *mysql.MySQLError
+	var sqliteErr *sqlite.Error
+	switch {
+	case err == nil || errors.As(err, &qe):
+		return err
+	case errors.As(err, &pqErr):
+		return postgresQueryError(pqErr, err, query)
+	case errors.As(err, &mysqlErr):
+		return mysqlQueryError(mysqlErr, err, query)
+	case errors.As(err, &sqliteErr):
+		return sqliteQueryError(sqliteErr, err, query)
+	default:
+		return err
+	}
+}
+
+// AsQueryError returns the server error in err's chain, converting a driver
+// error that has not been converted yet.
+func AsQueryError(err error) (*models.QueryError, bool) {
+	var qe *models.QueryError
+	if errors.As(NewQueryError(err, ""), &qe) {
+		return qe, true
+	}
+	return nil, false
+}
+
+// queryFailed wraps an error from running query, keeping what the server
+// reported about it.
+func queryFailed(err error, query string) error {
+	return fmt.Errorf("%w: %w", ErrQueryFailed, NewQueryError(err, query))
+}
+
+func postgresQueryError(pqErr *pq.Error, err error, query string) *models.QueryError {
+	qe := &models.QueryError{
+		Dialect:    "postgresql",
+		Code:       string(pqErr.Code),
+		Severity:   pqErr.Severity,
+		Message:    pqErr.Message,
+		Detail:     pqErr.Detail,
+		Hint:       pqErr.Hint,
+		Query:      query,
+		Position:   -1,
+		Schema:     pqErr.Schema,
+		Table:      pqErr.Table,
+		Column:     pqErr.Column,
+		Constraint: pqErr.Constraint,
+		Err:        err,
+	}
+	if pos, convErr := strconv.Atoi(pqErr.Position); convErr == nil && pos > 0 {
+		qe.Position = pos - 1
+	}
+	return qe
+}
+
+func mysqlQueryError(mysqlErr *mysql.MySQLError, err error, query string) *models.QueryError {
+	qe := &models.QueryError{
+		Dialect:    "mysql",
+		Code:       mysqlStates[mysqlErr.Number],
+		VendorCode: int(mysqlErr.Number),
+		Severity:   "ERROR",
+		Message:    mysqlErr.Message,
+		Query:      query,
+		Position:   -1,
+		Err:        err,
+	}
+	if qe.Code == "" && mysqlErr.SQLState != [5]byte{} {
+		qe.Code = string(mysqlErr.SQLState[:])
+	}
+
+	msg := mysqlErr.Message
+	if m := mysqlColumnName.FindStringSubmatch(msg); m != nil {
+		qe.Column = lastPart(m[1])
+	}
+	if m := mysqlTableName.FindStringSubmatch(msg); m != nil {
+		qe.Schema, qe.Table = splitQualified(m[1])
+	}
+	if m := mysqlKeyName.FindStringSubmatch(msg); m != nil {
+		qe.Table, qe.Constraint = splitQualified(m[1])
+	}
+	if m := mysqlForeignKey.FindStringSubmatch(msg); m != nil {
+		qe.Schema, qe.Table, qe.Constraint = m[1], m[2], m[3]
+	}
+	if m := mysqlCheckName.FindStringSubmatch(msg); m != nil {
+		qe.Constraint = m[1]
+	}
+	if m := mysqlNear.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[2])
+		qe.Position = nearPosition(query, m[1], line)
+	}
+	return qe
+}
+
+func sqliteQueryError(sqliteErr *sqlite.Error, err error, query string) *models.QueryError {
+	msg := sqliteResultCode.ReplaceAllString(sqliteErr.Error(), "")
+	if _, rest, ok := strings.Cut(msg, ": "); ok {
+		msg = rest
+	}
+
+	code := sqliteErr.Code()
+	qe := &models.QueryError{
+		Dialect:    "sqlite",
+		Code:       sqliteStates[code],
+		VendorCode: code,
+		Severity:   "ERROR",
+		Message:    msg,
+		Query:      query,
+		Position:   -1,
+		Err:        err,
+	}
+	if qe.Code == "" {
+		qe.Code = sqlitePrimaryStates[code&0xff]
+	}
+	for _, m := range sqliteMessages {
+		if qe.Code == "" && strings.HasPrefix(msg, m.prefix) {
+			qe.Code = m.code
+			switch m.code {
+			case "42703", "42702":
+				qe.Column = lastPart(msg[len(m.prefix):])
+			case "42P01":
+				qe.Schema, qe.Table = splitQualified(msg[len(m.prefix):])
+			}
+		}
+	}
+
+	if m := sqliteConstraint.FindStringSubmatch(msg); m != nil {
+		qe.Table = m[1]
+		if m[3] == "" {
+			qe.Column = m[2]
+		}
+	}
+	if m := sqliteCheckName.FindStringSubmatch(msg); m != nil {
+		qe.Constraint = m[1]
+	}
+	switch m := sqliteNear.FindStringSubmatch(msg); {
+	case m != nil:
+		qe.Code = "42601"
+		if strings.Count(query, m[1]) == 1 {
+			qe.Position = utf8.RuneCountInString(query[:strings.Index(query, m[1])])
+		}
+	case msg == "incomplete input" && query != "":
+		qe.Position = utf8.RuneCountInString(strings.TrimRight(query, " \t\r\n;"))
+	}
+	return qe
+}
+
+// nearPosition finds where the text MySQL quotes in "near '...' at line N"
+// starts in query. The quoted text is the rest of the query from the error
+// on, cut short when long; it is empty when the query ended too early.
+func nearPosition(query, near string, line int) int {
+	if query == "" {
+		return -1
+	}
+	if near == "" {
+		return utf8.RuneCountInString(strings.TrimRight(query, " \t\r\n;"))
+	}
+
+	start := 0
+	for i := 1; i < line; i++ {
+		next := strings.IndexByte(query[start:], '\n')
+		if next < 0 {
+			return -1
+		}
+		start += next + 1
+	}
+	i := strings.Index(query[start:], near)
+	if i < 0 {
+		return -1
+	}
+	return utf8.RuneCountInString(query[:start+i])
+}
+
+func lastPart(name string) string {
+	return name[strings.LastIndexByte(name, '.')+1:]
+}
+
+func splitQualified(name string) (string, string) {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
+
+// SQLStateName returns the condition name of an SQLSTATE, such as
+// "unique_violation" for 23505, or "" for an unknown code.
+func SQLStateName(code string) string {
+	if code == "" {
+		return ""
+	}
+	return pq.ErrorCode(code).Name()
+}
+
+// Remedies suggests fixes for common errors, keyed by SQLSTATE. It returns
+// nil when there is nothing better to say than the message itself.
+func Remedies(qe *models.QueryError) []string {
+	if qe == nil {
+		return nil
+	}
+	column := quoteName(qe.Column, "the column")
+	table := quoteName(qe.Table, "the table")
+
+	switch qe.Code {
+	case "23505":
+		upsert := "INSERT ... ON CONFLICT (...) DO UPDATE"
+		if qe.Dialect == "mysql" {
+			upsert = "INSERT ... ON DUPLICATE KEY UPDATE"
+		}
+		return []string{
+			"Another row already has this value; update that row instead.",
+			fmt.Sprintf("To insert or update in one statement, use %s.", upsert),
+		}
+	case "23503":
+		return []string{
+			"When inserting or updating, make sure the referenced row exists first.",
+			"When deleting, delete or re-point the rows that reference it first, or declare the foreign key ON DELETE CASCADE or SET NULL.",
+		}
+	case "23502":
+		return []string{fmt.Sprintf("Give %s a value, or give it a DEFAULT.", column)}
+	case "23514":
+		if qe.Constraint != "" {
+			return []string{fmt.Sprintf("Change the values so they satisfy the check constraint %q.", qe.Constraint)}
+		}
+		return []string{"Change the values so they satisfy the check constraint."}
+	case "42703":
+		remedies := []string{fmt.Sprintf("Check the spelling of %s and that it belongs to a table in FROM.", column)}
+		if qe.Dialect == "postgresql" {
+			remedies = append(remedies, `Unquoted names are folded to lower case; quote mixed-case names, as in "createdAt".`)
+		}
+		return remedies
+	case "42P01":
+		switch qe.Dialect {
+		case "postgresql":
+			return []string{
+				fmt.Sprintf("Check the spelling of %s.", table),
+				"Unqualified names are looked up on the search_path; qualify the table with its schema.",
+			}
+		case "mysql":
+			return []string{fmt.Sprintf("Check the spelling of %s, or qualify it with its database.", table)}
+		default:
+			return []string{fmt.Sprintf("Check the spelling of %s.", table)}
+		}
+	case "42702":
+		return []string{fmt.Sprintf("Qualify %s with the table name or alias it should come from.", column)}
+	case "42601":
+		return []string{
+			"Look just before the marked position for a missing comma, parenthesis or keyword.",
+			"Quote identifiers that are reserved words.",
+		}
+	case "42883":
+		return []string{"Check the function name, and cast the arguments if their types do not match, as in CAST(x AS text)."}
+	case "42804", "22P02":
+		return []string{"Cast the value to the column's type, or fix the literal."}
+	case "22012":
+		return []string{"Guard the divisor, as in x / NULLIF(y, 0)."}
+	case "40P01", "40001":
+		return []string{
+			"The transaction was rolled back so another could finish; run it again.",
+			"Touching rows in the same order in every transaction makes this less likely.",
+		}
+	case "55P03":
+		return []string{"Another session holds a lock on the data; try again once it finishes."}
+	case "57014":
+		return []string{"The statement was cancelled or timed out; narrow it with WHERE or LIMIT, or add an index."}
+	case "25006":
+		return []string{"The connection or transaction is read-only; use a connection that allows writes."}
+	case "25P02":
+		return []string{"An earlier statement in the transaction failed; ROLLBACK and start again."}
+	case "42501":
+		return []string{"Ask for the privilege on the object, or connect as a role that has it."}
+	default:
+		return nil
+	}
+}
+
+// quoteName quotes name, or returns fallback when it is empty.
+func quoteName(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return strconv.Quote(name)
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func TestAsQueryErrorPostgres(t *testing.T) {
+	wrapped := fmt.Errorf("failed to execute query: %w", &pq.Error{
+		Code:       "23505",
+		Message:    "duplicate key value violates unique constraint \"users_email_key\"",
+		Detail:     "Key (email)=(a@example.com) already exists.",
+		Table:      "users",
+		Constraint: "users_email_key",
+	})
+	qe, ok := AsQueryError(wrapped)
+	if !ok {
+		t.Fatal("expected a query error")
+	}
+	if qe.Code != "23505" || qe.Table != "users" || qe.Constraint != "users_email_key" || qe.Detail == "" || qe.Position != -1 {
+		t.Fatalf("query error = %+v", qe)
+	}
+	if SQLStateName(qe.Code) != "unique_violation" {
+		t.Fatalf("SQLStateName = %q", SQLStateName(qe.Code))
+	}
+
+	qe, _ = AsQueryError(NewQueryError(&pq.Error{Code: "42601", Message: "syntax error", Position: "8"}, "SELECT FROMM users"))
+	if qe.Position != 7 || qe.Query != "SELECT FROMM users" {
+		t.Fatalf("position = %d, query = %q, want 7", qe.Position, qe.Query)
+	}
+
+	if _, ok := AsQueryError(errors.New("near \"FROM\": syntax error")); ok {
+		t.Fatal("a plain error is not a query error")
+	}
+}
+
+func TestQueryErrorKeepsMessage(t *testing.T) {
+	pqErr := &pq.Error{Code: "42P01", Message: "relation \"userz\" does not exist"}
+	err := queryFailed(pqErr, "SELECT * FROM userz")
+	if !errors.Is(err, ErrQueryFailed) {
+		t.Fatal("expected ErrQueryFailed in the chain")
+	}
+	if want := "query execution failed: " + pqErr.Error(); err.Error() != want {
+		t.Fatalf("Error() = %q, want %q", err.Error(), want)
+	}
+	if qe, _ := AsQueryError(err); qe.Query != "SELECT * FROM userz" {
+		t.Fatalf("query = %q", qe.Query)
+	}
+}
+
+func TestAsQueryErrorMySQL(t *testing.T) {
+	state := [5]byte{'2', '3', '0', '0', '0'}
+	tests := []struct {
+		err   *mysql.MySQLError
+		query string
+		want  models.QueryError
+	}{
+		{
+			&mysql.MySQLError{Number: 1062, SQLState: state, Message: "Duplicate entry 'a' for key 'users.email'"},
+			"",
+			models.QueryError{Code: "23505", Table: "users", Constraint: "email", Position: -1},
+		},
+		{
+			&mysql.MySQLError{Number: 1452, SQLState: state, Message: "Cannot add or update a child row: a foreign key constraint fails (`app`.`orders`, CONSTRAINT `orders_user_fk` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"},
+			"",
+			models.QueryError{Code: "23503", Schema: "app", Table: "orders", Constraint: "orders_user_fk", Position: -1},
+		},
+		{
+			&mysql.MySQLError{Number: 1054, Message: "Unknown column 'u.emial' in 'field list'"},
+			"",
+			models.QueryError{Code: "42703", Column: "emial", Position: -1},
+		},
+		{
+			&mysql.MySQLError{Number: 1146, Message: "Table 'app.userz' doesn't exist"},
+			"",
+			models.QueryError{Code: "42P01", Schema: "app", Table: "userz", Position: -1},
+		},
+		{
+			&mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near 'FROMM users' at line 2"},
+			"SELECT id\nFROMM users",
+			models.QueryError{Code: "42601", Position: 10},
+		},
+		{
+			&mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use near '' at line 1"},
+			"SELECT * FROM ",
+			models.QueryError{Code: "42601", Position: 13},
+		},
+		{
+			&mysql.MySQLError{Number: 9999, SQLState: [5]byte{'H', 'Y', '0', '0', '0'}, Message: "something else"},
+			"",
+			models.QueryError{Code: "HY000", Position: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		qe, ok := AsQueryError(NewQueryError(tt.err, tt.query))
+		if !ok {
+			t.Fatalf("%s: expected a query error", tt.err.Message)
+		}
+		got := models.QueryError{Code: qe.Code, Schema: qe.Schema, Table: qe.Table, Column: qe.Column, Constraint: qe.Constraint, Position: qe.Position}
+		if got != tt.want {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.err.Message, got, tt.want)
+		}
+		if qe.Dialect != "mysql" || qe.VendorCode != int(tt.err.Number) || qe.Message != tt.err.Message {
+			t.Errorf("%s: dialect %q, vendor code %d, message %q", tt.err.Message, qe.Dialect, qe.VendorCode, qe.Message)
+		}
+	}
+}
+
+func TestAsQueryErrorSQLite(t *testing.T) {
+	ctx := context.Background()
+	d := NewSQLiteDriver()
+	conn := &models.Connection{Name: "errors", Type: models.SQLiteType, Database: filepath.Join(t.TempDir(), "errors.db")}
+	if err := d.Connect(ctx, conn, nil); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer func() { _ = d.Disconnect(ctx) }()
+
+	err := d.ExecuteTransaction(ctx, []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT UNIQUE NOT NULL)",
+		"INSERT INTO users VALUES (1, 'a')",
+	})
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  models.QueryError
+	}{
+		{"INSERT INTO users VALUES (2, 'a')", models.QueryError{Code: "23505", Message: "UNIQUE constraint failed: users.email", Table: "users", Column: "email", Position: -1}},
+		{"INSERT INTO users (id) VALUES (3)", models.QueryError{Code: "23502", Message: "NOT NULL constraint failed: users.email", Table: "users", Column: "email", Position: -1}},
+		{"SELECT emial FROM users", models.QueryError{Code: "42703", Message: "no such column: emial", Column: "emial", Position: -1}},
+		{"SELECT * FROM userz", models.QueryError{Code: "42P01", Message: "no such table: userz", Table: "userz", Position: -1}},
+		{"SELECT id,\nFROM users", models.QueryError{Code: "42601", Message: `near "FROM": syntax error`, Position: 11}},
+		{"SELECT * FROM users WHERE", models.QueryError{Code: "42601", Message: "incomplete input", Position: 25}},
+	}
+
+	for _, tt := range tests {
+		_, err := d.ExecuteQuery(ctx, tt.query)
+		qe, ok := AsQueryError(err)
+		if !ok {
+			t.Fatalf("%s: expected a query error, got %v", tt.query, err)
+		}
+		got := models.QueryError{Code: qe.Code, Message: qe.Message, Table: qe.Table, Column: qe.Column, Position: qe.Position}
+		if got != tt.want {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.query, got, tt.want)
+		}
+		if qe.Query != tt.query {
+			t.Errorf("%s: query = %q", tt.query, qe.Query)
+		}
+	}
+}
+
+func TestRemedies(t *testing.T) {
+	if got := Remedies(&models.QueryError{Code: "23505", Dialect: "mysql"}); len(got) != 2 || got[1] != "To insert or update in one statement, use INSERT ... ON DUPLICATE KEY UPDATE." {
+		t.Errorf("mysql unique violation remedies = %q", got)
+	}
+	if got := Remedies(&models.QueryError{Code: "42703", Dialect: "postgresql", Column: "emial"}); len(got) != 2 || got[0] != `Check the spelling of "emial" and that it belongs to a table in FROM.` {
+		t.Errorf("postgres undefined column remedies = %q", got)
+	}
+	if got := Remedies(&models.QueryError{Code: "23502"}); len(got) != 1 || got[0] != "Give the column a value, or give it a DEFAULT." {
+		t.Errorf("not null remedies without a column = %q", got)
+	}
+	if got := Remedies(&models.QueryError{Code: "XX000"}); got != nil {
+		t.Errorf("unknown code remedies = %q", got)
+	}
+}
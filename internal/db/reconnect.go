@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// poolMaxIdleConns restores database/sql's default idle pool size after
+// Reconnect emptied it.
+const poolMaxIdleConns = 2
+
+// Reconnect drops every idle pooled connection, which may be dead after a
+// network failure or server restart, and pings so a fresh one is opened.
+// Connections in use are discarded by the pool when they fail.
+func (bd *BaseDriver) Reconnect(ctx context.Context) error {
+	if !bd.IsConnected() || bd.db == nil {
+		return ErrNotConnected
+	}
+	bd.db.SetMaxIdleConns(0)
+	bd.db.SetMaxIdleConns(poolMaxIdleConns)
+	return bd.db.PingContext(ctx)
+}
+
+// IsConnectionError reports whether err means the session to the server is
+// gone, as opposed to the statement itself failing.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 is connection exception; 57P01-57P03 are the server
+		// shutting down or not yet accepting connections.
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03":
+			return true
+		}
+		return pqErr.Code.Class() == "08"
+	}
+
+	var netErr *net.OpError
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/lib/pq"
+)
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{driver.ErrBadConn, true},
+		{fmt.Errorf("query failed: %w", io.ErrUnexpectedEOF), true},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, true},
+		{&pq.Error{Code: "57P01"}, true},
+		{&pq.Error{Code: "08006"}, true},
+		{&pq.Error{Code: "42P01"}, false},
+		{errors.New("syntax error"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsConnectionError(tt.err); got != tt.want {
+			t.Errorf("IsConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	d := NewSQLiteDriver()
+	if err := d.Reconnect(context.Background()); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Reconnect before Connect = %v", err)
+	}
+
+	conn := &models.Connection{Name: "rw", Type: models.SQLiteType, Database: filepath.Join(t.TempDir(), "t.db")}
+	if err := d.Connect(context.Background(), conn, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = d.Disconnect(context.Background()) }()
+
+	if err := d.Reconnect(context.Background()); err != nil {
+		t.Fatalf("Reconnect: %v", err)
+	}
+	if _, err := d.ExecuteQuery(context.Background(), "SELECT 1"); err != nil {
+		t.Errorf("query after Reconnect: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+// readOnlySessionSQL returns the statement that makes a session read-only for
+// the given database type.
+func readOnlySessionSQL(t models.ConnectionType) string {
+	switch t {
+	case models.PostgresType:
+		return "SET default_transaction_read_only = on"
+	case models.MySQLType:
+		return "SET SESSION TRANSACTION READ ONLY"
+	case models.SQLiteType:
+		return "PRAGMA query_only = ON"
+	default:
+		return ""
+	}
+}
+
+// sessionConnector wraps a driver connector and runs setup statements on every
+// new pooled connection, so session settings survive reconnects and apply to
+// all connections in the pool.
+type sessionConnector struct {
+	base       driver.Connector
+	statements []string
+}
+
+func (c *sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range c.statements {
+		if err := execOnConn(ctx, conn, stmt); err != nil {
+			if closeErr := conn.Close(); closeErr != nil {
+				logging.Debug().Err(closeErr).Msg("failed to close connection after session setup failure")
+			}
+			return nil, fmt.Errorf("session setup %q failed: %w", stmt, err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *sessionConnector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+// dsnConnector adapts drivers that do not implement driver.DriverContext.
+type dsnConnector struct {
+	dsn string
+	drv driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.drv.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+// openWithSession opens a pool whose connections all run statements first.
+func openWithSession(driverName, dsn string, statements []string) (*sql.DB, error) {
+	drv, err := lookupDriver(driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	var base driver.Connector = dsnConnector{dsn: dsn, drv: drv}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		base, err = dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sql.OpenDB(&sessionConnector{base: base, statements: statements}), nil
+}
+
+// lookupDriver returns the driver registered as driverName.
+func lookupDriver(driverName string) (driver.Driver, error) {
+	probe, err := sql.Open(driverName, "")
+	if err != nil {
+		return nil, err
+	}
+	drv := probe.Driver()
+	if err := probe.Close(); err != nil {
+		logging.Debug().Err(err).Msg("failed to close probe db")
+	}
+	return drv, nil
+}
+
+func execOnConn(ctx context.Context, conn driver.Conn, query string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, query, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := stmt.Close(); closeErr != nil {
+			logging.Debug().Err(closeErr).Msg("failed to close session setup statement")
+		}
+	}()
+
+	sc, ok := stmt.(driver.StmtExecContext)
+	if !ok {
+		return ErrUnsupportedOperation
+	}
+	_, err = sc.ExecContext(ctx, nil)
+	return err
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+func newSQLiteFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	d := NewSQLiteDriver()
+	if err := d.Connect(context.Background(), &models.Connection{Name: "rw", Type: models.SQLiteType, Database: path}, nil); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer func() { _ = d.Disconnect(context.Background()) }()
+
+	if _, err := d.ExecuteNonQuery(context.Background(), "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	if _, err := d.ExecuteNonQuery(context.Background(), "INSERT INTO items (name) VALUES ('a'), ('b'), ('c')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	return path
+}
+
+func TestReadOnlySession(t *testing.T) {
+	path := newSQLiteFile(t)
+
+	d := NewSQLiteDriver()
+	conn := &models.Connection{Name: "ro", Type: models.SQLiteType, Database: path, ReadOnly: true}
+	if err := d.Connect(context.Background(), conn, nil); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer func() { _ = d.Disconnect(context.Background()) }()
+
+	// Hold several connections open so the setup statement must run on each.
+	d.BaseDb().SetMaxIdleConns(4)
+	for i := 0; i < 4; i++ {
+		result, err := d.ExecuteQuery(context.Background(), "SELECT count(*) FROM items")
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if len(result.Rows) != 1 {
+			t.Fatalf("expected one row, got %d", len(result.Rows))
+		}
+		if _, err := d.ExecuteNonQuery(context.Background(), "DELETE FROM items"); err == nil {
+			t.Fatal("expected write to fail on a read-only session")
+		}
+	}
+}
+
+func TestExecuteTransactionWithLimit(t *testing.T) {
+	path := newSQLiteFile(t)
+
+	d := NewSQLiteDriver()
+	if err := d.Connect(context.Background(), &models.Connection{Name: "rw", Type: models.SQLiteType, Database: path}, nil); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer func() { _ = d.Disconnect(context.Background()) }()
+
+	affected, err := d.ExecuteTransactionWithLimit(context.Background(), []string{"DELETE FROM items"}, 2)
+	if !errors.Is(err, ErrRowLimitExceeded) {
+		t.Fatalf("expected ErrRowLimitExceeded, got %v", err)
+	}
+	if affected != 3 {
+		t.Errorf("affected = %d, want 3", affected)
+	}
+
+	result, err := d.ExecuteQuery(context.Background(), "SELECT name FROM items")
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if len(result.Rows) != 3 {
+		t.Errorf("expected rollback to keep 3 rows, got %d", len(result.Rows))
+	}
+
+	affected, err = d.ExecuteTransactionWithLimit(context.Background(), []string{"DELETE FROM items WHERE name = 'a'"}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if affected != 1 {
+		t.Errorf("affected = %d, want 1", affected)
+	}
+}
+
+func TestExecuteReadOnlyQueryRollsBack(t *testing.T) {
+	path := newSQLiteFile(t)
+
+	d := NewSQLiteDriver()
+	if err := d.Connect(context.Background(), &models.Connection{Name: "rw", Type: models.SQLiteType, Database: path}, nil); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer func() { _ = d.Disconnect(context.Background()) }()
+
+	result, err := d.ExecuteReadOnlyQuery(context.Background(), "DELETE FROM items RETURNING id")
+	if err != nil {
+		t.Fatalf("read-only query: %v", err)
+	}
+	if len(result.Rows) != 3 {
+		t.Errorf("expected 3 returned rows, got %d", len(result.Rows))
+	}
+
+	result, err = d.ExecuteQuery(context.Background(), "SELECT name FROM items")
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if len(result.Rows) != 3 {
+		t.Errorf("expected the rollback to keep 3 rows, got %d", len(result.Rows))
+	}
+}
+
+func TestReadOnlySessionSQL(t *testing.T) {
+	for _, typ := range []models.ConnectionType{models.PostgresType, models.MySQLType, models.SQLiteType} {
+		if readOnlySessionSQL(typ) == "" {
+			t.Errorf("no read-only statement for %s", typ)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/android-lewis/dbsmith/internal/explain"
 	"github.com/android-lewis/dbsmith/internal/logging"
 	"github.com/android-lewis/dbsmith/internal/models"
 	"github.com/android-lewis/dbsmith/internal/secrets"
@@ -37,11 +38,15 @@ func (d *SQLiteDriver) Connect(ctx context.Context, conn *models.Connection, sec
 		return err
 	}
 
-	if conn.Database == "" {
+	resolved, err := ResolveConnection(conn, secretsMgr)
+	if err != nil {
+		return err
+	}
+	if resolved.Database == "" {
 		return ErrInvalidConnection
 	}
 
-	return d.ConnectWithDSN(ctx, "sqlite", conn.Database, conn)
+	return d.ConnectWithDSN(ctx, "sqlite", resolved.Database, conn)
 }
 
 func (d *SQLiteDriver) GetSchemas(ctx context.Context) ([]models.Schema, error) {
@@ -58,18 +63,18 @@ func (d *SQLiteDriver) GetTables(ctx context.Context, schema models.Schema) ([]m
 		return nil, ErrNotConnected
 	}
 
-	query := "SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name"
+	query := "SELECT name, type FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name"
 
 	rows, err := d.BaseDb().QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
 	}
 	defer closeRows(rows)
 
 	var tables []models.Table
 	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
+		var tableName, tableType string
+		if err := rows.Scan(&tableName, &tableType); err != nil {
 			return nil, err
 		}
 
@@ -78,6 +83,9 @@ func (d *SQLiteDriver) GetTables(ctx context.Context, schema models.Schema) ([]m
 			Schema: schema.Name,
 			Type:   "BASE TABLE",
 		}
+		if tableType == "view" {
+			table.Type = "VIEW"
+		}
 		tables = append(tables, table)
 	}
 
@@ -131,7 +139,7 @@ func (d *SQLiteDriver) GetTableColumns(ctx context.Context, schemaName, tableNam
 		}
 
 		col.Nullable = notnull == 0
-		col.IsPrimaryKey = pk == 1
+		col.IsPrimaryKey = pk > 0
 		col.IsForeignKey = fkColumns[col.Name]
 
 		if dfltValue != nil {
@@ -160,6 +168,107 @@ func (d *SQLiteDriver) GetTableColumns(ctx context.Context, schemaName, tableNam
 	}, nil
 }
 
+// GetFunctions returns nothing: SQLite functions are registered by the
+// application, not stored in the database.
+func (d *SQLiteDriver) GetFunctions(ctx context.Context) ([]models.Function, error) {
+	if !d.IsConnected() || d.BaseDb() == nil {
+		return nil, ErrNotConnected
+	}
+	return nil, nil
+}
+
+// GetSearchPath returns nothing: SQLite tables are not listed by schema.
+func (d *SQLiteDriver) GetSearchPath(ctx context.Context) ([]string, error) {
+	if !d.IsConnected() || d.BaseDb() == nil {
+		return nil, ErrNotConnected
+	}
+	return nil, nil
+}
+
+// GetForeignKeys reads the foreign keys of every table. SQLite keys are
+// unnamed; a key without target columns references the primary key.
+func (d *SQLiteDriver) GetForeignKeys(ctx context.Context) ([]models.ForeignKey, error) {
+	if !d.IsConnected() || d.BaseDb() == nil {
+		return nil, ErrNotConnected
+	}
+
+	tables, err := d.GetTables(ctx, models.Schema{})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []models.ForeignKey
+	for _, table := range tables {
+		tableKeys, err := d.tableForeignKeys(ctx, table.Name)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, tableKeys...)
+	}
+	return keys, nil
+}
+
+func (d *SQLiteDriver) tableForeignKeys(ctx context.Context, table string) ([]models.ForeignKey, error) {
+	query := `SELECT id, "table", "from", COALESCE("to", '') FROM pragma_foreign_key_list(?) ORDER BY id, seq`
+	rows, err := d.BaseDb().QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
+	}
+	defer closeRows(rows)
+
+	var keys []models.ForeignKey
+	lastID := -1
+	for rows.Next() {
+		var id int
+		var refTable, from, to string
+		if err := rows.Scan(&id, &refTable, &from, &to); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign key row: %w", err)
+		}
+		if id != lastID {
+			keys = append(keys, models.ForeignKey{Table: table, RefTable: refTable})
+			lastID = id
+		}
+		fk := &keys[len(keys)-1]
+		fk.Columns = append(fk.Columns, from)
+		if to != "" {
+			fk.RefColumns = append(fk.RefColumns, to)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range keys {
+		if len(keys[i].RefColumns) > 0 {
+			continue
+		}
+		pk, err := d.primaryKeyColumns(ctx, keys[i].RefTable)
+		if err != nil {
+			return nil, err
+		}
+		keys[i].RefColumns = pk
+	}
+	return keys, nil
+}
+
+func (d *SQLiteDriver) primaryKeyColumns(ctx context.Context, table string) ([]string, error) {
+	rows, err := d.BaseDb().QueryContext(ctx, "SELECT name FROM pragma_table_info(?) WHERE pk > 0 ORDER BY pk", table)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
+	}
+	defer closeRows(rows)
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan primary key row: %w", err)
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
 func (d *SQLiteDriver) GetTableIndexes(ctx context.Context, table string) ([]models.Index, error) {
 	if !d.IsConnected() || d.BaseDb() == nil {
 		return nil, ErrNotConnected
@@ -174,7 +283,7 @@ func (d *SQLiteDriver) GetTableIndexes(ctx context.Context, table string) ([]mod
 	indexListQuery := fmt.Sprintf("PRAGMA index_list(%s)", table)
 	rows, err := d.BaseDb().QueryContext(ctx, indexListQuery)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+		return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
 	}
 	defer closeRows(rows)
 
@@ -203,7 +312,7 @@ func (d *SQLiteDriver) GetTableIndexes(ctx context.Context, table string) ([]mod
 		indexInfoQuery := fmt.Sprintf("PRAGMA index_info(%s)", name)
 		colRows, err := d.BaseDb().QueryContext(ctx, indexInfoQuery)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrQueryFailed, err)
+			return nil, fmt.Errorf("%w: %w", ErrQueryFailed, err)
 		}
 
 		columns, err := scanIndexColumns(colRows)
@@ -341,11 +450,32 @@ func (d *SQLiteDriver) GetServerInfo(ctx context.Context) (*models.ServerInfo, e
 	return info, nil
 }
 
-func (d *SQLiteDriver) GetQueryExecutionPlan(ctx context.Context, sql string) (*models.QueryResult, error) {
+// GetQueryExecutionPlan returns the parsed EXPLAIN QUERY PLAN tree. SQLite
+// has no equivalent of EXPLAIN ANALYZE.
+func (d *SQLiteDriver) GetQueryExecutionPlan(ctx context.Context, sql string, analyze bool) (*models.QueryPlan, error) {
 	if !d.IsConnected() || d.BaseDb() == nil {
 		return nil, ErrNotConnected
 	}
 
-	explainSQL := "EXPLAIN QUERY PLAN " + sql
-	return d.ExecuteQuery(ctx, explainSQL)
+	if analyze {
+		return nil, fmt.Errorf("%w: SQLite does not support EXPLAIN ANALYZE", ErrUnsupportedOperation)
+	}
+
+	result, err := d.ExecuteQuery(ctx, "EXPLAIN QUERY PLAN "+sql)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]explain.SQLiteRow, 0, len(result.Rows))
+	for _, r := range result.Rows {
+		if len(r) < 4 {
+			continue
+		}
+		rows = append(rows, explain.SQLiteRow{
+			ID:     toInt64(r[0]),
+			Parent: toInt64(r[1]),
+			Detail: fmt.Sprintf("%s", r[3]),
+		})
+	}
+	return explain.ParseSQLite(rows)
 }
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+func TestSQLiteGetForeignKeys(t *testing.T) {
+	ctx := context.Background()
+	d := NewSQLiteDriver()
+	conn := &models.Connection{Name: "fk", Type: models.SQLiteType, Database: filepath.Join(t.TempDir(), "fk.db")}
+	if err := d.Connect(ctx, conn, nil); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer func() { _ = d.Disconnect(ctx) }()
+
+	err := d.ExecuteTransaction(ctx, []string{
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE products (sku TEXT, region TEXT, PRIMARY KEY (sku, region))",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers, sku TEXT, region TEXT, " +
+			"FOREIGN KEY (sku, region) REFERENCES products (sku, region))",
+	})
+	if err != nil {
+		t.Fatalf("create tables: %v", err)
+	}
+
+	keys, err := d.GetForeignKeys(ctx)
+	if err != nil {
+		t.Fatalf("GetForeignKeys: %v", err)
+	}
+
+	byTarget := map[string]models.ForeignKey{}
+	for _, fk := range keys {
+		if fk.Table != "orders" {
+			t.Fatalf("unexpected key on %s", fk.Table)
+		}
+		byTarget[fk.RefTable] = fk
+	}
+	if got := byTarget["customers"]; !reflect.DeepEqual(got.Columns, []string{"customer_id"}) || !reflect.DeepEqual(got.RefColumns, []string{"id"}) {
+		t.Errorf("customers key = %+v", got)
+	}
+	if got := byTarget["products"]; !reflect.DeepEqual(got.Columns, []string{"sku", "region"}) || !reflect.DeepEqual(got.RefColumns, []string{"sku", "region"}) {
+		t.Errorf("products key = %+v", got)
+	}
+}
+
+func TestSQLiteGetTablesIncludesViews(t *testing.T) {
+	ctx := context.Background()
+	d := NewSQLiteDriver()
+	conn := &models.Connection{Name: "views", Type: models.SQLiteType, Database: filepath.Join(t.TempDir(), "views.db")}
+	if err := d.Connect(ctx, conn, nil); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer func() { _ = d.Disconnect(ctx) }()
+
+	err := d.ExecuteTransaction(ctx, []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)",
+		"CREATE VIEW active_users AS SELECT id FROM users",
+	})
+	if err != nil {
+		t.Fatalf("create objects: %v", err)
+	}
+
+	tables, err := d.GetTables(ctx, models.Schema{})
+	if err != nil {
+		t.Fatalf("GetTables: %v", err)
+	}
+	got := map[string]string{}
+	for _, table := range tables {
+		got[table.Name] = table.Type
+	}
+	want := map[string]string{"active_users": "VIEW", "users": "BASE TABLE"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tables = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,851 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/sqlparse"
+)
+
+// Severity ranks a diagnostic.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+// Diagnostic is a problem found in a SQL buffer. Start and End are byte
+// offsets of the text it applies to.
+type Diagnostic struct {
+	Start    int
+	End      int
+	Severity Severity
+	Message  string
+}
+
+// Catalog is the schema metadata names are checked against. Unqualified
+// table names resolve in the SearchPath schemas, or in every schema when it
+// is empty. Qualified names are only checked in the listed Schemas.
+type Catalog struct {
+	Schemas    []string
+	SearchPath []string
+	Tables     []CatalogTable
+}
+
+// CatalogTable is a table or view. Columns is nil when they are not known,
+// in which case no column is reported as unknown for it. PrimaryKey lists
+// the primary key columns, when known.
+type CatalogTable struct {
+	Schema     string
+	Name       string
+	Columns    []string
+	PrimaryKey []string
+}
+
+// TableName is a table named in SQL, with quotes removed.
+type TableName struct {
+	Schema string
+	Name   string
+}
+
+// Diagnose checks sql for unbalanced parentheses, quotes and comments,
+// comparisons with NULL and selected columns missing from GROUP BY. With a
+// catalog it also reports unknown tables and columns and ambiguous column
+// references. Diagnostics are ordered by position; when the buffer is
+// unbalanced only that is reported.
+func Diagnose(sql, dialect string, catalog *Catalog) []Diagnostic {
+	if diags := checkBalance(sql, dialect); len(diags) > 0 {
+		return diags
+	}
+
+	c := newChecker(dialect, catalog)
+	statements := sqlparse.SplitStatements(sqlparse.Lex(sql, dialect))
+	for _, stmt := range statements {
+		c.noteDDL(stmt)
+	}
+	for _, stmt := range statements {
+		c.diags = append(c.diags, checkNullComparisons(stmt)...)
+		c.checkStatement(stmt)
+	}
+
+	sort.SliceStable(c.diags, func(i, j int) bool { return c.diags[i].Start < c.diags[j].Start })
+	return c.diags
+}
+
+// ReferencedTables returns the tables the queries in sql read or write,
+// leaving out CTEs, derived tables and table functions.
+func ReferencedTables(sql, dialect string) []TableName {
+	var names []TableName
+	seen := map[TableName]bool{}
+	for _, stmt := range sqlparse.SplitStatements(sqlparse.Lex(sql, dialect)) {
+		for _, s := range sqlparse.Parse(stmt) {
+			for _, t := range s.Tables {
+				if t.Body != nil || t.Function || len(t.Parts) == 0 || len(t.Parts) > 2 {
+					continue
+				}
+				if len(t.Parts) == 1 && s.LookupCTE(t.Parts[0]) != nil {
+					continue
+				}
+				name := TableName{Name: t.Parts[len(t.Parts)-1]}
+				if len(t.Parts) == 2 {
+					name.Schema = t.Parts[0]
+				}
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+func isPostgres(dialect string) bool {
+	switch strings.ToLower(dialect) {
+	case "postgres", "postgresql":
+		return true
+	}
+	return false
+}
+
+func isMySQL(dialect string) bool {
+	switch strings.ToLower(dialect) {
+	case "mysql", "mariadb":
+		return true
+	}
+	return false
+}
+
+func isSQLite(dialect string) bool {
+	switch strings.ToLower(dialect) {
+	case "sqlite", "sqlite3":
+		return true
+	}
+	return false
+}
+
+// checkBalance scans sql for unterminated strings, quoted identifiers and
+// comments, and for parentheses that are not matched. An unterminated quote
+// or comment hides everything after it, so it is reported on its own.
+func checkBalance(sql, dialect string) []Diagnostic {
+	mysql, postgres := isMySQL(dialect), isPostgres(dialect)
+	unterminated := func(start int, what string) []Diagnostic {
+		end := strings.IndexByte(sql[start:], '\n')
+		if end < 0 {
+			end = len(sql)
+		} else {
+			end += start
+		}
+		return []Diagnostic{{Start: start, End: end, Severity: SeverityError, Message: "unterminated " + what}}
+	}
+
+	var diags []Diagnostic
+	var open []int
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"), c == '#' && mysql:
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return diags
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return unterminated(i, "comment")
+			}
+			i += end + 3
+		case c == '\'':
+			escapes := mysql || postgres && i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i < 2 || !isWordByte(sql[i-2]))
+			end, ok := scanQuoted(sql, i, c, escapes)
+			if !ok {
+				return unterminated(i, "string")
+			}
+			i = end
+		case c == '"':
+			end, ok := scanQuoted(sql, i, c, mysql)
+			if !ok {
+				if mysql {
+					return unterminated(i, "string")
+				}
+				return unterminated(i, "quoted identifier")
+			}
+			i = end
+		case c == '`' && !postgres:
+			end, ok := scanQuoted(sql, i, c, false)
+			if !ok {
+				return unterminated(i, "quoted identifier")
+			}
+			i = end
+		case c == '$' && postgres && (i == 0 || !isWordByte(sql[i-1])):
+			tag := dollarTag(sql[i:])
+			if tag == "" {
+				continue
+			}
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				return unterminated(i, "string")
+			}
+			i += len(tag) + end + len(tag) - 1
+		case c == '(':
+			open = append(open, i)
+		case c == ')':
+			if len(open) == 0 {
+				diags = append(diags, Diagnostic{Start: i, End: i + 1, Severity: SeverityError, Message: "unmatched closing parenthesis"})
+				continue
+			}
+			open = open[:len(open)-1]
+		}
+	}
+
+	for _, i := range open {
+		diags = append(diags, Diagnostic{Start: i, End: i + 1, Severity: SeverityError, Message: "unclosed parenthesis"})
+	}
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Start < diags[j].Start })
+	return diags
+}
+
+// scanQuoted returns the index of the quote closing the one at sql[start].
+// A doubled quote is part of the text, as is any byte after a backslash
+// when escapes is set.
+func scanQuoted(sql string, start int, quote byte, escapes bool) (int, bool) {
+	for i := start + 1; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// dollarTag returns the $tag$ that opens a Postgres dollar-quoted string at
+// the start of s, or "" when there is none.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			return s[:i+1]
+		case c >= '0' && c <= '9':
+			if i == 1 {
+				return ""
+			}
+		case !isWordByte(c):
+			return ""
+		}
+	}
+	return ""
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+var nullComparisons = map[string]string{"=": "IS NULL", "==": "IS NULL", "<>": "IS NOT NULL", "!=": "IS NOT NULL"}
+
+// checkNullComparisons reports = NULL and <> NULL in conditions, which are
+// never true. Assignments such as SET x = NULL are left alone.
+func checkNullComparisons(stmt []sqlparse.Lexeme) []Diagnostic {
+	var diags []Diagnostic
+	conditions := []bool{false}
+	inWhen := false
+	for i, l := range stmt {
+		top := len(conditions) - 1
+		switch l.Kind {
+		case sqlparse.LParen:
+			conditions = append(conditions, conditions[top])
+		case sqlparse.RParen:
+			if top > 0 {
+				conditions = conditions[:top]
+			}
+		case sqlparse.Word:
+			switch l.Upper() {
+			case "WHERE", "ON", "HAVING", "SELECT", "RETURNING":
+				conditions[top] = true
+			case "SET", "VALUES", "UPDATE", "FROM", "GROUP", "ORDER", "LIMIT", "INTO", "WINDOW":
+				conditions[top] = false
+			case "WHEN":
+				inWhen = true
+			case "THEN":
+				inWhen = false
+			}
+		case sqlparse.Operator:
+			fix, ok := nullComparisons[l.Text]
+			if !ok || !conditions[top] && !inWhen {
+				continue
+			}
+			start, end := -1, -1
+			switch {
+			case i+1 < len(stmt) && stmt[i+1].Is("NULL"):
+				start, end = l.Start, stmt[i+1].End
+			case i > 0 && stmt[i-1].Is("NULL"):
+				start, end = stmt[i-1].Start, l.End
+			}
+			if start >= 0 {
+				diags = append(diags, Diagnostic{
+					Start:    start,
+					End:      end,
+					Severity: SeverityWarning,
+					Message:  "comparison with NULL is never true; use " + fix,
+				})
+			}
+		}
+	}
+	return diags
+}
+
+// checker reports the name and GROUP BY problems of parsed statements.
+type checker struct {
+	dialect string
+	catalog *Catalog
+	diags   []Diagnostic
+
+	schemas map[string]bool
+	tables  map[string]*CatalogTable
+	byName  map[string][]*CatalogTable
+	// changed holds tables the buffer creates or alters. They exist, but
+	// their columns are not known until the statements have run.
+	changed map[string]bool
+
+	tableCols map[*sqlparse.Table]columnSet
+	bodyCols  map[*sqlparse.Body]columnSet
+	visiting  map[*sqlparse.Body]bool
+	// keys holds the primary key of each catalog table referenced.
+	keys map[*sqlparse.Table][]string
+}
+
+func newChecker(dialect string, catalog *Catalog) *checker {
+	c := &checker{
+		dialect:   dialect,
+		catalog:   catalog,
+		changed:   map[string]bool{},
+		tableCols: map[*sqlparse.Table]columnSet{},
+		bodyCols:  map[*sqlparse.Body]columnSet{},
+		visiting:  map[*sqlparse.Body]bool{},
+		keys:      map[*sqlparse.Table][]string{},
+	}
+	if catalog == nil {
+		return c
+	}
+
+	c.schemas = make(map[string]bool, len(catalog.Schemas))
+	for _, schema := range catalog.Schemas {
+		c.schemas[strings.ToUpper(schema)] = true
+	}
+	c.tables = make(map[string]*CatalogTable, len(catalog.Tables))
+	c.byName = make(map[string][]*CatalogTable, len(catalog.Tables))
+	for i := range catalog.Tables {
+		t := &catalog.Tables[i]
+		c.tables[catalogKey(t.Schema, t.Name)] = t
+		c.byName[strings.ToUpper(t.Name)] = append(c.byName[strings.ToUpper(t.Name)], t)
+	}
+	return c
+}
+
+func catalogKey(schema, name string) string {
+	return strings.ToUpper(schema) + "." + strings.ToUpper(name)
+}
+
+// noteDDL remembers the tables a CREATE, ALTER or RENAME in the buffer
+// touches.
+func (c *checker) noteDDL(stmt []sqlparse.Lexeme) {
+	for _, object := range ddlObjects(stmt) {
+		parts := sqlparse.SplitName(object)
+		c.changed[strings.ToUpper(parts[len(parts)-1])] = true
+	}
+}
+
+func (c *checker) add(start, end int, severity Severity, format string, args ...any) {
+	c.diags = append(c.diags, Diagnostic{Start: start, End: end, Severity: severity, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) checkStatement(stmt []sqlparse.Lexeme) {
+	scopes := sqlparse.Parse(stmt)
+	for _, s := range scopes {
+		for _, t := range s.Tables {
+			c.columnsOf(t)
+		}
+	}
+	for _, s := range scopes {
+		if c.catalog != nil {
+			c.checkNames(s)
+		}
+		c.checkGroupBy(s)
+	}
+}
+
+// columnSet is the resolved columns of a table or query.
+type columnSet struct {
+	cols  []string
+	known bool
+}
+
+// columnsOf returns the columns of a table reference and whether they are
+// known. Names that cannot be resolved are reported the first time.
+func (c *checker) columnsOf(t *sqlparse.Table) ([]string, bool) {
+	set, ok := c.tableCols[t]
+	if !ok {
+		set.cols, set.known = c.resolveTable(t)
+		if t.Columns != nil {
+			set = columnSet{cols: t.Columns, known: true}
+		}
+		c.tableCols[t] = set
+	}
+	return set.cols, set.known
+}
+
+func (c *checker) resolveTable(t *sqlparse.Table) ([]string, bool) {
+	if t.Function {
+		return nil, false
+	}
+	if t.Body != nil {
+		return c.outputs(t.Body)
+	}
+	if cte := t.CTE(); cte != nil {
+		if cte.Columns != nil {
+			return cte.Columns, true
+		}
+		return c.outputs(cte.Body)
+	}
+	if c.catalog == nil || len(t.Parts) == 0 || len(t.Parts) > 2 {
+		return nil, false
+	}
+
+	name := t.Parts[len(t.Parts)-1]
+	if c.changed[strings.ToUpper(name)] || c.systemTable(t.Parts) {
+		return nil, false
+	}
+
+	var table *CatalogTable
+	if len(t.Parts) == 2 {
+		if !c.schemas[strings.ToUpper(t.Parts[0])] {
+			return nil, false
+		}
+		table = c.tables[catalogKey(t.Parts[0], name)]
+	} else {
+		table = c.lookupUnqualified(name)
+	}
+	if table == nil {
+		c.add(t.Start, t.End, SeverityError, "unknown table %q", name)
+		return nil, false
+	}
+	c.keys[t] = table.PrimaryKey
+	return table.Columns, table.Columns != nil
+}
+
+func (c *checker) lookupUnqualified(name string) *CatalogTable {
+	if len(c.catalog.SearchPath) == 0 {
+		if tables := c.byName[strings.ToUpper(name)]; len(tables) > 0 {
+			return tables[0]
+		}
+		return nil
+	}
+	for _, schema := range c.catalog.SearchPath {
+		if table := c.tables[catalogKey(schema, name)]; table != nil {
+			return table
+		}
+	}
+	return nil
+}
+
+// systemTable reports whether parts names a catalog table that is not
+// listed with the user's tables.
+func (c *checker) systemTable(parts []string) bool {
+	name := strings.ToLower(parts[len(parts)-1])
+	switch {
+	case isPostgres(c.dialect):
+		return strings.HasPrefix(name, "pg_")
+	case isSQLite(c.dialect):
+		return strings.HasPrefix(name, "sqlite_")
+	case isMySQL(c.dialect):
+		return len(parts) == 1 && name == "dual"
+	}
+	return false
+}
+
+// outputs returns the names of the columns a query produces, taken from
+// its first SELECT. They are unknown when a * passes through a table whose
+// columns are unknown.
+func (c *checker) outputs(b *sqlparse.Body) ([]string, bool) {
+	if set, ok := c.bodyCols[b]; ok {
+		return set.cols, set.known
+	}
+	if c.visiting[b] {
+		return nil, false
+	}
+	c.visiting[b] = true
+	outputs, known := b.Outputs()
+	var cols []string
+	for _, out := range outputs {
+		if out.Star == nil {
+			cols = append(cols, out.Name)
+			continue
+		}
+		tableCols, ok := c.columnsOf(out.Star)
+		known = known && ok
+		cols = append(cols, tableCols...)
+	}
+	delete(c.visiting, b)
+	if !known {
+		cols = nil
+	}
+	c.bodyCols[b] = columnSet{cols: cols, known: known}
+	return cols, known
+}
+
+var nameClauses = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "HAVING": true,
+	"ORDER": true, "SET": true, "RETURNING": true, "QUALIFY": true,
+	"LIMIT": false, "OFFSET": false, "FETCH": false, "FOR": false, "WINDOW": false, "INTO": false,
+}
+
+// checkNames resolves the column references of a scope.
+func (c *checker) checkNames(s *sqlparse.Scope) {
+	checked := false
+	clause := ""
+	depth := 0
+	for i, l := range s.Lexemes {
+		switch l.Kind {
+		case sqlparse.LParen:
+			depth++
+			continue
+		case sqlparse.RParen:
+			depth--
+			continue
+		case sqlparse.Word:
+			if check, ok := nameClauses[l.Upper()]; ok && depth == 0 {
+				clause, checked = l.Upper(), check
+			}
+		}
+		if s.Used[i] || !isReference(s, i, c.dialect) {
+			continue
+		}
+		if s.Assigned[i] && len(sqlparse.SplitName(l.Text)) == 1 {
+			c.checkTargetColumn(s, l, sqlparse.Unquote(l.Text))
+			continue
+		}
+		if !checked || clause == "ORDER" && s.SetOp {
+			continue
+		}
+		c.checkName(s, i, clause)
+	}
+}
+
+// isReference reports whether the lexeme at i can be a column reference
+// rather than a keyword, function name, type, literal or option.
+func isReference(s *sqlparse.Scope, i int, dialect string) bool {
+	lx := s.Lexemes
+	l := lx[i]
+	switch {
+	case sqlparse.IsReserved(l):
+		return false
+	case l.Kind != sqlparse.Word && l.Kind != sqlparse.Ident:
+		return false
+	case isMySQL(dialect) && strings.HasPrefix(l.Text, `"`):
+		// MySQL reads double quotes as a string.
+		return false
+	}
+
+	if i+1 < len(lx) {
+		switch next := lx[i+1]; {
+		case next.Kind == sqlparse.LParen, next.Kind == sqlparse.String:
+			return false
+		case next.Is("FROM") && i > 0 && lx[i-1].Kind == sqlparse.LParen:
+			// EXTRACT(field FROM ...), TRIM(BOTH FROM ...)
+			return false
+		}
+	}
+	if i > 0 {
+		switch prev := lx[i-1]; {
+		case prev.Kind == sqlparse.Operator && (prev.Text == "::" || prev.Text == ":" || prev.Text == "@"):
+			return false
+		case prev.Kind == sqlparse.Number, prev.Is("AS"), prev.Is("OVER"), prev.Is("COLLATE"):
+			return false
+		}
+	}
+	return !castTypeTail(lx, i)
+}
+
+// typeWords continue a type name over several words, as in double
+// precision or interval day to second.
+var typeWords = map[string]bool{
+	"PRECISION": true, "VARYING": true, "WITH": true, "WITHOUT": true, "TIME": true, "ZONE": true,
+	"YEAR": true, "MONTH": true, "DAY": true, "HOUR": true, "MINUTE": true, "SECOND": true, "TO": true,
+}
+
+// castTypeTail reports whether lx[i] is a later word of a type named after
+// :: or CAST(... AS.
+func castTypeTail(lx []sqlparse.Lexeme, i int) bool {
+	j := i
+	for j > 0 && typeWords[lx[j].Upper()] {
+		j--
+	}
+	if j == i || j == 0 {
+		return false
+	}
+	prev := lx[j-1]
+	return prev.Kind == sqlparse.Operator && prev.Text == "::" || prev.Is("AS")
+}
+
+// systemColumns are columns every table has without listing them.
+var systemColumns = map[string]bool{
+	"CTID": true, "XMIN": true, "XMAX": true, "CMIN": true, "CMAX": true, "TABLEOID": true,
+	"OID": true, "ROWID": true, "_ROWID_": true,
+}
+
+func (c *checker) checkName(s *sqlparse.Scope, i int, clause string) {
+	l := s.Lexemes[i]
+	parts := sqlparse.SplitName(l.Text)
+	name := parts[len(parts)-1]
+	if name == "" || name == "*" || systemColumns[strings.ToUpper(name)] {
+		return
+	}
+
+	switch len(parts) {
+	case 1:
+		switch clause {
+		case "ORDER", "GROUP", "HAVING":
+			if s.Aliases[strings.ToUpper(name)] {
+				return
+			}
+		}
+		if s.FindTable(name) != nil {
+			return
+		}
+		c.resolveColumn(s, l, name)
+	case 2:
+		t := s.FindTable(parts[0])
+		if t == nil {
+			if !s.PartialChain() {
+				c.add(l.Start, l.End, SeverityError, "unknown table or alias %q", parts[0])
+			}
+			return
+		}
+		if cols, known := c.columnsOf(t); known && !containsFold(cols, name) {
+			c.add(l.Start, l.End, SeverityError, "unknown column %q in %q", name, parts[0])
+		}
+	case 3:
+		t := s.FindQualifiedTable(parts[0], parts[1])
+		if t == nil {
+			return
+		}
+		if cols, known := c.columnsOf(t); known && !containsFold(cols, name) {
+			c.add(l.Start, l.End, SeverityError, "unknown column %q in %q", name, parts[1])
+		}
+	}
+}
+
+// resolveColumn looks an unqualified column up from the innermost scope
+// outwards. It is ambiguous when several tables of the first scope that
+// has it provide it, and unknown when no scope does and all their columns
+// are known.
+func (c *checker) resolveColumn(s *sqlparse.Scope, l sqlparse.Lexeme, name string) {
+	seen := false
+	for sc := s; sc != nil; sc = sc.Parent {
+		var matches []string
+		complete := !sc.Partial
+		for _, t := range sc.Tables {
+			cols, known := c.columnsOf(t)
+			if !known {
+				complete = false
+				continue
+			}
+			if containsFold(cols, name) {
+				matches = append(matches, t.Label())
+			}
+		}
+		if len(matches) > 1 && !sc.Natural && !sc.Using[strings.ToUpper(name)] {
+			c.add(l.Start, l.End, SeverityError, "ambiguous column %q (%s)", name, strings.Join(matches, ", "))
+			return
+		}
+		if len(matches) > 0 || !complete {
+			return
+		}
+		seen = seen || len(sc.Tables) > 0
+	}
+
+	// Chroma types some common column names as keywords; only report those
+	// that were lexed as names or quoted. SQLite reads an unknown
+	// double-quoted name as a string.
+	reportable := l.Kind == sqlparse.Word && l.Plain || l.Kind == sqlparse.Ident && !(isSQLite(c.dialect) && strings.HasPrefix(l.Text, `"`))
+	if seen && reportable {
+		c.add(l.Start, l.End, SeverityError, "unknown column %q", name)
+	}
+}
+
+// checkTargetColumn checks a column assigned by UPDATE ... SET or listed
+// by INSERT against the table written to.
+func (c *checker) checkTargetColumn(s *sqlparse.Scope, l sqlparse.Lexeme, name string) {
+	if len(s.Tables) == 0 {
+		return
+	}
+	t := s.Tables[0]
+	if cols, known := c.columnsOf(t); known && !containsFold(cols, name) {
+		c.add(l.Start, l.End, SeverityError, "unknown column %q in %q", name, t.Label())
+	}
+}
+
+var aggregateFuncs = map[string]bool{
+	"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true,
+	"ARRAY_AGG": true, "STRING_AGG": true, "GROUP_CONCAT": true, "JSON_AGG": true,
+	"JSONB_AGG": true, "JSON_OBJECT_AGG": true, "JSONB_OBJECT_AGG": true,
+	"JSON_ARRAYAGG": true, "JSON_OBJECTAGG": true, "JSON_GROUP_ARRAY": true,
+	"JSON_GROUP_OBJECT": true, "XMLAGG": true, "BOOL_AND": true, "BOOL_OR": true,
+	"BIT_AND": true, "BIT_OR": true, "BIT_XOR": true, "EVERY": true, "STDDEV": true,
+	"STDDEV_POP": true, "STDDEV_SAMP": true, "VARIANCE": true, "VAR_POP": true,
+	"VAR_SAMP": true, "TOTAL": true, "PERCENTILE_CONT": true, "PERCENTILE_DISC": true,
+	"MODE": true, "ANY_VALUE": true, "CORR": true, "COVAR_POP": true, "COVAR_SAMP": true,
+}
+
+// checkGroupBy warns about selected columns that are neither grouped nor
+// aggregated in a query that groups or aggregates. Columns of a table whose
+// whole primary key is grouped are functionally dependent on it and pass.
+func (c *checker) checkGroupBy(s *sqlparse.Scope) {
+	if s.Verb != "SELECT" {
+		return
+	}
+	group := sqlparse.FindTopLevel(s.Lexemes, 0, "GROUP")
+	aggregate := false
+	for _, item := range s.Items {
+		aggregate = aggregate || hasAggregate(s.Lexemes[item.Start:item.End])
+	}
+	if group < 0 && !aggregate {
+		return
+	}
+
+	grouped := map[string]bool{}
+	ordinals := map[string]bool{}
+	var refs [][]string
+	if group >= 0 {
+		end := sqlparse.FindTopLevel(s.Lexemes, group+1, "HAVING", "WINDOW", "ORDER", "LIMIT", "OFFSET", "FETCH", "FOR", "QUALIFY")
+		if end < 0 {
+			end = len(s.Lexemes)
+		}
+		for j := group + 1; j < end; j++ {
+			switch l := s.Lexemes[j]; l.Kind {
+			case sqlparse.Word, sqlparse.Ident:
+				if l.Is("ALL") {
+					return
+				}
+				parts := sqlparse.SplitName(l.Text)
+				grouped[strings.ToUpper(parts[len(parts)-1])] = true
+				refs = append(refs, parts)
+			case sqlparse.Number:
+				if prev := s.Lexemes[j-1]; prev.Is("BY") || prev.Kind == sqlparse.Comma {
+					ordinals[l.Text] = true
+				}
+			}
+		}
+	}
+
+	for n, item := range s.Items {
+		lx := s.Lexemes[item.Start:item.End]
+		if len(lx) != 1 || !isReference(s, item.Start, c.dialect) {
+			continue
+		}
+		parts := sqlparse.SplitName(lx[0].Text)
+		name := parts[len(parts)-1]
+		if name == "" || name == "*" || grouped[strings.ToUpper(name)] || ordinals[fmt.Sprint(n+1)] {
+			continue
+		}
+		if item.Alias >= 0 && grouped[strings.ToUpper(sqlparse.Unquote(s.Lexemes[item.Alias].Text))] {
+			continue
+		}
+		if t := c.owner(s, parts); t != nil && c.groupsKey(refs, t) {
+			continue
+		}
+		c.add(lx[0].Start, lx[0].End, SeverityWarning, "%q is not in GROUP BY and not aggregated", name)
+	}
+}
+
+// owner returns the table of s that the column reference parts belongs to,
+// or nil when it is unknown or ambiguous.
+func (c *checker) owner(s *sqlparse.Scope, parts []string) *sqlparse.Table {
+	if len(parts) >= 2 {
+		for _, t := range s.Tables {
+			if strings.EqualFold(t.Label(), parts[len(parts)-2]) {
+				return t
+			}
+		}
+		return nil
+	}
+
+	var found *sqlparse.Table
+	for _, t := range s.Tables {
+		cols, known := c.columnsOf(t)
+		if !known {
+			return nil
+		}
+		for _, col := range cols {
+			if strings.EqualFold(col, parts[0]) {
+				if found != nil {
+					return nil
+				}
+				found = t
+				break
+			}
+		}
+	}
+	return found
+}
+
+// groupsKey reports whether the GROUP BY references in refs cover the whole
+// primary key of t.
+func (c *checker) groupsKey(refs [][]string, t *sqlparse.Table) bool {
+	key := c.keys[t]
+	if len(key) == 0 {
+		return false
+	}
+	for _, col := range key {
+		covered := false
+		for _, ref := range refs {
+			n := len(ref)
+			if strings.EqualFold(ref[n-1], col) && (n == 1 || strings.EqualFold(ref[n-2], t.Label())) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// hasAggregate reports whether lx calls an aggregate function that is not
+// used as a window function.
+func hasAggregate(lx []sqlparse.Lexeme) bool {
+	for j, l := range lx {
+		if l.Kind != sqlparse.Word || !aggregateFuncs[l.Upper()] || j+1 >= len(lx) || lx[j+1].Kind != sqlparse.LParen {
+			continue
+		}
+		k := sqlparse.MatchingParen(lx, j+1) + 1
+		if k+1 < len(lx) && lx[k].Is("FILTER") && lx[k+1].Kind == sqlparse.LParen {
+			k = sqlparse.MatchingParen(lx, k+1) + 1
+		}
+		if k < len(lx) && lx[k].Is("OVER") {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
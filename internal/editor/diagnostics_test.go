@@ -0,0 +1,231 @@
+package editor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testCatalog = &Catalog{
+	Schemas:    []string{"public", "audit"},
+	SearchPath: []string{"public"},
+	Tables: []CatalogTable{
+		{Schema: "public", Name: "users", Columns: []string{"id", "email", "status", "created_at"}, PrimaryKey: []string{"id"}},
+		{Schema: "public", Name: "orders", Columns: []string{"id", "user_id", "total", "status"}, PrimaryKey: []string{"id"}},
+		{Schema: "public", Name: "events", Columns: nil},
+		{Schema: "audit", Name: "log", Columns: []string{"id", "message"}},
+	},
+}
+
+// diagnose returns the diagnostics of sql as "text: message" pairs.
+func diagnose(sql, dialect string, catalog *Catalog) []string {
+	var got []string
+	for _, d := range Diagnose(sql, dialect, catalog) {
+		got = append(got, sql[d.Start:d.End]+": "+d.Message)
+	}
+	return got
+}
+
+func TestDiagnoseBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		dialect string
+		want    []string
+	}{
+		{"balanced", "SELECT (1 + (2)) FROM users", "postgresql", nil},
+		{"unclosed", "SELECT count(id FROM users", "postgresql", []string{"(: unclosed parenthesis"}},
+		{"unmatched", "SELECT 1)", "postgresql", []string{"): unmatched closing parenthesis"}},
+		{"paren in string", "SELECT '(' FROM users WHERE email = 'it''s'", "postgresql", nil},
+		{"paren in comment", "SELECT 1 -- (\n/* ) */", "postgresql", nil},
+		{"unterminated string", "SELECT 'abc, (\nFROM users", "postgresql", []string{"'abc, (: unterminated string"}},
+		{"unterminated identifier", `SELECT "abc FROM users`, "postgresql", []string{`"abc FROM users: unterminated quoted identifier`}},
+		{"unterminated comment", "SELECT 1 /* note", "postgresql", []string{"/* note: unterminated comment"}},
+		{"mysql escapes", `SELECT 'it\'s' FROM users`, "mysql", nil},
+		{"postgres standard strings", `SELECT 'C:\' FROM users`, "postgresql", nil},
+		{"postgres escape string", `SELECT E'it\'s' FROM users`, "postgresql", nil},
+		{"dollar quoting", "SELECT $fn$ it's ( $fn$, $1 FROM users", "postgresql", nil},
+		{"unterminated dollar quote", "SELECT $$ it's", "postgresql", []string{"$$ it's: unterminated string"}},
+		{"mysql hash comment", "SELECT 1 # it's (", "mysql", nil},
+		{"backticks", "SELECT `a(` FROM users", "mysql", nil},
+	}
+
+	for _, tt := range tests {
+		if got := diagnose(tt.sql, tt.dialect, nil); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiagnoseNullComparisons(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT * FROM users WHERE status = NULL", []string{"= NULL: comparison with NULL is never true; use IS NULL"}},
+		{"SELECT * FROM users WHERE NULL <> status", []string{"NULL <>: comparison with NULL is never true; use IS NOT NULL"}},
+		{"SELECT * FROM users u JOIN orders o ON o.status != NULL", []string{"!= NULL: comparison with NULL is never true; use IS NOT NULL"}},
+		{"SELECT CASE WHEN status = NULL THEN 1 END FROM users", []string{"= NULL: comparison with NULL is never true; use IS NULL"}},
+		{"SELECT * FROM users WHERE status IS NULL", nil},
+		{"UPDATE users SET status = NULL, email = NULL WHERE id = 1", nil},
+		{"UPDATE users SET status = CASE WHEN id = 1 THEN NULL END, email = NULL", nil},
+		{"INSERT INTO users (id) VALUES (1) ON DUPLICATE KEY UPDATE status = NULL", nil},
+		{"UPDATE users SET status = NULL WHERE email = NULL", []string{"= NULL: comparison with NULL is never true; use IS NULL"}},
+	}
+
+	for _, tt := range tests {
+		if got := diagnose(tt.sql, "postgresql", nil); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestDiagnoseNames(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id, email FROM users WHERE status = 'active' ORDER BY created_at", nil},
+		{"SELECT * FROM userz", []string{`userz: unknown table "userz"`}},
+		{"SELECT * FROM audit.logs", []string{`audit.logs: unknown table "logs"`}},
+		{"SELECT * FROM log", []string{`log: unknown table "log"`}},
+		{"SELECT message FROM audit.log", nil},
+		{"SELECT * FROM other.thing, pg_class, events", nil},
+		{"SELECT emial FROM users", []string{`emial: unknown column "emial"`}},
+		{"SELECT u.emial FROM users u", []string{`u.emial: unknown column "emial" in "u"`}},
+		{"SELECT x.id FROM users u", []string{`x.id: unknown table or alias "x"`}},
+		{"SELECT users.id FROM users u", []string{`users.id: unknown table or alias "users"`}},
+		{"SELECT public.users.emial FROM public.users", []string{`public.users.emial: unknown column "emial" in "users"`}},
+		{"SELECT id FROM users u JOIN orders o ON o.user_id = u.id", []string{`id: ambiguous column "id" (u, o)`}},
+		{"SELECT status FROM users JOIN orders USING (status)", nil},
+		{"SELECT id FROM users NATURAL JOIN orders", nil},
+		{"SELECT total, emial FROM orders, events", nil},
+		{"SELECT email AS e FROM users ORDER BY e", nil},
+		{"SELECT email FROM users WHERE id IN (SELECT user_id FROM orders WHERE total > 10)", nil},
+		{"SELECT email FROM users u WHERE EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.id AND email <> '')", nil},
+		{"SELECT email FROM users WHERE id IN (SELECT user_id FROM orders WHERE totl > 10)", []string{`totl: unknown column "totl"`}},
+		{"SELECT t.n, t.x FROM (SELECT count(*) AS n FROM orders) t", []string{`t.x: unknown column "x" in "t"`}},
+		{"SELECT n FROM (SELECT id AS n FROM orders) AS t(m)", []string{`n: unknown column "n"`}},
+		{"WITH big AS (SELECT user_id, total FROM orders WHERE total > 100) SELECT b.user_id, b.id FROM big b", []string{`b.id: unknown column "id" in "b"`}},
+		{"WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM r WHERE n < 5) SELECT n FROM r", nil},
+		{"SELECT id FROM users UNION SELECT id FROM orders ORDER BY id", nil},
+		{"SELECT lower(email), count(*) FROM users GROUP BY lower(email)", nil},
+		{"SELECT created_at::date, extract(epoch FROM created_at) FROM users", nil},
+		{`SELECT email COLLATE "C" AS e FROM users ORDER BY email COLLATE pg_catalog."default"`, nil},
+		{"SELECT id::double precision, created_at::timestamp with time zone, CAST(id AS character varying) FROM users", nil},
+		{`SELECT emial COLLATE "C" FROM users`, []string{`emial: unknown column "emial"`}},
+		{"UPDATE users SET emial = 'x' WHERE id = 1", []string{`emial: unknown column "emial" in "users"`}},
+		{"UPDATE users u SET status = o.status FROM orders o WHERE o.user_id = u.id", nil},
+		{"DELETE FROM orders WHERE totl = 0", []string{`totl: unknown column "totl"`}},
+		{"DELETE FROM orders o USING users u WHERE o.user_id = u.id AND u.status = 'x'", nil},
+		{"INSERT INTO users (id, emial) VALUES (1, 'a')", []string{`emial: unknown column "emial" in "users"`}},
+		{"INSERT INTO orders (user_id, total) SELECT id, 0 FROM users WHERE status = 'new' ON CONFLICT (id) DO UPDATE SET total = excluded.total", nil},
+		{"SELECT * FROM generate_series(1, 3) g, users", nil},
+		{"SELECT * FROM (users u JOIN orders o ON o.user_id = u.id) WHERE x.y = 1", nil},
+		{"CREATE TABLE tmp (id int); SELECT id FROM tmp", nil},
+		{"CREATE VIEW v AS SELECT emial FROM users", []string{`emial: unknown column "emial"`}},
+		{"EXPLAIN ANALYZE SELECT emial FROM users", []string{`emial: unknown column "emial"`}},
+		{`SELECT "Email" FROM users`, nil},
+		{"SELECT ctid, u.xmin FROM users u", nil},
+		{`SELECT "emial" FROM users`, []string{`"emial": unknown column "emial"`}},
+	}
+
+	for _, tt := range tests {
+		if got := diagnose(tt.sql, "postgresql", testCatalog); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestDiagnoseNamesDialects(t *testing.T) {
+	sqlite := &Catalog{Tables: []CatalogTable{{Name: "users", Columns: []string{"id", "email"}}}}
+	if got := diagnose(`SELECT id, "emial" FROM users, sqlite_master`, "sqlite", sqlite); got != nil {
+		t.Errorf("sqlite double-quoted name: got %q", got)
+	}
+	if got := diagnose("SELECT emial FROM users", "sqlite", sqlite); len(got) != 1 {
+		t.Errorf("sqlite unknown column: got %q", got)
+	}
+
+	mysql := &Catalog{
+		Schemas:    []string{"app"},
+		SearchPath: []string{"app"},
+		Tables:     []CatalogTable{{Schema: "app", Name: "users", Columns: []string{"id", "email"}}},
+	}
+	if got := diagnose(`SELECT id FROM users WHERE email = "emial" AND 1 FROM DUAL`, "mysql", mysql); got != nil {
+		t.Errorf("mysql double-quoted string: got %q", got)
+	}
+	if got := diagnose("SELECT `emial` FROM app.users", "mysql", mysql); len(got) != 1 {
+		t.Errorf("mysql backticks: got %q", got)
+	}
+	if got := diagnose("SELECT email COLLATE utf8mb4_bin, id::interval day to second FROM users", "mysql", mysql); got != nil {
+		t.Errorf("mysql collation and cast type: got %q", got)
+	}
+}
+
+func TestDiagnoseGroupBy(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT status, count(*) FROM users GROUP BY status", nil},
+		{"SELECT status, email, count(*) FROM users GROUP BY status", []string{`email: "email" is not in GROUP BY and not aggregated`}},
+		{"SELECT u.status, count(*) FROM users u GROUP BY status", nil},
+		{"SELECT status, count(*) FROM users GROUP BY 1", nil},
+		{"SELECT status AS s, count(*) FROM users GROUP BY s", nil},
+		{"SELECT status, count(*) FROM users", []string{`status: "status" is not in GROUP BY and not aggregated`}},
+		{"SELECT status, count(*) OVER () FROM users", nil},
+		{"SELECT max(id), min(id) FROM users", nil},
+		{"SELECT status, (SELECT count(*) FROM orders) FROM users", nil},
+	}
+
+	for _, tt := range tests {
+		if got := diagnose(tt.sql, "postgresql", nil); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestDiagnoseGroupByPrimaryKey(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"SELECT id, email FROM users GROUP BY id", nil},
+		{"SELECT u.id, u.email, count(o.id) FROM users u JOIN orders o ON o.user_id = u.id GROUP BY u.id", nil},
+		{"SELECT u.email, o.total FROM users u JOIN orders o ON o.user_id = u.id GROUP BY u.id", []string{`o.total: "total" is not in GROUP BY and not aggregated`}},
+		{"SELECT status, email FROM users GROUP BY status", []string{`email: "email" is not in GROUP BY and not aggregated`}},
+	}
+
+	for _, tt := range tests {
+		if got := diagnose(tt.sql, "postgresql", testCatalog); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tt.sql, got, tt.want)
+		}
+	}
+
+	if got := diagnose("SELECT id, email FROM users GROUP BY id", "postgresql", nil); len(got) != 1 {
+		t.Errorf("without a catalog the key is unknown, got %q", got)
+	}
+}
+
+func TestDiagnoseOrderAndOffsets(t *testing.T) {
+	sql := "SELECT 1;\nSELECT status, count(*) FROM users WHERE id = NULL"
+	diags := Diagnose(sql, "postgresql", nil)
+	if len(diags) != 2 {
+		t.Fatalf("got %d diagnostics, want 2: %v", len(diags), diags)
+	}
+	if diags[0].Start > diags[1].Start {
+		t.Fatal("diagnostics are not ordered by position")
+	}
+	if diags[0].Severity != SeverityWarning || !strings.HasPrefix(sql[diags[0].Start:], "status") {
+		t.Fatalf("first diagnostic = %+v", diags[0])
+	}
+}
+
+func TestReferencedTables(t *testing.T) {
+	sql := `WITH recent AS (SELECT * FROM orders) SELECT * FROM recent r JOIN public."Users" u ON true, generate_series(1, 2);
+		UPDATE audit.log SET id = 1 WHERE id IN (SELECT id FROM orders)`
+	want := []TableName{{Name: "orders"}, {Schema: "public", Name: "Users"}, {Schema: "audit", Name: "log"}}
+	if got := ReferencedTables(sql, "postgresql"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReferencedTables = %v, want %v", got, want)
+	}
+}
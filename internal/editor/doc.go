@@ -0,0 +1,8 @@
+// Package editor analyzes the SQL in the editor buffer: it classifies
+// statements for the safety checks and finds the problems shown as live
+// diagnostics.
+//
+// Statements are lexed and parsed into query scopes by internal/sqlparse,
+// the same scopes completion resolves tables and columns through, so a name
+// the checker reports as unknown is one completion would not offer there.
+package editor
@@ -0,0 +1,780 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/sqlparse"
+)
+
+// StatementClass groups statements by the kind of change they can make.
+type StatementClass string
+
+const (
+	ClassRead        StatementClass = "read"
+	ClassWrite       StatementClass = "write"
+	ClassDDL         StatementClass = "ddl"
+	ClassPrivilege   StatementClass = "privilege"
+	ClassDestructive StatementClass = "destructive"
+)
+
+var classSeverity = map[StatementClass]int{
+	ClassRead:        0,
+	ClassWrite:       1,
+	ClassDDL:         2,
+	ClassPrivilege:   3,
+	ClassDestructive: 4,
+}
+
+// StatementAnalysis is the classification of a single statement.
+type StatementAnalysis struct {
+	SQL     string
+	Kind    string
+	Class   StatementClass
+	Warning string
+
+	// TautologicalWhere is set when the WHERE clause is always true, such as
+	// WHERE 1=1.
+	TautologicalWhere bool
+
+	// DryRunSQL counts the rows an UPDATE or DELETE would touch. It is empty
+	// when no safe count query could be derived.
+	DryRunSQL string
+
+	// Objects names the tables and views a DDL statement creates, alters,
+	// drops, renames or truncates, as written in the SQL.
+	Objects []string
+}
+
+// NeedsConfirmation reports whether the statement should be confirmed before
+// it is executed.
+func (s StatementAnalysis) NeedsConfirmation() bool {
+	return s.Class == ClassDestructive || s.Class == ClassPrivilege
+}
+
+// IsDDL reports whether the statement changes the schema.
+func (s StatementAnalysis) IsDDL() bool {
+	switch s.Kind {
+	case "DROP", "TRUNCATE", "ALTER":
+		return true
+	}
+	return s.Class == ClassDDL
+}
+
+// ModifiesRows reports whether the statement inserts, updates or deletes rows.
+func (s StatementAnalysis) ModifiesRows() bool {
+	switch s.Kind {
+	case "INSERT", "UPDATE", "DELETE", "REPLACE", "MERGE":
+		return true
+	}
+	return false
+}
+
+// CallsProcedure reports whether the statement runs server-side code whose
+// effects cannot be seen from the SQL, such as CALL or DO.
+func (s StatementAnalysis) CallsProcedure() bool {
+	switch s.Kind {
+	case "CALL", "DO", "EXEC", "EXECUTE":
+		return true
+	}
+	return false
+}
+
+// Preview returns the statement on one line with comments removed, cut to max
+// characters.
+func (s StatementAnalysis) Preview(max int) string {
+	preview := cleanSQL(s.SQL)
+	if max > 3 && len(preview) > max {
+		preview = preview[:max-3] + "..."
+	}
+	return preview
+}
+
+// QueryAnalysis is the per-statement classification of a SQL buffer.
+type QueryAnalysis struct {
+	Statements []StatementAnalysis
+}
+
+// NeedsConfirmation reports whether any statement should be confirmed.
+func (a QueryAnalysis) NeedsConfirmation() bool {
+	for _, s := range a.Statements {
+		if s.NeedsConfirmation() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsReadOnly reports whether every statement is a read.
+func (a QueryAnalysis) IsReadOnly() bool {
+	for _, s := range a.Statements {
+		if s.Class != ClassRead {
+			return false
+		}
+	}
+	return true
+}
+
+// HasDDL reports whether any statement changes the schema.
+func (a QueryAnalysis) HasDDL() bool {
+	for _, s := range a.Statements {
+		if s.IsDDL() {
+			return true
+		}
+	}
+	return false
+}
+
+// ModifiesRows reports whether any statement inserts, updates or deletes rows.
+func (a QueryAnalysis) ModifiesRows() bool {
+	for _, s := range a.Statements {
+		if s.ModifiesRows() {
+			return true
+		}
+	}
+	return false
+}
+
+// OnlyModifiesRows reports whether every statement inserts, updates or
+// deletes rows.
+func (a QueryAnalysis) OnlyModifiesRows() bool {
+	for _, s := range a.Statements {
+		if !s.ModifiesRows() {
+			return false
+		}
+	}
+	return len(a.Statements) > 0
+}
+
+// CallsProcedure reports whether any statement runs a procedure or block.
+func (a QueryAnalysis) CallsProcedure() bool {
+	for _, s := range a.Statements {
+		if s.CallsProcedure() {
+			return true
+		}
+	}
+	return false
+}
+
+// Class returns the most severe class across all statements.
+func (a QueryAnalysis) Class() StatementClass {
+	class := ClassRead
+	for _, s := range a.Statements {
+		if classSeverity[s.Class] > classSeverity[class] {
+			class = s.Class
+		}
+	}
+	return class
+}
+
+// AnalyzeStatements splits sql into statements and classifies each one.
+// Comments, string literals and quoted identifiers are tokenized with the
+// dialect's lexer, so keywords inside them are never mistaken for clauses.
+func AnalyzeStatements(sql, dialect string) QueryAnalysis {
+	var analysis QueryAnalysis
+	for _, stmt := range sqlparse.SplitStatements(sqlparse.Lex(sql, dialect)) {
+		s := analyzeStatement(stmt, sql)
+		s.SQL = strings.TrimSpace(sql[stmt[0].Start:stmt[len(stmt)-1].End])
+		s.Objects = ddlObjects(stmt)
+		analysis.Statements = append(analysis.Statements, s)
+	}
+	return analysis
+}
+
+var readVerbs = map[string]bool{
+	"SELECT": true, "VALUES": true, "TABLE": true, "SHOW": true, "DESCRIBE": true,
+	"DESC": true, "EXPLAIN": true,
+	// Transaction control does not change data by itself. SET, BEGIN and
+	// START are classified by analyzeStatement, because they can change the
+	// session's access mode.
+	"USE": true, "BEGIN": true, "START": true, "COMMIT": true,
+	"ROLLBACK": true, "SAVEPOINT": true, "RELEASE": true, "END": true,
+}
+
+var ddlVerbs = map[string]bool{
+	"CREATE": true, "ALTER": true, "RENAME": true, "COMMENT": true,
+}
+
+func analyzeStatement(lx []sqlparse.Lexeme, sql string) StatementAnalysis {
+	i := 0
+	for i < len(lx) && lx[i].Kind == sqlparse.LParen {
+		i++
+	}
+	if i >= len(lx) {
+		return StatementAnalysis{Kind: "UNKNOWN", Class: ClassRead}
+	}
+
+	verb := lx[i].Upper()
+	switch verb {
+	case "WITH":
+		return analyzeWith(lx, i, sql)
+	case "EXPLAIN":
+		return analyzeExplain(lx, i, sql)
+	case "UPDATE", "DELETE":
+		return analyzeModify(lx, i, verb, sql, "")
+	case "INSERT":
+		if i+2 < len(lx) && lx[i+1].Is("OR") && lx[i+2].Is("REPLACE") {
+			return replaceAnalysis("INSERT")
+		}
+		return StatementAnalysis{Kind: verb, Class: ClassWrite}
+	case "REPLACE":
+		return replaceAnalysis(verb)
+	case "DROP":
+		return StatementAnalysis{
+			Kind:    verb,
+			Class:   ClassDestructive,
+			Warning: "This will permanently remove database objects",
+		}
+	case "TRUNCATE":
+		return StatementAnalysis{
+			Kind:    verb,
+			Class:   ClassDestructive,
+			Warning: "This will delete all rows from the table",
+		}
+	case "ALTER":
+		if d := sqlparse.FindTopLevel(lx, i+1, "DROP"); d >= 0 {
+			what := "objects"
+			if d+1 < len(lx) && lx[d+1].Kind == sqlparse.Word && !lx[d+1].Is("IF") {
+				what = strings.ToLower(lx[d+1].Text)
+			}
+			return StatementAnalysis{
+				Kind:    verb,
+				Class:   ClassDestructive,
+				Warning: fmt.Sprintf("ALTER ... DROP permanently removes %s and any data they hold", what),
+			}
+		}
+		return StatementAnalysis{Kind: verb, Class: ClassDDL}
+	case "GRANT":
+		return StatementAnalysis{Kind: verb, Class: ClassPrivilege, Warning: "This changes access privileges"}
+	case "REVOKE":
+		return StatementAnalysis{Kind: verb, Class: ClassPrivilege, Warning: "This removes access privileges"}
+	case "SELECT":
+		if into := selectInto(lx, i); into >= 0 {
+			return analyzeSelectInto(lx, into)
+		}
+	case "SET":
+		return analyzeSet(lx, i)
+	case "BEGIN", "START":
+		if readWriteTransaction(lx[i+1:]) {
+			return sessionAccessChange(verb)
+		}
+	case "PRAGMA":
+		return analyzePragma(lx, i)
+	}
+
+	switch {
+	case readVerbs[verb]:
+		return StatementAnalysis{Kind: verb, Class: ClassRead}
+	case ddlVerbs[verb]:
+		return StatementAnalysis{Kind: verb, Class: ClassDDL}
+	case verb == "":
+		return StatementAnalysis{Kind: "UNKNOWN", Class: ClassWrite}
+	default:
+		// Unknown statements (CALL, COPY, MERGE, VACUUM, ...) may write.
+		return StatementAnalysis{Kind: verb, Class: ClassWrite}
+	}
+}
+
+// selectInto returns the index of the top-level INTO of the SELECT at
+// lx[sel], or -1.
+func selectInto(lx []sqlparse.Lexeme, sel int) int {
+	return sqlparse.FindTopLevel(lx, sel+1, "INTO")
+}
+
+// analyzeSelectInto classifies SELECT ... INTO by its target: a table is
+// created, a server-side file is written, and variables are only assigned.
+func analyzeSelectInto(lx []sqlparse.Lexeme, into int) StatementAnalysis {
+	if into+1 >= len(lx) {
+		return StatementAnalysis{Kind: "SELECT", Class: ClassDDL}
+	}
+	target := lx[into+1]
+	switch {
+	case target.Kind == sqlparse.Operator && target.Text == "@", strings.HasPrefix(target.Text, ":"):
+		return StatementAnalysis{Kind: "SELECT", Class: ClassRead}
+	case target.Is("OUTFILE") || target.Is("DUMPFILE"):
+		return StatementAnalysis{Kind: "SELECT", Class: ClassWrite}
+	}
+	return StatementAnalysis{Kind: "SELECT", Class: ClassDDL}
+}
+
+// accessSettings are the settings and SET forms that change what the
+// session is allowed to do. Any of them can undo the read-only session an
+// environment policy installs.
+var accessSettings = map[string]bool{
+	"ROLE": true, "AUTHORIZATION": true, "SESSION_REPLICATION_ROLE": true,
+}
+
+// analyzeSet classifies SET. Assigning MySQL user variables is a read,
+// changing the session's access mode is a privilege change and any other
+// setting is a write, since it changes how later statements behave.
+func analyzeSet(lx []sqlparse.Lexeme, set int) StatementAnalysis {
+	rest := lx[set+1:]
+	userVars := len(rest) > 0
+	expectTarget := true
+	for i, l := range rest {
+		switch {
+		case l.Kind == sqlparse.Comma:
+			expectTarget = true
+			continue
+		case expectTarget:
+			userVars = userVars && l.Kind == sqlparse.Operator && l.Text == "@" &&
+				i+1 < len(rest) && rest[i+1].Kind != sqlparse.Operator
+			expectTarget = false
+		}
+		if l.Kind != sqlparse.Word && l.Kind != sqlparse.Ident {
+			continue
+		}
+		name := strings.ToUpper(strings.Trim(l.Text, "`\""))
+		if dot := strings.LastIndex(name, "."); dot >= 0 {
+			name = name[dot+1:]
+		}
+		if accessSettings[name] || strings.HasSuffix(name, "READ_ONLY") {
+			return sessionAccessChange("SET")
+		}
+	}
+
+	switch {
+	case readWriteTransaction(rest):
+		return sessionAccessChange("SET")
+	case userVars:
+		return StatementAnalysis{Kind: "SET", Class: ClassRead}
+	default:
+		return StatementAnalysis{Kind: "SET", Class: ClassWrite}
+	}
+}
+
+// readWriteTransaction reports whether a BEGIN or START TRANSACTION asks for
+// READ WRITE access.
+func readWriteTransaction(lx []sqlparse.Lexeme) bool {
+	for i := 0; i+1 < len(lx); i++ {
+		if lx[i].Is("READ") && lx[i+1].Is("WRITE") {
+			return true
+		}
+	}
+	return false
+}
+
+func sessionAccessChange(kind string) StatementAnalysis {
+	return StatementAnalysis{
+		Kind:    kind,
+		Class:   ClassPrivilege,
+		Warning: "This changes the session's access mode and can lift a read-only session",
+	}
+}
+
+// accessPragmas are the SQLite pragmas that lift or bypass a read-only
+// session when set.
+var accessPragmas = map[string]bool{
+	"query_only": true, "writable_schema": true, "trusted_schema": true,
+}
+
+// introspectionPragmas take an argument that names an object to describe
+// rather than a value to set.
+var introspectionPragmas = map[string]bool{
+	"table_info": true, "table_xinfo": true, "table_list": true, "index_info": true,
+	"index_xinfo": true, "index_list": true, "foreign_key_list": true,
+	"foreign_key_check": true, "integrity_check": true, "quick_check": true,
+}
+
+// analyzePragma classifies PRAGMA name, PRAGMA name = value and
+// PRAGMA name(value).
+func analyzePragma(lx []sqlparse.Lexeme, pragma int) StatementAnalysis {
+	if pragma+1 >= len(lx) {
+		return StatementAnalysis{Kind: "PRAGMA", Class: ClassRead}
+	}
+	name := strings.ToLower(lx[pragma+1].Text)
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+	sets := hasOperator(lx[pragma+2:], "=") ||
+		pragma+2 < len(lx) && lx[pragma+2].Kind == sqlparse.LParen && !introspectionPragmas[name]
+	switch {
+	case !sets:
+		return StatementAnalysis{Kind: "PRAGMA", Class: ClassRead}
+	case accessPragmas[name]:
+		return sessionAccessChange("PRAGMA")
+	default:
+		return StatementAnalysis{Kind: "PRAGMA", Class: ClassWrite}
+	}
+}
+
+var ddlModifiers = map[string]bool{
+	"OR": true, "REPLACE": true, "GLOBAL": true, "LOCAL": true, "TEMP": true,
+	"TEMPORARY": true, "UNLOGGED": true, "MATERIALIZED": true, "RECURSIVE": true,
+}
+
+// ddlObjects returns the tables and views named by CREATE, ALTER, DROP or
+// TRUNCATE TABLE/VIEW, RENAME TABLE, ALTER TABLE ... RENAME TO and
+// SELECT ... INTO.
+func ddlObjects(lx []sqlparse.Lexeme) []string {
+	if len(lx) < 2 || lx[0].Kind != sqlparse.Word {
+		return nil
+	}
+
+	verb := lx[0].Upper()
+	i := 1
+	switch verb {
+	case "CREATE", "ALTER", "DROP":
+		for i < len(lx) && ddlModifiers[lx[i].Upper()] {
+			i++
+		}
+		if i >= len(lx) || !(lx[i].Is("TABLE") || lx[i].Is("VIEW")) {
+			return nil
+		}
+		i++
+	case "SELECT":
+		into := selectInto(lx, 0)
+		if into < 0 {
+			return nil
+		}
+		i = into + 1
+		for i < len(lx) && (ddlModifiers[lx[i].Upper()] || lx[i].Is("TABLE")) {
+			i++
+		}
+		if i < len(lx) && (lx[i].Kind == sqlparse.Word || lx[i].Kind == sqlparse.Ident) {
+			return []string{lx[i].Text}
+		}
+		return nil
+	case "TRUNCATE", "RENAME":
+		if lx[i].Is("TABLE") {
+			i++
+		} else if verb == "RENAME" {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	for i < len(lx) && (lx[i].Is("IF") || lx[i].Is("NOT") || lx[i].Is("EXISTS") || lx[i].Is("ONLY")) {
+		i++
+	}
+
+	var objects []string
+	for ; i < len(lx); i++ {
+		if lx[i].Kind != sqlparse.Word && lx[i].Kind != sqlparse.Ident {
+			break
+		}
+		objects = append(objects, lx[i].Text)
+		next := i + 1
+		// RENAME TABLE a TO b, c TO d names both sides of each pair.
+		if verb == "RENAME" && next+1 < len(lx) && lx[next].Is("TO") {
+			objects = append(objects, lx[next+1].Text)
+			next += 2
+		}
+		if verb == "CREATE" || verb == "ALTER" || next >= len(lx) || lx[next].Kind != sqlparse.Comma {
+			break
+		}
+		i = next
+	}
+
+	if verb == "ALTER" {
+		if r := sqlparse.FindTopLevel(lx, i, "RENAME"); r >= 0 && r+2 < len(lx) && lx[r+1].Is("TO") {
+			objects = append(objects, lx[r+2].Text)
+		}
+	}
+	return objects
+}
+
+func replaceAnalysis(kind string) StatementAnalysis {
+	return StatementAnalysis{
+		Kind:    kind,
+		Class:   ClassDestructive,
+		Warning: "REPLACE deletes existing rows with a conflicting unique key before inserting",
+	}
+}
+
+// analyzeWith classifies WITH ... <statement> by its main statement and by any
+// data-modifying CTE bodies, keeping the most severe result.
+func analyzeWith(lx []sqlparse.Lexeme, with int, sql string) StatementAnalysis {
+	var ctes []StatementAnalysis
+	main := -1
+
+	for i := with + 1; i < len(lx) && main < 0; i++ {
+		switch {
+		case lx[i].Kind == sqlparse.LParen:
+			end := sqlparse.MatchingParen(lx, i)
+			if i > 0 && (lx[i-1].Is("AS") || lx[i-1].Is("MATERIALIZED")) && end > i+1 {
+				ctes = append(ctes, analyzeStatement(lx[i+1:end], sql))
+			}
+			i = end
+		case lx[i].Kind == sqlparse.Word:
+			switch lx[i].Upper() {
+			case "SELECT", "INSERT", "UPDATE", "DELETE", "MERGE", "VALUES", "TABLE", "REPLACE":
+				main = i
+			}
+		}
+	}
+
+	var result StatementAnalysis
+	if main < 0 {
+		result = StatementAnalysis{Kind: "WITH", Class: ClassRead}
+	} else {
+		cteText := ""
+		readOnlyCTEs := true
+		for _, c := range ctes {
+			if c.Class != ClassRead {
+				readOnlyCTEs = false
+			}
+		}
+		if readOnlyCTEs {
+			cteText = strings.TrimSpace(sql[lx[with].Start:lx[main].Start])
+		}
+		switch verb := lx[main].Upper(); verb {
+		case "UPDATE", "DELETE":
+			result = analyzeModify(lx, main, verb, sql, cteText)
+			if !readOnlyCTEs {
+				result.DryRunSQL = ""
+			}
+		default:
+			result = analyzeStatement(lx[main:], sql)
+		}
+	}
+
+	for _, c := range ctes {
+		if classSeverity[c.Class] > classSeverity[result.Class] {
+			result.Class = c.Class
+			result.Kind = c.Kind
+			result.Warning = c.Warning
+			result.DryRunSQL = ""
+		}
+	}
+	return result
+}
+
+// analyzeExplain treats EXPLAIN as a read unless ANALYZE makes the database
+// execute the statement.
+func analyzeExplain(lx []sqlparse.Lexeme, explain int, sql string) StatementAnalysis {
+	i, analyze := sqlparse.SkipExplain(lx, explain)
+	if !analyze || i >= len(lx) {
+		return StatementAnalysis{Kind: "EXPLAIN", Class: ClassRead}
+	}
+	return analyzeStatement(lx[i:], sql)
+}
+
+// analyzeModify classifies an UPDATE or DELETE starting at lx[verb] and builds
+// a dry-run count query when the target is a single table.
+func analyzeModify(lx []sqlparse.Lexeme, verb int, kind, sql, prefix string) StatementAnalysis {
+	result := StatementAnalysis{Kind: kind, Class: ClassWrite}
+
+	where := sqlparse.FindTopLevel(lx, verb+1, "WHERE")
+	whereEnd := len(lx)
+	if where >= 0 {
+		if end := sqlparse.FindTopLevel(lx, where+1, "ORDER", "LIMIT", "RETURNING"); end >= 0 {
+			whereEnd = end
+		}
+		result.TautologicalWhere = where+1 >= whereEnd || isTautology(lx[where+1:whereEnd])
+	}
+
+	action := "remove"
+	if kind == "UPDATE" {
+		action = "modify"
+	}
+	switch {
+	case where < 0:
+		result.Class = ClassDestructive
+		result.Warning = fmt.Sprintf("%s without WHERE clause will %s all rows", kind, action)
+	case result.TautologicalWhere:
+		result.Class = ClassDestructive
+		result.Warning = fmt.Sprintf("%s WHERE clause is always true and will %s all rows", kind, action)
+	}
+
+	targetStart, targetEnd := modifyTarget(lx, verb, kind, where)
+	if targetStart < 0 || targetEnd <= targetStart {
+		return result
+	}
+
+	var b strings.Builder
+	if prefix != "" {
+		b.WriteString(prefix)
+		b.WriteString(" ")
+	}
+	b.WriteString("SELECT count(*) FROM ")
+	b.WriteString(sql[lx[targetStart].Start:lx[targetEnd-1].End])
+	if where >= 0 && whereEnd > where+1 {
+		b.WriteString(" WHERE ")
+		b.WriteString(sql[lx[where+1].Start:lx[whereEnd-1].End])
+	}
+	result.DryRunSQL = b.String()
+	return result
+}
+
+// modifyTarget returns the sqlparse.Lexeme range naming the table (and alias) an
+// UPDATE or DELETE touches, or -1 when the statement joins other tables.
+func modifyTarget(lx []sqlparse.Lexeme, verb int, kind string, where int) (int, int) {
+	end := where
+	if end < 0 {
+		end = len(lx)
+	}
+
+	start := verb + 1
+	var stop int
+	if kind == "DELETE" {
+		if start >= end || !lx[start].Is("FROM") {
+			return -1, -1
+		}
+		start++
+		stop = end
+		if u := sqlparse.FindTopLevel(lx[:end], start, "ORDER", "LIMIT", "RETURNING"); u >= 0 {
+			stop = u
+		}
+		if sqlparse.FindTopLevel(lx[:stop], start, "USING", "JOIN") >= 0 {
+			return -1, -1
+		}
+	} else {
+		for start < end && (lx[start].Is("ONLY") || lx[start].Is("LOW_PRIORITY") || lx[start].Is("IGNORE")) {
+			start++
+		}
+		if start+1 < end && lx[start].Is("OR") {
+			start += 2
+		}
+		stop = sqlparse.FindTopLevel(lx[:end], start, "SET")
+		if stop < 0 || sqlparse.FindTopLevel(lx[:end], stop, "FROM") >= 0 || sqlparse.FindTopLevel(lx[:stop], start, "JOIN") >= 0 {
+			return -1, -1
+		}
+	}
+
+	for _, l := range lx[start:stop] {
+		if l.Kind == sqlparse.Comma || l.Kind == sqlparse.LParen {
+			return -1, -1
+		}
+	}
+	return start, stop
+}
+
+// isTautology reports whether a WHERE condition is always true: some OR
+// branch consists only of conditions such as 1=1, TRUE, 'a'='a' or x = x.
+func isTautology(cond []sqlparse.Lexeme) bool {
+	for _, branch := range splitCondition(cond, "OR") {
+		allTrue := len(branch) > 0
+		for _, term := range splitCondition(branch, "AND") {
+			if !isAlwaysTrue(term) {
+				allTrue = false
+				break
+			}
+		}
+		if allTrue {
+			return true
+		}
+	}
+	return false
+}
+
+func splitCondition(cond []sqlparse.Lexeme, op string) [][]sqlparse.Lexeme {
+	var parts [][]sqlparse.Lexeme
+	depth := 0
+	start := 0
+	between := false
+	for i, l := range cond {
+		switch {
+		case l.Kind == sqlparse.LParen:
+			depth++
+		case l.Kind == sqlparse.RParen:
+			depth--
+		case depth == 0 && l.Is("BETWEEN"):
+			between = true
+		case depth == 0 && l.Is(op):
+			if op == "AND" && between {
+				between = false
+				continue
+			}
+			parts = append(parts, cond[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, cond[start:])
+}
+
+func isAlwaysTrue(term []sqlparse.Lexeme) bool {
+	if len(term) >= 2 && term[0].Kind == sqlparse.LParen && sqlparse.MatchingParen(term, 0) == len(term)-1 {
+		return isTautology(term[1 : len(term)-1])
+	}
+
+	switch len(term) {
+	case 1:
+		if term[0].Is("TRUE") {
+			return true
+		}
+		if term[0].Kind == sqlparse.Number {
+			n, err := strconv.ParseFloat(term[0].Text, 64)
+			return err == nil && n != 0
+		}
+	case 2:
+		if term[0].Is("NOT") {
+			return term[1].Is("FALSE") || term[1].Kind == sqlparse.Number && isZero(term[1].Text)
+		}
+	case 3:
+		left, op, right := term[0], term[1], term[2]
+		if op.Kind == sqlparse.Word && op.Is("LIKE") {
+			return right.Kind == sqlparse.String && strings.Trim(right.Text, "'%") == "" && strings.Contains(right.Text, "%")
+		}
+		if op.Kind != sqlparse.Operator {
+			return false
+		}
+		if isLiteral(left) && isLiteral(right) {
+			return compareLiterals(left, op.Text, right)
+		}
+		if !isLiteral(left) && !left.Is("NULL") && left.Text == right.Text {
+			switch op.Text {
+			case "=", "==", ">=", "<=":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isLiteral(l sqlparse.Lexeme) bool {
+	return l.Kind == sqlparse.Number || l.Kind == sqlparse.String
+}
+
+func isZero(text string) bool {
+	n, err := strconv.ParseFloat(text, 64)
+	return err == nil && n == 0
+}
+
+func compareLiterals(left sqlparse.Lexeme, op string, right sqlparse.Lexeme) bool {
+	var cmp int
+	if left.Kind == sqlparse.Number && right.Kind == sqlparse.Number {
+		l, errL := strconv.ParseFloat(left.Text, 64)
+		r, errR := strconv.ParseFloat(right.Text, 64)
+		if errL != nil || errR != nil {
+			return false
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	} else if left.Kind == sqlparse.String && right.Kind == sqlparse.String {
+		cmp = strings.Compare(left.Text, right.Text)
+	} else {
+		return false
+	}
+
+	switch op {
+	case "=", "==":
+		return cmp == 0
+	case "<>", "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+func hasOperator(lx []sqlparse.Lexeme, op string) bool {
+	for _, l := range lx {
+		if l.Kind == sqlparse.Operator && l.Text == op {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+package editor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalyzeStatementsClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		sql       string
+		dialect   string
+		wantKind  string
+		wantClass StatementClass
+	}{
+		{"select", "SELECT * FROM users", "", "SELECT", ClassRead},
+		{"parenthesised select", "(SELECT 1) UNION (SELECT 2)", "", "SELECT", ClassRead},
+		{"show", "SHOW TABLES", "mysql", "SHOW", ClassRead},
+		{"insert", "INSERT INTO t (a) VALUES (1)", "", "INSERT", ClassWrite},
+		{"update with where", "UPDATE t SET a = 1 WHERE id = 2", "", "UPDATE", ClassWrite},
+		{"delete with where", "DELETE FROM t WHERE id = 2", "", "DELETE", ClassWrite},
+		{"create", "CREATE TABLE t (id INT)", "", "CREATE", ClassDDL},
+		{"alter add", "ALTER TABLE t ADD COLUMN c INT", "", "ALTER", ClassDDL},
+		{"alter drop column", "ALTER TABLE t DROP COLUMN c", "", "ALTER", ClassDestructive},
+		{"alter drop constraint", "ALTER TABLE t DROP CONSTRAINT t_fk", "postgres", "ALTER", ClassDestructive},
+		{"grant", "GRANT SELECT ON t TO app", "", "GRANT", ClassPrivilege},
+		{"revoke", "REVOKE ALL ON t FROM app", "", "REVOKE", ClassPrivilege},
+		{"mysql replace", "REPLACE INTO t (id, a) VALUES (1, 2)", "mysql", "REPLACE", ClassDestructive},
+		{"sqlite insert or replace", "INSERT OR REPLACE INTO t VALUES (1)", "sqlite", "INSERT", ClassDestructive},
+		{"create or replace view", "CREATE OR REPLACE VIEW v AS SELECT 1", "postgres", "CREATE", ClassDDL},
+		{"cte delete", "WITH old AS (SELECT id FROM t WHERE ts < now()) DELETE FROM t", "postgres", "DELETE", ClassDestructive},
+		{"cte delete with where", "WITH old AS (SELECT id FROM t) DELETE FROM t WHERE id IN (SELECT id FROM old)", "postgres", "DELETE", ClassWrite},
+		{"cte select", "WITH x AS (SELECT 1) SELECT * FROM x", "", "SELECT", ClassRead},
+		{"data modifying cte", "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", "postgres", "DELETE", ClassDestructive},
+		{"explain", "EXPLAIN DELETE FROM t", "postgres", "EXPLAIN", ClassRead},
+		{"explain analyze", "EXPLAIN ANALYZE DELETE FROM t", "postgres", "DELETE", ClassDestructive},
+		{"explain options analyze", "EXPLAIN (ANALYZE, FORMAT JSON) UPDATE t SET a = 1 WHERE id = 1", "postgres", "UPDATE", ClassWrite},
+		{"pragma read", "PRAGMA table_info(users)", "sqlite", "PRAGMA", ClassRead},
+		{"pragma write", "PRAGMA journal_mode = WAL", "sqlite", "PRAGMA", ClassWrite},
+		{"pragma call syntax write", "PRAGMA foreign_keys(0)", "sqlite", "PRAGMA", ClassWrite},
+		{"pragma lifts query only", "PRAGMA main.query_only(0)", "sqlite", "PRAGMA", ClassPrivilege},
+		{"pragma query only read", "PRAGMA query_only", "sqlite", "PRAGMA", ClassRead},
+		{"set lifts read only", "SET default_transaction_read_only = off", "postgres", "SET", ClassPrivilege},
+		{"set session read write", "SET SESSION TRANSACTION READ WRITE", "mysql", "SET", ClassPrivilege},
+		{"set characteristics read write", "SET SESSION CHARACTERISTICS AS TRANSACTION READ WRITE", "postgres", "SET", ClassPrivilege},
+		{"set system variable", "SET @@session.transaction_read_only = 0", "mysql", "SET", ClassPrivilege},
+		{"set user variable hides read only", "SET @x = 1, @@transaction_read_only = 0", "mysql", "SET", ClassPrivilege},
+		{"set role", "SET ROLE admin", "postgres", "SET", ClassPrivilege},
+		{"set transaction read only", "SET TRANSACTION READ ONLY", "postgres", "SET", ClassWrite},
+		{"set other setting", "SET search_path TO app", "postgres", "SET", ClassWrite},
+		{"set user variable", "SET @x = 1, @y = 2", "mysql", "SET", ClassRead},
+		{"begin", "BEGIN", "postgres", "BEGIN", ClassRead},
+		{"begin read write", "BEGIN READ WRITE", "postgres", "BEGIN", ClassPrivilege},
+		{"start transaction read write", "START TRANSACTION READ WRITE", "mysql", "START", ClassPrivilege},
+		{"select into table", "SELECT * INTO archive FROM orders", "postgres", "SELECT", ClassDDL},
+		{"cte select into table", "WITH o AS (SELECT 1) SELECT * INTO archive FROM o", "postgres", "SELECT", ClassDDL},
+		{"select into variable", "SELECT a INTO @v FROM t", "mysql", "SELECT", ClassRead},
+		{"select into outfile", "SELECT a INTO OUTFILE '/tmp/a' FROM t", "mysql", "SELECT", ClassWrite},
+		{"unknown", "CALL refresh()", "", "CALL", ClassWrite},
+		{"keyword in string", "SELECT 'DROP TABLE t'", "", "SELECT", ClassRead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis := AnalyzeStatements(tt.sql, tt.dialect)
+			if len(analysis.Statements) != 1 {
+				t.Fatalf("got %d statements, want 1", len(analysis.Statements))
+			}
+			got := analysis.Statements[0]
+			if got.Kind != tt.wantKind {
+				t.Errorf("Kind = %q, want %q", got.Kind, tt.wantKind)
+			}
+			if got.Class != tt.wantClass {
+				t.Errorf("Class = %q, want %q", got.Class, tt.wantClass)
+			}
+			if got.NeedsConfirmation() && got.Warning == "" {
+				t.Error("expected a warning for a statement that needs confirmation")
+			}
+		})
+	}
+}
+
+func TestAnalyzeStatementsMultiple(t *testing.T) {
+	sql := "SELECT 1;\n-- cleanup\nDROP TABLE users; UPDATE t SET a = ';' WHERE id = 1;"
+	analysis := AnalyzeStatements(sql, "postgres")
+
+	if len(analysis.Statements) != 3 {
+		t.Fatalf("got %d statements, want 3", len(analysis.Statements))
+	}
+	wantClasses := []StatementClass{ClassRead, ClassDestructive, ClassWrite}
+	for i, want := range wantClasses {
+		if analysis.Statements[i].Class != want {
+			t.Errorf("statement %d class = %q, want %q", i, analysis.Statements[i].Class, want)
+		}
+	}
+	if analysis.Statements[1].SQL != "DROP TABLE users" {
+		t.Errorf("statement 1 SQL = %q", analysis.Statements[1].SQL)
+	}
+	if !analysis.NeedsConfirmation() {
+		t.Error("expected the buffer to need confirmation")
+	}
+	if analysis.IsReadOnly() {
+		t.Error("expected the buffer not to be read-only")
+	}
+	if analysis.Class() != ClassDestructive {
+		t.Errorf("Class() = %q, want %q", analysis.Class(), ClassDestructive)
+	}
+	if !analysis.HasDDL() {
+		t.Error("expected DROP to count as DDL")
+	}
+	if !analysis.ModifiesRows() {
+		t.Error("expected UPDATE to count as a row change")
+	}
+	if analysis.OnlyModifiesRows() {
+		t.Error("expected SELECT and DROP not to count as row changes")
+	}
+}
+
+func TestAnalyzeStatementsRowCapKinds(t *testing.T) {
+	tests := []struct {
+		sql          string
+		dialect      string
+		onlyModifies bool
+		calls        bool
+	}{
+		{"UPDATE t SET a = 1 WHERE id = 1; DELETE FROM t WHERE id = 2", "", true, false},
+		{"INSERT INTO t VALUES (1); SELECT * FROM t", "", false, false},
+		{"UPDATE t SET a = 1 WHERE id = 1; CALL refresh()", "", false, true},
+		{"DO $$ BEGIN DELETE FROM t; END $$", "postgres", false, true},
+		{"SELECT 1", "", false, false},
+	}
+
+	for _, tt := range tests {
+		analysis := AnalyzeStatements(tt.sql, tt.dialect)
+		if got := analysis.OnlyModifiesRows(); got != tt.onlyModifies {
+			t.Errorf("OnlyModifiesRows(%q) = %v, want %v", tt.sql, got, tt.onlyModifies)
+		}
+		if got := analysis.CallsProcedure(); got != tt.calls {
+			t.Errorf("CallsProcedure(%q) = %v, want %v", tt.sql, got, tt.calls)
+		}
+	}
+}
+
+func TestTautologicalWhere(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want bool
+	}{
+		{"one equals one", "DELETE FROM t WHERE 1=1", true},
+		{"spaced", "DELETE FROM t WHERE 1 = 1", true},
+		{"true", "UPDATE t SET a = 1 WHERE TRUE", true},
+		{"strings", "DELETE FROM t WHERE 'a' = 'a'", true},
+		{"not equal literals", "DELETE FROM t WHERE 1 <> 2", true},
+		{"same column", "DELETE FROM t WHERE id = id", true},
+		{"like all", "DELETE FROM t WHERE name LIKE '%'", true},
+		{"or branch", "DELETE FROM t WHERE id = 5 OR 1=1", true},
+		{"parenthesised", "DELETE FROM t WHERE (1=1)", true},
+		{"and with real condition", "DELETE FROM t WHERE 1=1 AND id = 5", false},
+		{"real condition", "DELETE FROM t WHERE id = 5", false},
+		{"false literal", "DELETE FROM t WHERE 1 = 2", false},
+		{"between", "DELETE FROM t WHERE id BETWEEN 1 AND 2", false},
+		{"null", "DELETE FROM t WHERE NULL = NULL", false},
+		{"limit after where", "DELETE FROM t WHERE 1=1 LIMIT 10", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt := AnalyzeStatements(tt.sql, "mysql").Statements[0]
+			if stmt.TautologicalWhere != tt.want {
+				t.Errorf("TautologicalWhere = %v, want %v", stmt.TautologicalWhere, tt.want)
+			}
+			if tt.want && stmt.Class != ClassDestructive {
+				t.Errorf("Class = %q, want %q", stmt.Class, ClassDestructive)
+			}
+		})
+	}
+}
+
+func TestDryRunSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		dialect string
+		want    string
+	}{
+		{"delete all", "DELETE FROM users", "", "SELECT count(*) FROM users"},
+		{"delete where", "DELETE FROM users u WHERE u.id > 10 RETURNING *", "postgres", "SELECT count(*) FROM users u WHERE u.id > 10"},
+		{"update where", "UPDATE public.users SET active = false WHERE last_login < '2020-01-01'", "postgres",
+			"SELECT count(*) FROM public.users WHERE last_login < '2020-01-01'"},
+		{"mysql order limit", "DELETE FROM `logs` WHERE level = 'debug' ORDER BY id LIMIT 100", "mysql",
+			"SELECT count(*) FROM `logs` WHERE level = 'debug'"},
+		{"cte", "WITH old AS (SELECT id FROM t) DELETE FROM t WHERE id IN (SELECT id FROM old)", "postgres",
+			"WITH old AS (SELECT id FROM t) SELECT count(*) FROM t WHERE id IN (SELECT id FROM old)"},
+		{"delete using", "DELETE FROM t USING u WHERE t.id = u.id", "postgres", ""},
+		{"update from", "UPDATE t SET a = u.a FROM u WHERE t.id = u.id", "postgres", ""},
+		{"mysql multi-table", "DELETE t FROM t JOIN u ON t.id = u.id", "mysql", ""},
+		{"insert", "INSERT INTO t VALUES (1)", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt := AnalyzeStatements(tt.sql, tt.dialect).Statements[0]
+			if stmt.DryRunSQL != tt.want {
+				t.Errorf("DryRunSQL = %q, want %q", stmt.DryRunSQL, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeStatementsObjects(t *testing.T) {
+	tests := []struct {
+		sql     string
+		dialect string
+		want    []string
+	}{
+		{"CREATE TABLE users (id INT)", "", []string{"users"}},
+		{"CREATE TABLE IF NOT EXISTS app.users (id INT)", "postgres", []string{"app.users"}},
+		{`CREATE OR REPLACE VIEW public."Active Users" AS SELECT 1`, "postgres", []string{`public."Active Users"`}},
+		{"CREATE TEMPORARY TABLE scratch AS SELECT 1", "postgres", []string{"scratch"}},
+		{"ALTER TABLE ONLY users ADD COLUMN age INT", "postgres", []string{"users"}},
+		{"ALTER TABLE users RENAME TO members", "postgres", []string{"users", "members"}},
+		{"ALTER TABLE users RENAME COLUMN a TO b", "postgres", []string{"users"}},
+		{"DROP TABLE IF EXISTS a, b CASCADE", "postgres", []string{"a", "b"}},
+		{"DROP MATERIALIZED VIEW totals", "postgres", []string{"totals"}},
+		{"RENAME TABLE a TO b, c TO d", "mysql", []string{"a", "b", "c", "d"}},
+		{"TRUNCATE TABLE `logs`", "mysql", []string{"`logs`"}},
+		{"CREATE INDEX idx ON users (id)", "postgres", nil},
+		{"CREATE SCHEMA app", "postgres", nil},
+		{"SELECT * FROM users", "", nil},
+		{"SELECT * INTO UNLOGGED TABLE archive FROM orders", "postgres", []string{"archive"}},
+	}
+
+	for _, tt := range tests {
+		analysis := AnalyzeStatements(tt.sql, tt.dialect)
+		if len(analysis.Statements) != 1 {
+			t.Fatalf("%q: got %d statements, want 1", tt.sql, len(analysis.Statements))
+		}
+		if got := analysis.Statements[0].Objects; !slices.Equal(got, tt.want) {
+			t.Errorf("%q: Objects = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/android-lewis/dbsmith/internal/constants"
 	"github.com/android-lewis/dbsmith/internal/db"
+	querysafety "github.com/android-lewis/dbsmith/internal/editor"
 	"github.com/android-lewis/dbsmith/internal/logging"
 	"github.com/android-lewis/dbsmith/internal/models"
 )
@@ -43,9 +44,16 @@ func (qe *QueryExecutor) ExecuteQuery(ctx context.Context, sql string) (*models.
 
 	start := time.Now()
 	result, err := qe.driver.ExecuteQuery(ctx, sql)
+	if err != nil && ctx.Err() == nil && db.IsConnectionError(err) {
+		result, err = qe.retryRead(ctx, sql, err)
+	}
 	duration := time.Since(start)
 
 	if err != nil {
+		if errors.Is(err, constants.ErrSessionLost) {
+			logging.Error().Err(err).Str("sql", sql).Msg("Session lost during query")
+			return nil, err
+		}
 		if errors.Is(err, context.Canceled) {
 			logging.Info().Msg("Query cancelled by user")
 			return nil, constants.ErrQueryCancelled
@@ -69,7 +77,9 @@ func (qe *QueryExecutor) ExecuteQuery(ctx context.Context, sql string) (*models.
 	return result, nil
 }
 
-func (qe *QueryExecutor) GetQueryExecutionPlan(ctx context.Context, sql string) (*models.QueryResult, error) {
+// ExecuteReadOnlyQuery runs sql in a read-only transaction that is rolled
+// back, for queries such as dry-run row counts that must never write.
+func (qe *QueryExecutor) ExecuteReadOnlyQuery(ctx context.Context, sql string) (*models.QueryResult, error) {
 	if !qe.driver.IsConnected() {
 		return nil, constants.ErrNotConnected
 	}
@@ -77,7 +87,30 @@ func (qe *QueryExecutor) GetQueryExecutionPlan(ctx context.Context, sql string)
 	ctx, cancel := context.WithTimeout(ctx, qe.timeout)
 	defer cancel()
 
-	return qe.driver.GetQueryExecutionPlan(ctx, sql)
+	result, err := qe.driver.ExecuteReadOnlyQuery(ctx, sql)
+	if err != nil {
+		if db.IsConnectionError(err) {
+			return nil, sessionLost("the query was not completed", err)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: query took longer than %v", constants.ErrQueryTimeout, qe.timeout)
+		}
+		return nil, fmt.Errorf("read-only query failed: %w", err)
+	}
+	return result, nil
+}
+
+// GetQueryExecutionPlan fetches the plan for sql. With analyze set the
+// statement is executed by the database inside a rolled back transaction.
+func (qe *QueryExecutor) GetQueryExecutionPlan(ctx context.Context, sql string, analyze bool) (*models.QueryPlan, error) {
+	if !qe.driver.IsConnected() {
+		return nil, constants.ErrNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, qe.timeout)
+	defer cancel()
+
+	return qe.driver.GetQueryExecutionPlan(ctx, sql, analyze)
 }
 
 func (qe *QueryExecutor) ExecuteNonQuery(ctx context.Context, sql string) (int64, error) {
@@ -90,6 +123,9 @@ func (qe *QueryExecutor) ExecuteNonQuery(ctx context.Context, sql string) (int64
 
 	rowsAffected, err := qe.driver.ExecuteNonQuery(ctx, sql)
 	if err != nil {
+		if db.IsConnectionError(err) {
+			return 0, sessionLost("the statement may or may not have been applied", err)
+		}
 		if errors.Is(err, context.DeadlineExceeded) {
 			return 0, fmt.Errorf("%w: query took longer than %v", constants.ErrQueryTimeout, qe.timeout)
 		}
@@ -118,6 +154,10 @@ func (qe *QueryExecutor) ExecuteTransaction(ctx context.Context, queries []strin
 	start := time.Now()
 	if err := qe.driver.ExecuteTransaction(ctx, queries); err != nil {
 		duration := time.Since(start)
+		if db.IsConnectionError(err) {
+			logging.Error().Err(err).Int("query_count", len(queries)).Msg("Session lost during transaction")
+			return sessionLost(transactionLostDetail, err)
+		}
 		if errors.Is(err, context.DeadlineExceeded) {
 			logging.Warn().
 				Dur("duration", duration).
@@ -138,6 +178,36 @@ func (qe *QueryExecutor) ExecuteTransaction(ctx context.Context, queries []strin
 	return nil
 }
 
+// ExecuteTransactionWithLimit runs queries in one transaction and rolls it back
+// when more than maxRows rows are affected.
+func (qe *QueryExecutor) ExecuteTransactionWithLimit(ctx context.Context, queries []string, maxRows int64) (int64, error) {
+	if !qe.driver.IsConnected() {
+		return 0, constants.ErrNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, qe.timeout)
+	defer cancel()
+
+	affected, err := qe.driver.ExecuteTransactionWithLimit(ctx, queries, maxRows)
+	if err != nil {
+		if db.IsConnectionError(err) {
+			return 0, sessionLost(transactionLostDetail, err)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 0, fmt.Errorf("%w: transaction took longer than %v", constants.ErrQueryTimeout, qe.timeout)
+		}
+		if errors.Is(err, db.ErrRowLimitExceeded) {
+			logging.Warn().
+				Int64("rows_affected", affected).
+				Int64("max_rows", maxRows).
+				Msg("Row limit exceeded, transaction rolled back")
+		}
+		return affected, fmt.Errorf("transaction failed: %w", err)
+	}
+
+	return affected, nil
+}
+
 func (qe *QueryExecutor) Ping(ctx context.Context) error {
 	if !qe.driver.IsConnected() {
 		return constants.ErrNotConnected
@@ -149,6 +219,69 @@ func (qe *QueryExecutor) Ping(ctx context.Context) error {
 	return qe.driver.Ping(ctx)
 }
 
+// Reconnect replaces the pooled connections after the server went away.
+func (qe *QueryExecutor) Reconnect(ctx context.Context) error {
+	if !qe.driver.IsConnected() {
+		return constants.ErrNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, constants.PingTimeout)
+	defer cancel()
+
+	return qe.driver.Reconnect(ctx)
+}
+
+const transactionLostDetail = "the transaction was not confirmed as committed, check the data before running it again"
+
+// idempotentKinds are statements that can safely run again on a new session.
+// Session and transaction control is left out: the session it applied to is
+// gone.
+var idempotentKinds = map[string]bool{
+	"SELECT": true, "WITH": true, "VALUES": true, "TABLE": true, "SHOW": true,
+	"DESCRIBE": true, "DESC": true, "EXPLAIN": true, "PRAGMA": true,
+}
+
+// retryRead reconnects after the session dropped under sql and runs it once
+// more if it only reads. Anything else may have been applied, so it fails
+// with ErrSessionLost instead.
+func (qe *QueryExecutor) retryRead(ctx context.Context, sql string, cause error) (*models.QueryResult, error) {
+	if !qe.isIdempotent(sql) {
+		return nil, sessionLost("the statement may or may not have been applied", cause)
+	}
+
+	logging.Warn().Err(cause).Msg("Connection lost during a read, reconnecting and retrying once")
+	if err := qe.Reconnect(ctx); err != nil {
+		return nil, sessionLost("reconnecting failed", err)
+	}
+	result, err := qe.driver.ExecuteQuery(ctx, sql)
+	if err != nil && db.IsConnectionError(err) {
+		return nil, sessionLost("the retry after reconnecting failed too", err)
+	}
+	return result, err
+}
+
+func (qe *QueryExecutor) isIdempotent(sql string) bool {
+	dialect := ""
+	if conn := qe.driver.GetConnection(); conn != nil {
+		dialect = conn.GetSQLDialect()
+	}
+
+	analysis := querysafety.AnalyzeStatements(sql, dialect)
+	if len(analysis.Statements) == 0 {
+		return false
+	}
+	for _, stmt := range analysis.Statements {
+		if stmt.Class != querysafety.ClassRead || !idempotentKinds[stmt.Kind] {
+			return false
+		}
+	}
+	return true
+}
+
+func sessionLost(detail string, cause error) error {
+	return fmt.Errorf("%w: %s: %w", constants.ErrSessionLost, detail, cause)
+}
+
 func (qe *QueryExecutor) SetMaxResults(max int) {
 	qe.maxResults = max
 }
@@ -2,7 +2,13 @@ package executor
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -99,6 +105,32 @@ func TestExecuteTransaction(t *testing.T) {
 	}
 }
 
+func TestExecuteTransactionWithLimit(t *testing.T) {
+	driver := db.NewMockDriver()
+	conn := &models.Connection{Name: "test", Type: models.PostgresType}
+	_ = driver.Connect(context.Background(), conn, nil)
+
+	qe := NewQueryExecutor(driver)
+
+	queries := []string{
+		"UPDATE users SET active = false WHERE id = 1",
+		"UPDATE users SET active = false WHERE id = 2",
+	}
+
+	affected, err := qe.ExecuteTransactionWithLimit(context.Background(), queries, 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if affected != 2 {
+		t.Errorf("Expected 2 rows affected, got %d", affected)
+	}
+
+	_, err = qe.ExecuteTransactionWithLimit(context.Background(), queries, 1)
+	if !errors.Is(err, db.ErrRowLimitExceeded) {
+		t.Errorf("Expected ErrRowLimitExceeded, got %v", err)
+	}
+}
+
 func TestPing(t *testing.T) {
 	driver := db.NewMockDriver()
 	conn := &models.Connection{Name: "test", Type: models.PostgresType}
@@ -255,12 +287,170 @@ func TestExplain(t *testing.T) {
 	qe := NewQueryExecutor(driver)
 	ctx := context.Background()
 
-	result, err := qe.GetQueryExecutionPlan(ctx, "SELECT * FROM users")
+	plan, err := qe.GetQueryExecutionPlan(ctx, "SELECT * FROM users", false)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 
-	if result.RowCount == 0 {
-		t.Error("Expected explain to return rows")
+	if plan.Root == nil {
+		t.Fatal("Expected explain to return a plan tree")
+	}
+
+	if plan.Analyzed {
+		t.Error("Expected estimated plan when analyze is false")
+	}
+
+	plan, err = qe.GetQueryExecutionPlan(ctx, "SELECT * FROM users", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !plan.Analyzed || !plan.Root.HasActual {
+		t.Error("Expected analyzed plan with actual row counts")
+	}
+}
+
+func TestExecuteQueryRetriesReadAfterReconnect(t *testing.T) {
+	driver, qe := newMonitoredDriver(t)
+	ctx := context.Background()
+
+	driver.SetSessionLost(true)
+	result, err := qe.ExecuteQuery(ctx, "WITH u AS (SELECT * FROM users) SELECT * FROM u")
+	if err != nil {
+		t.Fatalf("Expected the read to be retried, got %v", err)
+	}
+	if result == nil || driver.Reconnects() != 1 {
+		t.Errorf("Expected one reconnect, got %d", driver.Reconnects())
+	}
+
+	driver.SetSessionLost(true)
+	driver.SetReconnectFails(true)
+	if _, err := qe.ExecuteQuery(ctx, "SELECT 1"); !errors.Is(err, constants.ErrSessionLost) {
+		t.Errorf("Expected ErrSessionLost when reconnecting fails, got %v", err)
+	}
+}
+
+func TestSessionLostIsNotRetriedForWrites(t *testing.T) {
+	driver, qe := newMonitoredDriver(t)
+	ctx := context.Background()
+	driver.SetSessionLost(true)
+
+	for _, sql := range []string{"UPDATE users SET name = 'x'", "BEGIN", "SELECT 1; DELETE FROM users"} {
+		if _, err := qe.ExecuteQuery(ctx, sql); !errors.Is(err, constants.ErrSessionLost) {
+			t.Errorf("%q: expected ErrSessionLost, got %v", sql, err)
+		}
+	}
+	if err := qe.ExecuteTransaction(ctx, []string{"INSERT INTO users VALUES (1)"}); !errors.Is(err, constants.ErrSessionLost) {
+		t.Errorf("Expected ErrSessionLost from a transaction, got %v", err)
+	}
+	if _, err := qe.ExecuteTransactionWithLimit(ctx, []string{"DELETE FROM users"}, 10); !errors.Is(err, constants.ErrSessionLost) {
+		t.Errorf("Expected ErrSessionLost from a capped transaction, got %v", err)
+	}
+	if driver.Reconnects() != 0 {
+		t.Errorf("Expected no reconnect for writes, got %d", driver.Reconnects())
+	}
+}
+
+// droppingServer is a database/sql driver whose next drops queries fail the
+// way a dropped MySQL session does, so the error goes through a real
+// BaseDriver before the executor sees it.
+type droppingServer struct {
+	mu      sync.Mutex
+	drops   int
+	queries int
+}
+
+func (s *droppingServer) Open(string) (driver.Conn, error) {
+	return droppingConn{s}, nil
+}
+
+// query counts a statement and reports whether its session drops.
+func (s *droppingServer) query() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries++
+	if s.drops > 0 {
+		s.drops--
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+type droppingConn struct{ server *droppingServer }
+
+func (droppingConn) Prepare(string) (driver.Stmt, error) { return nil, db.ErrUnsupportedOperation }
+func (droppingConn) Close() error                        { return nil }
+func (droppingConn) Begin() (driver.Tx, error)           { return nil, db.ErrUnsupportedOperation }
+
+func (c droppingConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	if err := c.server.query(); err != nil {
+		return nil, err
+	}
+	return &oneRow{}, nil
+}
+
+func (c droppingConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	if err := c.server.query(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type oneRow struct{ done bool }
+
+func (r *oneRow) Columns() []string { return []string{"n"} }
+func (r *oneRow) Close() error      { return nil }
+func (r *oneRow) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+// droppingServers counts registrations, since database/sql drivers cannot
+// be registered twice under one name.
+var droppingServers atomic.Int32
+
+func newDroppingDriver(t *testing.T) (*droppingServer, *QueryExecutor) {
+	t.Helper()
+	server := &droppingServer{}
+	name := fmt.Sprintf("dbsmith-dropping-%d", droppingServers.Add(1))
+	sql.Register(name, server)
+
+	sqliteDriver := db.NewSQLiteDriver()
+	conn := &models.Connection{Name: "test", Type: models.SQLiteType, Database: ":memory:"}
+	if err := sqliteDriver.ConnectWithDSN(context.Background(), name, "", conn); err != nil {
+		t.Fatalf("ConnectWithDSN: %v", err)
+	}
+	t.Cleanup(func() { _ = sqliteDriver.Disconnect(context.Background()) })
+	return server, NewQueryExecutor(sqliteDriver)
+}
+
+func TestBaseDriverSessionLoss(t *testing.T) {
+	server, qe := newDroppingDriver(t)
+	ctx := context.Background()
+
+	server.drops = 1
+	result, err := qe.ExecuteQuery(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatalf("Expected the read to be retried, got %v", err)
+	}
+	if len(result.Rows) != 1 || server.queries != 2 {
+		t.Errorf("Expected one row after 2 queries, got %d rows after %d", len(result.Rows), server.queries)
+	}
+
+	server.drops, server.queries = 2, 0
+	if _, err := qe.ExecuteQuery(ctx, "SELECT 1"); !errors.Is(err, constants.ErrSessionLost) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected ErrSessionLost wrapping the driver error when the retry fails, got %v", err)
+	}
+
+	server.drops, server.queries = 1, 0
+	if _, err := qe.ExecuteNonQuery(ctx, "UPDATE users SET name = 'x'"); !errors.Is(err, constants.ErrSessionLost) {
+		t.Errorf("Expected ErrSessionLost for a write, got %v", err)
+	}
+	if server.queries != 1 {
+		t.Errorf("Expected the write to run once, got %d", server.queries)
 	}
 }
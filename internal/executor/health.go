@@ -0,0 +1,162 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/constants"
+	"github.com/android-lewis/dbsmith/internal/logging"
+)
+
+const (
+	DefaultHealthCheckInterval = 15 * time.Second
+	MinReconnectBackoff        = time.Second
+	MaxReconnectBackoff        = 30 * time.Second
+
+	// degradedRecheck is how soon a failed ping is confirmed, so a lost
+	// server is noticed without waiting a full interval.
+	degradedRecheck = 2 * time.Second
+)
+
+// HealthState is the connection health reported by a HealthMonitor.
+type HealthState int
+
+const (
+	HealthHealthy HealthState = iota
+	HealthDegraded
+	HealthLost
+)
+
+func (s HealthState) String() string {
+	switch s {
+	case HealthDegraded:
+		return "degraded"
+	case HealthLost:
+		return "lost"
+	default:
+		return "healthy"
+	}
+}
+
+// HealthMonitor pings the database in the background. One failed ping marks
+// the connection degraded and a second one lost, after which it reconnects
+// with exponential backoff until that succeeds.
+type HealthMonitor struct {
+	qe       *QueryExecutor
+	interval time.Duration
+	onChange func(HealthState)
+
+	mu      sync.Mutex
+	state   HealthState
+	backoff time.Duration
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewHealthMonitor checks qe every interval once started. onChange, if set,
+// is called from the monitor goroutine whenever the state changes.
+func NewHealthMonitor(qe *QueryExecutor, interval time.Duration, onChange func(HealthState)) *HealthMonitor {
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	return &HealthMonitor{qe: qe, interval: interval, onChange: onChange}
+}
+
+// Start runs the checks until Stop is called or ctx is done.
+func (m *HealthMonitor) Start(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		return
+	}
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+	go m.run(ctx, m.done)
+}
+
+// Stop ends the checks and waits for a check in progress to finish.
+func (m *HealthMonitor) Stop() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (m *HealthMonitor) State() HealthState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+func (m *HealthMonitor) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	timer := time.NewTimer(m.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			timer.Reset(m.check(ctx))
+		}
+	}
+}
+
+// check pings, or reconnects when the connection is lost, and returns how
+// long to wait before the next check.
+func (m *HealthMonitor) check(ctx context.Context) time.Duration {
+	if m.State() == HealthLost {
+		if err := m.qe.Reconnect(ctx); err != nil {
+			m.mu.Lock()
+			m.backoff = min(max(m.backoff*2, MinReconnectBackoff), MaxReconnectBackoff)
+			wait := m.backoff
+			m.mu.Unlock()
+			logging.Warn().Err(err).Dur("retry_in", wait).Msg("Reconnect failed")
+			return wait
+		}
+		logging.Info().Msg("Reconnected to database")
+		m.setState(HealthHealthy)
+		return m.interval
+	}
+
+	err := m.qe.Ping(ctx)
+	switch {
+	case err == nil:
+		m.setState(HealthHealthy)
+		return m.interval
+	case errors.Is(err, constants.ErrNotConnected) || ctx.Err() != nil:
+		// Disconnected on purpose or shutting down; nothing to report.
+		return m.interval
+	case m.State() == HealthHealthy:
+		logging.Warn().Err(err).Msg("Database health check failed")
+		m.setState(HealthDegraded)
+		return min(degradedRecheck, m.interval)
+	default:
+		logging.Warn().Err(err).Msg("Database connection lost, reconnecting")
+		m.setState(HealthLost)
+		return 0
+	}
+}
+
+func (m *HealthMonitor) setState(state HealthState) {
+	m.mu.Lock()
+	changed := m.state != state
+	m.state = state
+	if state != HealthLost {
+		m.backoff = 0
+	}
+	m.mu.Unlock()
+
+	if changed && m.onChange != nil {
+		m.onChange(state)
+	}
+}
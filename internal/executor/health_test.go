@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/db"
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+func newMonitoredDriver(t *testing.T) (*db.MockDriver, *QueryExecutor) {
+	t.Helper()
+	driver := db.NewMockDriver()
+	if err := driver.Connect(context.Background(), &models.Connection{Name: "test", Type: models.PostgresType}, nil); err != nil {
+		t.Fatal(err)
+	}
+	return driver, NewQueryExecutor(driver)
+}
+
+func TestHealthMonitorStates(t *testing.T) {
+	driver, qe := newMonitoredDriver(t)
+	var changes []HealthState
+	m := NewHealthMonitor(qe, time.Minute, func(s HealthState) { changes = append(changes, s) })
+	ctx := context.Background()
+
+	if wait := m.check(ctx); wait != time.Minute || m.State() != HealthHealthy {
+		t.Fatalf("healthy check: wait %v, state %v", wait, m.State())
+	}
+
+	driver.SetSessionLost(true)
+	driver.SetReconnectFails(true)
+	if wait := m.check(ctx); wait != degradedRecheck || m.State() != HealthDegraded {
+		t.Fatalf("first failure: wait %v, state %v", wait, m.State())
+	}
+	if wait := m.check(ctx); wait != 0 || m.State() != HealthLost {
+		t.Fatalf("second failure: wait %v, state %v", wait, m.State())
+	}
+
+	// Reconnect attempts back off exponentially up to the maximum.
+	var waits []time.Duration
+	for i := 0; i < 7; i++ {
+		waits = append(waits, m.check(ctx))
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Fatalf("backoff = %v, want %v", waits, want)
+		}
+	}
+
+	driver.SetReconnectFails(false)
+	if wait := m.check(ctx); wait != time.Minute || m.State() != HealthHealthy {
+		t.Fatalf("after reconnect: wait %v, state %v", wait, m.State())
+	}
+	if driver.Reconnects() != 8 {
+		t.Errorf("reconnects = %d, want 8", driver.Reconnects())
+	}
+
+	wantChanges := []HealthState{HealthDegraded, HealthLost, HealthHealthy}
+	if len(changes) != len(wantChanges) {
+		t.Fatalf("changes = %v, want %v", changes, wantChanges)
+	}
+	for i := range wantChanges {
+		if changes[i] != wantChanges[i] {
+			t.Fatalf("changes = %v, want %v", changes, wantChanges)
+		}
+	}
+}
+
+func TestHealthMonitorIgnoresDeliberateDisconnect(t *testing.T) {
+	driver, qe := newMonitoredDriver(t)
+	m := NewHealthMonitor(qe, time.Minute, nil)
+	_ = driver.Disconnect(context.Background())
+
+	m.check(context.Background())
+	m.check(context.Background())
+	if m.State() != HealthHealthy {
+		t.Errorf("state = %v after a deliberate disconnect", m.State())
+	}
+}
+
+func TestHealthMonitorRecoversInBackground(t *testing.T) {
+	driver, qe := newMonitoredDriver(t)
+	driver.SetSessionLost(true)
+
+	var mu sync.Mutex
+	var changes []HealthState
+	recovered := make(chan struct{})
+	m := NewHealthMonitor(qe, 10*time.Millisecond, func(s HealthState) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, s)
+		if s == HealthHealthy {
+			close(recovered)
+		}
+	})
+	m.Start(context.Background())
+	defer m.Stop()
+
+	select {
+	case <-recovered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor did not reconnect")
+	}
+	m.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(changes) != 3 || changes[1] != HealthLost {
+		t.Errorf("changes = %v", changes)
+	}
+}
@@ -0,0 +1,94 @@
+// Package explain parses dialect-specific EXPLAIN output into a common plan
+// tree and flags the operators worth a closer look.
+package explain
+
+import (
+	"math"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+const (
+	// ExpensiveShare is the fraction of total plan cost (or time) a single
+	// node must account for before it is flagged as expensive.
+	ExpensiveShare = 0.25
+
+	// RowMismatchFactor is how far actual rows may diverge from the planner
+	// estimate, in either direction, before the node is flagged.
+	RowMismatchFactor = 10.0
+)
+
+// Annotate computes self cost and cost share for every node and records
+// expensive operators, row estimate mismatches and sequential scans.
+func Annotate(plan *models.QueryPlan) {
+	if plan == nil || plan.Root == nil {
+		return
+	}
+
+	useTime := plan.Analyzed && plan.Root.HasActual
+	total := nodeWeight(plan.Root, useTime)
+	annotateNode(plan.Root, useTime, total)
+}
+
+func annotateNode(node *models.PlanNode, useTime bool, total float64) {
+	node.Issues = nil
+
+	self := nodeWeight(node, useTime)
+	for _, child := range node.Children {
+		self -= nodeWeight(child, useTime)
+	}
+	if self < 0 {
+		self = 0
+	}
+	node.SelfCost = self
+	if total > 0 {
+		node.CostShare = self / total
+	}
+
+	if node.CostShare >= ExpensiveShare {
+		node.Issues = append(node.Issues, models.PlanIssueExpensive)
+	}
+	if node.HasActual && rowMismatch(node.EstimatedRows, node.ActualRows) {
+		node.Issues = append(node.Issues, models.PlanIssueRowMismatch)
+	}
+	if node.SeqScan {
+		node.Issues = append(node.Issues, models.PlanIssueSeqScan)
+	}
+
+	for _, child := range node.Children {
+		annotateNode(child, useTime, total)
+	}
+}
+
+func nodeWeight(node *models.PlanNode, useTime bool) float64 {
+	if useTime {
+		return node.ActualTimeMs
+	}
+	return node.TotalCost
+}
+
+func rowMismatch(estimated, actual float64) bool {
+	// Smooth by one so an estimate of 1 row against 0 actual is not flagged.
+	e, a := estimated+1, actual+1
+	return math.Max(e, a)/math.Min(e, a) >= RowMismatchFactor
+}
+
+// Hotspots returns the flagged nodes in depth-first order.
+func Hotspots(plan *models.QueryPlan) []*models.PlanNode {
+	if plan == nil || plan.Root == nil {
+		return nil
+	}
+
+	var out []*models.PlanNode
+	var walk func(*models.PlanNode)
+	walk = func(n *models.PlanNode) {
+		if len(n.Issues) > 0 {
+			out = append(out, n)
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(plan.Root)
+	return out
+}
@@ -0,0 +1,267 @@
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+var mysqlOperationNames = map[string]string{
+	"query_block":                "Query Block",
+	"nested_loop":                "Nested Loop",
+	"ordering_operation":         "Sort",
+	"grouping_operation":         "Group",
+	"duplicates_removal":         "Distinct",
+	"windowing":                  "Window",
+	"union_result":               "Union",
+	"materialized_from_subquery": "Materialize",
+}
+
+var mysqlAccessTypes = map[string]string{
+	"ALL":         "Full Table Scan",
+	"index":       "Full Index Scan",
+	"range":       "Index Range Scan",
+	"ref":         "Index Lookup",
+	"eq_ref":      "Unique Index Lookup",
+	"const":       "Constant Lookup",
+	"system":      "System Table",
+	"fulltext":    "Fulltext Index",
+	"index_merge": "Index Merge",
+	"ref_or_null": "Index Lookup (or NULL)",
+}
+
+// ParseMySQL parses the output of EXPLAIN FORMAT=JSON.
+func ParseMySQL(raw string) (*models.QueryPlan, error) {
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse mysql plan: %w", err)
+	}
+
+	block, ok := doc["query_block"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse mysql plan: missing query_block")
+	}
+
+	plan := &models.QueryPlan{
+		Root:    mysqlNode("query_block", block),
+		Dialect: "mysql",
+		Raw:     raw,
+	}
+	Annotate(plan)
+	return plan, nil
+}
+
+func mysqlNode(key string, obj map[string]any) *models.PlanNode {
+	node := &models.PlanNode{Operation: mysqlOperationNames[key]}
+	if node.Operation == "" {
+		node.Operation = key
+	}
+
+	if name, ok := obj["table_name"].(string); ok {
+		access, _ := obj["access_type"].(string)
+		node.Operation = mysqlAccessTypes[access]
+		if node.Operation == "" {
+			node.Operation = "Table Access (" + access + ")"
+		}
+		node.Relation = name
+		node.Index, _ = obj["key"].(string)
+		node.SeqScan = access == "ALL"
+		node.Detail, _ = obj["attached_condition"].(string)
+		if rows, ok := jsonNumber(obj["rows_produced_per_join"]); ok {
+			node.EstimatedRows = rows
+		} else if rows, ok := jsonNumber(obj["rows_examined_per_scan"]); ok {
+			node.EstimatedRows = rows
+		}
+	}
+
+	if key == "ordering_operation" {
+		if filesort, _ := obj["using_filesort"].(bool); filesort {
+			node.Operation = "Sort (filesort)"
+		}
+	}
+	if key == "grouping_operation" {
+		if temp, _ := obj["using_temporary_table"].(bool); temp {
+			node.Operation = "Group (temporary table)"
+		}
+	}
+
+	if costInfo, ok := obj["cost_info"].(map[string]any); ok {
+		for _, field := range []string{"prefix_cost", "query_cost", "sort_cost"} {
+			if cost, ok := jsonNumber(costInfo[field]); ok {
+				node.TotalCost = cost
+				node.HasCost = true
+				break
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch v := obj[k].(type) {
+		case map[string]any:
+			if k == "table" {
+				node.Children = append(node.Children, mysqlNode(k, v))
+			} else if _, known := mysqlOperationNames[k]; known {
+				node.Children = append(node.Children, mysqlNode(k, v))
+			}
+		case []any:
+			children := mysqlArrayChildren(v)
+			if k == "nested_loop" {
+				loop := &models.PlanNode{Operation: mysqlOperationNames[k], Children: children}
+				for _, c := range children {
+					if c.HasCost && c.TotalCost > loop.TotalCost {
+						loop.TotalCost = c.TotalCost
+						loop.HasCost = true
+					}
+				}
+				node.Children = append(node.Children, loop)
+			} else {
+				node.Children = append(node.Children, children...)
+			}
+		}
+	}
+
+	return node
+}
+
+func mysqlArrayChildren(items []any) []*models.PlanNode {
+	var nodes []*models.PlanNode
+	for _, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if child, ok := obj[k].(map[string]any); ok {
+				nodes = append(nodes, mysqlNode(k, child))
+			}
+		}
+	}
+	return nodes
+}
+
+func jsonNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+var (
+	mysqlTreeCost   = regexp.MustCompile(`\(cost=([0-9.e+]+)(?:\.\.([0-9.e+]+))? rows=([0-9.e+]+)\)`)
+	mysqlTreeActual = regexp.MustCompile(`\(actual time=([0-9.]+)\.\.([0-9.]+) rows=([0-9.e+]+) loops=([0-9]+)\)`)
+	mysqlTreeTable  = regexp.MustCompile(`\bon ([A-Za-z0-9_$]+)(?: using ([A-Za-z0-9_$]+))?`)
+)
+
+// ParseMySQLTree parses the indented tree output of MySQL's EXPLAIN ANALYZE.
+func ParseMySQLTree(raw string) (*models.QueryPlan, error) {
+	type frame struct {
+		indent int
+		node   *models.PlanNode
+	}
+
+	var root *models.PlanNode
+	var stack []frame
+
+	for _, line := range strings.Split(raw, "\n") {
+		idx := strings.Index(line, "-> ")
+		if idx < 0 {
+			if len(stack) > 0 && strings.TrimSpace(line) != "" {
+				top := stack[len(stack)-1].node
+				top.Detail = strings.TrimSpace(top.Detail + " " + strings.TrimSpace(line))
+			}
+			continue
+		}
+
+		node := parseMySQLTreeLine(line[idx+3:])
+		for len(stack) > 0 && stack[len(stack)-1].indent >= idx {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			if root != nil {
+				return nil, fmt.Errorf("failed to parse mysql plan: multiple root nodes")
+			}
+			root = node
+		} else {
+			parent := stack[len(stack)-1].node
+			parent.Children = append(parent.Children, node)
+		}
+		stack = append(stack, frame{indent: idx, node: node})
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("failed to parse mysql plan: no plan nodes found")
+	}
+
+	plan := &models.QueryPlan{
+		Root:     root,
+		Dialect:  "mysql",
+		Analyzed: root.HasActual,
+		Raw:      raw,
+	}
+	if root.HasActual {
+		plan.ExecutionMs = root.ActualTimeMs
+	}
+	Annotate(plan)
+	return plan, nil
+}
+
+func parseMySQLTreeLine(text string) *models.PlanNode {
+	op := text
+	if i := strings.Index(text, "  ("); i >= 0 {
+		op = text[:i]
+	}
+	node := &models.PlanNode{Operation: strings.TrimSpace(op)}
+
+	lower := strings.ToLower(node.Operation)
+	if m := mysqlTreeTable.FindStringSubmatch(node.Operation); m != nil &&
+		(strings.Contains(lower, "scan") || strings.Contains(lower, "lookup")) {
+		node.Relation = m[1]
+		node.Index = m[2]
+	}
+	node.SeqScan = strings.HasPrefix(lower, "table scan")
+
+	if m := mysqlTreeCost.FindStringSubmatch(text); m != nil {
+		node.HasCost = true
+		if m[2] != "" {
+			node.StartupCost, _ = strconv.ParseFloat(m[1], 64)
+			node.TotalCost, _ = strconv.ParseFloat(m[2], 64)
+		} else {
+			node.TotalCost, _ = strconv.ParseFloat(m[1], 64)
+		}
+		node.EstimatedRows, _ = strconv.ParseFloat(m[3], 64)
+	}
+	if m := mysqlTreeActual.FindStringSubmatch(text); m != nil {
+		node.HasActual = true
+		end, _ := strconv.ParseFloat(m[2], 64)
+		node.ActualRows, _ = strconv.ParseFloat(m[3], 64)
+		node.Loops, _ = strconv.ParseInt(m[4], 10, 64)
+		loops := node.Loops
+		if loops < 1 {
+			loops = 1
+		}
+		node.ActualTimeMs = end * float64(loops)
+	}
+
+	return node
+}
@@ -0,0 +1,111 @@
+package explain
+
+import (
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+const mysqlJSONPlan = `{
+  "query_block": {
+    "select_id": 1,
+    "cost_info": {"query_cost": "105.50"},
+    "ordering_operation": {
+      "using_filesort": true,
+      "nested_loop": [
+        {
+          "table": {
+            "table_name": "orders",
+            "access_type": "ALL",
+            "rows_examined_per_scan": 1000,
+            "rows_produced_per_join": 1000,
+            "cost_info": {"prefix_cost": "101.00"},
+            "attached_condition": "(orders.total > 10)"
+          }
+        },
+        {
+          "table": {
+            "table_name": "users",
+            "access_type": "eq_ref",
+            "key": "PRIMARY",
+            "rows_examined_per_scan": 1,
+            "rows_produced_per_join": 1000,
+            "cost_info": {"prefix_cost": "105.50"}
+          }
+        }
+      ]
+    }
+  }
+}`
+
+func TestParseMySQL(t *testing.T) {
+	plan, err := ParseMySQL(mysqlJSONPlan)
+	if err != nil {
+		t.Fatalf("ParseMySQL failed: %v", err)
+	}
+
+	root := plan.Root
+	if root.Operation != "Query Block" || root.TotalCost != 105.5 {
+		t.Errorf("Unexpected root: %+v", root)
+	}
+	if len(root.Children) != 1 || root.Children[0].Operation != "Sort (filesort)" {
+		t.Fatalf("Expected filesort child, got %+v", root.Children)
+	}
+
+	loop := root.Children[0].Children[0]
+	if loop.Operation != "Nested Loop" || len(loop.Children) != 2 {
+		t.Fatalf("Expected nested loop with two tables, got %+v", loop)
+	}
+
+	orders := loop.Children[0]
+	if orders.Relation != "orders" || orders.Operation != "Full Table Scan" || !orders.HasIssue(models.PlanIssueSeqScan) {
+		t.Errorf("Expected full scan of orders flagged as seq scan, got %+v", orders)
+	}
+
+	users := loop.Children[1]
+	if users.Index != "PRIMARY" || users.SeqScan {
+		t.Errorf("Expected PRIMARY lookup on users, got %+v", users)
+	}
+}
+
+func TestParseMySQLTree(t *testing.T) {
+	raw := `-> Nested loop inner join  (cost=4.95 rows=10) (actual time=0.034..5.000 rows=10 loops=1)
+    -> Table scan on c  (cost=1.25 rows=10) (actual time=0.019..4.500 rows=10 loops=1)
+    -> Single-row index lookup on o using PRIMARY (id=c.id)  (cost=0.26 rows=1) (actual time=0.001..0.010 rows=50 loops=10)`
+
+	plan, err := ParseMySQLTree(raw)
+	if err != nil {
+		t.Fatalf("ParseMySQLTree failed: %v", err)
+	}
+
+	if !plan.Analyzed {
+		t.Error("Expected analyzed plan")
+	}
+
+	root := plan.Root
+	if root.Operation != "Nested loop inner join" || len(root.Children) != 2 {
+		t.Fatalf("Unexpected root: %+v", root)
+	}
+
+	scan := root.Children[0]
+	if scan.Relation != "c" || !scan.SeqScan || !scan.HasIssue(models.PlanIssueExpensive) {
+		t.Errorf("Expected expensive table scan on c, got %+v", scan)
+	}
+
+	lookup := root.Children[1]
+	if lookup.Relation != "o" || lookup.Index != "PRIMARY" || lookup.Loops != 10 {
+		t.Errorf("Unexpected lookup node: %+v", lookup)
+	}
+	if !lookup.HasIssue(models.PlanIssueRowMismatch) {
+		t.Error("Expected lookup to be flagged for row mismatch (1 estimated vs 50 actual)")
+	}
+}
+
+func TestParseMySQLInvalid(t *testing.T) {
+	if _, err := ParseMySQL(`{"foo": 1}`); err == nil {
+		t.Error("Expected error for plan without query_block")
+	}
+	if _, err := ParseMySQLTree("no plan here"); err == nil {
+		t.Error("Expected error for tree without nodes")
+	}
+}
@@ -0,0 +1,136 @@
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+type pgExplain struct {
+	Plan          pgNode  `json:"Plan"`
+	PlanningTime  float64 `json:"Planning Time"`
+	ExecutionTime float64 `json:"Execution Time"`
+}
+
+type pgNode struct {
+	NodeType          string   `json:"Node Type"`
+	JoinType          string   `json:"Join Type"`
+	Strategy          string   `json:"Strategy"`
+	RelationName      string   `json:"Relation Name"`
+	Alias             string   `json:"Alias"`
+	IndexName         string   `json:"Index Name"`
+	CTEName           string   `json:"CTE Name"`
+	StartupCost       *float64 `json:"Startup Cost"`
+	TotalCost         *float64 `json:"Total Cost"`
+	PlanRows          float64  `json:"Plan Rows"`
+	ActualTotalTime   *float64 `json:"Actual Total Time"`
+	ActualRows        *float64 `json:"Actual Rows"`
+	ActualLoops       int64    `json:"Actual Loops"`
+	Filter            string   `json:"Filter"`
+	IndexCond         string   `json:"Index Cond"`
+	HashCond          string   `json:"Hash Cond"`
+	MergeCond         string   `json:"Merge Cond"`
+	JoinFilter        string   `json:"Join Filter"`
+	RowsRemovedFilter float64  `json:"Rows Removed by Filter"`
+	SortKey           []string `json:"Sort Key"`
+	GroupKey          []string `json:"Group Key"`
+	Plans             []pgNode `json:"Plans"`
+}
+
+// ParsePostgres parses the output of EXPLAIN (FORMAT JSON).
+func ParsePostgres(raw string) (*models.QueryPlan, error) {
+	var docs []pgExplain
+	if err := json.Unmarshal([]byte(raw), &docs); err != nil {
+		return nil, fmt.Errorf("failed to parse postgres plan: %w", err)
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("failed to parse postgres plan: empty document")
+	}
+
+	doc := docs[0]
+	root := convertPgNode(doc.Plan)
+	plan := &models.QueryPlan{
+		Root:        root,
+		Dialect:     "postgresql",
+		Analyzed:    root.HasActual,
+		PlanningMs:  doc.PlanningTime,
+		ExecutionMs: doc.ExecutionTime,
+		Raw:         raw,
+	}
+	Annotate(plan)
+	return plan, nil
+}
+
+func convertPgNode(n pgNode) *models.PlanNode {
+	op := n.NodeType
+	switch {
+	case n.JoinType != "" && strings.HasSuffix(op, "Join"):
+		op = fmt.Sprintf("%s (%s)", op, n.JoinType)
+	case n.Strategy != "" && n.NodeType == "Aggregate":
+		op = fmt.Sprintf("%s (%s)", op, n.Strategy)
+	}
+
+	node := &models.PlanNode{
+		Operation:     op,
+		Relation:      n.RelationName,
+		Alias:         n.Alias,
+		Index:         n.IndexName,
+		EstimatedRows: n.PlanRows,
+		Loops:         n.ActualLoops,
+		SeqScan:       n.NodeType == "Seq Scan",
+		Detail:        pgDetail(n),
+	}
+	if node.Relation == "" && n.CTEName != "" {
+		node.Relation = n.CTEName
+	}
+	if n.TotalCost != nil {
+		node.HasCost = true
+		node.TotalCost = *n.TotalCost
+		if n.StartupCost != nil {
+			node.StartupCost = *n.StartupCost
+		}
+	}
+	if n.ActualRows != nil {
+		node.HasActual = true
+		node.ActualRows = *n.ActualRows
+		if n.ActualTotalTime != nil {
+			loops := n.ActualLoops
+			if loops < 1 {
+				loops = 1
+			}
+			node.ActualTimeMs = *n.ActualTotalTime * float64(loops)
+		}
+	}
+
+	for _, child := range n.Plans {
+		node.Children = append(node.Children, convertPgNode(child))
+	}
+	return node
+}
+
+func pgDetail(n pgNode) string {
+	var parts []string
+	for _, cond := range []struct{ label, value string }{
+		{"Index Cond", n.IndexCond},
+		{"Hash Cond", n.HashCond},
+		{"Merge Cond", n.MergeCond},
+		{"Join Filter", n.JoinFilter},
+		{"Filter", n.Filter},
+	} {
+		if cond.value != "" {
+			parts = append(parts, cond.label+": "+cond.value)
+		}
+	}
+	if len(n.SortKey) > 0 {
+		parts = append(parts, "Sort Key: "+strings.Join(n.SortKey, ", "))
+	}
+	if len(n.GroupKey) > 0 {
+		parts = append(parts, "Group Key: "+strings.Join(n.GroupKey, ", "))
+	}
+	if n.RowsRemovedFilter > 0 {
+		parts = append(parts, fmt.Sprintf("Rows Removed by Filter: %.0f", n.RowsRemovedFilter))
+	}
+	return strings.Join(parts, "; ")
+}
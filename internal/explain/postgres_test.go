@@ -0,0 +1,122 @@
+package explain
+
+import (
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+const pgAnalyzedPlan = `[
+  {
+    "Plan": {
+      "Node Type": "Hash Join",
+      "Join Type": "Inner",
+      "Startup Cost": 1.09,
+      "Total Cost": 120.50,
+      "Plan Rows": 5,
+      "Actual Startup Time": 0.05,
+      "Actual Total Time": 12.0,
+      "Actual Rows": 900,
+      "Actual Loops": 1,
+      "Hash Cond": "(o.user_id = u.id)",
+      "Plans": [
+        {
+          "Node Type": "Seq Scan",
+          "Relation Name": "orders",
+          "Alias": "o",
+          "Startup Cost": 0.0,
+          "Total Cost": 100.0,
+          "Plan Rows": 1000,
+          "Actual Startup Time": 0.01,
+          "Actual Total Time": 9.0,
+          "Actual Rows": 1000,
+          "Actual Loops": 1
+        },
+        {
+          "Node Type": "Index Scan",
+          "Relation Name": "users",
+          "Alias": "u",
+          "Index Name": "users_pkey",
+          "Startup Cost": 0.0,
+          "Total Cost": 1.05,
+          "Plan Rows": 1,
+          "Actual Startup Time": 0.01,
+          "Actual Total Time": 0.5,
+          "Actual Rows": 1,
+          "Actual Loops": 1,
+          "Index Cond": "(id = 1)"
+        }
+      ]
+    },
+    "Planning Time": 0.2,
+    "Execution Time": 12.3
+  }
+]`
+
+func TestParsePostgresAnalyzed(t *testing.T) {
+	plan, err := ParsePostgres(pgAnalyzedPlan)
+	if err != nil {
+		t.Fatalf("ParsePostgres failed: %v", err)
+	}
+
+	if !plan.Analyzed {
+		t.Error("Expected plan to be marked as analyzed")
+	}
+	if plan.ExecutionMs != 12.3 || plan.PlanningMs != 0.2 {
+		t.Errorf("Unexpected timings: planning=%v execution=%v", plan.PlanningMs, plan.ExecutionMs)
+	}
+
+	root := plan.Root
+	if root.Operation != "Hash Join (Inner)" {
+		t.Errorf("Expected 'Hash Join (Inner)', got %q", root.Operation)
+	}
+	if len(root.Children) != 2 {
+		t.Fatalf("Expected 2 children, got %d", len(root.Children))
+	}
+	if !root.HasIssue(models.PlanIssueRowMismatch) {
+		t.Error("Expected root to be flagged for row mismatch (5 estimated vs 900 actual)")
+	}
+
+	scan := root.Children[0]
+	if scan.Relation != "orders" || !scan.SeqScan {
+		t.Errorf("Expected seq scan on orders, got %+v", scan)
+	}
+	if !scan.HasIssue(models.PlanIssueSeqScan) || !scan.HasIssue(models.PlanIssueExpensive) {
+		t.Errorf("Expected seq scan to be flagged expensive and seq_scan, got %v", scan.Issues)
+	}
+
+	idx := root.Children[1]
+	if idx.Index != "users_pkey" || idx.Detail != "Index Cond: (id = 1)" {
+		t.Errorf("Unexpected index node: %+v", idx)
+	}
+	if len(idx.Issues) != 0 {
+		t.Errorf("Expected no issues on cheap index scan, got %v", idx.Issues)
+	}
+}
+
+func TestParsePostgresEstimated(t *testing.T) {
+	raw := `[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "users", "Total Cost": 35.5, "Plan Rows": 2550}}]`
+
+	plan, err := ParsePostgres(raw)
+	if err != nil {
+		t.Fatalf("ParsePostgres failed: %v", err)
+	}
+
+	if plan.Analyzed {
+		t.Error("Expected estimated plan")
+	}
+	if plan.Root.HasIssue(models.PlanIssueRowMismatch) {
+		t.Error("Row mismatch should not be reported without actual rows")
+	}
+	if plan.Root.CostShare != 1 {
+		t.Errorf("Expected single node to own the whole cost, got %v", plan.Root.CostShare)
+	}
+}
+
+func TestParsePostgresInvalid(t *testing.T) {
+	for _, raw := range []string{"", "not json", "[]"} {
+		if _, err := ParsePostgres(raw); err == nil {
+			t.Errorf("Expected error for %q", raw)
+		}
+	}
+}
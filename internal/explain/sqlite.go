@@ -0,0 +1,83 @@
+package explain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+// SQLiteRow is a single row returned by EXPLAIN QUERY PLAN.
+type SQLiteRow struct {
+	ID     int64
+	Parent int64
+	Detail string
+}
+
+// ParseSQLite builds a plan tree from EXPLAIN QUERY PLAN rows.
+func ParseSQLite(rows []SQLiteRow) (*models.QueryPlan, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("failed to parse sqlite plan: no rows")
+	}
+
+	root := &models.PlanNode{Operation: "QUERY PLAN"}
+	byID := make(map[int64]*models.PlanNode, len(rows))
+
+	for _, row := range rows {
+		node := sqliteNode(row.Detail)
+		byID[row.ID] = node
+
+		parent, ok := byID[row.Parent]
+		if !ok || row.Parent == row.ID {
+			parent = root
+		}
+		parent.Children = append(parent.Children, node)
+	}
+
+	if len(root.Children) == 1 {
+		root = root.Children[0]
+	}
+
+	plan := &models.QueryPlan{Root: root, Dialect: "sqlite"}
+	Annotate(plan)
+	return plan, nil
+}
+
+func sqliteNode(detail string) *models.PlanNode {
+	node := &models.PlanNode{Operation: detail}
+
+	fields := strings.Fields(detail)
+	if len(fields) < 2 {
+		return node
+	}
+
+	verb := strings.ToUpper(fields[0])
+	if verb != "SCAN" && verb != "SEARCH" {
+		return node
+	}
+
+	rest := fields[1:]
+	if strings.EqualFold(rest[0], "TABLE") && len(rest) > 1 {
+		rest = rest[1:]
+	}
+	node.Operation = verb
+	node.Relation = rest[0]
+	if len(rest) > 2 && strings.EqualFold(rest[1], "AS") {
+		node.Alias = rest[2]
+	}
+
+	upper := strings.ToUpper(detail)
+	if i := strings.Index(upper, " USING "); i >= 0 {
+		node.Detail = strings.TrimSpace(detail[i:])
+		words := strings.Fields(detail[i:])
+		for j := 0; j+1 < len(words); j++ {
+			if strings.EqualFold(words[j], "INDEX") {
+				node.Index = words[j+1]
+				break
+			}
+		}
+	}
+	node.SeqScan = verb == "SCAN" && node.Index == "" && !strings.Contains(upper, "USING")
+
+	return node
+}
@@ -0,0 +1,56 @@
+package explain
+
+import (
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+func TestParseSQLite(t *testing.T) {
+	rows := []SQLiteRow{
+		{ID: 3, Parent: 0, Detail: "SCAN users"},
+		{ID: 5, Parent: 0, Detail: "SEARCH orders USING INDEX idx_orders_user (user_id=?)"},
+		{ID: 9, Parent: 0, Detail: "USE TEMP B-TREE FOR ORDER BY"},
+	}
+
+	plan, err := ParseSQLite(rows)
+	if err != nil {
+		t.Fatalf("ParseSQLite failed: %v", err)
+	}
+
+	root := plan.Root
+	if len(root.Children) != 3 {
+		t.Fatalf("Expected 3 top-level steps, got %d", len(root.Children))
+	}
+
+	scan := root.Children[0]
+	if scan.Operation != "SCAN" || scan.Relation != "users" || !scan.HasIssue(models.PlanIssueSeqScan) {
+		t.Errorf("Expected seq scan on users, got %+v", scan)
+	}
+
+	search := root.Children[1]
+	if search.Relation != "orders" || search.Index != "idx_orders_user" || search.SeqScan {
+		t.Errorf("Expected index search on orders, got %+v", search)
+	}
+}
+
+func TestParseSQLiteNested(t *testing.T) {
+	rows := []SQLiteRow{
+		{ID: 2, Parent: 0, Detail: "CO-ROUTINE sub"},
+		{ID: 4, Parent: 2, Detail: "SCAN TABLE t AS x USING COVERING INDEX t_idx"},
+	}
+
+	plan, err := ParseSQLite(rows)
+	if err != nil {
+		t.Fatalf("ParseSQLite failed: %v", err)
+	}
+
+	if plan.Root.Operation != "CO-ROUTINE sub" || len(plan.Root.Children) != 1 {
+		t.Fatalf("Expected single co-routine root, got %+v", plan.Root)
+	}
+
+	child := plan.Root.Children[0]
+	if child.Relation != "t" || child.Alias != "x" || child.Index != "t_idx" || child.SeqScan {
+		t.Errorf("Unexpected nested node: %+v", child)
+	}
+}
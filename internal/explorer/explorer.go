@@ -69,6 +69,27 @@ func (e *Explorer) GetTableIndexes(ctx context.Context, tableName string) ([]mod
 	return e.driver.GetTableIndexes(ctx, tableName)
 }
 
+func (e *Explorer) GetFunctions(ctx context.Context) ([]models.Function, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	return e.driver.GetFunctions(ctx)
+}
+
+func (e *Explorer) GetSearchPath(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	return e.driver.GetSearchPath(ctx)
+}
+
+func (e *Explorer) GetForeignKeys(ctx context.Context) ([]models.ForeignKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	return e.driver.GetForeignKeys(ctx)
+}
+
 func (e *Explorer) GetTableData(ctx context.Context, tableName string, limit int, offset int) (*models.QueryResult, error) {
 	ctx, cancel := context.WithTimeout(ctx, e.timeout)
 	defer cancel()
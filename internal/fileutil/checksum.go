@@ -0,0 +1,13 @@
+package fileutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Checksum identifies file contents so a later save can tell whether the
+// file changed on disk since it was read.
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,88 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workspace.yaml")
+
+	if err := WriteFileAtomic(path, []byte("first"), 0600); err != nil {
+		t.Fatalf("WriteFileAtomic: %v", err)
+	}
+	if err := WriteFileAtomic(path, []byte("second"), 0600); err != nil {
+		t.Fatalf("WriteFileAtomic overwrite: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second" {
+		t.Errorf("content = %q, want %q", data, "second")
+	}
+
+	if runtime.GOOS != "windows" {
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestWriteFileAtomicMissingDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "file")
+	if err := WriteFileAtomic(path, []byte("x"), 0600); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestLockIsExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets")
+
+	first, err := Lock(path, time.Second)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	if _, err := Lock(path, 100*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Lock error = %v, want ErrLocked", err)
+	}
+
+	released := make(chan error, 1)
+	go func() {
+		l, err := Lock(path, 2*time.Second)
+		if err == nil {
+			err = l.Unlock()
+		}
+		released <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if err := first.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := <-released; err != nil {
+		t.Errorf("waiting Lock after Unlock: %v", err)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	if Checksum([]byte("a")) == Checksum([]byte("b")) {
+		t.Error("different contents should have different checksums")
+	}
+	if Checksum([]byte("a")) != Checksum([]byte("a")) {
+		t.Error("checksum should be stable")
+	}
+}
@@ -0,0 +1,62 @@
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLocked is returned when another process holds the lock past the
+// timeout.
+var ErrLocked = errors.New("file is locked by another process")
+
+// DefaultLockTimeout bounds how long a save waits for another dbsmith
+// instance to finish writing the same file.
+const DefaultLockTimeout = 5 * time.Second
+
+const lockRetryInterval = 50 * time.Millisecond
+
+// FileLock is an advisory lock on path, held through a sibling path.lock
+// file. It only excludes other processes that also take the lock.
+type FileLock struct {
+	f *os.File
+}
+
+// Lock takes an exclusive lock for path, retrying until timeout.
+func Lock(path string, timeout time.Duration) (*FileLock, error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := tryLock(f)
+		if err == nil {
+			return &FileLock{f: f}, nil
+		}
+		if !errors.Is(err, ErrLocked) || time.Now().After(deadline) {
+			_ = f.Close()
+			if errors.Is(err, ErrLocked) {
+				return nil, fmt.Errorf("%w: %s", ErrLocked, path)
+			}
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Unlock releases the lock. The lock file is left in place; removing it
+// would let a waiting process lock a file nobody else can see.
+func (l *FileLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := unlock(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
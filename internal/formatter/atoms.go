@@ -0,0 +1,433 @@
+package formatter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/android-lewis/dbsmith/internal/autocomplete"
+)
+
+type atomKind int
+
+const (
+	atomWord atomKind = iota
+	atomOp
+	atomComma
+	atomSemicolon
+	atomLineComment
+	atomBlockComment
+	atomGroup
+	atomLParen
+	atomRParen
+)
+
+// atom is a formatting unit. The chroma lexers split some lexemes (quoted
+// strings, compound operators, qualified names) into several tokens, so
+// tokens are first merged back into atoms and parenthesised runs are then
+// nested into groups.
+type atom struct {
+	kind     atomKind
+	text     string
+	keyword  bool
+	quoted   bool
+	unary    bool
+	call     bool
+	callee   bool
+	closed   bool
+	attached bool
+	children []*atom
+}
+
+func (a *atom) upper() string {
+	if a.kind != atomWord || a.quoted {
+		return ""
+	}
+	return strings.ToUpper(a.text)
+}
+
+// reservedKeywords limits keyword casing to words that are keywords in every
+// position. The chroma lexers also tag non-reserved words such as NAME or
+// TYPE, which are common column names.
+var reservedKeywords = func() map[string]bool {
+	words := []string{
+		"ADD", "ALL", "ALTER", "AND", "ANY", "AS", "ASC", "BEGIN", "BETWEEN", "BY",
+		"CASCADE", "CASE", "CAST", "CHECK", "COLUMN", "COMMIT", "CONFLICT", "CONSTRAINT",
+		"CREATE", "CROSS", "CURRENT_DATE", "CURRENT_TIMESTAMP", "DEFAULT", "DELETE",
+		"DESC", "DISTINCT", "DO", "DROP", "ELSE", "END", "EXCEPT", "EXISTS", "EXPLAIN",
+		"FALSE", "FETCH", "FILTER", "FIRST", "FOR", "FOREIGN", "FROM", "FULL", "GRANT",
+		"GROUP", "HAVING", "IF", "ILIKE", "IN", "INDEX", "INNER", "INSERT", "INTERSECT",
+		"INTO", "IS", "JOIN", "KEY", "LATERAL", "LEFT", "LIKE", "LIMIT", "NATURAL",
+		"NOT", "NOTHING", "NULL", "NULLS", "OFFSET", "ON", "ONLY", "OR", "ORDER",
+		"OUTER", "OVER", "PARTITION", "PRAGMA", "PRIMARY", "RECURSIVE", "REFERENCES",
+		"RENAME", "REPLACE", "RETURNING", "REVOKE", "RIGHT", "ROLLBACK", "ROWS",
+		"SELECT", "SET", "SHOW", "SOME", "TABLE", "THEN", "TO", "TRANSACTION",
+		"TRUE", "TRUNCATE", "UNION", "UNIQUE", "UPDATE", "USING", "VALUES", "VIEW",
+		"WHEN", "WHERE", "WINDOW", "WITH",
+	}
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}()
+
+var compoundOperators = map[string]bool{
+	">=": true, "<=": true, "<>": true, "!=": true, "==": true,
+	"::": true, "||": true, ":=": true, "**": true,
+	"->": true, "->>": true, "#>": true, "#>>": true,
+	"@>": true, "<@": true, "&&": true, "<<": true, ">>": true,
+	"~*": true, "!~": true, "!~*": true, "?|": true, "?&": true,
+}
+
+func lex(sql, dialect string) ([]*atom, error) {
+	tokens, err := autocomplete.Tokenize(sql, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	var atoms []*atom
+	gap := true
+	glue := false
+
+	last := func() *atom {
+		if len(atoms) == 0 {
+			return nil
+		}
+		return atoms[len(atoms)-1]
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		value := tok.Value
+
+		switch {
+		case tok.Type.InCategory(chroma.Comment):
+			text := value
+			if strings.HasPrefix(text, "/*") {
+				for !strings.HasSuffix(text, "*/") && i+1 < len(tokens) && tokens[i+1].Type.InCategory(chroma.Comment) {
+					i++
+					text += tokens[i].Value
+				}
+				atoms = append(atoms, &atom{kind: atomBlockComment, text: text})
+			} else {
+				atoms = append(atoms, &atom{kind: atomLineComment, text: strings.TrimRight(text, "\r\n \t")})
+			}
+			gap, glue = true, false
+			continue
+
+		case tok.Type.SubCategory() == chroma.LiteralString:
+			text := value
+			for i+1 < len(tokens) && tokens[i+1].Type.SubCategory() == chroma.LiteralString {
+				i++
+				text += tokens[i].Value
+			}
+			a := &atom{kind: atomWord, text: text, quoted: true}
+			if prev := last(); prev != nil && (glue || (!gap && prev.kind == atomWord)) {
+				prev.text += text
+				prev.keyword = false
+			} else {
+				atoms = append(atoms, a)
+			}
+			gap, glue = false, false
+			continue
+
+		case tok.Type.InCategory(chroma.Text) && strings.TrimSpace(value) == "":
+			gap = true
+			continue
+
+		case value == "`":
+			text := value
+			for i+1 < len(tokens) {
+				i++
+				text += tokens[i].Value
+				if tokens[i].Value == "`" {
+					break
+				}
+			}
+			if prev := last(); prev != nil && glue {
+				prev.text += text
+			} else {
+				atoms = append(atoms, &atom{kind: atomWord, text: text, quoted: true})
+			}
+			gap, glue = false, false
+			continue
+
+		case value == "[" || strings.HasPrefix(value, "[") && tok.Type.InCategory(chroma.Punctuation):
+			// Subscripts, slices and bracket-quoted identifiers are kept
+			// exactly as written.
+			text, end := bracketed(tokens, i)
+			i = end
+			prev := last()
+			switch {
+			case prev != nil && !gap && prev.kind == atomWord:
+				prev.text += text
+				prev.keyword = false
+			default:
+				attached := prev != nil && !gap && prev.kind == atomRParen
+				atoms = append(atoms, &atom{kind: atomWord, text: text, quoted: true, attached: attached})
+			}
+			gap, glue = false, false
+			continue
+
+		case isParamPrefix(value) && paramFollows(tokens, i) && !(value == ":" && !gap && last() != nil && last().text == ":"):
+			// A bind parameter such as :name, @var, @@global or $1 is one
+			// word; its name is glued on by the next token.
+			text := value
+			for i+1 < len(tokens) && tokens[i+1].Value == "@" {
+				i++
+				text += "@"
+			}
+			atoms = append(atoms, &atom{kind: atomWord, text: text})
+			gap, glue = false, true
+			continue
+
+		case tok.Type.InCategory(chroma.Punctuation) || tok.Type.InCategory(chroma.Operator) || value == ".":
+			for _, r := range strings.TrimSpace(value) {
+				ch := string(r)
+				prev := last()
+				switch ch {
+				case "(":
+					atoms = append(atoms, &atom{kind: atomLParen, text: ch, call: !gap && prev != nil && prev.kind == atomWord})
+				case ")":
+					atoms = append(atoms, &atom{kind: atomRParen, text: ch})
+				case ",":
+					atoms = append(atoms, &atom{kind: atomComma, text: ch})
+				case ";":
+					atoms = append(atoms, &atom{kind: atomSemicolon, text: ch})
+				case ".":
+					if prev != nil && !gap && (prev.kind == atomWord || prev.kind == atomOp) {
+						prev.text += ch
+						prev.keyword = false
+						prev.kind = atomWord
+					} else {
+						atoms = append(atoms, &atom{kind: atomWord, text: ch})
+					}
+					glue = true
+					gap = false
+					continue
+				default:
+					switch {
+					case glue && prev != nil:
+						prev.text += ch
+					case !gap && prev != nil && prev.kind == atomOp && compoundOperators[prev.text+ch]:
+						prev.text += ch
+					default:
+						atoms = append(atoms, &atom{kind: atomOp, text: ch, unary: isUnary(ch, prev)})
+					}
+				}
+				gap, glue = false, false
+			}
+			continue
+		}
+
+		prev := last()
+		if prev != nil && (glue || (!gap && prev.kind == atomWord)) {
+			prev.text += value
+			prev.keyword = false
+		} else {
+			atoms = append(atoms, &atom{
+				kind:    atomWord,
+				text:    value,
+				keyword: tok.Type.InCategory(chroma.Keyword) && reservedKeywords[strings.ToUpper(value)],
+			})
+		}
+		gap, glue = false, false
+	}
+
+	nested := nest(atoms)
+	markWindowKeywords(nested)
+	return nested, nil
+}
+
+func isParamPrefix(value string) bool {
+	switch value {
+	case ":", "@", "@@", "$", "?":
+		return true
+	}
+	return false
+}
+
+// paramFollows reports whether the token after i starts a parameter name,
+// with nothing in between.
+func paramFollows(tokens []autocomplete.Token, i int) bool {
+	if i+1 >= len(tokens) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(tokens[i+1].Value)
+	return r == '_' || r == '@' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// bracketed returns the raw text from the '[' starting tokens[i] to its
+// matching ']', and the index of the token that closes it.
+func bracketed(tokens []autocomplete.Token, i int) (string, int) {
+	var b strings.Builder
+	depth := 0
+	for ; i < len(tokens); i++ {
+		value := tokens[i].Value
+		if tokens[i].Type.SubCategory() != chroma.LiteralString && !tokens[i].Type.InCategory(chroma.Comment) {
+			depth += strings.Count(value, "[") - strings.Count(value, "]")
+		}
+		b.WriteString(value)
+		if depth <= 0 {
+			break
+		}
+	}
+	return b.String(), min(i, len(tokens)-1)
+}
+
+// markWindowKeywords cases OVER, FILTER, PARTITION BY and frame clauses in
+// window definitions as keywords. Only some of the chroma lexers tag them.
+func markWindowKeywords(atoms []*atom) {
+	for i, a := range atoms {
+		if a.kind == atomGroup {
+			markWindowKeywords(a.children)
+			continue
+		}
+		if a.kind != atomWord || a.quoted {
+			continue
+		}
+		var prev, next *atom
+		if i > 0 {
+			prev = atoms[i-1]
+		}
+		if i+1 < len(atoms) {
+			next = atoms[i+1]
+		}
+		switch a.upper() {
+		case "OVER":
+			a.keyword = a.keyword || prev != nil && prev.kind == atomGroup
+		case "FILTER":
+			a.keyword = a.keyword || prev != nil && prev.kind == atomGroup && prev.call && next != nil && next.kind == atomGroup
+		case "PARTITION":
+			a.keyword = a.keyword || next != nil && next.upper() == "BY"
+		case "ROWS", "RANGE", "GROUPS":
+			if startsFrame(atoms[i+1:]) {
+				markFrame(atoms[i:])
+			}
+		}
+	}
+}
+
+// startsFrame reports whether the atoms after ROWS, RANGE or GROUPS form a
+// window frame extent rather than, say, a column that happens to be named
+// range.
+func startsFrame(rest []*atom) bool {
+	if len(rest) == 0 {
+		return false
+	}
+	switch rest[0].upper() {
+	case "BETWEEN", "UNBOUNDED", "CURRENT":
+		return true
+	}
+	if len(rest) > 1 {
+		switch rest[1].upper() {
+		case "PRECEDING", "FOLLOWING":
+			return true
+		}
+	}
+	return false
+}
+
+// frameKeywords are the words of a window frame clause. None is reserved,
+// so they are only cased inside a frame.
+var frameKeywords = map[string]bool{
+	"ROWS": true, "RANGE": true, "GROUPS": true, "BETWEEN": true, "AND": true,
+	"UNBOUNDED": true, "PRECEDING": true, "FOLLOWING": true, "CURRENT": true,
+	"ROW": true, "EXCLUDE": true, "NO": true, "OTHERS": true, "TIES": true,
+	"GROUP": true,
+}
+
+// markFrame marks the frame clause starting at atoms[0]. The frame is the
+// last part of a window definition, so it runs to the end of the group.
+func markFrame(atoms []*atom) {
+	for _, a := range atoms {
+		if frameKeywords[a.upper()] {
+			a.keyword = true
+		}
+	}
+}
+
+func isUnary(op string, prev *atom) bool {
+	if op != "-" && op != "+" && op != "~" {
+		return false
+	}
+	if prev == nil {
+		return true
+	}
+	switch prev.kind {
+	case atomOp, atomComma, atomLParen:
+		return true
+	case atomWord:
+		return prev.keyword
+	default:
+		return false
+	}
+}
+
+// nest folds parenthesised runs into group atoms. Unmatched closing parens are
+// kept as plain operators; unclosed groups run to the end of the input.
+func nest(flat []*atom) []*atom {
+	root := &atom{kind: atomGroup, closed: true}
+	stack := []*atom{root}
+
+	for _, a := range flat {
+		top := stack[len(stack)-1]
+		switch a.kind {
+		case atomLParen:
+			g := &atom{kind: atomGroup, call: a.call}
+			if a.call && len(top.children) > 0 {
+				top.children[len(top.children)-1].callee = true
+			}
+			top.children = append(top.children, g)
+			stack = append(stack, g)
+		case atomRParen:
+			if len(stack) == 1 {
+				top.children = append(top.children, &atom{kind: atomOp, text: a.text})
+				continue
+			}
+			top.closed = true
+			stack = stack[:len(stack)-1]
+		default:
+			top.children = append(top.children, a)
+		}
+	}
+
+	return root.children
+}
+
+func isSubquery(g *atom) bool {
+	for _, c := range g.children {
+		if c.kind == atomLineComment || c.kind == atomBlockComment {
+			continue
+		}
+		switch c.upper() {
+		case "SELECT", "WITH":
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+func splitAt(atoms []*atom, kind atomKind) [][]*atom {
+	var parts [][]*atom
+	start := 0
+	for i, a := range atoms {
+		if a.kind == kind {
+			parts = append(parts, atoms[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, atoms[start:])
+}
+
+func hasKind(atoms []*atom, kind atomKind) bool {
+	for _, a := range atoms {
+		if a.kind == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,481 @@
+package formatter
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+type clauseKind int
+
+const (
+	clauseOther clauseKind = iota
+	clauseList
+	clauseCondition
+	clauseJoin
+)
+
+type clauseDef struct {
+	words []string
+	kind  clauseKind
+}
+
+// clauseDefs is ordered so longer keyword sequences win over their prefixes.
+var clauseDefs = []clauseDef{
+	{[]string{"LEFT", "OUTER", "JOIN"}, clauseJoin},
+	{[]string{"RIGHT", "OUTER", "JOIN"}, clauseJoin},
+	{[]string{"FULL", "OUTER", "JOIN"}, clauseJoin},
+	{[]string{"LEFT", "JOIN"}, clauseJoin},
+	{[]string{"RIGHT", "JOIN"}, clauseJoin},
+	{[]string{"FULL", "JOIN"}, clauseJoin},
+	{[]string{"INNER", "JOIN"}, clauseJoin},
+	{[]string{"CROSS", "JOIN"}, clauseJoin},
+	{[]string{"NATURAL", "JOIN"}, clauseJoin},
+	{[]string{"JOIN"}, clauseJoin},
+	{[]string{"GROUP", "BY"}, clauseList},
+	{[]string{"ORDER", "BY"}, clauseList},
+	{[]string{"UNION", "ALL"}, clauseOther},
+	{[]string{"INSERT", "INTO"}, clauseOther},
+	{[]string{"REPLACE", "INTO"}, clauseOther},
+	{[]string{"DELETE", "FROM"}, clauseOther},
+	{[]string{"ON", "CONFLICT"}, clauseOther},
+	{[]string{"WITH", "RECURSIVE"}, clauseOther},
+	{[]string{"SELECT"}, clauseList},
+	{[]string{"FROM"}, clauseList},
+	{[]string{"WHERE"}, clauseCondition},
+	{[]string{"HAVING"}, clauseCondition},
+	{[]string{"SET"}, clauseList},
+	{[]string{"VALUES"}, clauseList},
+	{[]string{"RETURNING"}, clauseList},
+	{[]string{"WINDOW"}, clauseList},
+	{[]string{"LIMIT"}, clauseOther},
+	{[]string{"OFFSET"}, clauseOther},
+	{[]string{"UNION"}, clauseOther},
+	{[]string{"INTERSECT"}, clauseOther},
+	{[]string{"EXCEPT"}, clauseOther},
+	{[]string{"UPDATE"}, clauseOther},
+	{[]string{"WITH"}, clauseOther},
+}
+
+// startOnlyClauses are only treated as clauses at the start of a statement,
+// since elsewhere they are part of other constructs (e.g. WITH TIME ZONE).
+var startOnlyClauses = map[string]bool{
+	"WITH":    true,
+	"REPLACE": true,
+}
+
+// calleeKeywords are keywords that keep their keyword casing even when
+// written directly before a parenthesis.
+var calleeKeywords = map[string]bool{
+	"IN": true, "VALUES": true, "EXISTS": true, "ANY": true, "ALL": true,
+	"SOME": true, "AS": true, "OVER": true, "FILTER": true, "ON": true,
+	"USING": true, "NOT": true, "AND": true, "OR": true, "INTO": true,
+	"KEY": true, "REFERENCES": true, "UNIQUE": true, "CHECK": true,
+}
+
+type clause struct {
+	keywords []*atom
+	kind     clauseKind
+	body     []*atom
+}
+
+// Format reformats every statement in sql.
+func Format(sql, dialect string, opts Options) (string, error) {
+	atoms, err := lex(sql, dialect)
+	if err != nil {
+		return "", err
+	}
+
+	p := &printer{opts: opts.normalized()}
+	statements := splitAt(atoms, atomSemicolon)
+	written := 0
+	for i, stmt := range statements {
+		terminated := i < len(statements)-1
+		if len(stmt) == 0 {
+			if terminated && written > 0 {
+				p.writeRaw(";")
+			}
+			continue
+		}
+		if written > 0 {
+			p.newline(0)
+			p.lines = append(p.lines, "")
+		}
+		p.query(stmt, 0)
+		if terminated {
+			p.writeRaw(";")
+		}
+		written++
+	}
+	p.flush()
+
+	return strings.Join(p.lines, "\n"), nil
+}
+
+type printer struct {
+	opts    Options
+	lines   []string
+	cur     strings.Builder
+	last    *atom
+	pending bool
+	level   int
+}
+
+func (p *printer) indent(level int) string {
+	return strings.Repeat(" ", level*p.opts.IndentWidth)
+}
+
+func (p *printer) flush() {
+	line := strings.TrimRight(p.cur.String(), " ")
+	if strings.TrimSpace(line) != "" {
+		p.lines = append(p.lines, line)
+	}
+	p.cur.Reset()
+}
+
+func (p *printer) newline(level int) {
+	p.flush()
+	p.cur.WriteString(p.indent(level))
+	p.last = nil
+	p.pending = false
+	p.level = level
+}
+
+func (p *printer) col() int {
+	return utf8.RuneCountInString(p.cur.String())
+}
+
+func (p *printer) writeRaw(s string) {
+	if p.pending {
+		p.newline(p.level)
+	}
+	p.cur.WriteString(s)
+}
+
+func (p *printer) write(a *atom, text string) {
+	if p.pending {
+		p.newline(p.level)
+	}
+	if p.last != nil && needsSpace(p.last, a) {
+		p.cur.WriteByte(' ')
+	}
+	p.cur.WriteString(text)
+	p.last = a
+	if a.kind == atomLineComment {
+		p.pending = true
+	}
+}
+
+func needsSpace(prev, next *atom) bool {
+	switch {
+	case next.kind == atomComma || next.kind == atomSemicolon || next.kind == atomRParen:
+		return false
+	case prev.kind == atomLParen:
+		return false
+	case prev.kind == atomOp && prev.unary:
+		return false
+	case prev.kind == atomOp && prev.text == "::", next.kind == atomOp && next.text == "::":
+		return false
+	case next.kind == atomLParen && next.call:
+		return false
+	case next.attached:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *printer) text(a *atom) string {
+	if a.kind != atomWord || !a.keyword {
+		return a.text
+	}
+	if a.callee && !calleeKeywords[a.upper()] {
+		return a.text
+	}
+	return p.caseKeyword(a.text)
+}
+
+func (p *printer) caseKeyword(s string) string {
+	switch p.opts.KeywordCase {
+	case KeywordUpper:
+		return strings.ToUpper(s)
+	case KeywordLower:
+		return strings.ToLower(s)
+	default:
+		return s
+	}
+}
+
+func (p *printer) fits(extra int) bool {
+	return p.col()+extra <= p.opts.LineWidth
+}
+
+// inline renders atoms on a single line. It reports false when the atoms
+// cannot stay on one line (subqueries, line comments). Line comments at the
+// end are left out, since they can trail the line.
+func (p *printer) inline(atoms []*atom) (string, bool) {
+	for len(atoms) > 0 && atoms[len(atoms)-1].kind == atomLineComment {
+		atoms = atoms[:len(atoms)-1]
+	}
+	scratch := &printer{opts: p.opts}
+	if !scratch.inlineInto(atoms) {
+		return "", false
+	}
+	return scratch.cur.String(), true
+}
+
+func (p *printer) inlineInto(atoms []*atom) bool {
+	for _, a := range atoms {
+		switch a.kind {
+		case atomLineComment:
+			return false
+		case atomGroup:
+			if isSubquery(a) {
+				return false
+			}
+			p.write(&atom{kind: atomLParen, call: a.call}, "(")
+			if !p.inlineInto(a.children) {
+				return false
+			}
+			if a.closed {
+				p.write(&atom{kind: atomRParen}, ")")
+			}
+		default:
+			p.write(a, p.text(a))
+		}
+	}
+	return true
+}
+
+func (p *printer) query(atoms []*atom, level int) {
+	for _, c := range splitClauses(atoms) {
+		p.newline(level)
+		if len(c.keywords) == 0 {
+			p.expr(c.body, level)
+			continue
+		}
+		for _, kw := range c.keywords {
+			p.write(kw, p.caseKeyword(kw.text))
+		}
+
+		switch c.kind {
+		case clauseList:
+			p.list(c, level)
+		case clauseCondition:
+			p.conditions(c.body, level, level+1)
+		case clauseJoin:
+			p.join(c.body, level)
+		default:
+			p.expr(c.body, level)
+		}
+	}
+}
+
+func (p *printer) list(c clause, level int) {
+	body := c.body
+	if len(body) == 0 {
+		return
+	}
+
+	// Keep SELECT DISTINCT [ON (...)] on the keyword line.
+	if c.keywords[0].upper() == "SELECT" && len(body) > 0 {
+		if u := body[0].upper(); u == "DISTINCT" || u == "ALL" {
+			n := 1
+			if len(body) > 2 && body[1].upper() == "ON" && body[2].kind == atomGroup {
+				n = 3
+			}
+			p.expr(body[:n], level)
+			body = body[n:]
+		}
+	}
+
+	if s, ok := p.inline(body); ok && p.fits(1+utf8.RuneCountInString(s)) {
+		p.expr(body, level)
+		return
+	}
+	p.items(splitAt(body, atomComma), level+1)
+}
+
+func (p *printer) items(items [][]*atom, level int) {
+	comma := &atom{kind: atomComma, text: ","}
+	for i, item := range items {
+		p.newline(level)
+		if p.opts.CommaStyle == CommaLeading && i > 0 {
+			p.writeRaw(", ")
+		}
+		p.expr(item, level)
+		if p.opts.CommaStyle == CommaTrailing && i < len(items)-1 {
+			p.write(comma, ",")
+		}
+	}
+}
+
+// conditions writes a boolean expression, breaking before top-level AND/OR
+// when it does not fit on the current line.
+func (p *printer) conditions(body []*atom, level, contLevel int) {
+	if s, ok := p.inline(body); ok && p.fits(1+utf8.RuneCountInString(s)) {
+		p.expr(body, level)
+		return
+	}
+
+	parts := splitConditions(body)
+	p.expr(parts[0], level)
+	for _, part := range parts[1:] {
+		p.newline(contLevel)
+		p.expr(part, contLevel)
+	}
+}
+
+func (p *printer) join(body []*atom, level int) {
+	if s, ok := p.inline(body); ok && p.fits(1+utf8.RuneCountInString(s)) {
+		p.expr(body, level)
+		return
+	}
+
+	for i, a := range body {
+		if a.upper() == "ON" {
+			p.expr(body[:i], level)
+			p.newline(level + 1)
+			p.write(a, p.caseKeyword(a.text))
+			p.conditions(body[i+1:], level+1, level+2)
+			return
+		}
+	}
+	p.expr(body, level)
+}
+
+func (p *printer) expr(atoms []*atom, level int) {
+	for _, a := range atoms {
+		if a.kind == atomGroup {
+			p.group(a, level)
+			continue
+		}
+		p.write(a, p.text(a))
+	}
+}
+
+func (p *printer) group(g *atom, level int) {
+	open := &atom{kind: atomLParen, call: g.call}
+	closeParen := &atom{kind: atomRParen}
+
+	if isSubquery(g) {
+		p.write(open, "(")
+		p.query(g.children, level+1)
+		if g.closed {
+			p.newline(level)
+			p.write(closeParen, ")")
+		}
+		return
+	}
+
+	s, ok := p.inline(g.children)
+	if !ok || g.call || !hasKind(g.children, atomComma) || p.fits(2+utf8.RuneCountInString(s)) {
+		p.write(open, "(")
+		p.expr(g.children, level)
+		if g.closed {
+			p.write(closeParen, ")")
+		}
+		return
+	}
+
+	p.write(open, "(")
+	p.items(splitAt(g.children, atomComma), level+1)
+	if g.closed {
+		p.newline(level)
+		p.write(closeParen, ")")
+	}
+}
+
+func splitClauses(atoms []*atom) []clause {
+	var clauses []clause
+	current := clause{}
+	started := false
+
+	for i := 0; i < len(atoms); {
+		if def, n := matchClause(atoms, i, started); n > 0 {
+			if started || len(current.body) > 0 {
+				clauses = append(clauses, current)
+			}
+			current = clause{keywords: atoms[i : i+n], kind: def.kind}
+			started = true
+			i += n
+			continue
+		}
+
+		a := atoms[i]
+		if a.kind != atomLineComment && a.kind != atomBlockComment {
+			started = true
+		}
+		current.body = append(current.body, a)
+		i++
+	}
+
+	if started || len(current.body) > 0 {
+		clauses = append(clauses, current)
+	}
+	return clauses
+}
+
+func matchClause(atoms []*atom, i int, started bool) (clauseDef, int) {
+	first := atoms[i].upper()
+	if first == "" {
+		return clauseDef{}, 0
+	}
+	if started && startOnlyClauses[first] {
+		return clauseDef{}, 0
+	}
+
+	var prev string
+	if i > 0 {
+		prev = atoms[i-1].upper()
+	}
+	switch {
+	case first == "FROM" && prev == "DISTINCT":
+		return clauseDef{}, 0
+	case first == "UPDATE" && (prev == "FOR" || prev == "DO"):
+		return clauseDef{}, 0
+	}
+
+	for _, def := range clauseDefs {
+		if i+len(def.words) > len(atoms) {
+			continue
+		}
+		matched := true
+		for j, w := range def.words {
+			if atoms[i+j].upper() != w {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return def, len(def.words)
+		}
+	}
+	return clauseDef{}, 0
+}
+
+// splitConditions splits at top-level AND/OR, keeping the connective at the
+// start of each following part. The AND of BETWEEN x AND y is not a split point.
+func splitConditions(atoms []*atom) [][]*atom {
+	var parts [][]*atom
+	start := 0
+	between := false
+
+	for i, a := range atoms {
+		switch a.upper() {
+		case "BETWEEN":
+			between = true
+		case "AND":
+			if between {
+				between = false
+				continue
+			}
+			fallthrough
+		case "OR":
+			if i > start {
+				parts = append(parts, atoms[start:i])
+				start = i
+			}
+		}
+	}
+	return append(parts, atoms[start:])
+}
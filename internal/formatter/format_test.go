@@ -0,0 +1,230 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/config"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		dialect string
+		opts    Options
+		want    string
+	}{
+		{
+			name:    "clauses on their own lines",
+			sql:     "select id, name from users where active = true order by name",
+			dialect: "postgresql",
+			opts:    DefaultOptions(),
+			want: `SELECT id, name
+FROM users
+WHERE active = TRUE
+ORDER BY name`,
+		},
+		{
+			name:    "operators and casts are normalized",
+			sql:     "select a.x::int from t a where a.y>=-1 and a.z<>'it''s'",
+			dialect: "postgresql",
+			opts:    DefaultOptions(),
+			want: `SELECT a.x::int
+FROM t a
+WHERE a.y >= -1 AND a.z <> 'it''s'`,
+		},
+		{
+			name:    "mysql quoted identifiers",
+			sql:     "select `a`.`b`, count(*) from `t` group by `a`.`b`",
+			dialect: "mysql",
+			opts:    DefaultOptions(),
+			want:    "SELECT `a`.`b`, count(*)\nFROM `t`\nGROUP BY `a`.`b`",
+		},
+		{
+			name:    "subquery is indented",
+			sql:     "select * from t where id in (select user_id from orders)",
+			dialect: "sqlite",
+			opts:    DefaultOptions(),
+			want: `SELECT *
+FROM t
+WHERE id IN (
+    SELECT user_id
+    FROM orders
+)`,
+		},
+		{
+			name:    "multiple statements and comments",
+			sql:     "-- first\nselect 1; insert into t (a, b) values (1, 2), (3, 4);",
+			dialect: "postgresql",
+			opts:    DefaultOptions(),
+			want: `-- first
+SELECT 1;
+
+INSERT INTO t (a, b)
+VALUES (1, 2), (3, 4);`,
+		},
+		{
+			name:    "long lists break with leading commas and lower keywords",
+			sql:     "SELECT first_name, last_name, email FROM users WHERE a = 1 AND b BETWEEN 1 AND 5",
+			dialect: "postgresql",
+			opts:    Options{KeywordCase: KeywordLower, IndentWidth: 2, CommaStyle: CommaLeading, LineWidth: 24},
+			want: `select
+  first_name
+  , last_name
+  , email
+from users
+where a = 1
+  and b between 1 and 5`,
+		},
+		{
+			name:    "preserve keyword case",
+			sql:     "Select a From t",
+			dialect: "postgresql",
+			opts:    Options{KeywordCase: KeywordPreserve},
+			want:    "Select a\nFrom t",
+		},
+		{
+			name:    "long join condition wraps",
+			sql:     "select 1 from a left join b on a.id = b.a_id and a.tenant = b.tenant",
+			dialect: "postgresql",
+			opts:    Options{LineWidth: 40},
+			want: `SELECT 1
+FROM a
+LEFT JOIN b
+    ON a.id = b.a_id
+        AND a.tenant = b.tenant`,
+		},
+		{
+			name:    "long column definitions break",
+			sql:     "create table t (id integer primary key, name text not null, created_at timestamp default now())",
+			dialect: "postgresql",
+			opts:    DefaultOptions(),
+			want: `CREATE TABLE t (
+    id integer PRIMARY KEY,
+    name text NOT NULL,
+    created_at timestamp DEFAULT now()
+)`,
+		},
+		{
+			name:    "is distinct from stays inline",
+			sql:     "select 1 from t where a is distinct from b",
+			dialect: "postgresql",
+			opts:    DefaultOptions(),
+			want:    "SELECT 1\nFROM t\nWHERE a IS DISTINCT FROM b",
+		},
+		{
+			name:    "mysql bind parameters and variables",
+			sql:     "select @@version, @@session.sql_mode from t where a = :name and b = @v and c = ? and d = $1",
+			dialect: "mysql",
+			opts:    DefaultOptions(),
+			want:    "SELECT @@version, @@session.sql_mode\nFROM t\nWHERE a = :name AND b = @v AND c = ? AND d = $1",
+		},
+		{
+			name:    "sqlite bind parameters and bracket identifiers",
+			sql:     "select [my col] from t where a = :name and b = @v and c = ?1 and d = $x",
+			dialect: "sqlite",
+			opts:    DefaultOptions(),
+			want:    "SELECT [my col]\nFROM t\nWHERE a = :name AND b = @v AND c = ?1 AND d = $x",
+		},
+		{
+			name:    "postgres parameters and subscripts",
+			sql:     "select arr[1], a.b[2:3], (f(x))[1] from t where id = $1 and name = :name",
+			dialect: "postgresql",
+			opts:    DefaultOptions(),
+			want:    "SELECT arr[1], a.b[2:3], (f(x))[1]\nFROM t\nWHERE id = $1 AND name = :name",
+		},
+		{
+			name:    "window clauses are cased",
+			sql:     "select row_number() over (partition by a order by b), count(*) filter (where x) over w from t window w as (partition by c)",
+			dialect: "mysql",
+			opts:    DefaultOptions(),
+			want: `SELECT
+    row_number() OVER (PARTITION BY a ORDER BY b),
+    count(*) FILTER (WHERE x) OVER w
+FROM t
+WINDOW w AS (PARTITION BY c)`,
+		},
+		{
+			name:    "comment after the from table",
+			sql:     "select a from t -- c\nwhere x = 1",
+			dialect: "postgresql",
+			opts:    DefaultOptions(),
+			want:    "SELECT a\nFROM t -- c\nWHERE x = 1",
+		},
+		{
+			name:    "window frames are cased",
+			sql:     "select sum(a) over (order by c rows between unbounded preceding and current row), range from t",
+			dialect: "postgresql",
+			opts:    DefaultOptions(),
+			want: `SELECT
+    sum(a) OVER (ORDER BY c ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW),
+    range
+FROM t`,
+		},
+		{
+			name:    "window frame offsets and exclusions",
+			sql:     "select sum(a) over (order by c groups 2 preceding exclude current row) from t",
+			dialect: "mysql",
+			opts:    DefaultOptions(),
+			want:    "SELECT sum(a) OVER (ORDER BY c GROUPS 2 PRECEDING EXCLUDE CURRENT ROW)\nFROM t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format(tt.sql, tt.dialect, tt.opts)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Format() mismatch\ngot:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			again, err := Format(got, tt.dialect, tt.opts)
+			if err != nil {
+				t.Fatalf("Format() second pass error = %v", err)
+			}
+			if again != got {
+				t.Errorf("Format() is not idempotent\nfirst:\n%s\nsecond:\n%s", got, again)
+			}
+		})
+	}
+}
+
+func TestOptionsFromConfig(t *testing.T) {
+	opts := OptionsFromConfig(config.FormatConfig{KeywordCase: "LOWER", CommaStyle: "bogus"})
+
+	if opts.KeywordCase != KeywordLower {
+		t.Errorf("KeywordCase = %s, want lower", opts.KeywordCase)
+	}
+	if opts.CommaStyle != CommaTrailing {
+		t.Errorf("CommaStyle = %s, want trailing fallback", opts.CommaStyle)
+	}
+	if opts.IndentWidth != DefaultIndentWidth || opts.LineWidth != DefaultLineWidth {
+		t.Errorf("Expected default indent and width, got %d and %d", opts.IndentWidth, opts.LineWidth)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.FormatConfig
+		wantErr bool
+	}{
+		{name: "unset", cfg: config.FormatConfig{}},
+		{name: "valid", cfg: config.FormatConfig{KeywordCase: "Lower", CommaStyle: "leading", Indent: 2, LineWidth: 100}},
+		{name: "unknown keyword case", cfg: config.FormatConfig{KeywordCase: "title"}, wantErr: true},
+		{name: "unknown comma style", cfg: config.FormatConfig{CommaStyle: "bogus"}, wantErr: true},
+		{name: "negative indent", cfg: config.FormatConfig{Indent: -1}, wantErr: true},
+		{name: "negative line width", cfg: config.FormatConfig{LineWidth: -5}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Package formatter pretty-prints SQL using the autocomplete tokenizer.
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/config"
+)
+
+type KeywordCase string
+
+const (
+	KeywordUpper    KeywordCase = "upper"
+	KeywordLower    KeywordCase = "lower"
+	KeywordPreserve KeywordCase = "preserve"
+)
+
+type CommaStyle string
+
+const (
+	CommaTrailing CommaStyle = "trailing"
+	CommaLeading  CommaStyle = "leading"
+)
+
+const (
+	DefaultIndentWidth = 4
+	DefaultLineWidth   = 80
+)
+
+type Options struct {
+	KeywordCase KeywordCase
+	IndentWidth int
+	CommaStyle  CommaStyle
+	LineWidth   int
+}
+
+func DefaultOptions() Options {
+	return Options{
+		KeywordCase: KeywordUpper,
+		IndentWidth: DefaultIndentWidth,
+		CommaStyle:  CommaTrailing,
+		LineWidth:   DefaultLineWidth,
+	}
+}
+
+// OptionsFromConfig converts the editor's format settings, falling back to
+// defaults for anything unset or unrecognised.
+func OptionsFromConfig(cfg config.FormatConfig) Options {
+	return Options{
+		KeywordCase: KeywordCase(strings.ToLower(cfg.KeywordCase)),
+		IndentWidth: cfg.Indent,
+		CommaStyle:  CommaStyle(strings.ToLower(cfg.CommaStyle)),
+		LineWidth:   cfg.LineWidth,
+	}.normalized()
+}
+
+// ValidateConfig reports format settings that OptionsFromConfig would
+// otherwise replace with defaults. Unset values are valid.
+func ValidateConfig(cfg config.FormatConfig) error {
+	switch KeywordCase(strings.ToLower(cfg.KeywordCase)) {
+	case "", KeywordUpper, KeywordLower, KeywordPreserve:
+	default:
+		return fmt.Errorf("invalid keyword case %q: want upper, lower or preserve", cfg.KeywordCase)
+	}
+	switch CommaStyle(strings.ToLower(cfg.CommaStyle)) {
+	case "", CommaTrailing, CommaLeading:
+	default:
+		return fmt.Errorf("invalid comma style %q: want trailing or leading", cfg.CommaStyle)
+	}
+	if cfg.Indent < 0 {
+		return fmt.Errorf("invalid indent %d: must not be negative", cfg.Indent)
+	}
+	if cfg.LineWidth < 0 {
+		return fmt.Errorf("invalid line width %d: must not be negative", cfg.LineWidth)
+	}
+	return nil
+}
+
+func (o Options) normalized() Options {
+	def := DefaultOptions()
+	switch o.KeywordCase {
+	case KeywordUpper, KeywordLower, KeywordPreserve:
+	default:
+		o.KeywordCase = def.KeywordCase
+	}
+	switch o.CommaStyle {
+	case CommaTrailing, CommaLeading:
+	default:
+		o.CommaStyle = def.CommaStyle
+	}
+	if o.IndentWidth <= 0 {
+		o.IndentWidth = def.IndentWidth
+	}
+	if o.LineWidth <= 0 {
+		o.LineWidth = def.LineWidth
+	}
+	return o
+}
@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+// dbeaverCredentialsKey is the fixed key DBeaver encrypts
+// credentials-config.json with when no master password is set.
+const dbeaverCredentialsKey = "babb4a9f774ab853c96c2d653dfe544a"
+
+const dbeaverCredentialsFile = "credentials-config.json"
+
+type dbeaverDataSources struct {
+	Connections map[string]dbeaverConnection `json:"connections"`
+}
+
+type dbeaverConnection struct {
+	Provider      string `json:"provider"`
+	Driver        string `json:"driver"`
+	Name          string `json:"name"`
+	ReadOnly      bool   `json:"read-only"`
+	Configuration struct {
+		Host     string `json:"host"`
+		Port     string `json:"port"`
+		Database string `json:"database"`
+		URL      string `json:"url"`
+		User     string `json:"user"`
+		Password string `json:"password"`
+		Type     string `json:"type"`
+	} `json:"configuration"`
+}
+
+type dbeaverCredentials struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// parseDBeaver reads a DBeaver data-sources.json. Only PostgreSQL, MySQL,
+// MariaDB and SQLite connections are returned; the rest are skipped.
+func parseDBeaver(r io.Reader, origin string) ([]Candidate, error) {
+	return parseDBeaverWithCredentials(r, origin, nil)
+}
+
+// parseDBeaverFile reads path and, when DBeaver keeps one beside it, the
+// encrypted credentials-config.json holding users and passwords. Credentials
+// protected by a DBeaver master password cannot be read and are left out.
+func parseDBeaverFile(path string) ([]Candidate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var creds map[string]dbeaverCredentials
+	credsPath := filepath.Join(filepath.Dir(path), dbeaverCredentialsFile)
+	if data, err := os.ReadFile(credsPath); err == nil {
+		if creds, err = decryptDBeaverCredentials(data); err != nil {
+			logging.Warn().Err(err).Str("path", credsPath).Msg("Importing DBeaver connections without saved credentials")
+		}
+	}
+
+	candidates, err := parseDBeaverWithCredentials(f, displayPath(path), creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return candidates, nil
+}
+
+func parseDBeaverWithCredentials(r io.Reader, origin string, creds map[string]dbeaverCredentials) ([]Candidate, error) {
+	var sources dbeaverDataSources
+	if err := json.NewDecoder(r).Decode(&sources); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(sources.Connections))
+	for id := range sources.Connections {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var candidates []Candidate
+	for _, id := range ids {
+		dc := sources.Connections[id]
+		cfg := dc.Configuration
+
+		conn := models.Connection{
+			Name:        orDefault(dc.Name, id),
+			Host:        cfg.Host,
+			Database:    cfg.Database,
+			Username:    cfg.User,
+			ReadOnly:    dc.ReadOnly,
+			Environment: dbeaverEnvironment(cfg.Type),
+		}
+		switch {
+		case dc.Provider == "postgresql":
+			conn.Type = models.PostgresType
+			conn.Port = defaultPostgresPort
+		case dc.Provider == "mysql" || dc.Provider == "mariadb":
+			conn.Type = models.MySQLType
+			conn.Port = defaultMySQLPort
+		case strings.Contains(dc.Driver, "sqlite"):
+			conn.Type = models.SQLiteType
+			conn.Host = ""
+			if conn.Database == "" {
+				conn.Database = strings.TrimPrefix(cfg.URL, "jdbc:sqlite:")
+			}
+		default:
+			continue
+		}
+		if conn.Type != models.SQLiteType {
+			conn.Host = orDefault(conn.Host, "localhost")
+			if cfg.Port != "" {
+				p, err := strconv.Atoi(cfg.Port)
+				if err != nil {
+					return nil, fmt.Errorf("connection %s: invalid port %q", conn.Name, cfg.Port)
+				}
+				conn.Port = p
+			}
+		}
+
+		password := cfg.Password
+		if c, ok := creds[id]; ok {
+			conn.Username = orDefault(conn.Username, c.User)
+			password = orDefault(password, c.Password)
+		}
+
+		candidates = append(candidates, Candidate{
+			Connection: conn,
+			Password:   password,
+			Source:     SourceDBeaver,
+			Origin:     fmt.Sprintf("%s [%s]", origin, id),
+		})
+	}
+	return candidates, nil
+}
+
+func dbeaverEnvironment(connectionType string) models.Environment {
+	switch connectionType {
+	case "dev":
+		return models.EnvironmentDev
+	case "test":
+		return models.EnvironmentStaging
+	case "prod":
+		return models.EnvironmentProd
+	}
+	return ""
+}
+
+// decryptDBeaverCredentials decodes credentials-config.json: AES-CBC with the
+// IV in the first block, holding {"<id>": {"#connection": {...}}}.
+func decryptDBeaverCredentials(data []byte) (map[string]dbeaverCredentials, error) {
+	key, _ := hex.DecodeString(dbeaverCredentialsKey)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("credentials are not AES encrypted; a DBeaver master password may be set")
+	}
+
+	plain := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(plain, data[aes.BlockSize:])
+	if pad := int(plain[len(plain)-1]); pad > 0 && pad <= aes.BlockSize {
+		plain = plain[:len(plain)-pad]
+	}
+
+	var raw map[string]map[string]dbeaverCredentials
+	if err := json.Unmarshal(plain, &raw); err != nil {
+		return nil, errors.New("credentials could not be decrypted; a DBeaver master password may be set")
+	}
+	creds := make(map[string]dbeaverCredentials, len(raw))
+	for id, entry := range raw {
+		creds[id] = entry["#connection"]
+	}
+	return creds, nil
+}
@@ -0,0 +1,257 @@
+// Package importer reads connections defined for other tools (.pgpass,
+// pg_service.conf, .my.cnf and DBeaver) so they can be added to a workspace.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/android-lewis/dbsmith/internal/secrets"
+)
+
+// Source names a kind of file connections can be imported from.
+type Source string
+
+const (
+	SourcePgpass    Source = "pgpass"
+	SourcePgService Source = "pg_service"
+	SourceMyCnf     Source = "mycnf"
+	SourceDBeaver   Source = "dbeaver"
+)
+
+// Sources lists every supported source in discovery order.
+var Sources = []Source{SourcePgService, SourcePgpass, SourceMyCnf, SourceDBeaver}
+
+var ErrUnknownSource = errors.New("unknown import source")
+
+// Candidate is a connection found in another tool's configuration. Password
+// is kept apart from the connection and only stored in the secrets manager
+// when the candidate is imported.
+type Candidate struct {
+	Connection models.Connection
+	Password   string
+	Source     Source
+	// Origin is the file and entry the candidate came from, e.g.
+	// "~/.pgpass:3".
+	Origin string
+}
+
+// ParseSource parses a source name as accepted on the command line.
+func ParseSource(name string) (Source, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "pgpass", ".pgpass":
+		return SourcePgpass, nil
+	case "pg_service", "pg-service", "pgservice", "pg_service.conf":
+		return SourcePgService, nil
+	case "mycnf", "my.cnf", ".my.cnf":
+		return SourceMyCnf, nil
+	case "dbeaver":
+		return SourceDBeaver, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownSource, name)
+}
+
+// Parse reads candidates from r, which holds a file of the given source.
+// origin names the file in Candidate.Origin.
+func Parse(source Source, r io.Reader, origin string) ([]Candidate, error) {
+	switch source {
+	case SourcePgpass:
+		return parsePgpass(r, origin)
+	case SourcePgService:
+		return parsePgService(r, origin)
+	case SourceMyCnf:
+		return parseMyCnf(r, origin)
+	case SourceDBeaver:
+		return parseDBeaver(r, origin)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownSource, source)
+}
+
+// ParseFile reads candidates from the file at path.
+func ParseFile(source Source, path string) ([]Candidate, error) {
+	if source == SourceDBeaver {
+		return parseDBeaverFile(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	candidates, err := Parse(source, f, displayPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return candidates, nil
+}
+
+// DefaultPaths returns where source's files usually live, honouring the
+// PGPASSFILE, PGSERVICEFILE and PGSYSCONFDIR variables libpq uses.
+func DefaultPaths(source Source) []string {
+	home, _ := os.UserHomeDir()
+	var paths []string
+	switch source {
+	case SourcePgpass:
+		if p := os.Getenv("PGPASSFILE"); p != "" {
+			return []string{p}
+		}
+		if runtime.GOOS == "windows" {
+			paths = append(paths, filepath.Join(os.Getenv("APPDATA"), "postgresql", "pgpass.conf"))
+		} else if home != "" {
+			paths = append(paths, filepath.Join(home, ".pgpass"))
+		}
+	case SourcePgService:
+		if p := os.Getenv("PGSERVICEFILE"); p != "" {
+			paths = append(paths, p)
+		} else if home != "" {
+			paths = append(paths, filepath.Join(home, ".pg_service.conf"))
+		}
+		if dir := os.Getenv("PGSYSCONFDIR"); dir != "" {
+			paths = append(paths, filepath.Join(dir, "pg_service.conf"))
+		}
+	case SourceMyCnf:
+		if home != "" {
+			paths = append(paths, filepath.Join(home, ".my.cnf"))
+		}
+	case SourceDBeaver:
+		const rel = "DBeaverData/workspace6/General/.dbeaver/data-sources.json"
+		switch runtime.GOOS {
+		case "windows":
+			paths = append(paths, filepath.Join(os.Getenv("APPDATA"), filepath.FromSlash(rel)))
+		case "darwin":
+			paths = append(paths, filepath.Join(home, "Library", filepath.FromSlash(rel)))
+		default:
+			paths = append(paths, filepath.Join(home, ".local", "share", filepath.FromSlash(rel)))
+		}
+	}
+	return paths
+}
+
+// Discover reads every default file that exists for the given sources, or
+// for all sources when none are given. Files that exist but cannot be parsed
+// are reported in the returned errors without stopping the others.
+func Discover(sources ...Source) ([]Candidate, []error) {
+	if len(sources) == 0 {
+		sources = Sources
+	}
+
+	var candidates []Candidate
+	var errs []error
+	for _, source := range sources {
+		for _, path := range DefaultPaths(source) {
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			found, err := ParseFile(source, path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			candidates = append(candidates, found...)
+		}
+	}
+	return UniqueNames(candidates), errs
+}
+
+// UniqueNames suffixes repeated connection names with -2, -3 and so on.
+func UniqueNames(candidates []Candidate) []Candidate {
+	seen := make(map[string]bool)
+	for i := range candidates {
+		name := candidates[i].Connection.Name
+		unique := name
+		for n := 2; seen[unique]; n++ {
+			unique = fmt.Sprintf("%s-%d", name, n)
+		}
+		seen[unique] = true
+		candidates[i].Connection.Name = unique
+	}
+	return candidates
+}
+
+// Workspace is the part of workspace.Manager an import needs. AddConnection
+// must not keep a connection it returns an error for, since Import deletes
+// the connection's stored secret on failure.
+type Workspace interface {
+	GetConnectionByName(name string) *models.Connection
+	AddConnection(conn models.Connection) error
+}
+
+// Result reports what Import did with each candidate.
+type Result struct {
+	Imported []string
+	Skipped  []string
+	Failed   map[string]error
+}
+
+// SecretKeyID is the key a connection's password is stored under, matching
+// the connection form.
+func SecretKeyID(conn models.Connection) string {
+	return fmt.Sprintf("dbsmith_%s_%s", conn.Name, conn.Type)
+}
+
+// Import adds candidates to ws, storing their passwords through secretsMgr.
+// Candidates whose name is already taken are skipped. A password stored for
+// a connection that then fails to be added is deleted again.
+func Import(ws Workspace, secretsMgr secrets.Manager, candidates []Candidate) Result {
+	result := Result{Failed: make(map[string]error)}
+	for _, c := range candidates {
+		conn := c.Connection
+		if ws.GetConnectionByName(conn.Name) != nil {
+			result.Skipped = append(result.Skipped, conn.Name)
+			continue
+		}
+
+		if c.Password != "" {
+			if secretsMgr == nil {
+				result.Failed[conn.Name] = errors.New("no secrets manager to store the password")
+				continue
+			}
+			conn.SecretKeyID = SecretKeyID(conn)
+			if err := secretsMgr.StoreSecret(conn.SecretKeyID, c.Password); err != nil {
+				result.Failed[conn.Name] = fmt.Errorf("failed to store password: %w", err)
+				continue
+			}
+		}
+
+		if err := ws.AddConnection(conn); err != nil {
+			if conn.SecretKeyID != "" {
+				_ = secretsMgr.DeleteSecret(conn.SecretKeyID)
+			}
+			result.Failed[conn.Name] = err
+			continue
+		}
+		result.Imported = append(result.Imported, conn.Name)
+	}
+	return result
+}
+
+// Describe summarises a candidate's target without its password.
+func (c Candidate) Describe() string {
+	conn := c.Connection
+	if conn.Type == models.SQLiteType {
+		return fmt.Sprintf("%s  %s", conn.Type, conn.Database)
+	}
+	target := fmt.Sprintf("%s:%d/%s", conn.Host, conn.Port, conn.Database)
+	if conn.Username != "" {
+		target = conn.Username + "@" + target
+	}
+	return fmt.Sprintf("%s  %s", conn.Type, target)
+}
+
+func displayPath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join("~", rel)
+	}
+	return path
+}
@@ -0,0 +1,313 @@
+package importer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+func TestParsePgpass(t *testing.T) {
+	in := `# comment
+db.example.com:5432:app:alice:s3cret
+*:*:*:postgres:pa\:ss\\word
+localhost:6543:reports:bob:x
+`
+	got, err := Parse(SourcePgpass, strings.NewReader(in), "~/.pgpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d candidates, want 3", len(got))
+	}
+
+	first := got[0]
+	if first.Connection.Name != "app@db.example.com" || first.Connection.Username != "alice" || first.Password != "s3cret" {
+		t.Errorf("first = %+v", first)
+	}
+	if first.Origin != "~/.pgpass:2" {
+		t.Errorf("origin = %q", first.Origin)
+	}
+
+	wild := got[1].Connection
+	if wild.Host != "localhost" || wild.Port != 5432 || wild.Database != "postgres" || got[1].Password != `pa:ss\word` {
+		t.Errorf("wildcard entry = %+v, password %q", wild, got[1].Password)
+	}
+	if got[2].Connection.Name != "reports@localhost:6543" {
+		t.Errorf("name = %q", got[2].Connection.Name)
+	}
+
+	if _, err := Parse(SourcePgpass, strings.NewReader("host:5432:db:user\n"), "x"); err == nil {
+		t.Error("expected an error for a short line")
+	}
+}
+
+func TestParsePgService(t *testing.T) {
+	in := `[prod]
+host=pg.prod
+port=6432
+dbname=app
+user=deploy
+password=hunter2
+sslmode=verify-full
+sslrootcert=/etc/ssl/prod.pem
+
+[local]
+dbname=scratch
+`
+	got, err := Parse(SourcePgService, strings.NewReader(in), "~/.pg_service.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d candidates, want 2", len(got))
+	}
+
+	want := models.Connection{
+		Name: "prod", Type: models.PostgresType, Host: "pg.prod", Port: 6432, Database: "app",
+		Username: "deploy", SSL: "verify-full", SSLCACertPath: "/etc/ssl/prod.pem",
+	}
+	if got[0].Connection != want || got[0].Password != "hunter2" {
+		t.Errorf("prod = %+v", got[0])
+	}
+	if c := got[1].Connection; c.Host != "localhost" || c.Port != 5432 || c.Database != "scratch" || got[1].Password != "" {
+		t.Errorf("local = %+v", got[1])
+	}
+}
+
+func TestParseMyCnf(t *testing.T) {
+	in := `[client]
+user = root
+password = "top secret"
+
+[mysqld]
+port = 3307
+
+[mysql]
+database = app
+
+[client_staging]
+host = mysql.staging
+ssl_ca = /etc/ssl/ca.pem
+
+!includedir /etc/mysql/conf.d/
+`
+	got, err := Parse(SourceMyCnf, strings.NewReader(in), "~/.my.cnf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d candidates, want 2: %+v", len(got), got)
+	}
+
+	base := got[0]
+	if base.Connection.Name != "app@localhost" || base.Connection.Port != 3306 || base.Connection.Username != "root" || base.Password != "top secret" {
+		t.Errorf("base = %+v", base)
+	}
+
+	staging := got[1]
+	if staging.Connection.Name != "staging" || staging.Connection.Host != "mysql.staging" ||
+		staging.Connection.Username != "root" || staging.Connection.SSLCACertPath != "/etc/ssl/ca.pem" || staging.Password != "top secret" {
+		t.Errorf("staging = %+v", staging)
+	}
+}
+
+const dataSources = `{
+	"connections": {
+		"postgres-jdbc-1": {
+			"provider": "postgresql",
+			"driver": "postgres-jdbc",
+			"name": "Orders",
+			"read-only": true,
+			"configuration": {"host": "orders.db", "port": "5433", "database": "orders", "type": "prod"}
+		},
+		"mysql8-2": {
+			"provider": "mysql",
+			"driver": "mysql8",
+			"name": "Shop",
+			"configuration": {"host": "shop.db", "database": "shop", "user": "shop", "password": "plain", "type": "dev"}
+		},
+		"sqlite-3": {
+			"provider": "generic",
+			"driver": "sqlite_jdbc",
+			"name": "Local",
+			"configuration": {"url": "jdbc:sqlite:/tmp/local.db"}
+		},
+		"oracle-4": {
+			"provider": "oracle",
+			"name": "Legacy",
+			"configuration": {"host": "ora"}
+		}
+	}
+}`
+
+func TestParseDBeaver(t *testing.T) {
+	got, err := Parse(SourceDBeaver, strings.NewReader(dataSources), "data-sources.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d candidates, want 3 (oracle skipped)", len(got))
+	}
+
+	shop, orders, local := got[0].Connection, got[1].Connection, got[2].Connection
+	if shop.Type != models.MySQLType || shop.Port != 3306 || got[0].Password != "plain" || shop.Environment != models.EnvironmentDev {
+		t.Errorf("shop = %+v", got[0])
+	}
+	if local.Type != models.SQLiteType || local.Database != "/tmp/local.db" || local.Host != "" {
+		t.Errorf("local = %+v", local)
+	}
+	if orders.Name != "Orders" || orders.Port != 5433 || !orders.ReadOnly || orders.Environment != models.EnvironmentProd {
+		t.Errorf("orders = %+v", orders)
+	}
+}
+
+func TestParseDBeaverFileReadsCredentials(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data-sources.json")
+	if err := os.WriteFile(path, []byte(dataSources), 0600); err != nil {
+		t.Fatal(err)
+	}
+	creds := encryptDBeaverCredentials(t, `{"postgres-jdbc-1":{"#connection":{"user":"ops","password":"from-creds"}}}`)
+	if err := os.WriteFile(filepath.Join(dir, dbeaverCredentialsFile), creds, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseFile(SourceDBeaver, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := got[1]
+	if orders.Connection.Username != "ops" || orders.Password != "from-creds" {
+		t.Errorf("orders = %+v", orders)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, dbeaverCredentialsFile), []byte("not encrypted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = ParseFile(SourceDBeaver, path); err != nil || len(got) != 3 || got[1].Password != "" {
+		t.Errorf("unreadable credentials: %d candidates, err %v", len(got), err)
+	}
+}
+
+func encryptDBeaverCredentials(t *testing.T, plain string) []byte {
+	t.Helper()
+	key, _ := hex.DecodeString(dbeaverCredentialsKey)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append([]byte(plain), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	iv := bytes.Repeat([]byte{7}, aes.BlockSize)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return append(iv, out...)
+}
+
+func TestParseSource(t *testing.T) {
+	for in, want := range map[string]Source{".pgpass": SourcePgpass, "pg_service": SourcePgService, "my.cnf": SourceMyCnf, "DBeaver": SourceDBeaver} {
+		if got, err := ParseSource(in); err != nil || got != want {
+			t.Errorf("ParseSource(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseSource("toad"); !errors.Is(err, ErrUnknownSource) {
+		t.Errorf("error = %v, want ErrUnknownSource", err)
+	}
+}
+
+func TestDiscoverUsesLibpqVariables(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	pgpass := filepath.Join(dir, "custom-pgpass")
+	if err := os.WriteFile(pgpass, []byte("h:5432:db:u:p\nh:5432:db:v:q\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PGPASSFILE", pgpass)
+
+	got, errs := Discover(SourcePgpass, SourceMyCnf)
+	if len(errs) != 0 {
+		t.Fatalf("errors: %v", errs)
+	}
+	if len(got) != 2 || got[0].Connection.Name != "db@h" || got[1].Connection.Name != "db@h-2" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+type fakeWorkspace struct {
+	conns  []models.Connection
+	addErr error
+}
+
+func (w *fakeWorkspace) GetConnectionByName(name string) *models.Connection {
+	for i := range w.conns {
+		if w.conns[i].Name == name {
+			return &w.conns[i]
+		}
+	}
+	return nil
+}
+
+func (w *fakeWorkspace) AddConnection(conn models.Connection) error {
+	if w.addErr != nil {
+		return w.addErr
+	}
+	w.conns = append(w.conns, conn)
+	return nil
+}
+
+type mapSecrets map[string]string
+
+func (m mapSecrets) StoreSecret(keyID, secret string) error { m[keyID] = secret; return nil }
+func (m mapSecrets) DeleteSecret(keyID string) error        { delete(m, keyID); return nil }
+func (m mapSecrets) RetrieveSecret(keyID string) (string, error) {
+	return m[keyID], nil
+}
+
+func TestImport(t *testing.T) {
+	ws := &fakeWorkspace{conns: []models.Connection{{Name: "existing", Type: models.MySQLType}}}
+	secrets := mapSecrets{}
+
+	result := Import(ws, secrets, []Candidate{
+		{Connection: models.Connection{Name: "prod", Type: models.PostgresType}, Password: "pw"},
+		{Connection: models.Connection{Name: "nopass", Type: models.SQLiteType}},
+		{Connection: models.Connection{Name: "existing", Type: models.MySQLType}, Password: "other"},
+	})
+
+	if len(result.Imported) != 2 || len(result.Skipped) != 1 || len(result.Failed) != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	prod := ws.GetConnectionByName("prod")
+	if prod.SecretKeyID != "dbsmith_prod_postgres" || secrets[prod.SecretKeyID] != "pw" {
+		t.Errorf("prod = %+v, secrets %v", prod, secrets)
+	}
+	if ws.GetConnectionByName("nopass").SecretKeyID != "" {
+		t.Error("connection without a password got a secret key")
+	}
+	if _, stored := secrets["dbsmith_existing_mysql"]; stored {
+		t.Error("skipped connection's password was stored")
+	}
+}
+
+func TestImportRemovesSecretWhenAddFails(t *testing.T) {
+	ws := &fakeWorkspace{addErr: errors.New("disk full")}
+	secrets := mapSecrets{}
+
+	result := Import(ws, secrets, []Candidate{
+		{Connection: models.Connection{Name: "prod", Type: models.PostgresType}, Password: "pw"},
+	})
+
+	if len(result.Imported) != 0 || result.Failed["prod"] == nil {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(secrets) != 0 {
+		t.Errorf("secrets = %v, want the password removed", secrets)
+	}
+}
@@ -0,0 +1,83 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// iniSection is one [group] of an INI-style file such as pg_service.conf or
+// .my.cnf, with keys lower-cased.
+type iniSection struct {
+	name   string
+	line   int
+	values map[string]string
+}
+
+func (s *iniSection) get(keys ...string) string {
+	for _, k := range keys {
+		if v, ok := s.values[k]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseINI reads sections from r. Keys before the first section header are
+// an error; "!include" directives and comments starting with # or ; are
+// skipped. normalizeKey, when set, is applied to every key.
+func parseINI(r io.Reader, normalizeKey func(string) string) ([]*iniSection, error) {
+	var sections []*iniSection
+	var current *iniSection
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || line[0] == '#' || line[0] == ';' || line[0] == '!' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated section header", lineNo)
+			}
+			current = &iniSection{
+				name:   strings.TrimSpace(line[1:end]),
+				line:   lineNo,
+				values: make(map[string]string),
+			}
+			sections = append(sections, current)
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("line %d: option outside of a section", lineNo)
+		}
+
+		key, value, _ := strings.Cut(line, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if normalizeKey != nil {
+			key = normalizeKey(key)
+		}
+		value = unquote(strings.TrimSpace(value))
+		current.values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+const defaultMySQLPort = 3306
+
+// parseMyCnf reads the client option groups of a MySQL option file. [client]
+// and [mysql] together describe the default connection; suffixed groups such
+// as [client_prod] (used with --defaults-group-suffix) each describe another
+// one, inheriting [client] for anything they leave out. Server groups are
+// ignored.
+func parseMyCnf(r io.Reader, origin string) ([]Candidate, error) {
+	sections, err := parseINI(r, func(key string) string {
+		return strings.ReplaceAll(key, "_", "-")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	base := &iniSection{values: make(map[string]string)}
+	var groups []*iniSection
+	bySuffix := make(map[string]*iniSection)
+	for _, s := range sections {
+		name := strings.ToLower(s.name)
+		if name == "client" || name == "mysql" {
+			mergeSection(base, s)
+			continue
+		}
+
+		suffix, ok := myCnfSuffix(name)
+		if !ok {
+			continue
+		}
+		g, seen := bySuffix[suffix]
+		if !seen {
+			g = &iniSection{name: suffix, values: make(map[string]string)}
+			bySuffix[suffix] = g
+			groups = append(groups, g)
+		}
+		mergeSection(g, s)
+	}
+
+	var candidates []Candidate
+	if base.line != 0 && base.get("host", "user", "password", "database") != "" {
+		base.name = "client"
+		c, err := myCnfCandidate(base, nil, origin)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	for _, g := range groups {
+		c, err := myCnfCandidate(g, base, origin)
+		if err != nil {
+			return nil, err
+		}
+		c.Connection.Name = g.name
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+func myCnfCandidate(s, defaults *iniSection, origin string) (Candidate, error) {
+	get := func(keys ...string) string {
+		if v := s.get(keys...); v != "" || defaults == nil {
+			return v
+		}
+		return defaults.get(keys...)
+	}
+
+	conn := models.Connection{
+		Type:          models.MySQLType,
+		Host:          orDefault(get("host"), "localhost"),
+		Port:          defaultMySQLPort,
+		Database:      get("database"),
+		Username:      get("user"),
+		SSLCACertPath: get("ssl-ca"),
+	}
+	if port := get("port"); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return Candidate{}, fmt.Errorf("[%s]: invalid port %q", s.name, port)
+		}
+		conn.Port = p
+	}
+	conn.Name = "mysql@" + conn.Host
+	if conn.Database != "" {
+		conn.Name = conn.Database + "@" + conn.Host
+	}
+
+	return Candidate{
+		Connection: conn,
+		Password:   get("password"),
+		Source:     SourceMyCnf,
+		Origin:     fmt.Sprintf("%s [%s]", origin, s.name),
+	}, nil
+}
+
+// myCnfSuffix returns the suffix of a group such as [client_prod] or
+// [mysql-staging].
+func myCnfSuffix(group string) (string, bool) {
+	for _, prefix := range []string{"client", "mysql"} {
+		rest, ok := strings.CutPrefix(group, prefix)
+		if ok && len(rest) > 1 && (rest[0] == '_' || rest[0] == '-') {
+			return rest[1:], true
+		}
+	}
+	return "", false
+}
+
+func mergeSection(dst, src *iniSection) {
+	if dst.line == 0 {
+		dst.line = src.line
+	}
+	for k, v := range src.values {
+		dst.values[k] = v
+	}
+}
@@ -0,0 +1,140 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+const defaultPostgresPort = 5432
+
+// parsePgpass reads a libpq password file: one
+// hostname:port:database:username:password entry per line, with \: and \\
+// escapes. A * field matches anything, so it imports as the libpq default.
+func parsePgpass(r io.Reader, origin string) ([]Candidate, error) {
+	var candidates []Candidate
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := splitPgpass(line)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("line %d: expected 5 colon-separated fields, got %d", lineNo, len(fields))
+		}
+		host, port, database, user := wildcard(fields[0]), wildcard(fields[1]), wildcard(fields[2]), wildcard(fields[3])
+
+		conn := models.Connection{
+			Type:     models.PostgresType,
+			Host:     orDefault(host, "localhost"),
+			Port:     defaultPostgresPort,
+			Database: orDefault(database, "postgres"),
+			Username: user,
+		}
+		if port != "" {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid port %q", lineNo, port)
+			}
+			conn.Port = p
+		}
+		conn.Name = postgresName(conn)
+
+		candidates = append(candidates, Candidate{
+			Connection: conn,
+			Password:   fields[4],
+			Source:     SourcePgpass,
+			Origin:     fmt.Sprintf("%s:%d", origin, lineNo),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+func splitPgpass(line string) []string {
+	var fields []string
+	var sb strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			sb.WriteByte(line[i])
+		case c == ':':
+			fields = append(fields, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return append(fields, sb.String())
+}
+
+func wildcard(field string) string {
+	if field == "*" {
+		return ""
+	}
+	return field
+}
+
+// parsePgService reads a libpq connection service file; each [service]
+// section becomes a connection named after the service.
+func parsePgService(r io.Reader, origin string) ([]Candidate, error) {
+	sections, err := parseINI(r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]Candidate, 0, len(sections))
+	for _, s := range sections {
+		conn := models.Connection{
+			Name:          s.name,
+			Type:          models.PostgresType,
+			Host:          orDefault(s.get("host", "hostaddr"), "localhost"),
+			Port:          defaultPostgresPort,
+			Database:      orDefault(s.get("dbname"), "postgres"),
+			Username:      s.get("user"),
+			SSL:           s.get("sslmode"),
+			SSLCACertPath: s.get("sslrootcert"),
+		}
+		if port := s.get("port"); port != "" {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: invalid port %q", s.name, port)
+			}
+			conn.Port = p
+		}
+
+		candidates = append(candidates, Candidate{
+			Connection: conn,
+			Password:   s.get("password"),
+			Source:     SourcePgService,
+			Origin:     fmt.Sprintf("%s [%s]", origin, s.name),
+		})
+	}
+	return candidates, nil
+}
+
+func postgresName(conn models.Connection) string {
+	name := conn.Database + "@" + conn.Host
+	if conn.Port != defaultPostgresPort {
+		name += ":" + strconv.Itoa(conn.Port)
+	}
+	return name
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,245 @@
+package keymap
+
+import "github.com/gdamore/tcell/v2"
+
+const killRingMax = 60
+
+type emacsCommand int
+
+const (
+	emacsOther emacsCommand = iota
+	emacsKill
+	emacsYank
+	emacsVertical
+)
+
+// Emacs is a keymap with Emacs movement, a kill ring and a mark. Keys it
+// does not bind, including plain typing, are left to the editor.
+type Emacs struct {
+	ring      []string
+	ringIndex int
+	yankStart int
+	yankEnd   int
+
+	// point is the cursor while the region is active, since the buffer's
+	// cursor is then the end of the selection.
+	mark       int
+	markSet    bool
+	markActive bool
+	point      int
+
+	prefix  bool
+	last    emacsCommand
+	goalCol int
+}
+
+// NewEmacs returns an Emacs keymap.
+func NewEmacs() *Emacs {
+	return &Emacs{}
+}
+
+func (m *Emacs) Mode() string {
+	if m.prefix {
+		return "EMACS C-x-"
+	}
+	return "EMACS"
+}
+
+func (m *Emacs) HandleKey(buf Buffer, event *tcell.EventKey) Result {
+	last := m.last
+	m.last = emacsOther
+	if m.prefix {
+		m.prefix = false
+		return m.handlePrefix(buf, event)
+	}
+
+	text := buf.Text()
+	point := m.cursor(buf, text)
+
+	if event.Modifiers()&tcell.ModAlt != 0 {
+		switch event.Key() {
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			m.kill(buf, text, backwardWord(text, point), point, last, true)
+			return handled
+		case tcell.KeyRune:
+		default:
+			return Result{}
+		}
+		switch event.Rune() {
+		case 'f':
+			m.move(buf, forwardWord(text, point))
+		case 'b':
+			m.move(buf, backwardWord(text, point))
+		case 'd':
+			m.kill(buf, text, point, forwardWord(text, point), last, false)
+		case '<':
+			m.move(buf, 0)
+		case '>':
+			m.move(buf, len(text))
+		case 'y':
+			m.yankPop(buf, last)
+		case 'q':
+			return Result{Handled: true, Action: ActionFormat}
+		default:
+			return Result{}
+		}
+		return handled
+	}
+
+	switch event.Key() {
+	case tcell.KeyCtrlA:
+		m.move(buf, lineStart(text, point))
+	case tcell.KeyCtrlE:
+		m.move(buf, lineEnd(text, point))
+	case tcell.KeyCtrlF:
+		m.move(buf, nextPos(text, point))
+	case tcell.KeyCtrlB:
+		m.move(buf, prevPos(text, point))
+	case tcell.KeyCtrlN, tcell.KeyCtrlP:
+		if last != emacsVertical {
+			m.goalCol = column(text, point)
+		}
+		line := lineIndex(text, point)
+		if event.Key() == tcell.KeyCtrlN {
+			line++
+		} else {
+			line--
+		}
+		if line >= 0 && line <= lineIndex(text, len(text)) {
+			m.move(buf, atColumn(text, lineAt(text, line), m.goalCol))
+		}
+		m.last = emacsVertical
+	case tcell.KeyCtrlD:
+		m.deactivate(buf)
+		buf.Replace(point, nextPos(text, point), "")
+	case tcell.KeyCtrlK:
+		end := lineEnd(text, point)
+		if end == point {
+			end = nextPos(text, point)
+		}
+		m.kill(buf, text, point, end, last, false)
+	case tcell.KeyCtrlW:
+		if m.markSet {
+			mark := min(m.mark, len(text))
+			m.kill(buf, text, min(mark, point), max(mark, point), last, mark < point)
+		} else {
+			m.kill(buf, text, backwardWord(text, point), point, last, true)
+		}
+	case tcell.KeyCtrlY:
+		m.yank(buf, point)
+	case tcell.KeyCtrlSpace:
+		m.mark, m.markSet, m.markActive, m.point = point, true, true, point
+		buf.Select(point, point)
+	case tcell.KeyCtrlG:
+		m.deactivate(buf)
+	case tcell.KeyCtrlX:
+		m.prefix = true
+	case tcell.KeyCtrlUnderscore:
+		m.deactivate(buf)
+		buf.Undo()
+	default:
+		m.deactivate(buf)
+		return Result{}
+	}
+	return handled
+}
+
+// handlePrefix handles the key after C-x. Unbound keys are swallowed.
+func (m *Emacs) handlePrefix(buf Buffer, event *tcell.EventKey) Result {
+	text := buf.Text()
+	switch event.Key() {
+	case tcell.KeyCtrlS:
+		return Result{Handled: true, Action: ActionSave}
+	case tcell.KeyCtrlX:
+		if m.markSet {
+			point := m.cursor(buf, text)
+			m.mark, m.point, m.markActive = point, min(m.mark, len(text)), true
+			buf.Select(m.mark, m.point)
+		}
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'u':
+			m.deactivate(buf)
+			buf.Undo()
+		case 'h':
+			m.mark, m.markSet, m.markActive, m.point = len(text), true, true, 0
+			buf.Select(0, len(text))
+		}
+	}
+	return handled
+}
+
+func (m *Emacs) cursor(buf Buffer, text string) int {
+	if m.markActive {
+		return min(m.point, len(text))
+	}
+	return min(max(buf.Cursor(), 0), len(text))
+}
+
+// move moves point, extending the region while the mark is active.
+func (m *Emacs) move(buf Buffer, pos int) {
+	if m.markActive {
+		m.point = pos
+		buf.Select(m.mark, pos)
+		return
+	}
+	buf.Select(pos, pos)
+}
+
+func (m *Emacs) deactivate(buf Buffer) {
+	if m.markActive {
+		m.markActive = false
+		buf.Select(m.point, m.point)
+	}
+}
+
+// kill deletes start..end onto the kill ring. Consecutive kills add to the
+// same entry, in front of it for backward kills.
+func (m *Emacs) kill(buf Buffer, text string, start, end int, last emacsCommand, backward bool) {
+	m.markActive = false
+	killed := text[start:end]
+	buf.Replace(start, end, "")
+	m.last = emacsKill
+
+	if last == emacsKill && len(m.ring) > 0 {
+		top := len(m.ring) - 1
+		if backward {
+			m.ring[top] = killed + m.ring[top]
+		} else {
+			m.ring[top] += killed
+		}
+		return
+	}
+	if killed == "" {
+		return
+	}
+	m.ring = append(m.ring, killed)
+	if len(m.ring) > killRingMax {
+		m.ring = m.ring[1:]
+	}
+}
+
+// yank inserts the latest kill at point and sets the mark before it.
+func (m *Emacs) yank(buf Buffer, point int) {
+	m.deactivate(buf)
+	if len(m.ring) == 0 {
+		return
+	}
+	m.ringIndex = len(m.ring) - 1
+	buf.Replace(point, point, m.ring[m.ringIndex])
+	m.mark, m.markSet = point, true
+	m.yankStart, m.yankEnd = point, point+len(m.ring[m.ringIndex])
+	m.last = emacsYank
+}
+
+// yankPop replaces the text just yanked with the kill before it, cycling
+// through the ring. It does nothing unless the last command was a yank.
+func (m *Emacs) yankPop(buf Buffer, last emacsCommand) {
+	if last != emacsYank || len(m.ring) == 0 || m.yankEnd > len(buf.Text()) {
+		return
+	}
+	m.ringIndex = (m.ringIndex - 1 + len(m.ring)) % len(m.ring)
+	buf.Replace(m.yankStart, m.yankEnd, m.ring[m.ringIndex])
+	m.yankEnd = m.yankStart + len(m.ring[m.ringIndex])
+	m.last = emacsYank
+}
@@ -0,0 +1,96 @@
+package keymap
+
+import "testing"
+
+func TestEmacsEditing(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		keys  string
+		want  string
+	}{
+		{"C-a C-e", "SELECT |1\nx", "<C-a>", "|SELECT 1\nx"},
+		{"C-e", "SELECT |1\nx", "<C-e>", "SELECT 1|\nx"},
+		{"C-f C-b", "SEL|ECT", "<C-f><C-f><C-b>", "SELE|CT"},
+		{"C-n keeps column", "abc|d\nx\nefgh", "<C-n><C-n>", "abcd\nx\nefg|h"},
+		{"C-p", "ab\nc|d", "<C-p>", "a|b\ncd"},
+		{"M-f", "|SELECT user_id, name", "<M-f><M-f>", "SELECT user_id|, name"},
+		{"M-b", "SELECT user_id, |name", "<M-b>", "SELECT |user_id, name"},
+		{"M-< M->", "a\n|b\nc", "<M-<>", "|a\nb\nc"},
+		{"M->", "a\n|b\nc", "<M->>", "a\nb\nc|"},
+		{"C-d", "SE|LECT", "<C-d>", "SE|ECT"},
+		{"C-k", "SELECT |1 FROM t\nx", "<C-k>", "SELECT |\nx"},
+		{"C-k at end kills newline", "a|\nb", "<C-k>", "a|b"},
+		{"C-k C-y", "SELECT |1\nx", "<C-k><C-e><C-f><C-e><C-y>", "SELECT \nx1|"},
+		{"consecutive kills append", "|a\nb\nc", "<C-k><C-k><C-k><C-y><C-y>", "a\nba\nb|\nc"},
+		{"M-d", "|SELECT id", "<M-d><M-d>", "|"},
+		{"M-BS", "SELECT id|", "<M-BS>", "SELECT |"},
+		{"backward kills prepend", "a b c|", "<M-BS><M-BS><C-y>", "a b c|"},
+		{"C-w without mark", "SELECT id|", "<C-w>", "SELECT |"},
+		{"C-w region", "SELECT |id FROM t", "<C-Space><M-f><M-f><C-w>", "SELECT | t"},
+		{"region kill yank", "|ab cd", "<C-Space><C-f><C-f><C-w><C-e><C-y>", " cdab|"},
+		{"M-y cycles", "|a b", "<M-d><C-f><M-d><C-y><M-y>", " a|"},
+		{"M-y needs yank", "|a b", "<M-d><M-y>", "| b"},
+		{"C-x u", "SE|LECT", "<C-d><C-x>u", "SE|LECT"},
+		{"C-_", "SE|LECT", "<C-d><C-_>", "SE|LECT"},
+		{"typing is left to the editor", "SE|LECT", "xy", "SExy|LECT"},
+		{"C-g cancels region", "|ab", "<C-Space><C-f><C-g>x", "ax|b"},
+		{"C-x C-x", "ab|cd", "<C-Space><C-f><C-f><C-x><C-x><C-w>", "ab|"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := newTestBuffer(tt.start)
+			feed(t, NewEmacs(), buf, tt.keys)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("%q on %q = %q, want %q", tt.keys, tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmacsRegion(t *testing.T) {
+	buf := newTestBuffer("SELECT |id FROM t")
+	emacs := NewEmacs()
+	feed(t, emacs, buf, "<C-Space><M-f>")
+	if got := buf.selection(); got != "id" {
+		t.Errorf("region = %q, want %q", got, "id")
+	}
+	feed(t, emacs, buf, "<C-x>h")
+	if got := buf.selection(); got != buf.text {
+		t.Errorf("C-x h region = %q, want the whole buffer", got)
+	}
+}
+
+func TestEmacsKillRingLimit(t *testing.T) {
+	emacs := NewEmacs()
+	buf := newTestBuffer("|")
+	for i := 0; i < killRingMax+5; i++ {
+		feed(t, emacs, buf, "ab<M-BS><C-f>")
+	}
+	if got := len(emacs.ring); got != killRingMax {
+		t.Errorf("kill ring holds %d entries, want %d", got, killRingMax)
+	}
+}
+
+func TestEmacsActions(t *testing.T) {
+	emacs := NewEmacs()
+	buf := newTestBuffer("|SELECT 1")
+	feed(t, emacs, buf, "<C-x>")
+	if got := emacs.Mode(); got != "EMACS C-x-" {
+		t.Errorf("Mode() = %q, want %q", got, "EMACS C-x-")
+	}
+	if got := feed(t, emacs, buf, "<C-s>"); got != ActionSave {
+		t.Errorf("C-x C-s action = %v, want ActionSave", got)
+	}
+	if got := feed(t, emacs, buf, "<M-q>"); got != ActionFormat {
+		t.Errorf("M-q action = %v, want ActionFormat", got)
+	}
+	if got := emacs.Mode(); got != "EMACS" {
+		t.Errorf("Mode() = %q, want EMACS", got)
+	}
+	for _, keys := range []string{"<F5>", "<Tab>", "<M-t>", "<CR>"} {
+		if emacs.HandleKey(buf, parseKeys(t, keys)[0]).Handled {
+			t.Errorf("%s was handled, want it left to the editor", keys)
+		}
+	}
+}
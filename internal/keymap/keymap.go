@@ -0,0 +1,70 @@
+// Package keymap implements the Vim and Emacs key bindings of the SQL
+// editor on top of a plain text buffer.
+package keymap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const (
+	NameDefault = "default"
+	NameVim     = "vim"
+	NameEmacs   = "emacs"
+)
+
+// Buffer is the text a keymap edits. Offsets are byte offsets into Text.
+type Buffer interface {
+	Text() string
+	// Cursor returns the cursor offset, or the end of the selection when
+	// there is one.
+	Cursor() int
+	// Replace replaces start..end with text and leaves the cursor after it.
+	Replace(start, end int, text string)
+	// Select selects start..end, or places the cursor when they are equal.
+	Select(start, end int)
+	Undo()
+	Redo()
+}
+
+// Action is something beyond editing that a key asks the editor to do.
+type Action int
+
+const (
+	ActionNone Action = iota
+	ActionSave
+	ActionFormat
+)
+
+// Result is the outcome of a key. Keys that are not handled get the
+// editor's default behavior.
+type Result struct {
+	Handled bool
+	Action  Action
+}
+
+// Keymap interprets keys for a buffer.
+type Keymap interface {
+	HandleKey(buf Buffer, event *tcell.EventKey) Result
+	// Mode describes the keymap's state for the editor title, such as
+	// "NORMAL" or "INSERT".
+	Mode() string
+}
+
+// New returns the keymap called name, or nil for the default bindings.
+func New(name string) (Keymap, error) {
+	switch strings.ToLower(name) {
+	case "", NameDefault:
+		return nil, nil
+	case NameVim:
+		return NewVim(), nil
+	case NameEmacs:
+		return NewEmacs(), nil
+	default:
+		return nil, fmt.Errorf("unknown keymap %q (want default, vim or emacs)", name)
+	}
+}
+
+var handled = Result{Handled: true}
@@ -0,0 +1,165 @@
+package keymap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// testBuffer is an in-memory Buffer with an undo history.
+type testBuffer struct {
+	text          string
+	anchor, point int
+	undo, redo    []string
+}
+
+// newTestBuffer returns a buffer holding s, with the cursor at the '|' in
+// it or at the start.
+func newTestBuffer(s string) *testBuffer {
+	pos := max(strings.IndexByte(s, '|'), 0)
+	text := strings.Replace(s, "|", "", 1)
+	return &testBuffer{text: text, anchor: pos, point: pos}
+}
+
+func (b *testBuffer) Text() string { return b.text }
+func (b *testBuffer) Cursor() int  { return b.point }
+
+func (b *testBuffer) Replace(start, end int, text string) {
+	b.undo = append(b.undo, b.text)
+	b.redo = nil
+	b.text = b.text[:start] + text + b.text[end:]
+	b.anchor = start + len(text)
+	b.point = b.anchor
+}
+
+func (b *testBuffer) Select(start, end int) {
+	b.anchor, b.point = start, end
+}
+
+func (b *testBuffer) Undo() {
+	if len(b.undo) > 0 {
+		b.redo = append(b.redo, b.text)
+		b.text = b.undo[len(b.undo)-1]
+		b.undo = b.undo[:len(b.undo)-1]
+		b.anchor, b.point = min(b.anchor, len(b.text)), min(b.point, len(b.text))
+	}
+}
+
+func (b *testBuffer) Redo() {
+	if len(b.redo) > 0 {
+		b.undo = append(b.undo, b.text)
+		b.text = b.redo[len(b.redo)-1]
+		b.redo = b.redo[:len(b.redo)-1]
+		b.anchor, b.point = min(b.anchor, len(b.text)), min(b.point, len(b.text))
+	}
+}
+
+// String returns the text with '|' at the cursor.
+func (b *testBuffer) String() string {
+	return b.text[:b.point] + "|" + b.text[b.point:]
+}
+
+// selection returns the selected text.
+func (b *testBuffer) selection() string {
+	return b.text[min(b.anchor, b.point):max(b.anchor, b.point)]
+}
+
+var namedKeys = map[string]tcell.Key{
+	"Esc":     tcell.KeyEscape,
+	"CR":      tcell.KeyEnter,
+	"BS":      tcell.KeyBackspace2,
+	"Tab":     tcell.KeyTab,
+	"Left":    tcell.KeyLeft,
+	"Right":   tcell.KeyRight,
+	"Up":      tcell.KeyUp,
+	"Down":    tcell.KeyDown,
+	"C-Space": tcell.KeyCtrlSpace,
+	"C-_":     tcell.KeyCtrlUnderscore,
+	"F5":      tcell.KeyF5,
+}
+
+// parseKeys turns keys such as "dw<Esc><C-r><M-f>" into events.
+func parseKeys(t *testing.T, keys string) []*tcell.EventKey {
+	t.Helper()
+	var events []*tcell.EventKey
+	for keys != "" {
+		if strings.HasPrefix(keys, "<M->>") {
+			events = append(events, tcell.NewEventKey(tcell.KeyRune, '>', tcell.ModAlt))
+			keys = keys[len("<M->>"):]
+			continue
+		}
+		if keys[0] == '<' {
+			if end := strings.IndexByte(keys, '>'); end > 1 {
+				name := keys[1:end]
+				keys = keys[end+1:]
+				named, ok := namedKeys[name]
+				switch {
+				case ok:
+					events = append(events, tcell.NewEventKey(named, 0, tcell.ModNone))
+				case strings.HasPrefix(name, "C-") && len(name) == 3:
+					key := tcell.KeyCtrlA + tcell.Key(name[2]-'a')
+					events = append(events, tcell.NewEventKey(key, 0, tcell.ModCtrl))
+				case strings.HasPrefix(name, "M-BS"):
+					events = append(events, tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModAlt))
+				case strings.HasPrefix(name, "M-") && len(name) == 3:
+					events = append(events, tcell.NewEventKey(tcell.KeyRune, rune(name[2]), tcell.ModAlt))
+				default:
+					t.Fatalf("unknown key <%s>", name)
+				}
+				continue
+			}
+		}
+		events = append(events, tcell.NewEventKey(tcell.KeyRune, rune(keys[0]), tcell.ModNone))
+		keys = keys[1:]
+	}
+	return events
+}
+
+// feed sends keys to km. Keys it leaves to the editor are typed into the
+// buffer. It returns the last action asked for.
+func feed(t *testing.T, km Keymap, buf *testBuffer, keys string) Action {
+	t.Helper()
+	action := ActionNone
+	for _, event := range parseKeys(t, keys) {
+		result := km.HandleKey(buf, event)
+		if result.Action != ActionNone {
+			action = result.Action
+		}
+		if result.Handled {
+			continue
+		}
+		start, end := min(buf.anchor, buf.point), max(buf.anchor, buf.point)
+		switch event.Key() {
+		case tcell.KeyRune:
+			buf.Replace(start, end, string(event.Rune()))
+		case tcell.KeyEnter:
+			buf.Replace(start, end, "\n")
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if start == end && start > 0 {
+				start = prevPos(buf.text, start)
+			}
+			buf.Replace(start, end, "")
+		}
+	}
+	return action
+}
+
+func TestNew(t *testing.T) {
+	for name, want := range map[string]string{"": "", "default": "", "vim": "NORMAL", "Emacs": "EMACS"} {
+		km, err := New(name)
+		if err != nil {
+			t.Fatalf("New(%q): %v", name, err)
+		}
+		got := ""
+		if km != nil {
+			got = km.Mode()
+		}
+		if got != want {
+			t.Errorf("New(%q) mode = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := New("nano"); err == nil {
+		t.Error("New(\"nano\") succeeded, want error")
+	}
+}
@@ -0,0 +1,321 @@
+package keymap
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+func lineStart(text string, pos int) int {
+	return strings.LastIndexByte(text[:pos], '\n') + 1
+}
+
+func lineEnd(text string, pos int) int {
+	if i := strings.IndexByte(text[pos:], '\n'); i >= 0 {
+		return pos + i
+	}
+	return len(text)
+}
+
+// lastChar returns the offset of the last character on pos's line, or the
+// line start when the line is empty.
+func lastChar(text string, pos int) int {
+	start, end := lineStart(text, pos), lineEnd(text, pos)
+	if end == start {
+		return start
+	}
+	return prevPos(text, end)
+}
+
+func firstNonBlank(text string, pos int) int {
+	p, end := lineStart(text, pos), lineEnd(text, pos)
+	for p < end && (text[p] == ' ' || text[p] == '\t') {
+		p++
+	}
+	return p
+}
+
+func nextPos(text string, pos int) int {
+	if pos >= len(text) {
+		return len(text)
+	}
+	_, size := utf8.DecodeRuneInString(text[pos:])
+	return pos + size
+}
+
+func prevPos(text string, pos int) int {
+	if pos <= 0 {
+		return 0
+	}
+	_, size := utf8.DecodeLastRuneInString(text[:pos])
+	return pos - size
+}
+
+func runeAt(text string, pos int) rune {
+	if pos >= len(text) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(text[pos:])
+	return r
+}
+
+// lineIndex returns the zero-based line pos is on.
+func lineIndex(text string, pos int) int {
+	return strings.Count(text[:pos], "\n")
+}
+
+// lineAt returns the start of line n, clamped to the lines of text.
+func lineAt(text string, n int) int {
+	pos := 0
+	for i := 0; i < n; i++ {
+		next := strings.IndexByte(text[pos:], '\n')
+		if next < 0 {
+			break
+		}
+		pos += next + 1
+	}
+	return pos
+}
+
+// column returns the rune column of pos on its line.
+func column(text string, pos int) int {
+	return utf8.RuneCountInString(text[lineStart(text, pos):pos])
+}
+
+// atColumn returns the offset of rune column col on the line starting at
+// start, or its end when the line is shorter.
+func atColumn(text string, start, col int) int {
+	pos, end := start, lineEnd(text, start)
+	for i := 0; i < col && pos < end; i++ {
+		pos = nextPos(text, pos)
+	}
+	return pos
+}
+
+// charClass groups characters for word motions: 0 is white space, 1 word
+// characters and 2 punctuation. With bigWord everything that is not white
+// space is one class, as for Vim's WORDs.
+func charClass(r rune, bigWord bool) int {
+	switch {
+	case r == 0 || unicode.IsSpace(r):
+		return 0
+	case bigWord || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isEmptyLine(text string, pos int) bool {
+	return pos < len(text) && text[pos] == '\n' && (pos == 0 || text[pos-1] == '\n')
+}
+
+// nextWordStart moves to the start of the next word, stopping at empty
+// lines as Vim's w does.
+func nextWordStart(text string, pos int, bigWord bool) int {
+	if pos >= len(text) {
+		return len(text)
+	}
+	class := charClass(runeAt(text, pos), bigWord)
+	if class != 0 {
+		for pos < len(text) && charClass(runeAt(text, pos), bigWord) == class {
+			pos = nextPos(text, pos)
+		}
+	}
+	for pos < len(text) && charClass(runeAt(text, pos), bigWord) == 0 {
+		if text[pos] == '\n' && isEmptyLine(text, pos+1) {
+			return pos + 1
+		}
+		pos = nextPos(text, pos)
+	}
+	return pos
+}
+
+// wordEnd moves to the last character of the current or next word.
+func wordEnd(text string, pos int, bigWord bool) int {
+	pos = nextPos(text, pos)
+	for pos < len(text) && charClass(runeAt(text, pos), bigWord) == 0 {
+		pos = nextPos(text, pos)
+	}
+	if pos >= len(text) {
+		return prevPos(text, len(text))
+	}
+	class := charClass(runeAt(text, pos), bigWord)
+	for {
+		next := nextPos(text, pos)
+		if next >= len(text) || charClass(runeAt(text, next), bigWord) != class {
+			return pos
+		}
+		pos = next
+	}
+}
+
+// prevWordStart moves to the start of the current or previous word.
+func prevWordStart(text string, pos int, bigWord bool) int {
+	pos = prevPos(text, pos)
+	for pos > 0 && charClass(runeAt(text, pos), bigWord) == 0 && !isEmptyLine(text, pos) {
+		pos = prevPos(text, pos)
+	}
+	if isEmptyLine(text, pos) {
+		return pos
+	}
+	class := charClass(runeAt(text, pos), bigWord)
+	for pos > 0 {
+		prev := prevPos(text, pos)
+		if charClass(runeAt(text, prev), bigWord) != class {
+			break
+		}
+		pos = prev
+	}
+	return pos
+}
+
+// forwardWord moves past the end of the next word, as Emacs's M-f does.
+func forwardWord(text string, pos int) int {
+	for pos < len(text) && !isWordRune(runeAt(text, pos)) {
+		pos = nextPos(text, pos)
+	}
+	for pos < len(text) && isWordRune(runeAt(text, pos)) {
+		pos = nextPos(text, pos)
+	}
+	return pos
+}
+
+// backwardWord moves to the start of the previous word, as Emacs's M-b
+// does.
+func backwardWord(text string, pos int) int {
+	for pos > 0 && !isWordRune(runeAt(text, prevPos(text, pos))) {
+		pos = prevPos(text, pos)
+	}
+	for pos > 0 && isWordRune(runeAt(text, prevPos(text, pos))) {
+		pos = prevPos(text, pos)
+	}
+	return pos
+}
+
+// wordObject returns the span of the word, or run of white space, under pos
+// on its line. With around, white space after the word is included, or
+// before it when there is none after.
+func wordObject(text string, pos int, bigWord, around bool) (int, int, bool) {
+	start, end := lineStart(text, pos), lineEnd(text, pos)
+	if start == end {
+		return 0, 0, false
+	}
+	class := charClass(runeAt(text, pos), bigWord)
+	from, to := pos, nextPos(text, pos)
+	for from > start && charClass(runeAt(text, prevPos(text, from)), bigWord) == class {
+		from = prevPos(text, from)
+	}
+	for to < end && charClass(runeAt(text, to), bigWord) == class {
+		to = nextPos(text, to)
+	}
+	if !around {
+		return from, to, true
+	}
+
+	if class == 0 {
+		for to < end && charClass(runeAt(text, to), bigWord) != 0 {
+			to = nextPos(text, to)
+		}
+		return from, to, true
+	}
+	trailing := to
+	for trailing < end && charClass(runeAt(text, trailing), bigWord) == 0 {
+		trailing = nextPos(text, trailing)
+	}
+	if trailing > to {
+		return from, trailing, true
+	}
+	for from > start && charClass(runeAt(text, prevPos(text, from)), bigWord) == 0 {
+		from = prevPos(text, from)
+	}
+	return from, to, true
+}
+
+// quoteObject returns the span of the quote-delimited string on pos's line
+// that contains pos, or the first one after it. Without around only the
+// inside is returned.
+func quoteObject(text string, pos int, quote byte, around bool) (int, int, bool) {
+	start, end := lineStart(text, pos), lineEnd(text, pos)
+	var quotes []int
+	for i := start; i < end; i++ {
+		if text[i] == quote {
+			quotes = append(quotes, i)
+		}
+	}
+	for i := 0; i+1 < len(quotes); i += 2 {
+		open, close := quotes[i], quotes[i+1]
+		if pos <= close {
+			if around {
+				return open, close + 1, true
+			}
+			return open + 1, close, true
+		}
+	}
+	return 0, 0, false
+}
+
+// bracketObject returns the span of the innermost open..close pair around
+// pos. Without around only the inside is returned.
+func bracketObject(text string, pos int, open, close byte, around bool) (int, int, bool) {
+	from := -1
+	depth := 0
+	for i := min(pos, len(text)-1); i >= 0; i-- {
+		switch {
+		case text[i] == close && i != pos:
+			depth++
+		case text[i] == open:
+			if depth == 0 {
+				from = i
+			} else {
+				depth--
+			}
+		}
+		if from >= 0 {
+			break
+		}
+	}
+	if from < 0 {
+		return 0, 0, false
+	}
+	to, ok := matchBracket(text, from)
+	if !ok {
+		return 0, 0, false
+	}
+	if around {
+		return from, to + 1, true
+	}
+	return from + 1, to, true
+}
+
+var bracketPairs = map[byte]byte{'(': ')', '[': ']', '{': '}', ')': '(', ']': '[', '}': '{'}
+
+// matchBracket returns the bracket matching the one at pos.
+func matchBracket(text string, pos int) (int, bool) {
+	open := text[pos]
+	close, ok := bracketPairs[open]
+	if !ok {
+		return 0, false
+	}
+	step := 1
+	if open == ')' || open == ']' || open == '}' {
+		step = -1
+	}
+	depth := 0
+	for i := pos; i >= 0 && i < len(text); i += step {
+		switch text[i] {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,946 @@
+package keymap
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+type vimMode int
+
+const (
+	vimNormal vimMode = iota
+	vimInsert
+	vimVisual
+	vimVisualLine
+	vimCommandLine
+)
+
+// keyRedo stands for Ctrl-R in a command.
+const keyRedo = '\x12'
+
+const maxCount = 99999
+
+type motionKind int
+
+const (
+	exclusive motionKind = iota
+	inclusive
+	linewise
+)
+
+// vimCommand is a parsed normal or visual mode command. For an operator,
+// op is d, c or y and key is the motion, the operator again for a whole
+// line, or i or a for a text object. arg is the character after f, t, r, i
+// and a. count is 0 when none was given.
+type vimCommand struct {
+	register rune
+	count    int
+	op       rune
+	key      rune
+	arg      rune
+}
+
+type parseState int
+
+const (
+	parseDone parseState = iota
+	parsePending
+	parseInvalid
+)
+
+type register struct {
+	text     string
+	linewise bool
+}
+
+// vimChange is the last change, repeated by '.'. text is what was typed
+// when the change entered insert mode.
+type vimChange struct {
+	cmd  vimCommand
+	text string
+}
+
+// insertState tracks an insert so what was typed can be repeated.
+type insertState struct {
+	cmd        vimCommand
+	repeatable bool
+	start      int
+	before     string
+	count      int
+}
+
+// Vim is a modal keymap with normal, insert, visual and command-line
+// modes. Typing in insert mode is left to the editor; the keymap only works
+// out what was typed when insert mode ends.
+type Vim struct {
+	mode       vimMode
+	keys       []rune
+	command    []rune
+	registers  map[rune]register
+	anchor     int
+	cursor     int
+	wantCol    int
+	wantSet    bool
+	lastFind   vimCommand
+	lastChange *vimChange
+	insert     *insertState
+}
+
+// NewVim returns a Vim keymap in normal mode.
+func NewVim() *Vim {
+	return &Vim{registers: map[rune]register{}}
+}
+
+func (v *Vim) Mode() string {
+	pending := ""
+	if len(v.keys) > 0 {
+		pending = " " + string(v.keys)
+	}
+	switch v.mode {
+	case vimInsert:
+		return "INSERT"
+	case vimVisual:
+		return "VISUAL" + pending
+	case vimVisualLine:
+		return "VISUAL LINE" + pending
+	case vimCommandLine:
+		return ":" + string(v.command)
+	default:
+		return "NORMAL" + pending
+	}
+}
+
+func (v *Vim) HandleKey(buf Buffer, event *tcell.EventKey) Result {
+	switch v.mode {
+	case vimInsert:
+		if event.Key() == tcell.KeyEscape {
+			v.finishInsert(buf)
+			return handled
+		}
+		return Result{}
+	case vimCommandLine:
+		return v.handleCommandLine(buf, event)
+	}
+
+	if event.Key() == tcell.KeyEscape {
+		switch {
+		case v.mode != vimNormal:
+			v.keys = nil
+			v.exitVisual(buf)
+		case len(v.keys) > 0:
+			v.keys = nil
+		default:
+			return Result{}
+		}
+		return handled
+	}
+
+	r, ok := vimKey(event)
+	if !ok {
+		return Result{}
+	}
+	v.keys = append(v.keys, r)
+	cmd, state := parseVimCommand(v.keys, v.mode != vimNormal)
+	switch state {
+	case parsePending:
+		return handled
+	case parseInvalid:
+		v.keys = nil
+		return handled
+	}
+	v.keys = nil
+
+	if v.mode == vimNormal {
+		return v.execute(buf, cmd)
+	}
+	return v.executeVisual(buf, cmd)
+}
+
+// vimKey turns an event into a command character. Keys with no meaning in
+// normal mode, such as function keys, are left to the editor.
+func vimKey(event *tcell.EventKey) (rune, bool) {
+	switch event.Key() {
+	case tcell.KeyRune:
+		if event.Modifiers()&(tcell.ModAlt|tcell.ModCtrl) != 0 {
+			return 0, false
+		}
+		return event.Rune(), true
+	case tcell.KeyLeft, tcell.KeyBackspace, tcell.KeyBackspace2:
+		return 'h', true
+	case tcell.KeyRight:
+		return 'l', true
+	case tcell.KeyUp:
+		return 'k', true
+	case tcell.KeyDown:
+		return 'j', true
+	case tcell.KeyHome:
+		return '0', true
+	case tcell.KeyEnd:
+		return '$', true
+	case tcell.KeyDelete:
+		return 'x', true
+	case tcell.KeyEnter:
+		if event.Modifiers()&tcell.ModShift != 0 {
+			return 0, false
+		}
+		return '+', true
+	case tcell.KeyCtrlR:
+		return keyRedo, true
+	default:
+		return 0, false
+	}
+}
+
+func isMotion(key rune) bool {
+	return strings.ContainsRune("hjklwbeWBE0^$G;,%+-fFtTg", key)
+}
+
+func isRegister(name rune) bool {
+	return name >= 'a' && name <= 'z' || name >= 'A' && name <= 'Z' || name >= '0' && name <= '9' || strings.ContainsRune(`"-_`, name)
+}
+
+// parseVimCommand parses the keys typed so far. In visual mode operators
+// apply to the selection, so they take no motion.
+func parseVimCommand(keys []rune, visual bool) (vimCommand, parseState) {
+	var c vimCommand
+	i := 0
+	if keys[0] == '"' {
+		if len(keys) < 2 {
+			return c, parsePending
+		}
+		if !isRegister(keys[1]) {
+			return c, parseInvalid
+		}
+		c.register = keys[1]
+		i = 2
+	}
+
+	count, i := parseCount(keys, i)
+	if i >= len(keys) {
+		return c, parsePending
+	}
+	c.key = keys[i]
+	i++
+
+	if !visual && (c.key == 'd' || c.key == 'c' || c.key == 'y') {
+		c.op = c.key
+		var motionCount int
+		motionCount, i = parseCount(keys, i)
+		if count > 0 || motionCount > 0 {
+			count = min(max(count, 1)*max(motionCount, 1), maxCount)
+		}
+		if i >= len(keys) {
+			return c, parsePending
+		}
+		c.key = keys[i]
+		i++
+	}
+	c.count = count
+
+	object := c.key == 'i' || c.key == 'a'
+	needsArg := strings.ContainsRune("fFtTg", c.key) ||
+		c.key == 'r' && c.op == 0 ||
+		object && (c.op != 0 || visual)
+	if needsArg {
+		if i >= len(keys) {
+			return c, parsePending
+		}
+		c.arg = keys[i]
+		if c.key == 'g' && c.arg != 'g' {
+			return c, parseInvalid
+		}
+	}
+
+	if c.op != 0 && c.key != c.op && !object && !isMotion(c.key) {
+		return c, parseInvalid
+	}
+	return c, parseDone
+}
+
+func parseCount(keys []rune, i int) (int, int) {
+	n := 0
+	for i < len(keys) && keys[i] >= '0' && keys[i] <= '9' && (n > 0 || keys[i] != '0') {
+		n = min(n*10+int(keys[i]-'0'), maxCount)
+		i++
+	}
+	return n, i
+}
+
+// vimAliases are commands that are shorthand for an operator.
+var vimAliases = map[rune]vimCommand{
+	'x': {op: 'd', key: 'l'},
+	'X': {op: 'd', key: 'h'},
+	'D': {op: 'd', key: '$'},
+	'C': {op: 'c', key: '$'},
+	's': {op: 'c', key: 'l'},
+	'S': {op: 'c', key: 'c'},
+	'Y': {op: 'y', key: 'y'},
+}
+
+func (v *Vim) execute(buf Buffer, c vimCommand) Result {
+	if alias, ok := vimAliases[c.key]; ok && c.op == 0 {
+		alias.register, alias.count = c.register, c.count
+		c = alias
+	}
+	if c.op != 0 || !isMotion(c.key) {
+		v.wantSet = false
+	}
+	if c.op != 0 {
+		v.operate(buf, c)
+		return handled
+	}
+
+	text := buf.Text()
+	pos := v.normalCursor(buf, text)
+	n := max(c.count, 1)
+
+	switch c.key {
+	case 'i':
+		v.startInsert(buf, c, pos, n)
+	case 'a':
+		if lineEnd(text, pos) > pos {
+			pos = nextPos(text, pos)
+		}
+		v.startInsert(buf, c, pos, n)
+	case 'I':
+		v.startInsert(buf, c, firstNonBlank(text, pos), n)
+	case 'A':
+		v.startInsert(buf, c, lineEnd(text, pos), n)
+	case 'o':
+		end := lineEnd(text, pos)
+		buf.Replace(end, end, "\n")
+		v.startInsert(buf, c, end+1, 1)
+	case 'O':
+		start := lineStart(text, pos)
+		buf.Replace(start, start, "\n")
+		v.startInsert(buf, c, start, 1)
+	case 'p', 'P':
+		v.put(buf, text, pos, c)
+	case 'J':
+		v.join(buf, pos, max(c.count, 2)-1)
+		v.lastChange = &vimChange{cmd: c}
+	case '~':
+		end := pos
+		for i := 0; i < n && end < lineEnd(text, pos); i++ {
+			end = nextPos(text, end)
+		}
+		buf.Replace(pos, end, toggleCase(text[pos:end]))
+		v.moveTo(buf, buf.Text(), end)
+		v.lastChange = &vimChange{cmd: c}
+	case 'r':
+		end := pos
+		for i := 0; i < n; i++ {
+			if end >= lineEnd(text, pos) {
+				return handled
+			}
+			end = nextPos(text, end)
+		}
+		replacement := strings.Repeat(string(c.arg), n)
+		buf.Replace(pos, end, replacement)
+		v.moveTo(buf, buf.Text(), prevPos(buf.Text(), pos+len(replacement)))
+		v.lastChange = &vimChange{cmd: c}
+	case 'u':
+		for i := 0; i < n; i++ {
+			buf.Undo()
+		}
+		v.moveTo(buf, buf.Text(), buf.Cursor())
+	case keyRedo:
+		for i := 0; i < n; i++ {
+			buf.Redo()
+		}
+		v.moveTo(buf, buf.Text(), buf.Cursor())
+	case '.':
+		v.repeat(buf, c.count)
+	case 'v', 'V':
+		v.mode = vimVisual
+		if c.key == 'V' {
+			v.mode = vimVisualLine
+		}
+		v.anchor, v.cursor = pos, pos
+		v.showSelection(buf)
+	case ':':
+		v.mode = vimCommandLine
+		v.command = nil
+	default:
+		if target, _, ok := v.motion(text, pos, c); ok {
+			v.moveTo(buf, text, target)
+			v.updateWantCol(text, target, c.key)
+		}
+	}
+	return handled
+}
+
+// normalCursor returns the cursor, kept on a character as in normal mode.
+func (v *Vim) normalCursor(buf Buffer, text string) int {
+	pos := min(max(buf.Cursor(), 0), len(text))
+	return min(pos, lastChar(text, pos))
+}
+
+func (v *Vim) moveTo(buf Buffer, text string, pos int) {
+	pos = min(max(pos, 0), len(text))
+	pos = min(pos, lastChar(text, pos))
+	buf.Select(pos, pos)
+}
+
+// updateWantCol records the column j and k aim for after a motion. It is
+// kept across j and k so they return to it past shorter lines.
+func (v *Vim) updateWantCol(text string, pos int, key rune) {
+	v.wantSet = true
+	switch key {
+	case 'j', 'k':
+	case '$':
+		v.wantCol = -1
+	default:
+		v.wantCol = column(text, pos)
+	}
+}
+
+// motion returns where a motion from pos ends and how an operator applies
+// to it. It reports false when the motion cannot be made.
+func (v *Vim) motion(text string, pos int, c vimCommand) (int, motionKind, bool) {
+	n := max(c.count, 1)
+	lines := strings.Count(text, "\n")
+
+	switch c.key {
+	case 'h':
+		start := lineStart(text, pos)
+		for i := 0; i < n && pos > start; i++ {
+			pos = prevPos(text, pos)
+		}
+		return pos, exclusive, true
+	case 'l':
+		end := lineEnd(text, pos)
+		for i := 0; i < n && pos < end; i++ {
+			pos = nextPos(text, pos)
+		}
+		return pos, exclusive, true
+	case 'j', 'k', '+', '-':
+		line := lineIndex(text, pos)
+		if c.key == 'j' || c.key == '+' {
+			line += n
+		} else {
+			line -= n
+		}
+		if line < 0 || line > lines {
+			return pos, linewise, false
+		}
+		start := lineAt(text, line)
+		if c.key == '+' || c.key == '-' {
+			return firstNonBlank(text, start), linewise, true
+		}
+		if !v.wantSet {
+			v.wantCol = column(text, pos)
+		}
+		if v.wantCol < 0 {
+			return lastChar(text, start), linewise, true
+		}
+		return atColumn(text, start, v.wantCol), linewise, true
+	case 'w', 'W':
+		for i := 0; i < n; i++ {
+			pos = nextWordStart(text, pos, c.key == 'W')
+		}
+		return pos, exclusive, true
+	case 'b', 'B':
+		for i := 0; i < n; i++ {
+			pos = prevWordStart(text, pos, c.key == 'B')
+		}
+		return pos, exclusive, true
+	case 'e', 'E':
+		for i := 0; i < n; i++ {
+			pos = wordEnd(text, pos, c.key == 'E')
+		}
+		return pos, inclusive, true
+	case '0':
+		return lineStart(text, pos), exclusive, true
+	case '^':
+		return firstNonBlank(text, pos), exclusive, true
+	case '$':
+		line := lineIndex(text, pos) + n - 1
+		if line > lines {
+			return pos, exclusive, false
+		}
+		return lineEnd(text, lineAt(text, line)), exclusive, true
+	case 'G', 'g':
+		line := lines
+		if c.key == 'g' {
+			line = 0
+		}
+		if c.count > 0 {
+			line = min(c.count-1, lines)
+		}
+		return firstNonBlank(text, lineAt(text, line)), linewise, true
+	case 'f', 'F', 't', 'T':
+		v.lastFind = vimCommand{key: c.key, arg: c.arg}
+		return find(text, pos, c.key, c.arg, n)
+	case ';', ',':
+		key := v.lastFind.key
+		if key == 0 {
+			return pos, exclusive, false
+		}
+		if c.key == ',' {
+			key = map[rune]rune{'f': 'F', 'F': 'f', 't': 'T', 'T': 't'}[key]
+		}
+		return find(text, pos, key, v.lastFind.arg, n)
+	case '%':
+		for p := pos; p < lineEnd(text, pos); p++ {
+			if _, ok := bracketPairs[text[p]]; ok {
+				match, found := matchBracket(text, p)
+				return match, inclusive, found
+			}
+		}
+		return pos, inclusive, false
+	default:
+		return pos, exclusive, false
+	}
+}
+
+// find searches pos's line for the n-th ch, as f, t, F and T do.
+func find(text string, pos int, key, ch rune, n int) (int, motionKind, bool) {
+	start, end := lineStart(text, pos), lineEnd(text, pos)
+	p := pos
+	if key == 'f' || key == 't' {
+		for i := 0; i < n; i++ {
+			p = nextPos(text, p)
+			for p < end && runeAt(text, p) != ch {
+				p = nextPos(text, p)
+			}
+			if p >= end {
+				return pos, inclusive, false
+			}
+		}
+		if key == 't' {
+			p = prevPos(text, p)
+		}
+		return p, inclusive, true
+	}
+
+	for i := 0; i < n; i++ {
+		for {
+			if p <= start {
+				return pos, exclusive, false
+			}
+			p = prevPos(text, p)
+			if runeAt(text, p) == ch {
+				break
+			}
+		}
+	}
+	if key == 'T' {
+		p = nextPos(text, p)
+	}
+	return p, exclusive, true
+}
+
+func textObject(text string, pos int, object rune, around bool) (int, int, bool) {
+	switch object {
+	case 'w', 'W':
+		return wordObject(text, pos, object == 'W', around)
+	case '"', '\'', '`':
+		return quoteObject(text, pos, byte(object), around)
+	case '(', ')', 'b':
+		return bracketObject(text, pos, '(', ')', around)
+	case '[', ']':
+		return bracketObject(text, pos, '[', ']', around)
+	case '{', '}', 'B':
+		return bracketObject(text, pos, '{', '}', around)
+	default:
+		return 0, 0, false
+	}
+}
+
+func (v *Vim) operate(buf Buffer, c vimCommand) {
+	text := buf.Text()
+	pos := v.normalCursor(buf, text)
+
+	var start, end int
+	lines := false
+	switch {
+	case c.key == c.op:
+		last := min(lineIndex(text, pos)+max(c.count, 1)-1, strings.Count(text, "\n"))
+		start, end, lines = lineStart(text, pos), lineEnd(text, lineAt(text, last)), true
+	case c.key == 'i' || c.key == 'a':
+		var ok bool
+		if start, end, ok = textObject(text, pos, c.arg, c.key == 'a'); !ok {
+			return
+		}
+	case c.op == 'c' && (c.key == 'w' || c.key == 'W') && charClass(runeAt(text, pos), c.key == 'W') != 0:
+		// cw changes to the end of the word, as ce does.
+		bigWord := c.key == 'W'
+		target := pos
+		for i := 0; i < max(c.count, 1); i++ {
+			if i > 0 || !atWordEnd(text, target, bigWord) {
+				target = wordEnd(text, target, bigWord)
+			}
+		}
+		start, end = pos, nextPos(text, target)
+	default:
+		target, kind, ok := v.motion(text, pos, c)
+		if !ok {
+			return
+		}
+		if (c.key == 'w' || c.key == 'W') && target > lineEnd(text, pos) {
+			// A word motion does not take the operator past the line.
+			target = lineEnd(text, pos)
+		}
+		start, end = min(pos, target), max(pos, target)
+		switch kind {
+		case inclusive:
+			end = nextPos(text, end)
+		case linewise:
+			start, end, lines = lineStart(text, start), lineEnd(text, end), true
+		}
+	}
+
+	v.applyOperator(buf, text, c, start, end, lines)
+}
+
+func atWordEnd(text string, pos int, bigWord bool) bool {
+	next := nextPos(text, pos)
+	return next >= len(text) || charClass(runeAt(text, next), bigWord) != charClass(runeAt(text, pos), bigWord)
+}
+
+// applyOperator applies c's operator to start..end. For whole lines end is
+// the end of the last line, before its newline.
+func (v *Vim) applyOperator(buf Buffer, text string, c vimCommand, start, end int, lines bool) {
+	reg := register{text: text[start:end], linewise: lines}
+	if lines {
+		reg.text += "\n"
+	}
+	repeatable := v.mode == vimNormal
+
+	switch c.op {
+	case 'y':
+		v.store(c.register, reg, true)
+		if !lines {
+			v.moveTo(buf, text, start)
+		}
+	case 'd':
+		v.store(c.register, reg, false)
+		if lines {
+			if end < len(text) {
+				end++
+			} else if start > 0 {
+				start--
+			}
+		}
+		buf.Replace(start, end, "")
+		text = buf.Text()
+		if lines {
+			start = firstNonBlank(text, min(start, len(text)))
+		}
+		v.moveTo(buf, text, start)
+		if repeatable {
+			v.lastChange = &vimChange{cmd: c}
+		}
+	case 'c':
+		v.store(c.register, reg, false)
+		buf.Replace(start, end, "")
+		v.startInsert(buf, c, start, 1)
+	}
+}
+
+// store saves text deleted or yanked into the register named name, or the
+// unnamed register. Uppercase names append to their register and _
+// discards the text. As in Vim, yanks are also kept in 0, deletes of lines
+// in 1 to 9 and smaller deletes in -.
+func (v *Vim) store(name rune, reg register, yank bool) {
+	switch {
+	case name == '_':
+		return
+	case name >= 'A' && name <= 'Z':
+		lower := unicode.ToLower(name)
+		prev := v.registers[lower]
+		reg = register{text: prev.text + reg.text, linewise: prev.linewise || reg.linewise}
+		v.registers[lower] = reg
+	case name != 0 && name != '"':
+		v.registers[name] = reg
+	case yank:
+		v.registers['0'] = reg
+	case reg.linewise || strings.Contains(reg.text, "\n"):
+		for i := '9'; i > '1'; i-- {
+			if prev, ok := v.registers[i-1]; ok {
+				v.registers[i] = prev
+			}
+		}
+		v.registers['1'] = reg
+	default:
+		v.registers['-'] = reg
+	}
+	v.registers['"'] = reg
+}
+
+func (v *Vim) load(name rune) (register, bool) {
+	if name == 0 {
+		name = '"'
+	}
+	reg, ok := v.registers[unicode.ToLower(name)]
+	return reg, ok && reg.text != ""
+}
+
+func (v *Vim) put(buf Buffer, text string, pos int, c vimCommand) {
+	reg, ok := v.load(c.register)
+	if !ok {
+		return
+	}
+	body := strings.Repeat(reg.text, max(c.count, 1))
+
+	if reg.linewise {
+		var at, first int
+		switch {
+		case c.key == 'P':
+			at = lineStart(text, pos)
+			first = at
+		case lineEnd(text, pos) == len(text):
+			at = len(text)
+			body = "\n" + strings.TrimSuffix(body, "\n")
+			first = at + 1
+		default:
+			at = lineEnd(text, pos) + 1
+			first = at
+		}
+		buf.Replace(at, at, body)
+		text = buf.Text()
+		v.moveTo(buf, text, firstNonBlank(text, first))
+	} else {
+		at := pos
+		if c.key == 'p' && lineEnd(text, pos) > pos {
+			at = nextPos(text, pos)
+		}
+		buf.Replace(at, at, body)
+		text = buf.Text()
+		v.moveTo(buf, text, prevPos(text, at+len(body)))
+	}
+	v.lastChange = &vimChange{cmd: c}
+}
+
+// join joins n lines after pos's line onto it, separated by a space.
+func (v *Vim) join(buf Buffer, pos, n int) {
+	at := pos
+	for i := 0; i < n; i++ {
+		text := buf.Text()
+		end := lineEnd(text, at)
+		if end >= len(text) {
+			break
+		}
+		next := end + 1
+		for next < len(text) && (text[next] == ' ' || text[next] == '\t') {
+			next++
+		}
+		sep := " "
+		if next >= len(text) || text[next] == '\n' || text[next] == ')' ||
+			end == lineStart(text, end) || text[end-1] == ' ' || text[end-1] == '\t' {
+			sep = ""
+		}
+		buf.Replace(end, next, sep)
+		at = end
+	}
+	v.moveTo(buf, buf.Text(), at)
+}
+
+func toggleCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+func (v *Vim) startInsert(buf Buffer, c vimCommand, at, count int) {
+	repeatable := v.mode == vimNormal
+	buf.Select(at, at)
+	v.mode = vimInsert
+	v.insert = &insertState{cmd: c, repeatable: repeatable, start: at, before: buf.Text(), count: count}
+}
+
+// finishInsert leaves insert mode, repeating what was typed for a count
+// and remembering it for '.'.
+func (v *Vim) finishInsert(buf Buffer) {
+	ins := v.insert
+	v.insert = nil
+	v.mode = vimNormal
+
+	typed := insertedText(ins.before, buf.Text(), ins.start)
+	if typed != "" && ins.count > 1 {
+		cursor := buf.Cursor()
+		buf.Replace(cursor, cursor, strings.Repeat(typed, ins.count-1))
+	}
+	if ins.repeatable {
+		v.lastChange = &vimChange{cmd: ins.cmd, text: typed}
+	}
+
+	text := buf.Text()
+	pos := min(buf.Cursor(), len(text))
+	if pos > lineStart(text, pos) {
+		pos = prevPos(text, pos)
+	}
+	v.moveTo(buf, text, pos)
+}
+
+// insertedText returns what was typed at start to turn before into after,
+// or "" when the edit was not a plain insertion there.
+func insertedText(before, after string, start int) string {
+	suffix := before[start:]
+	if len(after) < len(before) || !strings.HasPrefix(after, before[:start]) || !strings.HasSuffix(after, suffix) {
+		return ""
+	}
+	return after[start : len(after)-len(suffix)]
+}
+
+// repeat runs the last change again, with count in place of its own when
+// one is given.
+func (v *Vim) repeat(buf Buffer, count int) {
+	if v.lastChange == nil {
+		return
+	}
+	change := *v.lastChange
+	if count > 0 {
+		change.cmd.count = count
+	}
+
+	v.execute(buf, change.cmd)
+	if v.mode == vimInsert {
+		cursor := buf.Cursor()
+		buf.Replace(cursor, cursor, change.text)
+		v.finishInsert(buf)
+	}
+	v.lastChange = &change
+}
+
+func (v *Vim) executeVisual(buf Buffer, c vimCommand) Result {
+	text := buf.Text()
+	start, end, lines := v.selection(text)
+
+	switch c.key {
+	case 'v', 'V':
+		mode := vimVisual
+		if c.key == 'V' {
+			mode = vimVisualLine
+		}
+		if v.mode == mode {
+			v.exitVisual(buf)
+			return handled
+		}
+		v.mode = mode
+	case 'o':
+		v.anchor, v.cursor = v.cursor, v.anchor
+	case 'i', 'a':
+		if from, to, ok := textObject(text, v.cursor, c.arg, c.key == 'a'); ok && to > from {
+			v.anchor, v.cursor = from, prevPos(text, to)
+		}
+	case 'd', 'x', 'D', 'X':
+		if c.key == 'D' || c.key == 'X' {
+			start, end, lines = lineStart(text, start), lineEnd(text, end), true
+		}
+		v.applyOperator(buf, text, vimCommand{register: c.register, op: 'd'}, start, end, lines)
+		v.mode = vimNormal
+		return handled
+	case 'y', 'Y':
+		if c.key == 'Y' {
+			start, end, lines = lineStart(text, start), lineEnd(text, end), true
+		}
+		v.applyOperator(buf, text, vimCommand{register: c.register, op: 'y'}, start, end, lines)
+		v.mode = vimNormal
+		v.moveTo(buf, text, start)
+		return handled
+	case 'c', 's':
+		v.applyOperator(buf, text, vimCommand{register: c.register, op: 'c'}, start, end, lines)
+		return handled
+	case '~', 'u', 'U':
+		changed := text[start:end]
+		switch c.key {
+		case '~':
+			changed = toggleCase(changed)
+		case 'u':
+			changed = strings.ToLower(changed)
+		default:
+			changed = strings.ToUpper(changed)
+		}
+		buf.Replace(start, end, changed)
+		v.mode = vimNormal
+		v.moveTo(buf, buf.Text(), start)
+		return handled
+	case 'J':
+		v.mode = vimNormal
+		v.join(buf, start, max(lineIndex(text, end)-lineIndex(text, start), 1))
+		return handled
+	case 'p', 'P':
+		reg, ok := v.load(c.register)
+		if !ok {
+			return handled
+		}
+		v.store(0, register{text: text[start:end], linewise: lines}, false)
+		buf.Replace(start, end, strings.TrimSuffix(reg.text, "\n"))
+		v.mode = vimNormal
+		v.moveTo(buf, buf.Text(), start)
+		return handled
+	case ':':
+		return handled
+	default:
+		if target, _, ok := v.motion(text, v.cursor, c); ok {
+			v.cursor = min(target, lastChar(text, target))
+			v.updateWantCol(text, target, c.key)
+		}
+	}
+	v.showSelection(buf)
+	return handled
+}
+
+// selection returns the selected span. For whole lines end is the end of
+// the last line, before its newline.
+func (v *Vim) selection(text string) (int, int, bool) {
+	from, to := min(v.anchor, v.cursor), max(v.anchor, v.cursor)
+	from, to = min(from, len(text)), min(to, len(text))
+	if v.mode == vimVisualLine {
+		return lineStart(text, from), lineEnd(text, to), true
+	}
+	return from, nextPos(text, to), false
+}
+
+func (v *Vim) showSelection(buf Buffer) {
+	start, end, _ := v.selection(buf.Text())
+	buf.Select(start, end)
+}
+
+func (v *Vim) exitVisual(buf Buffer) {
+	v.mode = vimNormal
+	v.moveTo(buf, buf.Text(), v.cursor)
+}
+
+func (v *Vim) handleCommandLine(buf Buffer, event *tcell.EventKey) Result {
+	switch event.Key() {
+	case tcell.KeyEscape:
+		v.mode = vimNormal
+	case tcell.KeyEnter:
+		v.mode = vimNormal
+		return v.runCommandLine(buf, strings.TrimSpace(string(v.command)))
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(v.command) == 0 {
+			v.mode = vimNormal
+		} else {
+			v.command = v.command[:len(v.command)-1]
+		}
+	case tcell.KeyRune:
+		v.command = append(v.command, event.Rune())
+	default:
+		return Result{}
+	}
+	return handled
+}
+
+// runCommandLine runs an ex command. :w saves the query and :N goes to line
+// N; others are ignored.
+func (v *Vim) runCommandLine(buf Buffer, command string) Result {
+	switch command {
+	case "w", "write":
+		return Result{Handled: true, Action: ActionSave}
+	}
+	if n, err := strconv.Atoi(command); err == nil {
+		text := buf.Text()
+		v.moveTo(buf, text, firstNonBlank(text, lineAt(text, max(n, 1)-1)))
+	}
+	return handled
+}
@@ -0,0 +1,220 @@
+package keymap
+
+import "testing"
+
+func TestVimEditing(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		keys  string
+		want  string
+	}{
+		{"h l", "SEL|ECT", "hhl", "SE|LECT"},
+		{"l stops at line end", "|ab\ncd", "lll", "a|b\ncd"},
+		{"j keeps column", "ab|cd\nx\nefgh", "jj", "abcd\nx\nef|gh"},
+		{"$ then j", "a|b\nlonger", "$j", "ab\nlonge|r"},
+		{"w", "|SELECT id, name FROM t", "www", "SELECT id, |name FROM t"},
+		{"W", "|a.b c.d", "W", "a.b |c.d"},
+		{"b", "SELECT id FROM |t", "2b", "SELECT |id FROM t"},
+		{"e", "|SELECT id", "e", "SELEC|T id"},
+		{"0 ^ $", "  SELECT |1", "0", "|  SELECT 1"},
+		{"^", "  SELECT |1", "^", "  |SELECT 1"},
+		{"gg G", "a\n  b\n|c", "gg", "|a\n  b\nc"},
+		{"count G", "a\n  b\n|c", "2G", "a\n  |b\nc"},
+		{"f and ;", "|a,b,c,d", "f,;", "a,b|,c,d"},
+		{"F and ;", "a,b,c,|d", "F,;", "a,b|,c,d"},
+		{"f ; and ,", "|a,b,c", "f,;,", "a|,b,c"},
+		{"%", "|count(x(y))", "%", "count(x(y)|)"},
+		{"x", "SE|LECT", "x", "SE|ECT"},
+		{"count x", "SE|LECT", "3x", "SE|T"},
+		{"x at end of line", "ab|c\nd", "x", "a|b\nd"},
+		{"dw", "SELECT |id, name", "dw", "SELECT |, name"},
+		{"dw at end of line", "a |bc\nd", "dw", "a| \nd"},
+		{"d2w", "|one two three", "d2w", "|three"},
+		{"2dw", "|one two three", "2dw", "|three"},
+		{"de", "|one two", "de", "| two"},
+		{"d$", "SELECT |id FROM t", "d$", "SELECT| "},
+		{"D", "SELECT |id FROM t", "D", "SELECT| "},
+		{"dd", "a\n|b\nc", "dd", "a\n|c"},
+		{"dd last line", "a\n  b\n|c", "dd", "a\n  |b"},
+		{"3dd", "|a\nb\nc\nd", "3dd", "|d"},
+		{"dj", "|a\nb\nc", "dj", "|c"},
+		{"dG", "a\n|b\nc", "dG", "|a"},
+		{"dfx", "|abcxdef", "dfx", "|def"},
+		{"dt)", "count(|id, x)", "dt)", "count(|)"},
+		{"diw", "SELECT na|me FROM t", "diw", "SELECT | FROM t"},
+		{"daw", "SELECT na|me FROM t", "daw", "SELECT |FROM t"},
+		{"di(", "count(a, |b)", "di(", "count(|)"},
+		{"da(", "count(a, |b) + 1", "da(", "count| + 1"},
+		{"dib nested", "f(a, g(|b), c)", "dib", "f(a, g(|), c)"},
+		{"di'", "WHERE x = 'ab|c'", "di'", "WHERE x = '|'"},
+		{"da\"", `SELECT "co|l" FROM t`, `da"`, `SELECT | FROM t`},
+		{"ci(", "count(|x)", "ci(*<Esc>", "count(|*)"},
+		{"cw", "SELECT |id, name", "cwuser_id<Esc>", "SELECT user_i|d, name"},
+		{"cw on one letter", "|a b", "cwx<Esc>", "|x b"},
+		{"cc", "a\n|  b\nc", "ccz<Esc>", "a\n|z\nc"},
+		{"C", "SELECT |1", "C2<Esc>", "SELECT |2"},
+		{"s", "|abc", "sxy<Esc>", "x|ybc"},
+		{"S", "a\n|bcd", "Sx<Esc>", "a\n|x"},
+		{"r", "SEL|ECT", "rX", "SEL|XCT"},
+		{"count r", "SEL|ECT", "3rx", "SELxx|x"},
+		{"r past end", "SEL|ECT", "9rx", "SEL|ECT"},
+		{"~", "|select", "3~", "SEL|ect"},
+		{"J", "|SELECT\n  1", "J", "SELECT| 1"},
+		{"3J", "|a\nb\nc\nd", "3J", "a b| c\nd"},
+		{"i", "SEL|ECT", "ix<Esc>", "SEL|xECT"},
+		{"a", "SEL|ECT", "ax<Esc>", "SELE|xCT"},
+		{"I", "  SEL|ECT", "Ix<Esc>", "  |xSELECT"},
+		{"A", "SEL|ECT\nx", "A;<Esc>", "SELECT|;\nx"},
+		{"o", "|SELECT\n1", "oFROM t<Esc>", "SELECT\nFROM |t\n1"},
+		{"O", "SELECT\n|1", "O2,<Esc>", "SELECT\n2|,\n1"},
+		{"count i", "|", "3iab<Esc>", "ababa|b"},
+		{"insert backspace", "|", "iabc<BS><Esc>", "a|b"},
+		{"u", "SEL|ECT", "xxu", "SEL|CT"},
+		{"u and redo", "SEL|ECT", "xxuu<C-r>", "SEL|CT"},
+		{"yy p", "|a\nb", "yyp", "a\n|a\nb"},
+		{"yy p on last line", "a\n|b", "yyp", "a\nb\n|b"},
+		{"yy P", "a\n|b", "yyP", "a\n|b\nb"},
+		{"yw p", "|ab cd", "ywP", "ab| ab cd"},
+		{"x p swaps", "|ab", "xp", "b|a"},
+		{"dd p", "|a\nb", "ddp", "b\n|a"},
+		{"count p", "|a", "yl3p", "aaa|a"},
+		{".", "|a b c d", "dw.", "|c d"},
+		{". with count", "|abcdef", "x3.", "|ef"},
+		{". insert", "|a\nb", "A;<Esc>j.", "a;\nb|;"},
+		{". change word", "|foo bar", "cwx<Esc>w.", "x |x"},
+		{"escape clears pending", "|abc", "d<Esc>x", "|bc"},
+		{"unknown keys are swallowed", "|abc", "Q", "|abc"},
+		{"arrow keys", "a|bc\nd", "<Right><Down>", "abc\n|d"},
+		{"enter", "a|bc\n  d", "<CR>", "abc\n  |d"},
+		{":N", "|a\nb\n  c", ":3<CR>", "a\nb\n  |c"},
+		{": cancel", "|a", ":3<Esc>x", "|"},
+		{"multibyte", "|héllo", "lx", "h|llo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := newTestBuffer(tt.start)
+			feed(t, NewVim(), buf, tt.keys)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("%q on %q = %q, want %q", tt.keys, tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVimRegisters(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		keys  string
+		want  string
+	}{
+		{"named", "|a\nb", `"ayyj"ap`, "a\nb\n|a"},
+		{"append", "|a\nb", `"ayyj"Ayy"ap`, "a\nb\n|a\nb"},
+		{"yank kept in 0", "|a b", `yw"_dw"0P`, "a| b"},
+		{"black hole", "|a b", `yw"_dwP`, "a| b"},
+		{"deleted lines shift", "|a\nb\nc", `dddd"2p`, "c\n|a"},
+		{"small delete", "|ab", `x"-p`, "b|a"},
+		{"empty register", "|ab", `"zp`, "|ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := newTestBuffer(tt.start)
+			feed(t, NewVim(), buf, tt.keys)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("%q on %q = %q, want %q", tt.keys, tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVimVisual(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		keys  string
+		want  string
+	}{
+		{"v d", "SEL|ECT 1", "vlld", "SEL| 1"},
+		{"v e y P", "|id, name", "veyP", "i|did, name"},
+		{"v backwards", "SEL|ECT", "vhhd", "S|CT"},
+		{"v o", "a|bcd", "vlohd", "|d"},
+		{"V d", "a\n|b\nc", "Vjd", "|a"},
+		{"V y p", "|a\nb", "Vyjp", "a\nb\n|a"},
+		{"v iw", "SELECT na|me FROM", "viwd", "SELECT | FROM"},
+		{"v i(", "f(a, |b)", "vi(c<Esc>", "f|()"},
+		{"v U", "|select 1", "veU", "|SELECT 1"},
+		{"v ~", "|Ab", "vl~", "|aB"},
+		{"V J", "|a\nb\nc", "VjJ", "a| b\nc"},
+		{"v p replaces", "|one two", "yiwwviwp", "one |one"},
+		{"esc", "|abc", "vl<Esc>x", "a|c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := newTestBuffer(tt.start)
+			feed(t, NewVim(), buf, tt.keys)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("%q on %q = %q, want %q", tt.keys, tt.start, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVimSelectionIsShown(t *testing.T) {
+	buf := newTestBuffer("SELECT |id FROM t")
+	vim := NewVim()
+	feed(t, vim, buf, "ve")
+	if got := buf.selection(); got != "id" {
+		t.Errorf("selection = %q, want %q", got, "id")
+	}
+	if got := vim.Mode(); got != "VISUAL" {
+		t.Errorf("Mode() = %q, want VISUAL", got)
+	}
+}
+
+func TestVimModes(t *testing.T) {
+	vim := NewVim()
+	buf := newTestBuffer("|abc")
+	steps := []struct {
+		keys string
+		want string
+	}{
+		{"", "NORMAL"},
+		{"2d", "NORMAL 2d"},
+		{"<Esc>", "NORMAL"},
+		{"i", "INSERT"},
+		{"<Esc>V", "VISUAL LINE"},
+		{"<Esc>:w", ":w"},
+		{"<BS><BS>", "NORMAL"},
+	}
+	for _, step := range steps {
+		feed(t, vim, buf, step.keys)
+		if got := vim.Mode(); got != step.want {
+			t.Errorf("after %q Mode() = %q, want %q", step.keys, got, step.want)
+		}
+	}
+}
+
+func TestVimSave(t *testing.T) {
+	buf := newTestBuffer("|SELECT 1")
+	if got := feed(t, NewVim(), buf, ":w<CR>"); got != ActionSave {
+		t.Errorf(":w action = %v, want ActionSave", got)
+	}
+	if got := feed(t, NewVim(), buf, ":q<CR>"); got != ActionNone {
+		t.Errorf(":q action = %v, want ActionNone", got)
+	}
+}
+
+func TestVimPassesThrough(t *testing.T) {
+	vim := NewVim()
+	buf := newTestBuffer("|abc")
+	for _, keys := range []string{"<Esc>", "<F5>", "<Tab>", "<M-f>"} {
+		if vim.HandleKey(buf, parseKeys(t, keys)[0]).Handled {
+			t.Errorf("%s was handled in normal mode, want it left to the editor", keys)
+		}
+	}
+	feed(t, vim, buf, "i")
+	if vim.HandleKey(buf, parseKeys(t, "x")[0]).Handled {
+		t.Error("typing was handled in insert mode, want it left to the editor")
+	}
+}
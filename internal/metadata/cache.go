@@ -0,0 +1,376 @@
+package metadata
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+const (
+	DefaultWorkers = 4
+	// DefaultMaxAge is how old cached columns may get before the background
+	// load after connecting fetches them again.
+	DefaultMaxAge = 24 * time.Hour
+)
+
+// Source is where the cache reads metadata from; *explorer.Explorer
+// satisfies it.
+type Source interface {
+	GetSchemas(ctx context.Context) ([]models.Schema, error)
+	GetTables(ctx context.Context, schema models.Schema) ([]models.Table, error)
+	GetTableColumns(ctx context.Context, schemaName, tableName string) (*models.TableColumns, error)
+}
+
+// Options configures a Cache. Path is the file the cache is persisted to; an
+// empty Path keeps it in memory. Fingerprint identifies the connection the
+// file belongs to.
+type Options struct {
+	Path        string
+	Fingerprint string
+	Workers     int
+	MaxAge      time.Duration
+}
+
+// Cache holds the schemas, tables and columns of one connection. Lookups are
+// read-through: a miss is fetched from the source and kept. Start loads
+// everything in the background so later lookups are served from memory.
+type Cache struct {
+	source Source
+	opts   Options
+
+	mu            sync.Mutex
+	schemas       []models.Schema
+	schemasLoaded bool
+	tables        map[string][]models.Table
+	columns       map[string]columnsEntry
+	dirty         bool
+	cancel        context.CancelFunc
+	done          chan struct{}
+}
+
+type columnsEntry struct {
+	Schema  string               `json:"schema"`
+	Table   string               `json:"table"`
+	Columns *models.TableColumns `json:"columns"`
+	Loaded  time.Time            `json:"loaded"`
+}
+
+func New(source Source, opts Options) *Cache {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = DefaultMaxAge
+	}
+	return &Cache{
+		source:  source,
+		opts:    opts,
+		tables:  make(map[string][]models.Table),
+		columns: make(map[string]columnsEntry),
+	}
+}
+
+// Start loads the schemas and tables, and the columns not cached yet, in the
+// background. The cache is saved once the load finishes.
+func (c *Cache) Start(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		return
+	}
+
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	go c.run(ctx, c.done)
+}
+
+// Stop cancels a background load, waits for it and saves any changes.
+func (c *Cache) Stop() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.cancel, c.done = nil, nil
+	c.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	c.saveIfDirty()
+}
+
+func (c *Cache) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	start := time.Now()
+	if err := c.refresh(ctx, "", false); err != nil {
+		if ctx.Err() == nil {
+			logging.Warn().Err(err).Msg("Failed to load schema metadata")
+		}
+		return
+	}
+	logging.Debug().Dur("duration", time.Since(start)).Msg("Schema metadata loaded")
+	c.saveIfDirty()
+}
+
+// Schemas returns the schemas of the database. The result must not be
+// modified.
+func (c *Cache) Schemas(ctx context.Context) ([]models.Schema, error) {
+	c.mu.Lock()
+	if c.schemasLoaded {
+		schemas := c.schemas
+		c.mu.Unlock()
+		return schemas, nil
+	}
+	c.mu.Unlock()
+
+	return c.loadSchemas(ctx)
+}
+
+// Tables returns the tables of schema. The result must not be modified.
+func (c *Cache) Tables(ctx context.Context, schema string) ([]models.Table, error) {
+	c.mu.Lock()
+	tables, ok := c.tables[strings.ToUpper(schema)]
+	c.mu.Unlock()
+	if ok {
+		return tables, nil
+	}
+
+	return c.loadTables(ctx, schema)
+}
+
+// TableColumns returns the columns of schema.table.
+func (c *Cache) TableColumns(ctx context.Context, schema, table string) (*models.TableColumns, error) {
+	c.mu.Lock()
+	entry, ok := c.columns[tableKey(schema, table)]
+	c.mu.Unlock()
+	if ok {
+		return entry.Columns, nil
+	}
+
+	return c.loadColumns(ctx, schema, table)
+}
+
+// Refresh fetches schema again: its table list and the columns of every
+// table in it. An empty schema refreshes the whole database.
+func (c *Cache) Refresh(ctx context.Context, schema string) error {
+	if err := c.refresh(ctx, schema, true); err != nil {
+		return err
+	}
+	c.saveIfDirty()
+	return nil
+}
+
+// Invalidate drops what DDL on objects may have changed: the columns of each
+// named table or view and the table list of its schema. Objects are names as
+// written in SQL, optionally schema-qualified; unqualified names drop every
+// table list. Without objects the schema and table lists are dropped. The
+// dropped entries are fetched again on next use.
+func (c *Cache) Invalidate(objects ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dirty = true
+	if len(objects) == 0 {
+		c.schemas, c.schemasLoaded = nil, false
+		c.tables = make(map[string][]models.Table)
+		return
+	}
+
+	for _, object := range objects {
+		schema, name := splitObjectName(object)
+		if schema == "" {
+			c.tables = make(map[string][]models.Table)
+		} else {
+			delete(c.tables, strings.ToUpper(schema))
+		}
+		for key, entry := range c.columns {
+			if !strings.EqualFold(entry.Table, name) {
+				continue
+			}
+			if schema == "" || entry.Schema == "" || strings.EqualFold(entry.Schema, schema) {
+				delete(c.columns, key)
+			}
+		}
+	}
+}
+
+func (c *Cache) loadSchemas(ctx context.Context) ([]models.Schema, error) {
+	schemas, err := c.source.GetSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if schemas == nil {
+		schemas = []models.Schema{}
+	}
+
+	c.mu.Lock()
+	c.schemas, c.schemasLoaded = schemas, true
+	c.dirty = true
+	c.mu.Unlock()
+	return schemas, nil
+}
+
+// loadTables fetches the tables of schema and forgets the columns of tables
+// that no longer exist.
+func (c *Cache) loadTables(ctx context.Context, schema string) ([]models.Table, error) {
+	tables, err := c.source.GetTables(ctx, models.Schema{Name: schema})
+	if err != nil {
+		return nil, err
+	}
+	if tables == nil {
+		tables = []models.Table{}
+	}
+
+	present := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		present[strings.ToUpper(table.Name)] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tables[strings.ToUpper(schema)] = tables
+	for key, entry := range c.columns {
+		if strings.EqualFold(entry.Schema, schema) && !present[strings.ToUpper(entry.Table)] {
+			delete(c.columns, key)
+		}
+	}
+	c.dirty = true
+	return tables, nil
+}
+
+func (c *Cache) loadColumns(ctx context.Context, schema, table string) (*models.TableColumns, error) {
+	columns, err := c.source.GetTableColumns(ctx, schema, table)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.columns[tableKey(schema, table)] = columnsEntry{
+		Schema:  schema,
+		Table:   table,
+		Columns: columns,
+		Loaded:  time.Now(),
+	}
+	c.dirty = true
+	c.mu.Unlock()
+	return columns, nil
+}
+
+// refresh fetches the table lists of schema, or of every schema when it is
+// empty, and then the columns of their tables with bounded concurrency.
+// Unless all is set, columns cached within MaxAge are kept.
+func (c *Cache) refresh(ctx context.Context, schema string, all bool) error {
+	schemas := []models.Schema{{Name: schema}}
+	if schema == "" {
+		var err error
+		if schemas, err = c.loadSchemas(ctx); err != nil {
+			return err
+		}
+		if len(schemas) == 0 {
+			schemas = []models.Schema{{Name: ""}}
+		}
+	}
+
+	tablesBySchema := make([][]models.Table, len(schemas))
+	c.forEach(ctx, len(schemas), func(i int) {
+		tables, err := c.loadTables(ctx, schemas[i].Name)
+		if err != nil {
+			logging.Warn().Err(err).Str("schema", schemas[i].Name).Msg("Failed to load tables for metadata cache")
+			return
+		}
+		tablesBySchema[i] = tables
+	})
+
+	var pending []models.Table
+	for i, tables := range tablesBySchema {
+		for _, table := range tables {
+			if table.Schema == "" {
+				table.Schema = schemas[i].Name
+			}
+			if all || c.columnsStale(table.Schema, table.Name) {
+				pending = append(pending, table)
+			}
+		}
+	}
+
+	c.forEach(ctx, len(pending), func(i int) {
+		table := pending[i]
+		if _, err := c.loadColumns(ctx, table.Schema, table.Name); err != nil && ctx.Err() == nil {
+			logging.Warn().
+				Err(err).
+				Str("schema", table.Schema).
+				Str("table", table.Name).
+				Msg("Failed to load columns for metadata cache")
+		}
+	})
+	return ctx.Err()
+}
+
+func (c *Cache) columnsStale(schema, table string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.columns[tableKey(schema, table)]
+	return !ok || time.Since(entry.Loaded) >= c.opts.MaxAge
+}
+
+// forEach calls fn for 0..n-1 on at most Workers goroutines at a time,
+// stopping early when ctx is done.
+func (c *Cache) forEach(ctx context.Context, n int, fn func(i int)) {
+	sem := make(chan struct{}, c.opts.Workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for i := 0; i < n; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+}
+
+// tableKey is the case-insensitive key of schema.table.
+func tableKey(schema, table string) string {
+	if schema == "" {
+		return strings.ToUpper(table)
+	}
+	return strings.ToUpper(schema) + "." + strings.ToUpper(table)
+}
+
+// splitObjectName splits a possibly quoted, schema-qualified name such as
+// public."Order Items" into its schema and name.
+func splitObjectName(object string) (string, string) {
+	var parts []string
+	var part strings.Builder
+	var quote rune
+	for _, r := range object {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '`'):
+			quote = r
+		case quote == 0 && r == '.':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteRune(r)
+		}
+	}
+	parts = append(parts, part.String())
+
+	name := parts[len(parts)-1]
+	if len(parts) == 1 {
+		return "", name
+	}
+	return parts[len(parts)-2], name
+}
@@ -0,0 +1,272 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+type fakeSource struct {
+	mu          sync.Mutex
+	schemas     []models.Schema
+	tables      map[string][]models.Table
+	columns     map[string][]models.Column
+	calls       map[string]int
+	active, max int
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{
+		schemas: []models.Schema{{Name: "public"}, {Name: "app"}},
+		tables: map[string][]models.Table{
+			"public": {{Name: "users", Schema: "public"}, {Name: "orders", Schema: "public"}},
+			"app":    {{Name: "settings", Schema: "app"}},
+		},
+		columns: map[string][]models.Column{
+			"public.users":  {{Name: "id"}, {Name: "email"}},
+			"public.orders": {{Name: "id"}, {Name: "user_id"}},
+			"app.settings":  {{Name: "key"}, {Name: "value"}},
+		},
+		calls: map[string]int{},
+	}
+}
+
+func (f *fakeSource) track(call string) func() {
+	f.mu.Lock()
+	f.calls[call]++
+	f.active++
+	f.max = max(f.max, f.active)
+	f.mu.Unlock()
+	return func() {
+		f.mu.Lock()
+		f.active--
+		f.mu.Unlock()
+	}
+}
+
+func (f *fakeSource) count(call string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[call]
+}
+
+func (f *fakeSource) GetSchemas(ctx context.Context) ([]models.Schema, error) {
+	defer f.track("schemas")()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]models.Schema{}, f.schemas...), nil
+}
+
+func (f *fakeSource) GetTables(ctx context.Context, schema models.Schema) ([]models.Table, error) {
+	defer f.track("tables:" + schema.Name)()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]models.Table{}, f.tables[schema.Name]...), nil
+}
+
+func (f *fakeSource) GetTableColumns(ctx context.Context, schema, table string) (*models.TableColumns, error) {
+	defer f.track("columns:" + schema + "." + table)()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	columns, ok := f.columns[schema+"."+table]
+	if !ok {
+		return nil, errors.New("no such table")
+	}
+	return &models.TableColumns{Columns: append([]models.Column{}, columns...)}, nil
+}
+
+func columnNames(t *testing.T, c *Cache, schema, table string) string {
+	t.Helper()
+	columns, err := c.TableColumns(context.Background(), schema, table)
+	if err != nil {
+		t.Fatalf("TableColumns(%s.%s): %v", schema, table, err)
+	}
+	var names []string
+	for _, column := range columns.Columns {
+		names = append(names, column.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestCacheLoadsEverything(t *testing.T) {
+	source := newFakeSource()
+	cache := New(source, Options{Workers: 2})
+	if err := cache.refresh(context.Background(), "", false); err != nil {
+		t.Fatal(err)
+	}
+	before := source.count("columns:public.users")
+
+	tables, err := cache.Tables(context.Background(), "PUBLIC")
+	if err != nil || len(tables) != 2 {
+		t.Fatalf("Tables = %v, %v", tables, err)
+	}
+	if got := columnNames(t, cache, "public", "users"); got != "id,email" {
+		t.Fatalf("columns = %q", got)
+	}
+	if source.count("columns:public.users") != before {
+		t.Fatal("columns were fetched again instead of served from the cache")
+	}
+	if source.max > 2 {
+		t.Fatalf("%d concurrent fetches, want at most 2", source.max)
+	}
+}
+
+func TestCacheReadThrough(t *testing.T) {
+	source := newFakeSource()
+	cache := New(source, Options{})
+
+	for range 2 {
+		if got := columnNames(t, cache, "app", "settings"); got != "key,value" {
+			t.Fatalf("columns = %q", got)
+		}
+	}
+	if n := source.count("columns:app.settings"); n != 1 {
+		t.Fatalf("fetched %d times, want 1", n)
+	}
+	if _, err := cache.TableColumns(context.Background(), "app", "missing"); err == nil {
+		t.Fatal("expected an error for a missing table")
+	}
+}
+
+func TestCachePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata", "conn.json")
+	source := newFakeSource()
+	cache := New(source, Options{Path: path, Fingerprint: "abc"})
+	if err := cache.refresh(context.Background(), "", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := newFakeSource()
+	restored := New(fresh, Options{Path: path, Fingerprint: "abc"})
+	if err := restored.Load(); err != nil {
+		t.Fatal(err)
+	}
+	schemas, err := restored.Schemas(context.Background())
+	if err != nil || len(schemas) != 2 {
+		t.Fatalf("Schemas = %v, %v", schemas, err)
+	}
+	if got := columnNames(t, restored, "public", "orders"); got != "id,user_id" {
+		t.Fatalf("columns = %q", got)
+	}
+	if n := len(fresh.calls); n != 0 {
+		t.Fatalf("cold start made %d calls: %v", n, fresh.calls)
+	}
+
+	other := New(fresh, Options{Path: path, Fingerprint: "xyz"})
+	if err := other.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Schemas(context.Background()); err != nil || fresh.count("schemas") != 1 {
+		t.Fatal("a cache for another fingerprint should not be used")
+	}
+}
+
+func TestCacheRefreshPicksUpChanges(t *testing.T) {
+	source := newFakeSource()
+	cache := New(source, Options{})
+	if err := cache.refresh(context.Background(), "", false); err != nil {
+		t.Fatal(err)
+	}
+
+	source.mu.Lock()
+	source.tables["public"] = []models.Table{{Name: "users", Schema: "public"}}
+	source.columns["public.users"] = append(source.columns["public.users"], models.Column{Name: "age"})
+	source.mu.Unlock()
+
+	if err := cache.Refresh(context.Background(), "public"); err != nil {
+		t.Fatal(err)
+	}
+	if tables, _ := cache.Tables(context.Background(), "public"); len(tables) != 1 {
+		t.Fatalf("tables = %v", tables)
+	}
+	if got := columnNames(t, cache, "public", "users"); got != "id,email,age" {
+		t.Fatalf("columns = %q", got)
+	}
+	if _, ok := cache.columns[tableKey("public", "orders")]; ok {
+		t.Fatal("columns of a dropped table were kept")
+	}
+	if n := source.count("columns:app.settings"); n != 1 {
+		t.Fatalf("other schema fetched %d times, want 1", n)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	source := newFakeSource()
+	cache := New(source, Options{})
+	if err := cache.refresh(context.Background(), "", false); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.Invalidate(`public."users"`)
+	if _, ok := cache.columns[tableKey("public", "users")]; ok {
+		t.Fatal("columns of the altered table were kept")
+	}
+	if _, ok := cache.columns[tableKey("public", "orders")]; !ok {
+		t.Fatal("columns of another table were dropped")
+	}
+	if _, ok := cache.tables["PUBLIC"]; ok {
+		t.Fatal("table list of the altered schema was kept")
+	}
+	if _, ok := cache.tables["APP"]; !ok {
+		t.Fatal("table list of another schema was dropped")
+	}
+
+	cache.Invalidate()
+	if cache.schemasLoaded || len(cache.tables) != 0 {
+		t.Fatal("Invalidate without objects should drop the schema and table lists")
+	}
+	if _, err := cache.Schemas(context.Background()); err != nil || source.count("schemas") != 2 {
+		t.Fatal("schemas were not fetched again")
+	}
+}
+
+func TestSplitObjectName(t *testing.T) {
+	tests := map[string][2]string{
+		"users":                {"", "users"},
+		"app.users":            {"app", "users"},
+		`"my.schema"."Users"`:  {"my.schema", "Users"},
+		"`db`.`order items`":   {"db", "order items"},
+		"catalog.public.users": {"public", "users"},
+	}
+	for in, want := range tests {
+		schema, name := splitObjectName(in)
+		if schema != want[0] || name != want[1] {
+			t.Errorf("splitObjectName(%q) = %q, %q, want %q, %q", in, schema, name, want[0], want[1])
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	conn := &models.Connection{Name: "prod", Type: models.PostgresType, Host: "db", Port: 5432, Database: "app", Username: "me"}
+	renamed := *conn
+	renamed.Name = "production"
+	moved := *conn
+	moved.Host = "db2"
+
+	if Fingerprint(conn) != Fingerprint(&renamed) {
+		t.Fatal("renaming a connection should keep its fingerprint")
+	}
+	if Fingerprint(conn) == Fingerprint(&moved) {
+		t.Fatal("pointing a connection elsewhere should change its fingerprint")
+	}
+
+	t.Setenv("DBSMITH_TEST_HOST", "db")
+	templated := *conn
+	templated.Host = "${DBSMITH_TEST_HOST}"
+	templated.SecretKeyID = "prod-password"
+	if Fingerprint(&templated) != Fingerprint(conn) {
+		t.Error("a templated connection should fingerprint the database it resolves to")
+	}
+	t.Setenv("DBSMITH_TEST_HOST", "db2")
+	if Fingerprint(&templated) != Fingerprint(&moved) {
+		t.Error("changing the environment should move the fingerprint with it")
+	}
+}
@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/db"
+	"github.com/android-lewis/dbsmith/internal/fileutil"
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+const fileVersion = 1
+
+type snapshot struct {
+	Version     int                       `json:"version"`
+	Fingerprint string                    `json:"fingerprint"`
+	Schemas     []models.Schema           `json:"schemas"`
+	Tables      map[string][]models.Table `json:"tables"`
+	Columns     []columnsEntry            `json:"columns"`
+}
+
+// Fingerprint identifies the database a connection points at, so a renamed
+// connection keeps its cache and an edited one starts afresh. Environment
+// references are resolved; secrets are never read and passwords never
+// included.
+func Fingerprint(conn *models.Connection) string {
+	if resolved, err := db.ResolveConnectionWithoutSecrets(conn); err == nil {
+		conn = resolved
+	}
+	parts := []string{
+		string(conn.Type),
+		strings.ToLower(conn.Host),
+		strconv.Itoa(conn.Port),
+		conn.Database,
+		conn.Username,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Load fills the cache from its file. A missing file, or one written for
+// another connection or by another version, leaves the cache empty.
+func (c *Cache) Load() error {
+	if c.opts.Path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.opts.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read metadata cache: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to parse metadata cache: %w", err)
+	}
+	if snap.Version != fileVersion || snap.Fingerprint != c.opts.Fingerprint {
+		logging.Debug().Str("path", c.opts.Path).Msg("Ignoring metadata cache for another connection or version")
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schemas, c.schemasLoaded = snap.Schemas, snap.Schemas != nil
+	c.tables = make(map[string][]models.Table, len(snap.Tables))
+	for schema, tables := range snap.Tables {
+		c.tables[strings.ToUpper(schema)] = tables
+	}
+	c.columns = make(map[string]columnsEntry, len(snap.Columns))
+	for _, entry := range snap.Columns {
+		if entry.Columns != nil {
+			c.columns[tableKey(entry.Schema, entry.Table)] = entry
+		}
+	}
+	c.dirty = false
+	return nil
+}
+
+// Save writes the cache to its file.
+func (c *Cache) Save() error {
+	if c.opts.Path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	snap := snapshot{
+		Version:     fileVersion,
+		Fingerprint: c.opts.Fingerprint,
+		Tables:      c.tables,
+		Columns:     make([]columnsEntry, 0, len(c.columns)),
+	}
+	if c.schemasLoaded {
+		snap.Schemas = c.schemas
+	}
+	for _, entry := range c.columns {
+		snap.Columns = append(snap.Columns, entry)
+	}
+	data, err := json.Marshal(snap)
+	c.dirty = false
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.opts.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create metadata cache directory: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(c.opts.Path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write metadata cache: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) saveIfDirty() {
+	c.mu.Lock()
+	dirty := c.dirty
+	c.mu.Unlock()
+	if !dirty {
+		return
+	}
+	if err := c.Save(); err != nil {
+		logging.Warn().Err(err).Str("path", c.opts.Path).Msg("Failed to save metadata cache")
+	}
+}
@@ -1,6 +1,12 @@
 package models
 
-import "time"
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
 
 type ConnectionType string
 
@@ -10,6 +16,21 @@ const (
 	SQLiteType   ConnectionType = "sqlite"
 )
 
+// Environment tags a connection so per-environment policies from the config
+// can be applied. Values other than the predefined ones are custom
+// environments.
+type Environment string
+
+const (
+	EnvironmentDev     Environment = "dev"
+	EnvironmentStaging Environment = "staging"
+	EnvironmentProd    Environment = "prod"
+)
+
+// Connection describes a database to connect to. Host, Database, Username
+// and SSLCACertPath may contain ${ENV_VAR} and ${secret:key} references that
+// are resolved at connect time; PortTemplate holds a port written the same
+// way and is stored as the port key.
 type Connection struct {
 	Name          string         `yaml:"name"`
 	Type          ConnectionType `yaml:"type"`
@@ -20,8 +41,76 @@ type Connection struct {
 	SecretKeyID   string         `yaml:"secret_key_id,omitempty"`
 	SSL           string         `yaml:"ssl,omitempty"`
 	SSLCACertPath string         `yaml:"ssl_ca_cert_path,omitempty"`
+	Environment   Environment    `yaml:"environment,omitempty"`
+	ReadOnly      bool           `yaml:"read_only,omitempty"`
 	CreatedAt     time.Time      `yaml:"created_at,omitempty"`
 	LastModified  time.Time      `yaml:"last_modified,omitempty"`
+
+	PortTemplate string `yaml:"-"`
+}
+
+// UnmarshalYAML accepts a reference such as ${DB_PORT} for the port.
+func (c *Connection) UnmarshalYAML(value *yaml.Node) error {
+	type plain Connection
+
+	node := *value
+	var portTemplate string
+	if value.Kind == yaml.MappingNode {
+		node.Content = nil
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			k, v := value.Content[i], value.Content[i+1]
+			if k.Value == "port" && v.Kind == yaml.ScalarNode && strings.Contains(v.Value, "${") {
+				portTemplate = v.Value
+				continue
+			}
+			node.Content = append(node.Content, k, v)
+		}
+	}
+
+	var p plain
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+	*c = Connection(p)
+	c.PortTemplate = portTemplate
+	return nil
+}
+
+// MarshalYAML writes PortTemplate, when set, as the port.
+func (c Connection) MarshalYAML() (interface{}, error) {
+	type plain Connection
+
+	var node yaml.Node
+	if err := node.Encode(plain(c)); err != nil {
+		return nil, err
+	}
+	if c.PortTemplate == "" {
+		return &node, nil
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "port"}
+	val := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: c.PortTemplate}
+	at := len(node.Content)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		switch node.Content[i].Value {
+		case "port":
+			node.Content[i+1] = val
+			return &node, nil
+		case "host":
+			at = i + 2
+		}
+	}
+	node.Content = append(node.Content[:at], append([]*yaml.Node{key, val}, node.Content[at:]...)...)
+	return &node, nil
+}
+
+// PortString returns the port as configured: the template when the port is
+// a reference, otherwise the number.
+func (c *Connection) PortString() string {
+	if c.PortTemplate != "" {
+		return c.PortTemplate
+	}
+	return strconv.Itoa(c.Port)
 }
 
 func (c *Connection) GetSQLDialect() string {
@@ -37,31 +126,74 @@ func (c *Connection) GetSQLDialect() string {
 	}
 }
 
+// Workspace is a personal workspace file. Shared, when set, points at a team
+// workspace (a YAML file or a directory of .sql files) layered underneath it;
+// relative paths are resolved from the workspace file's directory.
 type Workspace struct {
-	Name               string           `yaml:"name"`
-	Connections        []Connection     `yaml:"connections"`
-	SavedQueries       []SavedQuery     `yaml:"saved_queries"`
-	LastOpenTabs       []string         `yaml:"last_open_tabs,omitempty"`
-	LastUsedConnection string           `yaml:"last_used_connection,omitempty"`
-	Preferences        *UserPreferences `yaml:"preferences,omitempty"`
-	CreatedAt          time.Time        `yaml:"created_at,omitempty"`
-	LastModified       time.Time        `yaml:"last_modified,omitempty"`
-	Version            int              `yaml:"version,omitempty"`
+	Name               string            `yaml:"name"`
+	Shared             string            `yaml:"shared,omitempty"`
+	Connections        []Connection      `yaml:"connections"`
+	SavedQueries       []SavedQuery      `yaml:"saved_queries"`
+	LastOpenTabs       []string          `yaml:"last_open_tabs,omitempty"`
+	LastUsedConnection string            `yaml:"last_used_connection,omitempty"`
+	Preferences        *UserPreferences  `yaml:"preferences,omitempty"`
+	Folders            []string          `yaml:"folders,omitempty"`
+	QueryUsage         []QueryUsage      `yaml:"query_usage,omitempty"`
+	CompletionUsage    []CompletionUsage `yaml:"completion_usage,omitempty"`
+	Snippets           []Snippet         `yaml:"snippets,omitempty"`
+	CreatedAt          time.Time         `yaml:"created_at,omitempty"`
+	LastModified       time.Time         `yaml:"last_modified,omitempty"`
+	Version            int               `yaml:"version,omitempty"`
 }
 
 type UserPreferences struct {
 	AutocompleteEnabled bool `yaml:"autocomplete_enabled"`
 }
 
+// SavedQuery is a named query in the workspace. Folder is a slash-separated
+// path such as "reports/monthly"; an empty folder is the root.
 type SavedQuery struct {
 	ID           string    `yaml:"id"`
 	Name         string    `yaml:"name"`
 	SQL          string    `yaml:"sql"`
 	Description  string    `yaml:"description,omitempty"`
+	Folder       string    `yaml:"folder,omitempty"`
+	Tags         []string  `yaml:"tags,omitempty"`
 	CreatedAt    time.Time `yaml:"created_at,omitempty"`
 	LastModified time.Time `yaml:"last_modified,omitempty"`
 }
 
+// QueryUsage tracks how often a saved query runs, overall and per connection.
+// It is stored apart from the query so running a query does not rewrite its
+// definition.
+type QueryUsage struct {
+	QueryID        string                     `yaml:"query_id"`
+	ExecutionCount int                        `yaml:"execution_count"`
+	LastExecutedAt time.Time                  `yaml:"last_executed_at,omitempty"`
+	Connections    map[string]ConnectionUsage `yaml:"connections,omitempty"`
+}
+
+// CompletionUsage counts the completions accepted on a connection so the
+// editor can rank them higher. Keys come from autocomplete.UsageKey.
+type CompletionUsage struct {
+	Connection string         `yaml:"connection"`
+	Counts     map[string]int `yaml:"counts,omitempty"`
+}
+
+// Snippet is an editor completion template; see autocomplete.Snippet for the
+// body syntax. Dialects limits it to those SQL dialects, empty means all.
+type Snippet struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description,omitempty"`
+	Body        string   `yaml:"body"`
+	Dialects    []string `yaml:"dialects,omitempty"`
+}
+
+type ConnectionUsage struct {
+	ExecutionCount int       `yaml:"execution_count"`
+	LastExecutedAt time.Time `yaml:"last_executed_at,omitempty"`
+}
+
 type ExecutionRecord struct {
 	QueryID      string
 	Query        string
@@ -100,6 +232,27 @@ type Index struct {
 	Type      string
 }
 
+// Function is a user-defined function. Arguments is the argument list as the
+// database prints it, e.g. "a integer, b numeric(10,2)".
+type Function struct {
+	Name       string
+	Schema     string
+	Arguments  string
+	ReturnType string
+}
+
+// ForeignKey references RefColumns of RefTable from Columns of Table; the
+// column lists pair up by position.
+type ForeignKey struct {
+	Name       string
+	Schema     string
+	Table      string
+	Columns    []string
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+}
+
 type TableColumns struct {
 	Columns []Column
 	DDL     string
@@ -132,3 +285,87 @@ type ServerInfo struct {
 	DatabaseSize    string
 	AdditionalInfo  map[string]string
 }
+
+// PlanIssue flags something noteworthy about a plan node.
+type PlanIssue string
+
+const (
+	PlanIssueExpensive   PlanIssue = "expensive"
+	PlanIssueRowMismatch PlanIssue = "row_mismatch"
+	PlanIssueSeqScan     PlanIssue = "seq_scan"
+)
+
+// PlanNode is a dialect-neutral operator in a query execution plan.
+type PlanNode struct {
+	Operation     string
+	Relation      string
+	Alias         string
+	Index         string
+	Detail        string
+	StartupCost   float64
+	TotalCost     float64
+	EstimatedRows float64
+	ActualRows    float64
+	ActualTimeMs  float64
+	Loops         int64
+	HasCost       bool
+	HasActual     bool
+	SeqScan       bool
+	SelfCost      float64
+	CostShare     float64
+	Issues        []PlanIssue
+	Children      []*PlanNode
+}
+
+// HasIssue reports whether the node was flagged with the given issue.
+func (n *PlanNode) HasIssue(issue PlanIssue) bool {
+	for _, i := range n.Issues {
+		if i == issue {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryPlan is the parsed result of an EXPLAIN.
+type QueryPlan struct {
+	Root        *PlanNode
+	Dialect     string
+	Analyzed    bool
+	PlanningMs  float64
+	ExecutionMs float64
+	Raw         string
+}
+
+// QueryError is a dialect-neutral error the database server reported for a
+// query. Code is the SQLSTATE; for MySQL and SQLite, which report a generic
+// class or none at all, it is derived from the vendor code where known.
+// Position is a zero-based character offset into Query, or -1 when the
+// server did not say where the error is.
+type QueryError struct {
+	Dialect    string
+	Code       string
+	VendorCode int
+	Severity   string
+	Message    string
+	Detail     string
+	Hint       string
+	Query      string
+	Position   int
+	Schema     string
+	Table      string
+	Column     string
+	Constraint string
+	Err        error
+}
+
+func (e *QueryError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
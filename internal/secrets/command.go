@@ -0,0 +1,197 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCommandTimeout  = 30 * time.Second
+	DefaultCommandCacheTTL = 5 * time.Minute
+
+	// expiryMargin is how long before a helper-reported expiration a cached
+	// secret is fetched again, so a token is not handed out just before it
+	// stops working.
+	expiryMargin = 30 * time.Second
+)
+
+// CommandOptions configures the cmd: and pass: providers. A zero CacheTTL
+// only caches secrets whose helper reports an expiration.
+type CommandOptions struct {
+	Timeout  time.Duration
+	CacheTTL time.Duration
+}
+
+// CommandProvider runs a helper command and uses its output as the secret,
+// following a credential_process-style protocol: the helper either prints
+// the secret itself (one trailing newline is dropped) or a JSON object
+//
+//	{"version": 1, "secret": "...", "expiration": "2024-01-02T15:04:05Z"}
+//
+// where "password" may be used instead of "secret" and the expiration is
+// optional. Results are cached until shortly before the expiration, or for
+// CacheTTL when the helper gives none. A non-zero exit fails with the
+// helper's stderr.
+type CommandProvider struct {
+	opts CommandOptions
+	now  func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	Credential
+	until time.Time
+}
+
+type helperOutput struct {
+	Version    int       `json:"version"`
+	Secret     string    `json:"secret"`
+	Password   string    `json:"password"`
+	Expiration time.Time `json:"expiration"`
+}
+
+func NewCommandProvider(opts CommandOptions) *CommandProvider {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultCommandTimeout
+	}
+	return &CommandProvider{opts: opts, now: time.Now, cache: make(map[string]cachedSecret)}
+}
+
+// Resolve runs command through the shell (sh -c, or cmd /C on Windows).
+func (p *CommandProvider) Resolve(command string) (string, error) {
+	cred, err := p.ResolveCredential(command)
+	return cred.Secret, err
+}
+
+// ResolveCredential is Resolve, also returning the expiration the helper
+// reported.
+func (p *CommandProvider) ResolveCredential(command string) (Credential, error) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return Credential{}, errors.New("empty command")
+	}
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	return p.resolve("cmd:"+command, shell, flag, command)
+}
+
+// Invalidate drops the cached output of command, e.g. after the server
+// rejected the token it printed.
+func (p *CommandProvider) Invalidate(command string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.cache, "cmd:"+strings.TrimSpace(command))
+}
+
+// Flush drops every cached secret, e.g. when the connection that used them
+// is closed.
+func (p *CommandProvider) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cache = make(map[string]cachedSecret)
+}
+
+func (p *CommandProvider) resolve(cacheKey, name string, args ...string) (Credential, error) {
+	p.mu.Lock()
+	if c, ok := p.cache[cacheKey]; ok && p.now().Before(c.until) {
+		p.mu.Unlock()
+		return c.Credential, nil
+	}
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return Credential{}, fmt.Errorf("helper timed out after %s", p.opts.Timeout)
+		}
+		if msg := firstLine(stderr.String()); msg != "" {
+			return Credential{}, fmt.Errorf("helper failed: %w: %s", err, msg)
+		}
+		return Credential{}, fmt.Errorf("helper failed: %w", err)
+	}
+
+	cred, err := parseHelperOutput(stdout.Bytes())
+	if err != nil {
+		return Credential{}, err
+	}
+
+	var until time.Time
+	switch {
+	case !cred.Expires.IsZero():
+		until = cred.Expires.Add(-expiryMargin)
+	case p.opts.CacheTTL > 0:
+		until = p.now().Add(p.opts.CacheTTL)
+	}
+	if p.now().Before(until) {
+		p.mu.Lock()
+		p.cache[cacheKey] = cachedSecret{Credential: cred, until: until}
+		p.mu.Unlock()
+	}
+	return cred, nil
+}
+
+// parseHelperOutput reads plain or JSON helper output.
+func parseHelperOutput(out []byte) (Credential, error) {
+	trimmed := bytes.TrimSpace(out)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		value := strings.TrimSuffix(string(out), "\n")
+		value = strings.TrimSuffix(value, "\r")
+		if value == "" {
+			return Credential{}, fmt.Errorf("%w: helper printed nothing", ErrSecretNotFound)
+		}
+		return Credential{Secret: value}, nil
+	}
+
+	var h helperOutput
+	if err := json.Unmarshal(trimmed, &h); err != nil {
+		return Credential{}, fmt.Errorf("invalid helper output: %w", err)
+	}
+	if h.Version > 1 {
+		return Credential{}, fmt.Errorf("unsupported helper output version %d", h.Version)
+	}
+	value := h.Secret
+	if value == "" {
+		value = h.Password
+	}
+	if value == "" {
+		return Credential{}, fmt.Errorf("%w: helper output has no secret", ErrSecretNotFound)
+	}
+	return Credential{Secret: value, Expires: h.Expiration}, nil
+}
+
+// resolvePass reads the first line of a pass(1) entry, as pass -c does.
+func resolvePass(p *CommandProvider, entry string) (string, error) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || strings.HasPrefix(entry, "-") {
+		return "", fmt.Errorf("invalid pass entry %q", entry)
+	}
+	out, err := p.resolve("pass:"+entry, "pass", "show", entry)
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(out.Secret, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(line)
+}
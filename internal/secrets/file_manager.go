@@ -7,47 +7,79 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/android-lewis/dbsmith/internal/fileutil"
 	"github.com/android-lewis/dbsmith/internal/security"
 )
 
+// EncryptedFileManager keeps secrets AES-encrypted in .secrets. The key is
+// either a random key in .secrets.key or, once a master password is set,
+// derived from that password and only held in memory while unlocked.
 type EncryptedFileManager struct {
 	configDir string
+
+	mu          sync.Mutex
+	key         []byte
+	keySalt     []byte
+	idleTimeout time.Duration
+	idleTimer   *time.Timer
+	idleGen     uint64
 }
 
 type secretsFile struct {
+	KDF     *kdfParams        `json:"kdf,omitempty"`
+	Check   string            `json:"check,omitempty"`
 	Secrets map[string]string `json:"secrets"`
 }
 
+func NewEncryptedFileManager(configDir string) *EncryptedFileManager {
+	return &EncryptedFileManager{configDir: configDir}
+}
+
+func (efm *EncryptedFileManager) secretsPath() string {
+	return filepath.Join(efm.configDir, ".secrets")
+}
+
+func (efm *EncryptedFileManager) keyPath() string {
+	return filepath.Join(efm.configDir, ".secrets.key")
+}
+
 func (efm *EncryptedFileManager) StoreSecret(keyID, secret string) error {
 	if err := os.MkdirAll(efm.configDir, 0700); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	keyFile := filepath.Join(efm.configDir, ".secrets.key")
-	key, err := efm.getOrCreateEncryptionKey(keyFile)
+	secretFile := efm.secretsPath()
+	lock, err := fileutil.Lock(secretFile, fileutil.DefaultLockTimeout)
 	if err != nil {
 		return err
 	}
+	defer func() { _ = lock.Unlock() }()
 
-	enc, err := security.NewEncryptor(key)
-	if err != nil {
+	sf, err := efm.loadSecretsFile(secretFile)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	encrypted, err := enc.Encrypt(secret)
+	if sf == nil {
+		sf = &secretsFile{Secrets: make(map[string]string)}
+	}
+
+	key, err := efm.encryptionKey(sf)
 	if err != nil {
 		return err
 	}
 
-	secretFile := filepath.Join(efm.configDir, ".secrets")
-	sf, err := efm.loadSecretsFile(secretFile)
-	if err != nil && !os.IsNotExist(err) {
+	enc, err := security.NewEncryptor(key)
+	if err != nil {
 		return err
 	}
 
-	if sf == nil {
-		sf = &secretsFile{Secrets: make(map[string]string)}
+	encrypted, err := enc.Encrypt(secret)
+	if err != nil {
+		return err
 	}
 
 	sf.Secrets[keyID] = encrypted
@@ -56,15 +88,8 @@ func (efm *EncryptedFileManager) StoreSecret(keyID, secret string) error {
 }
 
 func (efm *EncryptedFileManager) RetrieveSecret(keyID string) (string, error) {
-	keyFile := filepath.Join(efm.configDir, ".secrets.key")
-	key, err := efm.getOrCreateEncryptionKey(keyFile)
-	if err != nil {
-		return "", err
-	}
-
-	secretFile := filepath.Join(efm.configDir, ".secrets")
-	sf, err := efm.loadSecretsFile(secretFile)
-	if err != nil {
+	sf, err := efm.loadSecretsFile(efm.secretsPath())
+	if err != nil && !os.IsNotExist(err) {
 		return "", fmt.Errorf("failed to load secrets: %w", err)
 	}
 
@@ -77,6 +102,11 @@ func (efm *EncryptedFileManager) RetrieveSecret(keyID string) (string, error) {
 		return "", fmt.Errorf("secret not found: %s", keyID)
 	}
 
+	key, err := efm.encryptionKey(sf)
+	if err != nil {
+		return "", err
+	}
+
 	enc, err := security.NewEncryptor(key)
 	if err != nil {
 		return "", err
@@ -86,7 +116,13 @@ func (efm *EncryptedFileManager) RetrieveSecret(keyID string) (string, error) {
 }
 
 func (efm *EncryptedFileManager) DeleteSecret(keyID string) error {
-	secretFile := filepath.Join(efm.configDir, ".secrets")
+	secretFile := efm.secretsPath()
+	lock, err := fileutil.Lock(secretFile, fileutil.DefaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Unlock() }()
+
 	sf, err := efm.loadSecretsFile(secretFile)
 	if err != nil {
 		return err
@@ -105,12 +141,24 @@ func (efm *EncryptedFileManager) getOrCreateEncryptionKey(keyFile string) ([]byt
 		return data, nil
 	}
 
+	// Another process may be creating the key at the same time; only one of
+	// them may write it, and the others must use that key.
+	lock, err := fileutil.Lock(keyFile, fileutil.DefaultLockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	if data, err := os.ReadFile(keyFile); err == nil && len(data) == 32 {
+		return data, nil
+	}
+
 	key := make([]byte, 32)
 	if _, err := io.ReadFull(rand.Reader, key); err != nil {
 		return nil, err
 	}
 
-	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+	if err := fileutil.WriteFileAtomic(keyFile, key, 0600); err != nil {
 		return nil, fmt.Errorf("failed to save encryption key: %w", err)
 	}
 
@@ -137,5 +185,5 @@ func (efm *EncryptedFileManager) saveSecretsFile(secretFile string, sf *secretsF
 		return err
 	}
 
-	return os.WriteFile(secretFile, data, 0600)
+	return fileutil.WriteFileAtomic(secretFile, data, 0600)
 }
@@ -1,6 +1,8 @@
 package secrets
 
 import (
+	"errors"
+
 	"github.com/zalando/go-keyring"
 )
 
@@ -10,12 +12,20 @@ type Manager interface {
 	DeleteSecret(keyID string) error
 }
 
-func NewManager(configDir string) (Manager, error) {
-	testErr := keyring.Set("dbsmith-test", "test-key", "test-value")
-	if testErr == nil {
-		_ = keyring.Delete("dbsmith-test", "test-key")
-		return &KeyringManager{}, nil
-	}
+// keyringProbeKey is looked up, never written, to see whether a keyring
+// backend is reachable.
+const keyringProbeKey = "dbsmith-probe"
 
-	return &EncryptedFileManager{configDir: configDir}, nil
+// NewManager returns a registry resolving provider references (env:, file:,
+// cmd:, pass:) and storing everything else in the system keyring when one is
+// available, otherwise in the encrypted file in configDir.
+func NewManager(configDir string, opts CommandOptions) (Manager, error) {
+	return NewRegistry(defaultStore(configDir), opts), nil
+}
+
+func defaultStore(configDir string) Manager {
+	if _, err := keyring.Get("dbsmith", keyringProbeKey); err == nil || errors.Is(err, keyring.ErrNotFound) {
+		return &KeyringManager{}
+	}
+	return NewEncryptedFileManager(configDir)
 }
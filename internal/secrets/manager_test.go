@@ -1,9 +1,12 @@
 package secrets
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEncryptedFileManager(t *testing.T) {
@@ -77,3 +80,129 @@ func TestEncryptionKeyGeneration(t *testing.T) {
 		}
 	}
 }
+
+func useFastKDF(t *testing.T) {
+	t.Helper()
+	saved := defaultKDF
+	defaultKDF = kdfParams{Algorithm: "argon2id", Time: 1, MemoryKiB: 64, Threads: 1}
+	t.Cleanup(func() { defaultKDF = saved })
+}
+
+func TestMasterPasswordMigratesKeyFile(t *testing.T) {
+	useFastKDF(t)
+	tmpDir := t.TempDir()
+	manager := NewEncryptedFileManager(tmpDir)
+
+	if err := manager.StoreSecret("db", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if manager.Protected() {
+		t.Fatal("new secrets file should not be protected")
+	}
+
+	if err := manager.SetMasterPassword("", "correct horse"); err != nil {
+		t.Fatalf("SetMasterPassword: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".secrets.key")); !os.IsNotExist(err) {
+		t.Errorf("key file still exists after migration: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, ".secrets"))
+	if err != nil || !strings.Contains(string(data), `"argon2id"`) {
+		t.Fatalf("secrets file does not record the KDF: %s, %v", data, err)
+	}
+
+	if got, err := manager.RetrieveSecret("db"); err != nil || got != "hunter2" {
+		t.Errorf("after migration: %q, %v", got, err)
+	}
+
+	// A fresh process starts locked.
+	other := NewEncryptedFileManager(tmpDir)
+	if !other.Locked() {
+		t.Fatal("expected a new manager to be locked")
+	}
+	if _, err := other.RetrieveSecret("db"); !errors.Is(err, ErrSecretsLocked) {
+		t.Errorf("locked retrieve error = %v", err)
+	}
+	if err := other.StoreSecret("x", "y"); !errors.Is(err, ErrSecretsLocked) {
+		t.Errorf("locked store error = %v", err)
+	}
+	if err := other.Unlock("wrong"); !errors.Is(err, ErrWrongMasterPassword) {
+		t.Errorf("wrong password error = %v", err)
+	}
+	if err := other.Unlock("correct horse"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if got, err := other.RetrieveSecret("db"); err != nil || got != "hunter2" {
+		t.Errorf("after unlock: %q, %v", got, err)
+	}
+
+	other.Lock()
+	if !other.Locked() {
+		t.Error("Lock did not forget the key")
+	}
+}
+
+func TestMasterPasswordChange(t *testing.T) {
+	useFastKDF(t)
+	tmpDir := t.TempDir()
+	manager := NewEncryptedFileManager(tmpDir)
+	if err := manager.SetMasterPassword("", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.StoreSecret("db", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewEncryptedFileManager(tmpDir)
+	if err := other.Unlock("first"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := manager.SetMasterPassword("nope", "second"); !errors.Is(err, ErrWrongMasterPassword) {
+		t.Fatalf("change with wrong password: %v", err)
+	}
+	if err := manager.SetMasterPassword("first", "second"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := manager.RetrieveSecret("db"); err != nil || got != "s3cret" {
+		t.Errorf("after change: %q, %v", got, err)
+	}
+
+	// The other instance's cached key is stale now.
+	if _, err := other.RetrieveSecret("db"); !errors.Is(err, ErrSecretsLocked) {
+		t.Errorf("stale key error = %v", err)
+	}
+	if err := other.Unlock("first"); !errors.Is(err, ErrWrongMasterPassword) {
+		t.Errorf("old password error = %v", err)
+	}
+
+	if err := manager.SetMasterPassword("second", ""); err != nil {
+		t.Fatalf("removing master password: %v", err)
+	}
+	if manager.Protected() {
+		t.Error("still protected after removing the master password")
+	}
+	if got, err := NewEncryptedFileManager(tmpDir).RetrieveSecret("db"); err != nil || got != "s3cret" {
+		t.Errorf("after removal: %q, %v", got, err)
+	}
+}
+
+func TestMasterPasswordIdleLock(t *testing.T) {
+	useFastKDF(t)
+	manager := NewEncryptedFileManager(t.TempDir())
+	manager.SetIdleTimeout(20 * time.Millisecond)
+	if err := manager.SetMasterPassword("", "pw"); err != nil {
+		t.Fatal(err)
+	}
+	if manager.Locked() {
+		t.Fatal("setting the password should leave the manager unlocked")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !manager.Locked() {
+		if time.Now().After(deadline) {
+			t.Fatal("key was not forgotten after the idle timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,308 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/fileutil"
+	"github.com/android-lewis/dbsmith/internal/security"
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	ErrSecretsLocked       = errors.New("secrets are locked; enter the master password")
+	ErrWrongMasterPassword = errors.New("wrong master password")
+	ErrEmptyMasterPassword = errors.New("master password must not be empty")
+	ErrNoMasterPassword    = errors.New("secrets are not protected by a master password")
+	errUnsupportedKDF      = errors.New("unsupported key derivation function")
+)
+
+const DefaultIdleLockTimeout = 15 * time.Minute
+
+// masterPasswordCheckValue is stored encrypted so a wrong password is caught
+// on unlock rather than on the first secret read.
+const masterPasswordCheckValue = "dbsmith-master-password-check"
+
+// Lockable is implemented by managers whose secrets can be protected by a
+// master password and must be unlocked before use.
+type Lockable interface {
+	Protected() bool
+	Locked() bool
+	Unlock(password string) error
+	Lock()
+}
+
+// kdfParams describes how the key was derived from the master password. They
+// are stored with the secrets so stronger defaults do not break old files.
+type kdfParams struct {
+	Algorithm string `json:"algorithm"`
+	Salt      []byte `json:"salt"`
+	Time      uint32 `json:"time"`
+	MemoryKiB uint32 `json:"memory_kib"`
+	Threads   uint8  `json:"threads"`
+}
+
+// defaultKDF follows the RFC 9106 second recommended option for Argon2id.
+var defaultKDF = kdfParams{Algorithm: "argon2id", Time: 3, MemoryKiB: 64 * 1024, Threads: 4}
+
+func (p *kdfParams) deriveKey(password string) ([]byte, error) {
+	if p.Algorithm != "argon2id" {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedKDF, p.Algorithm)
+	}
+	return argon2.IDKey([]byte(password), p.Salt, p.Time, p.MemoryKiB, p.Threads, 32), nil
+}
+
+func newKDFParams() (*kdfParams, error) {
+	p := defaultKDF
+	p.Salt = make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, p.Salt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetIdleTimeout sets how long a derived key stays in memory after its last
+// use; zero keeps it until Lock is called.
+func (efm *EncryptedFileManager) SetIdleTimeout(d time.Duration) {
+	efm.mu.Lock()
+	defer efm.mu.Unlock()
+	efm.idleTimeout = d
+	efm.touchLocked()
+}
+
+// Protected reports whether the secrets file is encrypted with a key derived
+// from a master password.
+func (efm *EncryptedFileManager) Protected() bool {
+	sf, err := efm.loadSecretsFile(efm.secretsPath())
+	return err == nil && sf.KDF != nil
+}
+
+// Locked reports whether secrets cannot be read until Unlock is called.
+func (efm *EncryptedFileManager) Locked() bool {
+	if !efm.Protected() {
+		return false
+	}
+	efm.mu.Lock()
+	defer efm.mu.Unlock()
+	return efm.key == nil
+}
+
+// Unlock derives the key from password and keeps it in memory until Lock is
+// called or the idle timeout passes.
+func (efm *EncryptedFileManager) Unlock(password string) error {
+	sf, err := efm.loadSecretsFile(efm.secretsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNoMasterPassword
+		}
+		return fmt.Errorf("failed to load secrets: %w", err)
+	}
+	if sf.KDF == nil {
+		return ErrNoMasterPassword
+	}
+
+	key, err := verifyMasterPassword(sf, password)
+	if err != nil {
+		return err
+	}
+
+	efm.mu.Lock()
+	defer efm.mu.Unlock()
+	efm.setKeyLocked(key, sf.KDF.Salt)
+	return nil
+}
+
+// Lock forgets the derived key.
+func (efm *EncryptedFileManager) Lock() {
+	efm.mu.Lock()
+	defer efm.mu.Unlock()
+	efm.clearKeyLocked()
+}
+
+// SetMasterPassword re-encrypts every secret under a key derived from next.
+// current must be the existing master password when one is set; for a file
+// still using .secrets.key it is ignored and the key file is removed once the
+// secrets are migrated. An empty next removes the master password and goes
+// back to a key file.
+func (efm *EncryptedFileManager) SetMasterPassword(current, next string) error {
+	if err := os.MkdirAll(efm.configDir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	secretFile := efm.secretsPath()
+	lock, err := fileutil.Lock(secretFile, fileutil.DefaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	sf, err := efm.loadSecretsFile(secretFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if sf == nil {
+		sf = &secretsFile{Secrets: make(map[string]string)}
+	}
+	if sf.KDF == nil && next == "" {
+		return ErrEmptyMasterPassword
+	}
+
+	var oldKey []byte
+	switch {
+	case sf.KDF != nil:
+		if oldKey, err = verifyMasterPassword(sf, current); err != nil {
+			return err
+		}
+	case len(sf.Secrets) > 0:
+		if oldKey, err = efm.getOrCreateEncryptionKey(efm.keyPath()); err != nil {
+			return err
+		}
+	}
+
+	plain := make(map[string]string, len(sf.Secrets))
+	if len(sf.Secrets) > 0 {
+		dec, err := security.NewEncryptor(oldKey)
+		if err != nil {
+			return err
+		}
+		for id, encrypted := range sf.Secrets {
+			if plain[id], err = dec.Decrypt(encrypted); err != nil {
+				return fmt.Errorf("failed to decrypt secret %s: %w", id, err)
+			}
+		}
+	}
+
+	updated := &secretsFile{Secrets: make(map[string]string, len(plain))}
+	var newKey []byte
+	if next == "" {
+		// Write a fresh key file rather than reusing one left behind.
+		_ = os.Remove(efm.keyPath())
+		if newKey, err = efm.getOrCreateEncryptionKey(efm.keyPath()); err != nil {
+			return err
+		}
+	} else {
+		if updated.KDF, err = newKDFParams(); err != nil {
+			return err
+		}
+		if newKey, err = updated.KDF.deriveKey(next); err != nil {
+			return err
+		}
+	}
+
+	enc, err := security.NewEncryptor(newKey)
+	if err != nil {
+		return err
+	}
+	if updated.KDF != nil {
+		if updated.Check, err = enc.Encrypt(masterPasswordCheckValue); err != nil {
+			return err
+		}
+	}
+	for id, secret := range plain {
+		if updated.Secrets[id], err = enc.Encrypt(secret); err != nil {
+			return err
+		}
+	}
+
+	if err := efm.saveSecretsFile(secretFile, updated); err != nil {
+		return err
+	}
+
+	efm.mu.Lock()
+	defer efm.mu.Unlock()
+	if updated.KDF == nil {
+		efm.clearKeyLocked()
+		return nil
+	}
+	if err := os.Remove(efm.keyPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove old key file: %w", err)
+	}
+	efm.setKeyLocked(newKey, updated.KDF.Salt)
+	return nil
+}
+
+// encryptionKey returns the key for sf: the unlocked master key when the file
+// is protected, otherwise the key file's key.
+func (efm *EncryptedFileManager) encryptionKey(sf *secretsFile) ([]byte, error) {
+	if sf == nil || sf.KDF == nil {
+		return efm.getOrCreateEncryptionKey(efm.keyPath())
+	}
+
+	efm.mu.Lock()
+	defer efm.mu.Unlock()
+	// The password may have been changed by another process since we
+	// unlocked; the cached key no longer matches the file.
+	if efm.key != nil && subtle.ConstantTimeCompare(efm.keySalt, sf.KDF.Salt) != 1 {
+		efm.clearKeyLocked()
+	}
+	if efm.key == nil {
+		return nil, ErrSecretsLocked
+	}
+	efm.touchLocked()
+	return append([]byte(nil), efm.key...), nil
+}
+
+func verifyMasterPassword(sf *secretsFile, password string) ([]byte, error) {
+	if password == "" {
+		return nil, ErrEmptyMasterPassword
+	}
+	key, err := sf.KDF.deriveKey(password)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := security.NewEncryptor(key)
+	if err != nil {
+		return nil, err
+	}
+	if check, err := enc.Decrypt(sf.Check); err != nil || check != masterPasswordCheckValue {
+		return nil, ErrWrongMasterPassword
+	}
+	return key, nil
+}
+
+func (efm *EncryptedFileManager) setKeyLocked(key, salt []byte) {
+	efm.clearKeyLocked()
+	efm.key = key
+	efm.keySalt = append([]byte(nil), salt...)
+	efm.touchLocked()
+}
+
+func (efm *EncryptedFileManager) clearKeyLocked() {
+	for i := range efm.key {
+		efm.key[i] = 0
+	}
+	efm.key = nil
+	efm.keySalt = nil
+	efm.idleGen++
+	if efm.idleTimer != nil {
+		efm.idleTimer.Stop()
+		efm.idleTimer = nil
+	}
+}
+
+// touchLocked restarts the idle timer after the key was used. A timer that
+// already fired while we held the mutex sees a newer generation and does
+// nothing.
+func (efm *EncryptedFileManager) touchLocked() {
+	efm.idleGen++
+	if efm.idleTimer != nil {
+		efm.idleTimer.Stop()
+		efm.idleTimer = nil
+	}
+	if efm.key == nil || efm.idleTimeout <= 0 {
+		return
+	}
+	gen := efm.idleGen
+	efm.idleTimer = time.AfterFunc(efm.idleTimeout, func() {
+		efm.mu.Lock()
+		defer efm.mu.Unlock()
+		if efm.idleGen == gen {
+			efm.clearKeyLocked()
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSecretNotFound      = errors.New("secret not found")
+	ErrReadOnlyProvider    = errors.New("secret provider is read-only")
+	ErrProviderUnavailable = errors.New("secret provider unavailable")
+)
+
+// Provider resolves secret references of one scheme, such as the
+// "PGPASSWORD" in "env:PGPASSWORD". Providers are read-only: passwords are
+// managed in the external store, not through dbsmith.
+type Provider interface {
+	Resolve(ref string) (string, error)
+}
+
+// Credential is a secret and the time it stops being valid, for short-lived
+// tokens. A zero Expires means the secret does not expire.
+type Credential struct {
+	Secret  string
+	Expires time.Time
+}
+
+// CredentialProvider is a Provider whose secrets may expire, such as a
+// helper issuing short-lived database tokens.
+type CredentialProvider interface {
+	Provider
+	ResolveCredential(ref string) (Credential, error)
+	// Invalidate forgets any cached secret for ref.
+	Invalidate(ref string)
+}
+
+// ProviderFunc adapts a function to Provider.
+type ProviderFunc func(ref string) (string, error)
+
+func (f ProviderFunc) Resolve(ref string) (string, error) { return f(ref) }
+
+// Registry routes secret key IDs carrying a registered scheme ("env:",
+// "file:", "cmd:", "pass:") to their provider, and everything else to the
+// keyring or encrypted file it wraps.
+type Registry struct {
+	fallback  Manager
+	providers map[string]Provider
+}
+
+// NewRegistry wraps fallback with the env, file, cmd and pass providers.
+func NewRegistry(fallback Manager, opts CommandOptions) *Registry {
+	r := &Registry{fallback: fallback, providers: make(map[string]Provider)}
+	cmd := NewCommandProvider(opts)
+	r.Register("env", ProviderFunc(resolveEnv))
+	r.Register("file", ProviderFunc(resolveFile))
+	r.Register("cmd", cmd)
+	r.Register("pass", ProviderFunc(func(ref string) (string, error) {
+		return resolvePass(cmd, ref)
+	}))
+	return r
+}
+
+// Register adds or replaces the provider for scheme.
+func (r *Registry) Register(scheme string, p Provider) {
+	r.providers[scheme] = p
+}
+
+// Fallback returns the manager used for key IDs without a provider scheme.
+func (r *Registry) Fallback() Manager {
+	return r.fallback
+}
+
+// provider returns the provider and reference for keyID, if its scheme is
+// registered.
+func (r *Registry) provider(keyID string) (Provider, string, bool) {
+	scheme, ref, ok := strings.Cut(keyID, ":")
+	if !ok {
+		return nil, "", false
+	}
+	p, ok := r.providers[scheme]
+	return p, ref, ok
+}
+
+func (r *Registry) RetrieveSecret(keyID string) (string, error) {
+	if p, ref, ok := r.provider(keyID); ok {
+		secret, err := p.Resolve(ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s: %w", redactRef(keyID), err)
+		}
+		return secret, nil
+	}
+	if r.fallback == nil {
+		return "", fmt.Errorf("%w: %s", ErrSecretNotFound, keyID)
+	}
+	return r.fallback.RetrieveSecret(keyID)
+}
+
+// RetrieveCredential is RetrieveSecret, also reporting when the secret
+// expires if its provider knows.
+func (r *Registry) RetrieveCredential(keyID string) (Credential, error) {
+	p, ref, _ := r.provider(keyID)
+	cp, ok := p.(CredentialProvider)
+	if !ok {
+		secret, err := r.RetrieveSecret(keyID)
+		return Credential{Secret: secret}, err
+	}
+	cred, err := cp.ResolveCredential(ref)
+	if err != nil {
+		return Credential{}, fmt.Errorf("failed to resolve %s: %w", redactRef(keyID), err)
+	}
+	return cred, nil
+}
+
+// Invalidate forgets a cached secret for keyID so the next retrieval asks
+// its provider again.
+func (r *Registry) Invalidate(keyID string) {
+	if p, ref, ok := r.provider(keyID); ok {
+		if cp, ok := p.(CredentialProvider); ok {
+			cp.Invalidate(ref)
+		}
+	}
+}
+
+// Flush drops every secret the providers have cached, so helper output does
+// not outlive the connection that needed it.
+func (r *Registry) Flush() {
+	for _, p := range r.providers {
+		if f, ok := p.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+	}
+}
+
+func (r *Registry) StoreSecret(keyID, secret string) error {
+	if _, _, ok := r.provider(keyID); ok {
+		return fmt.Errorf("%w: %s", ErrReadOnlyProvider, redactRef(keyID))
+	}
+	if r.fallback == nil {
+		return ErrProviderUnavailable
+	}
+	return r.fallback.StoreSecret(keyID, secret)
+}
+
+func (r *Registry) DeleteSecret(keyID string) error {
+	if _, _, ok := r.provider(keyID); ok {
+		// Nothing is stored locally for a provider reference.
+		return nil
+	}
+	if r.fallback == nil {
+		return ErrProviderUnavailable
+	}
+	return r.fallback.DeleteSecret(keyID)
+}
+
+// IsProviderRef reports whether keyID names a built-in provider rather than
+// a secret stored by dbsmith.
+func IsProviderRef(keyID string) bool {
+	scheme, _, ok := strings.Cut(keyID, ":")
+	if !ok {
+		return false
+	}
+	switch scheme {
+	case "env", "file", "cmd", "pass":
+		return true
+	}
+	return false
+}
+
+// FileManager returns the encrypted file manager behind m, if m stores
+// secrets in the encrypted file.
+func FileManager(m Manager) (*EncryptedFileManager, bool) {
+	if r, ok := m.(*Registry); ok {
+		m = r.fallback
+	}
+	efm, ok := m.(*EncryptedFileManager)
+	return efm, ok
+}
+
+// Lockable support is delegated to the fallback so a master-password
+// protected file can still be unlocked through the registry.
+
+func (r *Registry) Protected() bool {
+	l, ok := r.fallback.(Lockable)
+	return ok && l.Protected()
+}
+
+func (r *Registry) Locked() bool {
+	l, ok := r.fallback.(Lockable)
+	return ok && l.Locked()
+}
+
+func (r *Registry) Unlock(password string) error {
+	l, ok := r.fallback.(Lockable)
+	if !ok {
+		return ErrNoMasterPassword
+	}
+	return l.Unlock(password)
+}
+
+func (r *Registry) Lock() {
+	if l, ok := r.fallback.(Lockable); ok {
+		l.Lock()
+	}
+}
+
+func resolveEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrSecretNotFound, name)
+	}
+	return value, nil
+}
+
+// resolveFile reads a secret file such as a Docker or Kubernetes secret
+// mount. A single trailing newline is dropped.
+func resolveFile(path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, rest)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s does not exist", ErrSecretNotFound, path)
+		}
+		return "", err
+	}
+	secret := strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(secret, "\r"), nil
+}
+
+// redactRef shortens a command reference so errors and logs do not carry
+// arguments that may be sensitive.
+func redactRef(keyID string) string {
+	if scheme, ref, ok := strings.Cut(keyID, ":"); ok && scheme == "cmd" {
+		if name, _, found := strings.Cut(strings.TrimSpace(ref), " "); found {
+			return "cmd:" + name + " ..."
+		}
+	}
+	return keyID
+}
@@ -0,0 +1,247 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryStore map[string]string
+
+func (m memoryStore) StoreSecret(keyID, secret string) error { m[keyID] = secret; return nil }
+func (m memoryStore) DeleteSecret(keyID string) error        { delete(m, keyID); return nil }
+func (m memoryStore) RetrieveSecret(keyID string) (string, error) {
+	if v, ok := m[keyID]; ok {
+		return v, nil
+	}
+	return "", ErrSecretNotFound
+}
+
+// writeStub writes an executable shell script and returns its path.
+func writeStub(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("stub helpers are shell scripts")
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRegistryRoutesBySchema(t *testing.T) {
+	t.Setenv("DBSMITH_TEST_PW", "from-env")
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "db")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := memoryStore{"dbsmith_prod_postgres": "stored"}
+	r := NewRegistry(store, CommandOptions{})
+
+	tests := map[string]string{
+		"env:DBSMITH_TEST_PW":   "from-env",
+		"file:" + secretFile:    "from-file",
+		"dbsmith_prod_postgres": "stored",
+	}
+	for keyID, want := range tests {
+		got, err := r.RetrieveSecret(keyID)
+		if err != nil || got != want {
+			t.Errorf("RetrieveSecret(%q) = %q, %v; want %q", keyID, got, err, want)
+		}
+	}
+
+	if _, err := r.RetrieveSecret("env:DBSMITH_TEST_UNSET"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("unset variable error = %v", err)
+	}
+	if err := r.StoreSecret("env:X", "y"); !errors.Is(err, ErrReadOnlyProvider) {
+		t.Errorf("store to provider error = %v", err)
+	}
+	if err := r.StoreSecret("plain", "y"); err != nil || store["plain"] != "y" {
+		t.Errorf("store to fallback: %v", err)
+	}
+	if err := r.DeleteSecret("file:/nope"); err != nil {
+		t.Errorf("deleting a provider reference: %v", err)
+	}
+
+	if !IsProviderRef("cmd:vault kv get x") || IsProviderRef("dbsmith_a_b") || IsProviderRef("vault:x") {
+		t.Error("IsProviderRef misclassified a key ID")
+	}
+}
+
+func TestCommandProviderPlainOutputIsCached(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "calls")
+	helper := writeStub(t, dir, "helper", `echo x >> "`+counter+`"
+echo "s3cret"
+`)
+
+	p := NewCommandProvider(CommandOptions{CacheTTL: time.Minute})
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		got, err := p.Resolve(helper + " --field password")
+		if err != nil || got != "s3cret" {
+			t.Fatalf("Resolve = %q, %v", got, err)
+		}
+	}
+	if calls := countLines(t, counter); calls != 1 {
+		t.Errorf("helper ran %d times, want 1 (cached)", calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := p.Resolve(helper + " --field password"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := countLines(t, counter); calls != 2 {
+		t.Errorf("helper ran %d times after the TTL, want 2", calls)
+	}
+}
+
+func TestRegistryFlushDropsCachedOutput(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "calls")
+	helper := writeStub(t, dir, "helper", `echo x >> "`+counter+`"
+echo "s3cret"
+`)
+
+	r := NewRegistry(memoryStore{}, CommandOptions{CacheTTL: time.Minute})
+	for i := 0; i < 2; i++ {
+		if _, err := r.RetrieveSecret("cmd:" + helper); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls := countLines(t, counter); calls != 1 {
+		t.Fatalf("helper ran %d times, want 1 (cached)", calls)
+	}
+
+	r.Flush()
+	if _, err := r.RetrieveSecret("cmd:" + helper); err != nil {
+		t.Fatal(err)
+	}
+	if calls := countLines(t, counter); calls != 2 {
+		t.Errorf("helper ran %d times after Flush, want 2", calls)
+	}
+}
+
+func TestCommandProviderJSONExpiration(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "calls")
+	helper := writeStub(t, dir, "helper", `echo x >> "`+counter+`"
+echo '{"version": 1, "password": "rotating", "expiration": "2030-01-01T00:10:00Z"}'
+`)
+
+	p := NewCommandProvider(CommandOptions{})
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+
+	if got, err := p.Resolve(helper); err != nil || got != "rotating" {
+		t.Fatalf("Resolve = %q, %v", got, err)
+	}
+	now = now.Add(5 * time.Minute)
+	_, _ = p.Resolve(helper)
+	if calls := countLines(t, counter); calls != 1 {
+		t.Errorf("helper ran %d times before expiration, want 1", calls)
+	}
+
+	now = now.Add(10 * time.Minute)
+	_, _ = p.Resolve(helper)
+	if calls := countLines(t, counter); calls != 2 {
+		t.Errorf("helper ran %d times after expiration, want 2", calls)
+	}
+}
+
+func TestRegistryCredentialExpiry(t *testing.T) {
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "calls")
+	helper := writeStub(t, dir, "token", `echo x >> "`+counter+`"
+echo '{"version": 1, "secret": "token", "expiration": "2030-01-01T00:15:00Z"}'
+`)
+
+	r := NewRegistry(nil, CommandOptions{})
+	cmd := r.providers["cmd"].(*CommandProvider)
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	cmd.now = func() time.Time { return now }
+
+	cred, err := r.RetrieveCredential("cmd:" + helper)
+	want := time.Date(2030, 1, 1, 0, 15, 0, 0, time.UTC)
+	if err != nil || cred.Secret != "token" || !cred.Expires.Equal(want) {
+		t.Fatalf("RetrieveCredential = %+v, %v", cred, err)
+	}
+
+	_, _ = r.RetrieveCredential("cmd:" + helper)
+	r.Invalidate("cmd:" + helper)
+	_, _ = r.RetrieveCredential("cmd:" + helper)
+	if calls := countLines(t, counter); calls != 2 {
+		t.Errorf("helper ran %d times after Invalidate, want 2", calls)
+	}
+
+	// Tokens are refreshed shortly before they expire, not at the last moment.
+	now = want.Add(-10 * time.Second)
+	_, _ = r.RetrieveCredential("cmd:" + helper)
+	if calls := countLines(t, counter); calls != 3 {
+		t.Errorf("helper ran %d times near expiry, want 3", calls)
+	}
+
+	t.Setenv("DBSMITH_TEST_PW", "static")
+	if cred, err := r.RetrieveCredential("env:DBSMITH_TEST_PW"); err != nil || cred.Secret != "static" || !cred.Expires.IsZero() {
+		t.Errorf("env credential = %+v, %v", cred, err)
+	}
+}
+
+func TestCommandProviderErrors(t *testing.T) {
+	dir := t.TempDir()
+	failing := writeStub(t, dir, "failing", "echo 'permission denied' >&2\nexit 3\n")
+	empty := writeStub(t, dir, "empty", "exit 0\n")
+	slow := writeStub(t, dir, "slow", "sleep 5\n")
+
+	p := NewCommandProvider(CommandOptions{Timeout: 200 * time.Millisecond})
+
+	if _, err := p.Resolve(failing); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("failing helper error = %v", err)
+	}
+	if _, err := p.Resolve(empty); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("empty helper error = %v", err)
+	}
+	if _, err := p.Resolve(slow); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("slow helper error = %v", err)
+	}
+
+	r := NewRegistry(nil, CommandOptions{})
+	_, err := r.RetrieveSecret("cmd:" + failing + " --token hunter2")
+	if err == nil || strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("error %v should not repeat the command's arguments", err)
+	}
+}
+
+func TestPassProvider(t *testing.T) {
+	dir := t.TempDir()
+	writeStub(t, dir, "pass", `[ "$1" = show ] && [ "$2" = team/db/prod ] || exit 1
+printf 'correct horse\nusername: app\n'
+`)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	r := NewRegistry(nil, CommandOptions{})
+	if got, err := r.RetrieveSecret("pass:team/db/prod"); err != nil || got != "correct horse" {
+		t.Errorf("pass = %q, %v", got, err)
+	}
+	if _, err := r.RetrieveSecret("pass:--help"); err == nil {
+		t.Error("expected an option-like entry to be rejected")
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
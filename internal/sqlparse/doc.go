@@ -0,0 +1,5 @@
+// Package sqlparse lexes SQL and parses statements into query scopes: the
+// tables, CTEs, aliases and select list of each SELECT, UPDATE, DELETE or
+// INSERT, nested the way the query nests them. Completion and diagnostics
+// both resolve names through these scopes.
+package sqlparse
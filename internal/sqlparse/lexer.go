@@ -0,0 +1,397 @@
+package sqlparse
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+type Kind int
+
+const (
+	Word Kind = iota
+	Ident
+	Number
+	String
+	Operator
+	LParen
+	RParen
+	Comma
+	Semicolon
+	// Subquery stands in for a parenthesized query that was parsed as a
+	// scope of its own.
+	Subquery
+)
+
+// Lexeme is a significant token with comments and whitespace removed. The
+// chroma lexers split quoted strings, qualified names and compound operators
+// into several tokens; those are merged back here. Start and End are byte
+// offsets into the lexed SQL. Plain is set on words the lexer typed as a
+// plain name rather than a keyword or builtin.
+type Lexeme struct {
+	Kind  Kind
+	Text  string
+	Start int
+	End   int
+	Plain bool
+}
+
+// Upper returns the text of a word in upper case, or "" for other lexemes.
+func (l Lexeme) Upper() string {
+	if l.Kind != Word {
+		return ""
+	}
+	return strings.ToUpper(l.Text)
+}
+
+// Is reports whether l is the word word, ignoring case.
+func (l Lexeme) Is(word string) bool {
+	return l.Kind == Word && strings.EqualFold(l.Text, word)
+}
+
+// Lexer returns the chroma lexer for dialect, falling back to the generic
+// SQL lexer.
+func Lexer(dialect string) chroma.Lexer {
+	name := "sql"
+	switch strings.ToLower(dialect) {
+	case "postgres", "postgresql":
+		name = "postgresql"
+	case "mysql":
+		name = "mysql"
+	case "sqlite", "sqlite3":
+		name = "sqlite3"
+	}
+	if lexer := lexers.Get(name); lexer != nil {
+		return lexer
+	}
+	return lexers.Get("sql")
+}
+
+var compoundOps = map[string]bool{
+	"<>": true, "!=": true, ">=": true, "<=": true, "==": true, "::": true, "||": true,
+}
+
+// Lex splits sql into lexemes using the lexer for dialect.
+func Lex(sql, dialect string) []Lexeme {
+	lexer := Lexer(dialect)
+	if lexer == nil {
+		return nil
+	}
+	iter, err := lexer.Tokenise(nil, sql)
+	if err != nil {
+		return nil
+	}
+	var tokens []chroma.Token
+	for _, tok := range iter.Tokens() {
+		if tok.Value != "" {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	var out []Lexeme
+	offset := 0
+	gap := true
+
+	clamp := func(n int) int {
+		if n > len(sql) {
+			return len(sql)
+		}
+		return n
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		start := offset
+		offset += len(tok.Value)
+
+		switch {
+		case tok.Type.InCategory(chroma.Comment):
+			gap = true
+			continue
+
+		case strings.TrimSpace(tok.Value) == "":
+			gap = true
+			continue
+
+		case tok.Type.SubCategory() == chroma.LiteralString:
+			text := tok.Value
+			for i+1 < len(tokens) && tokens[i+1].Type.SubCategory() == chroma.LiteralString {
+				i++
+				text += tokens[i].Value
+				offset += len(tokens[i].Value)
+			}
+			kind := Ident
+			if strings.HasPrefix(text, "'") || strings.HasPrefix(strings.ToUpper(text), "E'") || strings.HasPrefix(text, "$") {
+				kind = String
+			}
+			out = appendLexeme(out, Lexeme{Kind: kind, Text: text, Start: start, End: clamp(offset)}, gap)
+			gap = false
+			continue
+
+		case tok.Value == "`":
+			text := tok.Value
+			for i+1 < len(tokens) {
+				i++
+				text += tokens[i].Value
+				offset += len(tokens[i].Value)
+				if tokens[i].Value == "`" {
+					break
+				}
+			}
+			out = appendLexeme(out, Lexeme{Kind: Ident, Text: text, Start: start, End: clamp(offset)}, gap)
+			gap = false
+			continue
+
+		case tok.Value == ".":
+			out = appendLexeme(out, Lexeme{Kind: Operator, Text: ".", Start: start, End: clamp(offset)}, gap)
+			gap = false
+			continue
+
+		case tok.Type.InCategory(chroma.Punctuation) || tok.Type.InCategory(chroma.Operator):
+			pos := start
+			for _, r := range tok.Value {
+				ch := string(r)
+				l := Lexeme{Text: ch, Start: pos, End: clamp(pos + len(ch))}
+				pos += len(ch)
+				switch ch {
+				case " ", "\t", "\n", "\r":
+					gap = true
+					continue
+				case "(":
+					l.Kind = LParen
+				case ")":
+					l.Kind = RParen
+				case ",":
+					l.Kind = Comma
+				case ";":
+					l.Kind = Semicolon
+				default:
+					l.Kind = Operator
+					if n := len(out); n > 0 && !gap && out[n-1].Kind == Operator && compoundOps[out[n-1].Text+ch] {
+						out[n-1].Text += ch
+						out[n-1].End = l.End
+						continue
+					}
+				}
+				out = append(out, l)
+				gap = false
+			}
+			continue
+		}
+
+		kind := Word
+		if tok.Type.SubCategory() == chroma.LiteralNumber {
+			kind = Number
+		}
+		out = appendLexeme(out, Lexeme{Kind: kind, Text: tok.Value, Start: start, End: clamp(offset), Plain: tok.Type == chroma.Name}, gap)
+		gap = false
+	}
+
+	return out
+}
+
+// appendLexeme glues l onto the previous lexeme when the two form one
+// qualified name or number, such as schema."table" or 1.5.
+func appendLexeme(out []Lexeme, l Lexeme, gap bool) []Lexeme {
+	n := len(out)
+	if n == 0 || gap {
+		return append(out, l)
+	}
+	prev := &out[n-1]
+	if !strings.HasSuffix(prev.Text, ".") && l.Text != "." || !gluable(*prev) || !gluable(l) {
+		return append(out, l)
+	}
+
+	switch {
+	case prev.Kind == Number && (l.Kind == Number || l.Text == "."):
+	case prev.Text == "." && l.Kind == Number:
+		prev.Kind = Number
+	default:
+		prev.Kind = Ident
+	}
+	prev.Text += l.Text
+	prev.End = l.End
+	return out
+}
+
+func gluable(l Lexeme) bool {
+	switch l.Kind {
+	case Word, Ident, Number:
+		return true
+	case Operator:
+		return l.Text == "."
+	default:
+		return false
+	}
+}
+
+// SplitStatements splits lexemes into statements on top-level semicolons,
+// dropping empty statements.
+func SplitStatements(lexemes []Lexeme) [][]Lexeme {
+	var statements [][]Lexeme
+	depth := 0
+	start := 0
+	for i, l := range lexemes {
+		switch l.Kind {
+		case LParen:
+			depth++
+		case RParen:
+			if depth > 0 {
+				depth--
+			}
+		case Semicolon:
+			if depth == 0 {
+				if i > start {
+					statements = append(statements, lexemes[start:i])
+				}
+				start = i + 1
+			}
+		}
+	}
+	if start < len(lexemes) {
+		statements = append(statements, lexemes[start:])
+	}
+	return statements
+}
+
+// StatementAt returns the statement that the byte offset falls in: the
+// lexemes after the last top-level semicolon before it, up to the next one.
+func StatementAt(lexemes []Lexeme, offset int) []Lexeme {
+	depth := 0
+	start := 0
+	for i, l := range lexemes {
+		switch l.Kind {
+		case LParen:
+			depth++
+		case RParen:
+			if depth > 0 {
+				depth--
+			}
+		case Semicolon:
+			if depth != 0 {
+				continue
+			}
+			if offset <= l.Start {
+				return lexemes[start:i]
+			}
+			start = i + 1
+		}
+	}
+	return lexemes[start:]
+}
+
+// MatchingParen returns the index of the paren closing the one at open, or
+// len(lexemes) when it is unclosed.
+func MatchingParen(lexemes []Lexeme, open int) int {
+	depth := 0
+	for i := open; i < len(lexemes); i++ {
+		switch lexemes[i].Kind {
+		case LParen:
+			depth++
+		case RParen:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(lexemes)
+}
+
+// FindTopLevel returns the index of the first word in lexemes[from:] at paren
+// depth zero that matches one of words, or -1.
+func FindTopLevel(lexemes []Lexeme, from int, words ...string) int {
+	depth := 0
+	for i := from; i < len(lexemes); i++ {
+		switch lexemes[i].Kind {
+		case LParen:
+			depth++
+		case RParen:
+			depth--
+		case Word:
+			if depth != 0 {
+				continue
+			}
+			for _, w := range words {
+				if lexemes[i].Is(w) {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// SplitName splits a possibly quoted, qualified name such as
+// public."Order Items".id into its parts with quotes removed. A trailing
+// dot leaves an empty last part.
+func SplitName(text string) []string {
+	var parts []string
+	var part strings.Builder
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case quote != 0 && ch == quote:
+			if i+1 < len(text) && text[i+1] == quote {
+				part.WriteByte(ch)
+				i++
+				continue
+			}
+			quote = 0
+		case quote == 0 && (ch == '"' || ch == '`'):
+			quote = ch
+		case quote == 0 && ch == '[':
+			quote = ']'
+		case quote == 0 && ch == '.':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(ch)
+		}
+	}
+	return append(parts, part.String())
+}
+
+// Unquote returns the last part of a possibly quoted, qualified name.
+func Unquote(text string) string {
+	parts := SplitName(text)
+	return parts[len(parts)-1]
+}
+
+// SkipExplain returns the index of the statement EXPLAIN at lx[explain]
+// applies to, after its options, and whether the options include ANALYZE.
+func SkipExplain(lx []Lexeme, explain int) (int, bool) {
+	analyze := false
+	i := explain + 1
+	for i < len(lx) {
+		switch {
+		case lx[i].Kind == LParen:
+			end := MatchingParen(lx, i)
+			for _, l := range lx[i:min(end, len(lx))] {
+				if l.Is("ANALYZE") || l.Is("ANALYSE") {
+					analyze = true
+				}
+			}
+			i = end + 1
+			continue
+		case lx[i].Is("ANALYZE") || lx[i].Is("ANALYSE"):
+			analyze = true
+			i++
+			continue
+		case lx[i].Is("FORMAT"):
+			i++
+			if i < len(lx) && lx[i].Kind == Operator && lx[i].Text == "=" {
+				i++
+			}
+			i++
+			continue
+		case lx[i].Is("VERBOSE") || lx[i].Is("QUERY") || lx[i].Is("PLAN") || lx[i].Is("EXTENDED"):
+			i++
+			continue
+		}
+		break
+	}
+	return i, analyze
+}
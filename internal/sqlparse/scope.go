@@ -0,0 +1,785 @@
+package sqlparse
+
+import "strings"
+
+// Scope is one SELECT, UPDATE, DELETE or INSERT: the tables it makes visible
+// and its lexemes. Parenthesized subqueries are replaced by a Subquery
+// placeholder and parsed as scopes of their own, whose parent is this one.
+// Start and End are the byte range the scope covers; End is -1 when it runs
+// to the end of the text, as an unclosed subquery does.
+type Scope struct {
+	Parent  *Scope
+	Verb    string
+	Lexemes []Lexeme
+	Subs    map[int]*Body
+	CTEs    map[string]*CTE
+	Tables  []*Table
+	Items   []SelectItem
+	Start   int
+	End     int
+
+	// Used marks lexemes that are not column references: table names,
+	// aliases and the like.
+	Used map[int]bool
+	// Assigned marks columns written by UPDATE ... SET or listed by INSERT;
+	// they belong to the first table.
+	Assigned map[int]bool
+	Aliases  map[string]bool
+	Using    map[string]bool
+	Natural  bool
+	// Partial is set when the tables could not all be parsed, so no name
+	// can be reported as unknown.
+	Partial bool
+	SetOp   bool
+}
+
+// SelectItem is the lexeme range of a select list expression and the index
+// of its alias, or -1.
+type SelectItem struct {
+	Start, End int
+	Alias      int
+}
+
+// Body is a query expression: one scope, or several joined by UNION,
+// INTERSECT or EXCEPT.
+type Body struct {
+	Parts []*Scope
+}
+
+// CTE is a WITH query with the column list written after its name.
+type CTE struct {
+	Name    string
+	Columns []string
+	Body    *Body
+}
+
+// Table is an entry of a FROM clause or the target of a statement. Start
+// and End are the byte range of its name.
+type Table struct {
+	Scope    *Scope
+	Parts    []string
+	Alias    string
+	Start    int
+	End      int
+	Body     *Body
+	Function bool
+	// Columns is the column alias list written after the alias.
+	Columns []string
+}
+
+// Label is how the table is referred to in the query.
+func (t *Table) Label() string {
+	if t.Alias != "" {
+		return t.Alias
+	}
+	if len(t.Parts) > 0 {
+		return t.Parts[len(t.Parts)-1]
+	}
+	return "subquery"
+}
+
+// CTE returns the CTE t names, or nil when t is not a CTE reference.
+func (t *Table) CTE() *CTE {
+	if t.Body != nil || t.Function || len(t.Parts) != 1 {
+		return nil
+	}
+	return t.Scope.LookupCTE(t.Parts[0])
+}
+
+// Output is a column a query produces: a named column, or a table all of
+// whose columns a * or t.* passes through.
+type Output struct {
+	Name string
+	Star *Table
+}
+
+// Outputs lists the columns produced by the first SELECT of b. Expressions
+// without a name are skipped. It reports false when the list could not be
+// resolved: the query is not a SELECT or a t.* names no visible table.
+func (b *Body) Outputs() ([]Output, bool) {
+	if len(b.Parts) == 0 {
+		return nil, false
+	}
+	s := b.Parts[0]
+	if s.Verb != "SELECT" {
+		if len(s.Lexemes) == 1 && s.Lexemes[0].Kind == Subquery {
+			return s.Subs[0].Outputs()
+		}
+		return nil, false
+	}
+
+	var out []Output
+	complete := true
+	for _, item := range s.Items {
+		lx := s.Lexemes[item.Start:item.End]
+		switch {
+		case item.Alias >= 0:
+			out = append(out, Output{Name: Unquote(s.Lexemes[item.Alias].Text)})
+		case len(lx) == 1 && lx[0].Text == "*":
+			for _, t := range s.Tables {
+				out = append(out, Output{Star: t})
+			}
+		case len(lx) == 2 && strings.HasSuffix(lx[0].Text, ".") && lx[1].Text == "*":
+			parts := SplitName(lx[0].Text)
+			t := s.FindTable(parts[len(parts)-2])
+			if t == nil {
+				complete = false
+				continue
+			}
+			out = append(out, Output{Star: t})
+		case len(lx) == 1 && isName(lx[0]):
+			out = append(out, Output{Name: Unquote(lx[0].Text)})
+		case len(lx) > 1 && lx[0].Kind == Word && lx[1].Kind == LParen:
+			// Postgres names an unaliased call after the function.
+			out = append(out, Output{Name: strings.ToLower(lx[0].Text)})
+		}
+	}
+	return out, complete
+}
+
+// LookupCTE returns the CTE called name visible from s, or nil.
+func (s *Scope) LookupCTE(name string) *CTE {
+	for sc := s; sc != nil; sc = sc.Parent {
+		if c := sc.CTEs[strings.ToUpper(name)]; c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// FindTable returns the table visible from s that name refers to: its alias,
+// or its name when it has none.
+func (s *Scope) FindTable(name string) *Table {
+	for sc := s; sc != nil; sc = sc.Parent {
+		for _, t := range sc.Tables {
+			if strings.EqualFold(t.Label(), name) && (t.Alias != "" || t.Body == nil) {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// FindQualifiedTable returns the table visible from s named schema.name.
+func (s *Scope) FindQualifiedTable(schema, name string) *Table {
+	for sc := s; sc != nil; sc = sc.Parent {
+		for _, t := range sc.Tables {
+			if len(t.Parts) == 2 && strings.EqualFold(t.Parts[0], schema) && strings.EqualFold(t.Parts[1], name) {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// PartialChain reports whether s or a scope enclosing it is partial.
+func (s *Scope) PartialChain() bool {
+	for sc := s; sc != nil; sc = sc.Parent {
+		if sc.Partial {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports whether the byte offset falls in s.
+func (s *Scope) Contains(offset int) bool {
+	return s.Start <= offset && (s.End < 0 || offset <= s.End)
+}
+
+// Parse parses a statement into scopes. Every scope is listed after its
+// parent.
+func Parse(stmt []Lexeme) []*Scope {
+	p := &parser{}
+	p.parseStatement(stmt)
+	return p.scopes
+}
+
+// ScopeAt returns the innermost of scopes containing the byte offset, or
+// nil.
+func ScopeAt(scopes []*Scope, offset int) *Scope {
+	var found *Scope
+	for _, s := range scopes {
+		if s.Contains(offset) && (found == nil || s.Start >= found.Start) {
+			found = s
+		}
+	}
+	return found
+}
+
+type parser struct {
+	scopes []*Scope
+}
+
+func startsQuery(l Lexeme) bool {
+	return l.Is("SELECT") || l.Is("WITH") || l.Is("VALUES")
+}
+
+func (p *parser) parseStatement(lx []Lexeme) {
+	if len(lx) == 0 {
+		return
+	}
+	i := 0
+	if lx[0].Is("EXPLAIN") {
+		i, _ = SkipExplain(lx, 0)
+	}
+	if i >= len(lx) {
+		return
+	}
+
+	switch lx[i].Upper() {
+	case "SELECT", "WITH", "VALUES", "UPDATE", "DELETE", "INSERT", "REPLACE":
+		p.parseBody(lx[i:], nil, lx[i].Start, -1)
+	case "CREATE":
+		// CREATE TABLE ... AS SELECT, CREATE VIEW ... AS SELECT
+		for as := FindTopLevel(lx, i+1, "AS"); as >= 0 && as+1 < len(lx); as = FindTopLevel(lx, as+1, "AS") {
+			if startsQuery(lx[as+1]) || lx[as+1].Kind == LParen {
+				p.parseBody(lx[as+1:], nil, lx[as+1].Start, -1)
+				return
+			}
+		}
+	default:
+		if lx[i].Kind == LParen {
+			p.parseBody(lx[i:], nil, lx[i].Start, -1)
+		}
+	}
+}
+
+// inside returns the byte range between the paren at lx[open] and its match
+// at lx[close]. An unclosed paren runs to end, the end of the enclosing
+// range.
+func inside(lx []Lexeme, open, close, end int) (int, int) {
+	if close < len(lx) {
+		return lx[open].End, lx[close].Start
+	}
+	return lx[open].End, end
+}
+
+// parseBody parses the query expression lx covering the byte range start
+// to end.
+func (p *parser) parseBody(lx []Lexeme, parent *Scope, start, end int) *Body {
+	for len(lx) > 1 && lx[0].Kind == LParen && MatchingParen(lx, 0) == len(lx)-1 {
+		start, end = inside(lx, 0, len(lx)-1, end)
+		lx = lx[1 : len(lx)-1]
+	}
+	b := &Body{}
+	if len(lx) == 0 {
+		return b
+	}
+
+	switch lx[0].Upper() {
+	case "WITH":
+		holder := &Scope{Parent: parent, CTEs: map[string]*CTE{}, Start: start, End: end}
+		return p.parseBody(p.parseCTEs(lx, holder, end), holder, start, end)
+	case "UPDATE", "DELETE":
+		b.Parts = []*Scope{p.parseModify(lx, parent, start, end)}
+		return b
+	case "INSERT", "REPLACE":
+		b.Parts = []*Scope{p.parseInsert(lx, parent, start, end)}
+		return b
+	}
+
+	i, partStart := 0, start
+	for {
+		op := FindTopLevel(lx, i, "UNION", "INTERSECT", "EXCEPT", "MINUS")
+		j, partEnd := op, end
+		if op < 0 {
+			j = len(lx)
+		} else {
+			partEnd = lx[op].Start
+		}
+		part := lx[i:j]
+		for len(part) > 0 && (part[0].Is("ALL") || part[0].Is("DISTINCT")) {
+			part = part[1:]
+		}
+		b.Parts = append(b.Parts, p.parseSelect(part, parent, partStart, partEnd))
+		if op < 0 {
+			break
+		}
+		i, partStart = op+1, lx[op].End
+	}
+	if len(b.Parts) > 1 {
+		for _, s := range b.Parts {
+			s.SetOp = true
+		}
+	}
+	return b
+}
+
+// parseCTEs registers the CTEs of WITH ... in holder and returns the
+// statement that follows them. end is the end of the byte range lx covers.
+func (p *parser) parseCTEs(lx []Lexeme, holder *Scope, end int) []Lexeme {
+	i := 1
+	if i < len(lx) && lx[i].Is("RECURSIVE") {
+		i++
+	}
+	for i < len(lx) {
+		if lx[i].Kind != Word && lx[i].Kind != Ident {
+			return nil
+		}
+		name := Unquote(lx[i].Text)
+		c := &CTE{Name: name}
+		i++
+		if i < len(lx) && lx[i].Kind == LParen {
+			close := MatchingParen(lx, i)
+			c.Columns = nameList(lx[i+1 : min(close, len(lx))])
+			i = close + 1
+		}
+		if i >= len(lx) || !lx[i].Is("AS") {
+			return nil
+		}
+		i++
+		for i < len(lx) && (lx[i].Is("NOT") || lx[i].Is("MATERIALIZED")) {
+			i++
+		}
+		if i >= len(lx) || lx[i].Kind != LParen {
+			return nil
+		}
+		close := MatchingParen(lx, i)
+		// Register the name first so a recursive CTE can see itself.
+		holder.CTEs[strings.ToUpper(name)] = c
+		bodyStart, bodyEnd := inside(lx, i, close, end)
+		c.Body = p.parseBody(lx[i+1:min(close, len(lx))], holder, bodyStart, bodyEnd)
+		i = close + 1
+		if i < len(lx) && lx[i].Kind == Comma {
+			i++
+			continue
+		}
+		break
+	}
+	if i >= len(lx) {
+		return nil
+	}
+	return lx[i:]
+}
+
+// newScope creates a scope over lx with its subqueries parsed as children.
+func (p *parser) newScope(lx []Lexeme, parent *Scope, verb string, start, end int) *Scope {
+	s := &Scope{
+		Parent:   parent,
+		Verb:     verb,
+		Start:    start,
+		End:      end,
+		Subs:     map[int]*Body{},
+		Used:     map[int]bool{},
+		Assigned: map[int]bool{},
+		Aliases:  map[string]bool{},
+		Using:    map[string]bool{},
+	}
+	p.scopes = append(p.scopes, s)
+
+	for i := 0; i < len(lx); i++ {
+		if lx[i].Kind == LParen && i+1 < len(lx) && startsQuery(lx[i+1]) {
+			close := MatchingParen(lx, i)
+			subStart, subEnd := inside(lx, i, close, end)
+			n := len(s.Lexemes)
+			s.Lexemes = append(s.Lexemes, Lexeme{Kind: Subquery, Start: lx[i].Start, End: lx[min(close, len(lx)-1)].End})
+			s.Subs[n] = p.parseBody(lx[i+1:min(close, len(lx))], s, subStart, subEnd)
+			i = close
+			continue
+		}
+		s.Lexemes = append(s.Lexemes, lx[i])
+	}
+	return s
+}
+
+func (s *Scope) markUsed(from, to int) {
+	for i := from; i < to && i < len(s.Lexemes); i++ {
+		s.Used[i] = true
+	}
+}
+
+func (p *parser) parseSelect(lx []Lexeme, parent *Scope, start, end int) *Scope {
+	s := p.newScope(lx, parent, "SELECT", start, end)
+	if len(s.Lexemes) == 0 || !s.Lexemes[0].Is("SELECT") {
+		s.Verb = ""
+		s.markUsed(0, len(s.Lexemes))
+		return s
+	}
+	s.Used[0] = true
+
+	itemsEnd := FindTopLevel(s.Lexemes, 1, "FROM", "INTO", "WHERE", "GROUP", "HAVING", "WINDOW", "ORDER", "LIMIT", "OFFSET", "FETCH", "FOR")
+	if itemsEnd < 0 {
+		itemsEnd = len(s.Lexemes)
+	}
+	s.parseSelectItems(1, itemsEnd)
+
+	if into := FindTopLevel(s.Lexemes, 1, "INTO"); into >= 0 {
+		end := FindTopLevel(s.Lexemes, into+1, "FROM", "WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "FOR")
+		if end < 0 {
+			end = len(s.Lexemes)
+		}
+		s.markUsed(into, end)
+	}
+	if from := FindTopLevel(s.Lexemes, 1, "FROM"); from >= 0 {
+		end := FindTopLevel(s.Lexemes, from+1, "WHERE", "GROUP", "HAVING", "WINDOW", "ORDER", "LIMIT", "OFFSET", "FETCH", "FOR", "QUALIFY")
+		if end < 0 {
+			end = len(s.Lexemes)
+		}
+		s.Used[from] = true
+		s.parseFrom(from+1, end)
+	}
+	return s
+}
+
+var selectModifiers = map[string]bool{
+	"ALL": true, "DISTINCT": true, "DISTINCTROW": true, "HIGH_PRIORITY": true,
+	"STRAIGHT_JOIN": true, "SQL_SMALL_RESULT": true, "SQL_BIG_RESULT": true,
+	"SQL_BUFFER_RESULT": true, "SQL_NO_CACHE": true, "SQL_CALC_FOUND_ROWS": true,
+}
+
+// parseSelectItems splits the select list at commas and marks the aliases.
+func (s *Scope) parseSelectItems(i, end int) {
+	for i < end && selectModifiers[s.Lexemes[i].Upper()] {
+		s.Used[i] = true
+		i++
+	}
+	// DISTINCT ON (...) expressions stay checked but are not items.
+	if i < end && s.Lexemes[i].Is("ON") && i+1 < end && s.Lexemes[i+1].Kind == LParen {
+		s.Used[i] = true
+		i = MatchingParen(s.Lexemes, i+1) + 1
+	}
+
+	start, depth := i, 0
+	for j := i; j < end; j++ {
+		switch s.Lexemes[j].Kind {
+		case LParen:
+			depth++
+		case RParen:
+			depth--
+		case Comma:
+			if depth == 0 {
+				s.addSelectItem(start, j)
+				start = j + 1
+			}
+		}
+	}
+	s.addSelectItem(start, end)
+}
+
+func (s *Scope) addSelectItem(start, end int) {
+	if end <= start {
+		return
+	}
+	item := SelectItem{Start: start, End: end, Alias: -1}
+	last := end - 1
+	if end-start >= 2 && isName(s.Lexemes[last]) {
+		prev := s.Lexemes[last-1]
+		switch {
+		case prev.Is("AS"):
+			item.Alias, item.End = last, last-1
+			s.Used[last-1] = true
+		case prev.Kind == Word && !reservedWords[prev.Upper()], prev.Kind == Ident, prev.Kind == Number,
+			prev.Kind == String, prev.Kind == RParen, prev.Kind == Subquery:
+			if !(prev.Kind == Ident && strings.HasSuffix(prev.Text, ".")) {
+				item.Alias, item.End = last, last
+			}
+		}
+	}
+	if item.Alias >= 0 {
+		s.Used[item.Alias] = true
+		s.Aliases[strings.ToUpper(Unquote(s.Lexemes[item.Alias].Text))] = true
+	}
+	s.Items = append(s.Items, item)
+}
+
+// isName reports whether l can name a table, alias or column.
+func isName(l Lexeme) bool {
+	return l.Kind == Ident && !strings.HasSuffix(l.Text, ".") || l.Kind == Word && !reservedWords[l.Upper()]
+}
+
+var joinWords = map[string]bool{
+	"JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true,
+	"OUTER": true, "CROSS": true, "NATURAL": true, "STRAIGHT_JOIN": true,
+}
+
+// parseFrom parses the table list in lx[i:end]. ON conditions are left to
+// be checked as expressions.
+func (s *Scope) parseFrom(i, end int) {
+	expectTable := true
+	for i < end {
+		l := s.Lexemes[i]
+		switch {
+		case l.Kind == Comma:
+			expectTable = true
+		case l.Kind == Word && joinWords[l.Upper()] && !(i+1 < end && s.Lexemes[i+1].Kind == LParen):
+			if l.Is("NATURAL") {
+				s.Natural = true
+			}
+			if l.Is("JOIN") || l.Is("STRAIGHT_JOIN") {
+				expectTable = true
+			}
+		case l.Is("ON"):
+			s.Used[i] = true
+			i = s.skipCondition(i+1, end)
+			continue
+		case l.Is("USING") && i+1 < end && s.Lexemes[i+1].Kind == LParen:
+			close := MatchingParen(s.Lexemes, i+1)
+			for _, name := range s.Lexemes[i+2 : min(close, end)] {
+				if isName(name) {
+					s.Using[strings.ToUpper(Unquote(name.Text))] = true
+				}
+			}
+			s.markUsed(i, close+1)
+			i = close + 1
+			continue
+		case expectTable:
+			expectTable = false
+			if next := s.parseTable(i, end); next > i {
+				i = next
+				continue
+			}
+		}
+		s.Used[i] = true
+		i++
+	}
+}
+
+// skipCondition returns the end of the join condition starting at i.
+func (s *Scope) skipCondition(i, end int) int {
+	depth := 0
+	for ; i < end; i++ {
+		switch l := s.Lexemes[i]; {
+		case l.Kind == LParen:
+			depth++
+		case l.Kind == RParen:
+			depth--
+		case depth == 0 && l.Kind == Comma:
+			return i
+		case depth == 0 && l.Kind == Word && joinWords[l.Upper()] && !(i+1 < end && s.Lexemes[i+1].Kind == LParen):
+			return i
+		}
+	}
+	return end
+}
+
+// parseTable parses one table, derived table or table function with its
+// alias and returns the index after it.
+func (s *Scope) parseTable(i, end int) int {
+	for i < end && (s.Lexemes[i].Is("LATERAL") || s.Lexemes[i].Is("ONLY")) {
+		s.Used[i] = true
+		i++
+	}
+	if i >= end {
+		return i
+	}
+
+	l := s.Lexemes[i]
+	t := &Table{Scope: s, Start: l.Start, End: l.End}
+	switch {
+	case l.Kind == Subquery:
+		t.Body = s.Subs[i]
+		s.Used[i] = true
+		i++
+	case l.Kind == LParen:
+		// A parenthesized join; its tables are not tracked.
+		s.Partial = true
+		close := min(MatchingParen(s.Lexemes, i), end-1)
+		s.markUsed(i, close+1)
+		return close + 1
+	case isName(l):
+		t.Parts = SplitName(l.Text)
+		s.Used[i] = true
+		i++
+		if i < end && s.Lexemes[i].Kind == LParen {
+			t.Function = true
+			close := min(MatchingParen(s.Lexemes, i), end-1)
+			s.markUsed(i, close+1)
+			i = close + 1
+		}
+	default:
+		return i
+	}
+
+	if i < end && s.Lexemes[i].Is("AS") {
+		s.Used[i] = true
+		i++
+	}
+	if i < end && isName(s.Lexemes[i]) {
+		t.Alias = Unquote(s.Lexemes[i].Text)
+		s.Used[i] = true
+		i++
+		if i < end && s.Lexemes[i].Kind == LParen {
+			close := MatchingParen(s.Lexemes, i)
+			t.Columns = nameList(s.Lexemes[i+1 : min(close, end)])
+			close = min(close, end-1)
+			s.markUsed(i, close+1)
+			i = close + 1
+		}
+	}
+	s.Tables = append(s.Tables, t)
+	return i
+}
+
+// parseModify parses UPDATE ... SET ... [FROM ...] and
+// DELETE [targets] FROM ... [USING ...].
+func (p *parser) parseModify(lx []Lexeme, parent *Scope, start, end int) *Scope {
+	s := p.newScope(lx, parent, lx[0].Upper(), start, end)
+	s.Used[0] = true
+	stops := []string{"WHERE", "RETURNING", "ORDER", "LIMIT"}
+
+	if s.Verb == "DELETE" {
+		from := FindTopLevel(s.Lexemes, 1, "FROM")
+		if from < 0 {
+			s.Partial = true
+			return s
+		}
+		s.markUsed(1, from+1)
+		end := FindTopLevel(s.Lexemes, from+1, append(stops, "USING")...)
+		if end < 0 {
+			end = len(s.Lexemes)
+		}
+		s.parseFrom(from+1, end)
+		if end < len(s.Lexemes) && s.Lexemes[end].Is("USING") {
+			s.Used[end] = true
+			usingEnd := FindTopLevel(s.Lexemes, end+1, stops...)
+			if usingEnd < 0 {
+				usingEnd = len(s.Lexemes)
+			}
+			s.parseFrom(end+1, usingEnd)
+		}
+		return s
+	}
+
+	set := FindTopLevel(s.Lexemes, 1, "SET")
+	targetEnd := set
+	if set < 0 {
+		targetEnd = len(s.Lexemes)
+	}
+	i := 1
+	for i < targetEnd && (s.Lexemes[i].Is("ONLY") || s.Lexemes[i].Is("LOW_PRIORITY") || s.Lexemes[i].Is("IGNORE") || s.Lexemes[i].Is("OR")) {
+		if s.Lexemes[i].Is("OR") {
+			s.Used[i] = true
+			i++
+		}
+		s.Used[i] = true
+		i++
+	}
+	s.parseFrom(i, targetEnd)
+	if set < 0 {
+		// The SET clause is still being written.
+		s.Partial = true
+		return s
+	}
+	s.Used[set] = true
+
+	setEnd := FindTopLevel(s.Lexemes, set+1, append(stops, "FROM")...)
+	if setEnd < 0 {
+		setEnd = len(s.Lexemes)
+	}
+	depth := 0
+	for j := set + 1; j < setEnd; j++ {
+		switch s.Lexemes[j].Kind {
+		case LParen:
+			depth++
+		case RParen:
+			depth--
+		}
+		if depth == 0 && (j == set+1 || s.Lexemes[j-1].Kind == Comma) && j+1 < setEnd &&
+			s.Lexemes[j+1].Kind == Operator && s.Lexemes[j+1].Text == "=" && isName(s.Lexemes[j]) {
+			s.Assigned[j] = true
+		}
+	}
+	if setEnd < len(s.Lexemes) && s.Lexemes[setEnd].Is("FROM") {
+		s.Used[setEnd] = true
+		end := FindTopLevel(s.Lexemes, setEnd+1, stops...)
+		if end < 0 {
+			end = len(s.Lexemes)
+		}
+		s.parseFrom(setEnd+1, end)
+	}
+	return s
+}
+
+// parseInsert parses the target and column list of INSERT or REPLACE. A
+// SELECT source is parsed as a sibling scope, since the target is not
+// visible in it; ON CONFLICT, ON DUPLICATE KEY and RETURNING are skipped.
+func (p *parser) parseInsert(lx []Lexeme, parent *Scope, start, end int) *Scope {
+	source := FindTopLevel(lx, 1, "SELECT", "VALUES", "VALUE", "WITH", "TABLE", "DEFAULT", "SET")
+	if source < 0 {
+		source = len(lx)
+	}
+	s := p.newScope(lx[:source], parent, lx[0].Upper(), start, end)
+	s.markUsed(0, len(s.Lexemes))
+
+	if source < len(lx) && (lx[source].Is("SELECT") || lx[source].Is("WITH")) {
+		sourceEnd, byteEnd := len(lx), end
+		for on := FindTopLevel(lx, source, "ON", "RETURNING"); on >= 0; on = FindTopLevel(lx, on+1, "ON", "RETURNING") {
+			if lx[on].Is("RETURNING") || on+1 < len(lx) && (lx[on+1].Is("CONFLICT") || lx[on+1].Is("DUPLICATE")) {
+				sourceEnd, byteEnd = on, lx[on].Start
+				break
+			}
+		}
+		p.parseBody(lx[source:sourceEnd], parent, lx[source].Start, byteEnd)
+	}
+
+	into := FindTopLevel(s.Lexemes, 1, "INTO")
+	if into < 0 || into+1 >= len(s.Lexemes) || !isName(s.Lexemes[into+1]) {
+		s.Partial = true
+		return s
+	}
+	i := into + 1
+	t := &Table{Scope: s, Parts: SplitName(s.Lexemes[i].Text), Start: s.Lexemes[i].Start, End: s.Lexemes[i].End}
+	s.Tables = append(s.Tables, t)
+	i++
+	if i+1 < len(s.Lexemes) && s.Lexemes[i].Is("AS") {
+		t.Alias = Unquote(s.Lexemes[i+1].Text)
+		i += 2
+	}
+	if i < len(s.Lexemes) && s.Lexemes[i].Kind == LParen {
+		close := MatchingParen(s.Lexemes, i)
+		for j := i + 1; j < close && j < len(s.Lexemes); j++ {
+			if isName(s.Lexemes[j]) {
+				s.Used[j] = false
+				s.Assigned[j] = true
+			}
+		}
+	}
+	return s
+}
+
+// nameList returns the names in a parenthesized list such as a CTE's
+// column list.
+func nameList(lx []Lexeme) []string {
+	var names []string
+	for _, l := range lx {
+		if isName(l) {
+			names = append(names, Unquote(l.Text))
+		}
+	}
+	return names
+}
+
+// IsReserved reports whether l is a keyword that is never a column
+// reference or alias.
+func IsReserved(l Lexeme) bool {
+	return l.Kind == Word && reservedWords[l.Upper()]
+}
+
+var reservedWords = map[string]bool{
+	"ALL": true, "AND": true, "ANY": true, "ARRAY": true, "AS": true, "ASC": true,
+	"ASYMMETRIC": true, "AT": true, "BETWEEN": true, "BINARY": true, "BOTH": true,
+	"BY": true, "CASE": true, "CAST": true, "COLLATE": true, "CONFLICT": true,
+	"CONSTRAINT": true, "CROSS": true, "CURRENT_DATE": true, "CURRENT_TIME": true,
+	"CURRENT_TIMESTAMP": true, "CURRENT_USER": true, "DEFAULT": true, "DELETE": true,
+	"DESC": true, "DISTINCT": true, "DISTINCTROW": true, "DIV": true, "DO": true,
+	"DUAL": true, "DUPLICATE": true, "ELSE": true, "END": true, "ESCAPE": true,
+	"EXCEPT": true, "EXISTS": true, "FALSE": true, "FETCH": true, "FILTER": true,
+	"FIRST": true, "FOLLOWING": true, "FOR": true, "FROM": true, "FULL": true,
+	"GLOB": true, "GROUP": true, "GROUPS": true, "HAVING": true, "ILIKE": true,
+	"IN": true, "INNER": true, "INSERT": true, "INTERSECT": true, "INTERVAL": true,
+	"INTO": true, "IS": true, "ISNULL": true, "JOIN": true, "KEY": true, "LAST": true,
+	"LATERAL": true, "LEFT": true, "LIKE": true, "LIMIT": true, "LOCALTIME": true,
+	"LOCALTIMESTAMP": true, "MATERIALIZED": true, "MINUS": true, "MOD": true,
+	"NATURAL": true, "NOT": true, "NOTNULL": true, "NOTHING": true, "NULL": true,
+	"NULLS": true, "OFFSET": true, "ON": true, "ONLY": true, "OR": true,
+	"ORDER": true, "OUTER": true, "OVER": true, "PARTITION": true, "PRECEDING": true,
+	"RANGE": true, "RECURSIVE": true, "REGEXP": true, "RETURNING": true,
+	"RIGHT": true, "RLIKE": true, "ROWS": true, "SELECT": true, "SESSION_USER": true,
+	"SET": true, "SIMILAR": true, "SOME": true, "STRAIGHT_JOIN": true,
+	"SYMMETRIC": true, "THEN": true, "TIES": true, "TO": true, "TRUE": true,
+	"UNBOUNDED": true, "UNION": true, "UNKNOWN": true, "UPDATE": true,
+	"USING": true, "VALUES": true, "WHEN": true, "WHERE": true, "WINDOW": true,
+	"WITH": true, "WITHIN": true, "XOR": true, "ZONE": true, "CURRENT": true,
+	"ROW": true, "EXCLUDE": true, "QUALIFY": true, "TABLESAMPLE": true,
+}
@@ -0,0 +1,156 @@
+package sqlparse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func labels(tables []*Table) []string {
+	var names []string
+	for _, t := range tables {
+		names = append(names, t.Label())
+	}
+	return names
+}
+
+func TestScopeAt(t *testing.T) {
+	tests := []struct {
+		name   string
+		sql    string
+		tables []string
+		parent []string
+	}{
+		{
+			name:   "subquery",
+			sql:    "SELECT * FROM users u WHERE u.id IN (SELECT | FROM orders o)",
+			tables: []string{"o"},
+			parent: []string{"u"},
+		},
+		{
+			name:   "unclosed subquery",
+			sql:    "SELECT * FROM users WHERE id IN (SELECT user_id FROM orders WHERE |",
+			tables: []string{"orders"},
+			parent: []string{"users"},
+		},
+		{
+			name:   "second part of a union",
+			sql:    "SELECT id FROM users UNION SELECT | FROM orders",
+			tables: []string{"orders"},
+		},
+		{
+			name:   "main query after a CTE",
+			sql:    "WITH c AS (SELECT id FROM orders) SELECT | FROM c",
+			tables: []string{"c"},
+		},
+		{
+			name:   "INSERT returning after its source",
+			sql:    "INSERT INTO users (id) SELECT id FROM orders RETURNING |",
+			tables: []string{"users"},
+		},
+		{
+			name:   "UPDATE before SET is written",
+			sql:    "UPDATE users u |",
+			tables: []string{"u"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset := strings.Index(tt.sql, "|")
+			sql := tt.sql[:offset] + tt.sql[offset+1:]
+			s := ScopeAt(Parse(StatementAt(Lex(sql, "postgres"), offset)), offset)
+			if s == nil {
+				t.Fatal("no scope at the cursor")
+			}
+			if got := labels(s.Tables); !reflect.DeepEqual(got, tt.tables) {
+				t.Errorf("tables = %v, want %v", got, tt.tables)
+			}
+			var parent []string
+			if s.Parent != nil {
+				parent = labels(s.Parent.Tables)
+			}
+			if !reflect.DeepEqual(parent, tt.parent) {
+				t.Errorf("parent tables = %v, want %v", parent, tt.parent)
+			}
+		})
+	}
+}
+
+func TestStatementAt(t *testing.T) {
+	sql := "SELECT 1; SELECT (2; 3); "
+	lx := Lex(sql, "postgres")
+	if got := StatementAt(lx, 3); len(got) != 2 || got[1].Text != "1" {
+		t.Errorf("first statement = %v", got)
+	}
+	if got := StatementAt(lx, strings.Index(sql, "3")); len(got) != 6 {
+		t.Errorf("second statement = %v", got)
+	}
+	if got := StatementAt(lx, len(sql)); len(got) != 0 {
+		t.Errorf("after the last semicolon = %v", got)
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	tests := []struct {
+		sql      string
+		names    []string
+		stars    []string
+		complete bool
+	}{
+		{
+			sql:      "SELECT o.id, total AS amount, count(*), a + b, x.* FROM orders o JOIN users x ON true",
+			names:    []string{"id", "amount", "count"},
+			stars:    []string{"x"},
+			complete: true,
+		},
+		{
+			sql:      "SELECT * FROM users, (SELECT 1) s",
+			stars:    []string{"users", "s"},
+			complete: true,
+		},
+		{
+			sql:      "(SELECT id FROM users) UNION SELECT id FROM orders",
+			names:    []string{"id"},
+			complete: true,
+		},
+		{
+			sql:   "SELECT missing.* FROM users",
+			names: nil,
+		},
+		{
+			sql: "VALUES (1)",
+		},
+	}
+
+	for _, tt := range tests {
+		p := &parser{}
+		lx := Lex(tt.sql, "postgres")
+		b := p.parseBody(lx, nil, 0, -1)
+		outputs, complete := b.Outputs()
+		var names, stars []string
+		for _, out := range outputs {
+			if out.Star != nil {
+				stars = append(stars, out.Star.Label())
+				continue
+			}
+			names = append(names, out.Name)
+		}
+		if !reflect.DeepEqual(names, tt.names) || !reflect.DeepEqual(stars, tt.stars) || complete != tt.complete {
+			t.Errorf("%q: names %v, stars %v, complete %v", tt.sql, names, stars, complete)
+		}
+	}
+}
+
+func TestParseUnfinished(t *testing.T) {
+	sql := "SELECT n FROM (SELECT id AS n FROM orders) AS t(m), (SELECT * FROM (SELECT a FROM t"
+	for n := range len(sql) + 1 {
+		for _, s := range Parse(Lex(sql[:n], "postgres")) {
+			for _, table := range s.Tables {
+				if table.Body != nil {
+					table.Body.Outputs()
+				}
+			}
+		}
+	}
+}
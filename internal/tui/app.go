@@ -2,6 +2,8 @@ package tui
 
 import (
 	"github.com/android-lewis/dbsmith/internal/app"
+	"github.com/android-lewis/dbsmith/internal/executor"
+	"github.com/android-lewis/dbsmith/internal/secrets"
 	"github.com/android-lewis/dbsmith/internal/tui/components"
 	"github.com/android-lewis/dbsmith/internal/tui/editor"
 	"github.com/android-lewis/dbsmith/internal/tui/explorer"
@@ -36,6 +38,10 @@ func NewTUIApp(application *app.App) *TUIApp {
 		statusBar: components.NewStatusBar(application),
 	}
 
+	application.OnHealthChange = func(executor.HealthState) {
+		tuiApp.app.QueueUpdateDraw(tuiApp.statusBar.Update)
+	}
+
 	tuiApp.workspace = workspace.NewWorkspace(tuiApp.app, tuiApp.pages, application, tuiApp.helpBar, tuiApp.statusBar)
 
 	tuiApp.workspace.SetConnectionSelectedCallback(func() {
@@ -134,5 +140,9 @@ func Run(application *app.App) error {
 		tuiApp.ShowExplorer()
 	}
 
+	if lockable, ok := application.SecretsManager.(secrets.Lockable); ok && lockable.Locked() {
+		components.ShowUnlockPrompt(tuiApp.pages, tuiApp.app, lockable, nil)
+	}
+
 	return tuiApp.app.Run()
 }
@@ -1,9 +1,14 @@
 package components
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/android-lewis/dbsmith/internal/db"
 	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/android-lewis/dbsmith/internal/secrets"
 	"github.com/rivo/tview"
 )
 
@@ -55,9 +60,16 @@ func (m *ConnectionFormManager) ShowEditConnectionForm(conn *models.Connection)
 
 func (m *ConnectionFormManager) showConnectionForm(config ConnectionFormConfig) {
 	title := m.getFormTitle(config.IsEdit)
-	fields := m.buildFormFields(config)
 
-	dialog := NewFormDialog(m.pages, m.app, FormDialogConfig{
+	var dialog *FormDialog
+	updatePreview := func() {
+		if dialog != nil {
+			dialog.SetText(resolvedLabel, connectionPreview(dialog.GetValue))
+		}
+	}
+	fields := m.buildFormFields(config, updatePreview)
+
+	dialog = NewFormDialog(m.pages, m.app, FormDialogConfig{
 		Title:       title,
 		Fields:      fields,
 		SubmitLabel: "Save",
@@ -67,6 +79,7 @@ func (m *ConnectionFormManager) showConnectionForm(config ConnectionFormConfig)
 		OnSubmit:    m.createSubmitHandler(config.IsEdit),
 	})
 
+	updatePreview()
 	dialog.Show()
 }
 
@@ -77,10 +90,19 @@ func (m *ConnectionFormManager) getFormTitle(isEdit bool) string {
 	return " New Connection "
 }
 
-func (m *ConnectionFormManager) buildFormFields(config ConnectionFormConfig) []FormField {
+// resolvedLabel is the read-only form line previewing host, port, database
+// and username with their ${...} references resolved.
+const resolvedLabel = "Resolved"
+
+// secretRefLabel is the form field naming an external secret provider, such
+// as env:PGPASSWORD or cmd:vault kv get ..., used instead of a stored password.
+const secretRefLabel = "Secret Ref"
+
+func (m *ConnectionFormManager) buildFormFields(config ConnectionFormConfig, onChange func()) []FormField {
 	defaults := m.getDefaultValues(config)
 	dbTypes := []string{"postgres", "mysql", "sqlite"}
 	sslModes := []string{"disable", "prefer", "require"}
+	changed := func(string) { onChange() }
 
 	return []FormField{
 		{Type: FieldTypeInput, Label: "Name", InitialValue: defaults["name"], FieldWidth: 30},
@@ -89,30 +111,38 @@ func (m *ConnectionFormManager) buildFormFields(config ConnectionFormConfig) []F
 			Label:        "Type",
 			Options:      dbTypes,
 			InitialIndex: findIndex(dbTypes, defaults["dbType"]),
+			OnSelected:   func(string, int) { onChange() },
 		},
-		{Type: FieldTypeInput, Label: "Host", InitialValue: defaults["host"], FieldWidth: 30},
-		{Type: FieldTypeInput, Label: "Port", InitialValue: defaults["port"], FieldWidth: 10},
-		{Type: FieldTypeInput, Label: "Database", InitialValue: defaults["database"], FieldWidth: 30},
-		{Type: FieldTypeInput, Label: "Username", InitialValue: defaults["username"], FieldWidth: 30},
+		{Type: FieldTypeInput, Label: "Host", InitialValue: defaults["host"], FieldWidth: 30, OnChanged: changed},
+		{Type: FieldTypeInput, Label: "Port", InitialValue: defaults["port"], FieldWidth: 15, OnChanged: changed},
+		{Type: FieldTypeInput, Label: "Database", InitialValue: defaults["database"], FieldWidth: 30, OnChanged: changed},
+		{Type: FieldTypeInput, Label: "Username", InitialValue: defaults["username"], FieldWidth: 30, OnChanged: changed},
+		{Type: FieldTypeText, Label: resolvedLabel, FieldWidth: 40},
 		{Type: FieldTypePassword, Label: "Password", FieldWidth: 30},
+		{Type: FieldTypeInput, Label: secretRefLabel, InitialValue: defaults["secretRef"], FieldWidth: 40},
 		{
 			Type:         FieldTypeDropDown,
 			Label:        "SSL",
 			Options:      sslModes,
 			InitialIndex: findIndex(sslModes, defaults["ssl"]),
 		},
+		{Type: FieldTypeInput, Label: "Environment", InitialValue: defaults["environment"], FieldWidth: 15},
+		{Type: FieldTypeCheckbox, Label: "Read Only", InitialValue: defaults["readOnly"]},
 	}
 }
 
 func (m *ConnectionFormManager) getDefaultValues(config ConnectionFormConfig) map[string]string {
 	defaults := map[string]string{
-		"name":     "",
-		"dbType":   "postgres",
-		"host":     "localhost",
-		"port":     "5432",
-		"database": "",
-		"username": "",
-		"ssl":      "prefer",
+		"name":        "",
+		"dbType":      "postgres",
+		"host":        "localhost",
+		"port":        "5432",
+		"database":    "",
+		"username":    "",
+		"ssl":         "prefer",
+		"environment": "",
+		"readOnly":    "false",
+		"secretRef":   "",
 	}
 
 	if config.IsEdit && config.ExistingConn != nil {
@@ -121,9 +151,17 @@ func (m *ConnectionFormManager) getDefaultValues(config ConnectionFormConfig) ma
 		defaults["dbType"] = string(conn.Type)
 		defaults["host"] = conn.Host
 		defaults["port"] = fmt.Sprintf("%d", conn.Port)
+		if conn.PortTemplate != "" {
+			defaults["port"] = conn.PortTemplate
+		}
 		defaults["database"] = conn.Database
 		defaults["username"] = conn.Username
 		defaults["ssl"] = conn.SSL
+		defaults["environment"] = string(conn.Environment)
+		defaults["readOnly"] = fmt.Sprintf("%t", conn.ReadOnly)
+		if secrets.IsProviderRef(conn.SecretKeyID) {
+			defaults["secretRef"] = conn.SecretKeyID
+		}
 	}
 
 	return defaults
@@ -165,27 +203,51 @@ func (m *ConnectionFormManager) validateFormValues(values map[string]string, isE
 	if values["Database"] == "" {
 		return fmt.Errorf("database name is required")
 	}
-	if !isEdit && values["Password"] == "" {
-		return fmt.Errorf("password is required for new connections")
+	if port := strings.TrimSpace(values["Port"]); port != "" && !db.HasReferences(port) {
+		if _, err := strconv.Atoi(port); err != nil {
+			return fmt.Errorf("port must be a number or a ${VAR} reference")
+		}
+	}
+	ref := strings.TrimSpace(values[secretRefLabel])
+	if ref != "" && !secrets.IsProviderRef(ref) {
+		return fmt.Errorf("secret ref must start with env:, file:, cmd: or pass:")
+	}
+	if ref != "" && values["Password"] != "" {
+		return fmt.Errorf("enter either a password or a secret ref, not both")
+	}
+	if !isEdit && values["Password"] == "" && ref == "" {
+		return fmt.Errorf("a password or secret ref is required for new connections")
 	}
 	return nil
 }
 
 func (m *ConnectionFormManager) buildConnectionFromValues(values map[string]string) models.Connection {
 	port := 5432
-	_, _ = fmt.Sscanf(values["Port"], "%d", &port)
+	var portTemplate string
+	if db.HasReferences(values["Port"]) {
+		port = 0
+		portTemplate = strings.TrimSpace(values["Port"])
+	} else {
+		_, _ = fmt.Sscanf(values["Port"], "%d", &port)
+	}
 
 	secretKeyID := fmt.Sprintf("dbsmith_%s_%s", values["Name"], values["Type"])
+	if ref := strings.TrimSpace(values[secretRefLabel]); ref != "" {
+		secretKeyID = ref
+	}
 
 	return models.Connection{
-		Name:        values["Name"],
-		Type:        models.ConnectionType(values["Type"]),
-		Host:        values["Host"],
-		Port:        port,
-		Database:    values["Database"],
-		Username:    values["Username"],
-		SecretKeyID: secretKeyID,
-		SSL:         values["SSL"],
+		Name:         values["Name"],
+		Type:         models.ConnectionType(values["Type"]),
+		Host:         values["Host"],
+		Port:         port,
+		PortTemplate: portTemplate,
+		Database:     values["Database"],
+		Username:     values["Username"],
+		SecretKeyID:  secretKeyID,
+		SSL:          values["SSL"],
+		Environment:  models.Environment(strings.ToLower(strings.TrimSpace(values["Environment"]))),
+		ReadOnly:     values["Read Only"] == "true",
 	}
 }
 
@@ -197,3 +259,34 @@ func findIndex(slice []string, value string) int {
 	}
 	return 0
 }
+
+// connectionPreview shows the connection target with references resolved
+// and secrets masked, or why a reference cannot be resolved.
+func connectionPreview(value func(label string) string) string {
+	resolved := make(map[string]string)
+	for _, label := range []string{"Host", "Port", "Database", "Username"} {
+		v, err := db.PreviewReferences(strings.TrimSpace(value(label)))
+		if err != nil {
+			msg := strings.TrimPrefix(err.Error(), db.ErrUnresolvedReference.Error()+": ")
+			if !errors.Is(err, db.ErrUnresolvedReference) {
+				msg = err.Error()
+			}
+			return fmt.Sprintf("%s: %s", label, msg)
+		}
+		resolved[label] = v
+	}
+
+	if value("Type") == string(models.SQLiteType) {
+		return resolved["Database"]
+	}
+
+	target := resolved["Host"]
+	if resolved["Port"] != "" {
+		target += ":" + resolved["Port"]
+	}
+	target += "/" + resolved["Database"]
+	if resolved["Username"] != "" {
+		target = resolved["Username"] + "@" + target
+	}
+	return target
+}
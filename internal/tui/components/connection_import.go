@@ -0,0 +1,147 @@
+package components
+
+import (
+	"fmt"
+
+	"github.com/android-lewis/dbsmith/internal/importer"
+	"github.com/android-lewis/dbsmith/internal/tui/theme"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// ShowConnectionImport lists candidates found in other tools' configuration
+// for the user to pick from. Candidates whose name exists(name) reports as
+// taken start unchecked. onDone receives the checked candidates, or nil when
+// the dialog is cancelled.
+func ShowConnectionImport(pages *tview.Pages, app *tview.Application, candidates []importer.Candidate,
+	exists func(name string) bool, onDone func([]importer.Candidate)) {
+	const pageName = "connection-import"
+
+	selected := make([]bool, len(candidates))
+	for i, c := range candidates {
+		selected[i] = !exists(c.Connection.Name)
+	}
+
+	list := tview.NewList().
+		ShowSecondaryText(true).
+		SetHighlightFullLine(true).
+		SetMainTextColor(theme.ThemeColors.Primary).
+		SetSecondaryTextColor(theme.ThemeColors.ForegroundMuted).
+		SetSelectedTextColor(theme.ThemeColors.Foreground).
+		SetSelectedBackgroundColor(theme.ThemeColors.Selection)
+	list.SetBorder(true).
+		SetTitle(" Import Connections (Space to toggle, A for all, Tab for buttons, Esc to cancel) ").
+		SetTitleAlign(tview.AlignLeft)
+
+	itemText := func(i int) (string, string) {
+		c := candidates[i]
+		mark := "[ ]"
+		if selected[i] {
+			mark = theme.Icons.Check
+		}
+		main := fmt.Sprintf("%s %s", tview.Escape(mark), c.Connection.Name)
+		if exists(c.Connection.Name) {
+			main += "  (exists, will be skipped)"
+		}
+		secondary := c.Describe()
+		if c.Password != "" {
+			secondary += "  password"
+		}
+		return main, fmt.Sprintf("%s  from %s", secondary, c.Origin)
+	}
+	toggle := func(i int) {
+		if i < 0 || i >= len(candidates) {
+			return
+		}
+		selected[i] = !selected[i]
+		main, secondary := itemText(i)
+		list.SetItemText(i, main, secondary)
+	}
+
+	for i := range candidates {
+		main, secondary := itemText(i)
+		list.AddItem(main, secondary, 0, nil)
+	}
+	list.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+		toggle(i)
+	})
+
+	cancel := func() {
+		pages.RemovePage(pageName)
+		onDone(nil)
+	}
+
+	buttons := tview.NewForm().
+		AddButton("Import", func() {
+			var chosen []importer.Candidate
+			for i, c := range candidates {
+				if selected[i] {
+					chosen = append(chosen, c)
+				}
+			}
+			if len(chosen) == 0 {
+				ShowError(pages, app, fmt.Errorf("no connections selected"))
+				return
+			}
+			pages.RemovePage(pageName)
+			onDone(chosen)
+		}).
+		AddButton("Cancel", cancel).
+		SetButtonsAlign(tview.AlignCenter)
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEsc:
+			cancel()
+			return nil
+		case tcell.KeyTab:
+			app.SetFocus(buttons)
+			return nil
+		}
+		switch event.Rune() {
+		case ' ':
+			toggle(list.GetCurrentItem())
+			return nil
+		case 'a', 'A':
+			all := true
+			for _, s := range selected {
+				all = all && s
+			}
+			for i := range selected {
+				if selected[i] == all {
+					toggle(i)
+				}
+			}
+			return nil
+		}
+		return event
+	})
+	buttons.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEsc:
+			cancel()
+			return nil
+		case tcell.KeyBacktab:
+			app.SetFocus(list)
+			return nil
+		}
+		return event
+	})
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(list, 0, 1, true).
+		AddItem(buttons, 3, 0, false)
+
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(layout, 24, 0, true).
+			AddItem(nil, 0, 1, false), 110, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	pages.AddPage(pageName, modal, true, true)
+	app.SetFocus(list)
+}
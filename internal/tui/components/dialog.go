@@ -1,6 +1,10 @@
 package components
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/tui/theme"
 	"github.com/rivo/tview"
 )
 
@@ -40,3 +44,49 @@ func ShowConfirm(pages *tview.Pages, app *tview.Application, message string, cal
 	pages.AddPage("confirm", dialog, true, true)
 	app.SetFocus(dialog)
 }
+
+// ShowTypedConfirm only confirms once expected has been typed, for operations
+// where a reflexive Enter would be dangerous.
+func ShowTypedConfirm(pages *tview.Pages, app *tview.Application, message, expected string, callback func(bool)) {
+	const pageName = "typed-confirm"
+
+	lines := strings.Count(message, "\n") + 1
+	form := tview.NewForm()
+	form.AddTextView("", message, 64, lines, true, true)
+	input := tview.NewInputField().
+		SetLabel(fmt.Sprintf("Type %q to confirm: ", expected)).
+		SetFieldWidth(30)
+	form.AddFormItem(input)
+
+	done := func(confirmed bool) {
+		pages.RemovePage(pageName)
+		callback(confirmed)
+	}
+
+	form.AddButton("Confirm", func() {
+		if input.GetText() != expected {
+			input.SetFieldTextColor(theme.ThemeColors.Error)
+			app.SetFocus(input)
+			return
+		}
+		done(true)
+	})
+	form.AddButton("Cancel", func() {
+		done(false)
+	})
+	form.SetCancelFunc(func() {
+		done(false)
+	})
+	input.SetChangedFunc(func(string) {
+		input.SetFieldTextColor(theme.ThemeColors.Foreground)
+	})
+
+	form.SetBorder(true).
+		SetTitle(" Confirm ").
+		SetTitleAlign(tview.AlignCenter)
+	form.SetFocus(1)
+
+	modal := NewFormModal(form, 72, lines+9)
+	pages.AddPage(pageName, modal, true, true)
+	app.SetFocus(modal)
+}
@@ -1,6 +1,8 @@
 package components
 
 import (
+	"fmt"
+
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
@@ -78,6 +80,9 @@ const (
 	FieldTypeInput FieldType = iota
 	FieldTypePassword
 	FieldTypeDropDown
+	FieldTypeCheckbox
+	// FieldTypeText is a read-only line whose text is changed with SetText.
+	FieldTypeText
 )
 
 type FormField struct {
@@ -106,6 +111,7 @@ type FormDialog struct {
 	form          *tview.Form
 	modal         *FormModal
 	inputValues   map[string]string
+	textFields    map[string]*tview.InputField
 	escapeToClose bool
 }
 
@@ -161,6 +167,7 @@ func NewFormDialog(pages *tview.Pages, app *tview.Application, config FormDialog
 
 func (fd *FormDialog) buildForm() {
 	fd.form = tview.NewForm()
+	fd.textFields = make(map[string]*tview.InputField)
 
 	for _, field := range fd.fields {
 		fd.addField(field)
@@ -179,6 +186,10 @@ func (fd *FormDialog) addField(field FormField) {
 		fd.addPasswordField(field)
 	case FieldTypeDropDown:
 		fd.addDropDownField(field)
+	case FieldTypeCheckbox:
+		fd.addCheckboxField(field)
+	case FieldTypeText:
+		fd.addTextField(field)
 	}
 }
 
@@ -207,6 +218,35 @@ func (fd *FormDialog) addDropDownField(field FormField) {
 	fd.form.AddDropDown(field.Label, field.Options, initialIndex, fd.createSelectHandler(field.Label, field.OnSelected))
 }
 
+// addCheckboxField stores the checkbox state as "true" or "false".
+func (fd *FormDialog) addCheckboxField(field FormField) {
+	checked := field.InitialValue == "true"
+	fd.inputValues[field.Label] = fmt.Sprintf("%t", checked)
+	fd.form.AddCheckbox(field.Label, checked, func(checked bool) {
+		fd.inputValues[field.Label] = fmt.Sprintf("%t", checked)
+		if field.OnChanged != nil {
+			field.OnChanged(fd.inputValues[field.Label])
+		}
+	})
+}
+
+func (fd *FormDialog) addTextField(field FormField) {
+	text := tview.NewInputField().
+		SetLabel(field.Label).
+		SetText(field.InitialValue).
+		SetFieldWidth(fd.getFieldWidth(field.FieldWidth))
+	text.SetDisabled(true)
+	fd.form.AddFormItem(text)
+	fd.textFields[field.Label] = text
+}
+
+// SetText replaces the text of a FieldTypeText field.
+func (fd *FormDialog) SetText(label, text string) {
+	if field, ok := fd.textFields[label]; ok {
+		field.SetText(text)
+	}
+}
+
 func (fd *FormDialog) getFieldWidth(width int) int {
 	if width == 0 {
 		return 30
@@ -26,6 +26,8 @@ var helpContexts = map[string][]KeyHelp{
 		{Key: "E", Desc: "Edit"},
 		{Key: "D", Desc: "Delete"},
 		{Key: "T", Desc: "Test"},
+		{Key: "I", Desc: "Import"},
+		{Key: "W", Desc: "Workspaces"},
 		{Key: "F10", Desc: "Quit"},
 		{Key: "F1", Desc: "More"},
 	},
@@ -34,6 +36,7 @@ var helpContexts = map[string][]KeyHelp{
 		{Key: "Alt+H", Desc: "Schemas"},
 		{Key: "Alt+I", Desc: "Indexes"},
 		{Key: "Alt+D", Desc: "Data"},
+		{Key: "Alt+R", Desc: "Refresh"},
 		{Key: "S", Desc: "Server Info"},
 		{Key: "F3", Desc: "Editor"},
 		{Key: "F10", Desc: "Quit"},
@@ -67,6 +70,9 @@ var helpContextsExpanded = map[string][]KeyHelp{
 		{Key: "E", Desc: "Edit connection"},
 		{Key: "D", Desc: "Delete connection"},
 		{Key: "T", Desc: "Test connection"},
+		{Key: "I", Desc: "Import from .pgpass, .my.cnf, DBeaver"},
+		{Key: "P", Desc: "Set or change master password"},
+		{Key: "W", Desc: "Switch or create workspace"},
 		{Key: "Esc", Desc: "Cancel / Back"},
 		{Key: "F1", Desc: "Collapse help"},
 	},
@@ -75,6 +81,7 @@ var helpContextsExpanded = map[string][]KeyHelp{
 		{Key: "Alt+H", Desc: "Toggle schemas panel"},
 		{Key: "Alt+I", Desc: "Toggle indexes panel"},
 		{Key: "Alt+D", Desc: "Toggle data preview"},
+		{Key: "Alt+R", Desc: "Refresh schema metadata"},
 		{Key: "S", Desc: "Show server info"},
 		{Key: "Enter", Desc: "Select item"},
 		{Key: "PgUp/PgDn", Desc: "Scroll data preview"},
@@ -83,7 +90,8 @@ var helpContextsExpanded = map[string][]KeyHelp{
 	"editor": {
 		{Key: "F5/Shift+Enter", Desc: "Execute query"},
 		{Key: "Esc", Desc: "Cancel running query"},
-		{Key: "Alt+M", Desc: "Toggle Execute/Analyze"},
+		{Key: "Alt+M", Desc: "Cycle Execute/Explain/Explain Analyze"},
+		{Key: "Alt+F", Desc: "Format SQL (selection or buffer)"},
 		{Key: "Alt+S", Desc: "Quick save"},
 		{Key: "Alt+Shift+S", Desc: "Save As"},
 		{Key: "Alt+L", Desc: "Load saved query"},
@@ -92,6 +100,10 @@ var helpContextsExpanded = map[string][]KeyHelp{
 		{Key: "Alt+W", Desc: "Close tab"},
 		{Key: "Alt+R", Desc: "Rename tab"},
 		{Key: "Alt+1-9", Desc: "Switch to tab"},
+		{Key: "Tab/Ctrl+Space", Desc: "Complete (Ctrl+Space inside a snippet)"},
+		{Key: "Tab/Shift+Tab", Desc: "Next/previous snippet tab stop"},
+		{Key: "F8/Shift+F8", Desc: "Next/previous diagnostic"},
+		{Key: ":w / C-x C-s", Desc: "Save query (Vim/Emacs keymap)"},
 		{Key: "F1", Desc: "Collapse help"},
 	},
 	"editor_completion": {
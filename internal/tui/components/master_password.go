@@ -0,0 +1,100 @@
+package components
+
+import (
+	"errors"
+
+	"github.com/android-lewis/dbsmith/internal/secrets"
+	"github.com/rivo/tview"
+)
+
+const (
+	masterPasswordLabel  = "Master Password"
+	currentPasswordLabel = "Current Password"
+	newPasswordLabel     = "New Password"
+	confirmPasswordLabel = "Confirm Password"
+)
+
+// ShowUnlockPrompt asks for the master password protecting the secrets file.
+// onDone reports whether the secrets were unlocked; skipping leaves them
+// locked until the next prompt.
+func ShowUnlockPrompt(pages *tview.Pages, app *tview.Application, lockable secrets.Lockable, onDone func(unlocked bool)) {
+	done := func(unlocked bool) {
+		if onDone != nil {
+			onDone(unlocked)
+		}
+	}
+
+	dialog := NewFormDialog(pages, app, FormDialogConfig{
+		Title: " Unlock Secrets ",
+		Fields: []FormField{
+			{Type: FieldTypePassword, Label: masterPasswordLabel, FieldWidth: 30},
+		},
+		SubmitLabel: "Unlock",
+		CancelLabel: "Skip",
+		OnSubmit: func(values map[string]string) error {
+			if err := lockable.Unlock(values[masterPasswordLabel]); err != nil {
+				return err
+			}
+			done(true)
+			return nil
+		},
+		OnCancel:      func() { done(false) },
+		PageName:      "unlock-secrets",
+		ModalWidth:    56,
+		EscapeToClose: true,
+	})
+	dialog.Show()
+}
+
+// ShowMasterPasswordForm sets, changes or removes the master password of the
+// encrypted secrets file. Leaving the new password empty removes it.
+func ShowMasterPasswordForm(pages *tview.Pages, app *tview.Application, mgr *secrets.EncryptedFileManager, onDone func()) {
+	protected := mgr.Protected()
+
+	var fields []FormField
+	title := " Set Master Password "
+	if protected {
+		title = " Change Master Password "
+		fields = append(fields, FormField{Type: FieldTypePassword, Label: currentPasswordLabel, FieldWidth: 30})
+	}
+	fields = append(fields,
+		FormField{Type: FieldTypePassword, Label: newPasswordLabel, FieldWidth: 30},
+		FormField{Type: FieldTypePassword, Label: confirmPasswordLabel, FieldWidth: 30},
+	)
+
+	dialog := NewFormDialog(pages, app, FormDialogConfig{
+		Title:       title,
+		Fields:      fields,
+		SubmitLabel: "Save",
+		OnSubmit: func(values map[string]string) error {
+			next := values[newPasswordLabel]
+			if next != values[confirmPasswordLabel] {
+				return errors.New("the new passwords do not match")
+			}
+			if next == "" && !protected {
+				return secrets.ErrEmptyMasterPassword
+			}
+			if err := mgr.SetMasterPassword(values[currentPasswordLabel], next); err != nil {
+				return err
+			}
+
+			message := "Master password set. Secrets are re-encrypted and the key file was removed."
+			switch {
+			case next == "":
+				message = "Master password removed. Secrets are encrypted with a key file again."
+			case protected:
+				message = "Master password changed."
+			}
+			ShowInfo(pages, app, message)
+			if onDone != nil {
+				onDone()
+			}
+			return nil
+		},
+		OnCancel:      onDone,
+		PageName:      "master-password",
+		ModalWidth:    56,
+		EscapeToClose: true,
+	})
+	dialog.Show()
+}
@@ -0,0 +1,88 @@
+package components
+
+import (
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/db"
+	"github.com/android-lewis/dbsmith/internal/tui/theme"
+	"github.com/rivo/tview"
+)
+
+const (
+	queryErrorWidth     = 64
+	queryErrorMaxHeight = 18
+)
+
+// ShowQueryError reports a failed query with what the server said about it:
+// the SQLSTATE, detail, hint and objects involved, followed by known
+// remedies. location, such as "line 2, column 8", says where in the editor
+// the error is. Errors the server did not report are shown as they are.
+// Focus returns to focusWidget when the dialog closes.
+func ShowQueryError(pages *tview.Pages, app *tview.Application, title string, err error, location string, focusWidget tview.Primitive) {
+	qe, ok := db.AsQueryError(err)
+	if !ok {
+		ShowError(pages, app, err)
+		return
+	}
+
+	const pageName = "query-error"
+
+	var b strings.Builder
+	b.WriteString(theme.ColorTag(theme.ColorError, true) + tview.Escape(qe.Message) + theme.ColorTagReset())
+	field := func(label, value string) {
+		if value != "" {
+			b.WriteString("\n" + theme.ColorTag(theme.ColorForegroundMuted, false) + label + ":" + theme.ColorTagReset() + " " + tview.Escape(value))
+		}
+	}
+
+	b.WriteString("\n")
+	code := qe.Code
+	if name := db.SQLStateName(qe.Code); name != "" {
+		code += " (" + name + ")"
+	}
+	field("SQLSTATE", code)
+	field("Position", location)
+	field("Detail", qe.Detail)
+	field("Hint", qe.Hint)
+	table := qe.Table
+	if qe.Schema != "" && table != "" {
+		table = qe.Schema + "." + table
+	}
+	field("Table", table)
+	field("Column", qe.Column)
+	field("Constraint", qe.Constraint)
+
+	if remedies := db.Remedies(qe); len(remedies) > 0 {
+		b.WriteString("\n\n" + theme.ColorTag(theme.ColorInfo, true) + "Try:" + theme.ColorTagReset())
+		for _, remedy := range remedies {
+			b.WriteString("\n• " + tview.Escape(remedy))
+		}
+	}
+
+	text := b.String()
+	height := 0
+	for _, line := range strings.Split(text, "\n") {
+		height += max(1, (tview.TaggedStringWidth(line)+queryErrorWidth-1)/queryErrorWidth)
+	}
+	height = min(height, queryErrorMaxHeight)
+
+	done := func() {
+		pages.RemovePage(pageName)
+		if focusWidget != nil {
+			app.SetFocus(focusWidget)
+		}
+	}
+
+	form := tview.NewForm()
+	form.AddTextView("", text, queryErrorWidth, height, true, true)
+	form.AddButton("OK", done)
+	form.SetCancelFunc(done)
+	form.SetBorder(true).
+		SetTitle(" " + title + " ").
+		SetTitleAlign(tview.AlignCenter)
+	form.SetFocus(1)
+
+	modal := NewFormModal(form, queryErrorWidth+8, height+7)
+	pages.AddPage(pageName, modal, true, true)
+	app.SetFocus(modal)
+}
@@ -2,6 +2,7 @@ package components
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/android-lewis/dbsmith/internal/models"
@@ -19,6 +20,7 @@ type SavedQueriesManager struct {
 	onQueryLoad            func(queryID, queryName, querySQL string)
 	getSQLText             func() string
 	getCurrentSavedQueryID func() string
+	getConnectionName      func() string
 }
 
 func NewSavedQueriesManager(pages *tview.Pages, app *tview.Application, workspace *workspace.Manager) *SavedQueriesManager {
@@ -57,13 +59,18 @@ func (s *SavedQueriesManager) SetCallbacks(
 	s.getCurrentSavedQueryID = getCurrentSavedQueryID
 }
 
+// SetConnectionNameFunc lets the browser show usage for the active connection.
+func (s *SavedQueriesManager) SetConnectionNameFunc(getConnectionName func() string) {
+	s.getConnectionName = getConnectionName
+}
+
 func (s *SavedQueriesManager) QuickSave(focusWidget tview.Primitive) {
 	if s.workspace == nil {
 		ShowError(s.pages, s.app, fmt.Errorf("no workspace loaded"))
 		return
 	}
 
-	workspacePath := workspace.GetDefaultWorkspacePath()
+	workspacePath := s.workspace.GetFilePath()
 	if workspacePath == "" {
 		ShowError(s.pages, s.app, fmt.Errorf("workspace file path not found"))
 		return
@@ -90,16 +97,18 @@ func (s *SavedQueriesManager) QuickSave(focusWidget tview.Primitive) {
 		Name:        existingQuery.Name,
 		SQL:         sql,
 		Description: existingQuery.Description,
+		Folder:      existingQuery.Folder,
+		Tags:        existingQuery.Tags,
 		CreatedAt:   existingQuery.CreatedAt,
 	}
 
 	if err := s.workspace.UpdateSavedQuery(updatedQuery); err != nil {
-		ShowError(s.pages, s.app, fmt.Errorf("failed to save query: %w", err))
+		ShowWorkspaceSaveError(s.pages, s.app, s.workspace, fmt.Errorf("failed to save query: %w", err), nil)
 		return
 	}
 
 	if err := s.workspace.Save(workspacePath); err != nil {
-		ShowError(s.pages, s.app, fmt.Errorf("failed to save workspace: %w", err))
+		ShowWorkspaceSaveError(s.pages, s.app, s.workspace, fmt.Errorf("failed to save workspace: %w", err), nil)
 		return
 	}
 
@@ -132,10 +141,14 @@ func (s *SavedQueriesManager) ShowSaveDialog(focusWidget tview.Primitive) {
 	var form *tview.Form
 	name := ""
 	description := ""
+	folder := ""
+	tags := ""
 
 	if isEditMode {
 		name = existingQuery.Name
 		description = existingQuery.Description
+		folder = existingQuery.Folder
+		tags = strings.Join(existingQuery.Tags, ", ")
 	}
 
 	form = tview.NewForm()
@@ -143,6 +156,17 @@ func (s *SavedQueriesManager) ShowSaveDialog(focusWidget tview.Primitive) {
 	form.AddInputField("Name", name, 40, nil, func(text string) { name = text })
 	form.AddInputField("Description", description, 50, nil, func(text string) { description = text })
 
+	folderField := tview.NewInputField().
+		SetLabel("Folder").
+		SetText(folder).
+		SetFieldWidth(40).
+		SetPlaceholder("e.g. reports/monthly").
+		SetChangedFunc(func(text string) { folder = text })
+	folderField.SetAutocompleteFunc(folderCompleter(s.workspace.ListFolders()))
+	form.AddFormItem(folderField)
+
+	form.AddInputField("Tags", tags, 40, nil, func(text string) { tags = text })
+
 	form.AddButton("Save", func() {
 		if name == "" {
 			ShowError(s.pages, s.app, fmt.Errorf("query name is required"))
@@ -159,6 +183,8 @@ func (s *SavedQueriesManager) ShowSaveDialog(focusWidget tview.Primitive) {
 				Name:        name,
 				SQL:         sql,
 				Description: description,
+				Folder:      folder,
+				Tags:        splitTags(tags),
 				CreatedAt:   existingQuery.CreatedAt,
 			}
 			err = s.workspace.UpdateSavedQuery(updatedQuery)
@@ -169,24 +195,26 @@ func (s *SavedQueriesManager) ShowSaveDialog(focusWidget tview.Primitive) {
 				Name:        name,
 				SQL:         sql,
 				Description: description,
+				Folder:      folder,
+				Tags:        splitTags(tags),
 				CreatedAt:   time.Now(),
 			}
 			err = s.workspace.AddSavedQuery(savedQuery)
 		}
 
 		if err != nil {
-			ShowError(s.pages, s.app, fmt.Errorf("failed to save query: %w", err))
+			ShowWorkspaceSaveError(s.pages, s.app, s.workspace, fmt.Errorf("failed to save query: %w", err), nil)
 			return
 		}
 
-		workspacePath := workspace.GetDefaultWorkspacePath()
+		workspacePath := s.workspace.GetFilePath()
 		if workspacePath == "" {
 			ShowError(s.pages, s.app, fmt.Errorf("workspace file path not found"))
 			return
 		}
 
 		if err := s.workspace.Save(workspacePath); err != nil {
-			ShowError(s.pages, s.app, fmt.Errorf("failed to save workspace: %w", err))
+			ShowWorkspaceSaveError(s.pages, s.app, s.workspace, fmt.Errorf("failed to save workspace: %w", err), nil)
 			return
 		}
 
@@ -232,7 +260,7 @@ func (s *SavedQueriesManager) ShowSaveDialog(focusWidget tview.Primitive) {
 		AddItem(tview.NewFlex().
 			SetDirection(tview.FlexRow).
 			AddItem(nil, 0, 1, false).
-			AddItem(form, 13, 0, true).
+			AddItem(form, 17, 0, true).
 			AddItem(nil, 0, 1, false), 70, 0, true).
 		AddItem(nil, 0, 1, false)
 
@@ -240,65 +268,21 @@ func (s *SavedQueriesManager) ShowSaveDialog(focusWidget tview.Primitive) {
 	s.app.SetFocus(form)
 }
 
-func (s *SavedQueriesManager) ShowLoadDialog(focusWidget tview.Primitive, loadSQL func(sql string)) {
-	if s.workspace == nil {
-		ShowError(s.pages, s.app, fmt.Errorf("no workspace loaded"))
-		return
-	}
-
-	savedQueries := s.workspace.ListSavedQueries()
-	if len(savedQueries) == 0 {
-		ShowInfo(s.pages, s.app, "No saved queries found")
-		return
-	}
-
-	list := tview.NewList().
-		ShowSecondaryText(true).
-		SetHighlightFullLine(true)
-
-	list.SetBorder(true).
-		SetTitle(" Load Saved Query (Enter to load, Esc to cancel) ").
-		SetTitleAlign(tview.AlignCenter)
+func splitTags(text string) []string {
+	return workspace.NormalizeTags(strings.Split(text, ","))
+}
 
-	for _, query := range savedQueries {
-		q := query
-		secondaryText := query.Description
-		if secondaryText == "" {
-			secondaryText = fmt.Sprintf("Created: %s", query.CreatedAt.Format("2006-01-02 15:04"))
+func folderCompleter(folders []string) func(string) []string {
+	return func(text string) []string {
+		if text == "" {
+			return nil
 		}
-		list.AddItem(query.Name, secondaryText, 0, func() {
-			if loadSQL != nil {
-				loadSQL(q.SQL)
-			}
-
-			if s.onQueryLoad != nil {
-				s.onQueryLoad(q.ID, q.Name, q.SQL)
+		var matches []string
+		for _, f := range folders {
+			if strings.HasPrefix(strings.ToLower(f), strings.ToLower(text)) {
+				matches = append(matches, f)
 			}
-
-			s.pages.RemovePage("load-query")
-			s.app.SetFocus(focusWidget)
-			ShowInfo(s.pages, s.app, fmt.Sprintf("Loaded query: %s", q.Name))
-		})
-	}
-
-	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEsc {
-			s.pages.RemovePage("load-query")
-			s.app.SetFocus(focusWidget)
-			return nil
 		}
-		return event
-	})
-
-	flex := tview.NewFlex().
-		AddItem(nil, 0, 1, false).
-		AddItem(tview.NewFlex().
-			SetDirection(tview.FlexRow).
-			AddItem(nil, 0, 1, false).
-			AddItem(list, 15, 0, true).
-			AddItem(nil, 0, 1, false), 60, 0, true).
-		AddItem(nil, 0, 1, false)
-
-	s.pages.AddPage("load-query", flex, true, true)
-	s.app.SetFocus(list)
+		return matches
+	}
 }
@@ -0,0 +1,462 @@
+package components
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/android-lewis/dbsmith/internal/tui/theme"
+	"github.com/android-lewis/dbsmith/internal/workspace"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	loadQueryPage   = "load-query"
+	queryPromptPage = "saved-query-prompt"
+)
+
+// browserNode is the reference stored on each tree node. Folder nodes have a
+// nil query; the root is the folder "".
+type browserNode struct {
+	folder string
+	query  *models.SavedQuery
+}
+
+// queryBrowser is the saved query dialog: a search box over a folder tree
+// with a preview of the selected query.
+type queryBrowser struct {
+	s           *SavedQueriesManager
+	focusWidget tview.Primitive
+	loadSQL     func(sql string)
+
+	layout   *tview.Flex
+	search   *tview.InputField
+	tree     *tview.TreeView
+	preview  *tview.TextView
+	expanded map[string]bool
+}
+
+func (s *SavedQueriesManager) ShowLoadDialog(focusWidget tview.Primitive, loadSQL func(sql string)) {
+	if s.workspace == nil {
+		ShowError(s.pages, s.app, fmt.Errorf("no workspace loaded"))
+		return
+	}
+
+	if len(s.workspace.ListSavedQueries()) == 0 && len(s.workspace.ListFolders()) == 0 {
+		ShowInfo(s.pages, s.app, "No saved queries found")
+		return
+	}
+
+	b := &queryBrowser{
+		s:           s,
+		focusWidget: focusWidget,
+		loadSQL:     loadSQL,
+		expanded:    make(map[string]bool),
+	}
+	b.build()
+	b.refresh(browserNode{})
+
+	flex := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(b.layout, 28, 0, true).
+			AddItem(nil, 0, 1, false), 110, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	s.pages.AddPage(loadQueryPage, flex, true, true)
+	s.app.SetFocus(b.tree)
+}
+
+func (b *queryBrowser) build() {
+	b.search = tview.NewInputField().
+		SetLabel("Search: ").
+		SetPlaceholder("text, tag:name or #name")
+
+	b.tree = tview.NewTreeView().
+		SetGraphics(true).
+		SetGraphicsColor(theme.ThemeColors.Border)
+	b.tree.SetBorder(true).SetTitle(" Queries ")
+
+	b.preview = tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(true).
+		SetWordWrap(true)
+	b.preview.SetBorder(true).SetTitle(" Preview ")
+
+	hints := tview.NewTextView().
+		SetDynamicColors(true).
+		SetText(theme.ColorTag(theme.ColorForegroundMuted, false) +
+			"Enter load/expand  n new folder  r rename folder  m move  d delete  Tab search  Esc close" +
+			theme.ColorTagReset())
+
+	b.layout = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(b.search, 1, 0, false).
+		AddItem(tview.NewFlex().
+			AddItem(b.tree, 0, 2, true).
+			AddItem(b.preview, 0, 3, false), 0, 1, true).
+		AddItem(hints, 1, 0, false)
+	b.layout.SetBorder(true).
+		SetTitle(" Saved Queries ").
+		SetTitleAlign(tview.AlignCenter)
+
+	b.search.SetChangedFunc(func(string) {
+		b.refresh(b.selected())
+	})
+	b.search.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEsc:
+			b.close()
+		default:
+			b.s.app.SetFocus(b.tree)
+		}
+	})
+	b.search.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyDown {
+			b.s.app.SetFocus(b.tree)
+			return nil
+		}
+		return event
+	})
+
+	b.tree.SetChangedFunc(func(node *tview.TreeNode) {
+		b.showPreview(node)
+	})
+	b.tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		ref, ok := node.GetReference().(browserNode)
+		if !ok {
+			return
+		}
+		if ref.query != nil {
+			b.load(*ref.query)
+			return
+		}
+		if ref.folder != "" {
+			node.SetExpanded(!node.IsExpanded())
+			b.expanded[ref.folder] = node.IsExpanded()
+		}
+	})
+	b.tree.SetInputCapture(b.handleTreeKey)
+}
+
+func (b *queryBrowser) handleTreeKey(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyEsc:
+		b.close()
+		return nil
+	case tcell.KeyTab, tcell.KeyBacktab:
+		b.s.app.SetFocus(b.search)
+		return nil
+	case tcell.KeyRune:
+	default:
+		return event
+	}
+
+	ref := b.selected()
+	switch event.Rune() {
+	case '/':
+		b.s.app.SetFocus(b.search)
+	case 'n':
+		b.createFolder(ref.folder)
+	case 'r':
+		if ref.query == nil && ref.folder != "" {
+			b.renameFolder(ref.folder)
+		}
+	case 'm':
+		if ref.query != nil {
+			b.moveQuery(*ref.query)
+		}
+	case 'd':
+		b.delete(ref)
+	default:
+		return event
+	}
+	return nil
+}
+
+func (b *queryBrowser) selected() browserNode {
+	if node := b.tree.GetCurrentNode(); node != nil {
+		if ref, ok := node.GetReference().(browserNode); ok {
+			return ref
+		}
+	}
+	return browserNode{}
+}
+
+// refresh rebuilds the tree from the current search and reselects the node
+// matching keep when it is still visible.
+func (b *queryBrowser) refresh(keep browserNode) {
+	ws := b.s.workspace
+	filter := workspace.ParseQueryFilter(b.search.GetText())
+	filtering := filter.Text != "" || len(filter.Tags) > 0
+
+	root := tview.NewTreeNode(ws.GetName()).
+		SetReference(browserNode{}).
+		SetColor(theme.ThemeColors.Primary)
+	folders := map[string]*tview.TreeNode{"": root}
+
+	var folderNode func(folder string) *tview.TreeNode
+	folderNode = func(folder string) *tview.TreeNode {
+		if node, ok := folders[folder]; ok {
+			return node
+		}
+		parent, name := "", folder
+		if i := strings.LastIndex(folder, "/"); i >= 0 {
+			parent, name = folder[:i], folder[i+1:]
+		}
+		node := tview.NewTreeNode(name + "/").
+			SetReference(browserNode{folder: folder}).
+			SetColor(theme.ThemeColors.Accent).
+			SetExpanded(filtering || b.expanded[folder])
+		folderNode(parent).AddChild(node)
+		folders[folder] = node
+		return node
+	}
+
+	if !filtering {
+		for _, folder := range ws.ListFolders() {
+			folderNode(folder)
+		}
+	}
+
+	var current *tview.TreeNode
+	queries := ws.FilterSavedQueries(filter)
+	for i := range queries {
+		q := queries[i]
+		color := theme.ThemeColors.Foreground
+		if ws.QueryOrigin(q.ID) != workspace.OriginPersonal {
+			color = theme.ThemeColors.Info
+		}
+		node := tview.NewTreeNode(q.Name).
+			SetReference(browserNode{folder: q.Folder, query: &q}).
+			SetColor(color)
+		folderNode(workspace.NormalizeFolder(q.Folder)).AddChild(node)
+		if keep.query != nil && keep.query.ID == q.ID {
+			current = node
+		}
+	}
+
+	if current == nil && keep.query == nil {
+		current = folders[keep.folder]
+	}
+	if current == nil {
+		current = firstQueryNode(root)
+	}
+	if current == nil {
+		current = root
+	}
+
+	b.tree.SetRoot(root).SetCurrentNode(current)
+	b.tree.SetTitle(fmt.Sprintf(" Queries [%d] ", len(queries)))
+	b.showPreview(current)
+}
+
+func firstQueryNode(node *tview.TreeNode) *tview.TreeNode {
+	for _, child := range node.GetChildren() {
+		if ref, ok := child.GetReference().(browserNode); ok && ref.query != nil {
+			return child
+		}
+		if child.IsExpanded() {
+			if found := firstQueryNode(child); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func (b *queryBrowser) showPreview(node *tview.TreeNode) {
+	ref, ok := node.GetReference().(browserNode)
+	if !ok {
+		b.preview.Clear()
+		return
+	}
+	if ref.query == nil {
+		b.preview.SetText(b.folderPreview(ref.folder))
+		return
+	}
+	b.preview.SetText(b.queryPreview(*ref.query))
+	b.preview.ScrollToBeginning()
+}
+
+func (b *queryBrowser) folderPreview(folder string) string {
+	count := len(b.s.workspace.FilterSavedQueries(workspace.QueryFilter{Folder: folder}))
+	name := folder
+	if name == "" {
+		name = b.s.workspace.GetName()
+	}
+	return fmt.Sprintf("%s%s%s\n\n%d saved queries",
+		theme.ColorTag(theme.ColorAccent, true), tview.Escape(name), theme.ColorTagReset(), count)
+}
+
+func (b *queryBrowser) queryPreview(q models.SavedQuery) string {
+	var sb strings.Builder
+	muted := theme.ColorTag(theme.ColorForegroundMuted, false)
+	reset := theme.ColorTagReset()
+
+	sb.WriteString(theme.ColorTag(theme.ColorPrimary, true) + tview.Escape(q.Name) + reset + "\n")
+	if q.Folder != "" {
+		sb.WriteString(muted + "Folder: " + reset + tview.Escape(q.Folder) + "\n")
+	}
+	if len(q.Tags) > 0 {
+		sb.WriteString(muted + "Tags:   " + reset + tview.Escape("#"+strings.Join(q.Tags, " #")) + "\n")
+	}
+	switch b.s.workspace.QueryOrigin(q.ID) {
+	case workspace.OriginShared:
+		sb.WriteString(muted + "Origin: " + reset + "shared workspace\n")
+	case workspace.OriginOverride:
+		sb.WriteString(muted + "Origin: " + reset + "shared workspace, edited locally\n")
+	}
+	if q.Description != "" {
+		sb.WriteString(tview.Escape(q.Description) + "\n")
+	}
+
+	usage := b.s.workspace.GetQueryUsage(q.ID)
+	if usage.ExecutionCount == 0 {
+		sb.WriteString(muted + "Never run" + reset + "\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("%sRuns:%s   %d, last %s\n", muted, reset,
+			usage.ExecutionCount, usage.LastExecutedAt.Format("2006-01-02 15:04")))
+	}
+	if b.s.getConnectionName != nil {
+		if conn := b.s.getConnectionName(); conn != "" {
+			if cu, ok := usage.Connections[conn]; ok {
+				sb.WriteString(fmt.Sprintf("%sOn %s:%s %d, last %s\n", muted, tview.Escape(conn), reset,
+					cu.ExecutionCount, cu.LastExecutedAt.Format("2006-01-02 15:04")))
+			} else {
+				sb.WriteString(fmt.Sprintf("%sNever run on %s%s\n", muted, tview.Escape(conn), reset))
+			}
+		}
+	}
+
+	sb.WriteString("\n" + tview.Escape(q.SQL))
+	return sb.String()
+}
+
+func (b *queryBrowser) load(q models.SavedQuery) {
+	if b.loadSQL != nil {
+		b.loadSQL(q.SQL)
+	}
+
+	if b.s.onQueryLoad != nil {
+		b.s.onQueryLoad(q.ID, q.Name, q.SQL)
+	}
+
+	b.close()
+	ShowInfo(b.s.pages, b.s.app, fmt.Sprintf("Loaded query: %s", q.Name))
+}
+
+func (b *queryBrowser) close() {
+	b.s.pages.RemovePage(loadQueryPage)
+	b.s.app.SetFocus(b.focusWidget)
+}
+
+func (b *queryBrowser) createFolder(parent string) {
+	initial := ""
+	if parent != "" {
+		initial = parent + "/"
+	}
+	b.prompt(" New Folder ", "Folder", initial, func(name string) {
+		if err := b.s.workspace.CreateFolder(name); err != nil {
+			b.saveError(err)
+			return
+		}
+		folder := workspace.NormalizeFolder(name)
+		for parent := folder; strings.Contains(parent, "/"); {
+			parent = parent[:strings.LastIndex(parent, "/")]
+			b.expanded[parent] = true
+		}
+		b.refresh(browserNode{folder: folder})
+	})
+}
+
+func (b *queryBrowser) renameFolder(folder string) {
+	b.prompt(" Rename Folder ", "Folder", folder, func(name string) {
+		if err := b.s.workspace.RenameFolder(folder, name); err != nil {
+			b.saveError(err)
+			return
+		}
+		b.refresh(browserNode{folder: workspace.NormalizeFolder(name)})
+	})
+}
+
+func (b *queryBrowser) moveQuery(q models.SavedQuery) {
+	b.prompt(" Move Query ", "Folder", q.Folder, func(folder string) {
+		if err := b.s.workspace.MoveSavedQuery(q.ID, folder); err != nil {
+			b.saveError(err)
+			return
+		}
+		if folder = workspace.NormalizeFolder(folder); folder != "" {
+			b.expanded[folder] = true
+		}
+		b.refresh(browserNode{query: &q})
+	})
+}
+
+func (b *queryBrowser) delete(ref browserNode) {
+	switch {
+	case ref.query != nil:
+		q := *ref.query
+		ShowConfirm(b.s.pages, b.s.app, fmt.Sprintf("Delete saved query %q?", q.Name), func(confirmed bool) {
+			b.s.app.SetFocus(b.tree)
+			if !confirmed {
+				return
+			}
+			if err := b.s.workspace.DeleteSavedQuery(q.ID); err != nil {
+				b.saveError(err)
+				return
+			}
+			b.refresh(browserNode{folder: q.Folder})
+		})
+	case ref.folder != "":
+		if err := b.s.workspace.DeleteFolder(ref.folder); err != nil {
+			b.saveError(err)
+			return
+		}
+		parent := ""
+		if i := strings.LastIndex(ref.folder, "/"); i >= 0 {
+			parent = ref.folder[:i]
+		}
+		b.refresh(browserNode{folder: parent})
+	}
+}
+
+// saveError reports a failed change; after a conflict is resolved the tree
+// is rebuilt from whatever the workspace now holds.
+func (b *queryBrowser) saveError(err error) {
+	ShowWorkspaceSaveError(b.s.pages, b.s.app, b.s.workspace, err, func() {
+		b.refresh(browserNode{})
+		b.s.app.SetFocus(b.tree)
+	})
+}
+
+// prompt asks for a single folder path on top of the browser.
+func (b *queryBrowser) prompt(title, label, initial string, done func(string)) {
+	input := tview.NewInputField().
+		SetLabel(label).
+		SetText(initial).
+		SetFieldWidth(40)
+	input.SetAutocompleteFunc(folderCompleter(b.s.workspace.ListFolders()))
+
+	closePrompt := func() {
+		b.s.pages.RemovePage(queryPromptPage)
+		b.s.app.SetFocus(b.tree)
+	}
+
+	form := tview.NewForm().AddFormItem(input)
+	form.AddButton("OK", func() {
+		closePrompt()
+		done(input.GetText())
+	})
+	form.AddButton("Cancel", closePrompt)
+	form.SetCancelFunc(closePrompt)
+	form.SetBorder(true).
+		SetTitle(title).
+		SetTitleAlign(tview.AlignCenter)
+
+	modal := NewFormModal(form, 60, 7)
+	b.s.pages.AddPage(queryPromptPage, modal, true, true)
+	b.s.app.SetFocus(modal)
+}
@@ -18,6 +18,35 @@ type SQLEditor struct {
 	contentChanged bool
 
 	userChangedFunc func()
+	userMovedFunc   func()
+
+	snippet *snippetSession
+	marks   []Mark
+}
+
+// Mark underlines a span of the text, given as byte offsets, and flags its
+// line in the left border. An empty span underlines one cell. When several
+// marks share a line, the first one colors the border flag.
+type Mark struct {
+	Start int
+	End   int
+	Color tcell.Color
+}
+
+// TabStop is a span of text the cursor visits while a snippet is being
+// filled in, as byte offsets.
+type TabStop struct {
+	Start int
+	End   int
+}
+
+// snippetSession tracks the tab stops of an inserted snippet. Edits are
+// assumed to happen in the current stop; moving the cursor out of it ends
+// the session.
+type snippetSession struct {
+	stops   []TabStop
+	current int
+	length  int
 }
 
 func NewSQLEditor() *SQLEditor {
@@ -40,10 +69,17 @@ func NewSQLEditor() *SQLEditor {
 
 	textArea.SetChangedFunc(func() {
 		e.contentChanged = true
+		e.trackSnippetEdit()
 		if e.userChangedFunc != nil {
 			e.userChangedFunc()
 		}
 	})
+	textArea.SetMovedFunc(func() {
+		e.trackSnippetCursor()
+		if e.userMovedFunc != nil {
+			e.userMovedFunc()
+		}
+	})
 
 	return e
 }
@@ -63,6 +99,11 @@ func (e *SQLEditor) SetChangedFunc(handler func()) *SQLEditor {
 	return e
 }
 
+func (e *SQLEditor) SetMovedFunc(handler func()) *SQLEditor {
+	e.userMovedFunc = handler
+	return e
+}
+
 func (e *SQLEditor) SetHighlighter(h *syntax.Highlighter) *SQLEditor {
 	e.highlighter = h
 	e.contentChanged = true
@@ -79,6 +120,11 @@ func (e *SQLEditor) GetDialect() string {
 	return e.dialect
 }
 
+func (e *SQLEditor) SetMarks(marks []Mark) *SQLEditor {
+	e.marks = marks
+	return e
+}
+
 func (e *SQLEditor) SetPlaceholder(placeholder string) *SQLEditor {
 
 	lines := strings.Split(placeholder, "\n")
@@ -109,6 +155,119 @@ func (e *SQLEditor) ReplaceRange(start, end int, text string) {
 	e.contentChanged = true
 }
 
+// Undo undoes the last edit, as Ctrl+Z does.
+func (e *SQLEditor) Undo() {
+	e.sendKey(tcell.KeyCtrlZ)
+}
+
+// Redo redoes the last undone edit, as Ctrl+Y does.
+func (e *SQLEditor) Redo() {
+	e.sendKey(tcell.KeyCtrlY)
+}
+
+// sendKey runs the text area's own handling of key, bypassing the input
+// capture.
+func (e *SQLEditor) sendKey(key tcell.Key) {
+	capture := e.TextArea.GetInputCapture()
+	e.TextArea.SetInputCapture(nil)
+	defer e.TextArea.SetInputCapture(capture)
+	e.TextArea.InputHandler()(tcell.NewEventKey(key, 0, tcell.ModCtrl), nil)
+}
+
+// InsertSnippet replaces start..end with text and selects the first of
+// stops, which are relative to text. The last stop is where the cursor ends
+// up; NextTabStop and PrevTabStop move between them.
+func (e *SQLEditor) InsertSnippet(start, end int, text string, stops []TabStop) {
+	e.snippet = nil
+	e.ReplaceRange(start, end, text)
+	if len(stops) == 0 {
+		return
+	}
+
+	absolute := make([]TabStop, len(stops))
+	for i, stop := range stops {
+		absolute[i] = TabStop{Start: start + stop.Start, End: start + stop.End}
+	}
+	if len(absolute) == 1 {
+		e.Select(absolute[0].Start, absolute[0].End)
+		return
+	}
+	e.snippet = &snippetSession{stops: absolute, length: e.GetTextLength()}
+	e.selectTabStop(0)
+}
+
+// InSnippet reports whether a snippet's tab stops are active.
+func (e *SQLEditor) InSnippet() bool {
+	return e.snippet != nil
+}
+
+// NextTabStop selects the next tab stop. Reaching the last one ends the
+// snippet.
+func (e *SQLEditor) NextTabStop() {
+	if e.snippet != nil {
+		e.selectTabStop(e.snippet.current + 1)
+	}
+}
+
+func (e *SQLEditor) PrevTabStop() {
+	if e.snippet != nil && e.snippet.current > 0 {
+		e.selectTabStop(e.snippet.current - 1)
+	}
+}
+
+// ExitSnippet leaves the tab stops, keeping the cursor where it is.
+func (e *SQLEditor) ExitSnippet() {
+	e.snippet = nil
+}
+
+func (e *SQLEditor) selectTabStop(index int) {
+	s := e.snippet
+	s.current = index
+	stop := s.stops[index]
+	if index == len(s.stops)-1 {
+		e.snippet = nil
+	}
+	e.Select(stop.Start, stop.End)
+}
+
+// trackSnippetEdit grows or shrinks the current tab stop by the size of an
+// edit and shifts the stops after it.
+func (e *SQLEditor) trackSnippetEdit() {
+	s := e.snippet
+	if s == nil {
+		return
+	}
+	length := e.GetTextLength()
+	delta := length - s.length
+	s.length = length
+
+	current := &s.stops[s.current]
+	oldEnd := current.End
+	current.End += delta
+	if current.End < current.Start {
+		e.snippet = nil
+		return
+	}
+	for i := range s.stops {
+		if i != s.current && s.stops[i].Start >= oldEnd {
+			s.stops[i].Start += delta
+			s.stops[i].End += delta
+		}
+	}
+}
+
+func (e *SQLEditor) trackSnippetCursor() {
+	s := e.snippet
+	if s == nil {
+		return
+	}
+	_, start, end := e.GetSelection()
+	stop := s.stops[s.current]
+	if start < stop.Start || end > stop.End {
+		e.snippet = nil
+	}
+}
+
 func (e *SQLEditor) Draw(screen tcell.Screen) {
 
 	e.TextArea.Draw(screen)
@@ -174,6 +333,61 @@ func (e *SQLEditor) Draw(screen tcell.Screen) {
 			}
 		}
 	}
+
+	e.drawMarks(screen, lines)
+}
+
+// drawMarks underlines the marked spans that are in view and flags their
+// lines in the left border.
+func (e *SQLEditor) drawMarks(screen tcell.Screen, lines []string) {
+	x, y, width, height := e.GetInnerRect()
+	rowOffset, colOffset := e.GetOffset()
+	flagged := map[int]bool{}
+
+	for _, mark := range e.marks {
+		startLine, startCol := positionAt(lines, mark.Start)
+		endLine, endCol := positionAt(lines, mark.End)
+		if mark.End <= mark.Start {
+			endLine, endCol = startLine, startCol+1
+		}
+
+		for line := startLine; line <= endLine; line++ {
+			row := line - rowOffset
+			if row < 0 || row >= height {
+				continue
+			}
+			if !flagged[line] && x > 0 {
+				flagged[line] = true
+				screen.SetContent(x-1, y+row, '●', nil, tcell.StyleDefault.
+					Foreground(mark.Color).
+					Background(theme.ThemeColors.Background))
+			}
+
+			from, to := 0, len([]rune(lines[line]))
+			if line == startLine {
+				from = startCol
+			}
+			if line == endLine {
+				to = endCol
+			}
+			for col := max(from, colOffset); col < to && col-colOffset < width; col++ {
+				ch, comb, style, _ := screen.GetContent(x+col-colOffset, y+row)
+				screen.SetContent(x+col-colOffset, y+row, ch, comb, style.Underline(tcell.UnderlineStyleCurly, mark.Color))
+			}
+		}
+	}
+}
+
+// positionAt converts a byte offset into a line and rune column.
+func positionAt(lines []string, offset int) (int, int) {
+	for i, line := range lines {
+		if offset <= len(line) || i == len(lines)-1 {
+			offset = min(offset, len(line))
+			return i, len([]rune(line[:offset]))
+		}
+		offset -= len(line) + 1
+	}
+	return 0, 0
 }
 
 // offsetAt computes a byte offset from a (line, col) position where col is a rune index.
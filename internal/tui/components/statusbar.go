@@ -2,8 +2,10 @@ package components
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/android-lewis/dbsmith/internal/app"
+	"github.com/android-lewis/dbsmith/internal/executor"
 	"github.com/android-lewis/dbsmith/internal/tui/theme"
 	"github.com/gdamore/tcell/v2"
 	"github.com/navidys/tvxwidgets"
@@ -65,25 +67,38 @@ func (s *StatusBar) Update() {
 		var statusIcon string
 		var statusColor tcell.Color
 
-		if s.app.Driver == nil {
+		var health string
+
+		switch {
+		case s.app.Driver == nil:
+			statusIcon = theme.Icons.Disconnected
+			statusColor = theme.ThemeColors.Error
+		case s.app.HealthState() == executor.HealthLost:
 			statusIcon = theme.Icons.Disconnected
 			statusColor = theme.ThemeColors.Error
-		} else {
+			health = fmt.Sprintf(" [#%06x::b]CONNECTION LOST, RECONNECTING[-:-:-]", statusColor.Hex())
+		case s.app.HealthState() == executor.HealthDegraded:
+			statusIcon = theme.Icons.Warning
+			statusColor = theme.ThemeColors.Warning
+			health = fmt.Sprintf(" [#%06x::b]DEGRADED[-:-:-]", statusColor.Hex())
+		default:
 			statusIcon = theme.Icons.Connected
 			statusColor = theme.ThemeColors.Success
 		}
 
-		text = fmt.Sprintf(" [#%06x::b]%s[-:-:-] [#%06x::b]%s[-:-:-] [#%06x]│[-] %s [#%06x]│[-] %s@%s:%d/%s",
+		text = fmt.Sprintf(" [#%06x::b]%s[-:-:-] [#%06x::b]%s[-:-:-]%s%s [#%06x]│[-] %s [#%06x]│[-] %s@%s:%s/%s",
 			statusColor.Hex(),
 			statusIcon,
 			theme.ThemeColors.Foreground.Hex(),
 			conn.Name,
+			s.environmentBadge(),
+			health,
 			theme.ThemeColors.ForegroundMuted.Hex(),
 			conn.Type,
 			theme.ThemeColors.ForegroundMuted.Hex(),
 			conn.Username,
 			conn.Host,
-			conn.Port,
+			conn.PortString(),
 			conn.Database,
 		)
 	}
@@ -112,3 +127,26 @@ func (s *StatusBar) SetIdle() {
 
 	s.Update()
 }
+
+// environmentBadge renders the connection's environment tag in the policy
+// color, plus a read-only marker when writes are blocked.
+func (s *StatusBar) environmentBadge() string {
+	conn := s.app.Connection
+	policy := s.app.EnvironmentPolicy()
+
+	badge := ""
+	if conn.Environment != "" {
+		color, ok := theme.ParseColor(policy.Color)
+		if !ok {
+			color = theme.ThemeColors.Info
+		}
+		badge = fmt.Sprintf(" [#%06x:#%06x:b] %s [-:-:-]",
+			theme.ThemeColors.Background.Hex(),
+			color.Hex(),
+			strings.ToUpper(string(conn.Environment)))
+	}
+	if policy.ReadOnly {
+		badge += fmt.Sprintf(" [#%06x::b]READ ONLY[-:-:-]", theme.ThemeColors.Warning.Hex())
+	}
+	return badge
+}
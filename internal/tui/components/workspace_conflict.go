@@ -0,0 +1,54 @@
+package components
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/workspace"
+	"github.com/rivo/tview"
+)
+
+// ShowWorkspaceSaveError reports a failed workspace save. When another
+// dbsmith instance made conflicting edits, the user chooses between keeping
+// their version and reloading the file; onResolved runs after either so the
+// caller can refresh its view.
+func ShowWorkspaceSaveError(pages *tview.Pages, app *tview.Application, ws *workspace.Manager, err error, onResolved func()) {
+	var conflict *workspace.ConflictError
+	if ws == nil || !errors.As(err, &conflict) {
+		ShowError(pages, app, err)
+		return
+	}
+
+	const pageName = "workspace-conflict"
+	message := fmt.Sprintf("The workspace was changed by another dbsmith instance.\n\nBoth edited: %s\n\n"+
+		"Keep Mine overwrites their changes; Reload discards yours.",
+		strings.Join(conflict.Conflicts, ", "))
+
+	dialog := tview.NewModal().
+		SetText(message).
+		AddButtons([]string{"Keep Mine", "Reload", "Cancel"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			pages.RemovePage(pageName)
+
+			var resolveErr error
+			switch buttonLabel {
+			case "Keep Mine":
+				resolveErr = ws.ForceSave()
+			case "Reload":
+				resolveErr = ws.Reload()
+			default:
+				return
+			}
+			if resolveErr != nil {
+				ShowError(pages, app, resolveErr)
+				return
+			}
+			if onResolved != nil {
+				onResolved()
+			}
+		})
+
+	pages.AddPage(pageName, dialog, true, true)
+	app.SetFocus(dialog)
+}
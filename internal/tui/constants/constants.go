@@ -3,10 +3,12 @@ package constants
 import "time"
 
 const (
-	TimeoutAutocomplete = 2 * time.Second
-	TimeoutSchemaLoad   = 3 * time.Second
-	TimeoutQueryExec    = 30 * time.Second
-	TimeoutConnection   = 5 * time.Second
+	TimeoutAutocomplete    = 2 * time.Second
+	TimeoutSchemaLoad      = 3 * time.Second
+	TimeoutMetadataRefresh = time.Minute
+	TimeoutQueryExec       = 30 * time.Second
+	TimeoutConnection      = 5 * time.Second
+	TimeoutDryRun          = 5 * time.Second
 )
 
 const (
@@ -137,10 +137,42 @@ func (c *completionOverlay) render() {
 }
 
 func formatCompletionLine(item autocomplete.Item) string {
+	label := highlightMatches(item.Label, item.Matches)
+	if item.Function != nil {
+		line := label + tview.Escape(item.Function.ParamList())
+		if item.Function.Returns != "" {
+			line += fmt.Sprintf(" [#%06x]→ %s[-]", theme.ThemeColors.ForegroundMuted.Hex(), tview.Escape(item.Function.Returns))
+		}
+		return line
+	}
 	if item.Detail == "" {
-		return item.Label
+		return label
+	}
+	return fmt.Sprintf("%s [#%06x]%s[-]", label, theme.ThemeColors.ForegroundMuted.Hex(), item.Detail)
+}
+
+// highlightMatches shows the characters of label matched by the typed word
+// in the accent colour.
+func highlightMatches(label string, matches []int) string {
+	if len(matches) == 0 {
+		return label
+	}
+
+	matched := make(map[int]bool, len(matches))
+	for _, m := range matches {
+		matched[m] = true
+	}
+	accent := fmt.Sprintf("[#%06x::b]", theme.ThemeColors.Accent.Hex())
+
+	var b strings.Builder
+	for i, r := range []rune(label) {
+		if matched[i] {
+			b.WriteString(accent + string(r) + "[-::-]")
+		} else {
+			b.WriteRune(r)
+		}
 	}
-	return fmt.Sprintf("%s [#%06x]%s[-]", item.Label, theme.ThemeColors.ForegroundMuted.Hex(), item.Detail)
+	return b.String()
 }
 
 func highlightCompletionLine(text string) string {
@@ -2,6 +2,7 @@ package editor
 
 import (
 	"context"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +15,8 @@ import (
 	"github.com/gdamore/tcell/v2"
 )
 
+const usageFlushDelay = 5 * time.Second
+
 type completionState struct {
 	items    []autocomplete.Item
 	selected int
@@ -22,20 +25,22 @@ type completionState struct {
 }
 
 type completionCache struct {
-	tables        []models.Table
-	tablesLoaded  time.Time
-	columnsByName map[string]columnCacheEntry
-}
+	schemas       []models.Schema
+	searchPath    []string
+	schemasLoaded time.Time
 
-type columnCacheEntry struct {
-	columns []autocomplete.Column
-	loaded  time.Time
+	functions        []autocomplete.Function
+	functionsLoaded  time.Time
+	functionsLoading bool
+
+	foreignKeys       []autocomplete.ForeignKey
+	foreignKeysLoaded time.Time
 }
 
 const completionMaxTables = 200
-const completionTablesTTL = 30 * time.Second
-const completionColumnsTTL = 2 * time.Minute
+const completionSchemasTTL = 30 * time.Second
 const completionColumnWorkers = 4
+const completionForeignKeysTTL = 2 * time.Minute
 
 // tableKey returns a normalized cache key for a table including its schema.
 // Format: "SCHEMA.TABLE" (uppercase). Empty schema uses empty prefix.
@@ -84,7 +89,23 @@ func (e *Editor) handleCompletionInput(event *tcell.EventKey) bool {
 		}
 	}
 
-	if event.Key() == tcell.KeyTab {
+	// While a snippet is being filled in, Tab moves between its tab stops
+	// and Ctrl+Space completes.
+	if e.sqlInput.InSnippet() {
+		switch event.Key() {
+		case tcell.KeyTab:
+			e.sqlInput.NextTabStop()
+			return true
+		case tcell.KeyBacktab:
+			e.sqlInput.PrevTabStop()
+			return true
+		case tcell.KeyEscape:
+			e.sqlInput.ExitSnippet()
+			return true
+		}
+	}
+
+	if event.Key() == tcell.KeyTab || event.Key() == tcell.KeyCtrlSpace {
 		if e.dbApp != nil && e.dbApp.Workspace != nil && !e.dbApp.Workspace.GetAutocompleteEnabled() {
 			return false
 		}
@@ -122,6 +143,8 @@ func (e *Editor) triggerCompletion() {
 		components.ShowError(e.pages, e.app, err)
 		return
 	}
+	acContext.Usage = e.completionUsage()
+	acContext.Snippets = e.completionSnippets()
 
 	result := autocomplete.CompleteWithAnalysis(analysis, acContext, request.Dialect)
 
@@ -181,16 +204,100 @@ func (e *Editor) applyCompletion() {
 		startOffset, endOffset = endOffset, startOffset
 	}
 
-	e.sqlInput.ReplaceRange(startOffset, endOffset, item.Label)
+	if item.Snippet != nil {
+		e.hideCompletion()
+		e.insertSnippet(startOffset, endOffset, *item.Snippet)
+		e.recordCompletionUsage(item)
+		return
+	}
+
+	text := item.Label
+	if item.Kind == autocomplete.KindFunction && !strings.HasPrefix(e.sqlInput.GetText()[endOffset:], "(") {
+		text += "("
+	}
+
 	e.hideCompletion()
+	e.sqlInput.ReplaceRange(startOffset, endOffset, text)
+	e.recordCompletionUsage(item)
+}
+
+// insertSnippet expands snippet in place of start..end, indenting its lines
+// to match the line it is inserted into.
+func (e *Editor) insertSnippet(start, end int, snippet autocomplete.Snippet) {
+	text := e.sqlInput.GetText()
+	lineStart := strings.LastIndex(text[:start], "\n") + 1
+	line := text[lineStart:start]
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+
+	body, stops := autocomplete.ExpandSnippet(snippet.Body, indent)
+	tabStops := make([]components.TabStop, len(stops))
+	for i, stop := range stops {
+		tabStops[i] = components.TabStop{Start: stop.Start, End: stop.End}
+	}
+	e.sqlInput.InsertSnippet(start, end, body, tabStops)
+}
+
+// completionSnippets returns the workspace snippets followed by those from
+// the config, so a workspace snippet shadows a config one of the same name.
+func (e *Editor) completionSnippets() []autocomplete.Snippet {
+	if e.dbApp == nil {
+		return nil
+	}
+
+	var defined []models.Snippet
+	if e.dbApp.Workspace != nil {
+		defined = append(defined, e.dbApp.Workspace.ListSnippets()...)
+	}
+	if e.dbApp.Config != nil {
+		defined = append(defined, e.dbApp.Config.Editor.Snippets...)
+	}
+
+	snippets := make([]autocomplete.Snippet, 0, len(defined))
+	for _, s := range defined {
+		snippets = append(snippets, autocomplete.Snippet{
+			Name:        s.Name,
+			Description: s.Description,
+			Body:        s.Body,
+			Dialects:    s.Dialects,
+		})
+	}
+	return snippets
+}
+
+func (e *Editor) completionUsage() map[string]int {
+	if e.dbApp == nil || e.dbApp.Workspace == nil || e.dbApp.Connection == nil {
+		return nil
+	}
+	return e.dbApp.Workspace.GetCompletionUsage(e.dbApp.Connection.Name)
+}
+
+// recordCompletionUsage counts an accepted completion so it ranks higher on
+// this connection from now on. The count is saved off the UI goroutine once
+// accepting pauses.
+func (e *Editor) recordCompletionUsage(item autocomplete.Item) {
+	if e.dbApp == nil || e.dbApp.Workspace == nil || e.dbApp.Connection == nil {
+		return
+	}
+	ws := e.dbApp.Workspace
+	ws.RecordCompletion(e.dbApp.Connection.Name, autocomplete.UsageKey(item))
+
+	if e.usageFlush != nil {
+		e.usageFlush.Stop()
+	}
+	e.usageFlush = time.AfterFunc(usageFlushDelay, func() {
+		if err := ws.FlushCompletionUsage(); err != nil {
+			logging.Warn().Err(err).Msg("Failed to save completion usage")
+		}
+	})
 }
 
 func (e *Editor) getAutocompleteContext(analysis autocomplete.Analysis) (autocomplete.Context, error) {
-	if e.dbApp == nil || e.dbApp.Explorer == nil {
+	if e.dbApp == nil || e.dbApp.Explorer == nil || e.dbApp.Metadata == nil {
 		return autocomplete.Context{}, nil
 	}
 
-	needsTables := analysis.HasKind(autocomplete.KindTable) || analysis.HasKind(autocomplete.KindColumn)
+	needsTables := analysis.HasKind(autocomplete.KindTable) || analysis.HasKind(autocomplete.KindColumn) ||
+		analysis.HasKind(autocomplete.KindSchema)
 	if !needsTables {
 		return autocomplete.Context{}, nil
 	}
@@ -198,46 +305,106 @@ func (e *Editor) getAutocompleteContext(analysis autocomplete.Analysis) (autocom
 	ctx, cancel := context.WithTimeout(context.Background(), constants.TimeoutAutocomplete)
 	defer cancel()
 
-	tables, err := e.loadCompletionTables(ctx)
+	// A qualifier may name a schema that is not loaded by default.
+	schemaHint := analysis.Schema
+	if schemaHint == "" {
+		schemaHint = analysis.Qualifier
+	}
+	tables, err := e.loadCompletionTables(ctx, schemaHint)
 	if err != nil {
 		return autocomplete.Context{}, err
 	}
 
-	if len(tables) > completionMaxTables {
-		tables = tables[:completionMaxTables]
+	var columnsByTable map[string][]autocomplete.Column
+	if analysis.HasKind(autocomplete.KindColumn) {
+		columnsByTable = e.loadCompletionColumns(ctx, tables, analysis.TargetTables())
 	}
 
-	if !analysis.HasKind(autocomplete.KindColumn) {
-		return buildContextFromTables(tables, nil), nil
+	result := buildContextFromTables(tables, columnsByTable)
+	result.Schemas = schemaNames(e.completionCache.schemas)
+	result.SearchPath = e.completionCache.searchPath
+	if analysis.HasKind(autocomplete.KindFunction) {
+		result.Functions = e.loadCompletionFunctions(ctx)
+	}
+	if analysis.HasKind(autocomplete.KindJoin) {
+		result.ForeignKeys = e.loadCompletionForeignKeys(ctx)
+	}
+	return result, nil
+}
+
+// loadCompletionForeignKeys returns cached foreign keys, or none when they
+// cannot be read, so join suggestions fall back to column names.
+func (e *Editor) loadCompletionForeignKeys(ctx context.Context) []autocomplete.ForeignKey {
+	if !e.completionCache.foreignKeysLoaded.IsZero() && time.Since(e.completionCache.foreignKeysLoaded) < completionForeignKeysTTL {
+		return e.completionCache.foreignKeys
+	}
+
+	keys, err := e.dbApp.Explorer.GetForeignKeys(ctx)
+	if err != nil {
+		logging.Warn().Err(err).Msg("Failed to load foreign keys for autocomplete")
+		return nil
 	}
 
-	targets := analysis.TargetTables()
-	columnsByTable := e.loadCompletionColumns(ctx, tables, targets)
+	foreignKeys := make([]autocomplete.ForeignKey, 0, len(keys))
+	for _, fk := range keys {
+		foreignKeys = append(foreignKeys, autocomplete.ForeignKey{
+			Schema:     fk.Schema,
+			Table:      fk.Table,
+			Columns:    fk.Columns,
+			RefSchema:  fk.RefSchema,
+			RefTable:   fk.RefTable,
+			RefColumns: fk.RefColumns,
+		})
+	}
 
-	return buildContextFromTables(tables, columnsByTable), nil
+	e.completionCache.foreignKeys = foreignKeys
+	e.completionCache.foreignKeysLoaded = time.Now()
+	return foreignKeys
 }
 
-func (e *Editor) loadCompletionTables(ctx context.Context) ([]models.Table, error) {
-	if !e.completionCache.tablesLoaded.IsZero() && time.Since(e.completionCache.tablesLoaded) < completionTablesTTL {
-		return e.completionCache.tables, nil
+func (e *Editor) loadCompletionSchemas(ctx context.Context) ([]models.Schema, error) {
+	if !e.completionCache.schemasLoaded.IsZero() && time.Since(e.completionCache.schemasLoaded) < completionSchemasTTL {
+		return e.completionCache.schemas, nil
 	}
 
-	schemas, err := e.dbApp.Explorer.GetSchemas(ctx)
+	schemas, err := e.dbApp.Metadata.Schemas(ctx)
 	if err != nil {
 		return nil, err
 	}
-
 	if len(schemas) == 0 {
 		schemas = []models.Schema{{Name: ""}}
 	}
 
+	searchPath, err := e.dbApp.Explorer.GetSearchPath(ctx)
+	if err != nil {
+		logging.Warn().Err(err).Msg("Failed to load search path for autocomplete")
+		searchPath = nil
+	}
+
+	e.completionCache.schemas = schemas
+	e.completionCache.searchPath = searchPath
+	e.completionCache.schemasLoaded = time.Now()
+	return schemas, nil
+}
+
+// loadCompletionTables loads tables schema by schema, starting with
+// schemaHint and the search path so the tables most likely to be typed
+// survive the completionMaxTables cap.
+func (e *Editor) loadCompletionTables(ctx context.Context, schemaHint string) ([]models.Table, error) {
+	schemas, err := e.loadCompletionSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	searchPath := e.completionCache.searchPath
+	schemas = orderCompletionSchemas(schemas, searchPath, schemaHint)
 	if e.dbApp.Config != nil && !e.dbApp.Config.UI.ShowSchemas && len(schemas) > 1 {
-		schemas = schemas[:1]
+		schemas = preferredCompletionSchemas(schemas, searchPath, schemaHint)
 	}
 
 	var tables []models.Table
 	for _, schema := range schemas {
-		schemaTables, err := e.dbApp.Explorer.GetTables(ctx, schema)
+		schemaTables, err := e.dbApp.Metadata.Tables(ctx, schema.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -248,27 +415,72 @@ func (e *Editor) loadCompletionTables(ctx context.Context) ([]models.Table, erro
 		}
 	}
 
-	e.completionCache.tables = tables
-	e.completionCache.tablesLoaded = time.Now()
 	return tables, nil
 }
 
+func schemaRank(name string, searchPath []string, schemaHint string) int {
+	if schemaHint != "" && strings.EqualFold(name, schemaHint) {
+		return 0
+	}
+	for i, schema := range searchPath {
+		if strings.EqualFold(name, schema) {
+			return i + 1
+		}
+	}
+	return len(searchPath) + 1
+}
+
+func orderCompletionSchemas(schemas []models.Schema, searchPath []string, schemaHint string) []models.Schema {
+	ordered := append([]models.Schema{}, schemas...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return schemaRank(ordered[i].Name, searchPath, schemaHint) < schemaRank(ordered[j].Name, searchPath, schemaHint)
+	})
+	return ordered
+}
+
+// preferredCompletionSchemas keeps the hinted and search path schemas, or
+// the first schema when neither is known.
+func preferredCompletionSchemas(ordered []models.Schema, searchPath []string, schemaHint string) []models.Schema {
+	var preferred []models.Schema
+	for _, schema := range ordered {
+		if schemaRank(schema.Name, searchPath, schemaHint) <= len(searchPath) {
+			preferred = append(preferred, schema)
+		}
+	}
+	if len(preferred) == 0 {
+		return ordered[:1]
+	}
+	return preferred
+}
+
+func schemaNames(schemas []models.Schema) []string {
+	names := make([]string, 0, len(schemas))
+	for _, schema := range schemas {
+		if schema.Name != "" {
+			names = append(names, schema.Name)
+		}
+	}
+	return names
+}
+
+// loadCompletionColumns reads the columns of the target tables, or of every
+// table when there are none, from the metadata cache. Misses are fetched
+// concurrently.
 func (e *Editor) loadCompletionColumns(ctx context.Context, tables []models.Table, targetNames []string) map[string][]autocomplete.Column {
 	if len(tables) == 0 {
 		return nil
 	}
 
-	if e.completionCache.columnsByName == nil {
-		e.completionCache.columnsByName = make(map[string]columnCacheEntry)
-	}
-
-	columnsByTable := map[string][]autocomplete.Column{}
 	targetSet := map[string]bool{}
 	for _, name := range targetNames {
 		targetSet[strings.ToUpper(name)] = true
 	}
 
-	var tablesToLoad []models.Table
+	var mu sync.Mutex
+	columnsByTable := map[string][]autocomplete.Column{}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, completionColumnWorkers)
+
 	for _, table := range tables {
 		key := tableKey(table.Schema, table.Name)
 		// Also check for unqualified name match if target has no schema
@@ -276,33 +488,15 @@ func (e *Editor) loadCompletionColumns(ctx context.Context, tables []models.Tabl
 		if len(targetSet) > 0 && !targetSet[key] && !targetSet[nameOnly] {
 			continue
 		}
-		entry, ok := e.completionCache.columnsByName[key]
-		if ok && time.Since(entry.loaded) < completionColumnsTTL {
-			columnsByTable[key] = entry.columns
-			continue
-		}
-		tablesToLoad = append(tablesToLoad, table)
-	}
-
-	if len(tablesToLoad) == 0 {
-		return columnsByTable
-	}
 
-	var mu sync.Mutex
-	newEntries := make(map[string]columnCacheEntry)
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, completionColumnWorkers)
-
-	for _, table := range tablesToLoad {
 		table := table
-		key := tableKey(table.Schema, table.Name)
 		wg.Add(1)
 		sem <- struct{}{}
 		go func() {
 			defer wg.Done()
 			defer func() { <-sem }()
 
-			columns, err := e.dbApp.Explorer.GetTableColumns(ctx, table.Schema, table.Name)
+			columns, err := e.dbApp.Metadata.TableColumns(ctx, table.Schema, table.Name)
 			if err != nil {
 				logging.Warn().
 					Err(err).
@@ -319,20 +513,11 @@ func (e *Editor) loadCompletionColumns(ctx context.Context, tables []models.Tabl
 
 			mu.Lock()
 			columnsByTable[key] = cols
-			newEntries[key] = columnCacheEntry{
-				columns: cols,
-				loaded:  time.Now(),
-			}
 			mu.Unlock()
 		}()
 	}
 
 	wg.Wait()
-
-	for key, entry := range newEntries {
-		e.completionCache.columnsByName[key] = entry
-	}
-
 	return columnsByTable
 }
 
@@ -0,0 +1,347 @@
+package editor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/android-lewis/dbsmith/internal/db"
+	querysafety "github.com/android-lewis/dbsmith/internal/editor"
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/android-lewis/dbsmith/internal/tui/components"
+	"github.com/android-lewis/dbsmith/internal/tui/constants"
+	"github.com/android-lewis/dbsmith/internal/tui/theme"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const diagnosticsDelay = 400 * time.Millisecond
+const diagnosticsSearchPathTTL = 30 * time.Second
+const diagnosticsMaxHeight = 4
+const diagnosticsMaxColumnTables = 20
+
+// diagnosticsState holds the diagnostics shown for the buffer. live comes
+// from the last pass over the buffer; server is the position of the last
+// query error and is dropped on the next edit. generation, live, server and
+// current are only touched on the UI goroutine.
+type diagnosticsState struct {
+	timer      *time.Timer
+	generation int
+	live       []querysafety.Diagnostic
+	server     *querysafety.Diagnostic
+	current    int
+
+	mu               sync.Mutex
+	searchPath       []string
+	searchPathLoaded time.Time
+}
+
+func newDiagnosticsView() *tview.TextView {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWrap(false)
+	view.SetBackgroundColor(theme.ThemeColors.Background)
+	return view
+}
+
+// scheduleDiagnostics runs a diagnostics pass once typing pauses. It must be
+// called on the UI goroutine.
+func (e *Editor) scheduleDiagnostics() {
+	e.diagnostics.generation++
+	generation := e.diagnostics.generation
+	if e.diagnostics.server != nil {
+		e.diagnostics.server = nil
+		e.refreshDiagnostics()
+	}
+
+	if e.diagnostics.timer != nil {
+		e.diagnostics.timer.Stop()
+	}
+	e.diagnostics.timer = time.AfterFunc(diagnosticsDelay, func() {
+		e.app.QueueUpdateDraw(func() {
+			e.startDiagnostics(generation)
+		})
+	})
+}
+
+func (e *Editor) startDiagnostics(generation int) {
+	if generation != e.diagnostics.generation {
+		return
+	}
+	text := e.sqlInput.GetText()
+	dialect := e.sqlInput.GetDialect()
+
+	go func() {
+		var catalog *querysafety.Catalog
+		if strings.TrimSpace(text) != "" {
+			catalog = e.diagnosticsCatalog(text, dialect)
+		}
+		diags := querysafety.Diagnose(text, dialect, catalog)
+
+		e.app.QueueUpdateDraw(func() {
+			if generation != e.diagnostics.generation {
+				return
+			}
+			e.diagnostics.live = diags
+			e.diagnostics.current = -1
+			e.refreshDiagnostics()
+		})
+	}()
+}
+
+// diagnosticsCatalog builds the catalog names are checked against from the
+// metadata cache. Columns are only read for the tables text refers to. It
+// returns nil, which limits diagnostics to syntax, when the tables cannot
+// all be listed.
+func (e *Editor) diagnosticsCatalog(text, dialect string) *querysafety.Catalog {
+	if e.dbApp.Metadata == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), constants.TimeoutAutocomplete)
+	defer cancel()
+
+	schemas, err := e.dbApp.Metadata.Schemas(ctx)
+	if err != nil {
+		logging.Debug().Err(err).Msg("Skipping name diagnostics: schemas unavailable")
+		return nil
+	}
+	if len(schemas) == 0 {
+		schemas = []models.Schema{{Name: ""}}
+	}
+
+	catalog := &querysafety.Catalog{}
+	for _, schema := range schemas {
+		tables, err := e.dbApp.Metadata.Tables(ctx, schema.Name)
+		if err != nil {
+			logging.Debug().Err(err).Str("schema", schema.Name).Msg("Skipping name diagnostics: tables unavailable")
+			return nil
+		}
+		if schema.Name != "" {
+			catalog.Schemas = append(catalog.Schemas, schema.Name)
+		}
+		for _, table := range tables {
+			catalog.Tables = append(catalog.Tables, querysafety.CatalogTable{Schema: schema.Name, Name: table.Name})
+		}
+	}
+
+	for _, schema := range e.diagnosticsSearchPath(ctx) {
+		for _, known := range catalog.Schemas {
+			if strings.EqualFold(schema, known) {
+				catalog.SearchPath = append(catalog.SearchPath, known)
+				break
+			}
+		}
+	}
+
+	loaded := 0
+	for _, ref := range querysafety.ReferencedTables(text, dialect) {
+		for i := range catalog.Tables {
+			table := &catalog.Tables[i]
+			if table.Columns != nil || !strings.EqualFold(table.Name, ref.Name) ||
+				ref.Schema != "" && !strings.EqualFold(table.Schema, ref.Schema) {
+				continue
+			}
+			if loaded >= diagnosticsMaxColumnTables {
+				break
+			}
+			loaded++
+			columns, err := e.dbApp.Metadata.TableColumns(ctx, table.Schema, table.Name)
+			if err != nil || columns == nil || len(columns.Columns) == 0 {
+				continue
+			}
+			for _, column := range columns.Columns {
+				table.Columns = append(table.Columns, column.Name)
+				if column.IsPrimaryKey {
+					table.PrimaryKey = append(table.PrimaryKey, column.Name)
+				}
+			}
+		}
+	}
+	return catalog
+}
+
+// diagnosticsSearchPath returns the schemas unqualified names resolve in,
+// cached for a while. It may be called from any goroutine.
+func (e *Editor) diagnosticsSearchPath(ctx context.Context) []string {
+	e.diagnostics.mu.Lock()
+	defer e.diagnostics.mu.Unlock()
+	if !e.diagnostics.searchPathLoaded.IsZero() && time.Since(e.diagnostics.searchPathLoaded) < diagnosticsSearchPathTTL {
+		return e.diagnostics.searchPath
+	}
+	if e.dbApp.Explorer == nil {
+		return nil
+	}
+
+	searchPath, err := e.dbApp.Explorer.GetSearchPath(ctx)
+	if err != nil {
+		logging.Warn().Err(err).Msg("Failed to load search path for diagnostics")
+		return nil
+	}
+	e.diagnostics.searchPath = searchPath
+	e.diagnostics.searchPathLoaded = time.Now()
+	return searchPath
+}
+
+// allDiagnostics returns the server error, if any, followed by the live
+// diagnostics.
+func (e *Editor) allDiagnostics() []querysafety.Diagnostic {
+	if e.diagnostics.server == nil {
+		return e.diagnostics.live
+	}
+	return append([]querysafety.Diagnostic{*e.diagnostics.server}, e.diagnostics.live...)
+}
+
+// refreshDiagnostics underlines the diagnostics in the editor and lists them
+// below it.
+func (e *Editor) refreshDiagnostics() {
+	diags := e.allDiagnostics()
+	text := e.sqlInput.GetText()
+
+	var marks []components.Mark
+	for _, severity := range []querysafety.Severity{querysafety.SeverityError, querysafety.SeverityWarning} {
+		for _, d := range diags {
+			if d.Severity == severity {
+				marks = append(marks, components.Mark{Start: d.Start, End: d.End, Color: diagnosticColor(d.Severity)})
+			}
+		}
+	}
+	e.sqlInput.SetMarks(marks)
+
+	var b strings.Builder
+	for i, d := range diags {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		line, col := lineColumn(text, d.Start)
+		pointer := " "
+		if i == e.diagnostics.current {
+			pointer = "›"
+		}
+		name := theme.ColorError
+		if d.Severity == querysafety.SeverityWarning {
+			name = theme.ColorWarning
+		}
+		fmt.Fprintf(&b, "%s%s●%s %d:%d %s", pointer, theme.ColorTag(name, false), theme.ColorTagReset(), line, col, tview.Escape(d.Message))
+	}
+	e.diagnosticsView.SetText(b.String())
+	e.diagnosticsView.ScrollTo(max(e.diagnostics.current, 0), 0)
+	e.mainFlex.ResizeItem(e.diagnosticsView, min(len(diags), diagnosticsMaxHeight), 0)
+}
+
+func diagnosticColor(severity querysafety.Severity) tcell.Color {
+	if severity == querysafety.SeverityWarning {
+		return theme.ThemeColors.Warning
+	}
+	return theme.ThemeColors.Error
+}
+
+// jumpToDiagnostic moves the cursor to the next diagnostic after it, or the
+// previous one before it, wrapping around.
+func (e *Editor) jumpToDiagnostic(forward bool) {
+	diags := e.allDiagnostics()
+	if len(diags) == 0 {
+		return
+	}
+
+	line, col := e.sqlInput.CursorPosition()
+	cursor := e.sqlInput.OffsetAt(line, col)
+	target := -1
+	if forward {
+		for i, d := range diags {
+			if d.Start > cursor && (target < 0 || d.Start < diags[target].Start) {
+				target = i
+			}
+		}
+		if target < 0 {
+			target = 0
+		}
+	} else {
+		for i, d := range diags {
+			if d.Start < cursor && (target < 0 || d.Start > diags[target].Start) {
+				target = i
+			}
+		}
+		if target < 0 {
+			target = len(diags) - 1
+		}
+	}
+
+	e.diagnostics.current = target
+	e.sqlInput.Select(diags[target].Start, diags[target].Start)
+	e.refreshDiagnostics()
+}
+
+// markServerError maps the position a database error reports back onto
+// ran, the buffer the failed query was taken from. While the buffer is
+// unchanged it also underlines the word there and moves the cursor to it. It
+// returns where the error is, such as "line 2, column 8", or "" when the
+// server did not say.
+func (e *Editor) markServerError(err error, ran string) string {
+	qe, ok := db.AsQueryError(err)
+	if !ok || qe.Position < 0 || qe.Query == "" {
+		return ""
+	}
+	position := qe.Position
+	offset := strings.Index(ran, qe.Query)
+	if offset < 0 {
+		// The query wraps the buffer, as EXPLAIN does.
+		trimmed := strings.TrimSpace(ran)
+		k := strings.Index(qe.Query, trimmed)
+		if trimmed == "" || k < 0 {
+			return ""
+		}
+		position -= utf8.RuneCountInString(qe.Query[:k])
+		offset = strings.Index(ran, trimmed)
+		if position < 0 {
+			return ""
+		}
+	}
+	for i := 0; i < position && offset < len(ran); i++ {
+		_, size := utf8.DecodeRuneInString(ran[offset:])
+		offset += size
+	}
+
+	if e.sqlInput.GetText() == ran {
+		end := offset
+		for end < len(ran) {
+			r, size := utf8.DecodeRuneInString(ran[end:])
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+				break
+			}
+			end += size
+		}
+		e.diagnostics.server = &querysafety.Diagnostic{
+			Start:    offset,
+			End:      end,
+			Severity: querysafety.SeverityError,
+			Message:  qe.Message,
+		}
+		e.diagnostics.current = 0
+		e.sqlInput.Select(offset, offset)
+		e.refreshDiagnostics()
+	}
+
+	line, col := lineColumn(ran, offset)
+	return fmt.Sprintf("line %d, column %d", line, col)
+}
+
+// showQueryError reports err from running a query taken from ran, the
+// buffer at the time, and marks where in it the server located the error.
+func (e *Editor) showQueryError(title string, err error, ran string) {
+	location := e.markServerError(err, ran)
+	components.ShowQueryError(e.pages, e.app, title, fmt.Errorf("%s: %w", strings.ToLower(title), err), location, e.sqlInput)
+}
+
+// lineColumn converts a byte offset into a one-based line and column.
+func lineColumn(text string, offset int) (int, int) {
+	offset = min(offset, len(text))
+	before := text[:offset]
+	line := strings.Count(before, "\n") + 1
+	col := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	return line, col
+}
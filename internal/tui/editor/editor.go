@@ -8,6 +8,9 @@ import (
 
 	"github.com/android-lewis/dbsmith/internal/app"
 	querysafety "github.com/android-lewis/dbsmith/internal/editor"
+	"github.com/android-lewis/dbsmith/internal/formatter"
+	"github.com/android-lewis/dbsmith/internal/keymap"
+	"github.com/android-lewis/dbsmith/internal/logging"
 	"github.com/android-lewis/dbsmith/internal/models"
 	"github.com/android-lewis/dbsmith/internal/tui/components"
 	"github.com/android-lewis/dbsmith/internal/tui/constants"
@@ -22,7 +25,8 @@ type editorMode int
 
 const (
 	modeExecute editorMode = iota
-	modeAnalyze
+	modeExplain
+	modeExplainAnalyze
 )
 
 type Editor struct {
@@ -35,10 +39,12 @@ type Editor struct {
 	mainFlex          *tview.Flex
 	sqlInput          *components.SQLEditor
 	resultsTable      *tview.Table
-	analysisView      *tview.TextView
+	planView          *planView
 	bottomFlex        *tview.Flex
 	queryStats        *components.QueryStats
+	diagnosticsView   *tview.TextView
 	completionOverlay *completionOverlay
+	signatureOverlay  *signatureOverlay
 
 	mode           editorMode
 	lastResult     *models.QueryResult
@@ -47,6 +53,17 @@ type Editor struct {
 
 	completionState completionState
 	completionCache completionCache
+	diagnostics     diagnosticsState
+
+	// usageFlush saves the recorded completion counts once accepting pauses.
+	usageFlush *time.Timer
+
+	// keymap is the Vim or Emacs keymap, or nil for the default bindings.
+	keymap keymap.Keymap
+
+	// runText is the buffer the running query was taken from, used to place
+	// the position of a server error.
+	runText string
 
 	// Result state for selection callback (avoids re-registering callback per query)
 	resultRowCount int
@@ -74,6 +91,7 @@ func NewEditor(app *tview.Application, pages *tview.Pages, dbApp *app.App, helpB
 
 	e.savedQueriesManager = components.NewSavedQueriesManager(pages, app, dbApp.Workspace)
 	e.exportManager = components.NewExportManager(pages, app)
+	e.keymap = e.newKeymap()
 
 	e.buildUI()
 	e.configureExportCallbacks()
@@ -91,17 +109,19 @@ func (e *Editor) buildUI() {
 		}
 	}
 
-	dialect := e.getDialectDisplayName()
 	e.sqlInput.SetBorder(true).
-		SetTitle(fmt.Sprintf(" SQL Editor [%s] ", dialect)).
 		SetTitleAlign(tview.AlignLeft)
+	e.refreshTitle()
+	e.applyEnvironmentStyle()
 
 	e.sqlInput.SetChangedFunc(func() {
 		if e.onCheckModified != nil {
 			e.onCheckModified()
 		}
 		e.hideCompletion()
+		e.scheduleDiagnostics()
 	})
+	e.sqlInput.SetMovedFunc(e.updateSignatureHelp)
 
 	e.resultsTable = tview.NewTable().
 		SetBorders(false).
@@ -130,17 +150,13 @@ func (e *Editor) buildUI() {
 		}
 	})
 
-	e.analysisView = tview.NewTextView().
-		SetDynamicColors(true).
-		SetScrollable(true).
-		SetWordWrap(true)
-
-	e.analysisView.SetBorder(true).
-		SetTitle(" Query Analysis ").
-		SetTitleAlign(tview.AlignLeft)
+	e.planView = newPlanView()
 
 	e.queryStats = components.NewQueryStats()
+	e.diagnosticsView = newDiagnosticsView()
+	e.diagnostics.current = -1
 	e.completionOverlay = newCompletionOverlay(e.pages)
+	e.signatureOverlay = newSignatureOverlay(e.pages)
 
 	e.bottomFlex = tview.NewFlex().
 		AddItem(e.resultsTable, 0, 1, false)
@@ -148,6 +164,7 @@ func (e *Editor) buildUI() {
 	e.mainFlex = tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(e.sqlInput, 8, 2, true).
+		AddItem(e.diagnosticsView, 0, 0, false).
 		AddItem(e.queryStats, 3, 0, false).
 		AddItem(e.bottomFlex, 0, 3, false)
 
@@ -156,12 +173,19 @@ func (e *Editor) buildUI() {
 
 func (e *Editor) setupKeybindings() {
 	e.sqlInput.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if e.completionState.active && e.handleCompletionInput(event) {
+			return nil
+		}
+		if e.handleKeymap(event) {
+			return nil
+		}
 		if e.handleCompletionInput(event) {
 			return nil
 		}
 
 		switch event.Key() {
 		case tcell.KeyEscape:
+			e.hideSignatureHelp()
 			if e.isQueryRunning && e.queryCancel != nil {
 				e.queryCancel()
 			}
@@ -171,6 +195,14 @@ func (e *Editor) setupKeybindings() {
 			e.executeQuery()
 			return nil
 
+		case tcell.KeyF8:
+			e.jumpToDiagnostic(event.Modifiers()&tcell.ModShift == 0)
+			return nil
+
+		case tcell.KeyF20:
+			e.jumpToDiagnostic(false)
+			return nil
+
 		case tcell.KeyEnter:
 			if event.Modifiers()&tcell.ModShift != 0 {
 				e.executeQuery()
@@ -183,6 +215,9 @@ func (e *Editor) setupKeybindings() {
 			case 'm', 'M':
 				e.toggleMode()
 				return nil
+			case 'f', 'F':
+				e.formatSQL()
+				return nil
 			case 's', 'S':
 				e.savedQueriesManager.QuickSave(e.sqlInput)
 				return nil
@@ -207,6 +242,15 @@ func (e *Editor) setupKeybindings() {
 		return event
 	})
 
+	e.planView.tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyTab {
+			e.app.SetFocus(e.sqlInput)
+			theme.SetFocused(e.sqlInput)
+			return nil
+		}
+		return event
+	})
+
 	e.resultsTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyTab {
 			e.app.SetFocus(e.sqlInput)
@@ -243,6 +287,31 @@ func (e *Editor) ConfigureSavedQueriesCallbacks() {
 		e.getSQLText,
 		e.getCurrentSavedQueryID,
 	)
+	e.savedQueriesManager.SetConnectionNameFunc(func() string {
+		if e.dbApp.Connection == nil {
+			return ""
+		}
+		return e.dbApp.Connection.Name
+	})
+}
+
+// recordSavedQueryRun counts a successful run of the tab's saved query. It
+// must be called on the UI goroutine.
+func (e *Editor) recordSavedQueryRun() {
+	if e.getCurrentSavedQueryID == nil || e.dbApp.Workspace == nil {
+		return
+	}
+	queryID := e.getCurrentSavedQueryID()
+	if queryID == "" {
+		return
+	}
+	connName := ""
+	if e.dbApp.Connection != nil {
+		connName = e.dbApp.Connection.Name
+	}
+	if err := e.dbApp.Workspace.RecordQueryExecution(queryID, connName); err != nil {
+		logging.Warn().Err(err).Str("query_id", queryID).Msg("Failed to record saved query usage")
+	}
 }
 
 func (e *Editor) configureExportCallbacks() {
@@ -262,6 +331,8 @@ func (e *Editor) configureExportCallbacks() {
 	)
 }
 
+const explainAnalyzeWarning = "EXPLAIN ANALYZE executes the statement. It runs inside a transaction that is rolled back afterwards, but side effects outside the transaction (sequences, triggers calling out, DDL on MySQL) may persist."
+
 func (e *Editor) executeQuery() {
 	sql, err := e.validateQueryPrerequisites()
 	if err != nil {
@@ -269,18 +340,28 @@ func (e *Editor) executeQuery() {
 		return
 	}
 
-	// Check for destructive queries and show confirmation
-	safetyInfo := querysafety.AnalyzeQuerySafety(sql)
-	if safetyInfo.IsDestructive {
-		confirmMsg := fmt.Sprintf("%s Query Warning\n\n%s\n\nAre you sure you want to execute this query?",
-			safetyInfo.QueryType, safetyInfo.Warning)
+	if e.mode == modeExplain {
+		e.prepareForQueryExecution()
+		go e.runQuery(sql)
+		return
+	}
+
+	analysis := querysafety.AnalyzeStatements(sql, e.sqlInput.GetDialect())
 
-		components.ShowConfirm(e.pages, e.app, confirmMsg, func(confirmed bool) {
-			if confirmed {
-				e.prepareForQueryExecution()
-				go e.runQuery(sql)
-			}
-		})
+	if err := e.checkRowCap(analysis); err != nil {
+		components.ShowError(e.pages, e.app, err)
+		return
+	}
+
+	c := e.confirmationFor(analysis)
+	if e.mode == modeExplainAnalyze && !analysis.IsReadOnly() {
+		// EXPLAIN ANALYZE runs the statement, so it always asks, on top of
+		// whatever the environment policy requires.
+		c.required = true
+		c.reason = strings.TrimSpace(explainAnalyzeWarning + "\n" + c.reason)
+	}
+	if c.required {
+		e.confirmStatements(sql, analysis, c)
 		return
 	}
 
@@ -288,6 +369,33 @@ func (e *Editor) executeQuery() {
 	go e.runQuery(sql)
 }
 
+// formatSQL reformats the selection, or the whole buffer when nothing is
+// selected, using the formatter options from the editor config.
+func (e *Editor) formatSQL() {
+	opts := formatter.DefaultOptions()
+	if e.dbApp.Config != nil {
+		opts = formatter.OptionsFromConfig(e.dbApp.Config.Editor.Format)
+	}
+
+	text, start, end := e.sqlInput.GetSelection()
+	if start == end {
+		text = e.sqlInput.GetText()
+		start, end = 0, len(text)
+	}
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	formatted, err := formatter.Format(text, e.sqlInput.GetDialect(), opts)
+	if err != nil {
+		components.ShowError(e.pages, e.app, fmt.Errorf("format failed: %w", err))
+		return
+	}
+	if formatted != text {
+		e.sqlInput.ReplaceRange(start, end, formatted)
+	}
+}
+
 func (e *Editor) validateQueryPrerequisites() (string, error) {
 	sql := strings.TrimSpace(e.sqlInput.GetText())
 	if sql == "" {
@@ -305,10 +413,10 @@ func (e *Editor) prepareForQueryExecution() {
 	e.resultsTable.Clear()
 	e.resultsTable.SetCell(0, 0, tview.NewTableCell("Executing query..."))
 
-	dialectName := e.getDialectDisplayName()
-	e.sqlInput.SetTitle(fmt.Sprintf(" SQL Editor [%s] Running - Press Esc to cancel ", dialectName))
+	e.runText = e.sqlInput.GetText()
 
 	e.isQueryRunning = true
+	e.refreshTitle()
 	if e.onRunningStateChange != nil {
 		e.onRunningStateChange(true)
 	}
@@ -324,7 +432,7 @@ func (e *Editor) runQuery(sql string) {
 	if e.mode == modeExecute {
 		cancelled = e.executeQueryMode(ctx, sql)
 	} else {
-		cancelled = e.executeAnalyzeMode(ctx, sql)
+		cancelled = e.executeAnalyzeMode(ctx, sql, e.mode == modeExplainAnalyze)
 	}
 }
 
@@ -337,10 +445,7 @@ func (e *Editor) cleanupAfterQuery(cancelled *bool) {
 	}
 	e.queryCancel = nil
 
-	dialectName := e.getDialectDisplayName()
-	e.app.QueueUpdateDraw(func() {
-		e.sqlInput.SetTitle(fmt.Sprintf(" SQL Editor [%s] ", dialectName))
-	})
+	e.app.QueueUpdateDraw(e.refreshTitle)
 
 	if *cancelled {
 		e.showCancellationMessage()
@@ -356,15 +461,21 @@ func (e *Editor) showCancellationMessage() {
 				tview.NewTableCell("Query cancelled by user").
 					SetTextColor(theme.ThemeColors.Warning))
 		})
-	case modeAnalyze:
+	case modeExplain, modeExplainAnalyze:
 		e.app.QueueUpdateDraw(func() {
-			e.analysisView.Clear()
-			e.analysisView.SetText("Analysis cancelled by user")
+			e.planView.SetMessage("Analysis cancelled by user")
 		})
 	}
 }
 
 func (e *Editor) executeQueryMode(ctx context.Context, sql string) bool {
+	if maxRows := e.dbApp.EnvironmentPolicy().MaxRowsAffected; maxRows > 0 {
+		analysis := querysafety.AnalyzeStatements(sql, e.sqlInput.GetDialect())
+		if analysis.ModifiesRows() {
+			return e.executeCappedWrite(ctx, analysis, maxRows)
+		}
+	}
+
 	startTime := time.Now()
 	result, err := e.dbApp.Executor.ExecuteQuery(ctx, sql)
 	duration := time.Since(startTime)
@@ -374,7 +485,7 @@ func (e *Editor) executeQueryMode(ctx context.Context, sql string) bool {
 			return true
 		}
 		e.app.QueueUpdateDraw(func() {
-			components.ShowError(e.pages, e.app, fmt.Errorf("query failed: %w", err))
+			e.showQueryError("Query failed", err, e.runText)
 			e.resultsTable.Clear()
 		})
 		return false
@@ -382,29 +493,52 @@ func (e *Editor) executeQueryMode(ctx context.Context, sql string) bool {
 
 	e.lastResult = result
 	rowCount := len(result.Rows)
+	ranDDL := e.invalidateMetadata(sql)
 
 	e.app.QueueUpdateDraw(func() {
+		if ranDDL {
+			e.completionCache.schemasLoaded = time.Time{}
+			e.completionCache.foreignKeysLoaded = time.Time{}
+		}
 		e.queryStats.RecordQuery(duration, rowCount)
+		e.recordSavedQueryRun()
 		e.displayResults(result)
 	})
 	return false
 }
 
-func (e *Editor) executeAnalyzeMode(ctx context.Context, sql string) bool {
-	result, err := e.dbApp.Executor.GetQueryExecutionPlan(ctx, sql)
+// invalidateMetadata drops the cached metadata of the objects DDL in sql
+// changed, so the explorer and autocomplete see them as they are now. It
+// reports whether sql contained DDL.
+func (e *Editor) invalidateMetadata(sql string) bool {
+	ranDDL := false
+	for _, stmt := range querysafety.AnalyzeStatements(sql, e.sqlInput.GetDialect()).Statements {
+		if !stmt.IsDDL() {
+			continue
+		}
+		ranDDL = true
+		if e.dbApp.Metadata != nil {
+			e.dbApp.Metadata.Invalidate(stmt.Objects...)
+		}
+	}
+	return ranDDL
+}
+
+func (e *Editor) executeAnalyzeMode(ctx context.Context, sql string, analyze bool) bool {
+	plan, err := e.dbApp.Executor.GetQueryExecutionPlan(ctx, sql, analyze)
 	if err != nil {
 		if ctx.Err() == context.Canceled {
 			return true
 		}
 		e.app.QueueUpdateDraw(func() {
-			components.ShowError(e.pages, e.app, fmt.Errorf("analysis failed: %w", err))
-			e.analysisView.Clear()
+			e.showQueryError("Analysis failed", err, e.runText)
+			e.planView.SetMessage("No plan available")
 		})
 		return false
 	}
 
 	e.app.QueueUpdateDraw(func() {
-		e.displayAnalysis(result)
+		e.displayAnalysis(plan)
 	})
 	return false
 }
@@ -437,33 +571,24 @@ func (e *Editor) displayResults(result *models.QueryResult) {
 	theme.SetFocused(e.resultsTable)
 }
 
-func (e *Editor) displayAnalysis(result *models.QueryResult) {
-	e.analysisView.Clear()
-
-	var output strings.Builder
-	output.WriteString(theme.ColorTag(theme.ColorWarning, true))
-	output.WriteString("Query Plan")
-	output.WriteString(theme.ColorTagReset())
-	output.WriteString("\n\n")
-
-	for _, row := range result.Rows {
-		for _, val := range row {
-			output.WriteString(fmt.Sprintf("%v\n", val))
-		}
-	}
-
-	e.analysisView.SetText(output.String())
+func (e *Editor) displayAnalysis(plan *models.QueryPlan) {
+	e.planView.SetPlan(plan)
+	e.app.SetFocus(e.planView.tree)
 }
 
+// toggleMode cycles Execute -> Explain -> Explain Analyze. ANALYZE is its own
+// step because it runs the statement for real.
 func (e *Editor) toggleMode() {
-	if e.mode == modeExecute {
-		e.mode = modeAnalyze
-		e.bottomFlex.Clear()
-		e.bottomFlex.AddItem(e.analysisView, 0, 1, false)
-		e.analysisView.Clear()
-		e.analysisView.SetText(theme.ColorTag(theme.ColorForegroundMuted, false) + "Execute a query to see the query plan" + theme.ColorTagReset())
-		components.ShowInfo(e.pages, e.app, "Switched to Analyze mode")
-	} else {
+	switch e.mode {
+	case modeExecute:
+		e.mode = modeExplain
+		e.showPlanView("Execute a query to see the estimated plan")
+		components.ShowInfo(e.pages, e.app, "Switched to Explain mode (estimated plan, statement is not executed)")
+	case modeExplain:
+		e.mode = modeExplainAnalyze
+		e.showPlanView("Execute a query to see the plan with actual timings")
+		components.ShowInfo(e.pages, e.app, "Switched to Explain Analyze mode\n\nStatements are executed inside a transaction that is rolled back.")
+	default:
 		e.mode = modeExecute
 		e.bottomFlex.Clear()
 		e.bottomFlex.AddItem(e.resultsTable, 0, 1, false)
@@ -471,8 +596,16 @@ func (e *Editor) toggleMode() {
 	}
 }
 
+func (e *Editor) showPlanView(placeholder string) {
+	e.bottomFlex.Clear()
+	e.bottomFlex.AddItem(e.planView, 0, 1, false)
+	e.planView.SetTitle(" Query Plan ")
+	e.planView.SetMessage(placeholder)
+}
+
 func (e *Editor) Show() {
 	e.hideCompletion()
+	e.hideSignatureHelp()
 	e.pages.AddPage("editor", e.mainFlex, true, true)
 	e.pages.SwitchToPage("editor")
 	e.app.SetFocus(e.sqlInput)
@@ -0,0 +1,118 @@
+package editor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	querysafety "github.com/android-lewis/dbsmith/internal/editor"
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/android-lewis/dbsmith/internal/tui/theme"
+)
+
+// applyEnvironmentStyle colors the editor border with the connection's
+// environment color so production sessions are hard to mistake.
+func (e *Editor) applyEnvironmentStyle() {
+	color, ok := theme.ParseColor(e.dbApp.EnvironmentPolicy().Color)
+	if !ok || e.dbApp.Connection == nil || e.dbApp.Connection.Environment == "" {
+		return
+	}
+	e.sqlInput.SetBorderColor(color)
+	e.sqlInput.SetTitleColor(color)
+}
+
+// confirmation describes how a statement batch has to be confirmed before it
+// runs under the active environment policy.
+type confirmation struct {
+	required  bool
+	typedName string
+	reason    string
+}
+
+func (e *Editor) confirmationFor(analysis querysafety.QueryAnalysis) confirmation {
+	policy := e.dbApp.EnvironmentPolicy()
+	env := ""
+	connName := ""
+	if conn := e.dbApp.Connection; conn != nil {
+		env = string(conn.Environment)
+		connName = conn.Name
+	}
+
+	switch {
+	case policy.TypedConfirmDDL && analysis.HasDDL() && connName != "":
+		return confirmation{
+			required:  true,
+			typedName: connName,
+			reason:    fmt.Sprintf("Schema changes on %s connections must be confirmed by typing the connection name", env),
+		}
+	case policy.ConfirmWrites && !analysis.IsReadOnly():
+		return confirmation{
+			required: true,
+			reason:   fmt.Sprintf("Writes on %s connections must be confirmed", env),
+		}
+	case (e.dbApp.Config == nil || e.dbApp.Config.Editor.ConfirmDestructive) && analysis.NeedsConfirmation():
+		return confirmation{required: true}
+	default:
+		return confirmation{}
+	}
+}
+
+// checkRowCap refuses buffers the environment's row cap cannot be enforced
+// on: procedure calls, whose writes happen out of sight, and writes mixed
+// with other statements, whose results the capped transaction would drop.
+func (e *Editor) checkRowCap(analysis querysafety.QueryAnalysis) error {
+	policy := e.dbApp.EnvironmentPolicy()
+	if policy.MaxRowsAffected <= 0 {
+		return nil
+	}
+	env := ""
+	if conn := e.dbApp.Connection; conn != nil {
+		env = string(conn.Environment)
+	}
+
+	switch {
+	case analysis.CallsProcedure():
+		return fmt.Errorf("procedure calls cannot be run on %s connections, which limit writes to %d rows", env, policy.MaxRowsAffected)
+	case analysis.ModifiesRows() && !analysis.OnlyModifiesRows():
+		return fmt.Errorf("run writes on their own on %s connections, which limit writes to %d rows", env, policy.MaxRowsAffected)
+	}
+	return nil
+}
+
+// executeCappedWrite runs the statements in one transaction that is rolled
+// back when more than maxRows rows are affected.
+func (e *Editor) executeCappedWrite(ctx context.Context, analysis querysafety.QueryAnalysis, maxRows int64) bool {
+	queries := make([]string, 0, len(analysis.Statements))
+	for _, stmt := range analysis.Statements {
+		queries = append(queries, stmt.SQL)
+	}
+
+	startTime := time.Now()
+	affected, err := e.dbApp.Executor.ExecuteTransactionWithLimit(ctx, queries, maxRows)
+	duration := time.Since(startTime)
+
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			return true
+		}
+		e.app.QueueUpdateDraw(func() {
+			e.showQueryError("Query failed", err, e.runText)
+		})
+		return false
+	}
+
+	result := &models.QueryResult{
+		Columns:     []string{"rows_affected"},
+		Rows:        [][]interface{}{{affected}},
+		RowCount:    1,
+		ExecutionMs: duration.Milliseconds(),
+	}
+	e.lastResult = result
+
+	e.app.QueueUpdateDraw(func() {
+		e.queryStats.RecordQuery(duration, 0)
+		e.recordSavedQueryRun()
+		e.displayResults(result)
+	})
+	return false
+}
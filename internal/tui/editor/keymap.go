@@ -0,0 +1,90 @@
+package editor
+
+import (
+	"github.com/android-lewis/dbsmith/internal/keymap"
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/tui/components"
+	"github.com/gdamore/tcell/v2"
+)
+
+// keymapBuffer lets a keymap edit the SQL editor.
+type keymapBuffer struct {
+	input *components.SQLEditor
+}
+
+func (b keymapBuffer) Text() string {
+	return b.input.GetText()
+}
+
+func (b keymapBuffer) Cursor() int {
+	line, col := b.input.CursorPosition()
+	return b.input.OffsetAt(line, col)
+}
+
+func (b keymapBuffer) Replace(start, end int, text string) {
+	b.input.ReplaceRange(start, end, text)
+}
+
+func (b keymapBuffer) Select(start, end int) {
+	b.input.Select(start, end)
+}
+
+func (b keymapBuffer) Undo() {
+	b.input.Undo()
+}
+
+func (b keymapBuffer) Redo() {
+	b.input.Redo()
+}
+
+// newKeymap returns the keymap the editor config asks for. An unknown name
+// falls back to the default bindings.
+func (e *Editor) newKeymap() keymap.Keymap {
+	if e.dbApp.Config == nil {
+		return nil
+	}
+	km, err := keymap.New(e.dbApp.Config.Editor.Keymap)
+	if err != nil {
+		logging.Warn().Err(err).Msg("Using the default editor keymap")
+		return nil
+	}
+	return km
+}
+
+// handleKeymap passes event to the keymap and carries out what it asks
+// for. It reports whether the keymap consumed the event.
+func (e *Editor) handleKeymap(event *tcell.EventKey) bool {
+	if e.keymap == nil {
+		return false
+	}
+	mode := e.keymap.Mode()
+	result := e.keymap.HandleKey(keymapBuffer{input: e.sqlInput}, event)
+
+	switch result.Action {
+	case keymap.ActionSave:
+		e.savedQueriesManager.QuickSave(e.sqlInput)
+	case keymap.ActionFormat:
+		e.formatSQL()
+	}
+	if result.Handled && event.Key() == tcell.KeyEscape {
+		e.sqlInput.ExitSnippet()
+		e.hideSignatureHelp()
+	}
+	if e.keymap.Mode() != mode {
+		e.refreshTitle()
+	}
+	return result.Handled
+}
+
+// refreshTitle shows the dialect, the keymap mode and whether a query is
+// running in the editor title.
+func (e *Editor) refreshTitle() {
+	title := " SQL Editor [" + e.getDialectDisplayName() + "] "
+	if e.keymap != nil {
+		title += e.keymap.Mode() + " "
+	}
+	if e.isQueryRunning {
+		title += "Running - Press Esc to cancel "
+	}
+	e.sqlInput.SetTitle(title)
+}
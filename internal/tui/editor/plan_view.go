@@ -0,0 +1,207 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/explain"
+	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/android-lewis/dbsmith/internal/tui/theme"
+	"github.com/android-lewis/dbsmith/internal/tui/utils"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// planView renders a parsed query plan as a collapsible tree with a short
+// summary of the hotspots above it.
+type planView struct {
+	*tview.Flex
+	summary *tview.TextView
+	tree    *tview.TreeView
+}
+
+func newPlanView() *planView {
+	pv := &planView{
+		Flex: tview.NewFlex().SetDirection(tview.FlexRow),
+		summary: tview.NewTextView().
+			SetDynamicColors(true).
+			SetWordWrap(true),
+		tree: tview.NewTreeView().
+			SetGraphics(true).
+			SetGraphicsColor(theme.ThemeColors.Border),
+	}
+
+	pv.tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		node.SetExpanded(!node.IsExpanded())
+	})
+
+	pv.SetBorder(true).
+		SetTitle(" Query Plan ").
+		SetTitleAlign(tview.AlignLeft)
+
+	pv.AddItem(pv.summary, 3, 0, false).
+		AddItem(pv.tree, 0, 1, true)
+
+	return pv
+}
+
+// SetMessage replaces the plan with a muted placeholder message.
+func (pv *planView) SetMessage(msg string) {
+	pv.summary.SetText(theme.ColorTag(theme.ColorForegroundMuted, false) + msg + theme.ColorTagReset())
+	pv.tree.SetRoot(nil)
+}
+
+// SetPlan renders plan, expanding every node so hotspots are visible.
+func (pv *planView) SetPlan(plan *models.QueryPlan) {
+	if plan == nil || plan.Root == nil {
+		pv.SetMessage("The database returned an empty plan")
+		return
+	}
+
+	pv.summary.SetText(planSummary(plan))
+
+	root := buildPlanTreeNode(plan.Root, plan.Analyzed)
+	pv.tree.SetRoot(root).SetCurrentNode(root)
+
+	if plan.Analyzed {
+		pv.SetTitle(" Query Plan [EXPLAIN ANALYZE] ")
+	} else {
+		pv.SetTitle(" Query Plan [estimated] ")
+	}
+}
+
+func planSummary(plan *models.QueryPlan) string {
+	var b strings.Builder
+
+	hotspots := explain.Hotspots(plan)
+	var expensive, mismatches, scans int
+	for _, n := range hotspots {
+		if n.HasIssue(models.PlanIssueExpensive) {
+			expensive++
+		}
+		if n.HasIssue(models.PlanIssueRowMismatch) {
+			mismatches++
+		}
+		if n.HasIssue(models.PlanIssueSeqScan) {
+			scans++
+		}
+	}
+
+	if plan.Analyzed {
+		b.WriteString(fmt.Sprintf("Planning %.2fms  Execution %.2fms  ", plan.PlanningMs, plan.ExecutionMs))
+		b.WriteString(theme.ColorTag(theme.ColorForegroundMuted, false))
+		b.WriteString("(statement rolled back)")
+		b.WriteString(theme.ColorTagReset())
+	} else if plan.Root.HasCost {
+		b.WriteString(fmt.Sprintf("Estimated cost %.2f  rows %s", plan.Root.TotalCost, formatRows(plan.Root.EstimatedRows)))
+	}
+	b.WriteString("\n")
+
+	b.WriteString(issueCount(theme.ColorError, "expensive", expensive))
+	b.WriteString("  ")
+	b.WriteString(issueCount(theme.ColorWarning, "row estimate mismatch", mismatches))
+	b.WriteString("  ")
+	b.WriteString(issueCount(theme.ColorAccent, "sequential scan", scans))
+	b.WriteString("\n")
+	b.WriteString(theme.ColorTag(theme.ColorForegroundMuted, false))
+	b.WriteString("Enter collapses/expands a node")
+	b.WriteString(theme.ColorTagReset())
+
+	return b.String()
+}
+
+func issueCount(color theme.ColorName, label string, count int) string {
+	if count == 0 {
+		return theme.ColorTag(theme.ColorForegroundMuted, false) + "0 " + label + theme.ColorTagReset()
+	}
+	return theme.ColorTag(color, true) + fmt.Sprintf("%d %s", count, label) + theme.ColorTagReset()
+}
+
+func buildPlanTreeNode(n *models.PlanNode, analyzed bool) *tview.TreeNode {
+	node := tview.NewTreeNode(planNodeText(n, analyzed)).
+		SetReference(n).
+		SetSelectable(true).
+		SetExpanded(true)
+
+	switch {
+	case n.HasIssue(models.PlanIssueExpensive):
+		node.SetColor(theme.ThemeColors.Error)
+	case n.HasIssue(models.PlanIssueRowMismatch):
+		node.SetColor(theme.ThemeColors.Warning)
+	case n.HasIssue(models.PlanIssueSeqScan):
+		node.SetColor(theme.ThemeColors.Accent)
+	default:
+		node.SetColor(theme.ThemeColors.Foreground)
+	}
+	node.SetSelectedTextStyle(tcell.StyleDefault.
+		Background(theme.ThemeColors.Selection).
+		Foreground(theme.ThemeColors.SelectionText))
+
+	for _, child := range n.Children {
+		node.AddChild(buildPlanTreeNode(child, analyzed))
+	}
+
+	if n.Detail != "" {
+		detail := tview.NewTreeNode(tview.Escape(n.Detail)).
+			SetColor(theme.ThemeColors.ForegroundMuted).
+			SetSelectable(false)
+		node.AddChild(detail)
+	}
+
+	return node
+}
+
+func planNodeText(n *models.PlanNode, analyzed bool) string {
+	var b strings.Builder
+	b.WriteString(n.Operation)
+
+	if n.Relation != "" {
+		b.WriteString(" on ")
+		b.WriteString(n.Relation)
+		if n.Alias != "" && n.Alias != n.Relation {
+			b.WriteString(" ")
+			b.WriteString(n.Alias)
+		}
+	}
+	if n.Index != "" {
+		b.WriteString(" using ")
+		b.WriteString(n.Index)
+	}
+
+	if n.HasCost {
+		b.WriteString(fmt.Sprintf("  cost=%.2f", n.TotalCost))
+	}
+	if n.HasActual {
+		b.WriteString(fmt.Sprintf("  rows=%s→%s", formatRows(n.EstimatedRows), formatRows(n.ActualRows)))
+		b.WriteString(fmt.Sprintf("  time=%.2fms", n.ActualTimeMs))
+		if n.Loops > 1 {
+			b.WriteString(fmt.Sprintf("  loops=%d", n.Loops))
+		}
+	} else if n.EstimatedRows > 0 {
+		b.WriteString("  rows=" + formatRows(n.EstimatedRows))
+	}
+
+	if n.CostShare >= 0.01 && (n.HasCost || analyzed) {
+		b.WriteString(fmt.Sprintf("  %.0f%%", n.CostShare*100))
+	}
+
+	var flags []string
+	if n.HasIssue(models.PlanIssueExpensive) {
+		flags = append(flags, "hot")
+	}
+	if n.HasIssue(models.PlanIssueRowMismatch) {
+		flags = append(flags, "misestimate")
+	}
+	if n.HasIssue(models.PlanIssueSeqScan) {
+		flags = append(flags, "seq scan")
+	}
+	if len(flags) > 0 {
+		b.WriteString("  (" + strings.Join(flags, ", ") + ")")
+	}
+
+	return tview.Escape(b.String())
+}
+
+func formatRows(rows float64) string {
+	return utils.FormatNumber(int64(rows + 0.5))
+}
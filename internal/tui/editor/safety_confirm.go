@@ -0,0 +1,131 @@
+package editor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	querysafety "github.com/android-lewis/dbsmith/internal/editor"
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/tui/components"
+	"github.com/android-lewis/dbsmith/internal/tui/constants"
+	"github.com/android-lewis/dbsmith/internal/tui/utils"
+)
+
+const confirmPreviewWidth = 60
+
+// confirmStatements estimates the rows each statement would touch and then
+// asks for confirmation with a per-statement breakdown.
+func (e *Editor) confirmStatements(sql string, analysis querysafety.QueryAnalysis, c confirmation) {
+	go func() {
+		estimates := e.estimateAffectedRows(analysis)
+		e.app.QueueUpdateDraw(func() {
+			message := confirmationMessage(analysis, estimates, c.reason)
+			onDone := func(confirmed bool) {
+				if confirmed {
+					e.prepareForQueryExecution()
+					go e.runQuery(sql)
+				}
+			}
+			if c.typedName != "" {
+				components.ShowTypedConfirm(e.pages, e.app, message, c.typedName, onDone)
+				return
+			}
+			components.ShowConfirm(e.pages, e.app, message, onDone)
+		})
+	}()
+}
+
+// estimateAffectedRows runs the dry-run count queries for UPDATE and DELETE
+// statements in a read-only transaction that is rolled back, so a count query
+// built from a WHERE clause with side effects cannot write.
+// Failures are logged and leave the statement without an estimate.
+func (e *Editor) estimateAffectedRows(analysis querysafety.QueryAnalysis) map[int]int64 {
+	estimates := make(map[int]int64)
+	if e.dbApp.Executor == nil || !e.dbApp.Executor.IsConnected() {
+		return estimates
+	}
+
+	for i, stmt := range analysis.Statements {
+		if stmt.DryRunSQL == "" {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), constants.TimeoutDryRun)
+		result, err := e.dbApp.Executor.ExecuteReadOnlyQuery(ctx, stmt.DryRunSQL)
+		cancel()
+		if err != nil {
+			logging.Debug().Err(err).Str("sql", stmt.DryRunSQL).Msg("Dry-run row count failed")
+			continue
+		}
+		if result == nil || len(result.Rows) == 0 || len(result.Rows[0]) == 0 {
+			continue
+		}
+		if n, ok := countValue(result.Rows[0][0]); ok {
+			estimates[i] = n
+		}
+	}
+
+	return estimates
+}
+
+func countValue(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	case []byte:
+		parsed, err := strconv.ParseInt(string(n), 10, 64)
+		return parsed, err == nil
+	case string:
+		parsed, err := strconv.ParseInt(n, 10, 64)
+		return parsed, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func confirmationMessage(analysis querysafety.QueryAnalysis, estimates map[int]int64, reason string) string {
+	var b strings.Builder
+
+	flagged := 0
+	for _, stmt := range analysis.Statements {
+		if stmt.NeedsConfirmation() {
+			flagged++
+		}
+	}
+	if len(analysis.Statements) == 1 {
+		stmt := analysis.Statements[0]
+		b.WriteString(fmt.Sprintf("%s Query Warning\n", stmt.Kind))
+		if reason != "" {
+			b.WriteString("\n" + reason)
+		}
+		if stmt.Warning != "" {
+			b.WriteString("\n" + stmt.Warning)
+		}
+		if n, ok := estimates[0]; ok {
+			b.WriteString(fmt.Sprintf("\nEstimated rows affected: %s", utils.FormatNumber(n)))
+		}
+	} else {
+		if reason != "" {
+			b.WriteString(reason + "\n")
+		}
+		b.WriteString(fmt.Sprintf("%d of %d statements flagged\n", flagged, len(analysis.Statements)))
+		for i, stmt := range analysis.Statements {
+			b.WriteString(fmt.Sprintf("\n%d. [%s] %s", i+1, stmt.Class, stmt.Preview(confirmPreviewWidth)))
+			if stmt.Warning != "" {
+				b.WriteString("\n   " + stmt.Warning)
+			}
+			if n, ok := estimates[i]; ok {
+				b.WriteString(fmt.Sprintf("\n   ~%s rows affected", utils.FormatNumber(n)))
+			}
+		}
+	}
+
+	b.WriteString("\n\nAre you sure you want to execute this query?")
+	return b.String()
+}
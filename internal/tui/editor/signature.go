@@ -0,0 +1,216 @@
+package editor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/autocomplete"
+	"github.com/android-lewis/dbsmith/internal/explorer"
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/tui/constants"
+	"github.com/android-lewis/dbsmith/internal/tui/theme"
+	"github.com/rivo/tview"
+)
+
+const signaturePageName = "editor_signature_popup"
+
+const completionFunctionsTTL = 2 * time.Minute
+
+// signatureOverlay shows the signature of the call around the cursor in the
+// top right corner, with the current argument highlighted.
+type signatureOverlay struct {
+	pages   *tview.Pages
+	view    *tview.TextView
+	grid    *tview.Grid
+	visible bool
+}
+
+func newSignatureOverlay(pages *tview.Pages) *signatureOverlay {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignLeft)
+	view.SetBorder(true).
+		SetTitle(" Signature ")
+	view.SetBackgroundColor(theme.ThemeColors.BackgroundAlt)
+	view.SetTextColor(theme.ThemeColors.Foreground)
+
+	return &signatureOverlay{
+		pages: pages,
+		view:  view,
+		grid:  tview.NewGrid(),
+	}
+}
+
+// Show renders help and reports whether the popup was newly added, which
+// moves focus to it.
+func (s *signatureOverlay) Show(help autocomplete.SignatureHelp) bool {
+	text, width := formatSignature(help)
+	s.view.SetText(text)
+
+	s.grid.Clear()
+	s.grid.SetRows(1, 3, 0)
+	s.grid.SetColumns(0, width+4, 2)
+	s.grid.AddItem(s.view, 1, 1, 1, 1, 0, 0, false)
+
+	if s.visible {
+		return false
+	}
+	s.visible = true
+	s.pages.RemovePage(signaturePageName)
+	s.pages.AddPage(signaturePageName, s.grid, true, true)
+	return true
+}
+
+func (s *signatureOverlay) Hide() {
+	if !s.visible {
+		return
+	}
+	s.visible = false
+	s.pages.RemovePage(signaturePageName)
+}
+
+// formatSignature returns the tagged signature text and its visible width.
+func formatSignature(help autocomplete.SignatureHelp) (string, int) {
+	f := help.Function
+	muted := fmt.Sprintf("[#%06x]", theme.ThemeColors.ForegroundMuted.Hex())
+	active := fmt.Sprintf("[#%06x::b]", theme.ThemeColors.Accent.Hex())
+
+	var text, plain strings.Builder
+	text.WriteString(tview.Escape(f.Name) + "(")
+	plain.WriteString(f.Name + "(")
+	for i, param := range f.Params {
+		if i > 0 {
+			text.WriteString(", ")
+			plain.WriteString(", ")
+		}
+		if f.Variadic && i == len(f.Params)-1 {
+			param += "..."
+		}
+		if i == help.ActiveParam {
+			text.WriteString(active + tview.Escape(param) + "[-::-]")
+		} else {
+			text.WriteString(tview.Escape(param))
+		}
+		plain.WriteString(param)
+	}
+	text.WriteString(")")
+	plain.WriteString(")")
+
+	if f.Returns != "" {
+		text.WriteString(" " + muted + "→ " + tview.Escape(f.Returns) + "[-]")
+		plain.WriteString(" → " + f.Returns)
+	}
+	if n := len(help.Overloads); n > 1 {
+		note := fmt.Sprintf(" +%d overloads", n-1)
+		text.WriteString(muted + note + "[-]")
+		plain.WriteString(note)
+	}
+	return text.String(), len([]rune(plain.String()))
+}
+
+// updateSignatureHelp shows or hides the signature popup for the cursor
+// position. It only uses cached user-defined functions and refreshes them in
+// the background.
+func (e *Editor) updateSignatureHelp() {
+	if e.signatureOverlay == nil {
+		return
+	}
+	if e.dbApp != nil && e.dbApp.Workspace != nil && !e.dbApp.Workspace.GetAutocompleteEnabled() {
+		e.signatureOverlay.Hide()
+		return
+	}
+
+	line, col := e.sqlInput.CursorPosition()
+	help, ok, err := autocomplete.SignatureAt(autocomplete.Request{
+		SQL:      e.sqlInput.GetText(),
+		Position: autocomplete.Position{Line: line, Column: col},
+		Dialect:  e.sqlInput.GetDialect(),
+		Context:  autocomplete.Context{Functions: e.cachedCompletionFunctions()},
+	})
+	if err != nil || !ok {
+		e.signatureOverlay.Hide()
+		return
+	}
+
+	if e.signatureOverlay.Show(help) {
+		e.app.SetFocus(e.sqlInput)
+	}
+}
+
+func (e *Editor) hideSignatureHelp() {
+	if e.signatureOverlay != nil {
+		e.signatureOverlay.Hide()
+	}
+}
+
+// cachedCompletionFunctions returns the cached user-defined functions and
+// starts a background refresh when they are stale.
+func (e *Editor) cachedCompletionFunctions() []autocomplete.Function {
+	if e.dbApp == nil || e.dbApp.Explorer == nil || e.completionCache.functionsLoading ||
+		time.Since(e.completionCache.functionsLoaded) < completionFunctionsTTL {
+		return e.completionCache.functions
+	}
+
+	e.completionCache.functionsLoading = true
+	exp := e.dbApp.Explorer
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), constants.TimeoutAutocomplete)
+		defer cancel()
+
+		functions, err := fetchCompletionFunctions(ctx, exp)
+		e.app.QueueUpdate(func() {
+			e.completionCache.functionsLoading = false
+			e.storeCompletionFunctions(functions, err)
+		})
+	}()
+	return e.completionCache.functions
+}
+
+// loadCompletionFunctions returns the user-defined functions, loading them
+// when the cache is stale.
+func (e *Editor) loadCompletionFunctions(ctx context.Context) []autocomplete.Function {
+	if time.Since(e.completionCache.functionsLoaded) < completionFunctionsTTL {
+		return e.completionCache.functions
+	}
+	functions, err := fetchCompletionFunctions(ctx, e.dbApp.Explorer)
+	e.storeCompletionFunctions(functions, err)
+	return e.completionCache.functions
+}
+
+// storeCompletionFunctions caches a load result. A failed load is cached as
+// well, keeping the previous functions, so it is not retried on every key.
+func (e *Editor) storeCompletionFunctions(functions []autocomplete.Function, err error) {
+	e.completionCache.functionsLoaded = time.Now()
+	if err != nil {
+		logging.Warn().Err(err).Msg("Failed to load functions for autocomplete")
+		return
+	}
+	e.completionCache.functions = functions
+}
+
+func fetchCompletionFunctions(ctx context.Context, exp *explorer.Explorer) ([]autocomplete.Function, error) {
+	functions, err := exp.GetFunctions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]autocomplete.Function, 0, len(functions))
+	for _, fn := range functions {
+		params := autocomplete.SplitArguments(fn.Arguments)
+		variadic := false
+		if n := len(params); n > 0 && strings.HasPrefix(strings.ToUpper(params[n-1]), "VARIADIC ") {
+			params[n-1] = strings.TrimSpace(params[n-1][len("VARIADIC "):])
+			variadic = true
+		}
+		result = append(result, autocomplete.Function{
+			Name:     fn.Name,
+			Schema:   fn.Schema,
+			Params:   params,
+			Returns:  fn.ReturnType,
+			Variadic: variadic,
+		})
+	}
+	return result, nil
+}
@@ -241,6 +241,10 @@ func (et *EditorTabs) renderTabBar() {
 
 func (et *EditorTabs) showActiveEditorContent() {
 	et.contentFlex.Clear()
+	for _, tab := range et.tabs {
+		tab.editor.hideCompletion()
+		tab.editor.hideSignatureHelp()
+	}
 
 	if len(et.tabs) == 0 {
 		return
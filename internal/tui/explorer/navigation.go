@@ -40,6 +40,9 @@ func (e *Explorer) setupKeybindings() {
 			case 'd', 'D':
 				e.toggleDataPreview()
 				return nil
+			case 'r', 'R':
+				e.refreshMetadata()
+				return nil
 			}
 		}
 		return event
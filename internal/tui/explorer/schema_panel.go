@@ -30,7 +30,7 @@ func (e *Explorer) buildSchemasList() *tview.List {
 func (e *Explorer) loadSchemas() {
 	e.schemasList.Clear()
 
-	if e.dbApp.Explorer == nil {
+	if e.dbApp.Metadata == nil {
 		e.schemasList.AddItem("No connection", "", 0, nil)
 		return
 	}
@@ -41,7 +41,7 @@ func (e *Explorer) loadSchemas() {
 		ctx, cancel := context.WithTimeout(context.Background(), constants.TimeoutSchemaLoad)
 		defer cancel()
 
-		schemas, err := e.dbApp.Explorer.GetSchemas(ctx)
+		schemas, err := e.dbApp.Metadata.Schemas(ctx)
 		if err != nil {
 			e.app.QueueUpdateDraw(func() {
 				e.schemasList.Clear()
@@ -75,6 +75,39 @@ func (e *Explorer) loadSchemas() {
 	}()
 }
 
+// refreshMetadata fetches the selected schema again, or every schema when
+// none is selected, and reloads the panels from the refreshed cache.
+func (e *Explorer) refreshMetadata() {
+	if e.dbApp.Metadata == nil {
+		return
+	}
+
+	schema := e.selectedSchema
+	if !e.showSchemas {
+		schema = ""
+	}
+	e.statusBar.SetLoading("Refreshing metadata...")
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), constants.TimeoutMetadataRefresh)
+		defer cancel()
+
+		err := e.dbApp.Metadata.Refresh(ctx, schema)
+		e.app.QueueUpdateDraw(func() {
+			e.statusBar.SetIdle()
+			if err != nil {
+				components.ShowError(e.pages, e.app, fmt.Errorf("failed to refresh metadata: %w", err))
+				return
+			}
+			if schema == "" {
+				e.loadSchemas()
+			} else {
+				e.loadTablesForSchema(schema)
+			}
+		})
+	}()
+}
+
 // toggleSchemas shows or hides the schemas panel
 func (e *Explorer) toggleSchemas() {
 	e.showSchemas = !e.showSchemas
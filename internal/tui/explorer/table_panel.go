@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/android-lewis/dbsmith/internal/models"
 	"github.com/android-lewis/dbsmith/internal/tui/components"
 	"github.com/android-lewis/dbsmith/internal/tui/constants"
 	"github.com/android-lewis/dbsmith/internal/tui/theme"
@@ -32,7 +31,7 @@ func (e *Explorer) loadTablesForSchema(schemaName string) {
 	e.selectedSchema = schemaName
 	e.tablesList.Clear()
 
-	if e.dbApp.Explorer == nil {
+	if e.dbApp.Metadata == nil {
 		e.tablesList.AddItem("No connection", "", 0, nil)
 		return
 	}
@@ -43,8 +42,7 @@ func (e *Explorer) loadTablesForSchema(schemaName string) {
 		ctx, cancel := context.WithTimeout(context.Background(), constants.TimeoutSchemaLoad)
 		defer cancel()
 
-		schema := models.Schema{Name: schemaName}
-		tables, err := e.dbApp.Explorer.GetTables(ctx, schema)
+		tables, err := e.dbApp.Metadata.Tables(ctx, schemaName)
 		if err != nil {
 			e.app.QueueUpdateDraw(func() {
 				e.tablesList.Clear()
@@ -1,6 +1,10 @@
 package theme
 
-import "github.com/gdamore/tcell/v2"
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
 
 var ThemeColors = struct {
 	Background      tcell.Color
@@ -92,3 +96,13 @@ func GetColor(name ColorName) tcell.Color {
 		return ThemeColors.Foreground
 	}
 }
+
+// ParseColor resolves a color name or #rrggbb value from the config. It
+// reports false for empty or unknown values.
+func ParseColor(value string) (tcell.Color, bool) {
+	if value == "" {
+		return tcell.ColorDefault, false
+	}
+	color := tcell.GetColor(strings.ToLower(value))
+	return color, color != tcell.ColorDefault
+}
@@ -2,14 +2,16 @@ package workspace
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"sort"
 
 	"github.com/android-lewis/dbsmith/internal/app"
 	"github.com/android-lewis/dbsmith/internal/db"
+	"github.com/android-lewis/dbsmith/internal/importer"
 	"github.com/android-lewis/dbsmith/internal/logging"
 	"github.com/android-lewis/dbsmith/internal/models"
+	"github.com/android-lewis/dbsmith/internal/secrets"
 	"github.com/android-lewis/dbsmith/internal/tui/components"
 	"github.com/android-lewis/dbsmith/internal/tui/constants"
 	"github.com/android-lewis/dbsmith/internal/tui/theme"
@@ -31,8 +33,8 @@ type Workspace struct {
 	testingConn     bool
 	loadingOverlay  *components.LoadingOverlay
 	connectionForm  *components.ConnectionFormManager
+	saveConflict    error
 
-	workspacePath        string
 	onConnectionSelected func()
 }
 
@@ -49,10 +51,6 @@ func NewWorkspace(app *tview.Application, pages *tview.Pages, dbApp *app.App, he
 	w.configureConnectionFormCallbacks()
 	w.loadingOverlay = components.NewLoadingOverlay()
 
-	if w.dbApp.Workspace.GetFilePath() != "" {
-		w.workspacePath = w.dbApp.Workspace.GetFilePath()
-	}
-
 	w.buildUI()
 	return w
 }
@@ -103,6 +101,15 @@ func (w *Workspace) setupKeybindings() {
 				w.testConnection(conn)
 			}
 			return nil
+		case 'i', 'I':
+			w.showImportDialog()
+			return nil
+		case 'p', 'P':
+			w.showMasterPasswordForm()
+			return nil
+		case 'w', 'W':
+			w.showWorkspaceSelector()
+			return nil
 		}
 		return event
 	})
@@ -122,6 +129,7 @@ func (w *Workspace) loadConnections() {
 		return
 	}
 
+	w.connectionsList.SetTitle(fmt.Sprintf(" Connections [%s] ", w.dbApp.Workspace.GetName()))
 	w.connections = w.dbApp.Workspace.ListConnections()
 
 	if len(w.connections) == 0 {
@@ -174,7 +182,14 @@ func (w *Workspace) formatConnectionItem(conn models.Connection) (mainText, seco
 	if conn.Type == models.SQLiteType {
 		secondaryText = fmt.Sprintf("%s  %s", conn.Type, conn.Database)
 	} else {
-		secondaryText = fmt.Sprintf("%s  %s:%d/%s  user:%s", conn.Type, conn.Host, conn.Port, conn.Database, conn.Username)
+		secondaryText = fmt.Sprintf("%s  %s:%s/%s  user:%s", conn.Type, conn.Host, conn.PortString(), conn.Database, conn.Username)
+	}
+
+	switch w.dbApp.Workspace.ConnectionOrigin(conn.Name) {
+	case wsmgr.OriginShared:
+		secondaryText += "  [shared]"
+	case wsmgr.OriginOverride:
+		secondaryText += "  [shared, edited locally]"
 	}
 
 	return mainText, secondaryText
@@ -205,6 +220,10 @@ func (w *Workspace) testConnection(conn *models.Connection) {
 		if err != nil {
 			w.app.QueueUpdateDraw(func() {
 				w.testingConn = false
+				if errors.Is(err, secrets.ErrSecretsLocked) {
+					w.unlockThen(func() { w.testConnection(conn) })
+					return
+				}
 				components.ShowError(
 					w.pages,
 					w.app,
@@ -227,92 +246,131 @@ func (w *Workspace) testConnection(conn *models.Connection) {
 	}()
 }
 
+// showWorkspaceSelector lets the user reopen a recent workspace or create or
+// open one by path.
 func (w *Workspace) showWorkspaceSelector() {
-	var form *tview.Form
+	const pageName = "workspace-selector"
 
-	form = tview.NewForm().
-		AddInputField("Workspace File", "", 50, nil, nil).
-		AddButton("Create New", func() {
-			input := form.GetFormItem(0).(*tview.InputField)
-			path := input.GetText()
-			if path == "" {
-				components.ShowError(w.pages, w.app, fmt.Errorf("workspace file path is required"))
-				return
-			}
-			w.createWorkspace(path)
-		}).
-		AddButton("Load Existing", func() {
-			input := form.GetFormItem(0).(*tview.InputField)
-			path := input.GetText()
+	closeSelector := func() {
+		if w.dbApp.Workspace == nil {
+			w.app.Stop()
+			return
+		}
+		w.pages.RemovePage(pageName)
+		w.app.SetFocus(w.connectionsList)
+	}
+
+	recent := tview.NewList().
+		ShowSecondaryText(true).
+		SetHighlightFullLine(true).
+		SetMainTextColor(theme.ThemeColors.Primary).
+		SetSecondaryTextColor(theme.ThemeColors.ForegroundMuted).
+		SetSelectedTextColor(theme.ThemeColors.Foreground).
+		SetSelectedBackgroundColor(theme.ThemeColors.Selection)
+	recent.SetBorder(true).
+		SetTitle(" Recent Workspaces ").
+		SetTitleAlign(tview.AlignLeft)
+
+	current := ""
+	if w.dbApp.Workspace != nil {
+		current = w.dbApp.Workspace.GetFilePath()
+	}
+	for _, entry := range w.dbApp.RecentWorkspaces() {
+		e := entry
+		name := e.Name
+		if e.Path == current {
+			name = fmt.Sprintf("%s %s", theme.Icons.Check, name)
+		}
+		recent.AddItem(name, e.Path, 0, func() {
+			w.switchWorkspace(e.Path)
+		})
+	}
+	if recent.GetItemCount() == 0 {
+		recent.AddItem("No recent workspaces", "Enter a path below to create one", 0, nil)
+	}
+
+	pathInput := tview.NewInputField().
+		SetLabel("Workspace File ").
+		SetFieldWidth(60).
+		SetPlaceholder("~/work/acme.yaml")
+
+	form := tview.NewForm().
+		AddFormItem(pathInput).
+		AddButton("Open", func() {
+			path := pathInput.GetText()
 			if path == "" {
 				components.ShowError(w.pages, w.app, fmt.Errorf("workspace file path is required"))
 				return
 			}
-			w.loadWorkspace(path)
+			w.switchWorkspace(path)
 		}).
-		AddButton("Cancel", func() {
-			w.app.Stop()
-		})
-
+		AddButton("Cancel", closeSelector)
 	form.SetBorder(true).
-		SetTitle(" Workspace Manager ").
+		SetTitle(" Open or Create ").
+		SetTitleAlign(tview.AlignLeft)
+
+	layout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(recent, 0, 1, true).
+		AddItem(form, 7, 0, false)
+	layout.SetBorder(true).
+		SetTitle(" Workspace Manager (Tab to switch, Esc to close) ").
 		SetTitleAlign(tview.AlignCenter)
 
+	recent.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEsc:
+			closeSelector()
+			return nil
+		case tcell.KeyTab:
+			w.app.SetFocus(form)
+			return nil
+		}
+		return event
+	})
 	form.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyEsc {
-			w.app.Stop()
+			closeSelector()
+			return nil
+		}
+		if event.Key() == tcell.KeyBacktab && pathInput.HasFocus() {
+			w.app.SetFocus(recent)
+			return nil
 		}
 		return event
 	})
 
-	w.pages.AddPage("workspace-selector", form, true, true)
-}
-
-func (w *Workspace) createWorkspace(path string) {
-	absPath, err := filepath.Abs(path)
-	if err != nil {
-		components.ShowError(w.pages, w.app, err)
-		return
-	}
-
-	dir := filepath.Dir(absPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		components.ShowError(w.pages, w.app, fmt.Errorf("failed to create directory: %w", err))
-		return
-	}
-
-	ws := wsmgr.New()
-	ws.SetName("Default Workspace")
-
-	if err := ws.Save(absPath); err != nil {
-		components.ShowError(w.pages, w.app, err)
-		return
-	}
-
-	w.dbApp.Workspace = ws
-	w.workspacePath = absPath
-	w.pages.RemovePage("workspace-selector")
-	w.loadConnections()
-	components.ShowInfo(w.pages, w.app, fmt.Sprintf("Workspace created: %s", absPath))
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(layout, 22, 0, true).
+			AddItem(nil, 0, 1, false), 90, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	w.pages.AddPage(pageName, modal, true, true)
+	w.app.SetFocus(recent)
 }
 
-func (w *Workspace) loadWorkspace(path string) {
-	absPath, err := filepath.Abs(path)
-	if err != nil {
+// switchWorkspace opens the workspace at path, creating it if needed. The
+// current connection belongs to the old workspace and is closed first.
+func (w *Workspace) switchWorkspace(path string) {
+	if err := w.dbApp.Disconnect(); err != nil {
 		components.ShowError(w.pages, w.app, err)
 		return
 	}
 
-	ws, err := wsmgr.Load(absPath)
-	if err != nil {
+	if err := w.dbApp.OpenWorkspace(path); err != nil {
 		components.ShowError(w.pages, w.app, err)
 		return
 	}
 
-	w.dbApp.Workspace = ws
-	w.workspacePath = absPath
 	w.pages.RemovePage("workspace-selector")
+	w.statusBar.Update()
 	w.loadConnections()
+	w.app.SetFocus(w.connectionsList)
+	components.ShowInfo(w.pages, w.app, fmt.Sprintf("Workspace opened: %s", w.dbApp.Workspace.GetName()))
 }
 
 func (w *Workspace) configureConnectionFormCallbacks() {
@@ -335,18 +393,24 @@ func (w *Workspace) configureConnectionFormCallbacks() {
 				return err
 			}
 
-			if w.workspacePath == "" {
-				return fmt.Errorf("workspace file path not set")
-			}
-
-			if err := w.dbApp.Workspace.Save(w.workspacePath); err != nil {
-				return err
+			if err := w.dbApp.SaveWorkspace(); err != nil {
+				// The form closes either way; the conflict prompt replaces
+				// the success message once it has.
+				if !errors.Is(err, wsmgr.ErrWorkspaceConflict) {
+					return err
+				}
+				w.saveConflict = err
 			}
 
 			w.loadConnections()
 			return nil
 		},
 		func(message string) {
+			if err := w.saveConflict; err != nil {
+				w.saveConflict = nil
+				w.showSaveError(err)
+				return
+			}
 			components.ShowInfo(w.pages, w.app, message)
 		},
 	)
@@ -363,8 +427,8 @@ func (w *Workspace) confirmDelete(name string) {
 			return
 		}
 
-		if err := w.dbApp.Workspace.Save(w.workspacePath); err != nil {
-			components.ShowError(w.pages, w.app, err)
+		if err := w.dbApp.SaveWorkspace(); err != nil {
+			w.showSaveError(err)
 			return
 		}
 
@@ -373,6 +437,89 @@ func (w *Workspace) confirmDelete(name string) {
 	})
 }
 
+// showImportDialog offers the connections found in .pgpass, pg_service.conf,
+// .my.cnf and DBeaver for import into the current workspace.
+func (w *Workspace) showImportDialog() {
+	candidates, errs := importer.Discover()
+	for _, err := range errs {
+		logging.Warn().Err(err).Msg("Failed to read connections for import")
+	}
+	if len(candidates) == 0 {
+		message := "No connections found in .pgpass, pg_service.conf, .my.cnf or DBeaver"
+		if len(errs) > 0 {
+			components.ShowError(w.pages, w.app, fmt.Errorf("%s: %w", message, errors.Join(errs...)))
+		} else {
+			components.ShowInfo(w.pages, w.app, message)
+		}
+		return
+	}
+
+	exists := func(name string) bool {
+		return w.dbApp.Workspace.GetConnectionByName(name) != nil
+	}
+	components.ShowConnectionImport(w.pages, w.app, candidates, exists, func(chosen []importer.Candidate) {
+		w.app.SetFocus(w.connectionsList)
+		if chosen == nil {
+			return
+		}
+
+		result := importer.Import(w.dbApp.Workspace, w.dbApp.SecretsManager, chosen)
+		w.loadConnections()
+
+		message := fmt.Sprintf("Imported %d connection(s)", len(result.Imported))
+		if len(result.Skipped) > 0 {
+			message += fmt.Sprintf(", skipped %d that already exist", len(result.Skipped))
+		}
+		if len(result.Failed) > 0 {
+			names := make([]string, 0, len(result.Failed))
+			for name := range result.Failed {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			failures := make([]error, 0, len(names))
+			for _, name := range names {
+				failures = append(failures, fmt.Errorf("%s: %w", name, result.Failed[name]))
+			}
+			components.ShowError(w.pages, w.app, fmt.Errorf("%s; %d failed:\n%w", message, len(failures), errors.Join(failures...)))
+			return
+		}
+		components.ShowInfo(w.pages, w.app, message)
+	})
+}
+
+// unlockThen asks for the master password and runs retry once the secrets
+// are unlocked.
+func (w *Workspace) unlockThen(retry func()) {
+	lockable, ok := w.dbApp.SecretsManager.(secrets.Lockable)
+	if !ok {
+		return
+	}
+	components.ShowUnlockPrompt(w.pages, w.app, lockable, func(unlocked bool) {
+		w.app.SetFocus(w.connectionsList)
+		if unlocked {
+			retry()
+		}
+	})
+}
+
+func (w *Workspace) showMasterPasswordForm() {
+	fileSecrets, ok := secrets.FileManager(w.dbApp.SecretsManager)
+	if !ok {
+		components.ShowInfo(w.pages, w.app, "Passwords are stored in the system keyring, which has its own protection.")
+		return
+	}
+	components.ShowMasterPasswordForm(w.pages, w.app, fileSecrets, func() {
+		w.app.SetFocus(w.connectionsList)
+	})
+}
+
+func (w *Workspace) showSaveError(err error) {
+	components.ShowWorkspaceSaveError(w.pages, w.app, w.dbApp.Workspace, err, func() {
+		w.loadConnections()
+		w.app.SetFocus(w.connectionsList)
+	})
+}
+
 func (w *Workspace) SetConnectionSelectedCallback(callback func()) {
 	w.onConnectionSelected = callback
 }
@@ -385,6 +532,10 @@ func (w *Workspace) selectConnection(conn *models.Connection) {
 		if err := w.dbApp.ConnectToDatabase(conn); err != nil {
 			w.app.QueueUpdateDraw(func() {
 				w.pages.RemovePage("loading")
+				if errors.Is(err, secrets.ErrSecretsLocked) {
+					w.unlockThen(func() { w.selectConnection(conn) })
+					return
+				}
 				components.ShowError(w.pages, w.app, fmt.Errorf("failed to connect: %w", err))
 			})
 			return
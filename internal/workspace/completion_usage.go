@@ -0,0 +1,141 @@
+package workspace
+
+import "github.com/android-lewis/dbsmith/internal/models"
+
+// completionUsageLimit caps the completions remembered per connection; the
+// least used are forgotten first.
+const completionUsageLimit = 500
+
+// RecordCompletion counts an accepted completion on connectionName. The
+// count is held in memory until FlushCompletionUsage or the next save writes
+// it, so accepting a completion never waits on the workspace file.
+func (m *Manager) RecordCompletion(connectionName, key string) {
+	if connectionName == "" || key == "" {
+		return
+	}
+
+	m.usageMu.Lock()
+	defer m.usageMu.Unlock()
+	if m.pendingUsage == nil {
+		m.pendingUsage = make(map[string]map[string]int)
+	}
+	counts := m.pendingUsage[connectionName]
+	if counts == nil {
+		counts = make(map[string]int)
+		m.pendingUsage[connectionName] = counts
+	}
+	counts[key]++
+}
+
+// FlushCompletionUsage saves the completion counts recorded since the last
+// save, if there are any. It may be called from any goroutine.
+func (m *Manager) FlushCompletionUsage() error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	if !m.applyCompletionUsage() || m.filePath == "" {
+		return nil
+	}
+	return m.save(m.filePath)
+}
+
+// GetCompletionUsage returns the accepted completion counts for
+// connectionName, including ones not saved yet. The map is shared and must
+// not be modified.
+func (m *Manager) GetCompletionUsage(connectionName string) map[string]int {
+	m.usageMu.Lock()
+	defer m.usageMu.Unlock()
+
+	var saved map[string]int
+	for _, u := range m.workspace.CompletionUsage {
+		if u.Connection == connectionName {
+			saved = u.Counts
+			break
+		}
+	}
+	pending := m.pendingUsage[connectionName]
+	if len(pending) == 0 {
+		return saved
+	}
+	return addCounts(saved, pending)
+}
+
+// applyCompletionUsage moves the pending counts into the workspace and
+// reports whether there were any. Count maps in the workspace are replaced
+// rather than modified, so maps handed out by GetCompletionUsage stay valid.
+func (m *Manager) applyCompletionUsage() bool {
+	m.usageMu.Lock()
+	defer m.usageMu.Unlock()
+
+	if len(m.pendingUsage) == 0 {
+		return false
+	}
+	for name, pending := range m.pendingUsage {
+		usage := m.completionUsageFor(name)
+		counts := addCounts(usage.Counts, pending)
+		forgetLeastUsed(counts, pending)
+		usage.Counts = counts
+	}
+	m.pendingUsage = nil
+	return true
+}
+
+func (m *Manager) completionUsageFor(connectionName string) *models.CompletionUsage {
+	for i := range m.workspace.CompletionUsage {
+		if m.workspace.CompletionUsage[i].Connection == connectionName {
+			return &m.workspace.CompletionUsage[i]
+		}
+	}
+	m.workspace.CompletionUsage = append(m.workspace.CompletionUsage, models.CompletionUsage{Connection: connectionName})
+	return &m.workspace.CompletionUsage[len(m.workspace.CompletionUsage)-1]
+}
+
+func (m *Manager) removeCompletionUsage(connectionName string) {
+	m.usageMu.Lock()
+	defer m.usageMu.Unlock()
+
+	delete(m.pendingUsage, connectionName)
+	for i, u := range m.workspace.CompletionUsage {
+		if u.Connection == connectionName {
+			m.workspace.CompletionUsage = append(m.workspace.CompletionUsage[:i], m.workspace.CompletionUsage[i+1:]...)
+			return
+		}
+	}
+}
+
+func addCounts(a, b map[string]int) map[string]int {
+	sum := make(map[string]int, len(a)+len(b))
+	for key, n := range a {
+		sum[key] = n
+	}
+	for key, n := range b {
+		sum[key] += n
+	}
+	return sum
+}
+
+// forgetLeastUsed drops the lowest counts until completionUsageLimit remain.
+// Keys in recent, the completions just accepted, are forgotten last.
+func forgetLeastUsed(counts, recent map[string]int) {
+	for len(counts) > completionUsageLimit {
+		victim := ""
+		for key, count := range counts {
+			if victim == "" {
+				victim = key
+				continue
+			}
+			_, isRecent := recent[key]
+			_, victimRecent := recent[victim]
+			if isRecent != victimRecent {
+				if !isRecent {
+					victim = key
+				}
+				continue
+			}
+			if count < counts[victim] || (count == counts[victim] && key < victim) {
+				victim = key
+			}
+		}
+		delete(counts, victim)
+	}
+}
@@ -0,0 +1,96 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+func TestRecordCompletion(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "workspace-*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer func() {
+		_ = os.Remove(tmpfile.Name())
+	}()
+
+	m := New()
+	if err := m.AddConnection(models.Connection{Name: "prod", Type: models.PostgresType, Host: "localhost", Port: 5432}); err != nil {
+		t.Fatalf("AddConnection: %v", err)
+	}
+	if err := m.Save(tmpfile.Name()); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	m.RecordCompletion("prod", "table:USERS")
+	m.RecordCompletion("prod", "table:USERS")
+	m.RecordCompletion("local", "column:ID")
+	m.RecordCompletion("", "table:IGNORED")
+
+	if got := m.GetCompletionUsage("prod"); got["table:USERS"] != 2 {
+		t.Errorf("unsaved prod usage = %v", got)
+	}
+	unflushed, err := Load(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := unflushed.GetCompletionUsage("prod"); got != nil {
+		t.Errorf("usage saved before flush: %v", got)
+	}
+
+	if err := m.FlushCompletionUsage(); err != nil {
+		t.Fatalf("FlushCompletionUsage: %v", err)
+	}
+	loaded, err := Load(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := loaded.GetCompletionUsage("prod"); got["table:USERS"] != 2 || len(got) != 1 {
+		t.Errorf("prod usage = %v", got)
+	}
+	if got := loaded.GetCompletionUsage("local"); got["column:ID"] != 1 {
+		t.Errorf("local usage = %v", got)
+	}
+	if got := loaded.GetCompletionUsage(""); got != nil {
+		t.Errorf("usage recorded without a connection: %v", got)
+	}
+
+	if err := loaded.DeleteConnection("prod"); err != nil {
+		t.Fatalf("DeleteConnection: %v", err)
+	}
+	if got := loaded.GetCompletionUsage("prod"); got != nil {
+		t.Errorf("usage kept after delete: %v", got)
+	}
+}
+
+func TestRecordCompletionForgetsLeastUsed(t *testing.T) {
+	m := New()
+	for i := 0; i < completionUsageLimit; i++ {
+		key := fmt.Sprintf("column:C%03d", i)
+		m.RecordCompletion("prod", key)
+		if i > 0 {
+			m.RecordCompletion("prod", key)
+		}
+	}
+	if err := m.FlushCompletionUsage(); err != nil {
+		t.Fatalf("FlushCompletionUsage: %v", err)
+	}
+	m.RecordCompletion("prod", "table:NEW")
+	if err := m.FlushCompletionUsage(); err != nil {
+		t.Fatalf("FlushCompletionUsage: %v", err)
+	}
+
+	usage := m.GetCompletionUsage("prod")
+	if len(usage) != completionUsageLimit {
+		t.Fatalf("kept %d entries, want %d", len(usage), completionUsageLimit)
+	}
+	if _, ok := usage["column:C000"]; ok {
+		t.Error("least used entry was not forgotten")
+	}
+	if usage["table:NEW"] != 1 {
+		t.Error("new entry was forgotten")
+	}
+}
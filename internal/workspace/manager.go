@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/android-lewis/dbsmith/internal/config"
 	"github.com/android-lewis/dbsmith/internal/constants"
+	"github.com/android-lewis/dbsmith/internal/fileutil"
 	"github.com/android-lewis/dbsmith/internal/logging"
 	"github.com/android-lewis/dbsmith/internal/models"
 	"gopkg.in/yaml.v3"
@@ -26,6 +28,24 @@ func GetDefaultWorkspacePath() string {
 type Manager struct {
 	workspace *models.Workspace
 	filePath  string
+
+	// base is the workspace as last read from or written to filePath, and
+	// diskChecksum the checksum of those bytes. Save uses them to detect and
+	// merge changes made by another dbsmith instance.
+	base         *models.Workspace
+	diskChecksum string
+
+	// shared is the read-only team workspace layered under this one.
+	shared *sharedFile
+
+	// saveMu serialises saves, which may run off the UI goroutine when
+	// completion usage is flushed.
+	saveMu sync.Mutex
+
+	// usageMu guards pendingUsage, the accepted completion counts not yet
+	// moved into the workspace, and the workspace's CompletionUsage.
+	usageMu      sync.Mutex
+	pendingUsage map[string]map[string]int
 }
 
 func New() *Manager {
@@ -36,7 +56,7 @@ func New() *Manager {
 			Preferences:  &models.UserPreferences{AutocompleteEnabled: true},
 			CreatedAt:    time.Now(),
 			LastModified: time.Now(),
-			Version:      constants.WorkspaceVersion,
+			Version:      workspaceVersion,
 		},
 	}
 }
@@ -47,19 +67,40 @@ func Load(filePath string) (*Manager, error) {
 		return nil, fmt.Errorf("failed to read workspace file: %w", err)
 	}
 
-	ws := &models.Workspace{}
-	if err := yaml.Unmarshal(data, ws); err != nil {
-		return nil, fmt.Errorf("failed to parse workspace file: %w", err)
+	ws, version, err := decodeWorkspace(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
 	}
 
 	if ws.Preferences == nil {
 		ws.Preferences = &models.UserPreferences{AutocompleteEnabled: true}
 	}
 
-	return &Manager{
-		workspace: ws,
-		filePath:  filePath,
-	}, nil
+	m := &Manager{
+		workspace:    ws,
+		filePath:     filePath,
+		base:         cloneWorkspace(ws),
+		diskChecksum: fileutil.Checksum(data),
+	}
+	m.loadSharedOrWarn()
+
+	if version < workspaceVersion {
+		backup, err := backupWorkspace(filePath, data, version)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.Save(filePath); err != nil {
+			return nil, err
+		}
+		logging.Info().
+			Str("workspace_path", filePath).
+			Str("backup_path", backup).
+			Int("from_version", version).
+			Int("to_version", workspaceVersion).
+			Msg("Migrated workspace file")
+	}
+
+	return m, nil
 }
 
 func (m *Manager) autoSave() error {
@@ -69,18 +110,111 @@ func (m *Manager) autoSave() error {
 	return nil
 }
 
+// Save writes the workspace to filePath under an advisory lock. When the
+// file is the one this workspace was read from and another process changed
+// it since, non-conflicting changes are merged in first; conflicting edits
+// return a *ConflictError and nothing is written.
 func (m *Manager) Save(filePath string) error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	return m.save(filePath)
+}
+
+func (m *Manager) save(filePath string) error {
+	m.applyCompletionUsage()
+
+	lock, err := fileutil.Lock(filePath, fileutil.DefaultLockTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to lock workspace file: %w", err)
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	if filePath == m.filePath {
+		if err := m.mergeFromDisk(); err != nil {
+			return err
+		}
+	}
+
+	return m.write(filePath)
+}
+
+// ForceSave overwrites the workspace file with the in-memory workspace,
+// discarding changes other processes made to it.
+func (m *Manager) ForceSave() error {
+	if m.filePath == "" {
+		return errors.New("workspace file path not set")
+	}
+
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	m.applyCompletionUsage()
+
+	lock, err := fileutil.Lock(m.filePath, fileutil.DefaultLockTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to lock workspace file: %w", err)
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	return m.write(m.filePath)
+}
+
+// Reload replaces the in-memory workspace with the file on disk, discarding
+// unsaved changes.
+func (m *Manager) Reload() error {
+	if m.filePath == "" {
+		return errors.New("workspace file path not set")
+	}
+
+	data, err := os.ReadFile(m.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read workspace file: %w", err)
+	}
+
+	ws, _, err := decodeWorkspace(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", m.filePath, err)
+	}
+	if ws.Preferences == nil {
+		ws.Preferences = &models.UserPreferences{AutocompleteEnabled: true}
+	}
+
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+	m.usageMu.Lock()
+	*m.workspace = *ws
+	m.usageMu.Unlock()
+	m.base = cloneWorkspace(ws)
+	m.diskChecksum = fileutil.Checksum(data)
+	m.loadSharedOrWarn()
+	return nil
+}
+
+// loadSharedOrWarn loads the shared layer, carrying on with just the personal
+// workspace if it cannot be read, e.g. before the team repo is cloned.
+func (m *Manager) loadSharedOrWarn() {
+	if err := m.loadSharedLayer(); err != nil {
+		logging.Warn().
+			Err(err).
+			Str("shared_path", m.SharedPath()).
+			Msg("Shared workspace unavailable")
+	}
+}
+
+func (m *Manager) write(filePath string) error {
 	m.workspace.LastModified = time.Now()
+	m.workspace.Version = workspaceVersion
 	data, err := yaml.Marshal(m.workspace)
 	if err != nil {
 		return fmt.Errorf("failed to marshal workspace: %w", err)
 	}
 
-	if err := os.WriteFile(filePath, data, 0600); err != nil {
+	if err := fileutil.WriteFileAtomic(filePath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write workspace file: %w", err)
 	}
 
 	m.filePath = filePath
+	m.base = cloneWorkspace(m.workspace)
+	m.diskChecksum = fileutil.Checksum(data)
 	return nil
 }
 
@@ -106,7 +240,7 @@ func (m *Manager) AddConnection(conn models.Connection) error {
 		return errors.New("connection type is required")
 	}
 
-	for _, c := range m.workspace.Connections {
+	for _, c := range m.connections() {
 		if c.Name == conn.Name {
 			return fmt.Errorf("connection with name '%s' already exists", conn.Name)
 		}
@@ -114,27 +248,37 @@ func (m *Manager) AddConnection(conn models.Connection) error {
 
 	conn.CreatedAt = time.Now()
 	conn.LastModified = time.Now()
+	lastModified := m.workspace.LastModified
 	m.workspace.Connections = append(m.workspace.Connections, conn)
 	m.workspace.LastModified = time.Now()
 
+	// A connection that could not be saved is taken back out, so a later
+	// save does not write it after the caller has given up on it.
+	if err := m.autoSave(); err != nil {
+		if idx := m.personalConnectionIndex(conn.Name); idx >= 0 {
+			m.workspace.Connections = append(m.workspace.Connections[:idx], m.workspace.Connections[idx+1:]...)
+		}
+		m.workspace.LastModified = lastModified
+		return err
+	}
+
 	logging.Info().
 		Str("connection_name", conn.Name).
 		Str("connection_type", string(conn.Type)).
 		Msg("Connection added to workspace")
 
-	return m.autoSave()
+	return nil
 }
 
+// DeleteConnection removes a personal connection. Deleting an override
+// brings back the shared connection it replaced; shared connections
+// themselves cannot be deleted.
 func (m *Manager) DeleteConnection(name string) error {
-	idx := -1
-	for i, c := range m.workspace.Connections {
-		if c.Name == name {
-			idx = i
-			break
-		}
-	}
-
+	idx := m.personalConnectionIndex(name)
 	if idx == -1 {
+		if m.sharedConnectionIndex(name) >= 0 {
+			return fmt.Errorf("%w: connection %s", ErrSharedReadOnly, name)
+		}
 		return fmt.Errorf("connection not found: %s", name)
 	}
 
@@ -142,6 +286,9 @@ func (m *Manager) DeleteConnection(name string) error {
 		m.workspace.Connections[:idx],
 		m.workspace.Connections[idx+1:]...,
 	)
+	if m.sharedConnectionIndex(name) == -1 {
+		m.removeCompletionUsage(name)
+	}
 	m.workspace.LastModified = time.Now()
 
 	logging.Info().Str("connection_name", name).Msg("Connection deleted from workspace")
@@ -150,10 +297,11 @@ func (m *Manager) DeleteConnection(name string) error {
 }
 
 func (m *Manager) GetConnection(name string) (*models.Connection, error) {
-	for i, c := range m.workspace.Connections {
-		if c.Name == name {
-			return &m.workspace.Connections[i], nil
-		}
+	if i := m.personalConnectionIndex(name); i >= 0 {
+		return &m.workspace.Connections[i], nil
+	}
+	if i := m.sharedConnectionIndex(name); i >= 0 {
+		return &m.shared.Connections[i], nil
 	}
 
 	return nil, fmt.Errorf("connection not found: %s", name)
@@ -168,27 +316,28 @@ func (m *Manager) GetConnectionByName(name string) *models.Connection {
 }
 
 func (m *Manager) ListConnections() []models.Connection {
-	conns := make([]models.Connection, len(m.workspace.Connections))
-	copy(conns, m.workspace.Connections)
+	layered := m.connections()
+	conns := make([]models.Connection, len(layered))
+	copy(conns, layered)
 	return conns
 }
 
+// UpdateConnection replaces a connection. Updating a shared connection saves
+// a personal override, leaving the shared workspace untouched.
 func (m *Manager) UpdateConnection(conn models.Connection) error {
-	idx := -1
-	for i, c := range m.workspace.Connections {
-		if c.Name == conn.Name {
-			idx = i
-			break
-		}
-	}
-
-	if idx == -1 {
+	idx := m.personalConnectionIndex(conn.Name)
+	switch {
+	case idx >= 0:
+		conn.CreatedAt = m.workspace.Connections[idx].CreatedAt
+		conn.LastModified = time.Now()
+		m.workspace.Connections[idx] = conn
+	case m.sharedConnectionIndex(conn.Name) >= 0:
+		conn.CreatedAt = time.Now()
+		conn.LastModified = time.Now()
+		m.workspace.Connections = append(m.workspace.Connections, conn)
+	default:
 		return fmt.Errorf("connection not found: %s", conn.Name)
 	}
-
-	conn.CreatedAt = m.workspace.Connections[idx].CreatedAt
-	conn.LastModified = time.Now()
-	m.workspace.Connections[idx] = conn
 	m.workspace.LastModified = time.Now()
 
 	return m.autoSave()
@@ -203,12 +352,15 @@ func (m *Manager) AddSavedQuery(query models.SavedQuery) error {
 		return errors.New("query name is required")
 	}
 
-	for _, q := range m.workspace.SavedQueries {
+	for _, q := range m.savedQueries() {
 		if q.ID == query.ID {
 			return fmt.Errorf("query with ID '%s' already exists", query.ID)
 		}
 	}
 
+	query.Folder = NormalizeFolder(query.Folder)
+	query.Tags = NormalizeTags(query.Tags)
+	m.keepFolder(query.Folder)
 	query.CreatedAt = time.Now()
 	query.LastModified = time.Now()
 	m.workspace.SavedQueries = append(m.workspace.SavedQueries, query)
@@ -217,16 +369,14 @@ func (m *Manager) AddSavedQuery(query models.SavedQuery) error {
 	return m.autoSave()
 }
 
+// DeleteSavedQuery removes a personal saved query; like connections, shared
+// queries cannot be deleted and deleting an override restores them.
 func (m *Manager) DeleteSavedQuery(id string) error {
-	idx := -1
-	for i, q := range m.workspace.SavedQueries {
-		if q.ID == id {
-			idx = i
-			break
-		}
-	}
-
+	idx := m.personalQueryIndex(id)
 	if idx == -1 {
+		if m.sharedQueryIndex(id) >= 0 {
+			return fmt.Errorf("%w: query %s", ErrSharedReadOnly, id)
+		}
 		return fmt.Errorf("query not found: %s", id)
 	}
 
@@ -234,78 +384,79 @@ func (m *Manager) DeleteSavedQuery(id string) error {
 		m.workspace.SavedQueries[:idx],
 		m.workspace.SavedQueries[idx+1:]...,
 	)
+	if m.sharedQueryIndex(id) < 0 {
+		m.removeQueryUsage(id)
+	}
 	m.workspace.LastModified = time.Now()
 
 	return m.autoSave()
 }
 
 func (m *Manager) GetSavedQuery(id string) (*models.SavedQuery, error) {
-	for i, q := range m.workspace.SavedQueries {
-		if q.ID == id {
-			return &m.workspace.SavedQueries[i], nil
-		}
+	if i := m.personalQueryIndex(id); i >= 0 {
+		return &m.workspace.SavedQueries[i], nil
+	}
+	if i := m.sharedQueryIndex(id); i >= 0 {
+		return &m.shared.SavedQueries[i], nil
 	}
 
 	return nil, fmt.Errorf("query not found: %s", id)
 }
 
 func (m *Manager) ListSavedQueries() []models.SavedQuery {
-	queries := make([]models.SavedQuery, len(m.workspace.SavedQueries))
-	copy(queries, m.workspace.SavedQueries)
+	layered := m.savedQueries()
+	queries := make([]models.SavedQuery, len(layered))
+	copy(queries, layered)
 	return queries
 }
 
+// SearchSavedQueries returns queries whose name, description, folder, tags or
+// SQL body contain every word of term.
 func (m *Manager) SearchSavedQueries(term string) []models.SavedQuery {
-	var results []models.SavedQuery
-
-	for _, q := range m.workspace.SavedQueries {
-		if matchesString(q.Name, term) {
-			results = append(results, q)
-		}
-	}
-
-	return results
+	return m.FilterSavedQueries(QueryFilter{Text: term})
 }
 
 func (m *Manager) GetWorkspace() *models.Workspace {
 	return m.workspace
 }
-func (m *Manager) UpdateSavedQuery(query models.SavedQuery) error {
-	idx := -1
-	for i, q := range m.workspace.SavedQueries {
-		if q.ID == query.ID {
-			idx = i
-			break
-		}
-	}
 
-	if idx == -1 {
-		return fmt.Errorf("query not found: %s", query.ID)
+// UpdateSavedQuery replaces a saved query. Updating a shared query saves a
+// personal override.
+func (m *Manager) UpdateSavedQuery(query models.SavedQuery) error {
+	existing, err := m.GetSavedQuery(query.ID)
+	if err != nil {
+		return err
 	}
 
-	query.CreatedAt = m.workspace.SavedQueries[idx].CreatedAt
-	query.LastModified = time.Now()
-	m.workspace.SavedQueries[idx] = query
-	m.workspace.LastModified = time.Now()
+	query.Folder = NormalizeFolder(query.Folder)
+	query.Tags = NormalizeTags(query.Tags)
+	m.keepFolder(NormalizeFolder(existing.Folder))
+	m.keepFolder(query.Folder)
+	query.CreatedAt = existing.CreatedAt
+	m.putSavedQuery(query)
 
 	return m.autoSave()
 }
 
-func matchesString(text, term string) bool {
-	if term == "" {
-		return true
+// putSavedQuery stores query in the personal layer, as an override when it
+// only exists in the shared one.
+func (m *Manager) putSavedQuery(query models.SavedQuery) {
+	now := time.Now()
+	if query.CreatedAt.IsZero() {
+		query.CreatedAt = now
 	}
+	query.LastModified = now
 
-	textLower := strings.ToLower(text)
-	termLower := strings.ToLower(term)
-
-	for i := 0; i < len(text)-len(term)+1; i++ {
-		if text[i:i+len(term)] == term || textLower[i:i+len(term)] == termLower {
-			return true
-		}
+	if idx := m.personalQueryIndex(query.ID); idx >= 0 {
+		m.workspace.SavedQueries[idx] = query
+	} else {
+		m.workspace.SavedQueries = append(m.workspace.SavedQueries, query)
 	}
+	m.workspace.LastModified = now
+}
 
-	return false
+func matchesString(text, term string) bool {
+	return strings.Contains(strings.ToLower(text), strings.ToLower(term))
 }
 
 func (m *Manager) GetAutocompleteEnabled() bool {
@@ -334,7 +485,7 @@ func (m *Manager) SetLastUsedConnection(name string) error {
 	// Allow clearing last used connection with empty string
 	if name != "" {
 		found := false
-		for _, c := range m.workspace.Connections {
+		for _, c := range m.connections() {
 			if c.Name == name {
 				found = true
 				break
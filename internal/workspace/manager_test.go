@@ -2,6 +2,7 @@ package workspace
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/android-lewis/dbsmith/internal/models"
@@ -58,6 +59,47 @@ func TestAddConnectionDuplicate(t *testing.T) {
 	}
 }
 
+func TestAddConnectionRollsBackWhenSaveFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workspace.yaml")
+	m := New()
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	m.filePath = path
+
+	// A directory where the lock file should go makes every save fail.
+	if err := os.Remove(path + ".lock"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path+".lock", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddConnection(models.Connection{Name: "lost", Type: models.PostgresType}); err == nil {
+		t.Fatal("expected AddConnection to fail when the workspace cannot be saved")
+	}
+	if m.GetConnectionByName("lost") != nil {
+		t.Error("connection that failed to save is still in the workspace")
+	}
+
+	if err := os.Remove(path + ".lock"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddConnection(models.Connection{Name: "kept", Type: models.PostgresType}); err != nil {
+		t.Fatalf("AddConnection: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.GetConnectionByName("lost") != nil {
+		t.Error("connection that failed to save was written by a later save")
+	}
+	if loaded.GetConnectionByName("kept") == nil {
+		t.Error("expected the later connection to be saved")
+	}
+}
+
 func TestDeleteConnection(t *testing.T) {
 	m := New()
 
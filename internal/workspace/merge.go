@@ -0,0 +1,310 @@
+package workspace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/fileutil"
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrWorkspaceConflict is matched by *ConflictError.
+var ErrWorkspaceConflict = errors.New("workspace file was changed by another process")
+
+// ConflictError lists the items both this process and another one changed
+// since the workspace was read.
+type ConflictError struct {
+	Path      string
+	Conflicts []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %v; conflicting changes to %s",
+		e.Path, ErrWorkspaceConflict, strings.Join(e.Conflicts, ", "))
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrWorkspaceConflict
+}
+
+// mergeFromDisk folds in changes another process saved since this workspace
+// was read. Edits to different connections, queries and folders merge;
+// editing the same item on both sides is a conflict.
+func (m *Manager) mergeFromDisk() error {
+	data, err := os.ReadFile(m.filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read workspace file: %w", err)
+	}
+	if fileutil.Checksum(data) == m.diskChecksum {
+		return nil
+	}
+
+	theirs, _, err := decodeWorkspace(data)
+	if err != nil {
+		return fmt.Errorf("%s changed on disk and cannot be read: %w", m.filePath, err)
+	}
+
+	base := m.base
+	if base == nil {
+		base = &models.Workspace{}
+	}
+
+	merged, conflicts := mergeWorkspaces(base, m.workspace, theirs)
+	if len(conflicts) > 0 {
+		return &ConflictError{Path: m.filePath, Conflicts: conflicts}
+	}
+
+	logging.Info().Str("workspace_path", m.filePath).Msg("Merged workspace changes from another process")
+	sharedChanged := merged.Shared != m.workspace.Shared
+	m.usageMu.Lock()
+	*m.workspace = *merged
+	m.usageMu.Unlock()
+	if sharedChanged {
+		m.loadSharedOrWarn()
+	}
+	return nil
+}
+
+// mergeWorkspaces three-way merges ours and theirs against their common
+// base. Conflicting items keep our version and are reported.
+func mergeWorkspaces(base, ours, theirs *models.Workspace) (*models.Workspace, []string) {
+	merged := cloneWorkspace(ours)
+	var conflicts []string
+
+	name, conflict := mergeValue(base.Name, ours.Name, theirs.Name)
+	merged.Name = name
+	if conflict {
+		conflicts = append(conflicts, "workspace name")
+	}
+
+	merged.Shared, conflict = mergeValue(base.Shared, ours.Shared, theirs.Shared)
+	if conflict {
+		conflicts = append(conflicts, "shared workspace")
+	}
+
+	// Preferences and session state are per user; on a clash ours wins.
+	merged.Preferences, _ = mergeValue(base.Preferences, ours.Preferences, theirs.Preferences)
+	merged.LastUsedConnection, _ = mergeValue(base.LastUsedConnection, ours.LastUsedConnection, theirs.LastUsedConnection)
+	merged.LastOpenTabs, _ = mergeValue(base.LastOpenTabs, ours.LastOpenTabs, theirs.LastOpenTabs)
+	merged.CompletionUsage = mergeCompletionUsage(base.CompletionUsage, ours.CompletionUsage, theirs.CompletionUsage)
+
+	var keyConflicts []string
+	merged.Connections, keyConflicts = mergeKeyed(base.Connections, ours.Connections, theirs.Connections,
+		func(c models.Connection) string { return c.Name })
+	for _, k := range keyConflicts {
+		conflicts = append(conflicts, "connection "+k)
+	}
+
+	merged.SavedQueries, keyConflicts = mergeKeyed(base.SavedQueries, ours.SavedQueries, theirs.SavedQueries,
+		func(q models.SavedQuery) string { return q.ID })
+	for _, k := range keyConflicts {
+		conflicts = append(conflicts, "saved query "+k)
+	}
+
+	merged.Folders, _ = mergeKeyed(base.Folders, ours.Folders, theirs.Folders, NormalizeFolder)
+	merged.Snippets, keyConflicts = mergeKeyed(base.Snippets, ours.Snippets, theirs.Snippets, SnippetKey)
+	for _, k := range keyConflicts {
+		conflicts = append(conflicts, "snippet "+k)
+	}
+	merged.QueryUsage = mergeUsage(base.QueryUsage, ours.QueryUsage, theirs.QueryUsage, merged.SavedQueries)
+
+	return merged, conflicts
+}
+
+// mergeValue returns whichever side changed value; when both changed it to
+// different values it keeps ours and reports a conflict.
+func mergeValue[T any](base, ours, theirs T) (T, bool) {
+	switch {
+	case sameYAML(ours, theirs), sameYAML(base, theirs):
+		return ours, false
+	case sameYAML(base, ours):
+		return theirs, false
+	default:
+		return ours, true
+	}
+}
+
+// mergeKeyed merges lists of items identified by key. Additions, edits and
+// deletions from either side apply; items changed differently on both sides
+// keep our version and their keys are returned as conflicts.
+func mergeKeyed[T any](base, ours, theirs []T, key func(T) string) ([]T, []string) {
+	index := func(items []T) map[string]T {
+		m := make(map[string]T, len(items))
+		for _, item := range items {
+			m[key(item)] = item
+		}
+		return m
+	}
+	b, o, t := index(base), index(ours), index(theirs)
+
+	same := func(x map[string]T, y map[string]T, k string) bool {
+		xv, inX := x[k]
+		yv, inY := y[k]
+		return inX == inY && (!inX || sameYAML(xv, yv))
+	}
+
+	var conflicts []string
+	decide := func(k string) (T, bool) {
+		switch {
+		case same(o, t, k), same(b, t, k):
+			v, ok := o[k]
+			return v, ok
+		case same(b, o, k):
+			v, ok := t[k]
+			return v, ok
+		default:
+			conflicts = append(conflicts, k)
+			v, ok := o[k]
+			return v, ok
+		}
+	}
+
+	var merged []T
+	seen := make(map[string]bool)
+	for _, list := range [][]T{ours, theirs} {
+		for _, item := range list {
+			k := key(item)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			if v, ok := decide(k); ok {
+				merged = append(merged, v)
+			}
+		}
+	}
+	return merged, conflicts
+}
+
+// mergeUsage adds up the runs each side recorded since base, so two
+// instances running queries at once never lose counts.
+func mergeUsage(base, ours, theirs []models.QueryUsage, queries []models.SavedQuery) []models.QueryUsage {
+	index := func(items []models.QueryUsage) map[string]models.QueryUsage {
+		m := make(map[string]models.QueryUsage, len(items))
+		for _, u := range items {
+			m[u.QueryID] = u
+		}
+		return m
+	}
+	b, t := index(base), index(theirs)
+
+	var merged []models.QueryUsage
+	add := func(o models.QueryUsage) {
+		bu, tu := b[o.QueryID], t[o.QueryID]
+		u := models.QueryUsage{
+			QueryID:        o.QueryID,
+			ExecutionCount: o.ExecutionCount + tu.ExecutionCount - bu.ExecutionCount,
+			LastExecutedAt: o.LastExecutedAt,
+		}
+		if tu.LastExecutedAt.After(u.LastExecutedAt) {
+			u.LastExecutedAt = tu.LastExecutedAt
+		}
+
+		names := make(map[string]bool)
+		for name := range o.Connections {
+			names[name] = true
+		}
+		for name := range tu.Connections {
+			names[name] = true
+		}
+		for name := range names {
+			oc, tc, bc := o.Connections[name], tu.Connections[name], bu.Connections[name]
+			cu := models.ConnectionUsage{
+				ExecutionCount: oc.ExecutionCount + tc.ExecutionCount - bc.ExecutionCount,
+				LastExecutedAt: oc.LastExecutedAt,
+			}
+			if tc.LastExecutedAt.After(cu.LastExecutedAt) {
+				cu.LastExecutedAt = tc.LastExecutedAt
+			}
+			if u.Connections == nil {
+				u.Connections = make(map[string]models.ConnectionUsage)
+			}
+			u.Connections[name] = cu
+		}
+		merged = append(merged, u)
+	}
+
+	o := index(ours)
+	for _, q := range queries {
+		ou, inO := o[q.ID]
+		_, inT := t[q.ID]
+		if !inO && !inT {
+			continue
+		}
+		if !inO {
+			ou = models.QueryUsage{QueryID: q.ID}
+		}
+		add(ou)
+	}
+	return merged
+}
+
+// mergeCompletionUsage adds up the completions each side accepted since
+// base. A connection whose counts one side removed stays removed.
+func mergeCompletionUsage(base, ours, theirs []models.CompletionUsage) []models.CompletionUsage {
+	index := func(items []models.CompletionUsage) map[string]map[string]int {
+		m := make(map[string]map[string]int, len(items))
+		for _, u := range items {
+			m[u.Connection] = u.Counts
+		}
+		return m
+	}
+	b, o, t := index(base), index(ours), index(theirs)
+
+	var merged []models.CompletionUsage
+	seen := make(map[string]bool)
+	for _, list := range [][]models.CompletionUsage{ours, theirs} {
+		for _, u := range list {
+			name := u.Connection
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			bc, inB := b[name]
+			oc, inO := o[name]
+			tc, inT := t[name]
+			if inB && (!inO || !inT) {
+				continue
+			}
+
+			counts := make(map[string]int)
+			for _, side := range []map[string]int{oc, tc} {
+				for key := range side {
+					if n := oc[key] + tc[key] - bc[key]; n > 0 {
+						counts[key] = n
+					}
+				}
+			}
+			forgetLeastUsed(counts, nil)
+			merged = append(merged, models.CompletionUsage{Connection: name, Counts: counts})
+		}
+	}
+	return merged
+}
+
+func sameYAML(a, b interface{}) bool {
+	ad, aerr := yaml.Marshal(a)
+	bd, berr := yaml.Marshal(b)
+	return aerr == nil && berr == nil && bytes.Equal(ad, bd)
+}
+
+func cloneWorkspace(ws *models.Workspace) *models.Workspace {
+	clone := &models.Workspace{}
+	data, err := yaml.Marshal(ws)
+	if err == nil {
+		err = yaml.Unmarshal(data, clone)
+	}
+	if err != nil {
+		c := *ws
+		return &c
+	}
+	return clone
+}
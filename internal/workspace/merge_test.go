@@ -0,0 +1,181 @@
+package workspace
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+// twoInstances saves a workspace and loads it twice, like two dbsmith
+// processes sharing one file.
+func twoInstances(t *testing.T) (path string, a, b *Manager) {
+	t.Helper()
+	path = filepath.Join(t.TempDir(), "workspace.yaml")
+
+	m := New()
+	m.SetName("shared")
+	_ = m.AddConnection(models.Connection{Name: "local", Type: models.SQLiteType, Database: "dev.db"})
+	_ = m.AddSavedQuery(models.SavedQuery{ID: "q1", Name: "Users", SQL: "SELECT * FROM users"})
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	var err error
+	if a, err = Load(path); err != nil {
+		t.Fatalf("Load a: %v", err)
+	}
+	if b, err = Load(path); err != nil {
+		t.Fatalf("Load b: %v", err)
+	}
+	return path, a, b
+}
+
+func TestSaveMergesNonConflictingChanges(t *testing.T) {
+	path, a, b := twoInstances(t)
+
+	if err := a.AddConnection(models.Connection{Name: "prod", Type: models.PostgresType, Host: "db"}); err != nil {
+		t.Fatalf("a.AddConnection: %v", err)
+	}
+	if err := b.AddSavedQuery(models.SavedQuery{ID: "q2", Name: "Orders", SQL: "SELECT * FROM orders"}); err != nil {
+		t.Fatalf("b.AddSavedQuery: %v", err)
+	}
+	if err := b.DeleteConnection("local"); err != nil {
+		t.Fatalf("b.DeleteConnection: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	conns := loaded.ListConnections()
+	if len(conns) != 1 || conns[0].Name != "prod" {
+		t.Errorf("connections = %v, want only prod", conns)
+	}
+	if len(loaded.ListSavedQueries()) != 2 {
+		t.Errorf("saved queries = %v, want q1 and q2", loaded.ListSavedQueries())
+	}
+
+	// b's in-memory workspace picks up a's connection too.
+	if _, err := b.GetConnection("prod"); err != nil {
+		t.Error("merged changes were not applied in memory")
+	}
+}
+
+func TestSaveReportsConflicts(t *testing.T) {
+	path, a, b := twoInstances(t)
+
+	qa, _ := a.GetSavedQuery("q1")
+	qa.SQL = "SELECT id FROM users"
+	if err := a.UpdateSavedQuery(*qa); err != nil {
+		t.Fatalf("a.UpdateSavedQuery: %v", err)
+	}
+
+	qb, _ := b.GetSavedQuery("q1")
+	qb.SQL = "SELECT name FROM users"
+	err := b.UpdateSavedQuery(*qb)
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrWorkspaceConflict) {
+		t.Fatalf("UpdateSavedQuery error = %v, want ConflictError", err)
+	}
+	if len(conflict.Conflicts) != 1 || conflict.Conflicts[0] != "saved query q1" {
+		t.Errorf("conflicts = %v", conflict.Conflicts)
+	}
+
+	onDisk, _ := Load(path)
+	if q, _ := onDisk.GetSavedQuery("q1"); q.SQL != "SELECT id FROM users" {
+		t.Errorf("conflicting save overwrote the file: %q", q.SQL)
+	}
+
+	if err := b.ForceSave(); err != nil {
+		t.Fatalf("ForceSave: %v", err)
+	}
+	onDisk, _ = Load(path)
+	if q, _ := onDisk.GetSavedQuery("q1"); q.SQL != "SELECT name FROM users" {
+		t.Errorf("ForceSave did not write ours: %q", q.SQL)
+	}
+
+	if err := a.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if q, _ := a.GetSavedQuery("q1"); q.SQL != "SELECT name FROM users" {
+		t.Errorf("Reload did not pick up the file: %q", q.SQL)
+	}
+}
+
+func TestSaveMergesUsageCounts(t *testing.T) {
+	path, a, b := twoInstances(t)
+
+	_ = a.RecordQueryExecution("q1", "local")
+	_ = a.RecordQueryExecution("q1", "local")
+	_ = b.RecordQueryExecution("q1", "local")
+	_ = b.RecordQueryExecution("q1", "prod")
+
+	loaded, _ := Load(path)
+	usage := loaded.GetQueryUsage("q1")
+	if usage.ExecutionCount != 4 {
+		t.Errorf("ExecutionCount = %d, want 4", usage.ExecutionCount)
+	}
+	if usage.Connections["local"].ExecutionCount != 3 || usage.Connections["prod"].ExecutionCount != 1 {
+		t.Errorf("Connections = %+v", usage.Connections)
+	}
+}
+
+func TestSaveMergesCompletionUsage(t *testing.T) {
+	path, a, b := twoInstances(t)
+
+	a.RecordCompletion("local", "table:USERS")
+	a.RecordCompletion("gone", "table:OLD")
+	if err := a.FlushCompletionUsage(); err != nil {
+		t.Fatalf("a.FlushCompletionUsage: %v", err)
+	}
+	if err := b.Reload(); err != nil {
+		t.Fatalf("b.Reload: %v", err)
+	}
+
+	a.RecordCompletion("local", "table:USERS")
+	a.RecordCompletion("local", "column:ID")
+	if err := a.FlushCompletionUsage(); err != nil {
+		t.Fatalf("a.FlushCompletionUsage: %v", err)
+	}
+	b.RecordCompletion("local", "table:USERS")
+	b.RecordCompletion("prod", "table:ORDERS")
+	b.removeCompletionUsage("gone")
+	if err := b.FlushCompletionUsage(); err != nil {
+		t.Fatalf("b.FlushCompletionUsage: %v", err)
+	}
+
+	loaded, _ := Load(path)
+	local := loaded.GetCompletionUsage("local")
+	if local["table:USERS"] != 3 || local["column:ID"] != 1 {
+		t.Errorf("local usage = %v, want table:USERS 3 and column:ID 1", local)
+	}
+	if got := loaded.GetCompletionUsage("prod"); got["table:ORDERS"] != 1 {
+		t.Errorf("prod usage = %v", got)
+	}
+	if got := loaded.GetCompletionUsage("gone"); got != nil {
+		t.Errorf("removed usage came back: %v", got)
+	}
+}
+
+func TestMergeKeyed(t *testing.T) {
+	id := func(s string) string { return s }
+	merged, conflicts := mergeKeyed(
+		[]string{"a", "b", "c"},
+		[]string{"a", "c", "d"},
+		[]string{"a", "b", "e"},
+		id,
+	)
+	want := []string{"a", "d", "e"}
+	if len(conflicts) != 0 || len(merged) != len(want) {
+		t.Fatalf("merged = %v, conflicts = %v", merged, conflicts)
+	}
+	for i := range want {
+		if merged[i] != want[i] {
+			t.Errorf("merged = %v, want %v", merged, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/constants"
+	"github.com/android-lewis/dbsmith/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrWorkspaceTooNew is returned for files written by a newer dbsmith, which
+// this build could silently damage.
+var ErrWorkspaceTooNew = errors.New("workspace file was written by a newer version of dbsmith")
+
+// migration upgrades a decoded workspace document from version from to
+// from+1. Steps work on the generic YAML tree so they can read fields the
+// current models no longer have.
+type migration struct {
+	from        int
+	description string
+	apply       func(doc map[string]interface{}) error
+}
+
+// migrations must stay ordered and contiguous, ending at workspaceVersion. A
+// change to the workspace schema bumps constants.WorkspaceVersion and adds the
+// step that upgrades the previous one.
+var migrations []migration
+
+// workspaceVersion is the version this build reads and writes. It is a
+// variable so tests can exercise migrations against a fake table.
+var workspaceVersion = constants.WorkspaceVersion
+
+// decodeWorkspace parses workspace YAML, upgrading it to the current version.
+// It returns the version the data was written with. Files without a version
+// predate versioning and are treated as version 1.
+func decodeWorkspace(data []byte) (*models.Workspace, int, error) {
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse workspace file: %w", err)
+	}
+
+	version, err := documentVersion(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	if version > workspaceVersion {
+		return nil, version, fmt.Errorf("%w: file version %d, supported version %d",
+			ErrWorkspaceTooNew, version, workspaceVersion)
+	}
+
+	if version < workspaceVersion {
+		if err := applyMigrations(doc, version); err != nil {
+			return nil, version, err
+		}
+		if data, err = yaml.Marshal(doc); err != nil {
+			return nil, version, fmt.Errorf("failed to marshal migrated workspace: %w", err)
+		}
+	}
+
+	ws := &models.Workspace{}
+	if err := yaml.Unmarshal(data, ws); err != nil {
+		return nil, version, fmt.Errorf("failed to parse workspace file: %w", err)
+	}
+	ws.Version = workspaceVersion
+	return ws, version, nil
+}
+
+func documentVersion(doc map[string]interface{}) (int, error) {
+	switch v := doc["version"].(type) {
+	case nil:
+		return 1, nil
+	case int:
+		if v < 1 {
+			return 1, nil
+		}
+		return v, nil
+	default:
+		return 0, fmt.Errorf("failed to parse workspace file: invalid version %v", v)
+	}
+}
+
+func applyMigrations(doc map[string]interface{}, version int) error {
+	for _, step := range migrations {
+		if step.from < version {
+			continue
+		}
+		if step.from != version {
+			return fmt.Errorf("no workspace migration from version %d", version)
+		}
+		if err := step.apply(doc); err != nil {
+			return fmt.Errorf("failed to migrate workspace from version %d (%s): %w", step.from, step.description, err)
+		}
+		version++
+		doc["version"] = version
+	}
+	if version != workspaceVersion {
+		return fmt.Errorf("no workspace migration from version %d", version)
+	}
+	return nil
+}
+
+// backupWorkspace copies the original file next to itself before a migrated
+// version overwrites it, and returns the backup path.
+func backupWorkspace(filePath string, data []byte, version int) (string, error) {
+	backup := fmt.Sprintf("%s.v%d-%s.bak", filePath, version, time.Now().Format("20060102-150405"))
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to back up workspace file: %w", err)
+	}
+	return backup, nil
+}
@@ -0,0 +1,233 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/constants"
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+// copyFixture copies a file from testdata/migrations into a temp dir so Load
+// can rewrite it and drop a backup beside it.
+func copyFixture(t *testing.T, name string) (path string, original []byte) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "migrations", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	path = filepath.Join(t.TempDir(), "workspace.yaml")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	return path, data
+}
+
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(path + ".v*.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestLoadUnversionedWorkspace(t *testing.T) {
+	path, original := copyFixture(t, "v0_unversioned.yaml")
+
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	ws := m.GetWorkspace()
+	if ws.Version != constants.WorkspaceVersion {
+		t.Errorf("Version = %d, want %d", ws.Version, constants.WorkspaceVersion)
+	}
+	if ws.Connections[0].Type != models.PostgresType {
+		t.Errorf("connection type = %q, want %q", ws.Connections[0].Type, models.PostgresType)
+	}
+	if m.GetAutocompleteEnabled() {
+		t.Error("preferences were lost")
+	}
+	q, err := m.GetSavedQuery("query_1700000000")
+	if err != nil {
+		t.Fatalf("saved query lost: %v", err)
+	}
+	if want := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC); !q.CreatedAt.Equal(want) {
+		t.Errorf("CreatedAt = %v, want %v", q.CreatedAt, want)
+	}
+
+	// Files without a version are version 1, which is current.
+	if len(backups(t, path)) != 0 {
+		t.Error("unversioned workspace should not be backed up")
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != string(original) {
+		t.Error("unversioned workspace file was rewritten on load")
+	}
+}
+
+func TestLoadCurrentWorkspaceIsUntouched(t *testing.T) {
+	path, original := copyFixture(t, "v1_workspace.yaml")
+
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if m.GetName() != "Current Workspace" {
+		t.Errorf("name = %q", m.GetName())
+	}
+	if len(backups(t, path)) != 0 {
+		t.Error("current workspace should not be backed up")
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != string(original) {
+		t.Error("current workspace file was rewritten on load")
+	}
+}
+
+func TestBackupWorkspace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workspace.yaml")
+	backup, err := backupWorkspace(path, []byte("name: Old\n"), 1)
+	if err != nil {
+		t.Fatalf("backupWorkspace: %v", err)
+	}
+	if found := backups(t, path); len(found) != 1 || found[0] != backup || !strings.Contains(backup, ".v1-") {
+		t.Fatalf("backups = %v, want %s", found, backup)
+	}
+	if data, _ := os.ReadFile(backup); string(data) != "name: Old\n" {
+		t.Errorf("backup = %q", data)
+	}
+}
+
+// fakeMigrations swaps in a one-step migration table that upgrades version 1
+// files to version 2, restoring the real table afterwards.
+func fakeMigrations(t *testing.T, apply func(doc map[string]interface{}) error) {
+	t.Helper()
+	oldVersion, oldMigrations := workspaceVersion, migrations
+	t.Cleanup(func() { workspaceVersion, migrations = oldVersion, oldMigrations })
+
+	workspaceVersion = 2
+	migrations = []migration{{from: 1, description: "test step", apply: apply}}
+}
+
+func prefixName(doc map[string]interface{}) error {
+	name, ok := doc["name"].(string)
+	if !ok {
+		return errors.New("name missing")
+	}
+	doc["name"] = "Migrated " + name
+	return nil
+}
+
+func TestApplyMigrations(t *testing.T) {
+	fakeMigrations(t, prefixName)
+
+	doc := map[string]interface{}{"version": 1, "name": "Old"}
+	if err := applyMigrations(doc, 1); err != nil {
+		t.Fatalf("applyMigrations: %v", err)
+	}
+	if doc["name"] != "Migrated Old" {
+		t.Errorf("name = %v, want the migrated name", doc["name"])
+	}
+	if doc["version"] != 2 {
+		t.Errorf("version = %v, want 2", doc["version"])
+	}
+
+	if err := applyMigrations(map[string]interface{}{}, 1); err == nil || !strings.Contains(err.Error(), "test step") {
+		t.Errorf("expected the failing step to be named, got %v", err)
+	}
+
+	migrations = nil
+	if err := applyMigrations(map[string]interface{}{"version": 1}, 1); err == nil {
+		t.Error("expected an error for a missing migration step")
+	}
+}
+
+func TestLoadMigratesAndBacksUp(t *testing.T) {
+	fakeMigrations(t, prefixName)
+	path, original := copyFixture(t, "v1_workspace.yaml")
+
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if m.GetName() != "Migrated Current Workspace" {
+		t.Errorf("name = %q, want the migrated name", m.GetName())
+	}
+	if m.GetWorkspace().Version != 2 {
+		t.Errorf("Version = %d, want 2", m.GetWorkspace().Version)
+	}
+
+	found := backups(t, path)
+	if len(found) != 1 || !strings.Contains(found[0], ".v1-") {
+		t.Fatalf("backups = %v, want one v1 backup", found)
+	}
+	if data, _ := os.ReadFile(found[0]); string(data) != string(original) {
+		t.Error("backup does not hold the original file")
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if reloaded.GetName() != "Migrated Current Workspace" || reloaded.GetWorkspace().Version != 2 {
+		t.Errorf("rewritten file = %q version %d", reloaded.GetName(), reloaded.GetWorkspace().Version)
+	}
+	if len(backups(t, path)) != 1 {
+		t.Error("loading a migrated file should not back it up again")
+	}
+}
+
+func TestLoadFailedMigrationLeavesFileUntouched(t *testing.T) {
+	fakeMigrations(t, func(map[string]interface{}) error { return errors.New("boom") })
+	path, original := copyFixture(t, "v1_workspace.yaml")
+
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Load error = %v, want the migration error", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(original) {
+		t.Error("workspace file was modified by a failed migration")
+	}
+	if len(backups(t, path)) != 0 {
+		t.Error("failed migration should not leave a backup")
+	}
+}
+
+func TestLoadRefusesNewerWorkspace(t *testing.T) {
+	path, original := copyFixture(t, "v99_future.yaml")
+
+	_, err := Load(path)
+	if !errors.Is(err, ErrWorkspaceTooNew) {
+		t.Fatalf("Load error = %v, want ErrWorkspaceTooNew", err)
+	}
+	if !strings.Contains(err.Error(), "99") {
+		t.Errorf("error should name the file version: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != string(original) {
+		t.Error("newer workspace file was modified")
+	}
+	if len(backups(t, path)) != 0 {
+		t.Error("newer workspace should not be backed up")
+	}
+}
+
+func TestMigrationsAreContiguous(t *testing.T) {
+	version := 1
+	for i, step := range migrations {
+		if step.from != version {
+			t.Errorf("migration %d starts at version %d, want %d", i, step.from, version)
+		}
+		version = step.from + 1
+	}
+	if version != constants.WorkspaceVersion {
+		t.Errorf("migrations end at version %d, want %d", version, constants.WorkspaceVersion)
+	}
+}
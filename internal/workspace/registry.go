@@ -0,0 +1,242 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/config"
+	"github.com/android-lewis/dbsmith/internal/constants"
+	"github.com/android-lewis/dbsmith/internal/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvWorkspace selects the workspace to open when --workspace is not given.
+const EnvWorkspace = "DBSMITH_WORKSPACE"
+
+// ErrUnknownWorkspace is returned for a --workspace value that is neither a
+// registered name nor a path.
+var ErrUnknownWorkspace = errors.New("unknown workspace")
+
+// RegistryEntry is a workspace file the user has opened before.
+type RegistryEntry struct {
+	Name       string    `yaml:"name"`
+	Path       string    `yaml:"path"`
+	LastOpened time.Time `yaml:"last_opened"`
+}
+
+// Registry remembers known workspace files so they can be opened by name or
+// picked from the recent list.
+type Registry struct {
+	Workspaces []RegistryEntry `yaml:"workspaces"`
+
+	path    string
+	loaded  map[string]time.Time
+	removed map[string]bool
+}
+
+func GetDefaultRegistryPath() string {
+	path, err := config.GetConfigFilePath(constants.WorkspaceRegistryFileName)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// LoadRegistry reads the registry at path. A missing file is an empty
+// registry.
+func LoadRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace registry: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace registry: %w", err)
+	}
+	r.markLoaded()
+	return r, nil
+}
+
+// Save writes the registry under an advisory lock. Entries another instance
+// recorded since the registry was loaded are merged in first, so concurrent
+// instances do not drop each other's recent workspaces.
+func (r *Registry) Save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	lock, err := fileutil.Lock(r.path, fileutil.DefaultLockTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to lock workspace registry: %w", err)
+	}
+	defer func() { _ = lock.Unlock() }()
+
+	disk, err := LoadRegistry(r.path)
+	if err != nil {
+		return err
+	}
+	r.merge(disk.Workspaces)
+
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workspace registry: %w", err)
+	}
+
+	if err := fileutil.WriteFileAtomic(r.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write workspace registry: %w", err)
+	}
+
+	r.markLoaded()
+	r.removed = nil
+	return nil
+}
+
+// markLoaded records the entries as they are on disk, so merge can tell
+// entries another instance removed from ones it has not seen yet.
+func (r *Registry) markLoaded() {
+	r.loaded = make(map[string]time.Time, len(r.Workspaces))
+	for _, e := range r.Workspaces {
+		r.loaded[e.Path] = e.LastOpened
+	}
+}
+
+// merge adds the entries from disk that this registry lacks and takes the
+// newer of the two where both know a workspace. Workspaces removed on either
+// side since the registry was loaded stay removed.
+func (r *Registry) merge(disk []RegistryEntry) {
+	onDisk := make(map[string]bool, len(disk))
+	for _, e := range disk {
+		onDisk[e.Path] = true
+	}
+
+	kept := r.Workspaces[:0]
+	index := make(map[string]int, len(r.Workspaces))
+	for _, e := range r.Workspaces {
+		if opened, ok := r.loaded[e.Path]; ok && !onDisk[e.Path] && opened.Equal(e.LastOpened) {
+			continue
+		}
+		index[e.Path] = len(kept)
+		kept = append(kept, e)
+	}
+	r.Workspaces = kept
+
+	for _, e := range disk {
+		if r.removed[e.Path] {
+			continue
+		}
+		i, ok := index[e.Path]
+		switch {
+		case !ok:
+			index[e.Path] = len(r.Workspaces)
+			r.Workspaces = append(r.Workspaces, e)
+		case e.LastOpened.After(r.Workspaces[i].LastOpened):
+			r.Workspaces[i] = e
+		}
+	}
+}
+
+// Touch records that the workspace at path was opened now, adding it when it
+// is new.
+func (r *Registry) Touch(name, path string) {
+	now := time.Now()
+	delete(r.removed, path)
+	for i := range r.Workspaces {
+		if r.Workspaces[i].Path == path {
+			r.Workspaces[i].Name = name
+			r.Workspaces[i].LastOpened = now
+			return
+		}
+	}
+	r.Workspaces = append(r.Workspaces, RegistryEntry{Name: name, Path: path, LastOpened: now})
+}
+
+func (r *Registry) Remove(path string) {
+	if r.removed == nil {
+		r.removed = make(map[string]bool)
+	}
+	r.removed[path] = true
+	for i, e := range r.Workspaces {
+		if e.Path == path {
+			r.Workspaces = append(r.Workspaces[:i], r.Workspaces[i+1:]...)
+			return
+		}
+	}
+}
+
+// Recent returns the known workspaces, most recently opened first.
+func (r *Registry) Recent() []RegistryEntry {
+	entries := make([]RegistryEntry, len(r.Workspaces))
+	copy(entries, r.Workspaces)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastOpened.After(entries[j].LastOpened)
+	})
+	return entries
+}
+
+// Lookup finds a workspace by name, ignoring case. When several share a
+// name the most recently opened wins.
+func (r *Registry) Lookup(name string) (RegistryEntry, bool) {
+	for _, e := range r.Recent() {
+		if strings.EqualFold(e.Name, name) {
+			return e, true
+		}
+	}
+	return RegistryEntry{}, false
+}
+
+// ResolvePath turns a --workspace value into an absolute file path. The value
+// may be the name of a registered workspace or a path; an empty value selects
+// the default workspace. Only values with a path separator or a .yaml/.yml
+// extension are taken as paths, so a mistyped name is reported rather than
+// opened as a new file.
+func (r *Registry) ResolvePath(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		path := GetDefaultWorkspacePath()
+		if path == "" {
+			return "", errors.New("failed to locate default workspace")
+		}
+		return path, nil
+	}
+
+	if e, ok := r.Lookup(value); ok {
+		return e.Path, nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(value))
+	if !strings.ContainsAny(value, "/"+string(filepath.Separator)) && ext != ".yaml" && ext != ".yml" {
+		return "", fmt.Errorf("%w: %s", ErrUnknownWorkspace, value)
+	}
+	return AbsPath(value)
+}
+
+// AbsPath expands a leading ~ and makes path absolute.
+func AbsPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid workspace path %q: %w", path, err)
+	}
+	return abs, nil
+}
@@ -0,0 +1,133 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegistryMissingFile(t *testing.T) {
+	r, err := LoadRegistry(filepath.Join(t.TempDir(), "workspaces.yaml"))
+	if err != nil {
+		t.Fatalf("LoadRegistry: %v", err)
+	}
+	if len(r.Recent()) != 0 {
+		t.Errorf("expected empty registry, got %v", r.Recent())
+	}
+}
+
+func TestRegistryTouchAndRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "workspaces.yaml")
+	r, _ := LoadRegistry(path)
+
+	r.Touch("work", "/tmp/work.yaml")
+	r.Touch("home", "/tmp/home.yaml")
+	r.Workspaces[0].LastOpened = time.Now().Add(-time.Hour)
+	r.Touch("personal", "/tmp/home.yaml")
+
+	if len(r.Workspaces) != 2 {
+		t.Fatalf("got %d entries, want 2", len(r.Workspaces))
+	}
+	recent := r.Recent()
+	if recent[0].Name != "personal" || recent[1].Name != "work" {
+		t.Errorf("Recent order = %v", recent)
+	}
+
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry: %v", err)
+	}
+	if e, ok := loaded.Lookup("WORK"); !ok || e.Path != "/tmp/work.yaml" {
+		t.Errorf("Lookup(WORK) = %v, %v", e, ok)
+	}
+
+	loaded.Remove("/tmp/work.yaml")
+	if _, ok := loaded.Lookup("work"); ok {
+		t.Error("expected work to be removed")
+	}
+}
+
+func TestRegistrySaveMergesConcurrentInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workspaces.yaml")
+
+	seed, _ := LoadRegistry(path)
+	seed.Touch("shared", "/tmp/shared.yaml")
+	seed.Touch("old", "/tmp/old.yaml")
+	if err := seed.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	first, _ := LoadRegistry(path)
+	second, _ := LoadRegistry(path)
+
+	first.Touch("work", "/tmp/work.yaml")
+	first.Remove("/tmp/old.yaml")
+	if err := first.Save(); err != nil {
+		t.Fatalf("first Save: %v", err)
+	}
+
+	second.Touch("home", "/tmp/home.yaml")
+	second.Touch("renamed", "/tmp/shared.yaml")
+	if err := second.Save(); err != nil {
+		t.Fatalf("second Save: %v", err)
+	}
+
+	loaded, err := LoadRegistry(path)
+	if err != nil {
+		t.Fatalf("LoadRegistry: %v", err)
+	}
+	for _, name := range []string{"work", "home", "renamed"} {
+		if _, ok := loaded.Lookup(name); !ok {
+			t.Errorf("expected %s in the merged registry", name)
+		}
+	}
+	if _, ok := loaded.Lookup("shared"); ok {
+		t.Error("expected the newer name for /tmp/shared.yaml to win")
+	}
+	if _, ok := loaded.Lookup("old"); ok {
+		t.Error("expected an entry removed by another instance to stay removed")
+	}
+	if len(loaded.Workspaces) != 3 {
+		t.Errorf("got %d entries, want 3: %v", len(loaded.Workspaces), loaded.Workspaces)
+	}
+}
+
+func TestRegistryResolvePath(t *testing.T) {
+	r := &Registry{}
+	r.Touch("acme", "/srv/acme.yaml")
+
+	if got, _ := r.ResolvePath("acme"); got != "/srv/acme.yaml" {
+		t.Errorf("ResolvePath(acme) = %q", got)
+	}
+	if _, err := r.ResolvePath("acmee"); !errors.Is(err, ErrUnknownWorkspace) {
+		t.Errorf("ResolvePath(acmee) error = %v, want ErrUnknownWorkspace", err)
+	}
+	wd, _ := os.Getwd()
+	if got, _ := r.ResolvePath("ws/acmee"); got != filepath.Join(wd, "ws", "acmee") {
+		t.Errorf("ResolvePath(ws/acmee) = %q", got)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	if got, _ := r.ResolvePath("~/ws/other.yaml"); got != filepath.Join(home, "ws", "other.yaml") {
+		t.Errorf("ResolvePath(~/ws/other.yaml) = %q", got)
+	}
+
+	if got, _ := r.ResolvePath("local.yaml"); got != filepath.Join(wd, "local.yaml") {
+		t.Errorf("ResolvePath(local.yaml) = %q", got)
+	}
+	if got, _ := r.ResolvePath("team.YML"); got != filepath.Join(wd, "team.YML") {
+		t.Errorf("ResolvePath(team.YML) = %q", got)
+	}
+
+	if got, _ := r.ResolvePath(""); got != GetDefaultWorkspacePath() {
+		t.Errorf("ResolvePath(\"\") = %q, want default", got)
+	}
+}
@@ -0,0 +1,379 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+var (
+	ErrFolderExists   = errors.New("folder already exists")
+	ErrFolderNotFound = errors.New("folder not found")
+	ErrFolderNotEmpty = errors.New("folder is not empty")
+)
+
+// QueryFilter narrows saved queries. Text must match every word somewhere in
+// the name, description, folder, tags or SQL body; Tags must all be present;
+// Folder includes its subfolders.
+type QueryFilter struct {
+	Text   string
+	Tags   []string
+	Folder string
+}
+
+// ParseQueryFilter reads a search box query. Words written as tag:name or
+// #name become tag filters; everything else is free text.
+func ParseQueryFilter(text string) QueryFilter {
+	var filter QueryFilter
+	var words []string
+	for _, word := range strings.Fields(text) {
+		lower := strings.ToLower(word)
+		switch {
+		case strings.HasPrefix(lower, "tag:") && len(word) > len("tag:"):
+			filter.Tags = append(filter.Tags, word[len("tag:"):])
+		case strings.HasPrefix(word, "#") && len(word) > 1:
+			filter.Tags = append(filter.Tags, word[1:])
+		default:
+			words = append(words, word)
+		}
+	}
+	filter.Text = strings.Join(words, " ")
+	filter.Tags = NormalizeTags(filter.Tags)
+	return filter
+}
+
+// NormalizeFolder trims slashes and whitespace from each path segment and
+// drops empty segments, so " reports//monthly/ " becomes "reports/monthly".
+func NormalizeFolder(folder string) string {
+	var parts []string
+	for _, part := range strings.Split(folder, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// NormalizeTags lowercases, trims and de-duplicates tags, keeping their order.
+func NormalizeTags(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#")))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out
+}
+
+func inFolder(queryFolder, folder string) bool {
+	if folder == "" {
+		return true
+	}
+	return queryFolder == folder || strings.HasPrefix(queryFolder, folder+"/")
+}
+
+// ListFolders returns every folder, including empty folders and the parents
+// of nested folders, sorted by path.
+func (m *Manager) ListFolders() []string {
+	set := make(map[string]bool)
+	add := func(folder string) {
+		folder = NormalizeFolder(folder)
+		for folder != "" {
+			set[folder] = true
+			i := strings.LastIndex(folder, "/")
+			if i < 0 {
+				break
+			}
+			folder = folder[:i]
+		}
+	}
+
+	for _, f := range m.workspace.Folders {
+		add(f)
+	}
+	if m.shared != nil {
+		for _, f := range m.shared.Folders {
+			add(f)
+		}
+	}
+	for _, q := range m.savedQueries() {
+		add(q.Folder)
+	}
+
+	folders := make([]string, 0, len(set))
+	for f := range set {
+		folders = append(folders, f)
+	}
+	sort.Strings(folders)
+	return folders
+}
+
+func (m *Manager) hasFolder(folder string) bool {
+	for _, f := range m.ListFolders() {
+		if f == folder {
+			return true
+		}
+	}
+	return false
+}
+
+// keepFolder records folder so it survives after its last query moves out.
+func (m *Manager) keepFolder(folder string) {
+	if folder == "" {
+		return
+	}
+	for _, f := range m.workspace.Folders {
+		if NormalizeFolder(f) == folder {
+			return
+		}
+	}
+	m.workspace.Folders = append(m.workspace.Folders, folder)
+}
+
+// CreateFolder adds an empty folder so it shows up before any query is saved
+// into it.
+func (m *Manager) CreateFolder(folder string) error {
+	folder = NormalizeFolder(folder)
+	if folder == "" {
+		return errors.New("folder name is required")
+	}
+	if m.hasFolder(folder) {
+		return fmt.Errorf("%w: %s", ErrFolderExists, folder)
+	}
+
+	m.keepFolder(folder)
+	m.workspace.LastModified = time.Now()
+	return m.autoSave()
+}
+
+// RenameFolder moves a folder, its subfolders and their queries to newName.
+func (m *Manager) RenameFolder(oldName, newName string) error {
+	oldName = NormalizeFolder(oldName)
+	newName = NormalizeFolder(newName)
+	if oldName == "" || newName == "" {
+		return errors.New("folder name is required")
+	}
+	if !m.hasFolder(oldName) {
+		return fmt.Errorf("%w: %s", ErrFolderNotFound, oldName)
+	}
+	if oldName == newName {
+		return nil
+	}
+	if m.hasFolder(newName) {
+		return fmt.Errorf("%w: %s", ErrFolderExists, newName)
+	}
+	if inFolder(newName, oldName) {
+		return fmt.Errorf("cannot move folder %s into itself", oldName)
+	}
+	if m.sharedFolderUsed(oldName) {
+		return fmt.Errorf("%w: folder %s", ErrSharedReadOnly, oldName)
+	}
+
+	rename := func(folder string) string {
+		if !inFolder(folder, oldName) {
+			return folder
+		}
+		return newName + strings.TrimPrefix(folder, oldName)
+	}
+
+	for i, f := range m.workspace.Folders {
+		m.workspace.Folders[i] = rename(NormalizeFolder(f))
+	}
+	for i := range m.workspace.SavedQueries {
+		if q := &m.workspace.SavedQueries[i]; q.Folder != "" {
+			q.Folder = rename(NormalizeFolder(q.Folder))
+		}
+	}
+
+	m.workspace.LastModified = time.Now()
+	return m.autoSave()
+}
+
+// DeleteFolder removes a folder and its subfolders. Folders that still hold
+// queries cannot be deleted.
+func (m *Manager) DeleteFolder(folder string) error {
+	folder = NormalizeFolder(folder)
+	if !m.hasFolder(folder) {
+		return fmt.Errorf("%w: %s", ErrFolderNotFound, folder)
+	}
+	for _, q := range m.savedQueries() {
+		if q.Folder != "" && inFolder(NormalizeFolder(q.Folder), folder) {
+			return fmt.Errorf("%w: %s", ErrFolderNotEmpty, folder)
+		}
+	}
+	if m.sharedFolderUsed(folder) {
+		return fmt.Errorf("%w: folder %s", ErrSharedReadOnly, folder)
+	}
+
+	kept := m.workspace.Folders[:0]
+	for _, f := range m.workspace.Folders {
+		if !inFolder(NormalizeFolder(f), folder) {
+			kept = append(kept, f)
+		}
+	}
+	m.workspace.Folders = kept
+
+	m.workspace.LastModified = time.Now()
+	return m.autoSave()
+}
+
+// MoveSavedQuery puts a query into folder; an empty folder moves it to the
+// root.
+func (m *Manager) MoveSavedQuery(id, folder string) error {
+	q, err := m.GetSavedQuery(id)
+	if err != nil {
+		return err
+	}
+
+	moved := *q
+	m.keepFolder(NormalizeFolder(q.Folder))
+	moved.Folder = NormalizeFolder(folder)
+	m.keepFolder(moved.Folder)
+	m.putSavedQuery(moved)
+	return m.autoSave()
+}
+
+// sharedFolderUsed reports whether the shared workspace defines folder or
+// keeps queries in it, which makes it impossible to rename or delete.
+func (m *Manager) sharedFolderUsed(folder string) bool {
+	if m.shared == nil {
+		return false
+	}
+	for _, f := range m.shared.Folders {
+		if inFolder(NormalizeFolder(f), folder) {
+			return true
+		}
+	}
+	for _, q := range m.shared.SavedQueries {
+		if q.Folder != "" && inFolder(q.Folder, folder) && m.personalQueryIndex(q.ID) < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ListTags returns every tag used by a saved query, sorted.
+func (m *Manager) ListTags() []string {
+	set := make(map[string]bool)
+	for _, q := range m.savedQueries() {
+		for _, tag := range NormalizeTags(q.Tags) {
+			set[tag] = true
+		}
+	}
+
+	tags := make([]string, 0, len(set))
+	for tag := range set {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// FilterSavedQueries returns the queries matching filter, in workspace order.
+func (m *Manager) FilterSavedQueries(filter QueryFilter) []models.SavedQuery {
+	folder := NormalizeFolder(filter.Folder)
+	wantTags := NormalizeTags(filter.Tags)
+	words := strings.Fields(filter.Text)
+
+	var results []models.SavedQuery
+	for _, q := range m.savedQueries() {
+		if !inFolder(NormalizeFolder(q.Folder), folder) {
+			continue
+		}
+		if !hasAllTags(q.Tags, wantTags) {
+			continue
+		}
+		if !matchesAllWords(q, words) {
+			continue
+		}
+		results = append(results, q)
+	}
+	return results
+}
+
+func hasAllTags(have, want []string) bool {
+	set := make(map[string]bool)
+	for _, tag := range NormalizeTags(have) {
+		set[tag] = true
+	}
+	for _, tag := range want {
+		if !set[tag] {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesAllWords(q models.SavedQuery, words []string) bool {
+	haystack := strings.Join([]string{
+		q.Name, q.Description, q.Folder, strings.Join(q.Tags, " "), q.SQL,
+	}, "\n")
+	for _, word := range words {
+		if !matchesString(haystack, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// RecordQueryExecution counts a run of a saved query on connectionName.
+func (m *Manager) RecordQueryExecution(queryID, connectionName string) error {
+	if _, err := m.GetSavedQuery(queryID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	usage := m.usageFor(queryID)
+	usage.ExecutionCount++
+	usage.LastExecutedAt = now
+
+	if connectionName != "" {
+		if usage.Connections == nil {
+			usage.Connections = make(map[string]models.ConnectionUsage)
+		}
+		cu := usage.Connections[connectionName]
+		cu.ExecutionCount++
+		cu.LastExecutedAt = now
+		usage.Connections[connectionName] = cu
+	}
+
+	return m.autoSave()
+}
+
+// GetQueryUsage returns the usage recorded for a saved query. Queries that
+// never ran get a zero value.
+func (m *Manager) GetQueryUsage(queryID string) models.QueryUsage {
+	for _, u := range m.workspace.QueryUsage {
+		if u.QueryID == queryID {
+			return u
+		}
+	}
+	return models.QueryUsage{QueryID: queryID}
+}
+
+func (m *Manager) usageFor(queryID string) *models.QueryUsage {
+	for i := range m.workspace.QueryUsage {
+		if m.workspace.QueryUsage[i].QueryID == queryID {
+			return &m.workspace.QueryUsage[i]
+		}
+	}
+	m.workspace.QueryUsage = append(m.workspace.QueryUsage, models.QueryUsage{QueryID: queryID})
+	return &m.workspace.QueryUsage[len(m.workspace.QueryUsage)-1]
+}
+
+func (m *Manager) removeQueryUsage(queryID string) {
+	for i, u := range m.workspace.QueryUsage {
+		if u.QueryID == queryID {
+			m.workspace.QueryUsage = append(m.workspace.QueryUsage[:i], m.workspace.QueryUsage[i+1:]...)
+			return
+		}
+	}
+}
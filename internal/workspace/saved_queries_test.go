@@ -0,0 +1,201 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+func newQueryManager(t *testing.T) *Manager {
+	t.Helper()
+	m := New()
+	queries := []models.SavedQuery{
+		{ID: "q1", Name: "Active users", SQL: "SELECT * FROM users WHERE active", Folder: "reports", Tags: []string{"Users", "#daily"}},
+		{ID: "q2", Name: "Monthly revenue", SQL: "SELECT sum(total) FROM orders", Folder: "reports/finance", Tags: []string{"finance"}},
+		{ID: "q3", Name: "Cleanup", SQL: "DELETE FROM sessions WHERE expired", Description: "nightly job", Tags: []string{"daily"}},
+	}
+	for _, q := range queries {
+		if err := m.AddSavedQuery(q); err != nil {
+			t.Fatalf("AddSavedQuery(%s): %v", q.ID, err)
+		}
+	}
+	return m
+}
+
+func queryIDs(queries []models.SavedQuery) []string {
+	ids := make([]string, 0, len(queries))
+	for _, q := range queries {
+		ids = append(ids, q.ID)
+	}
+	return ids
+}
+
+func TestNormalizeFolderAndTags(t *testing.T) {
+	if got := NormalizeFolder(" reports//monthly/ "); got != "reports/monthly" {
+		t.Errorf("NormalizeFolder = %q", got)
+	}
+	got := NormalizeTags([]string{"Users", " #daily", "users", ""})
+	if want := []string{"users", "daily"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTags = %v, want %v", got, want)
+	}
+}
+
+func TestParseQueryFilter(t *testing.T) {
+	got := ParseQueryFilter("  users tag:Daily  #finance active #")
+	want := QueryFilter{Text: "users active #", Tags: []string{"daily", "finance"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseQueryFilter = %+v, want %+v", got, want)
+	}
+}
+
+func TestFilterSavedQueries(t *testing.T) {
+	m := newQueryManager(t)
+
+	tests := []struct {
+		name   string
+		filter QueryFilter
+		want   []string
+	}{
+		{"empty", QueryFilter{}, []string{"q1", "q2", "q3"}},
+		{"sql body", QueryFilter{Text: "orders"}, []string{"q2"}},
+		{"description", QueryFilter{Text: "NIGHTLY"}, []string{"q3"}},
+		{"all words", QueryFilter{Text: "select users"}, []string{"q1"}},
+		{"tag", QueryFilter{Tags: []string{"daily"}}, []string{"q1", "q3"}},
+		{"tags and text", QueryFilter{Text: "delete", Tags: []string{"#Daily"}}, []string{"q3"}},
+		{"folder includes subfolders", QueryFilter{Folder: "reports"}, []string{"q1", "q2"}},
+		{"subfolder", QueryFilter{Folder: "reports/finance/"}, []string{"q2"}},
+		{"folder prefix is not a parent", QueryFilter{Folder: "rep"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := queryIDs(m.FilterSavedQueries(tt.filter))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListFoldersAndTags(t *testing.T) {
+	m := newQueryManager(t)
+	if err := m.CreateFolder("scratch/tmp"); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+
+	want := []string{"reports", "reports/finance", "scratch", "scratch/tmp"}
+	if got := m.ListFolders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFolders = %v, want %v", got, want)
+	}
+	if err := m.CreateFolder("reports"); !errors.Is(err, ErrFolderExists) {
+		t.Errorf("CreateFolder existing = %v, want ErrFolderExists", err)
+	}
+
+	wantTags := []string{"daily", "finance", "users"}
+	if got := m.ListTags(); !reflect.DeepEqual(got, wantTags) {
+		t.Errorf("ListTags = %v, want %v", got, wantTags)
+	}
+}
+
+func TestRenameFolder(t *testing.T) {
+	m := newQueryManager(t)
+	_ = m.CreateFolder("reports/empty")
+
+	if err := m.RenameFolder("reports", "analytics"); err != nil {
+		t.Fatalf("RenameFolder: %v", err)
+	}
+
+	want := []string{"analytics", "analytics/empty", "analytics/finance"}
+	if got := m.ListFolders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFolders = %v, want %v", got, want)
+	}
+	q, _ := m.GetSavedQuery("q2")
+	if q.Folder != "analytics/finance" {
+		t.Errorf("q2 folder = %q", q.Folder)
+	}
+
+	if err := m.RenameFolder("analytics", "analytics/nested"); err == nil {
+		t.Error("expected error moving a folder into itself")
+	}
+	if err := m.RenameFolder("missing", "x"); !errors.Is(err, ErrFolderNotFound) {
+		t.Errorf("RenameFolder missing = %v, want ErrFolderNotFound", err)
+	}
+}
+
+func TestDeleteFolderAndMove(t *testing.T) {
+	m := newQueryManager(t)
+
+	if err := m.DeleteFolder("reports"); !errors.Is(err, ErrFolderNotEmpty) {
+		t.Errorf("DeleteFolder non-empty = %v, want ErrFolderNotEmpty", err)
+	}
+
+	_ = m.CreateFolder("archive")
+	if err := m.MoveSavedQuery("q2", "archive"); err != nil {
+		t.Fatalf("MoveSavedQuery: %v", err)
+	}
+	if err := m.DeleteFolder("reports/finance"); err != nil {
+		t.Errorf("DeleteFolder emptied folder: %v", err)
+	}
+	if err := m.DeleteFolder("archive"); !errors.Is(err, ErrFolderNotEmpty) {
+		t.Errorf("DeleteFolder archive = %v, want ErrFolderNotEmpty", err)
+	}
+
+	if err := m.MoveSavedQuery("q2", ""); err != nil {
+		t.Fatalf("MoveSavedQuery to root: %v", err)
+	}
+	if err := m.DeleteFolder("archive"); err != nil {
+		t.Errorf("DeleteFolder archive: %v", err)
+	}
+
+	want := []string{"reports"}
+	if got := m.ListFolders(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ListFolders = %v, want %v", got, want)
+	}
+}
+
+func TestRecordQueryExecution(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "workspace-*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer func() {
+		_ = os.Remove(tmpfile.Name())
+	}()
+
+	m := newQueryManager(t)
+	if err := m.Save(tmpfile.Name()); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	_ = m.RecordQueryExecution("q1", "prod")
+	_ = m.RecordQueryExecution("q1", "prod")
+	_ = m.RecordQueryExecution("q1", "local")
+	if err := m.RecordQueryExecution("missing", "prod"); err == nil {
+		t.Error("expected error recording an unknown query")
+	}
+
+	loaded, err := Load(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	usage := loaded.GetQueryUsage("q1")
+	if usage.ExecutionCount != 3 {
+		t.Errorf("ExecutionCount = %d, want 3", usage.ExecutionCount)
+	}
+	if usage.Connections["prod"].ExecutionCount != 2 || usage.Connections["local"].ExecutionCount != 1 {
+		t.Errorf("Connections = %+v", usage.Connections)
+	}
+	if usage.LastExecutedAt.IsZero() {
+		t.Error("LastExecutedAt should be set")
+	}
+
+	if err := loaded.DeleteSavedQuery("q1"); err != nil {
+		t.Fatalf("DeleteSavedQuery: %v", err)
+	}
+	if got := loaded.GetQueryUsage("q1"); got.ExecutionCount != 0 {
+		t.Errorf("usage kept after delete: %+v", got)
+	}
+}
@@ -0,0 +1,518 @@
+package workspace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/android-lewis/dbsmith/internal/fileutil"
+	"github.com/android-lewis/dbsmith/internal/logging"
+	"github.com/android-lewis/dbsmith/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrSharedReadOnly is returned when deleting or renaming something that
+// comes from the shared workspace. Edits to shared entries are saved as
+// personal overrides instead.
+var ErrSharedReadOnly = errors.New("entry belongs to the shared workspace")
+
+// Origin tells which layer a connection or saved query comes from.
+type Origin string
+
+const (
+	OriginPersonal Origin = "personal"
+	OriginShared   Origin = "shared"
+	// OriginOverride is a personal entry replacing a shared one of the same
+	// name or ID. Deleting it brings the shared entry back.
+	OriginOverride Origin = "override"
+)
+
+// SharedConnectionsFile holds the connections, folders and snippets of a
+// shared workspace directory; every other entry is a .sql file.
+const SharedConnectionsFile = "connections.yaml"
+
+// frontMatterFence opens and closes the metadata block at the top of a shared
+// .sql file. The block is YAML with each line commented out, so the file
+// still runs as plain SQL:
+//
+//	-- ---
+//	-- name: Active users
+//	-- tags: [reports]
+//	-- ---
+//	SELECT ...
+const frontMatterFence = "-- ---"
+
+// sharedFile is the layout of a shared workspace YAML file. It carries no
+// secrets, timestamps or usage so that it diffs cleanly under version
+// control.
+type sharedFile struct {
+	Connections  []models.Connection `yaml:"connections,omitempty"`
+	SavedQueries []models.SavedQuery `yaml:"saved_queries,omitempty"`
+	Folders      []string            `yaml:"folders,omitempty"`
+	Snippets     []models.Snippet    `yaml:"snippets,omitempty"`
+}
+
+type queryFrontMatter struct {
+	ID          string   `yaml:"id,omitempty"`
+	Name        string   `yaml:"name,omitempty"`
+	Description string   `yaml:"description,omitempty"`
+	Folder      string   `yaml:"folder,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+}
+
+// loadShared reads a shared workspace from a YAML file or a directory.
+func loadShared(sharedPath string) (*sharedFile, error) {
+	info, err := os.Stat(sharedPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shared workspace: %w", err)
+	}
+	if info.IsDir() {
+		return loadSharedDir(sharedPath)
+	}
+
+	data, err := os.ReadFile(sharedPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shared workspace: %w", err)
+	}
+	sf := &sharedFile{}
+	if err := yaml.Unmarshal(data, sf); err != nil {
+		return nil, fmt.Errorf("failed to parse shared workspace %s: %w", sharedPath, err)
+	}
+	sf.normalize()
+	return sf, nil
+}
+
+// loadSharedDir reads connections.yaml and every .sql file below dir. A
+// file's directory is its folder unless the front-matter names one.
+func loadSharedDir(dir string) (*sharedFile, error) {
+	sf := &sharedFile{}
+
+	data, err := os.ReadFile(filepath.Join(dir, SharedConnectionsFile))
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, sf); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", SharedConnectionsFile, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to read shared workspace: %w", err)
+	}
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(p), ".sql") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read shared query: %w", err)
+		}
+		q, err := parseQueryFile(filepath.ToSlash(rel), data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		sf.SavedQueries = append(sf.SavedQueries, q)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shared workspace: %w", err)
+	}
+
+	sf.normalize()
+	return sf, nil
+}
+
+// parseQueryFile turns a shared .sql file into a saved query. rel is its
+// slash-separated path inside the shared directory and provides the default
+// ID, name and folder.
+func parseQueryFile(rel string, data []byte) (models.SavedQuery, error) {
+	var meta queryFrontMatter
+	sql := string(data)
+
+	lines := strings.SplitAfter(sql, "\n")
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == frontMatterFence {
+		var block strings.Builder
+		end := -1
+		for i := 1; i < len(lines); i++ {
+			line := strings.TrimSpace(lines[i])
+			if line == frontMatterFence {
+				end = i
+				break
+			}
+			line = strings.TrimRight(strings.TrimPrefix(lines[i], "--"), "\r\n")
+			block.WriteString(strings.TrimPrefix(line, " "))
+			block.WriteString("\n")
+		}
+		if end < 0 {
+			return models.SavedQuery{}, errors.New("front-matter is not closed")
+		}
+		if err := yaml.Unmarshal([]byte(block.String()), &meta); err != nil {
+			return models.SavedQuery{}, fmt.Errorf("invalid front-matter: %w", err)
+		}
+		sql = strings.Join(lines[end+1:], "")
+	}
+
+	base := strings.TrimSuffix(rel, path.Ext(rel))
+	q := models.SavedQuery{
+		ID:          meta.ID,
+		Name:        meta.Name,
+		SQL:         strings.TrimSpace(sql),
+		Description: meta.Description,
+		Folder:      meta.Folder,
+		Tags:        meta.Tags,
+	}
+	if q.ID == "" {
+		q.ID = base
+	}
+	if q.Name == "" {
+		q.Name = path.Base(base)
+	}
+	if q.Folder == "" {
+		if dir := path.Dir(rel); dir != "." {
+			q.Folder = dir
+		}
+	}
+	return q, nil
+}
+
+// formatQueryFile writes q in the layout parseQueryFile reads.
+func formatQueryFile(q models.SavedQuery) ([]byte, error) {
+	meta, err := yaml.Marshal(queryFrontMatter{
+		ID:          q.ID,
+		Name:        q.Name,
+		Description: q.Description,
+		Tags:        q.Tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(frontMatterFence + "\n")
+	for _, line := range strings.Split(strings.TrimRight(string(meta), "\n"), "\n") {
+		buf.WriteString("-- " + line + "\n")
+	}
+	buf.WriteString(frontMatterFence + "\n")
+	buf.WriteString(strings.TrimSpace(q.SQL) + "\n")
+	return buf.Bytes(), nil
+}
+
+var connectionTypeAliases = map[string]models.ConnectionType{
+	"postgres":   models.PostgresType,
+	"postgresql": models.PostgresType,
+	"mysql":      models.MySQLType,
+	"mariadb":    models.MySQLType,
+	"sqlite":     models.SQLiteType,
+	"sqlite3":    models.SQLiteType,
+}
+
+// normalize strips anything that must not live in a shared workspace and
+// sorts entries so exports are stable.
+func (sf *sharedFile) normalize() {
+	for i := range sf.Connections {
+		c := &sf.Connections[i]
+		if canonical, ok := connectionTypeAliases[strings.ToLower(strings.TrimSpace(string(c.Type)))]; ok {
+			c.Type = canonical
+		}
+		c.SecretKeyID = ""
+		c.CreatedAt = time.Time{}
+		c.LastModified = time.Time{}
+	}
+	for i := range sf.SavedQueries {
+		q := &sf.SavedQueries[i]
+		q.Folder = NormalizeFolder(q.Folder)
+		q.Tags = NormalizeTags(q.Tags)
+		q.CreatedAt = time.Time{}
+		q.LastModified = time.Time{}
+	}
+	for i, f := range sf.Folders {
+		sf.Folders[i] = NormalizeFolder(f)
+	}
+
+	sort.SliceStable(sf.Connections, func(i, j int) bool {
+		return sf.Connections[i].Name < sf.Connections[j].Name
+	})
+	sort.SliceStable(sf.SavedQueries, func(i, j int) bool {
+		a, b := sf.SavedQueries[i], sf.SavedQueries[j]
+		if a.Folder != b.Folder {
+			return a.Folder < b.Folder
+		}
+		return a.ID < b.ID
+	})
+	sort.Strings(sf.Folders)
+	sort.SliceStable(sf.Snippets, func(i, j int) bool {
+		return SnippetKey(sf.Snippets[i]) < SnippetKey(sf.Snippets[j])
+	})
+}
+
+// SharedPath returns the resolved path of the shared workspace, or "" when
+// none is configured.
+func (m *Manager) SharedPath() string {
+	shared := m.workspace.Shared
+	if shared == "" {
+		return ""
+	}
+	if strings.HasPrefix(shared, "~") || filepath.IsAbs(shared) || m.filePath == "" {
+		if abs, err := AbsPath(shared); err == nil {
+			return abs
+		}
+		return shared
+	}
+	return filepath.Join(filepath.Dir(m.filePath), shared)
+}
+
+// SetSharedPath layers the shared workspace at sharedPath under this one.
+// An empty path removes the shared layer.
+func (m *Manager) SetSharedPath(sharedPath string) error {
+	previous := m.workspace.Shared
+	m.workspace.Shared = sharedPath
+	if err := m.loadSharedLayer(); err != nil {
+		m.workspace.Shared = previous
+		_ = m.loadSharedLayer()
+		return err
+	}
+	m.workspace.LastModified = time.Now()
+	return m.autoSave()
+}
+
+// loadSharedLayer (re)reads the shared workspace configured in the personal
+// file.
+func (m *Manager) loadSharedLayer() error {
+	m.shared = nil
+	sharedPath := m.SharedPath()
+	if sharedPath == "" {
+		return nil
+	}
+	sf, err := loadShared(sharedPath)
+	if err != nil {
+		return err
+	}
+	m.shared = sf
+	return nil
+}
+
+// ReloadShared re-reads the shared workspace, for example after a git pull.
+func (m *Manager) ReloadShared() error {
+	return m.loadSharedLayer()
+}
+
+// HasShared reports whether a shared workspace is layered under this one.
+func (m *Manager) HasShared() bool {
+	return m.shared != nil
+}
+
+// ConnectionOrigin reports which layer the named connection comes from.
+func (m *Manager) ConnectionOrigin(name string) Origin {
+	personal := m.personalConnectionIndex(name) >= 0
+	shared := m.sharedConnectionIndex(name) >= 0
+	return origin(personal, shared)
+}
+
+// QueryOrigin reports which layer the saved query with id comes from.
+func (m *Manager) QueryOrigin(id string) Origin {
+	personal := m.personalQueryIndex(id) >= 0
+	shared := m.sharedQueryIndex(id) >= 0
+	return origin(personal, shared)
+}
+
+func origin(personal, shared bool) Origin {
+	switch {
+	case personal && shared:
+		return OriginOverride
+	case shared:
+		return OriginShared
+	default:
+		return OriginPersonal
+	}
+}
+
+func (m *Manager) personalConnectionIndex(name string) int {
+	for i, c := range m.workspace.Connections {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Manager) sharedConnectionIndex(name string) int {
+	if m.shared == nil {
+		return -1
+	}
+	for i, c := range m.shared.Connections {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Manager) personalQueryIndex(id string) int {
+	for i, q := range m.workspace.SavedQueries {
+		if q.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Manager) sharedQueryIndex(id string) int {
+	if m.shared == nil {
+		return -1
+	}
+	for i, q := range m.shared.SavedQueries {
+		if q.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// connections returns shared connections, replaced by personal overrides,
+// followed by personal-only connections.
+func (m *Manager) connections() []models.Connection {
+	if m.shared == nil {
+		return m.workspace.Connections
+	}
+	return layer(m.shared.Connections, m.workspace.Connections,
+		func(c models.Connection) string { return c.Name })
+}
+
+// savedQueries layers saved queries the same way connections does.
+func (m *Manager) savedQueries() []models.SavedQuery {
+	if m.shared == nil {
+		return m.workspace.SavedQueries
+	}
+	return layer(m.shared.SavedQueries, m.workspace.SavedQueries,
+		func(q models.SavedQuery) string { return q.ID })
+}
+
+func layer[T any](shared, personal []T, key func(T) string) []T {
+	overrides := make(map[string]T, len(personal))
+	for _, item := range personal {
+		overrides[key(item)] = item
+	}
+
+	out := make([]T, 0, len(shared)+len(personal))
+	seen := make(map[string]bool, len(shared))
+	for _, item := range shared {
+		k := key(item)
+		seen[k] = true
+		if o, ok := overrides[k]; ok {
+			item = o
+		}
+		out = append(out, item)
+	}
+	for _, item := range personal {
+		if !seen[key(item)] {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// ExportShared writes every connection, saved query, folder and snippet of the
+// layered workspace as a shared workspace, without secrets, timestamps or
+// usage. A path ending in a separator, or an existing directory, gets the
+// directory layout; anything else is written as one YAML file.
+func (m *Manager) ExportShared(target string) error {
+	sf := &sharedFile{
+		Connections:  m.ListConnections(),
+		SavedQueries: m.ListSavedQueries(),
+		Folders:      m.ListFolders(),
+		Snippets:     m.ListSnippets(),
+	}
+	sf.normalize()
+
+	if strings.HasSuffix(target, "/") || strings.HasSuffix(target, string(filepath.Separator)) {
+		return exportSharedDir(target, sf)
+	}
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		return exportSharedDir(target, sf)
+	}
+
+	data, err := yaml.Marshal(sf)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shared workspace: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(target, data, 0644); err != nil {
+		return fmt.Errorf("failed to write shared workspace: %w", err)
+	}
+	return nil
+}
+
+func exportSharedDir(dir string, sf *sharedFile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create shared workspace directory: %w", err)
+	}
+
+	data, err := yaml.Marshal(sharedFile{Connections: sf.Connections, Folders: sf.Folders, Snippets: sf.Snippets})
+	if err != nil {
+		return fmt.Errorf("failed to marshal shared workspace: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(filepath.Join(dir, SharedConnectionsFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write shared workspace: %w", err)
+	}
+
+	used := make(map[string]bool)
+	for _, q := range sf.SavedQueries {
+		rel := path.Join(q.Folder, queryFileName(q, used))
+		data, err := formatQueryFile(q)
+		if err != nil {
+			return fmt.Errorf("failed to format query %s: %w", q.ID, err)
+		}
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return fmt.Errorf("failed to create folder for %s: %w", q.ID, err)
+		}
+		if err := fileutil.WriteFileAtomic(p, data, 0644); err != nil {
+			return fmt.Errorf("failed to write query %s: %w", q.ID, err)
+		}
+	}
+
+	logging.Info().Str("shared_path", dir).Int("queries", len(sf.SavedQueries)).Msg("Exported shared workspace")
+	return nil
+}
+
+// queryFileName picks a file name for q from its name, unique within used.
+func queryFileName(q models.SavedQuery, used map[string]bool) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(q.Name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	slug := strings.Trim(b.String(), "-")
+	if slug == "" {
+		slug = "query"
+	}
+
+	name := slug
+	for n := 2; used[path.Join(q.Folder, name)]; n++ {
+		name = fmt.Sprintf("%s-%d", slug, n)
+	}
+	used[path.Join(q.Folder, name)] = true
+	return name + ".sql"
+}
@@ -0,0 +1,230 @@
+package workspace
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+const sharedYAML = `connections:
+  - name: analytics
+    type: postgresql
+    host: db.internal
+    database: analytics
+saved_queries:
+  - id: daily
+    name: Daily signups
+    sql: SELECT count(*) FROM users
+    folder: reports
+folders:
+  - reports
+`
+
+// layeredWorkspace writes a personal workspace next to shared.yaml and loads
+// it.
+func layeredWorkspace(t *testing.T) (*Manager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	sharedPath := filepath.Join(dir, "shared.yaml")
+	if err := os.WriteFile(sharedPath, []byte(sharedYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	personal := New()
+	personal.SetName("mine")
+	personal.GetWorkspace().Shared = "shared.yaml"
+	_ = personal.AddConnection(models.Connection{Name: "local", Type: models.SQLiteType, Database: "dev.db"})
+	wsPath := filepath.Join(dir, "workspace.yaml")
+	if err := personal.Save(wsPath); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Load(wsPath)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return m, sharedPath
+}
+
+func TestSharedLayerIsMerged(t *testing.T) {
+	m, _ := layeredWorkspace(t)
+
+	if !m.HasShared() {
+		t.Fatal("shared layer was not loaded")
+	}
+	var names []string
+	for _, c := range m.ListConnections() {
+		names = append(names, c.Name)
+	}
+	if want := []string{"analytics", "local"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("connections = %v, want %v", names, want)
+	}
+	if c, _ := m.GetConnection("analytics"); c.Type != models.PostgresType {
+		t.Errorf("shared connection type = %q, want postgres", c.Type)
+	}
+	if got := m.ConnectionOrigin("analytics"); got != OriginShared {
+		t.Errorf("analytics origin = %q", got)
+	}
+	if got := m.ConnectionOrigin("local"); got != OriginPersonal {
+		t.Errorf("local origin = %q", got)
+	}
+	if got := m.FilterSavedQueries(QueryFilter{Folder: "reports"}); len(got) != 1 || got[0].ID != "daily" {
+		t.Errorf("reports folder = %v", got)
+	}
+}
+
+func TestSharedEditsBecomeOverrides(t *testing.T) {
+	m, sharedPath := layeredWorkspace(t)
+	before, _ := os.ReadFile(sharedPath)
+
+	conn, _ := m.GetConnection("analytics")
+	edited := *conn
+	edited.Username = "me"
+	edited.SecretKeyID = "dbsmith-analytics"
+	if err := m.UpdateConnection(edited); err != nil {
+		t.Fatalf("UpdateConnection: %v", err)
+	}
+	if err := m.MoveSavedQuery("daily", "mine"); err != nil {
+		t.Fatalf("MoveSavedQuery: %v", err)
+	}
+
+	if after, _ := os.ReadFile(sharedPath); string(after) != string(before) {
+		t.Error("shared file was modified")
+	}
+	if got := m.ConnectionOrigin("analytics"); got != OriginOverride {
+		t.Errorf("origin after edit = %q, want override", got)
+	}
+	if got := m.QueryOrigin("daily"); got != OriginOverride {
+		t.Errorf("query origin after move = %q, want override", got)
+	}
+	if len(m.ListConnections()) != 2 {
+		t.Errorf("override duplicated the connection: %v", m.ListConnections())
+	}
+
+	reloaded, err := Load(m.GetFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := reloaded.GetConnection("analytics"); c.Username != "me" {
+		t.Errorf("override was not saved: %+v", c)
+	}
+
+	if err := m.DeleteConnection("analytics"); err != nil {
+		t.Fatalf("deleting the override: %v", err)
+	}
+	if c, _ := m.GetConnection("analytics"); c.Username != "" {
+		t.Error("deleting the override did not restore the shared connection")
+	}
+	if err := m.DeleteConnection("analytics"); !errors.Is(err, ErrSharedReadOnly) {
+		t.Errorf("deleting a shared connection: %v, want ErrSharedReadOnly", err)
+	}
+	if err := m.RenameFolder("reports", "old-reports"); !errors.Is(err, ErrSharedReadOnly) {
+		t.Errorf("renaming a shared folder: %v, want ErrSharedReadOnly", err)
+	}
+}
+
+func TestMissingSharedLayerIsIgnored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workspace.yaml")
+	m := New()
+	m.GetWorkspace().Shared = "team/missing.yaml"
+	_ = m.AddConnection(models.Connection{Name: "local", Type: models.SQLiteType})
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.HasShared() || len(loaded.ListConnections()) != 1 {
+		t.Error("expected only the personal layer")
+	}
+	if want := filepath.Join(filepath.Dir(path), "team", "missing.yaml"); loaded.SharedPath() != want {
+		t.Errorf("SharedPath = %q, want %q", loaded.SharedPath(), want)
+	}
+	if err := loaded.SetSharedPath("also-missing.yaml"); err == nil {
+		t.Error("SetSharedPath should fail for a missing shared workspace")
+	}
+}
+
+func TestParseQueryFile(t *testing.T) {
+	data := "-- ---\r\n-- name: Active users\r\n-- tags:\r\n--   - Reports\r\n-- ---\r\nSELECT *\r\nFROM users\r\n"
+	q, err := parseQueryFile("ops/active.sql", []byte(data))
+	if err != nil {
+		t.Fatalf("parseQueryFile: %v", err)
+	}
+	want := models.SavedQuery{
+		ID:     "ops/active",
+		Name:   "Active users",
+		SQL:    "SELECT *\r\nFROM users",
+		Folder: "ops",
+		Tags:   []string{"Reports"},
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("got %+v, want %+v", q, want)
+	}
+
+	plain, err := parseQueryFile("count.sql", []byte("SELECT 1;\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.ID != "count" || plain.Name != "count" || plain.Folder != "" || plain.SQL != "SELECT 1;" {
+		t.Errorf("plain file = %+v", plain)
+	}
+
+	if _, err := parseQueryFile("bad.sql", []byte("-- ---\n-- name: x\nSELECT 1")); err == nil {
+		t.Error("expected an error for unclosed front-matter")
+	}
+}
+
+func TestExportShared(t *testing.T) {
+	m, _ := layeredWorkspace(t)
+	_ = m.UpdateConnection(models.Connection{
+		Name: "local", Type: models.SQLiteType, Database: "dev.db", SecretKeyID: "dbsmith-local",
+	})
+	_ = m.AddSavedQuery(models.SavedQuery{ID: "q1", Name: "Top Orders!", SQL: "SELECT 1", Tags: []string{"sales"}})
+
+	out := t.TempDir()
+	file := filepath.Join(out, "team.yaml")
+	if err := m.ExportShared(file); err != nil {
+		t.Fatalf("ExportShared file: %v", err)
+	}
+	data, _ := os.ReadFile(file)
+	for _, leak := range []string{"secret_key_id", "created_at", "last_modified", "execution_count"} {
+		if strings.Contains(string(data), leak) {
+			t.Errorf("exported file contains %s:\n%s", leak, data)
+		}
+	}
+
+	// Exporting again must produce identical bytes.
+	if err := m.ExportShared(file); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(file); string(again) != string(data) {
+		t.Error("export is not stable")
+	}
+
+	dir := filepath.Join(out, "team") + string(filepath.Separator)
+	if err := m.ExportShared(dir); err != nil {
+		t.Fatalf("ExportShared dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "reports", "daily-signups.sql")); err != nil {
+		t.Errorf("expected a .sql file per query: %v", err)
+	}
+
+	sf, err := loadShared(dir)
+	if err != nil {
+		t.Fatalf("loadShared: %v", err)
+	}
+	if len(sf.Connections) != 2 || len(sf.SavedQueries) != 2 {
+		t.Fatalf("round trip = %+v", sf)
+	}
+	if q := sf.SavedQueries[0]; q.ID != "q1" || q.Name != "Top Orders!" || q.Tags[0] != "sales" {
+		t.Errorf("round-tripped query = %+v", q)
+	}
+}
@@ -0,0 +1,32 @@
+package workspace
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+// SnippetKey identifies a snippet by name and dialects, so one name can have
+// a variant per dialect.
+func SnippetKey(s models.Snippet) string {
+	dialects := make([]string, len(s.Dialects))
+	for i, d := range s.Dialects {
+		dialects[i] = strings.ToLower(strings.TrimSpace(d))
+	}
+	sort.Strings(dialects)
+	key := strings.ToLower(s.Name)
+	if len(dialects) > 0 {
+		key += " (" + strings.Join(dialects, ", ") + ")"
+	}
+	return key
+}
+
+// ListSnippets returns the shared snippets, replaced by personal ones with
+// the same key, followed by personal-only snippets.
+func (m *Manager) ListSnippets() []models.Snippet {
+	if m.shared == nil {
+		return append([]models.Snippet{}, m.workspace.Snippets...)
+	}
+	return layer(m.shared.Snippets, m.workspace.Snippets, SnippetKey)
+}
@@ -0,0 +1,61 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
+)
+
+func TestSnippetKey(t *testing.T) {
+	a := models.Snippet{Name: "Upsert", Dialects: []string{"SQLite", "postgres"}}
+	b := models.Snippet{Name: "upsert", Dialects: []string{"postgres", "sqlite"}}
+	if SnippetKey(a) != SnippetKey(b) {
+		t.Fatalf("%q != %q", SnippetKey(a), SnippetKey(b))
+	}
+	if SnippetKey(a) == SnippetKey(models.Snippet{Name: "upsert", Dialects: []string{"mysql"}}) {
+		t.Fatal("dialect variants should have different keys")
+	}
+}
+
+func TestListSnippetsLayersShared(t *testing.T) {
+	dir := t.TempDir()
+	shared := `snippets:
+  - name: recent
+    body: SELECT * FROM $1 ORDER BY created_at DESC LIMIT 50
+  - name: locks
+    body: SELECT * FROM pg_locks
+    dialects: [postgres]
+`
+	if err := os.WriteFile(filepath.Join(dir, "shared.yaml"), []byte(shared), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	personal := New()
+	personal.GetWorkspace().Shared = "shared.yaml"
+	personal.GetWorkspace().Snippets = []models.Snippet{
+		{Name: "recent", Body: "SELECT * FROM $1 ORDER BY id DESC LIMIT 10"},
+		{Name: "mine", Body: "SELECT 1"},
+	}
+	wsPath := filepath.Join(dir, "workspace.yaml")
+	if err := personal.Save(wsPath); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(wsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippets := m.ListSnippets()
+	var names []string
+	for _, s := range snippets {
+		names = append(names, s.Name)
+	}
+	if len(names) != 3 || names[0] != "locks" || names[1] != "recent" || names[2] != "mine" {
+		t.Fatalf("snippets = %v", names)
+	}
+	if snippets[1].Body != "SELECT * FROM $1 ORDER BY id DESC LIMIT 10" {
+		t.Errorf("personal snippet should override the shared one, got %q", snippets[1].Body)
+	}
+}
@@ -5,8 +5,22 @@ package integration
 
 import (
 	"testing"
+
+	"github.com/android-lewis/dbsmith/internal/models"
 )
 
+// assertForeignKey fails unless keys holds table(column) -> refTable(refColumn).
+func assertForeignKey(t *testing.T, keys []models.ForeignKey, table, column, refTable, refColumn string) {
+	t.Helper()
+	for _, fk := range keys {
+		if fk.Table == table && fk.RefTable == refTable && len(fk.Columns) == 1 &&
+			fk.Columns[0] == column && fk.RefColumns[0] == refColumn {
+			return
+		}
+	}
+	t.Errorf("foreign key %s(%s) -> %s(%s) not found in %+v", table, column, refTable, refColumn, keys)
+}
+
 // =============================================================================
 // Secrets Manager Tests
 // =============================================================================
@@ -210,6 +210,13 @@ func TestPostgresSchemaOperations(t *testing.T) {
 	// The important thing is that the query succeeded without error
 	t.Logf("Found %d indexes on users table", len(indexes))
 
+	// Test getting foreign keys
+	keys, err := driver.GetForeignKeys(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get foreign keys: %v", err)
+	}
+	assertForeignKey(t, keys, "comments", "post_id", "posts", "id")
+
 	// Cleanup
 	setup.Cleanup(t)
 }
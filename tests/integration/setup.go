@@ -143,7 +143,7 @@ func SetupPostgresContainer(t *testing.T) *PostgresTestContainer {
 	}
 
 	tmpDir := t.TempDir()
-	secretsMgr, err := secrets.NewManager(tmpDir)
+	secretsMgr, err := secrets.NewManager(tmpDir, secrets.CommandOptions{})
 	if err != nil {
 		t.Fatalf("Failed to create secrets manager: %v", err)
 	}
@@ -316,7 +316,7 @@ exec docker-entrypoint.sh "$@"
 
 	// 5. Setup secrets manager
 	tmpDir := t.TempDir()
-	secretsMgr, err := secrets.NewManager(tmpDir)
+	secretsMgr, err := secrets.NewManager(tmpDir, secrets.CommandOptions{})
 	if err != nil {
 		t.Fatalf("Failed to create secrets manager: %v", err)
 	}
@@ -443,7 +443,7 @@ func SetupMySQLContainer(t *testing.T) *MySQLTestContainer {
 	}
 
 	tmpDir := t.TempDir()
-	secretsMgr, err := secrets.NewManager(tmpDir)
+	secretsMgr, err := secrets.NewManager(tmpDir, secrets.CommandOptions{})
 	if err != nil {
 		t.Fatalf("Failed to create secrets manager: %v", err)
 	}
@@ -511,7 +511,7 @@ func SetupSQLite(t *testing.T) *TestDatabaseSetup {
 	t.Helper()
 
 	tmpDir := t.TempDir()
-	secretsMgr, err := secrets.NewManager(tmpDir)
+	secretsMgr, err := secrets.NewManager(tmpDir, secrets.CommandOptions{})
 	if err != nil {
 		t.Fatalf("Failed to create secrets manager: %v", err)
 	}
@@ -193,6 +193,13 @@ func TestSQLiteSchemaOperations(t *testing.T) {
 		t.Fatal("Expected at least one column in users table")
 	}
 
+	// Test getting foreign keys
+	keys, err := driver.GetForeignKeys(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get foreign keys: %v", err)
+	}
+	assertForeignKey(t, keys, "comments", "post_id", "posts", "id")
+
 	// Cleanup
 	setup.Cleanup(t)
 }